@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+// apiBackend runs the commands through the admin API
+type apiBackend struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+func newAPIBackend(server, token string) (*apiBackend, error) {
+	if token == "" {
+		return nil, errors.New("an admin access token is required, set -token or CHATMIX_TOKEN (or use -offline)")
+	}
+	return &apiBackend{
+		server: strings.TrimSuffix(server, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// ListUsers pages through the user directory, which returns at most 200 users a request
+func (b *apiBackend) ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	query := url.Values{}
+	for name, value := range map[string]*time.Time{
+		"registered_after":  filter.JoinedAfter,
+		"registered_before": filter.JoinedBefore,
+		"last_seen_before":  filter.InactiveSince,
+	} {
+		if value != nil {
+			query.Set(name, value.UTC().Format(time.RFC3339))
+		}
+	}
+	if filter.Unverified {
+		query.Set("verified", "false")
+	}
+	query.Set("limit", "200")
+
+	var users []*model.User
+	for {
+		var response struct {
+			Users      []*model.User `json:"users"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := b.do(ctx, http.MethodGet, "/api/admin/users?"+query.Encode(), &response); err != nil {
+			return nil, err
+		}
+		users = append(users, response.Users...)
+		if response.NextCursor == "" {
+			return users, nil
+		}
+		query.Set("cursor", response.NextCursor)
+	}
+}
+
+func (b *apiBackend) SetBanned(ctx context.Context, username string, banned bool) (*model.User, error) {
+	method := http.MethodPost
+	if !banned {
+		method = http.MethodDelete
+	}
+	var user model.User
+	if err := b.do(ctx, method, "/api/admin/users/"+url.PathEscape(username)+"/ban", &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (b *apiBackend) RevokeSessions(ctx context.Context, username string) error {
+	return b.do(ctx, http.MethodDelete, "/api/admin/users/"+url.PathEscape(username)+"/sessions", nil)
+}
+
+func (b *apiBackend) PurgeExpired(ctx context.Context) error {
+	return b.do(ctx, http.MethodDelete, "/api/admin/tokens/expired", nil)
+}
+
+func (b *apiBackend) AuditLogs(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error) {
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if filter.Action != "" {
+		query.Set("action", filter.Action)
+	}
+	if filter.Target != "" {
+		query.Set("target", filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.UTC().Format(time.RFC3339Nano))
+	}
+
+	var response struct {
+		Entries []*model.AuditLog `json:"entries"`
+	}
+	err := b.do(ctx, http.MethodGet, "/api/admin/audit?"+query.Encode(), &response)
+	return response.Entries, err
+}
+
+func (b *apiBackend) Close() {}
+
+// do sends a request and decodes the JSON response into out, when not nil
+func (b *apiBackend) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, b.server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var body struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &body) != nil || body.Error == "" {
+			body.Error = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, body.Error)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
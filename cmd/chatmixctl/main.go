@@ -0,0 +1,274 @@
+// Command chatmixctl is an operator client for the admin API:
+//
+//	chatmixctl users [-unverified] [-inactive-for 720h]
+//	chatmixctl ban <username> | unban <username>
+//	chatmixctl revoke <username>
+//	chatmixctl purge-tokens
+//	chatmixctl audit [-action a] [-target t] [-since 1h] [-n 50] [-f]
+//
+// It calls the server at -server (CHATMIX_SERVER) with the access token of an admin in
+// -token (CHATMIX_TOKEN). With -offline it works on the database of the server config
+// instead, see config.ResolvePath, for when the server is down.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+// backend runs the commands, through the admin API or on the database
+type backend interface {
+	ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, error)
+	SetBanned(ctx context.Context, username string, banned bool) (*model.User, error)
+	RevokeSessions(ctx context.Context, username string) error
+	PurgeExpired(ctx context.Context) error
+	AuditLogs(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error)
+	Close()
+}
+
+// auditPollInterval is how often audit -f checks for new entries
+const auditPollInterval = 2 * time.Second
+
+var jsonOutput bool
+
+func main() {
+	flag.Usage = usage
+	server := flag.String("server", envOr("CHATMIX_SERVER", "http://localhost:8080"), "server URL")
+	token := flag.String("token", os.Getenv("CHATMIX_TOKEN"), "access token of an admin")
+	offline := flag.Bool("offline", false, "work on the database instead of the admin API")
+	flag.BoolVar(&jsonOutput, "json", false, "print JSON")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var b backend
+	var err error
+	if *offline {
+		b, err = newOfflineBackend()
+	} else {
+		b, err = newAPIBackend(*server, *token)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	defer b.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, b, flag.Arg(0), flag.Args()[1:]); err != nil {
+		b.Close()
+		fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: chatmixctl [flags] <command> [args]
+
+Commands:
+  users [-unverified] [-inactive-for d] [-joined-within d]   list users
+  ban <username>                                             ban a user and end their sessions
+  unban <username>                                           lift a ban
+  revoke <username>                                          end every session of a user
+  purge-tokens                                               delete expired tokens, sessions and captchas
+  audit [-action a] [-target t] [-since d] [-n 50] [-f]      show, or follow, the audit log
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func run(ctx context.Context, b backend, command string, args []string) error {
+	switch command {
+	case "users":
+		return listUsers(ctx, b, args)
+	case "ban", "unban":
+		username, err := usernameArg(command, args)
+		if err != nil {
+			return err
+		}
+		user, err := b.SetBanned(ctx, username, command == "ban")
+		if err != nil {
+			return err
+		}
+		return printResult(user, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "%s\tbanned=%t\n", user.Username, user.IsBanned)
+		})
+	case "revoke":
+		username, err := usernameArg(command, args)
+		if err != nil {
+			return err
+		}
+		if err := b.RevokeSessions(ctx, username); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Revoked the sessions of %s\n", username)
+		return nil
+	case "purge-tokens":
+		if err := b.PurgeExpired(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Purged expired tokens, sessions and captchas")
+		return nil
+	case "audit":
+		return auditLogs(ctx, b, args)
+	default:
+		return fmt.Errorf("unknown command %q, see chatmixctl -h", command)
+	}
+}
+
+func listUsers(ctx context.Context, b backend, args []string) error {
+	flags := flag.NewFlagSet("users", flag.ExitOnError)
+	unverified := flags.Bool("unverified", false, "only users with an unverified email")
+	inactiveFor := flags.Duration("inactive-for", 0, "only users not seen for this long")
+	joinedWithin := flags.Duration("joined-within", 0, "only users who joined this recently")
+	flags.Parse(args)
+
+	filter := model.UserFilter{Unverified: *unverified}
+	now := time.Now()
+	if *inactiveFor > 0 {
+		since := now.Add(-*inactiveFor)
+		filter.InactiveSince = &since
+	}
+	if *joinedWithin > 0 {
+		after := now.Add(-*joinedWithin)
+		filter.JoinedAfter = &after
+	}
+
+	users, err := b.ListUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printResult(users, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "USERNAME\tEMAIL\tROLE\tVERIFIED\tBANNED\tJOINED\tLAST SEEN")
+		for _, user := range users {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%s\t%s\n", user.Username, user.Email, user.EffectiveRole(),
+				user.IsVerified, user.IsBanned, user.JoinedAt.Format(time.DateOnly), user.LastSeen.Format(time.DateTime))
+		}
+	})
+}
+
+func auditLogs(ctx context.Context, b backend, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	action := flags.String("action", "", "only entries with this action, such as admin.users.ban")
+	target := flags.String("target", "", "only entries with this target")
+	since := flags.Duration("since", 0, "only entries of this recent period")
+	limit := flags.Int("n", 50, "number of entries to show")
+	follow := flags.Bool("f", false, "keep printing new entries")
+	flags.Parse(args)
+
+	filter := model.AuditFilter{Action: *action, Target: *target}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	entries, err := b.AuditLogs(ctx, filter, *limit)
+	if err != nil {
+		return err
+	}
+	// Entries come newest first; print them in order like a log
+	seen := make(map[string]bool)
+	for i := len(entries) - 1; i >= 0; i-- {
+		printAuditLog(entries[i])
+		seen[entries[i].ID.Hex()] = true
+	}
+	if !*follow {
+		return nil
+	}
+
+	if len(entries) > 0 {
+		filter.Since = entries[0].CreatedAt
+	} else if filter.Since.IsZero() {
+		filter.Since = time.Now()
+	}
+	ticker := time.NewTicker(auditPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		entries, err := b.AuditLogs(ctx, filter, 500)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Failed to get audit logs: %v\n", err)
+			continue
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			if seen[entries[i].ID.Hex()] {
+				continue
+			}
+			printAuditLog(entries[i])
+			seen[entries[i].ID.Hex()] = true
+			filter.Since = entries[i].CreatedAt
+		}
+	}
+}
+
+func printAuditLog(entry *model.AuditLog) {
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(entry)
+		return
+	}
+	line := fmt.Sprintf("%s  %-16s %s", entry.CreatedAt.Local().Format(time.DateTime), entry.Actor, entry.Action)
+	if entry.Target != "" {
+		line += " " + entry.Target
+	}
+	if len(entry.Details) > 0 {
+		details, _ := json.Marshal(entry.Details)
+		line += " " + string(details)
+	}
+	if entry.IPAddress != "" {
+		line += " from " + entry.IPAddress
+	}
+	fmt.Println(line)
+}
+
+// printResult writes value as JSON with -json, or as the table of fn
+func printResult(value interface{}, fn func(w *tabwriter.Writer)) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fn(w)
+	return w.Flush()
+}
+
+func usernameArg(command string, args []string) (string, error) {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return "", fmt.Errorf("usage: chatmixctl %s <username>", command)
+	}
+	return strings.TrimSpace(args[0]), nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "chatmixctl: %v\n", err)
+	os.Exit(1)
+}
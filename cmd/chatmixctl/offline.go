@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/internal/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// offlineBackend runs the commands on the database. Changes are recorded in the audit
+// log like those made through the API, with the local user as the actor.
+type offlineBackend struct {
+	db    *repository.Database
+	users service.UserAdminService
+	audit service.AuditService
+	actor string
+}
+
+func newOfflineBackend() (*offlineBackend, error) {
+	cfg, err := config.Load(config.ResolvePath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := repository.NewDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	logger.SetLevel(logrus.WarnLevel)
+
+	actor := "chatmixctl"
+	if current, err := user.Current(); err == nil {
+		actor += ":" + current.Username
+	}
+
+	return &offlineBackend{
+		db:    db,
+		users: service.NewUserAdminService(db.UserRepo, db.RefreshTokenRepo, db.SessionRepo, db.CaptchaRepo, logger),
+		audit: service.NewAuditService(db.AuditRepo, logger),
+		actor: actor,
+	}, nil
+}
+
+func (b *offlineBackend) ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	return b.users.ListUsers(ctx, filter)
+}
+
+func (b *offlineBackend) SetBanned(ctx context.Context, username string, banned bool) (*model.User, error) {
+	user, err := b.users.SetBanned(ctx, username, banned)
+	if err != nil {
+		return nil, err
+	}
+	action := model.AuditActionUnbanUser
+	if banned {
+		action = model.AuditActionBanUser
+	}
+	b.record(ctx, action, user.Username)
+	return user, nil
+}
+
+func (b *offlineBackend) RevokeSessions(ctx context.Context, username string) error {
+	user, err := b.users.RevokeSessions(ctx, username)
+	if err != nil {
+		return err
+	}
+	b.record(ctx, model.AuditActionRevokeUserSessions, user.Username)
+	return nil
+}
+
+func (b *offlineBackend) PurgeExpired(ctx context.Context) error {
+	if err := b.users.PurgeExpired(ctx); err != nil {
+		return err
+	}
+	b.record(ctx, model.AuditActionPurgeTokens, "")
+	return nil
+}
+
+func (b *offlineBackend) AuditLogs(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error) {
+	return b.audit.Find(ctx, filter, limit)
+}
+
+func (b *offlineBackend) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	b.db.Close(ctx)
+}
+
+func (b *offlineBackend) record(ctx context.Context, action, target string) {
+	entry := model.NewAuditLog(nil, action, target, "")
+	entry.Actor = b.actor
+	b.audit.Record(ctx, entry)
+}
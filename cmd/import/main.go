@@ -0,0 +1,143 @@
+// Command import creates accounts from a CSV or JSON export of another system, with the
+// same validation as POST /api/admin/users/import:
+//
+//	go run ./cmd/import -file users.csv -dry-run
+//	go run ./cmd/import -file users.csv -credentials passwords.csv
+//
+// The database is the one of the server config, see config.ResolvePath.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/internal/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	file := flag.String("file", "", "CSV or JSON file of the accounts to import")
+	format := flag.String("format", "", "csv or json (default: from the file extension)")
+	dryRun := flag.Bool("dry-run", false, "validate the records without creating accounts")
+	credentials := flag.String("credentials", "", "CSV file to write the generated passwords of temp_password accounts to")
+	actor := flag.String("actor", "import-cli", "name recorded as the creator of the import")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	cfg, err := config.Load(config.ResolvePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	input, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", *file, err)
+		os.Exit(1)
+	}
+	records, err := service.ParseUserImport(input, *format)
+	input.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", *file, err)
+		os.Exit(1)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stderr, service.ErrUserImportEmpty)
+		os.Exit(1)
+	}
+	// Generated passwords are only shown once, so they must go somewhere
+	if !*dryRun && *credentials == "" {
+		for _, record := range records {
+			if record.TempPassword {
+				fmt.Fprintln(os.Stderr, "Records with temp_password need -credentials to save the generated passwords")
+				os.Exit(2)
+			}
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	logger.SetLevel(logrus.WarnLevel)
+
+	db, err := repository.NewDatabase(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Close(ctx)
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	importService := service.NewUserImportService(db.UserRepo, cfg, logger)
+	job := importService.Import(ctx, *actor, records, *dryRun, func(job *model.UserImportJob) {
+		fmt.Fprintf(os.Stderr, "\r%d/%d processed, %d failed", job.Processed, job.Total, job.Failed)
+	})
+	fmt.Fprintln(os.Stderr)
+
+	for _, failure := range job.Errors {
+		fmt.Printf("row %d (%s): %s\n", failure.Row, failure.Username, failure.Error)
+	}
+	if job.Failed > len(job.Errors) {
+		fmt.Printf("... and %d more failures\n", job.Failed-len(job.Errors))
+	}
+
+	verb := "Created"
+	if job.DryRun {
+		verb = "Would create"
+	}
+	fmt.Printf("%s %d of %d accounts, %d failed\n", verb, job.Created, job.Total, job.Failed)
+
+	if len(job.Credentials) > 0 {
+		if err := writeCredentials(*credentials, job.Credentials); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write credentials: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d generated passwords to %s\n", len(job.Credentials), *credentials)
+	}
+
+	if job.Status == model.BulkJobFailed {
+		fmt.Fprintf(os.Stderr, "Import failed: %s\n", job.Error)
+		os.Exit(1)
+	}
+}
+
+// writeCredentials writes the generated passwords, readable by the owner only
+func writeCredentials(path string, credentials []model.UserImportCredential) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"username", "email", "password"})
+	for _, credential := range credentials {
+		writer.Write([]string{credential.Username, credential.Email, credential.Password})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// messagePrefix marks the messages of the load test; the rest of the text is the send
+// time in Unix nanoseconds, which the receiving client turns into a delivery latency
+const messagePrefix = "lt:"
+
+// httpError is a response with an error status
+type httpError struct {
+	status     int
+	retryAfter time.Duration
+	message    string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%d %s", e.status, e.message)
+}
+
+// errorKind names an error for the error counts of the report
+func errorKind(err error) string {
+	var httpErr *httpError
+	var closeErr *websocket.CloseError
+	switch {
+	case errors.As(err, &httpErr):
+		return "http_" + strconv.Itoa(httpErr.status)
+	case errors.As(err, &closeErr):
+		return "close_" + strconv.Itoa(closeErr.Code)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "network"
+	}
+}
+
+// client simulates one user: it signs in, starts a chat, and talks to its partner
+// until the test ends, starting a new chat whenever the partner leaves
+type client struct {
+	cfg      *settings
+	stats    *stats
+	http     *http.Client
+	username string
+	token    string
+	rng      *rand.Rand
+}
+
+func newClient(cfg *settings, stats *stats, httpClient *http.Client, index int) *client {
+	return &client{
+		cfg:      cfg,
+		stats:    stats,
+		http:     httpClient,
+		username: fmt.Sprintf("%s%05d", cfg.prefix, index),
+		rng:      rand.New(rand.NewSource(int64(index))),
+	}
+}
+
+func (c *client) run(ctx context.Context) {
+	started := time.Now()
+	if err := c.authenticate(ctx); err != nil {
+		if ctx.Err() == nil {
+			c.stats.fail(opAuth, errorKind(err))
+		}
+		return
+	}
+	c.stats.record(opAuth, time.Since(started))
+
+	for ctx.Err() == nil {
+		roomToken, err := c.startChat(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.stats.fail(opStart, errorKind(err))
+				c.pause(ctx, retryDelay(err))
+			}
+			continue
+		}
+		if err := c.chat(ctx, roomToken); err != nil && ctx.Err() == nil {
+			c.pause(ctx, time.Second)
+		}
+	}
+}
+
+// authenticate logs in, registering the account on its first run
+func (c *client) authenticate(ctx context.Context) error {
+	var response struct {
+		Token string `json:"token"`
+	}
+	body := map[string]interface{}{"username": c.username, "password": c.cfg.password}
+	err := c.withCaptcha(ctx, "/api/auth/login", body, &response)
+
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.status == http.StatusUnauthorized {
+		body["email"] = c.username + "@loadtest.invalid"
+		body["age"] = 18 + c.rng.Intn(40)
+		body["gender"] = "private"
+		err = c.withCaptcha(ctx, "/api/auth/register", body, &response)
+	}
+	if err != nil {
+		return err
+	}
+	if response.Token == "" {
+		return &httpError{status: http.StatusAccepted, message: "login needs verification"}
+	}
+	c.token = response.Token
+	return nil
+}
+
+// withCaptcha posts body with the solution of a builtin math captcha, retrying after
+// rate limiting
+func (c *client) withCaptcha(ctx context.Context, path string, body map[string]interface{}, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		var captcha struct {
+			Provider    string `json:"provider"`
+			ChallengeID string `json:"challenge_id"`
+			Challenge   string `json:"challenge"`
+		}
+		err := c.do(ctx, http.MethodGet, "/api/auth/captcha", nil, &captcha)
+		if err == nil && captcha.ChallengeID != "" {
+			answer, solveErr := solveCaptcha(captcha.Challenge)
+			if solveErr != nil {
+				return solveErr
+			}
+			body["captcha"] = captcha.ChallengeID
+			body["captcha_answer"] = answer
+		}
+		if err == nil {
+			err = c.do(ctx, http.MethodPost, path, body, out)
+		}
+
+		var httpErr *httpError
+		if !errors.As(err, &httpErr) || httpErr.status != http.StatusTooManyRequests || attempt >= 5 {
+			return err
+		}
+		c.stats.fail(opAuth, errorKind(err))
+		c.pause(ctx, retryDelay(err))
+	}
+}
+
+// solveCaptcha answers a builtin captcha such as "3 × 4 = ?"
+func solveCaptcha(challenge string) (string, error) {
+	var a, b int
+	var op string
+	if _, err := fmt.Sscanf(challenge, "%d %s %d", &a, &op, &b); err != nil {
+		return "", fmt.Errorf("unexpected captcha %q, disable features.captcha_enabled or use the builtin provider", challenge)
+	}
+	switch op {
+	case "+":
+		return strconv.Itoa(a + b), nil
+	case "-":
+		return strconv.Itoa(a - b), nil
+	case "×":
+		return strconv.Itoa(a * b), nil
+	}
+	return "", fmt.Errorf("unexpected captcha %q", challenge)
+}
+
+// startChat asks for a room until one is assigned, polling while queued, and returns
+// the room token to connect with
+func (c *client) startChat(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.matchTimeout)
+	defer cancel()
+
+	started := time.Now()
+	path := "/api/chat/start?username=" + url.QueryEscape(c.username)
+	for {
+		var response struct {
+			Status    string `json:"status"`
+			RoomToken string `json:"room_token"`
+		}
+		if err := c.do(ctx, http.MethodPost, path, nil, &response); err != nil {
+			return "", err
+		}
+		if response.Status == "room_assigned" && response.RoomToken != "" {
+			c.stats.record(opStart, time.Since(started))
+			return response.RoomToken, nil
+		}
+		if !c.pause(ctx, c.cfg.queuePoll) {
+			return "", ctx.Err()
+		}
+	}
+}
+
+// chat talks in the room until the test ends or the partner leaves
+func (c *client) chat(ctx context.Context, roomToken string) error {
+	wsURL := strings.Replace(c.cfg.server, "http", "ws", 1) + "/ws/chat?room_token=" + url.QueryEscape(roomToken)
+	header := http.Header{"Authorization": {"Bearer " + c.token}}
+
+	started := time.Now()
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, resp, err := dialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil {
+			err = &httpError{status: resp.StatusCode, message: "handshake refused"}
+		}
+		c.stats.fail(opConnect, errorKind(err))
+		return err
+	}
+	c.stats.record(opConnect, time.Since(started))
+	c.stats.count(0, 0, 1)
+	defer conn.Close()
+
+	// The reader ends the chat when the partner leaves or the socket closes
+	done := make(chan error, 1)
+	go func() { done <- c.read(conn) }()
+
+	interval := time.Duration(float64(time.Second) / c.cfg.rate)
+	timer := time.NewTimer(c.jitter(interval))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			<-done
+			return nil
+		case err := <-done:
+			if err != nil {
+				c.stats.fail(opDelivery, errorKind(err))
+			}
+			return err
+		case <-timer.C:
+			frame, _ := json.Marshal(map[string]string{
+				"type": "message",
+				"text": messagePrefix + strconv.FormatInt(time.Now().UnixNano(), 10),
+			})
+			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				c.stats.fail(opDelivery, errorKind(err))
+				return err
+			}
+			c.stats.count(1, 0, 0)
+			timer.Reset(c.jitter(interval))
+		}
+	}
+}
+
+// read records the delivery latency of the partner's messages. It returns nil when the
+// partner left or the socket closed normally.
+func (c *client) read(conn *websocket.Conn) error {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+
+		var frame struct {
+			Type string `json:"type"`
+			From string `json:"from"`
+			Text string `json:"text"`
+			Code int    `json:"code"`
+		}
+		if json.Unmarshal(data, &frame) != nil {
+			continue
+		}
+		switch {
+		case frame.Type == "message" && frame.From != c.username && strings.HasPrefix(frame.Text, messagePrefix):
+			if sent, err := strconv.ParseInt(strings.TrimPrefix(frame.Text, messagePrefix), 10, 64); err == nil {
+				c.stats.record(opDelivery, time.Since(time.Unix(0, sent)))
+				c.stats.count(0, 1, 0)
+			}
+		case frame.Type == "error" && frame.Code != 0:
+			c.stats.fail(opDelivery, "error_"+strconv.Itoa(frame.Code))
+		case frame.Type == "system" && frame.From != c.username && strings.HasSuffix(frame.Text, "đã rời khỏi phòng chat"):
+			return nil
+		}
+	}
+}
+
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		httpErr := &httpError{status: resp.StatusCode, message: resp.Status}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			httpErr.retryAfter = time.Duration(seconds) * time.Second
+		}
+		return httpErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// pause sleeps for d, reporting false when the context ended first
+func (c *client) pause(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// jitter spreads sends over ±50% of the interval, so clients do not send in lockstep
+func (c *client) jitter(interval time.Duration) time.Duration {
+	return interval/2 + time.Duration(c.rng.Int63n(int64(interval)+1))
+}
+
+// retryDelay honors Retry-After of a refused request
+func retryDelay(err error) time.Duration {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.retryAfter > 0 {
+		return httpErr.retryAfter
+	}
+	return time.Second
+}
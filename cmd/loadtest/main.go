@@ -0,0 +1,105 @@
+// Command loadtest drives a running server with simulated chat users:
+//
+//	go run ./cmd/loadtest -server http://localhost:8080 -clients 2000 -ramp 30s -duration 2m -rate 0.5
+//
+// Each client logs in, registering loadtest accounts on the first run, starts a chat,
+// connects to the room socket and sends messages at -rate per second until -duration
+// ends, starting a new chat whenever its partner leaves. The report lists latency
+// percentiles and error counts of sign-in, matchmaking, socket handshakes and message
+// delivery. Run it against a development server with the builtin captcha (or
+// features.captcha_enabled off), and raise the rate limits to test more than them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type settings struct {
+	server       string
+	prefix       string
+	password     string
+	rate         float64
+	matchTimeout time.Duration
+	queuePoll    time.Duration
+}
+
+func main() {
+	cfg := &settings{}
+	flag.StringVar(&cfg.server, "server", "http://localhost:8080", "server URL")
+	flag.StringVar(&cfg.prefix, "prefix", "loadtest_", "username prefix of the simulated users")
+	flag.StringVar(&cfg.password, "password", "loadtest123", "password of the simulated users")
+	flag.Float64Var(&cfg.rate, "rate", 0.5, "messages per second per client")
+	flag.DurationVar(&cfg.matchTimeout, "match-timeout", time.Minute, "how long a client waits for a room")
+	flag.DurationVar(&cfg.queuePoll, "queue-poll", 2*time.Second, "how often a queued client asks again")
+	clients := flag.Int("clients", 100, "number of simulated clients")
+	offset := flag.Int("offset", 0, "number of the first client, to run several load generators")
+	ramp := flag.Duration("ramp", 10*time.Second, "period over which the clients start")
+	duration := flag.Duration("duration", time.Minute, "test duration, the ramp included")
+	flag.Parse()
+
+	if *clients <= 0 || cfg.rate <= 0 || *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "loadtest: -clients, -rate and -duration must be positive")
+		os.Exit(2)
+	}
+	cfg.server = strings.TrimSuffix(cfg.server, "/")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	// One connection pool for all clients, sized so they do not queue on it
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = *clients
+	transport.MaxIdleConnsPerHost = *clients
+	transport.DialContext = (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	httpClient := &http.Client{Transport: transport, Timeout: 30 * time.Second}
+
+	results := newStats()
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fmt.Fprintf(os.Stderr, "[%s] %s\n", time.Since(started).Round(time.Second), results.progress())
+			}
+		}
+	}()
+
+	step := *ramp / time.Duration(*clients)
+	for i := 0; i < *clients; i++ {
+		if i > 0 && step > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(step):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			newClient(cfg, results, httpClient, index).run(ctx)
+		}(*offset + i + 1)
+	}
+
+	wg.Wait()
+	results.report(os.Stdout, time.Since(started))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations whose latency is recorded
+const (
+	opAuth     = "auth"     // login, or registration of a new account
+	opStart    = "start"    // POST /api/chat/start until a room is assigned, queueing included
+	opConnect  = "connect"  // WebSocket handshake
+	opDelivery = "delivery" // a message from send until the partner receives it
+)
+
+var operations = []string{opAuth, opStart, opConnect, opDelivery}
+
+// stats collects latencies and errors of every client
+type stats struct {
+	lock      sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]map[string]int // by operation and kind
+	sent      int
+	received  int
+	chats     int
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]map[string]int),
+	}
+}
+
+func (s *stats) record(op string, d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latencies[op] = append(s.latencies[op], d)
+}
+
+func (s *stats) fail(op, kind string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.errors[op] == nil {
+		s.errors[op] = make(map[string]int)
+	}
+	s.errors[op][kind]++
+}
+
+func (s *stats) count(sent, received, chats int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sent += sent
+	s.received += received
+	s.chats += chats
+}
+
+// progress returns a one-line summary for the periodic progress output
+func (s *stats) progress() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	failed := 0
+	for _, kinds := range s.errors {
+		for _, n := range kinds {
+			failed += n
+		}
+	}
+	return fmt.Sprintf("chats=%d sent=%d received=%d errors=%d", s.chats, s.sent, s.received, failed)
+}
+
+// report writes the latency percentiles and error rates of every operation
+func (s *stats) report(w io.Writer, elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fmt.Fprintf(w, "\n%-9s %8s %8s %8s %8s %8s %8s %8s\n", "OP", "OK", "ERRORS", "ERR%", "P50", "P90", "P99", "MAX")
+	for _, op := range operations {
+		latencies := s.latencies[op]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		failed := 0
+		for _, n := range s.errors[op] {
+			failed += n
+		}
+		rate := 0.0
+		if total := len(latencies) + failed; total > 0 {
+			rate = 100 * float64(failed) / float64(total)
+		}
+		fmt.Fprintf(w, "%-9s %8d %8d %7.2f%% %8s %8s %8s %8s\n", op, len(latencies), failed, rate,
+			percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99), percentile(latencies, 1))
+	}
+
+	fmt.Fprintf(w, "\n%d chats, %d messages sent (%.1f/s), %d delivered\n",
+		s.chats, s.sent, float64(s.sent)/elapsed.Seconds(), s.received)
+
+	for _, op := range operations {
+		if len(s.errors[op]) == 0 {
+			continue
+		}
+		kinds := make([]string, 0, len(s.errors[op]))
+		for kind, n := range s.errors[op] {
+			kinds = append(kinds, fmt.Sprintf("%s=%d", kind, n))
+		}
+		sort.Strings(kinds)
+		fmt.Fprintf(w, "%s errors: %s\n", op, strings.Join(kinds, " "))
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) string {
+	if len(sorted) == 0 {
+		return "-"
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i].Round(time.Millisecond / 10).String()
+}
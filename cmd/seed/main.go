@@ -0,0 +1,281 @@
+// Command seed fills the database of the server config with fake data for development
+// and load tests, so nobody has to register through the captcha flow:
+//
+//	go run ./cmd/seed -users 200 -rooms 50 -messages 20 -channels 3
+//
+// The same -seed always generates the same usernames, profiles and conversations.
+// Every seeded account has the username prefix -prefix and the password -password.
+// Accounts that already exist are left alone, so seeding again is safe.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	firstNames = []string{
+		"an", "binh", "chi", "dung", "giang", "ha", "hieu", "hoa", "khanh", "lan",
+		"linh", "long", "mai", "minh", "nam", "ngoc", "phuong", "quan", "thao", "trang",
+		"tuan", "vy", "alex", "emma", "kenji", "lucas", "mia", "noah", "sofia", "yuki",
+	}
+	bios = []string{
+		"Thích cà phê sáng và nhạc indie",
+		"Đang học tiếng Nhật, ai luyện cùng không?",
+		"Mê phim Ghibli, ghét trời mưa",
+		"Dân IT, rảnh thì chạy bộ",
+		"Looking for people to practice English with",
+		"Gamer, cat person, night owl",
+		"Hay đi phượt cuối tuần",
+		"",
+	}
+	languages = []string{"vi", "en", "ja", "ko", "fr"}
+	genders   = []model.Gender{model.GenderMale, model.GenderFemale, model.GenderOther, model.GenderPrivate}
+	lines     = []string{
+		"Chào bạn!", "Hi there", "Bạn ở đâu vậy?", "Mình ở Sài Gòn", "Hà Nội nè",
+		"Hôm nay bạn thế nào?", "Cũng ổn, hơi mệt", "Bạn thích nghe nhạc gì?", "Lo-fi với K-pop",
+		"Haha 😄", "Thật á?", "Mình cũng vậy", "What do you do for fun?", "Mostly games and movies",
+		"Cuối tuần này bạn làm gì?", "Chắc ngủ bù 😴", "Ok bye nhé", "Nice talking to you!",
+	}
+	channels = []model.ChannelRequest{
+		{Slug: "music", Name: "Âm nhạc", Description: "Chia sẻ bài hát bạn đang nghe"},
+		{Slug: "movies", Name: "Phim ảnh", Description: "Review phim, không spoil"},
+		{Slug: "games", Name: "Game", Description: "Tìm đồng đội và bàn chuyện game"},
+		{Slug: "language-exchange", Name: "Language exchange", Description: "Practice languages together"},
+		{Slug: "tech", Name: "Công nghệ", Description: "Code, gadget và mọi thứ liên quan"},
+	}
+)
+
+type seeder struct {
+	db       *repository.Database
+	rng      *rand.Rand
+	now      time.Time
+	prefix   string
+	hash     string
+	messages int
+}
+
+func main() {
+	users := flag.Int("users", 50, "number of users")
+	rooms := flag.Int("rooms", 0, "number of past two-person chats")
+	messages := flag.Int("messages", 20, "messages per chat and per channel")
+	channelCount := flag.Int("channels", 0, fmt.Sprintf("number of channels with members, at most %d", len(channels)))
+	seed := flag.Int64("seed", 1, "random seed")
+	prefix := flag.String("prefix", "seed_", "username prefix of seeded accounts")
+	password := flag.String("password", "chatmix123", "password of every seeded account")
+	flag.Parse()
+
+	if *users < 2 && (*rooms > 0 || *channelCount > 0) {
+		fatal(errors.New("rooms and channels need at least 2 users"))
+	}
+	*channelCount = min(*channelCount, len(channels))
+
+	cfg, err := config.Load(config.ResolvePath())
+	if err != nil {
+		fatal(fmt.Errorf("failed to load config: %w", err))
+	}
+	db, err := repository.NewDatabase(cfg)
+	if err != nil {
+		fatal(fmt.Errorf("failed to connect to database: %w", err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Close(ctx)
+	}()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(*password), cfg.Auth.Passwords.BcryptCost)
+	if err != nil {
+		fatal(err)
+	}
+
+	s := &seeder{
+		db:       db,
+		rng:      rand.New(rand.NewSource(*seed)),
+		now:      time.Now(),
+		prefix:   *prefix,
+		hash:     string(hash),
+		messages: *messages,
+	}
+
+	ctx := context.Background()
+	usernames, err := s.seedUsers(ctx, *users)
+	if err != nil {
+		db.Close(ctx)
+		fatal(err)
+	}
+	for i := 0; i < *rooms; i++ {
+		if err := s.seedRoom(ctx, usernames); err != nil {
+			db.Close(ctx)
+			fatal(err)
+		}
+	}
+	for _, channel := range channels[:*channelCount] {
+		if err := s.seedChannel(ctx, channel, usernames); err != nil {
+			db.Close(ctx)
+			fatal(err)
+		}
+	}
+
+	fmt.Printf("Seeded %d users (password %q), %d chats and %d channels\n", len(usernames), *password, *rooms, *channelCount)
+}
+
+// seedUsers creates the accounts and returns every seeded username, including those
+// that already existed
+func (s *seeder) seedUsers(ctx context.Context, n int) ([]string, error) {
+	users := make([]*model.User, n)
+	for i := range users {
+		users[i] = s.fakeUser(i)
+	}
+
+	usernames := make([]string, n)
+	created := 0
+	for start := 0; start < n; start += 100 {
+		batch := users[start:min(start+100, n)]
+		errs, err := s.db.UserRepo.CreateMany(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create users: %w", err)
+		}
+		for i, err := range errs {
+			if err != nil && !errors.Is(err, repository.ErrDuplicate) {
+				return nil, fmt.Errorf("failed to create %s: %w", batch[i].Username, err)
+			}
+			if err == nil {
+				created++
+			}
+		}
+	}
+	for i, user := range users {
+		usernames[i] = user.Username
+	}
+	fmt.Fprintf(os.Stderr, "Created %d users, %d already existed\n", created, n-created)
+	return usernames, nil
+}
+
+func (s *seeder) fakeUser(i int) *model.User {
+	name := firstNames[s.rng.Intn(len(firstNames))]
+	username := fmt.Sprintf("%s%s%d", s.prefix, name, i+1)
+	user := model.NewUserWithProfile(username, username+"@example.com", 16+s.rng.Intn(30),
+		genders[s.rng.Intn(len(genders))], bios[s.rng.Intn(len(bios))])
+	user.PasswordHash = s.hash
+	user.IsVerified = s.rng.Intn(10) < 8
+
+	// Mix of users online now, seen recently and long gone
+	user.JoinedAt = s.ago(365 * 24 * time.Hour)
+	user.LastSeen = user.JoinedAt.Add(time.Duration(s.rng.Int63n(int64(s.now.Sub(user.JoinedAt)) + 1)))
+	if s.rng.Intn(4) == 0 {
+		user.IsOnline = true
+		user.LastSeen = s.now
+	}
+	user.UpdatedAt = user.LastSeen
+
+	user.Languages = []string{languages[s.rng.Intn(len(languages))]}
+	if extra := languages[s.rng.Intn(len(languages))]; extra != user.Languages[0] && s.rng.Intn(2) == 0 {
+		user.Languages = append(user.Languages, extra)
+	}
+	return user
+}
+
+// seedRoom stores the messages of a past chat between two random users
+func (s *seeder) seedRoom(ctx context.Context, usernames []string) error {
+	code := s.roomCode()
+	a := usernames[s.rng.Intn(len(usernames))]
+	b := usernames[s.rng.Intn(len(usernames))]
+	for b == a {
+		b = usernames[s.rng.Intn(len(usernames))]
+	}
+	return s.seedMessages(ctx, code, []string{a, b})
+}
+
+// seedChannel creates a channel, unless it exists, with random members who talk in it
+func (s *seeder) seedChannel(ctx context.Context, request model.ChannelRequest, usernames []string) error {
+	channel := &model.Channel{
+		ID:          primitive.NewObjectID(),
+		Slug:        request.Slug,
+		Name:        request.Name,
+		Description: request.Description,
+		CreatedBy:   usernames[0],
+		CreatedAt:   s.now,
+		UpdatedAt:   s.now,
+	}
+	created, err := s.db.ChannelRepo.Create(ctx, channel)
+	if err != nil {
+		return fmt.Errorf("failed to create channel %s: %w", request.Slug, err)
+	}
+	if !created {
+		fmt.Fprintf(os.Stderr, "Channel %s already exists\n", request.Slug)
+		return nil
+	}
+
+	var members []string
+	for _, username := range usernames {
+		if s.rng.Intn(3) != 0 {
+			continue
+		}
+		member := &model.ChannelMember{
+			ID:       primitive.NewObjectID(),
+			Channel:  request.Slug,
+			Username: username,
+			JoinedAt: s.ago(30 * 24 * time.Hour),
+		}
+		if _, err := s.db.ChannelMemberRepo.Add(ctx, member); err != nil {
+			return fmt.Errorf("failed to add channel member: %w", err)
+		}
+		members = append(members, username)
+	}
+	if len(members) < 2 {
+		return nil
+	}
+	return s.seedMessages(ctx, channel.RoomCode(), members)
+}
+
+// seedMessages stores s.messages messages in the room, a few minutes apart and ending
+// at a random time in the last week
+func (s *seeder) seedMessages(ctx context.Context, roomCode string, members []string) error {
+	at := s.ago(7 * 24 * time.Hour).Add(-time.Duration(s.messages) * 3 * time.Minute)
+	for i := 0; i < s.messages; i++ {
+		message := model.NewMessage(roomCode, members[i%len(members)], lines[s.rng.Intn(len(lines))])
+		if len(members) > 2 {
+			message.From = members[s.rng.Intn(len(members))]
+		}
+		at = at.Add(time.Duration(10+s.rng.Intn(170)) * time.Second)
+		message.CreatedAt = at
+		if err := s.db.MessageRepo.Create(ctx, message); err != nil {
+			return fmt.Errorf("failed to create message: %w", err)
+		}
+	}
+	return nil
+}
+
+// roomCode returns an 8-character code like those of live rooms
+func (s *seeder) roomCode() string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	var code strings.Builder
+	for i := 0; i < 8; i++ {
+		code.WriteByte(alphabet[s.rng.Intn(len(alphabet))])
+	}
+	return code.String()
+}
+
+// ago returns a random time within the period before now
+func (s *seeder) ago(period time.Duration) time.Time {
+	return s.now.Add(-time.Duration(s.rng.Int63n(int64(period))))
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/internal/service"
+	"chatmix-backend/pkg/captcha"
+	"chatmix-backend/pkg/geoip"
+	"chatmix-backend/pkg/mailer"
+	"chatmix-backend/pkg/translate"
+)
+
+// minSecretBits is the least estimated entropy of an HS256 secret, see secretBits
+const minSecretBits = 128
+
+// providerCheckTimeout bounds the checks of providers without a configured timeout
+const providerCheckTimeout = 10 * time.Second
+
+// Outcomes of a check
+const (
+	checkPass = "PASS"
+	checkFail = "FAIL"
+	checkSkip = "SKIP" // the feature is disabled
+)
+
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+// runChecks is server --check: it loads the config, connects to the database and the
+// configured providers, and prints a report without starting the server. It returns the
+// exit code, 1 when a check failed.
+func runChecks(configPath string) int {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		printChecks([]checkResult{{"config", checkFail, err.Error()}})
+		return 1
+	}
+
+	results := []checkResult{{"config", checkPass, configPath}}
+	results = append(results, checkSigningKeys(cfg.Auth)...)
+	results = append(results,
+		checkDatabase(cfg),
+		checkSMTP(cfg.Email),
+		checkCaptcha(cfg.Captcha),
+		checkTranslation(cfg.Translation),
+		checkGeoIP(cfg.GeoIP),
+	)
+	printChecks(results)
+
+	for _, result := range results {
+		if result.status == checkFail {
+			return 1
+		}
+	}
+	return 0
+}
+
+func printChecks(results []checkResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.status, result.name, result.detail)
+	}
+	w.Flush()
+}
+
+func checked(name string, err error, detail string) checkResult {
+	if err != nil {
+		return checkResult{name, checkFail, err.Error()}
+	}
+	return checkResult{name, checkPass, detail}
+}
+
+// checkSigningKeys loads the JWT keys and checks the entropy of the HS256 secrets
+func checkSigningKeys(cfg config.AuthConfig) []checkResult {
+	active := cfg.ActiveKeyID
+	if active == "" {
+		active = "default"
+	}
+	results := []checkResult{checked("jwt keys", service.CheckSigningKeys(cfg), fmt.Sprintf("active key %q", active))}
+
+	if cfg.JWTSecret != "" {
+		results = append(results, checkSecret("auth.jwt_secret", cfg.JWTSecret))
+	}
+	for _, key := range cfg.SigningKeys {
+		if key.GetAlgorithm() == config.AlgorithmHS256 && key.Secret != "" {
+			results = append(results, checkSecret("signing key "+key.ID, key.Secret))
+		}
+	}
+	return results
+}
+
+func checkSecret(name, secret string) checkResult {
+	bits := secretBits(secret)
+	if bits < minSecretBits {
+		return checkResult{"jwt secret", checkFail, fmt.Sprintf("%s: about %.0f bits, use a random secret of at least %d bits", name, bits, minSecretBits)}
+	}
+	return checkResult{"jwt secret", checkPass, fmt.Sprintf("%s: about %.0f bits", name, bits)}
+}
+
+// secretBits estimates the entropy of a secret from the frequency of its characters.
+// It overrates secrets with patterns, but catches short and repetitive ones.
+func secretBits(secret string) float64 {
+	counts := map[rune]int{}
+	length := 0
+	for _, r := range secret {
+		counts[r]++
+		length++
+	}
+
+	perChar := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(length)
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * float64(length)
+}
+
+// checkDatabase connects to the database and lists the indexes or migrations the server
+// would still create
+func checkDatabase(cfg *config.Config) checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.Timeout)
+	defer cancel()
+
+	missing, err := repository.CheckDatabase(ctx, cfg)
+	if err != nil {
+		return checkResult{"database", checkFail, err.Error()}
+	}
+	if len(missing) > 0 {
+		return checkResult{"database", checkFail, "missing, created on startup: " + strings.Join(missing, "; ")}
+	}
+	return checkResult{"database", checkPass, fmt.Sprintf("%s, indexes and migrations up to date", cfg.GetDatabaseDriver())}
+}
+
+func checkSMTP(cfg config.EmailConfig) checkResult {
+	if !cfg.Enabled {
+		return checkResult{"smtp", checkSkip, "email disabled"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerCheckTimeout)
+	defer cancel()
+
+	smtp := mailer.NewSMTP(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From)
+	return checked("smtp", smtp.Check(ctx), fmt.Sprintf("%s:%d", cfg.Host, cfg.Port))
+}
+
+func checkCaptcha(cfg config.CaptchaConfig) checkResult {
+	var verifier *captcha.SiteVerify
+	switch cfg.GetProvider() {
+	case config.CaptchaHCaptcha:
+		verifier = captcha.NewHCaptcha(cfg.SecretKey, cfg.Timeout)
+	case config.CaptchaReCaptcha:
+		verifier = captcha.NewReCaptcha(cfg.SecretKey, cfg.MinScore, cfg.Timeout)
+	default:
+		return checkResult{"captcha", checkSkip, "builtin provider"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerCheckTimeout)
+	defer cancel()
+	return checked("captcha", verifier.CheckSecret(ctx), cfg.GetProvider())
+}
+
+// checkTranslation translates a word, which needs the endpoint and API key to work
+func checkTranslation(cfg config.TranslationConfig) checkResult {
+	if !cfg.Enabled {
+		return checkResult{"translation", checkSkip, "translation disabled"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerCheckTimeout)
+	defer cancel()
+
+	translator := translate.NewLibreTranslate(cfg.Endpoint, cfg.APIKey, cfg.Timeout)
+	_, err := translator.Translate(ctx, "hello", "en", "es")
+	return checked("translation", err, cfg.Endpoint)
+}
+
+func checkGeoIP(cfg config.GeoIPConfig) checkResult {
+	if !cfg.Enabled {
+		return checkResult{"geoip", checkSkip, "geoip disabled"}
+	}
+
+	maxmind, err := geoip.NewMaxMind(cfg.DatabasePath)
+	if err != nil {
+		return checkResult{"geoip", checkFail, err.Error()}
+	}
+	maxmind.Close()
+	return checkResult{"geoip", checkPass, cfg.DatabasePath}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,18 +11,34 @@ import (
 	"time"
 
 	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
 	"chatmix-backend/internal/handler"
 	"chatmix-backend/internal/repository"
 	"chatmix-backend/internal/router"
 	"chatmix-backend/internal/service"
+	"chatmix-backend/pkg/captcha"
+	"chatmix-backend/pkg/chatbot"
+	"chatmix-backend/pkg/geoip"
+	"chatmix-backend/pkg/mailer"
+	"chatmix-backend/pkg/metrics"
+	"chatmix-backend/pkg/translate"
 	"chatmix-backend/pkg/utils"
+	"chatmix-backend/web"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
+	check := flag.Bool("check", false, "check the config, database and providers, print a report and exit")
+	flag.Parse()
+
 	// Load configuration
-	configPath := getConfigPath()
+	configPath := config.ResolvePath()
+	if *check {
+		os.Exit(runChecks(configPath))
+	}
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
@@ -29,52 +46,299 @@ func main() {
 	}
 	fmt.Println("====> Database URI: ", cfg.Database.URI)
 
+	cfgProvider := config.NewProvider(configPath, cfg)
+
 	// Initialize logger
 	logger := utils.NewLogger(cfg)
 	logger.Info("Starting ChatMix Backend Server")
 
+	loggers := utils.NewLoggers(logger, cfg.Logging)
+	chatLogger := loggers.For("chat")
+	authLogger := loggers.For("auth")
+	repositoryLogger := loggers.For("repository")
+	httpLogger := loggers.For("http")
+
+	cfgProvider.OnReload(func(old, new *config.Config) {
+		loggers.SetLevels(new.Logging)
+	})
+
 	// Initialize database
 	db, err := repository.NewDatabase(cfg)
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to connect to database")
+		repositoryLogger.WithError(err).Fatal("Failed to connect to database")
 	}
 	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		if err := db.Close(ctx); err != nil {
-			logger.WithError(err).Error("Failed to close database connection")
+			repositoryLogger.WithError(err).Error("Failed to close database connection")
 		}
 	}()
 
-	logger.Info("Connected to MongoDB successfully")
+	repositoryLogger.WithField("driver", cfg.GetDatabaseDriver()).Info("Connected to database successfully")
+
+	var locator geoip.Locator
+	if cfg.GeoIP.Enabled {
+		maxmind, err := geoip.NewMaxMind(cfg.GeoIP.DatabasePath)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open GeoIP database")
+		}
+		defer maxmind.Close()
+		locator = maxmind
+	}
+
+	var mail mailer.Mailer = mailer.Noop{}
+	if cfg.Email.Enabled {
+		// Emails are sent in the background so requests never wait on the SMTP server
+		queue := mailer.NewQueue(
+			mailer.NewSMTP(cfg.Email.Host, cfg.Email.Port, cfg.Email.Username, cfg.Email.Password, cfg.Email.From),
+			mailer.QueueOptions{
+				Size:         cfg.Email.Queue.Size,
+				Workers:      cfg.Email.Queue.Workers,
+				MaxAttempts:  cfg.Email.Queue.MaxAttempts,
+				RetryBackoff: cfg.Email.Queue.RetryBackoff,
+				SendTimeout:  cfg.Email.Queue.SendTimeout,
+				OnError: func(msg mailer.Message, err error) {
+					logger.WithError(err).WithField("subject", msg.Subject).Error("Failed to send email")
+				},
+			})
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := queue.Close(ctx); err != nil {
+				logger.WithError(err).Warn("Email queue closed before every email was sent")
+			}
+		}()
+		mail = queue
+	}
+	emailTemplates, err := mailer.NewTemplates(cfg.Email.TemplatesDir, cfg.Email.DefaultLocale)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load email templates")
+	}
+
+	var captchaVerifier captcha.Verifier
+	switch cfg.Captcha.GetProvider() {
+	case config.CaptchaHCaptcha:
+		captchaVerifier = captcha.NewHCaptcha(cfg.Captcha.SecretKey, cfg.Captcha.Timeout)
+	case config.CaptchaReCaptcha:
+		captchaVerifier = captcha.NewReCaptcha(cfg.Captcha.SecretKey, cfg.Captcha.MinScore, cfg.Captcha.Timeout)
+	}
+
+	// Services publish domain events on the bus; subsystems subscribe below
+	events := event.NewBus(logger)
+
+	// Metrics are collected whether or not metrics.enabled serves them to Prometheus
+	registry := metrics.NewRegistry()
 
 	// Initialize services
 	userService := service.NewUserService(db.UserRepo, cfg, logger)
-	authService := service.NewAuthService(db.UserRepo, db.RefreshTokenRepo, db.SessionRepo, db.CaptchaRepo, cfg, logger)
-	chatService := service.NewChatService(cfg, logger)
+	notificationService := service.NewNotificationService(db.NotificationRepo, db.UserRepo, events, logger)
+	disposableService := service.NewDisposableEmailService(db.DisposableRepo, registry, cfg, authLogger)
+	passwordService := service.NewPasswordService(registry, cfg, authLogger)
+	authService, err := service.NewAuthService(db.UserRepo, db.RefreshTokenRepo, db.SessionRepo, db.CaptchaRepo,
+		captchaVerifier, disposableService, passwordService, db.VerificationRepo, locator, notificationService, mail, emailTemplates, events, cfg, authLogger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize auth service")
+	}
+	roomLimiter := service.NewRoomLimiter(cfgProvider, chatLogger)
+	usageService := service.NewUsageService(db.UsageRepo, cfgProvider, logger)
+	experimentService := service.NewExperimentService(db.ExperimentRepo, cfg, chatLogger)
+	sanctionService := service.NewSanctionService(db.SanctionRepo, chatLogger)
+	chatService := service.NewChatService(cfgProvider, roomLimiter, userService, usageService, experimentService, sanctionService, events, chatLogger)
+
+	var translator translate.Provider
+	if cfg.Translation.Enabled {
+		translator = translate.NewLibreTranslate(cfg.Translation.Endpoint, cfg.Translation.APIKey, cfg.Translation.Timeout)
+	}
+	translationService := service.NewTranslationService(translator, cfg, logger)
+	blocklistService := service.NewBlocklistService(db.BlocklistRepo, cfg, chatLogger)
+	networkBanService := service.NewNetworkBanService(db.NetworkBanRepo, cfg, authLogger)
+	legalHoldService := service.NewLegalHoldService(db.LegalHoldRepo, db.UserRepo, logger)
+	messageService := service.NewMessageService(db.MessageRepo, blocklistService, legalHoldService, events, cfg, chatLogger)
+	auditService := service.NewAuditService(db.AuditRepo, logger)
+	activityService := service.NewActivityService(db.SessionRepo, auditService, logger)
+	bulkUserService := service.NewBulkUserService(db.UserRepo, db.RefreshTokenRepo, db.SessionRepo, logger)
+	userImportService := service.NewUserImportService(db.UserRepo, cfg, logger)
+	userAdminService := service.NewUserAdminService(db.UserRepo, db.RefreshTokenRepo, db.SessionRepo, db.CaptchaRepo, logger)
+	subscriptionService := service.NewSubscriptionService(db.UserRepo, logger)
+	roomEventService := service.NewRoomEventService(db.RoomEventRepo, cfg, chatLogger)
+	retentionService := service.NewRetentionService(db.MessageRepo, db.AuditRepo, db.SessionRepo, db.RoomEventRepo,
+		legalHoldService, cfg.Retention, logger)
+	icebreakerService := service.NewIcebreakerService(db.IcebreakerRepo, cfg, logger)
+	chatStatsService := service.NewChatStatsService(db.ChatStatsRepo, events, cfg, logger)
+	badgeService := service.NewBadgeService(db.BadgeRepo, db.UserRepo, notificationService, logger)
+	apiKeyService := service.NewAPIKeyService(db.APIKeyRepo, db.UserRepo, cfg, authLogger)
+	channelService := service.NewChannelService(db.ChannelRepo, db.ChannelMemberRepo, events, chatLogger)
+	profileViewService := service.NewProfileViewService(db.ProfileViewRepo, cfg, logger)
+	registry.NewGaugeFunc("chatmix_queue_size", "Users waiting for a partner.",
+		func() float64 { return float64(chatService.GetQueueSize()) })
+	registry.NewGaugeFunc("chatmix_rooms_in_use", "Chat rooms open now.",
+		func() float64 { return float64(chatService.Capacity().RoomsInUse) })
+	matchMetricsService := service.NewMatchMetricsService(db.MatchStatsRepo, registry, cfg, chatLogger)
+	if cfg.Chat.Icebreakers.Enabled {
+		seedCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := icebreakerService.SeedDefaults(seedCtx); err != nil {
+			logger.WithError(err).Error("Failed to seed icebreaker prompts")
+		}
+		cancel()
+	}
+	loadCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := blocklistService.Reload(loadCtx); err != nil {
+		logger.WithError(err).Error("Failed to load moderation blocklist")
+	}
+	cancel()
+	loadCtx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	if err := networkBanService.Reload(loadCtx); err != nil {
+		logger.WithError(err).Error("Failed to load network bans")
+	}
+	cancel()
+	loadCtx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	if err := disposableService.Reload(loadCtx); err != nil {
+		logger.WithError(err).Error("Failed to load disposable email domains")
+	}
+	cancel()
+	loadCtx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	if err := experimentService.Reload(loadCtx); err != nil {
+		logger.WithError(err).Error("Failed to load matching experiments")
+	}
+	cancel()
+	experimentCtx, stopExperiments := context.WithCancel(context.Background())
+	defer stopExperiments()
+	go experimentService.Run(experimentCtx)
+	blocklistCtx, stopBlocklist := context.WithCancel(context.Background())
+	defer stopBlocklist()
+	go blocklistService.Run(blocklistCtx)
+	networkBanCtx, stopNetworkBans := context.WithCancel(context.Background())
+	defer stopNetworkBans()
+	go networkBanService.Run(networkBanCtx)
+	disposableCtx, stopDisposable := context.WithCancel(context.Background())
+	defer stopDisposable()
+	go disposableService.Run(disposableCtx)
+	profileViewCtx, stopProfileViews := context.WithCancel(context.Background())
+	defer stopProfileViews()
+	go profileViewService.Run(profileViewCtx)
+	matchMetricsCtx, stopMatchMetrics := context.WithCancel(context.Background())
+	defer stopMatchMetrics()
+	go matchMetricsService.Run(matchMetricsCtx)
+	if cfg.Retention.Enabled {
+		retentionCtx, stopRetention := context.WithCancel(context.Background())
+		defer stopRetention()
+		go retentionService.Run(retentionCtx)
+	}
 
 	// Initialize handlers
-	httpHandler := handler.NewHTTPHandler(userService, logger)
-	authHandler := handler.NewUserHandler(authService, userService, logger)
-	chatHandler := handler.NewChatHandler(chatService, authService)
+	var accessLog *handler.AccessLog
+	if cfg.Logging.Access.Enabled {
+		out, err := utils.NewAccessLog(cfg.Logging)
+		if err != nil {
+			httpLogger.WithError(err).Fatal("Failed to open access log")
+		}
+		accessLog = handler.NewAccessLog(out)
+	}
+	httpHandler := handler.NewHTTPHandler(userService, service.NewIdempotencyService(cfg), accessLog, logger)
+	authHandler := handler.NewUserHandler(authService, userService, auditService, activityService, chatStatsService,
+		badgeService, profileViewService, cfg.Auth, authLogger)
+	// Slash commands available in chat; register deployment-specific commands here
+	commands := handler.NewCommandRegistry(handler.DefaultCommands(auditService)...)
+	// The bot only chats in the rooms the chat service hands it, see chat.bot.enabled
+	chatHandler := handler.NewChatHandler(chatService, authService, translationService, messageService, auditService,
+		icebreakerService, channelService, chatbot.NewDefaultScripted(), commands, locator, cfg.WebSocket, chatLogger)
+	adminHandler := handler.NewAdminHandler(chatService, roomLimiter, userService, chatStatsService, messageService, auditService, notificationService,
+		bulkUserService, userImportService, userAdminService, subscriptionService, roomEventService, retentionService, legalHoldService,
+		sanctionService, matchMetricsService, experimentService, icebreakerService, channelService, blocklistService,
+		networkBanService, disposableService, passwordService, logger)
+	notificationHandler := handler.NewNotificationHandler(notificationService, logger)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, auditService, authLogger)
+	botHandler := handler.NewBotHandler(userService, messageService, cfg.Auth.APIKeys.BotRoom, logger)
+	banEnforcer := handler.NewBanEnforcer(networkBanService, authLogger)
+	channelHandler := handler.NewChannelHandler(channelService, messageService, chatLogger)
+	var billingHandler *handler.BillingHandler
+	if cfg.Billing.WebhookSecret != "" {
+		billingHandler = handler.NewBillingHandler(subscriptionService, cfg.Billing, logger)
+	}
+	var metricsHandler *handler.MetricsHandler
+	if cfg.Metrics.Enabled {
+		metricsHandler = handler.NewMetricsHandler(registry, cfg.Metrics, httpLogger)
+	}
+
+	// Subscribe subsystems to service events
+	event.Subscribe(events, func(e event.RoomClosed) { chatStatsService.RecordRoom(e.Summary) })
+	event.Subscribe(events, func(e event.RoomClosed) { usageService.RecordRoom(e.Summary) })
+	event.Subscribe(events, func(e event.RoomClosed) { matchMetricsService.RecordRoom(e.Summary) })
+	event.Subscribe(events, func(e event.MatchMade) { matchMetricsService.RecordMatch(e) })
+	event.Subscribe(events, func(e event.MatchAbandoned) { matchMetricsService.RecordAbandon(e) })
+	event.Subscribe(events, func(e event.RoomLifecycle) { roomEventService.Record(e.Event) })
+	event.Subscribe(events, func(e event.RoomClosed) { chatHandler.DetachBot(e.Summary.Code) })
+	event.Subscribe(events, func(e event.BotJoined) { chatHandler.AttachBot(e.RoomCode, e.Bot) })
+	event.Subscribe(events, func(e event.RoomMemberExpired) { chatHandler.ExpireMember(e.RoomCode, e.Username) })
+	event.Subscribe(events, func(e event.ChannelJoined) { chatHandler.AnnounceChannelJoin(e.Channel, e.Username) })
+	event.Subscribe(events, func(e event.ChannelLeft) { chatHandler.DetachChannelMember(e.Channel, e.Username) })
+	event.Subscribe(events, func(e event.ChannelDeleted) { chatHandler.CloseChannel(e.Channel) })
+	event.Subscribe(events, func(e event.UserKicked) { chatHandler.KickUser(e.UserID, e.Username, e.RoomCode) })
+	event.Subscribe(events, func(e event.ChatStatsRecorded) { badgeService.HandleChatStats(e.Stats) })
+	event.Subscribe(events, func(e event.UserLoggedIn) { badgeService.HandleLogin(e.User) })
+	// Push new notifications to the recipient's open chat connections
+	event.Subscribe(events, func(e event.NotificationCreated) { chatHandler.DeliverNotification(e.Notification) })
+	// A user is online while they have a chat connection on any device; the user service
+	// logs failures
+	chatHandler.OnPresence(func(_, username string, online bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if online {
+			_ = userService.SetUserOnline(ctx, username)
+		} else {
+			_ = userService.SetUserOffline(ctx, username)
+		}
+	})
+
+	// Rooms and the queue survive restarts when snapshots are enabled; restoring publishes
+	// events, so it runs once the subscribers above are registered
+	var snapshotter *service.ChatSnapshotter
+	snapshotCtx, stopSnapshots := context.WithCancel(context.Background())
+	defer stopSnapshots()
+	if cfg.Chat.Snapshot.Enabled {
+		snapshotter = service.NewChatSnapshotter(chatService, service.NewFileSnapshotStore(cfg.Chat.Snapshot.Path),
+			cfg.Chat.Snapshot, chatLogger)
+		snapshotter.Restore()
+		go snapshotter.Run(snapshotCtx)
+	}
+
+	// Matching and cleanup start once restored rooms and queue entries are in place;
+	// they stop on shutdown before the final snapshot
+	chatService.Start(context.Background())
+
+	limiterCtx, stopLimiter := context.WithCancel(context.Background())
+	defer stopLimiter()
+	go roomLimiter.Run(limiterCtx, chatHandler.ConnectionCount)
 
 	// Initialize router
-	appRouter := router.NewRouter(cfg, logger, httpHandler, authHandler, authService, chatHandler)
+	var staticHandler http.Handler
+	if frontend := cfg.Server.Frontend; frontend.Enabled {
+		files := web.Dist()
+		if frontend.Dir != "" {
+			files = os.DirFS(frontend.Dir)
+		}
+		if static, err := handler.NewStaticHandler(files, frontend); err != nil {
+			httpLogger.WithError(err).Warn("Frontend is enabled but not built, serving the API only")
+		} else {
+			staticHandler = static
+		}
+	}
+
+	appRouter := router.NewRouter(cfgProvider, httpLogger, httpHandler, authHandler, authService, chatHandler, adminHandler, notificationHandler,
+		apiKeyHandler, botHandler, channelHandler, banEnforcer, billingHandler, metricsHandler, staticHandler)
 	routes := appRouter.SetupRoutes()
 
 	// Create HTTP server
-	server := &http.Server{
-		Addr:         cfg.GetAddress(),
-		Handler:      routes,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-	}
+	server := newHTTPServer(cfg.Server, cfg.GetAddress(), routes)
 
 	go func() {
 		logger.WithFields(logrus.Fields{
 			"addr":          server.Addr,
 			"read_timeout":  cfg.Server.ReadTimeout,
 			"write_timeout": cfg.Server.WriteTimeout,
+			"idle_timeout":  cfg.Server.IdleTimeout,
+			"http2":         cfg.Server.HTTP2.Enabled,
 		}).Info("Starting HTTP server")
 
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -94,6 +358,25 @@ func main() {
 		"log_level":     cfg.Logging.Level,
 	}).Info("ChatMix Backend Server started successfully")
 
+	// Reload runtime-tunable config on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			newCfg, err := cfgProvider.Reload()
+			if err != nil {
+				logger.WithError(err).Error("Failed to reload config, keeping current settings")
+				continue
+			}
+			logger.WithFields(logrus.Fields{
+				"log_level":       newCfg.Logging.Level,
+				"max_rooms":       newCfg.Chat.MaxRooms,
+				"queue_timeout":   newCfg.Chat.QueueTimeout,
+				"allowed_origins": newCfg.Server.CORS.AllowedOrigins,
+			}).Info("Configuration reloaded")
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -101,6 +384,10 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Tell chat clients to reconnect; event streams would otherwise hold the shutdown
+	// until it times out, and sockets would just drop
+	chatHandler.Shutdown()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -108,26 +395,46 @@ func main() {
 		logger.WithError(err).Error("Server forced to shutdown")
 	}
 
-	logger.Info("Server exited")
-}
+	chatService.Stop()
 
-func getConfigPath() string {
-	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
-		return configPath
+	// Closed sockets did not leave their rooms, so the rooms are in the final snapshot
+	if snapshotter != nil {
+		stopSnapshots()
+		snapshotter.Save()
 	}
 
-	possiblePaths := []string{
-		"configs/config.yaml",
-		"../../configs/config.yaml",
-		"/etc/chatmix/config.yaml",
-		"config.yaml",
-	}
+	stopProfileViews()
+	profileViewService.Flush(ctx)
 
-	for _, path := range possiblePaths {
-		if _, err := os.Stat(path); err == nil {
-			return path
-		}
+	// Let in-flight event handlers finish before the database is closed
+	events.Wait()
+
+	// After the event handlers, which may still record matchmaking metrics
+	stopMatchMetrics()
+	matchMetricsService.Flush(ctx)
+
+	logger.Info("Server exited")
+}
+
+// newHTTPServer builds the server with the timeouts and limits of the config. With
+// HTTP/2 enabled, h2c serves clients that speak it without TLS and hands HTTP/1.1
+// requests, WebSocket upgrades included, to the handler unchanged.
+func newHTTPServer(cfg config.ServerConfig, addr string, handler http.Handler) *http.Server {
+	if cfg.HTTP2.Enabled {
+		handler = h2c.NewHandler(handler, &http2.Server{
+			MaxConcurrentStreams: cfg.HTTP2.MaxConcurrentStreams,
+			MaxReadFrameSize:     cfg.HTTP2.MaxReadFrameSize,
+			IdleTimeout:          cfg.HTTP2.IdleTimeout,
+		})
 	}
 
-	return "configs/config.yaml"
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
 }
@@ -6,6 +6,16 @@ import { Send, Loader2, WifiOff, RefreshCw, Users, Eye } from 'lucide-react';
 import toast from 'react-hot-toast';
 import CONFIG from '../config/api';
 
+// Messages for the statuses of a chat socket closed by the server
+const CLOSE_MESSAGES = {
+  auth_failed: 'Phiên đăng nhập đã hết hạn',
+  room_full: 'Phòng đã đủ người',
+  kicked: 'Bạn đã bị mời ra khỏi phòng',
+  rate_limited: 'Bạn gửi tin nhắn quá nhanh',
+  server_shutdown: 'Máy chủ đang khởi động lại, vui lòng kết nối lại sau giây lát',
+  replaced: 'Phòng chat đang mở ở một tab hoặc thiết bị khác',
+};
+
 const ChatRoom = ({ onBack }) => {
   const { user, isAuthenticated } = useAuth();
   const [roomCode, setRoomCode] = useState('');
@@ -119,7 +129,7 @@ const ChatRoom = ({ onBack }) => {
                 
                 // Initialize WebSocket connection
                 try {
-                  await chatService.connectToRoom(assignedRoomCode, user.username);
+                  await chatService.connectToRoom(assignedRoomCode, queueCheck.room_token, user.username);
                   setStatus('connected');
                   setIsLoading(false);
                   toast.success(`Đã vào phòng chat thành công!`);
@@ -149,7 +159,7 @@ const ChatRoom = ({ onBack }) => {
         console.log('Assigned to room:', roomCode);
 
         // Step 2: Connect to WebSocket
-        await chatService.connectToRoom(roomCode, user.username);
+        await chatService.connectToRoom(roomCode, response.room_token, user.username);
         
         if (!isMounted) return;
         
@@ -231,6 +241,8 @@ const ChatRoom = ({ onBack }) => {
         toast.error('Mất kết nối');
       } else if (newStatus === 'error') {
         toast.error('Lỗi kết nối');
+      } else if (CLOSE_MESSAGES[newStatus]) {
+        toast.error(CLOSE_MESSAGES[newStatus]);
       }
     };
 
@@ -294,7 +306,12 @@ const ChatRoom = ({ onBack }) => {
 
     try {
       setStatus('connecting');
-      await chatService.connectToRoom(roomCode, user.username);
+      // Room tokens are short-lived, get a new one
+      const current = await chatService.getCurrentRoom();
+      if (!current.in_room || current.room !== roomCode) {
+        throw new Error('No longer in this room');
+      }
+      await chatService.connectToRoom(roomCode, current.room_token, user.username);
       toast.success('Đã kết nối lại');
     } catch (error) {
       console.error('Retry failed:', error);
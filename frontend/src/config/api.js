@@ -1,6 +1,11 @@
+// Builds served by the backend itself (server.frontend in the backend config) set
+// REACT_APP_SAME_ORIGIN=true to call the API of the host that served the page
+const SAME_ORIGIN = process.env.REACT_APP_SAME_ORIGIN === 'true';
+const WS_ORIGIN = `${window.location.protocol === 'https:' ? 'wss:' : 'ws:'}//${window.location.host}`;
+
 const CONFIG = {
-  API_BASE_URL: 'https://chatmix.apex-intel.cloud/api',
-  WS_BASE_URL: 'wss://chatmix.apex-intel.cloud',
+  API_BASE_URL: SAME_ORIGIN ? '/api' : 'https://chatmix.apex-intel.cloud/api',
+  WS_BASE_URL: SAME_ORIGIN ? WS_ORIGIN : 'wss://chatmix.apex-intel.cloud',
 
   // API_BASE_URL: 'http://localhost:8082/api',
   // WS_BASE_URL: 'ws://localhost:8082',
@@ -10,6 +10,19 @@ class AuthService {
     
     this.token = localStorage.getItem('access_token');
     this.refreshToken = localStorage.getItem('refresh_token');
+    this.deviceId = this.loadDeviceId();
+  }
+
+  // Random ID of this browser, sent as X-Device-Fingerprint so moderators can ban a device
+  loadDeviceId() {
+    let deviceId = localStorage.getItem('device_id');
+    if (!deviceId) {
+      deviceId = window.crypto && window.crypto.randomUUID
+        ? window.crypto.randomUUID()
+        : `${Date.now().toString(36)}-${Math.random().toString(36).slice(2)}`;
+      localStorage.setItem('device_id', deviceId);
+    }
+    return deviceId;
   }
 
   // Migrate old localStorage keys to new keys
@@ -41,6 +54,8 @@ class AuthService {
       ...options,
     };
 
+    config.headers['X-Device-Fingerprint'] = this.deviceId;
+
     // Add authorization header if token exists
     if (this.token && !options.skipAuth) {
       config.headers.Authorization = `Bearer ${this.token}`;
@@ -4,6 +4,16 @@ import CONFIG from '../config/api';
 
 const WS_BASE_URL = CONFIG.WS_BASE_URL;
 
+// Statuses for the close codes of the chat socket, see internal/handler/close_codes.go
+const CLOSE_STATUSES = {
+  4001: 'auth_failed',
+  4002: 'room_full',
+  4003: 'kicked',
+  4004: 'rate_limited',
+  4005: 'server_shutdown', // reconnect after a moment
+  4409: 'replaced', // the room is open in another tab or device
+};
+
 class ChatService {
   constructor() {
     this.websocket = null;
@@ -25,6 +35,16 @@ class ChatService {
     }
   }
 
+  // Get the room the user is in, with a new room token to reconnect with
+  async getCurrentRoom() {
+    try {
+      return await authService.apiCall('/chat/current');
+    } catch (error) {
+      console.error('Error getting current room:', error);
+      throw error;
+    }
+  }
+
   // Check queue status
   async checkQueueStatus(username) {
     try {
@@ -35,8 +55,8 @@ class ChatService {
     }
   }
 
-  // Connect to WebSocket for specific room
-  connectToRoom(roomCode, username) {
+  // Connect to WebSocket for specific room, with the room token from startChat or getCurrentRoom
+  connectToRoom(roomCode, roomToken, username) {
     return new Promise((resolve, reject) => {
       try {
         // Close existing connection
@@ -44,8 +64,12 @@ class ChatService {
           this.websocket.close();
         }
 
+        // The generation of the previous connection to the same room lets the server
+        // refuse this one if another tab has taken the seat since
+        const previous = this.currentRoom === roomCode ? this.generation : null;
         this.currentRoom = roomCode;
         this.username = username;
+        this.generation = null;
 
         // Get auth token for WebSocket connection
         const token = authService.token;
@@ -54,10 +78,16 @@ class ChatService {
           return;
         }
 
-        const wsUrl = `${WS_BASE_URL}/ws/chat?room=${encodeURIComponent(roomCode)}&username=${encodeURIComponent(username)}&token=${encodeURIComponent(token)}`;
+        // The token is sent in the first frame rather than the URL, which would leak it into logs
+        // The device query parameter stands in for X-Device-Fingerprint, which sockets cannot send
+        let wsUrl = `${WS_BASE_URL}/ws/chat?room_token=${encodeURIComponent(roomToken)}&username=${encodeURIComponent(username)}&device=${encodeURIComponent(authService.deviceId)}`;
+        if (previous) {
+          wsUrl += `&generation=${previous}`;
+        }
         this.websocket = new WebSocket(wsUrl);
 
         this.websocket.onopen = () => {
+          this.websocket.send(JSON.stringify({ type: 'auth', token }));
           console.log('WebSocket connected to room:', roomCode);
           this.notifyStatusChange('connected');
           resolve();
@@ -66,6 +96,13 @@ class ChatService {
         this.websocket.onmessage = (event) => {
           try {
             const message = JSON.parse(event.data);
+            if (message.type === 'authenticated') {
+              return;
+            }
+            if (message.type === 'session') {
+              this.generation = message.generation;
+              return;
+            }
             this.handleMessage(message);
           } catch (error) {
             console.error('Error parsing message:', error);
@@ -74,7 +111,7 @@ class ChatService {
 
         this.websocket.onclose = (event) => {
           console.log('WebSocket closed:', event.code, event.reason);
-          this.notifyStatusChange('disconnected');
+          this.notifyStatusChange(CLOSE_STATUSES[event.code] || 'disconnected');
         };
 
         this.websocket.onerror = (error) => {
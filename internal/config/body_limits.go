@@ -0,0 +1,27 @@
+package config
+
+import "strings"
+
+// BodyLimits caps request body sizes in bytes. The route with the longest matching
+// path prefix overrides Default.
+type BodyLimits struct {
+	Default int64            `yaml:"default"`
+	Routes  []BodyLimitRoute `yaml:"routes"`
+}
+
+// BodyLimitRoute is the body limit of the paths starting with PathPrefix
+type BodyLimitRoute struct {
+	PathPrefix string `yaml:"path_prefix"`
+	MaxBytes   int64  `yaml:"max_bytes"`
+}
+
+// ForPath returns the body limit of a request path
+func (c BodyLimits) ForPath(path string) int64 {
+	limit, matched := c.Default, -1
+	for _, route := range c.Routes {
+		if strings.HasPrefix(path, route.PathPrefix) && len(route.PathPrefix) > matched {
+			limit, matched = route.MaxBytes, len(route.PathPrefix)
+		}
+	}
+	return limit
+}
@@ -2,20 +2,35 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	DriverMongo    = "mongo"
+	DriverPostgres = "postgres"
+)
+
 type Config struct {
-	Server    ServerConfig    `yaml:"server"`
-	Database  DatabaseConfig  `yaml:"database"`
-	WebSocket WebSocketConfig `yaml:"websocket"`
-	Logging   LoggingConfig   `yaml:"logging"`
-	Auth      AuthConfig      `yaml:"auth"`
-	Features  FeaturesConfig  `yaml:"features"`
-	Chat      ChatConfig      `yaml:"chat"`
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	WebSocket   WebSocketConfig   `yaml:"websocket"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Features    FeaturesConfig    `yaml:"features"`
+	Chat        ChatConfig        `yaml:"chat"`
+	Translation TranslationConfig `yaml:"translation"`
+	GeoIP       GeoIPConfig       `yaml:"geoip"`
+	Email       EmailConfig       `yaml:"email"`
+	Captcha     CaptchaConfig     `yaml:"captcha"`
+	Billing     BillingConfig     `yaml:"billing"`
+	Retention   RetentionConfig   `yaml:"retention"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
 }
 
 type ServerConfig struct {
@@ -23,58 +38,692 @@ type ServerConfig struct {
 	Port         int           `yaml:"port"`
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
-	CORS         CORSConfig    `yaml:"cors"`
+	// ReadHeaderTimeout bounds reading the request headers, so slow clients cannot hold
+	// connections open; IdleTimeout closes keep-alive connections without requests.
+	// WebSocket connections are taken over from the server and none of them apply.
+	ReadHeaderTimeout time.Duration     `yaml:"read_header_timeout"`
+	IdleTimeout       time.Duration     `yaml:"idle_timeout"`
+	MaxHeaderBytes    int               `yaml:"max_header_bytes"`
+	HTTP2             HTTP2Config       `yaml:"http2"`
+	CORS              CORSConfig        `yaml:"cors"`
+	BodyLimits        BodyLimits        `yaml:"body_limits"`
+	Idempotency       IdempotencyConfig `yaml:"idempotency"`
+	Frontend          FrontendConfig    `yaml:"frontend"`
+	Protocol          ProtocolConfig    `yaml:"protocol"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in front of the
+	// server. X-Forwarded-For and X-Real-IP are only read from them; other clients could
+	// send the headers to pose as any address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TrustedProxyPrefixes parses TrustedProxies, single addresses becoming one-address prefixes
+func (c ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// HTTP2Config serves HTTP/2 without TLS (h2c) next to HTTP/1.1, for a proxy in front
+// that terminates TLS and speaks HTTP/2 to the server. WebSocket upgrades keep using
+// HTTP/1.1.
+type HTTP2Config struct {
+	Enabled              bool          `yaml:"enabled"`
+	MaxConcurrentStreams uint32        `yaml:"max_concurrent_streams"` // per connection
+	MaxReadFrameSize     uint32        `yaml:"max_read_frame_size"`    // 16 KiB to 16 MiB
+	IdleTimeout          time.Duration `yaml:"idle_timeout"`           // server.idle_timeout when unset
+}
+
+// FrontendConfig serves the web app from the API server, for installs without a separate
+// web server. The build embedded from web/dist is served unless Dir is set.
+type FrontendConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"` // serve this directory instead, such as frontend/build
+	// AssetMaxAge is how long browsers cache the fingerprinted files under /static/;
+	// index.html and other files are revalidated on every load
+	AssetMaxAge time.Duration `yaml:"asset_max_age"`
+}
+
+// ProtocolConfig phases out old versions of the chat protocol. Clients state their
+// version with the X-Chat-Protocol header or the protocol query parameter; clients that
+// state none count as version 0.
+type ProtocolConfig struct {
+	// MinVersion refuses REST requests and socket connections of older clients with 426
+	MinVersion int `yaml:"min_version"`
+	// DeprecatedBelow warns clients older than this version that they must upgrade
+	DeprecatedBelow int `yaml:"deprecated_below"`
+}
+
+// IdempotencyConfig controls replaying responses to retried requests that carry an
+// Idempotency-Key header. Responses are kept in memory for TTL.
+type IdempotencyConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+	// MaxEntries bounds the stored keys; requests beyond it run without idempotency
+	MaxEntries int `yaml:"max_entries"`
 }
 
 type CORSConfig struct {
-	AllowedOrigins   []string `yaml:"allowed_origins"`
-	AllowedMethods   []string `yaml:"allowed_methods"`
-	AllowedHeaders   []string `yaml:"allowed_headers"`
-	AllowCredentials bool     `yaml:"allow_credentials"`
+	AllowedOrigins   []string          `yaml:"allowed_origins"` // exact origins, "*" or subdomain patterns like https://*.chatmix.app
+	AllowedMethods   []string          `yaml:"allowed_methods"`
+	AllowedHeaders   []string          `yaml:"allowed_headers"`
+	ExposedHeaders   []string          `yaml:"exposed_headers"`
+	AllowCredentials bool              `yaml:"allow_credentials"`
+	MaxAge           time.Duration     `yaml:"max_age"` // how long browsers may cache preflight results, 0 = not sent
+	Routes           []CORSRouteConfig `yaml:"routes"`
 }
 
 type DatabaseConfig struct {
+	Driver      string            `yaml:"driver"` // mongo, postgres
 	URI         string            `yaml:"uri"`
 	Name        string            `yaml:"name"`
-	Timeout     time.Duration     `yaml:"timeout"`
+	Timeout     time.Duration     `yaml:"timeout"` // connect and startup (migrations, indexes) timeout
 	Collections CollectionsConfig `yaml:"collections"`
+
+	// OperationTimeout bounds every repository call, even when the caller's context allows longer
+	OperationTimeout       time.Duration `yaml:"operation_timeout"`
+	ServerSelectionTimeout time.Duration `yaml:"server_selection_timeout"` // mongo only
+	Pool                   PoolConfig    `yaml:"pool"`
+	ReadConcern            string        `yaml:"read_concern"`  // mongo only: local, available, majority, linearizable, snapshot
+	WriteConcern           string        `yaml:"write_concern"` // mongo only: "majority" or the number of acknowledging nodes
+}
+
+// PoolConfig sizes the database connection pool. Zero values keep the driver defaults.
+type PoolConfig struct {
+	MaxSize         uint64        `yaml:"max_size"`
+	MinSize         uint64        `yaml:"min_size"` // postgres: max idle connections
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"`
 }
 
 type CollectionsConfig struct {
-	Messages      string `yaml:"messages"`
-	Users         string `yaml:"users"`
-	RefreshTokens string `yaml:"refresh_tokens"`
-	Sessions      string `yaml:"sessions"`
-	Captchas      string `yaml:"captchas"`
+	Messages          string `yaml:"messages"`
+	Users             string `yaml:"users"`
+	RefreshTokens     string `yaml:"refresh_tokens"`
+	Sessions          string `yaml:"sessions"`
+	Captchas          string `yaml:"captchas"`
+	AuditLogs         string `yaml:"audit_logs"`
+	Notifications     string `yaml:"notifications"`
+	VerificationCodes string `yaml:"verification_codes"`
+	Icebreakers       string `yaml:"icebreakers"`
+	ChatStats         string `yaml:"chat_stats"`
+	Badges            string `yaml:"badges"`
+	APIKeys           string `yaml:"api_keys"`
+	Channels          string `yaml:"channels"`
+	ChannelMembers    string `yaml:"channel_members"`
+	Blocklist         string `yaml:"blocklist"`
+	ProfileViews      string `yaml:"profile_views"`
+	DailyUsage        string `yaml:"daily_usage"`
+	RoomEvents        string `yaml:"room_events"`
+	LegalHolds        string `yaml:"legal_holds"`
+	MatchStats        string `yaml:"match_stats"`
+	Experiments       string `yaml:"experiments"`
+	Sanctions         string `yaml:"sanctions"`
+	NetworkBans       string `yaml:"network_bans"`
+	DisposableDomains string `yaml:"disposable_domains"`
 }
 
 type WebSocketConfig struct {
 	ReadBufferSize  int  `yaml:"read_buffer_size"`
 	WriteBufferSize int  `yaml:"write_buffer_size"`
 	CheckOrigin     bool `yaml:"check_origin"`
+	// AuthTimeout is how long a socket connected without a token has to send its auth frame
+	AuthTimeout time.Duration `yaml:"auth_timeout"`
+	// QueryToken accepts the token as a ?token= query parameter. Deprecated: the token
+	// ends up in access logs and proxies, clients should send an auth frame instead.
+	QueryToken bool `yaml:"query_token"`
+	// DuplicatePolicy decides which connection keeps the room seat when a user connects
+	// to a room twice: "newest" (default) replaces the open connection, "oldest" refuses
+	// the new one
+	DuplicatePolicy string `yaml:"duplicate_policy"`
+	// FrameRate is how many frames per second a socket may send on average and FrameBurst
+	// how many in a row; extra frames are dropped, and a socket that keeps sending them
+	// is closed as rate limited
+	FrameRate  float64 `yaml:"frame_rate"`
+	FrameBurst int     `yaml:"frame_burst"`
+	// SendBuffer is how many frames may wait for a slow client before it is disconnected.
+	// With SlowClientPolicy "drop_oldest" (default), once DropWatermark frames wait, each
+	// new frame drops the oldest waiting room broadcast, which the client notices by the
+	// gap in sequence numbers and resyncs; "disconnect" drops nothing.
+	SendBuffer       int    `yaml:"send_buffer"`
+	DropWatermark    int    `yaml:"drop_watermark"`
+	SlowClientPolicy string `yaml:"slow_client_policy"`
+	// Compression negotiates permessage-deflate with clients that offer it; frames are
+	// only compressed for connections that also declare the compression feature in hello
+	Compression bool `yaml:"compression"`
 }
 
+// Duplicate connection policies, see WebSocketConfig.DuplicatePolicy
+const (
+	DuplicateNewest = "newest"
+	DuplicateOldest = "oldest"
+)
+
+// Slow client policies, see WebSocketConfig.SlowClientPolicy
+const (
+	SlowClientDropOldest = "drop_oldest"
+	SlowClientDisconnect = "disconnect"
+)
+
 type LoggingConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
+	// Components overrides Level per component (chat, auth, repository, http),
+	// e.g. {"chat": "debug", "auth": "warn"}
+	Components map[string]string `yaml:"components"`
+
+	// Log files are rotated daily and when they reach MaxSizeMB. Rotated files older
+	// than MaxAge or beyond the newest MaxFiles are deleted; 0 keeps them.
+	Dir       string        `yaml:"dir"`
+	MaxSizeMB int           `yaml:"max_size_mb"`
+	MaxAge    time.Duration `yaml:"max_age"`
+	MaxFiles  int           `yaml:"max_files"`
+	Compress  bool          `yaml:"compress"` // gzip rotated files
+
+	// Access writes an access log for analytics apart from the application log
+	Access AccessLogConfig `yaml:"access"`
+}
+
+// Access log outputs
+const (
+	AccessLogFile   = "file"   // <dir>/access-<date>.log, rotated like the application log
+	AccessLogStdout = "stdout" // for log collectors reading the container output
+)
+
+// AccessLogConfig writes one JSON line per HTTP request with its request ID, user ID,
+// route template, status, bytes and latency. The output is opened on startup; the
+// sampling applies on reload.
+type AccessLogConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Output  string `yaml:"output"`
+	// SampleRate is the fraction of requests logged, from 0 to 1. Failed requests
+	// (status 400 and above) and requests slower than SlowThreshold are always logged.
+	SampleRate    float64       `yaml:"sample_rate"`
+	SlowThreshold time.Duration `yaml:"slow_threshold"` // 0 disables
 }
 
 type AuthConfig struct {
-	JWTSecret          string `yaml:"jwt_secret"`
-	AccessTokenExpiry  int    `yaml:"access_token_expiry"`  // hours
-	RefreshTokenExpiry int    `yaml:"refresh_token_expiry"` // hours
+	JWTSecret          string              `yaml:"jwt_secret"`
+	SigningKeys        []SigningKeyConfig  `yaml:"signing_keys"`
+	ActiveKeyID        string              `yaml:"active_key_id"`
+	AccessTokenExpiry  int                 `yaml:"access_token_expiry"`  // hours
+	RefreshTokenExpiry int                 `yaml:"refresh_token_expiry"` // hours
+	StepUp             StepUpConfig        `yaml:"step_up"`
+	TwoFactor          TwoFactorConfig     `yaml:"two_factor"`
+	APIKeys            APIKeysConfig       `yaml:"api_keys"`
+	Sessions           SessionsConfig      `yaml:"sessions"`
+	Passwords          PasswordsConfig     `yaml:"passwords"`
+	LoginThrottle      LoginThrottleConfig `yaml:"login_throttle"`
+	NetworkBans        NetworkBansConfig   `yaml:"network_bans"`
+	DisposableEmails   DisposableConfig    `yaml:"disposable_emails"`
+	// TokenTransport delivers tokens in JSON bodies (header) or as HttpOnly cookies
+	// guarded by a CSRF token (cookie), for browser deployments
+	TokenTransport string        `yaml:"token_transport"`
+	Cookies        CookiesConfig `yaml:"cookies"`
+}
+
+const (
+	TokenTransportHeader = "header"
+	TokenTransportCookie = "cookie"
+)
+
+// CookiesConfig controls the token cookies of the cookie token transport
+type CookiesConfig struct {
+	Domain   string `yaml:"domain"`    // empty = host-only cookies
+	SameSite string `yaml:"same_site"` // lax (default), strict or none
+	Insecure bool   `yaml:"insecure"`  // omit the Secure attribute, for local development over plain HTTP
+}
+
+// LoginThrottleConfig slows down repeated failed logins. Each consecutive failure for
+// the same account or IP address doubles the delay of the next attempt, from BaseDelay up
+// to MaxDelay; failures older than ResetAfter are forgotten and a successful login
+// clears the account's count.
+type LoginThrottleConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	BaseDelay  time.Duration `yaml:"base_delay"`
+	MaxDelay   time.Duration `yaml:"max_delay"`
+	ResetAfter time.Duration `yaml:"reset_after"`
+}
+
+// NetworkBansConfig controls the IP and device bans managed through /api/admin/network-bans
+type NetworkBansConfig struct {
+	// Refresh is how often the bans are reloaded from the database, picking up changes
+	// made through other instances, and expired bans are deleted
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// DisposableConfig refuses registrations with addresses of disposable mail services. The
+// blocked domains are the built-in list, the list at RemoteURL and the overrides managed
+// through /api/admin/disposable-domains.
+type DisposableConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// RemoteURL serves a plain text list with one domain per line; empty uses only the
+	// built-in list and the overrides
+	RemoteURL     string        `yaml:"remote_url"`
+	RemoteRefresh time.Duration `yaml:"remote_refresh"`
+	// Refresh is how often the overrides are reloaded from the database, picking up
+	// changes made through other instances
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// PasswordsConfig selects how passwords are hashed. Hashes of either algorithm verify;
+// on login a hash of the other algorithm or with weaker parameters is replaced.
+type PasswordsConfig struct {
+	Algorithm  string       `yaml:"algorithm"`   // bcrypt (default) or argon2id
+	BcryptCost int          `yaml:"bcrypt_cost"` // 4 to 31
+	Argon2     Argon2Config `yaml:"argon2"`
+	// TargetLatency is how long hashing a password should take on this hardware, checked
+	// by /api/admin/passwords/benchmark
+	TargetLatency PasswordLatencyConfig `yaml:"target_latency"`
+}
+
+// PasswordLatencyConfig is a band of hashing times: faster hashes are cheaper to brute
+// force, slower ones hold up logins and let bursts of them exhaust the CPU
+type PasswordLatencyConfig struct {
+	Min time.Duration `yaml:"min"`
+	Max time.Duration `yaml:"max"`
+}
+
+// Argon2Config are the Argon2id parameters of new password hashes
+type Argon2Config struct {
+	Memory      uint32 `yaml:"memory"` // KiB
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+	SaltLength  uint32 `yaml:"salt_length"` // bytes
+	KeyLength   uint32 `yaml:"key_length"`  // bytes
+}
+
+// Password hashing algorithms, see PasswordsConfig
+const (
+	PasswordBcrypt   = "bcrypt"
+	PasswordArgon2id = "argon2id"
+)
+
+// SessionsConfig controls how request activity is recorded on sessions
+type SessionsConfig struct {
+	TouchInterval time.Duration `yaml:"touch_interval"` // least time between two last_used updates of a session
+	// Sliding extends a session by IdleTimeout on activity and rejects requests of expired
+	// or revoked sessions, instead of letting sessions expire with their access token
+	Sliding     bool          `yaml:"sliding"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"` // defaults to access_token_expiry
+}
+
+// APIKeysConfig controls the API keys users issue for bots and integrations
+type APIKeysConfig struct {
+	MaxPerUser       int    `yaml:"max_per_user"`       // active keys per user
+	DefaultRateLimit int    `yaml:"default_rate_limit"` // requests per minute for keys created without one
+	MaxRateLimit     int    `yaml:"max_rate_limit"`     // highest requests per minute a key may ask for
+	BotRoom          string `yaml:"bot_room"`           // room code bots read and post to
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication. Pending 2FA logins use the
+// step_up code_ttl and max_attempts limits.
+type TwoFactorConfig struct {
+	Issuer        string `yaml:"issuer"`         // shown in authenticator apps
+	RecoveryCodes int    `yaml:"recovery_codes"` // number of recovery codes generated when 2FA is enabled
+	// MaxFailures wrong second-factor codes for an account lock its logins for Lockout,
+	// however many challenges they were spread over; failures older than Lockout are forgotten
+	MaxFailures int           `yaml:"max_failures"`
+	Lockout     time.Duration `yaml:"lockout"`
+}
+
+// StepUpConfig controls the emailed verification code required for suspicious logins
+type StepUpConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	CodeTTL     time.Duration `yaml:"code_ttl"`
+	MaxAttempts int           `yaml:"max_attempts"`
+}
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKeyConfig is a JWT signing key identified by the kid header of the tokens it signs.
+// HS256 keys use Secret; RS256 and EdDSA keys use PEM files. A key with only a public key
+// file can validate tokens but not sign them.
+type SigningKeyConfig struct {
+	ID             string `yaml:"id"`
+	Algorithm      string `yaml:"algorithm"` // HS256 (default), RS256, EdDSA
+	Secret         string `yaml:"secret"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+// GetAlgorithm returns the configured algorithm, defaulting to HS256
+func (k *SigningKeyConfig) GetAlgorithm() string {
+	if k.Algorithm == "" {
+		return AlgorithmHS256
+	}
+	return k.Algorithm
+}
+
+// CanSign reports whether the key has the material needed to sign tokens
+func (k *SigningKeyConfig) CanSign() bool {
+	if k.GetAlgorithm() == AlgorithmHS256 {
+		return k.Secret != ""
+	}
+	return k.PrivateKeyFile != ""
 }
 
 type FeaturesConfig struct {
-	MaxUsernameLength int  `yaml:"max_username_length"`
-	RequireAuth       bool `yaml:"require_auth"`
-	CaptchaEnabled    bool `yaml:"captcha_enabled"`
+	MaxUsernameLength int                `yaml:"max_username_length"`
+	RequireAuth       bool               `yaml:"require_auth"`
+	CaptchaEnabled    bool               `yaml:"captcha_enabled"`
+	ProfileViews      ProfileViewsConfig `yaml:"profile_views"`
+}
+
+// ProfileViewsConfig controls counting profile views. Views are buffered in memory and
+// written in batches, so viewing a profile never waits on the database.
+type ProfileViewsConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// MaxPending bounds the buffered views; views beyond it are dropped until the next flush
+	MaxPending int `yaml:"max_pending"`
 }
 
 type ChatConfig struct {
-	MaxRooms            int           `yaml:"max_rooms"`
-	QueueTimeout        time.Duration `yaml:"queue_timeout"`
-	RoomCleanupInterval time.Duration `yaml:"room_cleanup_interval"`
+	MaxRooms            int               `yaml:"max_rooms"`
+	MaxQueueLength      int               `yaml:"max_queue_length"` // 0 means unlimited
+	QueueTimeout        time.Duration     `yaml:"queue_timeout"`
+	RoomCleanupInterval time.Duration     `yaml:"room_cleanup_interval"`
+	EditWindow          time.Duration     `yaml:"edit_window"`        // how long a sender may edit/delete a message
+	HistoryLimit        int               `yaml:"history_limit"`      // max messages returned by history endpoints
+	MaxMessageLength    int               `yaml:"max_message_length"` // max characters per message after sanitation
+	MaxMessageLines     int               `yaml:"max_message_lines"`  // extra lines are joined onto the last line
+	Icebreakers         IcebreakersConfig `yaml:"icebreakers"`
+	SkipThreshold       time.Duration     `yaml:"skip_threshold"` // leaving first within this counts as a skip in chat stats
+	// ProfanityWords is the block list for content filtering; empty uses the built-in list.
+	// Each room applies the stricter of its members' content filter levels.
+	ProfanityWords []string `yaml:"profanity_words"`
+	// BlocklistRefresh is how often the moderator-managed blocklist is reloaded from the
+	// database, picking up changes made through other instances
+	BlocklistRefresh time.Duration `yaml:"blocklist_refresh"`
+	// ExperimentRefresh is how often the running matching experiments are reloaded from
+	// the database, picking up experiments defined or stopped through other instances
+	ExperimentRefresh time.Duration  `yaml:"experiment_refresh"`
+	Bot               BotConfig      `yaml:"bot"`
+	Replay            ReplayConfig   `yaml:"replay"`
+	Spam              SpamConfig     `yaml:"spam"`
+	Snapshot          SnapshotConfig `yaml:"snapshot"`
+	// RoomToken signs the tokens chat sockets connect with instead of the room code
+	RoomToken RoomTokenConfig `yaml:"room_token"`
+	// Autoscale replaces MaxRooms with a limit adapted to the server load
+	Autoscale AutoscaleConfig `yaml:"autoscale"`
+	Quotas    QuotaConfig     `yaml:"quotas"`
+	// RoomEvents records room lifecycle events, see model.RoomEvent
+	RoomEvents RoomEventsConfig `yaml:"room_events"`
+	// TimeZoneBias prefers waiting rooms whose members live in a similar time zone
+	TimeZoneBias TimeZoneBiasConfig `yaml:"timezone_bias"`
+	// Compatibility ranks waiting rooms by a weighted compatibility score instead of the
+	// shared language and time zone bias order
+	Compatibility CompatibilityConfig `yaml:"compatibility"`
+}
+
+// CompatibilityConfig weighs the parts of the compatibility score, see
+// model.MatchPreferences.Compatibility. A weight of 0 leaves its part out; when every
+// weight is 0 the defaults are language 2, age 1 and time zone 1.
+type CompatibilityConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	LanguageWeight float64 `yaml:"language_weight"`
+	AgeWeight      float64 `yaml:"age_weight"`
+	TimeZoneWeight float64 `yaml:"timezone_weight"`
+	// MaxAgeGap is the age difference in years that scores nothing for age
+	MaxAgeGap int `yaml:"max_age_gap"`
+	// MaxOffset is the UTC offset difference that scores nothing for time zone
+	MaxOffset time.Duration `yaml:"max_offset"`
+}
+
+// TimeZoneBiasConfig ranks a waiting room whose members' UTC offsets are at most
+// MaxOffset from the user's above other rooms, after a shared language
+type TimeZoneBiasConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	MaxOffset time.Duration `yaml:"max_offset"`
+}
+
+type RoomEventsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// QuotaConfig limits how much users chat per UTC day. Minutes count once a room
+// closes, so a chat in progress can run past the limit. A zero limit is unlimited.
+type QuotaConfig struct {
+	Enabled              bool `yaml:"enabled"`
+	MaxChatsPerDay       int  `yaml:"max_chats_per_day"`
+	MaxChatMinutesPerDay int  `yaml:"max_chat_minutes_per_day"`
+	// Premium are the limits of premium accounts, unlimited unless set
+	Premium QuotaLimits `yaml:"premium"`
+}
+
+type QuotaLimits struct {
+	MaxChatsPerDay       int `yaml:"max_chats_per_day"`
+	MaxChatMinutesPerDay int `yaml:"max_chat_minutes_per_day"`
+}
+
+// AutoscaleConfig adjusts the effective room limit every interval, starting at
+// chat.max_rooms and staying within MinRooms and MaxRooms. The limit shrinks while any
+// load signal is above 90% of its ceiling and grows while all are below 70%; a zero
+// ceiling ignores that signal.
+type AutoscaleConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	MinRooms       int           `yaml:"min_rooms"`
+	MaxRooms       int           `yaml:"max_rooms"`
+	Interval       time.Duration `yaml:"interval"`
+	MaxHeapMB      int           `yaml:"max_heap_mb"`
+	MaxGoroutines  int           `yaml:"max_goroutines"`
+	MaxConnections int           `yaml:"max_connections"` // open chat connections
+}
+
+// RoomTokenConfig signs room tokens, which bind a user to the room they were matched to.
+// Without a Secret a random key is generated on startup, so tokens do not survive a
+// restart and clients fetch new ones.
+type RoomTokenConfig struct {
+	Secret string        `yaml:"secret"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+// SnapshotConfig controls saving rooms and the queue so they survive a restart
+type SnapshotConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+	// MaxAge is the oldest snapshot restored on startup; older state is discarded
+	MaxAge time.Duration `yaml:"max_age"`
+	// RestoreGrace is how long restored room members have to reconnect before they
+	// are removed from their room
+	RestoreGrace time.Duration `yaml:"restore_grace"`
+}
+
+const (
+	SpamActionFlag        = "flag"         // deliver the message flagged
+	SpamActionBlock       = "block"        // reject the message
+	SpamActionShadowLimit = "shadow_limit" // store the message but only show it to its sender
+)
+
+// SpamConfig controls the spam heuristics applied to chat messages, see pkg/spam
+type SpamConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	AllowDomains   []string      `yaml:"allow_domains"` // links to other domains are reported as "link"
+	DenyDomains    []string      `yaml:"deny_domains"`  // reported as "denied_link"
+	RepeatLimit    int           `yaml:"repeat_limit"`  // same text this many times within repeat_window
+	RepeatWindow   time.Duration `yaml:"repeat_window"`
+	CapsRatio      float64       `yaml:"caps_ratio"` // share of capital letters reported as "caps"
+	CapsMinLetters int           `yaml:"caps_min_letters"`
+	MaxEmoji       int           `yaml:"max_emoji"`
+	// Actions maps a reason (link, denied_link, repeat, caps, emoji) to flag, block or
+	// shadow_limit. The strictest action of the matched reasons applies.
+	Actions map[string]string `yaml:"actions"`
+}
+
+// ReplayConfig controls the "history" frame with the latest room messages sent to a
+// member when they join, so the second user gets the context of a waiting room
+type ReplayConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	Limit          int  `yaml:"limit"`           // max messages replayed
+	IncludeWaiting bool `yaml:"include_waiting"` // also replay messages sent before the room filled
+}
+
+// BotConfig controls the server-side bot that chats with users left waiting for a match
+type BotConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Name          string        `yaml:"name"`           // the bot's room username is "bot:<name>"
+	WaitThreshold time.Duration `yaml:"wait_threshold"` // wait in the queue or alone in a room before the bot joins
+	MaxRooms      int           `yaml:"max_rooms"`      // bot rooms, on top of chat.max_rooms
+	// GeneratedNames gives the bot of each room its own adjective and animal name, such as
+	// "bot:CalmOtter", instead of Name
+	GeneratedNames bool `yaml:"generated_names"`
+}
+
+// IcebreakersConfig controls the prompt sent when a room fills up. The prompt pool is
+// stored in the database and managed by admins; Prompts only seeds an empty pool.
+type IcebreakersConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Prompts []string `yaml:"prompts"`
+}
+
+type TranslationConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Provider  string        `yaml:"provider"` // libretranslate
+	Endpoint  string        `yaml:"endpoint"`
+	APIKey    string        `yaml:"api_key"`
+	Timeout   time.Duration `yaml:"timeout"`
+	CacheSize int           `yaml:"cache_size"`
+	CacheTTL  time.Duration `yaml:"cache_ttl"`
+}
+
+type EmailConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	// TemplatesDir holds <locale>/<name>.txt|.html templates overriding the built-in ones
+	TemplatesDir  string           `yaml:"templates_dir"`
+	DefaultLocale string           `yaml:"default_locale"` // used for untranslated templates and users without a language
+	Queue         EmailQueueConfig `yaml:"queue"`
+}
+
+// EmailQueueConfig controls the background delivery of emails
+type EmailQueueConfig struct {
+	Size         int           `yaml:"size"` // buffered emails, sending fails once full
+	Workers      int           `yaml:"workers"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"` // doubled after each failed attempt
+	SendTimeout  time.Duration `yaml:"send_timeout"`
+}
+
+const (
+	CaptchaBuiltin   = "builtin"
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaReCaptcha = "recaptcha"
+)
+
+// CaptchaConfig selects the captcha checked when features.captcha_enabled is set
+type CaptchaConfig struct {
+	Provider  string        `yaml:"provider"` // builtin (default), hcaptcha, recaptcha
+	SiteKey   string        `yaml:"site_key"` // public key returned to the frontend widget
+	SecretKey string        `yaml:"secret_key"`
+	MinScore  float64       `yaml:"min_score"` // reCAPTCHA v3 only
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+// GetProvider returns the captcha provider, defaulting to the builtin math captcha
+func (c CaptchaConfig) GetProvider() string {
+	if c.Provider == "" {
+		return CaptchaBuiltin
+	}
+	return c.Provider
+}
+
+// BillingConfig accepts subscription updates from an external billing provider at
+// POST /api/billing/webhook. Bodies are signed with HMAC-SHA256 of "<timestamp>.<body>"
+// under WebhookSecret, sent as "X-Billing-Signature: t=<timestamp>,v1=<hex>".
+type BillingConfig struct {
+	WebhookSecret string        `yaml:"webhook_secret"` // the webhook is disabled when empty
+	Provider      string        `yaml:"provider"`       // recorded as the source of the subscriptions it updates
+	Tolerance     time.Duration `yaml:"tolerance"`      // how far the signature timestamp may be from now
+}
+
+// RetentionConfig deletes the data of each class once it is older than the class's
+// period, checking every Interval. Periods override DefaultRetentionPeriods per class;
+// a zero period keeps the class forever. Sessions are kept for their period after they
+// expire.
+type RetentionConfig struct {
+	Enabled  bool                     `yaml:"enabled"`
+	Interval time.Duration            `yaml:"interval"`
+	Periods  map[string]time.Duration `yaml:"periods"`
+}
+
+// MetricsConfig serves Prometheus metrics at Path and tunes the matchmaking quality
+// measures. The daily matchmaking rollups, see GET /api/admin/matchmaking/stats, are kept
+// whether or not the endpoint is enabled.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	Token   string `yaml:"token"` // when set, scrapes must send it as a bearer token
+	// LongChat is how long a chat must last to count as a long chat
+	LongChat time.Duration `yaml:"long_chat"`
+	// FlushInterval is how often the counters are added to the daily rollups
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// Retention data classes, see RetentionConfig
+const (
+	RetentionMessages   = "messages"
+	RetentionAuditLogs  = "audit_logs"
+	RetentionSessions   = "sessions"
+	RetentionRoomEvents = "room_events"
+)
+
+// DefaultRetentionPeriods are the periods of the classes a deployment does not override
+var DefaultRetentionPeriods = map[string]time.Duration{
+	RetentionMessages:   30 * 24 * time.Hour,
+	RetentionAuditLogs:  365 * 24 * time.Hour,
+	RetentionSessions:   7 * 24 * time.Hour,
+	RetentionRoomEvents: 90 * 24 * time.Hour,
+}
+
+type GeoIPConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	DatabasePath string `yaml:"database_path"` // MaxMind GeoLite2/GeoIP2 City database (.mmdb)
+}
+
+// ResolvePath returns the config file to load: $CONFIG_PATH, or the first of the usual
+// locations that exists
+func ResolvePath() string {
+	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
+		return configPath
+	}
+
+	possiblePaths := []string{
+		"configs/config.yaml",
+		"../../configs/config.yaml",
+		"/etc/chatmix/config.yaml",
+		"config.yaml",
+	}
+
+	for _, path := range possiblePaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return "configs/config.yaml"
 }
 
 func Load(path string) (*Config, error) {
@@ -92,6 +741,8 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	config.applyDefaults()
+
 	if err := config.validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -99,6 +750,396 @@ func Load(path string) (*Config, error) {
 	return &config, nil
 }
 
+// applyDefaults fills optional settings that were left empty in the config file
+func (c *Config) applyDefaults() {
+	if c.Database.Collections.Messages == "" {
+		c.Database.Collections.Messages = "messages"
+	}
+	if c.Database.Collections.AuditLogs == "" {
+		c.Database.Collections.AuditLogs = "audit_logs"
+	}
+	if c.Database.Collections.Notifications == "" {
+		c.Database.Collections.Notifications = "notifications"
+	}
+	if c.Database.Collections.VerificationCodes == "" {
+		c.Database.Collections.VerificationCodes = "verification_codes"
+	}
+	if c.Database.Collections.Icebreakers == "" {
+		c.Database.Collections.Icebreakers = "icebreakers"
+	}
+	if c.Database.Collections.ChatStats == "" {
+		c.Database.Collections.ChatStats = "chat_stats"
+	}
+	if c.Database.Collections.Badges == "" {
+		c.Database.Collections.Badges = "badges"
+	}
+	if c.Database.Collections.APIKeys == "" {
+		c.Database.Collections.APIKeys = "api_keys"
+	}
+	if c.Database.Collections.Channels == "" {
+		c.Database.Collections.Channels = "channels"
+	}
+	if c.Database.Collections.ChannelMembers == "" {
+		c.Database.Collections.ChannelMembers = "channel_members"
+	}
+	if c.Database.Collections.Blocklist == "" {
+		c.Database.Collections.Blocklist = "blocklist"
+	}
+	if c.Database.Collections.ProfileViews == "" {
+		c.Database.Collections.ProfileViews = "profile_views"
+	}
+	if c.Database.Collections.DailyUsage == "" {
+		c.Database.Collections.DailyUsage = "daily_usage"
+	}
+	if c.Database.Collections.RoomEvents == "" {
+		c.Database.Collections.RoomEvents = "room_events"
+	}
+	if c.Database.Collections.LegalHolds == "" {
+		c.Database.Collections.LegalHolds = "legal_holds"
+	}
+	if c.Database.Collections.MatchStats == "" {
+		c.Database.Collections.MatchStats = "match_stats"
+	}
+	if c.Database.Collections.Experiments == "" {
+		c.Database.Collections.Experiments = "experiments"
+	}
+	if c.Database.Collections.Sanctions == "" {
+		c.Database.Collections.Sanctions = "sanctions"
+	}
+	if c.Database.Collections.NetworkBans == "" {
+		c.Database.Collections.NetworkBans = "network_bans"
+	}
+	if c.Database.Collections.DisposableDomains == "" {
+		c.Database.Collections.DisposableDomains = "disposable_domains"
+	}
+	if c.Server.BodyLimits.Default <= 0 {
+		c.Server.BodyLimits.Default = 1 << 20
+	}
+	if c.Server.BodyLimits.Routes == nil {
+		c.Server.BodyLimits.Routes = []BodyLimitRoute{
+			{PathPrefix: "/api/auth", MaxBytes: 16 << 10},
+			{PathPrefix: "/api/admin/users/bulk", MaxBytes: 10 << 20},
+			{PathPrefix: "/api/admin/users/import", MaxBytes: 50 << 20},
+		}
+	}
+	if c.Auth.StepUp.CodeTTL <= 0 {
+		c.Auth.StepUp.CodeTTL = 10 * time.Minute
+	}
+	if c.Auth.StepUp.MaxAttempts <= 0 {
+		c.Auth.StepUp.MaxAttempts = 5
+	}
+	if c.Auth.Sessions.TouchInterval <= 0 {
+		c.Auth.Sessions.TouchInterval = time.Minute
+	}
+	if c.Auth.Sessions.IdleTimeout <= 0 {
+		c.Auth.Sessions.IdleTimeout = time.Duration(c.Auth.AccessTokenExpiry) * time.Hour
+	}
+	if c.Auth.TokenTransport == "" {
+		c.Auth.TokenTransport = TokenTransportHeader
+	}
+	if c.Auth.LoginThrottle.BaseDelay <= 0 {
+		c.Auth.LoginThrottle.BaseDelay = 100 * time.Millisecond
+	}
+	if c.Auth.LoginThrottle.MaxDelay <= 0 {
+		c.Auth.LoginThrottle.MaxDelay = 2 * time.Second
+	}
+	if c.Auth.LoginThrottle.ResetAfter <= 0 {
+		c.Auth.LoginThrottle.ResetAfter = 15 * time.Minute
+	}
+	if c.Auth.NetworkBans.Refresh <= 0 {
+		c.Auth.NetworkBans.Refresh = time.Minute
+	}
+	if c.Auth.DisposableEmails.RemoteRefresh <= 0 {
+		c.Auth.DisposableEmails.RemoteRefresh = 24 * time.Hour
+	}
+	if c.Auth.DisposableEmails.Refresh <= 0 {
+		c.Auth.DisposableEmails.Refresh = time.Minute
+	}
+	if c.Auth.Passwords.Algorithm == "" {
+		c.Auth.Passwords.Algorithm = PasswordBcrypt
+	}
+	if c.Auth.Passwords.BcryptCost == 0 {
+		c.Auth.Passwords.BcryptCost = 10
+	}
+	if c.Auth.Passwords.Argon2.Memory == 0 {
+		c.Auth.Passwords.Argon2.Memory = 64 * 1024
+	}
+	if c.Auth.Passwords.Argon2.Iterations == 0 {
+		c.Auth.Passwords.Argon2.Iterations = 3
+	}
+	if c.Auth.Passwords.Argon2.Parallelism == 0 {
+		c.Auth.Passwords.Argon2.Parallelism = 2
+	}
+	if c.Auth.Passwords.Argon2.SaltLength == 0 {
+		c.Auth.Passwords.Argon2.SaltLength = 16
+	}
+	if c.Auth.Passwords.Argon2.KeyLength == 0 {
+		c.Auth.Passwords.Argon2.KeyLength = 32
+	}
+	if c.Auth.Passwords.TargetLatency.Min <= 0 {
+		c.Auth.Passwords.TargetLatency.Min = 100 * time.Millisecond
+	}
+	if c.Auth.Passwords.TargetLatency.Max <= 0 {
+		c.Auth.Passwords.TargetLatency.Max = 500 * time.Millisecond
+	}
+	if c.Auth.Cookies.SameSite == "" {
+		c.Auth.Cookies.SameSite = "lax"
+	}
+	if c.Auth.TwoFactor.Issuer == "" {
+		c.Auth.TwoFactor.Issuer = "ChatMix"
+	}
+	if c.Auth.TwoFactor.RecoveryCodes <= 0 {
+		c.Auth.TwoFactor.RecoveryCodes = 10
+	}
+	if c.Auth.TwoFactor.MaxFailures <= 0 {
+		c.Auth.TwoFactor.MaxFailures = 10
+	}
+	if c.Auth.TwoFactor.Lockout <= 0 {
+		c.Auth.TwoFactor.Lockout = 30 * time.Minute
+	}
+	if c.Auth.APIKeys.MaxPerUser <= 0 {
+		c.Auth.APIKeys.MaxPerUser = 5
+	}
+	if c.Auth.APIKeys.DefaultRateLimit <= 0 {
+		c.Auth.APIKeys.DefaultRateLimit = 60
+	}
+	if c.Auth.APIKeys.MaxRateLimit < c.Auth.APIKeys.DefaultRateLimit {
+		c.Auth.APIKeys.MaxRateLimit = max(600, c.Auth.APIKeys.DefaultRateLimit)
+	}
+	if c.Auth.APIKeys.BotRoom == "" {
+		c.Auth.APIKeys.BotRoom = "BOTS"
+	}
+	if c.Database.OperationTimeout <= 0 {
+		c.Database.OperationTimeout = 5 * time.Second
+	}
+	if c.WebSocket.AuthTimeout <= 0 {
+		c.WebSocket.AuthTimeout = 10 * time.Second
+	}
+	if c.WebSocket.DuplicatePolicy == "" {
+		c.WebSocket.DuplicatePolicy = DuplicateNewest
+	}
+	if c.WebSocket.FrameRate <= 0 {
+		c.WebSocket.FrameRate = 5
+	}
+	if c.WebSocket.FrameBurst <= 0 {
+		c.WebSocket.FrameBurst = 10
+	}
+	if c.WebSocket.SendBuffer <= 0 {
+		c.WebSocket.SendBuffer = 64
+	}
+	if c.WebSocket.DropWatermark <= 0 {
+		c.WebSocket.DropWatermark = max(c.WebSocket.SendBuffer*3/4, 1)
+	}
+	if c.WebSocket.SlowClientPolicy == "" {
+		c.WebSocket.SlowClientPolicy = SlowClientDropOldest
+	}
+	if c.Captcha.Timeout <= 0 {
+		c.Captcha.Timeout = 5 * time.Second
+	}
+	if c.Billing.Provider == "" {
+		c.Billing.Provider = "billing"
+	}
+	if c.Billing.Tolerance <= 0 {
+		c.Billing.Tolerance = 5 * time.Minute
+	}
+	if c.Retention.Interval <= 0 {
+		c.Retention.Interval = time.Hour
+	}
+	if c.Metrics.Path == "" {
+		c.Metrics.Path = "/metrics"
+	}
+	if c.Metrics.LongChat <= 0 {
+		c.Metrics.LongChat = 2 * time.Minute
+	}
+	if c.Metrics.FlushInterval <= 0 {
+		c.Metrics.FlushInterval = time.Minute
+	}
+	if c.Retention.Periods == nil {
+		c.Retention.Periods = make(map[string]time.Duration)
+	}
+	for class, period := range DefaultRetentionPeriods {
+		if _, ok := c.Retention.Periods[class]; !ok {
+			c.Retention.Periods[class] = period
+		}
+	}
+	if c.Chat.Spam.RepeatLimit <= 0 {
+		c.Chat.Spam.RepeatLimit = 3
+	}
+	if c.Chat.Spam.RepeatWindow <= 0 {
+		c.Chat.Spam.RepeatWindow = time.Minute
+	}
+	if c.Chat.Spam.CapsRatio <= 0 {
+		c.Chat.Spam.CapsRatio = 0.7
+	}
+	if c.Chat.Spam.CapsMinLetters <= 0 {
+		c.Chat.Spam.CapsMinLetters = 10
+	}
+	if c.Chat.Spam.MaxEmoji <= 0 {
+		c.Chat.Spam.MaxEmoji = 10
+	}
+	defaultSpamActions := map[string]string{
+		"link":        SpamActionFlag,
+		"denied_link": SpamActionBlock,
+		"repeat":      SpamActionShadowLimit,
+		"caps":        SpamActionFlag,
+		"emoji":       SpamActionFlag,
+	}
+	if c.Chat.Spam.Actions == nil {
+		c.Chat.Spam.Actions = make(map[string]string)
+	}
+	for reason, action := range defaultSpamActions {
+		if c.Chat.Spam.Actions[reason] == "" {
+			c.Chat.Spam.Actions[reason] = action
+		}
+	}
+	if c.Email.Port == 0 {
+		c.Email.Port = 587
+	}
+	if c.Email.DefaultLocale == "" {
+		c.Email.DefaultLocale = "vi"
+	}
+	if c.Email.Queue.Size <= 0 {
+		c.Email.Queue.Size = 100
+	}
+	if c.Email.Queue.Workers <= 0 {
+		c.Email.Queue.Workers = 2
+	}
+	if c.Email.Queue.MaxAttempts <= 0 {
+		c.Email.Queue.MaxAttempts = 3
+	}
+	if c.Email.Queue.RetryBackoff <= 0 {
+		c.Email.Queue.RetryBackoff = 5 * time.Second
+	}
+	if c.Email.Queue.SendTimeout <= 0 {
+		c.Email.Queue.SendTimeout = 30 * time.Second
+	}
+	if c.Chat.MaxMessageLength <= 0 {
+		c.Chat.MaxMessageLength = 500
+	}
+	if c.Chat.MaxMessageLines <= 0 {
+		c.Chat.MaxMessageLines = 20
+	}
+	if c.Chat.EditWindow <= 0 {
+		c.Chat.EditWindow = 5 * time.Minute
+	}
+	if c.Chat.HistoryLimit <= 0 {
+		c.Chat.HistoryLimit = 100
+	}
+	if c.Chat.SkipThreshold <= 0 {
+		c.Chat.SkipThreshold = 30 * time.Second
+	}
+	if c.Chat.TimeZoneBias.MaxOffset <= 0 {
+		c.Chat.TimeZoneBias.MaxOffset = 3 * time.Hour
+	}
+	if compat := &c.Chat.Compatibility; compat.LanguageWeight == 0 && compat.AgeWeight == 0 && compat.TimeZoneWeight == 0 {
+		compat.LanguageWeight = 2
+		compat.AgeWeight = 1
+		compat.TimeZoneWeight = 1
+	}
+	if c.Chat.Compatibility.MaxAgeGap <= 0 {
+		c.Chat.Compatibility.MaxAgeGap = 10
+	}
+	if c.Chat.Compatibility.MaxOffset <= 0 {
+		c.Chat.Compatibility.MaxOffset = 6 * time.Hour
+	}
+	if c.Chat.Autoscale.Interval <= 0 {
+		c.Chat.Autoscale.Interval = 15 * time.Second
+	}
+	if c.Chat.Autoscale.MinRooms <= 0 {
+		c.Chat.Autoscale.MinRooms = max(c.Chat.MaxRooms/2, 1)
+	}
+	if c.Chat.Autoscale.MaxRooms <= 0 {
+		c.Chat.Autoscale.MaxRooms = c.Chat.MaxRooms * 2
+	}
+	if c.Chat.BlocklistRefresh <= 0 {
+		c.Chat.BlocklistRefresh = time.Minute
+	}
+	if c.Chat.ExperimentRefresh <= 0 {
+		c.Chat.ExperimentRefresh = time.Minute
+	}
+	if c.Server.Idempotency.TTL <= 0 {
+		c.Server.Idempotency.TTL = 10 * time.Minute
+	}
+	if c.Server.Idempotency.MaxEntries <= 0 {
+		c.Server.Idempotency.MaxEntries = 10000
+	}
+	if c.Server.ReadHeaderTimeout <= 0 {
+		c.Server.ReadHeaderTimeout = 10 * time.Second
+	}
+	if c.Server.IdleTimeout <= 0 {
+		c.Server.IdleTimeout = 2 * time.Minute
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		c.Server.MaxHeaderBytes = 1 << 20
+	}
+	if c.Server.HTTP2.MaxConcurrentStreams == 0 {
+		c.Server.HTTP2.MaxConcurrentStreams = 250
+	}
+	if c.Server.HTTP2.MaxReadFrameSize == 0 {
+		c.Server.HTTP2.MaxReadFrameSize = 1 << 20
+	}
+	if c.Server.HTTP2.IdleTimeout <= 0 {
+		c.Server.HTTP2.IdleTimeout = c.Server.IdleTimeout
+	}
+	if c.Server.Frontend.AssetMaxAge <= 0 {
+		c.Server.Frontend.AssetMaxAge = 365 * 24 * time.Hour
+	}
+	if c.Features.ProfileViews.FlushInterval <= 0 {
+		c.Features.ProfileViews.FlushInterval = 10 * time.Second
+	}
+	if c.Features.ProfileViews.MaxPending <= 0 {
+		c.Features.ProfileViews.MaxPending = 10000
+	}
+	if c.Logging.Dir == "" {
+		c.Logging.Dir = "logs"
+	}
+	if c.Logging.MaxSizeMB <= 0 {
+		c.Logging.MaxSizeMB = 100
+	}
+	if c.Logging.Access.Output == "" {
+		c.Logging.Access.Output = AccessLogFile
+	}
+	if c.Logging.Access.SampleRate <= 0 {
+		c.Logging.Access.SampleRate = 1
+	}
+	if c.Chat.Replay.Limit <= 0 {
+		c.Chat.Replay.Limit = 20
+	}
+	if c.Chat.Snapshot.Path == "" {
+		c.Chat.Snapshot.Path = "data/chat-snapshot.json"
+	}
+	if c.Chat.Snapshot.Interval <= 0 {
+		c.Chat.Snapshot.Interval = 30 * time.Second
+	}
+	if c.Chat.Snapshot.MaxAge <= 0 {
+		c.Chat.Snapshot.MaxAge = 10 * time.Minute
+	}
+	if c.Chat.Snapshot.RestoreGrace <= 0 {
+		c.Chat.Snapshot.RestoreGrace = 2 * time.Minute
+	}
+	if c.Chat.RoomToken.TTL <= 0 {
+		c.Chat.RoomToken.TTL = 2 * time.Minute
+	}
+	if c.Chat.Bot.Name == "" {
+		c.Chat.Bot.Name = "chatmix"
+	}
+	if c.Chat.Bot.WaitThreshold <= 0 {
+		c.Chat.Bot.WaitThreshold = time.Minute
+	}
+	if c.Chat.Bot.MaxRooms <= 0 {
+		c.Chat.Bot.MaxRooms = 10
+	}
+	if c.Translation.Timeout <= 0 {
+		c.Translation.Timeout = 5 * time.Second
+	}
+	if c.Translation.CacheSize <= 0 {
+		c.Translation.CacheSize = 10000
+	}
+	if c.Translation.CacheTTL <= 0 {
+		c.Translation.CacheTTL = 24 * time.Hour
+	}
+}
+
 func (c *Config) validate() error {
 	if c.Server.Host == "" {
 		return fmt.Errorf("server host is required")
@@ -108,6 +1149,55 @@ func (c *Config) validate() error {
 		return fmt.Errorf("server port must be between 1 and 65535")
 	}
 
+	if c.Server.ReadTimeout > 0 && c.Server.ReadHeaderTimeout > c.Server.ReadTimeout {
+		return fmt.Errorf("server read_header_timeout must not exceed read_timeout")
+	}
+
+	if _, err := c.Server.TrustedProxyPrefixes(); err != nil {
+		return fmt.Errorf("server trusted_proxies: %w", err)
+	}
+
+	if c.Server.MaxHeaderBytes < 4<<10 || c.Server.MaxHeaderBytes > 16<<20 {
+		return fmt.Errorf("server max_header_bytes must be between 4 KiB and 16 MiB")
+	}
+
+	if c.Server.HTTP2.MaxReadFrameSize < 16<<10 || c.Server.HTTP2.MaxReadFrameSize > 16<<20-1 {
+		return fmt.Errorf("server http2 max_read_frame_size must be between 16384 and 16777215")
+	}
+
+	if c.Server.Protocol.MinVersion < 0 || c.Server.Protocol.DeprecatedBelow < 0 {
+		return fmt.Errorf("server protocol versions must not be negative")
+	}
+
+	switch c.Database.Driver {
+	case "", DriverMongo, DriverPostgres:
+	default:
+		return fmt.Errorf("unsupported database driver: %s", c.Database.Driver)
+	}
+
+	switch c.WebSocket.DuplicatePolicy {
+	case DuplicateNewest, DuplicateOldest:
+	default:
+		return fmt.Errorf("unsupported websocket duplicate policy: %s", c.WebSocket.DuplicatePolicy)
+	}
+
+	switch c.WebSocket.SlowClientPolicy {
+	case SlowClientDropOldest, SlowClientDisconnect:
+	default:
+		return fmt.Errorf("unsupported websocket slow client policy: %s", c.WebSocket.SlowClientPolicy)
+	}
+	if c.WebSocket.DropWatermark > c.WebSocket.SendBuffer {
+		return fmt.Errorf("websocket drop_watermark must not exceed send_buffer")
+	}
+
+	for reason, action := range c.Chat.Spam.Actions {
+		switch action {
+		case SpamActionFlag, SpamActionBlock, SpamActionShadowLimit:
+		default:
+			return fmt.Errorf("unsupported spam action for %s: %s", reason, action)
+		}
+	}
+
 	if c.Database.URI == "" {
 		return fmt.Errorf("database URI is required")
 	}
@@ -116,6 +1206,121 @@ func (c *Config) validate() error {
 		return fmt.Errorf("database name is required")
 	}
 
+	if c.Database.Pool.MaxSize > 0 && c.Database.Pool.MinSize > c.Database.Pool.MaxSize {
+		return fmt.Errorf("database pool min size must not exceed max size")
+	}
+
+	switch c.Database.ReadConcern {
+	case "", "local", "available", "majority", "linearizable", "snapshot":
+	default:
+		return fmt.Errorf("unsupported database read concern: %s", c.Database.ReadConcern)
+	}
+
+	if w := c.Database.WriteConcern; w != "" && w != "majority" {
+		if n, err := strconv.Atoi(w); err != nil || n < 0 {
+			return fmt.Errorf("database write concern must be \"majority\" or a non-negative number")
+		}
+	}
+
+	if c.Email.Enabled && (c.Email.Host == "" || c.Email.From == "") {
+		return fmt.Errorf("email host and from address are required when email is enabled")
+	}
+
+	if c.Auth.StepUp.Enabled && !c.Email.Enabled {
+		return fmt.Errorf("step-up login verification requires email to be enabled")
+	}
+
+	switch c.Captcha.GetProvider() {
+	case CaptchaBuiltin:
+	case CaptchaHCaptcha, CaptchaReCaptcha:
+		if c.Captcha.SiteKey == "" || c.Captcha.SecretKey == "" {
+			return fmt.Errorf("captcha site key and secret key are required for %s", c.Captcha.Provider)
+		}
+	default:
+		return fmt.Errorf("unsupported captcha provider: %s", c.Captcha.Provider)
+	}
+
+	if output := c.Logging.Access.Output; output != AccessLogFile && output != AccessLogStdout {
+		return fmt.Errorf("unsupported access log output: %s", output)
+	}
+	if c.Logging.Access.SampleRate > 1 {
+		return fmt.Errorf("access log sample rate must be between 0 and 1")
+	}
+
+	if c.Billing.WebhookSecret != "" && len(c.Billing.WebhookSecret) < 16 {
+		return fmt.Errorf("billing webhook secret must be at least 16 characters")
+	}
+	if c.Billing.Provider == "admin" {
+		return fmt.Errorf("billing provider must not be %q, it marks subscriptions granted by admins", c.Billing.Provider)
+	}
+
+	for class, period := range c.Retention.Periods {
+		if _, ok := DefaultRetentionPeriods[class]; !ok {
+			return fmt.Errorf("unknown retention class: %s", class)
+		}
+		if period < 0 {
+			return fmt.Errorf("retention period of %s must not be negative", class)
+		}
+	}
+
+	if !strings.HasPrefix(c.Metrics.Path, "/") || c.Metrics.Path == "/" ||
+		strings.HasPrefix(c.Metrics.Path, "/api/") || strings.HasPrefix(c.Metrics.Path, "/ws/") {
+		return fmt.Errorf("metrics path must be an absolute path outside /api and /ws")
+	}
+
+	if c.GeoIP.Enabled && c.GeoIP.DatabasePath == "" {
+		return fmt.Errorf("geoip database path is required when geoip is enabled")
+	}
+
+	if err := c.Server.CORS.validate(); err != nil {
+		return err
+	}
+
+	for _, route := range c.Server.BodyLimits.Routes {
+		if route.PathPrefix == "" || route.MaxBytes <= 0 {
+			return fmt.Errorf("body limit routes require a path_prefix and a positive max_bytes")
+		}
+	}
+
+	if err := c.Auth.validateKeys(); err != nil {
+		return err
+	}
+
+	if c.Auth.LoginThrottle.MaxDelay < c.Auth.LoginThrottle.BaseDelay {
+		return fmt.Errorf("auth login_throttle max_delay must not be below base_delay")
+	}
+
+	switch c.Auth.Passwords.Algorithm {
+	case PasswordBcrypt, PasswordArgon2id:
+	default:
+		return fmt.Errorf("unsupported password algorithm: %s", c.Auth.Passwords.Algorithm)
+	}
+	if c.Auth.Passwords.BcryptCost < 4 || c.Auth.Passwords.BcryptCost > 31 {
+		return fmt.Errorf("auth passwords bcrypt_cost must be between 4 and 31")
+	}
+	if argon := c.Auth.Passwords.Argon2; argon.Memory < 8*uint32(argon.Parallelism) || argon.SaltLength < 8 || argon.KeyLength < 16 {
+		return fmt.Errorf("auth passwords argon2 needs memory of at least 8 KiB per thread, salt_length >= 8 and key_length >= 16")
+	}
+	if c.Auth.Passwords.TargetLatency.Min >= c.Auth.Passwords.TargetLatency.Max {
+		return fmt.Errorf("auth passwords target_latency min must be below max")
+	}
+
+	switch c.Auth.TokenTransport {
+	case TokenTransportHeader, TokenTransportCookie:
+	default:
+		return fmt.Errorf("unsupported auth token transport: %s", c.Auth.TokenTransport)
+	}
+
+	switch c.Auth.Cookies.SameSite {
+	case "lax", "strict":
+	case "none":
+		if c.Auth.Cookies.Insecure {
+			return fmt.Errorf("auth cookies with same_site none must be secure")
+		}
+	default:
+		return fmt.Errorf("unsupported auth cookie same_site: %s", c.Auth.Cookies.SameSite)
+	}
+
 	if c.Features.MaxUsernameLength <= 0 {
 		return fmt.Errorf("max username length must be positive")
 	}
@@ -124,6 +1329,31 @@ func (c *Config) validate() error {
 		return fmt.Errorf("max rooms must be positive")
 	}
 
+	if c.Chat.MaxQueueLength < 0 {
+		return fmt.Errorf("max queue length must not be negative")
+	}
+
+	if quotas := c.Chat.Quotas; quotas.MaxChatsPerDay < 0 || quotas.MaxChatMinutesPerDay < 0 ||
+		quotas.Premium.MaxChatsPerDay < 0 || quotas.Premium.MaxChatMinutesPerDay < 0 {
+		return fmt.Errorf("chat quotas must not be negative")
+	}
+
+	if compat := c.Chat.Compatibility; compat.LanguageWeight < 0 || compat.AgeWeight < 0 || compat.TimeZoneWeight < 0 {
+		return fmt.Errorf("chat compatibility weights must not be negative")
+	}
+
+	if autoscale := c.Chat.Autoscale; autoscale.Enabled {
+		if autoscale.MinRooms > autoscale.MaxRooms {
+			return fmt.Errorf("chat autoscale min_rooms must not exceed max_rooms")
+		}
+		if autoscale.MaxHeapMB < 0 || autoscale.MaxGoroutines < 0 || autoscale.MaxConnections < 0 {
+			return fmt.Errorf("chat autoscale ceilings must not be negative")
+		}
+		if autoscale.MaxHeapMB == 0 && autoscale.MaxGoroutines == 0 && autoscale.MaxConnections == 0 {
+			return fmt.Errorf("chat autoscale needs at least one of max_heap_mb, max_goroutines or max_connections")
+		}
+	}
+
 	if c.Chat.QueueTimeout <= 0 {
 		return fmt.Errorf("queue timeout must be positive")
 	}
@@ -132,9 +1362,79 @@ func (c *Config) validate() error {
 		return fmt.Errorf("room cleanup interval must be positive")
 	}
 
+	if c.Chat.RoomToken.Secret != "" && len(c.Chat.RoomToken.Secret) < 16 {
+		return fmt.Errorf("chat room token secret must be at least 16 characters")
+	}
+
+	if c.Translation.Enabled {
+		if c.Translation.Provider != "" && c.Translation.Provider != "libretranslate" {
+			return fmt.Errorf("unsupported translation provider: %s", c.Translation.Provider)
+		}
+		if c.Translation.Endpoint == "" {
+			return fmt.Errorf("translation endpoint is required when translation is enabled")
+		}
+	}
+
 	return nil
 }
 
+func (a *AuthConfig) validateKeys() error {
+	if a.JWTSecret == "" && len(a.SigningKeys) == 0 {
+		return fmt.Errorf("jwt secret or signing keys are required")
+	}
+
+	// ids maps key id -> whether the key can sign
+	ids := map[string]bool{}
+	if a.JWTSecret != "" {
+		ids["default"] = true
+	}
+	for _, key := range a.SigningKeys {
+		if key.ID == "" {
+			return fmt.Errorf("signing keys require an id")
+		}
+		if _, exists := ids[key.ID]; exists {
+			return fmt.Errorf("duplicate signing key id: %s", key.ID)
+		}
+
+		switch key.GetAlgorithm() {
+		case AlgorithmHS256:
+			if key.Secret == "" {
+				return fmt.Errorf("signing key %s: HS256 requires a secret", key.ID)
+			}
+		case AlgorithmRS256, AlgorithmEdDSA:
+			if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+				return fmt.Errorf("signing key %s: %s requires a private or public key file", key.ID, key.Algorithm)
+			}
+		default:
+			return fmt.Errorf("signing key %s: unsupported algorithm %s", key.ID, key.Algorithm)
+		}
+
+		ids[key.ID] = key.CanSign()
+	}
+
+	activeID := a.ActiveKeyID
+	if activeID == "" {
+		activeID = "default"
+	}
+	canSign, exists := ids[activeID]
+	if !exists {
+		return fmt.Errorf("active signing key %q is not configured", activeID)
+	}
+	if !canSign {
+		return fmt.Errorf("active signing key %q has no private key", activeID)
+	}
+
+	return nil
+}
+
+// GetDatabaseDriver returns the configured database driver, defaulting to MongoDB
+func (c *Config) GetDatabaseDriver() string {
+	if c.Database.Driver == "" {
+		return DriverMongo
+	}
+	return c.Database.Driver
+}
+
 func (c *Config) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
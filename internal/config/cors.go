@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CORSRouteConfig overrides the global CORS policy for paths starting with PathPrefix.
+// Empty lists and a nil AllowCredentials inherit the global value.
+type CORSRouteConfig struct {
+	PathPrefix       string        `yaml:"path_prefix"`
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials *bool         `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// ForPath returns the policy for a request path, applying the route override
+// with the longest matching prefix
+func (c CORSConfig) ForPath(path string) CORSConfig {
+	var match *CORSRouteConfig
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if strings.HasPrefix(path, route.PathPrefix) && (match == nil || len(route.PathPrefix) > len(match.PathPrefix)) {
+			match = route
+		}
+	}
+
+	policy := c
+	policy.Routes = nil
+	if match == nil {
+		return policy
+	}
+
+	if len(match.AllowedOrigins) > 0 {
+		policy.AllowedOrigins = match.AllowedOrigins
+	}
+	if len(match.AllowedMethods) > 0 {
+		policy.AllowedMethods = match.AllowedMethods
+	}
+	if len(match.AllowedHeaders) > 0 {
+		policy.AllowedHeaders = match.AllowedHeaders
+	}
+	if len(match.ExposedHeaders) > 0 {
+		policy.ExposedHeaders = match.ExposedHeaders
+	}
+	if match.AllowCredentials != nil {
+		policy.AllowCredentials = *match.AllowCredentials
+	}
+	if match.MaxAge > 0 {
+		policy.MaxAge = match.MaxAge
+	}
+	return policy
+}
+
+// AllowsAnyOrigin reports whether the policy is the global "*" wildcard
+func (c CORSConfig) AllowsAnyOrigin() bool {
+	return len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*"
+}
+
+// AllowsOrigin reports whether the origin matches one of the allowed origins
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || allowed == origin || matchOriginPattern(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOriginPattern matches origins against a subdomain pattern such as https://*.chatmix.app.
+// The wildcard stands for one or more subdomain labels and never matches the bare domain.
+func matchOriginPattern(pattern, origin string) bool {
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	if !found || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	subdomain := origin[len(prefix):]
+	if len(subdomain) <= len(suffix) {
+		return false
+	}
+	subdomain = subdomain[:len(subdomain)-len(suffix)]
+	return !strings.ContainsAny(subdomain, "/:@")
+}
+
+func (c CORSConfig) validate() error {
+	origins := append([]string(nil), c.AllowedOrigins...)
+	for _, route := range c.Routes {
+		if route.PathPrefix == "" {
+			return fmt.Errorf("cors route overrides require a path_prefix")
+		}
+		origins = append(origins, route.AllowedOrigins...)
+	}
+
+	for _, origin := range origins {
+		if origin == "*" || !strings.Contains(origin, "*") {
+			continue
+		}
+		if strings.Count(origin, "*") > 1 || !strings.Contains(origin, "://*.") {
+			return fmt.Errorf("invalid cors origin pattern %q: only a leading subdomain wildcard like https://*.example.com is supported", origin)
+		}
+	}
+
+	return nil
+}
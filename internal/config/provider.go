@@ -0,0 +1,70 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Provider holds the active configuration snapshot and swaps it atomically on reload.
+// Only runtime-tunable values are taken from a reloaded file; everything else
+// (addresses, database, secrets) keeps the value loaded at startup.
+type Provider struct {
+	path      string
+	current   atomic.Pointer[Config]
+	mu        sync.Mutex
+	listeners []func(old, new *Config)
+}
+
+func NewProvider(path string, cfg *Config) *Provider {
+	p := &Provider{path: path}
+	p.current.Store(cfg)
+	return p
+}
+
+// Get returns the current configuration snapshot. Callers must treat it as read-only.
+func (p *Provider) Get() *Config {
+	return p.current.Load()
+}
+
+// OnReload registers a callback invoked after each successful reload
+func (p *Provider) OnReload(fn func(old, new *Config)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, fn)
+}
+
+// Reload re-reads the config file and swaps in its runtime-tunable values
+func (p *Provider) Reload() (*Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	loaded, err := Load(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	old := p.current.Load()
+	next := *old
+	next.applyRuntimeTunables(loaded)
+	p.current.Store(&next)
+
+	for _, fn := range p.listeners {
+		fn(old, &next)
+	}
+
+	return &next, nil
+}
+
+// applyRuntimeTunables copies the values that are safe to change without a restart
+func (c *Config) applyRuntimeTunables(src *Config) {
+	c.Logging.Level = src.Logging.Level
+	c.Logging.Components = src.Logging.Components
+	c.Server.CORS = src.Server.CORS
+	c.Chat.MaxRooms = src.Chat.MaxRooms
+	c.Chat.Autoscale = src.Chat.Autoscale
+	c.Chat.MaxQueueLength = src.Chat.MaxQueueLength
+	c.Chat.QueueTimeout = src.Chat.QueueTimeout
+	c.Chat.Quotas = src.Chat.Quotas
+	c.Chat.TimeZoneBias = src.Chat.TimeZoneBias
+	c.Chat.Compatibility = src.Chat.Compatibility
+}
@@ -0,0 +1,86 @@
+// Package event is an in-process publish/subscribe bus. Services publish typed events
+// and subsystems such as stats, badges and notification delivery subscribe to them,
+// so publishers do not need to know who reacts.
+package event
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event is a message published on the bus; Name identifies its type for subscribers
+type Event interface {
+	Name() string
+}
+
+// Handler receives the events of the type it subscribed to
+type Handler func(e Event)
+
+// Bus delivers each published event to its subscribers. Every handler runs in its own
+// goroutine, so a slow subscriber never blocks the publisher and a panic in one is
+// logged instead of taking down the process.
+type Bus struct {
+	handlers map[string][]Handler
+	lock     sync.RWMutex
+	wg       sync.WaitGroup
+	logger   *logrus.Logger
+}
+
+func NewBus(logger *logrus.Logger) *Bus {
+	return &Bus{
+		handlers: make(map[string][]Handler),
+		logger:   logger,
+	}
+}
+
+// Subscribe registers a handler for events with the given name
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.handlers[name] = append(b.handlers[name], handler)
+}
+
+// Publish delivers the event to its subscribers asynchronously. A nil bus drops the event.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.lock.RLock()
+	handlers := b.handlers[e.Name()]
+	b.lock.RUnlock()
+
+	for _, handler := range handlers {
+		b.wg.Add(1)
+		go b.dispatch(handler, e)
+	}
+}
+
+// Wait blocks until every handler started so far has returned, for graceful shutdown
+func (b *Bus) Wait() {
+	b.wg.Wait()
+}
+
+func (b *Bus) dispatch(handler Handler, e Event) {
+	defer b.wg.Done()
+	defer func() {
+		if r := recover(); r != nil && b.logger != nil {
+			b.logger.WithFields(logrus.Fields{
+				"event": e.Name(),
+				"panic": r,
+			}).Error("Event handler panicked")
+		}
+	}()
+	handler(e)
+}
+
+// Subscribe registers a handler for events of type T
+func Subscribe[T Event](b *Bus, handler func(e T)) {
+	var zero T
+	b.Subscribe(zero.Name(), func(e Event) {
+		if typed, ok := e.(T); ok {
+			handler(typed)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package event
+
+import (
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+// Event names
+const (
+	NameUserLoggedIn        = "user.logged_in"
+	NameUserLoggedOut       = "user.logged_out"
+	NameRoomClosed          = "room.closed"
+	NameRoomLifecycle       = "room.lifecycle"
+	NameRoomMemberExpired   = "room.member_expired"
+	NameBotJoined           = "room.bot_joined"
+	NameMatchMade           = "match.made"
+	NameMatchAbandoned      = "match.abandoned"
+	NameChannelJoined       = "channel.joined"
+	NameChannelLeft         = "channel.left"
+	NameChannelDeleted      = "channel.deleted"
+	NameUserKicked          = "user.kicked"
+	NameMessageSent         = "message.sent"
+	NameChatStatsRecorded   = "chat_stats.recorded"
+	NameNotificationCreated = "notification.created"
+)
+
+// UserLoggedIn is published whenever tokens are issued to a user: registration,
+// login and token refresh
+type UserLoggedIn struct {
+	User *model.User
+}
+
+func (UserLoggedIn) Name() string { return NameUserLoggedIn }
+
+// UserLoggedOut is published when a user logs out
+type UserLoggedOut struct {
+	Username string
+}
+
+func (UserLoggedOut) Name() string { return NameUserLoggedOut }
+
+// RoomClosed is published when a room that had two members is deleted
+type RoomClosed struct {
+	Summary model.RoomSummary
+}
+
+func (RoomClosed) Name() string { return NameRoomClosed }
+
+// RoomLifecycle is published when a room is created or closed and when a member joins
+// or leaves it, for the room event log
+type RoomLifecycle struct {
+	Event *model.RoomEvent
+}
+
+func (RoomLifecycle) Name() string { return NameRoomLifecycle }
+
+// RoomMemberExpired is published when a member of a room restored from a snapshot is
+// removed because they did not reconnect in time
+type RoomMemberExpired struct {
+	RoomCode string
+	Username string
+}
+
+func (RoomMemberExpired) Name() string { return NameRoomMemberExpired }
+
+// BotJoined is published when a bot takes the second slot of a room
+type BotJoined struct {
+	RoomCode string
+	Bot      string // the bot's room username
+}
+
+func (BotJoined) Name() string { return NameBotJoined }
+
+// MatchMade is published for each user who got a partner after looking for one, the
+// first member of a waiting room included
+type MatchMade struct {
+	Username string
+	Bucket   string        // preference bucket of the user, see model.MatchPreferences.Bucket
+	Wait     time.Duration // since the user started looking, queue time included
+	Bot      bool          // the partner is a bot
+	// Variants maps experiment key -> variant of the user, see model.Experiment
+	Variants map[string]string
+}
+
+func (MatchMade) Name() string { return NameMatchMade }
+
+// Reasons a user stopped looking for a partner, see MatchAbandoned
+const (
+	AbandonQueueTimeout = "queue_timeout" // dropped from the queue after chat.queue_timeout
+	AbandonLeft         = "left"          // left their room before a partner came
+	AbandonLonelyRoom   = "lonely_room"   // their room was closed for waiting too long
+)
+
+// MatchAbandoned is published when a user stops looking for a partner before getting one
+type MatchAbandoned struct {
+	Username string
+	Bucket   string
+	Reason   string
+	Waited   time.Duration
+	Variants map[string]string
+}
+
+func (MatchAbandoned) Name() string { return NameMatchAbandoned }
+
+// ChannelJoined is published when a user joins a channel
+type ChannelJoined struct {
+	Channel  string // channel slug
+	Username string
+}
+
+func (ChannelJoined) Name() string { return NameChannelJoined }
+
+// ChannelLeft is published when a user leaves a channel
+type ChannelLeft struct {
+	Channel  string
+	Username string
+}
+
+func (ChannelLeft) Name() string { return NameChannelLeft }
+
+// ChannelDeleted is published when a moderator deletes a channel
+type ChannelDeleted struct {
+	Channel string
+}
+
+func (ChannelDeleted) Name() string { return NameChannelDeleted }
+
+// UserKicked is published when a moderator removes a user from chat, see
+// ChatService.Kick. RoomCode is the room they were removed from, empty when they were in
+// none.
+type UserKicked struct {
+	UserID   string
+	Username string
+	RoomCode string
+}
+
+func (UserKicked) Name() string { return NameUserKicked }
+
+// MessageSent is published when a chat message is stored
+type MessageSent struct {
+	Message *model.Message
+}
+
+func (MessageSent) Name() string { return NameMessageSent }
+
+// ChatStatsRecorded is published with a user's updated totals after each recorded chat
+type ChatStatsRecorded struct {
+	Stats *model.ChatStats
+}
+
+func (ChatStatsRecorded) Name() string { return NameChatStatsRecorded }
+
+// NotificationCreated is published after a notification is stored
+type NotificationCreated struct {
+	Notification *model.Notification
+}
+
+func (NotificationCreated) Name() string { return NameNotificationCreated }
@@ -0,0 +1,1549 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminHandler handles moderator and admin requests
+type AdminHandler struct {
+	chatService         service.ChatService
+	roomLimiter         *service.RoomLimiter
+	userService         service.UserService
+	chatStatsService    service.ChatStatsService
+	messageService      service.MessageService
+	auditService        service.AuditService
+	notificationService service.NotificationService
+	bulkUserService     service.BulkUserService
+	userImportService   service.UserImportService
+	userAdminService    service.UserAdminService
+	subscriptionService service.SubscriptionService
+	roomEventService    service.RoomEventService
+	retentionService    service.RetentionService
+	legalHoldService    service.LegalHoldService
+	sanctionService     service.SanctionService
+	matchMetricsService service.MatchMetricsService
+	experimentService   service.ExperimentService
+	icebreakerService   service.IcebreakerService
+	channelService      service.ChannelService
+	blocklistService    service.BlocklistService
+	networkBanService   service.NetworkBanService
+	disposableService   service.DisposableEmailService
+	passwordService     service.PasswordService
+	logger              *logrus.Logger
+}
+
+type AnnouncementRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type BulkUserRequest struct {
+	Action model.BulkAction `json:"action"`
+	Filter model.UserFilter `json:"filter"`
+}
+
+func NewAdminHandler(
+	chatService service.ChatService,
+	roomLimiter *service.RoomLimiter,
+	userService service.UserService,
+	chatStatsService service.ChatStatsService,
+	messageService service.MessageService,
+	auditService service.AuditService,
+	notificationService service.NotificationService,
+	bulkUserService service.BulkUserService,
+	userImportService service.UserImportService,
+	userAdminService service.UserAdminService,
+	subscriptionService service.SubscriptionService,
+	roomEventService service.RoomEventService,
+	retentionService service.RetentionService,
+	legalHoldService service.LegalHoldService,
+	sanctionService service.SanctionService,
+	matchMetricsService service.MatchMetricsService,
+	experimentService service.ExperimentService,
+	icebreakerService service.IcebreakerService,
+	channelService service.ChannelService,
+	blocklistService service.BlocklistService,
+	networkBanService service.NetworkBanService,
+	disposableService service.DisposableEmailService,
+	passwordService service.PasswordService,
+	logger *logrus.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		chatService:         chatService,
+		roomLimiter:         roomLimiter,
+		userService:         userService,
+		chatStatsService:    chatStatsService,
+		messageService:      messageService,
+		auditService:        auditService,
+		notificationService: notificationService,
+		bulkUserService:     bulkUserService,
+		userImportService:   userImportService,
+		userAdminService:    userAdminService,
+		subscriptionService: subscriptionService,
+		roomEventService:    roomEventService,
+		retentionService:    retentionService,
+		legalHoldService:    legalHoldService,
+		sanctionService:     sanctionService,
+		matchMetricsService: matchMetricsService,
+		experimentService:   experimentService,
+		icebreakerService:   icebreakerService,
+		channelService:      channelService,
+		blocklistService:    blocklistService,
+		networkBanService:   networkBanService,
+		disposableService:   disposableService,
+		passwordService:     passwordService,
+		logger:              logger,
+	}
+}
+
+// ListRooms returns every active room with its members and activity
+func (h *AdminHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rooms := h.chatService.ListRooms()
+	views := make([]map[string]interface{}, len(rooms))
+	for i, room := range rooms {
+		views[i] = roomView(room)
+	}
+
+	h.audit(ctx, r, model.AuditActionListRooms, "", map[string]interface{}{"room_count": len(rooms)})
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rooms": views,
+		"total": len(views),
+	})
+}
+
+// GetStats returns user counts, live room and queue counts, and chat stats summed over all users
+func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	stats, err := h.userService.GetUserStats(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get user stats")
+		WriteError(w, http.StatusInternalServerError, "Failed to get stats")
+		return
+	}
+
+	chatStats, err := h.chatStatsService.GetGlobalStats(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get chat stats")
+		WriteError(w, http.StatusInternalServerError, "Failed to get stats")
+		return
+	}
+
+	stats["active_rooms"] = len(h.chatService.ListRooms())
+	stats["queue_size"] = h.chatService.GetQueueSize()
+	stats["room_limit"] = h.roomLimiter.Status()
+	stats["chats"] = chatStats
+	stats["spam"] = h.messageService.SpamStats()
+
+	WriteJSON(w, http.StatusOK, stats)
+}
+
+// GetRoom returns a single room with its recent messages
+func (h *AdminHandler) GetRoom(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	code := mux.Vars(r)["code"]
+	room, exists := h.chatService.GetRoom(code)
+	if !exists {
+		WriteError(w, http.StatusNotFound, "Room not found")
+		return
+	}
+
+	messages, err := h.messageService.GetRoomHistory(ctx, code)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get room messages")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionViewRoom, code, nil)
+
+	view := roomView(room)
+	view["messages"] = messages
+	view["observe_url"] = "/ws/admin/rooms/" + code + "/observe"
+
+	WriteJSON(w, http.StatusOK, view)
+}
+
+// GetRetentionReport reports what a retention run would delete now, without deleting
+// anything, see config.RetentionConfig
+func (h *AdminHandler) GetRetentionReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	WriteJSON(w, http.StatusOK, h.retentionService.Enforce(ctx, true))
+}
+
+// GetMatchmakingStats returns the daily matchmaking rollups per preference bucket: wait
+// times, bot fallbacks, abandoned searches, long chats and skips
+func (h *AdminHandler) GetMatchmakingStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	days := 7
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > service.MaxMatchStatsDays {
+			WriteError(w, http.StatusBadRequest, "days must be between 1 and "+strconv.Itoa(service.MaxMatchStatsDays))
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := h.matchMetricsService.DailyStats(ctx, days)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get matchmaking stats")
+		WriteError(w, http.StatusInternalServerError, "Failed to get matchmaking stats")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"days":  days,
+		"stats": stats,
+	})
+}
+
+// ListExperiments returns every matching experiment, running and stopped
+func (h *AdminHandler) ListExperiments(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	experiments, err := h.experimentService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list experiments")
+		WriteError(w, http.StatusInternalServerError, "Failed to list experiments")
+		return
+	}
+	if experiments == nil {
+		experiments = []*model.Experiment{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"experiments": experiments,
+	})
+}
+
+// CreateExperiment starts an experiment that splits users between variants of the
+// matching algorithm
+func (h *AdminHandler) CreateExperiment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.ExperimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	experiment, err := h.experimentService.Create(ctx, req, actor.Username)
+	if err != nil {
+		h.writeExperimentError(w, req.Key, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionExperimentCreate, experiment.Key, map[string]interface{}{
+		"variants": experiment.Variants,
+	})
+
+	WriteJSON(w, http.StatusCreated, experiment)
+}
+
+// StopExperiment ends a running experiment; its users get the configured matching again
+func (h *AdminHandler) StopExperiment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	experiment, err := h.experimentService.Stop(ctx, key, actor.Username)
+	if err != nil {
+		h.writeExperimentError(w, key, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionExperimentStop, experiment.Key, map[string]interface{}{
+		"created_at": experiment.CreatedAt,
+	})
+
+	WriteJSON(w, http.StatusOK, experiment)
+}
+
+func (h *AdminHandler) writeExperimentError(w http.ResponseWriter, key string, err error) {
+	switch {
+	case errors.Is(err, service.ErrExperimentNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrExperimentExists), errors.Is(err, service.ErrExperimentStopped):
+		WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrExperimentInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.WithError(err).WithField("experiment", key).Error("Experiment request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to update experiment")
+	}
+}
+
+// ListLegalHolds returns the users whose data is under a legal hold
+func (h *AdminHandler) ListLegalHolds(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	holds, err := h.legalHoldService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list legal holds")
+		WriteError(w, http.StatusInternalServerError, "Failed to list legal holds")
+		return
+	}
+	if holds == nil {
+		holds = []*model.LegalHold{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"holds": holds,
+	})
+}
+
+// PlaceLegalHold keeps the messages, sessions and reports of a user from retention and
+// from the user's own deletion requests until the hold is lifted
+func (h *AdminHandler) PlaceLegalHold(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.LegalHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	hold, err := h.legalHoldService.Place(ctx, username, req, actor.Username)
+	if err != nil {
+		h.writeLegalHoldError(w, username, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionPlaceLegalHold, hold.Username, map[string]interface{}{
+		"reason":   hold.Reason,
+		"case_ref": hold.CaseRef,
+	})
+
+	WriteJSON(w, http.StatusCreated, hold)
+}
+
+// LiftLegalHold removes the legal hold of a user
+func (h *AdminHandler) LiftLegalHold(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	username := mux.Vars(r)["username"]
+	hold, err := h.legalHoldService.Lift(ctx, username)
+	if err != nil {
+		h.writeLegalHoldError(w, username, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionLiftLegalHold, hold.Username, map[string]interface{}{
+		"reason":    hold.Reason,
+		"case_ref":  hold.CaseRef,
+		"placed_by": hold.PlacedBy,
+		"placed_at": hold.PlacedAt,
+	})
+
+	WriteJSON(w, http.StatusOK, hold)
+}
+
+func (h *AdminHandler) writeLegalHoldError(w http.ResponseWriter, username string, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		WriteError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, service.ErrLegalHoldNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrLegalHoldExists):
+		WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrLegalHoldInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.WithError(err).WithField("username", username).Error("Legal hold request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to update legal hold")
+	}
+}
+
+// ListRoomEvents returns the lifecycle log of rooms, newest first, filtered by room,
+// user and time. It also covers rooms that are closed and whose messages are gone.
+func (h *AdminHandler) ListRoomEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	filter := model.RoomEventFilter{
+		RoomCode: query.Get("room"),
+		Username: query.Get("user"),
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid since")
+			return
+		}
+		filter.Since = t
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			WriteError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, 1000)
+	}
+
+	events, err := h.roomEventService.Find(ctx, filter, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list room events")
+		WriteError(w, http.StatusInternalServerError, "Failed to list room events")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionListRoomEvents, filter.RoomCode, map[string]interface{}{"user": filter.Username})
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+		"total":  len(events),
+	})
+}
+
+// GetMessageRevisions returns a message with every version replaced by edits and deletion
+func (h *AdminHandler) GetMessageRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id := mux.Vars(r)["id"]
+	message, revisions, err := h.messageService.GetRevisions(ctx, id)
+	if err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			WriteError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		h.logger.WithError(err).WithField("message", id).Error("Failed to get message revisions")
+		WriteError(w, http.StatusInternalServerError, "Failed to get message revisions")
+		return
+	}
+	if revisions == nil {
+		revisions = []model.MessageRevision{}
+	}
+
+	h.audit(ctx, r, model.AuditActionViewMessageRevisions, id, nil)
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":   message,
+		"revisions": revisions,
+	})
+}
+
+// CreateAnnouncement sends a system announcement to every user's notification inbox
+func (h *AdminHandler) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var req AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		WriteError(w, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	recipients, err := h.notificationService.Announce(ctx, req.Title, req.Body)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to send announcement")
+		WriteError(w, http.StatusInternalServerError, "Failed to send announcement")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionAnnounce, "", map[string]interface{}{
+		"title":      req.Title,
+		"recipients": recipients,
+	})
+
+	WriteJSON(w, http.StatusCreated, map[string]interface{}{
+		"recipients": recipients,
+	})
+}
+
+// StartBulkUserJob starts a background ban, unban, verify or delete job over the users matching a filter
+func (h *AdminHandler) StartBulkUserJob(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req BulkUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	job, err := h.bulkUserService.StartJob(actor, req.Action, req.Filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBulkAction) || errors.Is(err, service.ErrEmptyUserFilter) {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "Failed to start bulk job")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionBulkUsers, job.ID, map[string]interface{}{
+		"action": job.Action,
+		"filter": job.Filter,
+	})
+
+	WriteJSON(w, http.StatusAccepted, job)
+}
+
+func (h *AdminHandler) ListBulkUserJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := h.bulkUserService.ListJobs()
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs":  jobs,
+		"total": len(jobs),
+	})
+}
+
+func (h *AdminHandler) GetBulkUserJob(w http.ResponseWriter, r *http.Request) {
+	job, exists := h.bulkUserService.GetJob(mux.Vars(r)["id"])
+	if !exists {
+		WriteError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	WriteJSON(w, http.StatusOK, job)
+}
+
+// ImportUsers starts a background import of accounts from a CSV body (Content-Type
+// text/csv) or a JSON array of records. With ?dry_run=true the records are only checked.
+// Generated passwords of temp_password records are listed on the finished job.
+func (h *AdminHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	format := service.ImportFormatJSON
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		format = service.ImportFormatCSV
+	}
+	records, err := service.ParseUserImport(r.Body, format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyError(w, err)
+			return
+		}
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	job, err := h.userImportService.StartJob(actor, records, dryRun)
+	if err != nil {
+		if errors.Is(err, service.ErrUserImportEmpty) {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "Failed to start import")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionImportUsers, job.ID, map[string]interface{}{
+		"records": len(records),
+		"dry_run": dryRun,
+	})
+
+	WriteJSON(w, http.StatusAccepted, job)
+}
+
+func (h *AdminHandler) GetUserImportJob(w http.ResponseWriter, r *http.Request) {
+	job, exists := h.userImportService.GetJob(mux.Vars(r)["id"])
+	if !exists {
+		WriteError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	WriteJSON(w, http.StatusOK, job)
+}
+
+// ListUsers searches the user directory with the optional banned and verified
+// (true/false), registered_after, registered_before and last_seen_before (RFC 3339) and
+// email_domain query filters, sorted by sort (joined_at by default, see model.UserSort),
+// limit users (default 50, max 200) a page after cursor
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	search := model.UserDirectoryQuery{EmailDomain: strings.TrimPrefix(query.Get("email_domain"), "@")}
+	for name, target := range map[string]**bool{
+		"banned":   &search.Banned,
+		"verified": &search.Verified,
+	} {
+		if value := query.Get(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*target = &b
+		}
+	}
+	for name, target := range map[string]**time.Time{
+		"registered_after":  &search.RegisteredAfter,
+		"registered_before": &search.RegisteredBefore,
+		"last_seen_before":  &search.LastSeenBefore,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*target = &t
+		}
+	}
+
+	var ok bool
+	if search.Sort, ok = model.ParseUserSort(query.Get("sort")); !ok {
+		WriteError(w, http.StatusBadRequest, "Invalid sort")
+		return
+	}
+	request, err := parsePageRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = 50
+	}
+	request.Limit = min(request.Limit, 200)
+
+	page, err := h.userAdminService.SearchUsers(ctx, search, request)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list users")
+		WriteError(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+	}
+	WriteJSON(w, http.StatusOK, page)
+}
+
+// BanUser bans a user and ends their sessions; DELETE on the same path unbans
+func (h *AdminHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	h.setBanned(w, r, true)
+}
+
+func (h *AdminHandler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	h.setBanned(w, r, false)
+}
+
+func (h *AdminHandler) setBanned(w http.ResponseWriter, r *http.Request, banned bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	username := mux.Vars(r)["username"]
+	user, err := h.userAdminService.SetBanned(ctx, username, banned)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		h.logger.WithError(err).WithField("username", username).Error("Failed to update user ban")
+		WriteError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	action := model.AuditActionUnbanUser
+	if banned {
+		action = model.AuditActionBanUser
+	}
+	h.audit(ctx, r, action, user.Username, nil)
+
+	WriteJSON(w, http.StatusOK, user)
+}
+
+// KickUser removes a user from their room and closes their chat connections, optionally
+// timing them out of chat
+func (h *AdminHandler) KickUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.KickRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+	}
+	if req.SuspendMinutes < 0 {
+		WriteError(w, http.StatusBadRequest, "suspend_minutes must not be negative")
+		return
+	}
+
+	user, ok := h.sanctionTarget(ctx, w, mux.Vars(r)["username"])
+	if !ok {
+		return
+	}
+
+	var timeout *model.Sanction
+	if req.SuspendMinutes > 0 {
+		var err error
+		timeout, err = h.sanctionService.Timeout(ctx, user, model.TimeoutRequest{Minutes: req.SuspendMinutes, Reason: req.Reason}, actor.Username)
+		if err != nil {
+			h.writeSanctionError(w, user.Username, err)
+			return
+		}
+	}
+
+	roomCode := h.chatService.Kick(user)
+
+	details := map[string]interface{}{"room": roomCode, "suspend_minutes": req.SuspendMinutes}
+	if req.Reason != "" {
+		details["reason"] = req.Reason
+	}
+	h.audit(ctx, r, model.AuditActionKickUser, user.Username, details)
+
+	response := map[string]interface{}{"username": user.Username, "room": roomCode}
+	if timeout != nil {
+		response["suspended_until"] = timeout.ExpiresAt
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// TimeoutUser times a user out of matchmaking and chat rooms, removing them from their
+// room. Unlike a ban the user can still log in and use their profile.
+func (h *AdminHandler) TimeoutUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.TimeoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	user, ok := h.sanctionTarget(ctx, w, mux.Vars(r)["username"])
+	if !ok {
+		return
+	}
+
+	timeout, err := h.sanctionService.Timeout(ctx, user, req, actor.Username)
+	if err != nil {
+		h.writeSanctionError(w, user.Username, err)
+		return
+	}
+	roomCode := h.chatService.Kick(user)
+
+	h.audit(ctx, r, model.AuditActionTimeoutUser, user.Username, map[string]interface{}{
+		"room":       roomCode,
+		"minutes":    req.Minutes,
+		"reason":     timeout.Reason,
+		"expires_at": timeout.ExpiresAt,
+	})
+
+	WriteJSON(w, http.StatusCreated, timeout)
+}
+
+// LiftTimeout lets a timed out user chat again
+func (h *AdminHandler) LiftTimeout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	if err := h.sanctionService.LiftTimeout(ctx, username, actor.Username); err != nil {
+		h.writeSanctionError(w, username, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionLiftTimeout, username, nil)
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"username": username})
+}
+
+// ListSanctions returns the timeouts of a user, newest first, including lifted and
+// expired ones
+func (h *AdminHandler) ListSanctions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	username := mux.Vars(r)["username"]
+	sanctions, err := h.sanctionService.History(ctx, username)
+	if err != nil {
+		h.writeSanctionError(w, username, err)
+		return
+	}
+	if sanctions == nil {
+		sanctions = []*model.Sanction{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"sanctions": sanctions,
+	})
+}
+
+// sanctionTarget returns the user to sanction, writing the error response when there is none
+func (h *AdminHandler) sanctionTarget(ctx context.Context, w http.ResponseWriter, username string) (*model.User, bool) {
+	user, err := h.userService.GetUser(ctx, username)
+	if err != nil {
+		h.logger.WithError(err).WithField("username", username).Error("Failed to get user")
+		WriteError(w, http.StatusInternalServerError, "Failed to get user")
+		return nil, false
+	}
+	if user == nil {
+		WriteError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *AdminHandler) writeSanctionError(w http.ResponseWriter, username string, err error) {
+	switch {
+	case errors.Is(err, service.ErrTimeoutNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrTimeoutInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.WithError(err).WithField("username", username).Error("Sanction request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to update sanctions")
+	}
+}
+
+// GrantSubscription sets the tier of a user, replacing their current subscription
+func (h *AdminHandler) GrantSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var req model.SubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Tier != model.TierFree && req.Tier != model.TierPremium {
+		WriteError(w, http.StatusBadRequest, "tier must be free or premium")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		WriteError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+	if len(req.Reference) > 200 {
+		WriteError(w, http.StatusBadRequest, "reference must be at most 200 characters")
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	user, err := h.subscriptionService.Grant(ctx, username, &req, model.SubscriptionSourceAdmin)
+	if err != nil {
+		h.writeSubscriptionError(w, username, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionGrantSubscription, user.Username, map[string]interface{}{
+		"tier":       req.Tier,
+		"expires_at": req.ExpiresAt,
+	})
+
+	WriteJSON(w, http.StatusOK, user)
+}
+
+// RevokeSubscription moves a user to the free tier
+func (h *AdminHandler) RevokeSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	username := mux.Vars(r)["username"]
+	user, err := h.subscriptionService.Revoke(ctx, username, model.SubscriptionSourceAdmin)
+	if err != nil {
+		h.writeSubscriptionError(w, username, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionRevokeSubscription, user.Username, nil)
+
+	WriteJSON(w, http.StatusOK, user)
+}
+
+func (h *AdminHandler) writeSubscriptionError(w http.ResponseWriter, username string, err error) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	h.logger.WithError(err).WithField("username", username).Error("Failed to update subscription")
+	WriteError(w, http.StatusInternalServerError, "Failed to update subscription")
+}
+
+// RevokeUserSessions ends every session and refresh token of a user
+func (h *AdminHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	username := mux.Vars(r)["username"]
+	user, err := h.userAdminService.RevokeSessions(ctx, username)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		h.logger.WithError(err).WithField("username", username).Error("Failed to revoke user sessions")
+		WriteError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionRevokeUserSessions, user.Username, nil)
+
+	WriteStatus(w, http.StatusNoContent)
+}
+
+// PurgeExpiredTokens deletes expired refresh tokens, sessions and captchas
+func (h *AdminHandler) PurgeExpiredTokens(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := h.userAdminService.PurgeExpired(ctx); err != nil {
+		h.logger.WithError(err).Error("Failed to purge expired tokens")
+		WriteError(w, http.StatusInternalServerError, "Failed to purge expired tokens")
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionPurgeTokens, "", nil)
+
+	WriteStatus(w, http.StatusNoContent)
+}
+
+// ListAuditLogs returns the newest audit entries matching the optional action, target
+// and since (RFC 3339) query filters, at most limit (default 50, max 500)
+func (h *AdminHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	filter := model.AuditFilter{
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid since")
+			return
+		}
+		filter.Since = t
+	}
+	limit := 50
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			WriteError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, 500)
+	}
+
+	entries, err := h.auditService.Find(ctx, filter, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list audit logs")
+		WriteError(w, http.StatusInternalServerError, "Failed to list audit logs")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
+
+// ListIcebreakers returns the whole prompt pool with usage counts
+func (h *AdminHandler) ListIcebreakers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	icebreakers, err := h.icebreakerService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list icebreakers")
+		WriteError(w, http.StatusInternalServerError, "Failed to list icebreakers")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"icebreakers": icebreakers,
+		"total":       len(icebreakers),
+		"enabled":     h.icebreakerService.Enabled(),
+	})
+}
+
+func (h *AdminHandler) CreateIcebreaker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var req model.IcebreakerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	icebreaker, err := h.icebreakerService.Create(ctx, req)
+	if err != nil {
+		h.writeIcebreakerError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionIcebreakerCreate, icebreaker.ID.Hex(), map[string]interface{}{"text": icebreaker.Text})
+
+	WriteJSON(w, http.StatusCreated, icebreaker)
+}
+
+func (h *AdminHandler) UpdateIcebreaker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var req model.IcebreakerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	icebreaker, err := h.icebreakerService.Update(ctx, mux.Vars(r)["id"], req)
+	if err != nil {
+		h.writeIcebreakerError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionIcebreakerUpdate, icebreaker.ID.Hex(), map[string]interface{}{
+		"text":   icebreaker.Text,
+		"active": icebreaker.Active,
+	})
+
+	WriteJSON(w, http.StatusOK, icebreaker)
+}
+
+func (h *AdminHandler) DeleteIcebreaker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id := mux.Vars(r)["id"]
+	if err := h.icebreakerService.Delete(ctx, id); err != nil {
+		h.writeIcebreakerError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionIcebreakerDelete, id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) writeIcebreakerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrIcebreakerNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrIcebreakerInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.WithError(err).Error("Icebreaker request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to save icebreaker")
+	}
+}
+
+// ListBlocklist returns the moderation blocklist entries
+func (h *AdminHandler) ListBlocklist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	entries, err := h.blocklistService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list blocklist")
+		WriteError(w, http.StatusInternalServerError, "Failed to list blocklist")
+		return
+	}
+	if entries == nil {
+		entries = []*model.BlocklistEntry{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
+
+func (h *AdminHandler) CreateBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.BlocklistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	entry, err := h.blocklistService.Create(ctx, req, actor.Username)
+	if err != nil {
+		h.writeBlocklistError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionBlocklistCreate, entry.ID.Hex(), blocklistAuditDetails(entry))
+
+	WriteJSON(w, http.StatusCreated, entry)
+}
+
+// UpdateBlocklistEntry replaces an entry; a version in the body guards against
+// overwriting a concurrent change
+func (h *AdminHandler) UpdateBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.BlocklistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	entry, err := h.blocklistService.Update(ctx, mux.Vars(r)["id"], req, actor.Username)
+	if err != nil {
+		h.writeBlocklistError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionBlocklistUpdate, entry.ID.Hex(), blocklistAuditDetails(entry))
+
+	WriteJSON(w, http.StatusOK, entry)
+}
+
+func (h *AdminHandler) DeleteBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	entry, err := h.blocklistService.Delete(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeBlocklistError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionBlocklistDelete, entry.ID.Hex(), blocklistAuditDetails(entry))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) writeBlocklistError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBlocklistNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrBlocklistInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrBlocklistConflict):
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.WithError(err).Error("Blocklist request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to save blocklist entry")
+	}
+}
+
+// blocklistAuditDetails records the whole entry at each version, so the audit log holds
+// the history of every entry
+func blocklistAuditDetails(entry *model.BlocklistEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"pattern":  entry.Pattern,
+		"regex":    entry.Regex,
+		"severity": entry.Severity,
+		"language": entry.Language,
+		"version":  entry.Version,
+	}
+}
+
+// ListNetworkBans returns the IP and device bans, oldest first
+func (h *AdminHandler) ListNetworkBans(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	bans, err := h.networkBanService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list network bans")
+		WriteError(w, http.StatusInternalServerError, "Failed to list network bans")
+		return
+	}
+	if bans == nil {
+		bans = []*model.NetworkBan{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"bans":  bans,
+		"total": len(bans),
+	})
+}
+
+// CreateNetworkBan bans an IP address, a CIDR range or a device fingerprint from
+// registration, login and chat, for ttl_minutes or permanently
+func (h *AdminHandler) CreateNetworkBan(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.NetworkBanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	ban, err := h.networkBanService.Create(ctx, req, actor.Username)
+	if err != nil {
+		h.writeNetworkBanError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionNetworkBanCreate, ban.ID.Hex(), networkBanAuditDetails(ban))
+
+	WriteJSON(w, http.StatusCreated, ban)
+}
+
+// UpdateNetworkBan sets the reason and TTL of a ban; the TTL counts from now
+func (h *AdminHandler) UpdateNetworkBan(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.NetworkBanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	ban, err := h.networkBanService.Update(ctx, mux.Vars(r)["id"], req, actor.Username)
+	if err != nil {
+		h.writeNetworkBanError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionNetworkBanUpdate, ban.ID.Hex(), networkBanAuditDetails(ban))
+
+	WriteJSON(w, http.StatusOK, ban)
+}
+
+func (h *AdminHandler) DeleteNetworkBan(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	ban, err := h.networkBanService.Delete(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeNetworkBanError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionNetworkBanDelete, ban.ID.Hex(), networkBanAuditDetails(ban))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) writeNetworkBanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNetworkBanNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrNetworkBanInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrNetworkBanExists):
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.WithError(err).Error("Network ban request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to save network ban")
+	}
+}
+
+func networkBanAuditDetails(ban *model.NetworkBan) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       ban.Type,
+		"value":      ban.Value,
+		"reason":     ban.Reason,
+		"expires_at": ban.ExpiresAt,
+	}
+}
+
+// GetDisposableDomains describes the disposable email domain lists and returns the
+// domains blocked or allowed through BlockDisposableDomain and UnblockDisposableDomain
+func (h *AdminHandler) GetDisposableDomains(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	status, err := h.disposableService.Status(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list disposable domains")
+		WriteError(w, http.StatusInternalServerError, "Failed to list disposable domains")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// BlockDisposableDomain refuses registrations with addresses of a domain and its subdomains
+func (h *AdminHandler) BlockDisposableDomain(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.DisposableDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	domain, err := h.disposableService.Block(ctx, req.Domain, actor.Username)
+	if err != nil {
+		h.writeDisposableDomainError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionDisposableDomainBlock, domain.Domain, nil)
+
+	WriteJSON(w, http.StatusOK, domain)
+}
+
+// UnblockDisposableDomain allows a domain again, even one on the built-in or remote list
+func (h *AdminHandler) UnblockDisposableDomain(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	domain, err := h.disposableService.Unblock(ctx, mux.Vars(r)["domain"], actor.Username)
+	if err != nil {
+		h.writeDisposableDomainError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionDisposableDomainUnblock, domain.Domain, nil)
+
+	WriteJSON(w, http.StatusOK, domain)
+}
+
+// BenchmarkPasswords times password hashes with the configured parameters on this
+// instance and reports whether they meet auth.passwords.target_latency
+func (h *AdminHandler) BenchmarkPasswords(w http.ResponseWriter, r *http.Request) {
+	// A few hashes at a high cost take seconds
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	result, err := h.passwordService.Benchmark(ctx)
+	if err != nil {
+		if errors.Is(err, service.ErrPasswordBenchmarkRunning) {
+			WriteError(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.logger.WithError(err).Error("Failed to benchmark password hashing")
+		WriteError(w, http.StatusInternalServerError, "Failed to benchmark password hashing")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *AdminHandler) writeDisposableDomainError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrDisposableDomainInvalid):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrDisposableDomainNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.WithError(err).Error("Disposable domain request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to save disposable domain")
+	}
+}
+
+// CreateChannel adds a topic channel to the directory
+func (h *AdminHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	actor, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.ChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	channel, err := h.channelService.Create(ctx, req, actor.Username)
+	if err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionChannelCreate, channel.Slug, map[string]interface{}{"name": channel.Name})
+
+	WriteJSON(w, http.StatusCreated, channel)
+}
+
+func (h *AdminHandler) UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var req model.ChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	channel, err := h.channelService.Update(ctx, mux.Vars(r)["slug"], req)
+	if err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionChannelUpdate, channel.Slug, map[string]interface{}{
+		"name":        channel.Name,
+		"description": channel.Description,
+	})
+
+	WriteJSON(w, http.StatusOK, channel)
+}
+
+// DeleteChannel removes a channel and disconnects its members; its messages are kept
+func (h *AdminHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	slug := mux.Vars(r)["slug"]
+	if err := h.channelService.Delete(ctx, slug); err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+
+	h.audit(ctx, r, model.AuditActionChannelDelete, slug, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) writeChannelError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrChannelNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrChannelExists):
+		WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrChannelInvalid), errors.Is(err, service.ErrChannelDescTooLong):
+		WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.WithError(err).Error("Channel request failed")
+		WriteError(w, http.StatusInternalServerError, "Failed to save channel")
+	}
+}
+
+func (h *AdminHandler) audit(ctx context.Context, r *http.Request, action, target string, details map[string]interface{}) {
+	actor, _ := r.Context().Value("user").(*model.User)
+	entry := model.NewAuditLog(actor, action, target, clientIP(r))
+	entry.Details = details
+	h.auditService.Record(ctx, entry)
+}
+
+func roomView(room *model.ChatRoom) map[string]interface{} {
+	return map[string]interface{}{
+		"code":          room.Code,
+		"members":       room.Users,
+		"language":      room.Language,
+		"message_count": room.MessageCount,
+		"icebreaker_id": room.IcebreakerID,
+		"created_at":    room.CreatedAt,
+		"age_seconds":   int(time.Since(room.CreatedAt).Seconds()),
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyHandler manages the authenticated user's API keys and authenticates bot requests
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+	auditService  service.AuditService
+	validator     *validator.Validate
+	logger        *logrus.Logger
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyService, auditService service.AuditService, logger *logrus.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		auditService:  auditService,
+		validator:     validator.New(),
+		logger:        logger,
+	}
+}
+
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	keys, err := h.apiKeyService.List(ctx, user.ID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to list API keys")
+		WriteError(w, http.StatusInternalServerError, "Failed to get API keys")
+		return
+	}
+	if keys == nil {
+		keys = []*model.APIKey{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"keys":   keys,
+		"scopes": model.APIKeyScopes,
+	})
+}
+
+// CreateAPIKey issues a key; the response is the only time the key itself is shown
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	created, err := h.apiKeyService.Create(ctx, user, req)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyLimit) {
+			WriteError(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to create API key")
+		WriteError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	h.auditService.Record(ctx, model.NewAuditLog(user, model.AuditActionAPIKeyCreate, created.ID.Hex(), clientIP(r)))
+	WriteJSON(w, http.StatusCreated, created)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := h.apiKeyService.Revoke(ctx, user.ID, id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			WriteError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to revoke API key")
+		WriteError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	h.auditService.Record(ctx, model.NewAuditLog(user, model.AuditActionAPIKeyRevoke, id, clientIP(r)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIKeyMiddleware authenticates requests by their X-API-Key header, requires the scope
+// and enforces the key's rate limit. The key owner is stored in the context as "user"
+// and the key as "apiKey".
+func (h *APIKeyHandler) APIKeyMiddleware(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rawKey := r.Header.Get("X-API-Key")
+			if rawKey == "" {
+				WriteError(w, http.StatusUnauthorized, "API key required")
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			key, user, err := h.apiKeyService.Authenticate(ctx, rawKey)
+			cancel()
+			if err != nil {
+				if errors.Is(err, service.ErrAPIKeyInvalid) {
+					WriteError(w, http.StatusUnauthorized, "Invalid API key")
+					return
+				}
+				h.logger.WithError(err).Error("Failed to authenticate API key")
+				WriteError(w, http.StatusInternalServerError, "Failed to authenticate API key")
+				return
+			}
+
+			if !key.HasScope(scope) {
+				WriteError(w, http.StatusForbidden, "API key lacks scope "+scope)
+				return
+			}
+
+			remaining, retryAfter, err := h.apiKeyService.Allow(key)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.RateLimit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if err != nil {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				WriteError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+
+			noteAccessUser(r, user)
+			ctx = context.WithValue(r.Context(), "user", user)
+			ctx = context.WithValue(ctx, "apiKey", key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -3,10 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"chatmix-backend/internal/config"
 	"chatmix-backend/internal/model"
 	"chatmix-backend/internal/service"
 
@@ -17,22 +22,40 @@ import (
 
 // UserHandler handles authentication and user-related requests
 type UserHandler struct {
-	authService service.AuthService
-	userService service.UserService
-	validator   *validator.Validate
-	logger      *logrus.Logger
+	authService      service.AuthService
+	userService      service.UserService
+	auditService     service.AuditService
+	activityService  service.ActivityService
+	chatStatsService service.ChatStatsService
+	badgeService     service.BadgeService
+	profileViews     service.ProfileViewService
+	cookies          tokenCookies
+	validator        *validator.Validate
+	logger           *logrus.Logger
 }
 
 func NewUserHandler(
 	authService service.AuthService,
 	userService service.UserService,
+	auditService service.AuditService,
+	activityService service.ActivityService,
+	chatStatsService service.ChatStatsService,
+	badgeService service.BadgeService,
+	profileViews service.ProfileViewService,
+	authConfig config.AuthConfig,
 	logger *logrus.Logger,
 ) *UserHandler {
 	return &UserHandler{
-		authService: authService,
-		userService: userService,
-		validator:   validator.New(),
-		logger:      logger,
+		authService:      authService,
+		userService:      userService,
+		auditService:     auditService,
+		activityService:  activityService,
+		chatStatsService: chatStatsService,
+		badgeService:     badgeService,
+		profileViews:     profileViews,
+		cookies:          newTokenCookies(authConfig),
+		validator:        validator.New(),
+		logger:           logger,
 	}
 }
 
@@ -42,7 +65,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	var req model.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		writeBodyError(w, err)
 		return
 	}
 
@@ -62,9 +85,9 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		}).Error("Registration failed")
 
 		switch {
-		case strings.Contains(err.Error(), "already exists"):
+		case errors.Is(err, service.ErrUsernameTaken), errors.Is(err, service.ErrEmailTaken):
 			WriteError(w, http.StatusConflict, authResponse.Message)
-		case authResponse.Code == 1:
+		case authResponse.Code == 1, errors.Is(err, service.ErrDisposableEmail):
 			WriteError(w, http.StatusBadRequest, authResponse.Message)
 		default:
 			WriteError(w, http.StatusInternalServerError, "Registration failed")
@@ -78,7 +101,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		"ip":       ipAddress,
 	}).Info("User registered successfully")
 
-	WriteJSON(w, http.StatusCreated, authResponse)
+	h.writeAuthResponse(w, http.StatusCreated, authResponse)
 }
 
 // Login handles user login
@@ -88,7 +111,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	var req model.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		writeBodyError(w, err)
 		return
 	}
 
@@ -111,6 +134,12 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		}).Error("Login failed")
 
 		switch {
+		case errors.Is(err, service.ErrVerificationRequired):
+			WriteJSON(w, http.StatusAccepted, authResponse)
+		case errors.Is(err, service.ErrAccountBanned):
+			WriteError(w, http.StatusForbidden, "Account is banned")
+		case errors.Is(err, service.ErrSecondFactorLocked):
+			WriteError(w, http.StatusTooManyRequests, authResponse.Message)
 		case strings.Contains(err.Error(), "credentials"):
 			WriteError(w, http.StatusUnauthorized, "Invalid credentials")
 		case strings.Contains(err.Error(), "captcha"):
@@ -126,18 +155,65 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		"ip":       ipAddress,
 	}).Info("User logged in successfully")
 
-	WriteJSON(w, http.StatusOK, authResponse)
+	h.writeAuthResponse(w, http.StatusOK, authResponse)
+}
+
+// VerifyLogin completes a login that was challenged with an emailed verification code
+func (h *UserHandler) VerifyLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var req model.LoginVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	ipAddress := h.getClientIP(r)
+	authResponse, err := h.authService.VerifyLogin(ctx, &req, ipAddress, r.UserAgent())
+	if err != nil {
+		h.logger.WithError(err).WithField("ip", ipAddress).Error("Login verification failed")
+
+		switch {
+		case errors.Is(err, service.ErrInvalidVerificationCode):
+			WriteError(w, http.StatusUnauthorized, "Invalid verification code")
+		case errors.Is(err, service.ErrVerificationExpired):
+			WriteError(w, http.StatusGone, "Verification code expired, please log in again")
+		case errors.Is(err, service.ErrSecondFactorLocked):
+			WriteError(w, http.StatusTooManyRequests, authResponse.Message)
+		case errors.Is(err, service.ErrAccountBanned):
+			WriteError(w, http.StatusForbidden, "Account is banned")
+		default:
+			WriteError(w, http.StatusInternalServerError, "Login verification failed")
+		}
+		return
+	}
+
+	h.writeAuthResponse(w, http.StatusOK, authResponse)
 }
 
 func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
+	// With cookies the refresh token comes from its cookie and the body may be empty
 	var req model.RefreshTokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, http.StatusBadRequest, "Invalid request body")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !(h.cookies.enabled && errors.Is(err, io.EOF)) {
+		writeBodyError(w, err)
 		return
 	}
+	if h.cookies.enabled && req.RefreshToken == "" {
+		if !validCSRF(r) {
+			WriteError(w, http.StatusForbidden, "Invalid CSRF token")
+			return
+		}
+		req.RefreshToken = cookieValue(r, refreshTokenCookie)
+	}
 
 	if err := h.validator.Struct(&req); err != nil {
 		WriteError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
@@ -151,7 +227,17 @@ func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, authResponse)
+	h.writeAuthResponse(w, http.StatusOK, authResponse)
+}
+
+// writeAuthResponse writes issued tokens, as cookies when the cookie transport is enabled
+func (h *UserHandler) writeAuthResponse(w http.ResponseWriter, status int, response *model.AuthResponse) {
+	if err := h.cookies.deliver(w, response); err != nil {
+		h.logger.WithError(err).Error("Failed to issue token cookies")
+		WriteError(w, http.StatusInternalServerError, "Failed to issue tokens")
+		return
+	}
+	WriteJSON(w, status, response)
 }
 
 func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -164,7 +250,7 @@ func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := h.extractTokenFromHeader(r)
+	token, _ := h.requestToken(r)
 	if token == "" {
 		WriteError(w, http.StatusBadRequest, "Token required")
 		return
@@ -176,6 +262,8 @@ func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.cookies.clear(w)
+
 	WriteJSON(w, http.StatusOK, map[string]string{
 		"message": "Logged out successfully",
 	})
@@ -193,7 +281,7 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 
 	var req model.PasswordChangeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		writeBodyError(w, err)
 		return
 	}
 
@@ -216,11 +304,116 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.audit(ctx, r, user, model.AuditActionPasswordChange)
+
 	WriteJSON(w, http.StatusOK, map[string]string{
 		"message": "Password changed successfully",
 	})
 }
 
+// SetupTwoFactor returns a new TOTP secret and otpauth URI to add to an authenticator app
+func (h *UserHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	setup, err := h.authService.SetupTwoFactor(ctx, user.ID.Hex())
+	if err != nil {
+		h.writeTwoFactorError(w, user, err, "Two-factor setup failed")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, setup)
+}
+
+// EnableTwoFactor confirms the authenticator code and returns the recovery codes
+func (h *UserHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.handleTwoFactorCode(w, r, func(ctx context.Context, user *model.User, code string) (interface{}, error) {
+		codes, err := h.authService.EnableTwoFactor(ctx, user.ID.Hex(), code)
+		if err != nil {
+			return nil, err
+		}
+		h.audit(ctx, r, user, model.AuditActionTwoFactorEnable)
+		return model.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+	})
+}
+
+// DisableTwoFactor turns off 2FA with an authenticator or recovery code
+func (h *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.handleTwoFactorCode(w, r, func(ctx context.Context, user *model.User, code string) (interface{}, error) {
+		if err := h.authService.DisableTwoFactor(ctx, user.ID.Hex(), code); err != nil {
+			return nil, err
+		}
+		h.audit(ctx, r, user, model.AuditActionTwoFactorDisable)
+		return map[string]string{"message": "Two-factor authentication disabled"}, nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, confirmed with an authenticator code
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.handleTwoFactorCode(w, r, func(ctx context.Context, user *model.User, code string) (interface{}, error) {
+		codes, err := h.authService.RegenerateRecoveryCodes(ctx, user.ID.Hex(), code)
+		if err != nil {
+			return nil, err
+		}
+		return model.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+	})
+}
+
+// handleTwoFactorCode decodes a TwoFactorCodeRequest for the authenticated user and runs action
+func (h *UserHandler) handleTwoFactorCode(w http.ResponseWriter, r *http.Request,
+	action func(ctx context.Context, user *model.User, code string) (interface{}, error)) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req model.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	result, err := action(ctx, user, req.Code)
+	if err != nil {
+		h.writeTwoFactorError(w, user, err, "Two-factor update failed")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *UserHandler) writeTwoFactorError(w http.ResponseWriter, user *model.User, err error, message string) {
+	h.logger.WithError(err).WithField("user_id", user.ID.Hex()).Error(message)
+
+	switch {
+	case errors.Is(err, service.ErrInvalidVerificationCode):
+		WriteError(w, http.StatusBadRequest, "Invalid code")
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		WriteError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		WriteError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+	case errors.Is(err, service.ErrTwoFactorNotSetUp):
+		WriteError(w, http.StatusBadRequest, "Call /api/auth/2fa/setup first")
+	default:
+		WriteError(w, http.StatusInternalServerError, message)
+	}
+}
+
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value("user").(*model.User)
 	if !ok {
@@ -228,6 +421,12 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The profile URL is shared by every user, so caches must key on the token too
+	w.Header().Set("Vary", "Authorization")
+	if checkNotModified(w, r, userETag(user)) {
+		return
+	}
+
 	WriteJSON(w, http.StatusOK, user.ToPrivateUser())
 }
 
@@ -242,7 +441,7 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 
 	var req model.ProfileUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		writeBodyError(w, err)
 		return
 	}
 
@@ -261,6 +460,37 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	if req.Bio != "" {
 		user.Bio = req.Bio
 	}
+	if req.Languages != nil {
+		user.Languages = model.NormalizeLanguages(req.Languages)
+	}
+	if req.TranslateOptIn != nil {
+		user.TranslateOptIn = *req.TranslateOptIn
+	}
+	if req.ContentFilter != "" {
+		user.ContentFilter = req.ContentFilter
+	}
+	if req.HideProfileViews != nil {
+		user.HideProfileViews = *req.HideProfileViews
+	}
+	if req.LastSeenVisibility != "" {
+		user.LastSeenVisibility = req.LastSeenVisibility
+	}
+	if req.TimeZone != nil {
+		if *req.TimeZone != "" {
+			if _, err := model.LoadLocation(*req.TimeZone); err != nil {
+				WriteError(w, http.StatusBadRequest, "Unknown time zone")
+				return
+			}
+		}
+		user.TimeZone = *req.TimeZone
+	}
+	if req.ActiveHours != nil {
+		if req.ActiveHours.Start == req.ActiveHours.End {
+			user.ActiveHours = nil
+		} else {
+			user.ActiveHours = req.ActiveHours
+		}
+	}
 	user.UpdatedAt = time.Now()
 
 	if err := h.userService.UpdateUser(ctx, user); err != nil {
@@ -278,18 +508,13 @@ func (h *UserHandler) GenerateCaptcha(w http.ResponseWriter, r *http.Request) {
 
 	ipAddress := h.getClientIP(r)
 
-	challengeID, challenge, err := h.authService.GenerateCaptcha(ctx, ipAddress)
+	info, err := h.authService.GenerateCaptcha(ctx, ipAddress)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "Captcha generation failed")
 		return
 	}
 
-	response := map[string]string{
-		"challenge_id": challengeID,
-		"challenge":    challenge,
-	}
-
-	WriteJSON(w, http.StatusOK, response)
+	WriteJSON(w, http.StatusOK, info)
 }
 
 func (h *UserHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
@@ -306,23 +531,123 @@ func (h *UserHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.audit(ctx, r, user, model.AuditActionRevokeSessions)
+	h.cookies.clear(w)
+
 	WriteJSON(w, http.StatusOK, map[string]string{
 		"message": "All sessions revoked successfully",
 	})
 }
 
+// LogoutOthers signs the user out of every other device, keeping the session of the request
+func (h *UserHandler) LogoutOthers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	token, _ := h.requestToken(r)
+	if err := h.authService.LogoutOthers(ctx, user.ID.Hex(), token); err != nil {
+		if errors.Is(err, service.ErrSessionExpired) {
+			WriteError(w, http.StatusUnauthorized, "Session expired")
+			return
+		}
+		h.logger.WithError(err).WithField("user_id", user.ID.Hex()).Error("Failed to log out other devices")
+		WriteError(w, http.StatusInternalServerError, "Failed to log out other devices")
+		return
+	}
+
+	h.audit(ctx, r, user, model.AuditActionLogoutOthers)
+
+	WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "Logged out of all other devices",
+	})
+}
+
+// GetActivity returns the caller's recent logins, account changes and chats started per day
+func (h *UserHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	days := 30
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > service.MaxActivityDays {
+			WriteError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", service.MaxActivityDays))
+			return
+		}
+		days = parsed
+	}
+
+	timeline, err := h.activityService.Timeline(ctx, user, days)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", user.ID.Hex()).Error("Failed to build activity timeline")
+		WriteError(w, http.StatusInternalServerError, "Failed to get activity")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, timeline)
+}
+
+// GetChatStats returns the user's chat statistics
+func (h *UserHandler) GetChatStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	stats, err := h.chatStatsService.GetUserStats(ctx, user.Username)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", user.ID.Hex()).Error("Failed to get chat stats")
+		WriteError(w, http.StatusInternalServerError, "Failed to get chat stats")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, stats)
+}
+
+// audit records an account event performed by the user
+func (h *UserHandler) audit(ctx context.Context, r *http.Request, user *model.User, action string) {
+	h.auditService.Record(ctx, model.NewAuditLog(user, action, user.ID.Hex(), h.getClientIP(r)))
+}
+
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	users, err := h.userService.GetAllUsers(ctx)
+	page, err := parsePageRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := h.userService.GetAllUsers(ctx, page)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "Failed to get users")
 		return
 	}
 
-	publicUsers := make([]map[string]interface{}, len(users))
-	for i, user := range users {
+	writeNextCursor(w, users)
+	if checkNotModified(w, r, userETag(users.Items...)) {
+		return
+	}
+
+	publicUsers := make([]map[string]interface{}, len(users.Items))
+	for i, user := range users.Items {
 		publicUsers[i] = user.ToPublicUser()
 	}
 
@@ -333,20 +658,47 @@ func (h *UserHandler) GetOnlineUsers(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	users, err := h.userService.GetOnlineUsers(ctx)
+	page, err := parsePageRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := h.userService.GetOnlineUsers(ctx, page)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "Failed to get online users")
 		return
 	}
 
-	publicUsers := make([]map[string]interface{}, len(users))
-	for i, user := range users {
+	writeNextCursor(w, users)
+	publicUsers := make([]map[string]interface{}, len(users.Items))
+	for i, user := range users.Items {
 		publicUsers[i] = user.ToPublicUser()
 	}
 
 	WriteJSON(w, http.StatusOK, publicUsers)
 }
 
+// GetAvailability returns how many people are online around the active hours in the
+// caller's profile
+func (h *UserHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	availability, err := h.userService.Availability(ctx, user)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get availability")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, availability)
+}
+
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
@@ -369,35 +721,115 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, user.ToPublicUser())
+	// The route authenticates optionally, to tell owners and viewers apart
+	viewer, _ := r.Context().Value("user").(*model.User)
+	if viewer == nil || viewer.ID != user.ID {
+		h.profileViews.Record(user, viewer, h.getClientIP(r))
+		if checkNotModified(w, r, userETag(user)) {
+			return
+		}
+		WriteJSON(w, http.StatusOK, user.ToPublicUser())
+		return
+	}
+
+	// Owners also get their view counts, which change without the profile
+	profile := user.ToPublicUser()
+	if h.profileViews.Enabled() && !user.HideProfileViews {
+		stats, err := h.profileViews.Stats(ctx, user.Username)
+		if err != nil {
+			h.logger.WithError(err).WithField("user", user.Username).Warn("Failed to count profile views")
+		} else {
+			profile["profile_views"] = stats
+		}
+	}
+	WriteJSON(w, http.StatusOK, profile)
+}
+
+// GetUserBadges returns the badges awarded to a user
+func (h *UserHandler) GetUserBadges(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	username := mux.Vars(r)["username"]
+	badges, err := h.badgeService.GetUserBadges(ctx, username)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		h.logger.WithError(err).WithField("username", username).Error("Failed to get badges")
+		WriteError(w, http.StatusInternalServerError, "Failed to get badges")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"username": username,
+		"badges":   badges,
+	})
 }
 
 func (h *UserHandler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := h.extractTokenFromHeader(r)
+		token, fromCookie := h.requestToken(r)
 		if token == "" {
 			WriteError(w, http.StatusUnauthorized, "Authorization token required")
 			return
 		}
 
+		if fromCookie && !validCSRF(r) {
+			WriteError(w, http.StatusForbidden, "Invalid CSRF token")
+			return
+		}
+
 		user, err := h.authService.GetUserFromToken(token)
 		if err != nil {
 			WriteError(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
+		if err := h.authService.TouchSession(r.Context(), token); err != nil {
+			if errors.Is(err, service.ErrSessionExpired) {
+				WriteError(w, http.StatusUnauthorized, "Session expired")
+				return
+			}
+			h.logger.WithError(err).WithField("user_id", user.ID.Hex()).Warn("Failed to record session activity")
+		}
+
+		noteAccessUser(r, user)
 		ctx := context.WithValue(r.Context(), "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequireRole rejects authenticated users that have none of the given roles.
+// Must be used after AuthMiddleware.
+func (h *UserHandler) RequireRole(roles ...model.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value("user").(*model.User)
+			if !ok || user == nil {
+				WriteError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+
+			if !user.HasRole(roles...) {
+				WriteError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (h *UserHandler) OptionalAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := h.extractTokenFromHeader(r)
-		if token != "" {
+		token, fromCookie := h.requestToken(r)
+		if token != "" && (!fromCookie || validCSRF(r)) {
 			user, err := h.authService.GetUserFromToken(token)
-			if err == nil {
+			if err == nil && !errors.Is(h.authService.TouchSession(r.Context(), token), service.ErrSessionExpired) {
 				// Add user to context if token is valid
+				noteAccessUser(r, user)
 				ctx := context.WithValue(r.Context(), "user", user)
 				r = r.WithContext(ctx)
 			}
@@ -407,6 +839,20 @@ func (h *UserHandler) OptionalAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// requestToken returns the access token of the Authorization header or, with the cookie
+// transport, of the access token cookie; fromCookie requests need a CSRF check
+func (h *UserHandler) requestToken(r *http.Request) (token string, fromCookie bool) {
+	if token := h.extractTokenFromHeader(r); token != "" {
+		return token, false
+	}
+	if h.cookies.enabled {
+		if token := cookieValue(r, accessTokenCookie); token != "" {
+			return token, true
+		}
+	}
+	return "", false
+}
+
 func (h *UserHandler) extractTokenFromHeader(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -422,20 +868,5 @@ func (h *UserHandler) extractTokenFromHeader(r *http.Request) string {
 }
 
 func (h *UserHandler) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(forwarded, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
+	return clientIP(r)
 }
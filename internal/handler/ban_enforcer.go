@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// deviceFingerprintHeader carries the device fingerprint of a client. WebSocket and
+// EventSource clients, which cannot set headers, send the device query parameter instead.
+const deviceFingerprintHeader = "X-Device-Fingerprint"
+
+// BanEnforcer refuses requests from banned IP ranges and devices on the routes it guards:
+// registration, login and the chat connections. See service.NetworkBanService.
+type BanEnforcer struct {
+	bans   service.NetworkBanService
+	logger *logrus.Logger
+}
+
+func NewBanEnforcer(bans service.NetworkBanService, logger *logrus.Logger) *BanEnforcer {
+	return &BanEnforcer{bans: bans, logger: logger}
+}
+
+// Middleware answers 403 to requests from a banned address or device. Temporary bans
+// come with their expiry and a Retry-After header.
+func (e *BanEnforcer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, device := clientIP(r), deviceFingerprint(r)
+		ban := e.bans.Check(ip, device)
+		if ban == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		e.logger.WithFields(logrus.Fields{
+			"ban_id": ban.ID.Hex(),
+			"type":   ban.Type,
+			"ip":     ip,
+			"path":   r.URL.Path,
+		}).Info("Request from banned network or device refused")
+		writeNetworkBan(w, ban)
+	})
+}
+
+// deviceFingerprint returns the device fingerprint of the request, or ""
+func deviceFingerprint(r *http.Request) string {
+	if device := strings.TrimSpace(r.Header.Get(deviceFingerprintHeader)); device != "" {
+		return device
+	}
+	return strings.TrimSpace(r.URL.Query().Get("device"))
+}
+
+func writeNetworkBan(w http.ResponseWriter, ban *model.NetworkBan) {
+	message := "Access from this network or device is blocked"
+	if ban.ExpiresAt != nil {
+		if wait := time.Until(*ban.ExpiresAt); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		message += " until " + ban.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	WriteError(w, http.StatusForbidden, message)
+}
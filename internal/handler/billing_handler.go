@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// BillingHandler receives subscription updates from the billing provider, see
+// config.BillingConfig
+type BillingHandler struct {
+	subscriptionService service.SubscriptionService
+	config              config.BillingConfig
+	validator           *validator.Validate
+	logger              *logrus.Logger
+}
+
+func NewBillingHandler(subscriptionService service.SubscriptionService, config config.BillingConfig, logger *logrus.Logger) *BillingHandler {
+	return &BillingHandler{
+		subscriptionService: subscriptionService,
+		config:              config,
+		validator:           validator.New(),
+		logger:              logger,
+	}
+}
+
+// HandleWebhook applies a signed model.BillingEvent. Stale and unknown-user events are
+// acknowledged with 200 so the provider stops retrying them; only failures to apply an
+// event ask for a retry.
+func (h *BillingHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !h.validSignature(r.Header.Get("X-Billing-Signature"), body, time.Now()) {
+		WriteError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	var event model.BillingEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validator.Struct(&event); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger := h.logger.WithFields(logrus.Fields{"event": event.ID, "user": event.Username})
+	_, err = h.subscriptionService.HandleBillingEvent(ctx, h.config.Provider, &event)
+	switch {
+	case errors.Is(err, service.ErrStaleBillingEvent):
+		logger.Info("Ignored stale billing event")
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"applied": false, "reason": "stale"})
+	case errors.Is(err, service.ErrUserNotFound):
+		logger.Warn("Billing event for unknown user")
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"applied": false, "reason": "unknown_user"})
+	case err != nil:
+		logger.WithError(err).Error("Failed to apply billing event")
+		WriteError(w, http.StatusInternalServerError, "Failed to apply event")
+	default:
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"applied": true})
+	}
+}
+
+// validSignature checks a "t=<unix>,v1=<hex>" header against the body. Several v1
+// values are accepted while the provider rotates secrets.
+func (h *BillingHandler) validSignature(header string, body []byte, now time.Time) bool {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > h.config.Tolerance || age < -h.config.Tolerance {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.config.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/pkg/chatbot"
+)
+
+// botReplyDelay makes bot answers feel less instant
+const botReplyDelay = 1500 * time.Millisecond
+
+// botClient is a bot participant connected to a room like any other member: it reads
+// the room broadcast through Send and answers through the normal message path.
+type botClient struct {
+	connGeneration
+	clientFeatures
+	handler  *ChatHandler
+	bot      chatbot.Bot
+	roomCode string
+	username string
+
+	greetOnce sync.Once
+	replying  atomic.Bool // one pending reply at a time, so bursts get a single answer
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newBotClient(h *ChatHandler, bot chatbot.Bot, roomCode, username string) *botClient {
+	return &botClient{
+		handler:  h,
+		bot:      bot,
+		roomCode: roomCode,
+		username: username,
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *botClient) Send(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	var message ChatMessage
+	if err := json.Unmarshal(data, &message); err != nil || message.Type != "message" || message.Bot {
+		return true
+	}
+
+	if c.replying.CompareAndSwap(false, true) {
+		go c.reply(message.Text)
+	}
+	return true
+}
+
+func (c *botClient) SendDroppable(data []byte) bool {
+	return c.Send(data)
+}
+
+func (c *botClient) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+func (c *botClient) CloseWith(int, string) {
+	c.Close()
+}
+
+// greet sends the bot's greeting the first time a user is present
+func (c *botClient) greet() {
+	c.greetOnce.Do(func() {
+		c.say(c.bot.Greeting(c.language()))
+	})
+}
+
+func (c *botClient) reply(text string) {
+	defer c.replying.Store(false)
+
+	select {
+	case <-time.After(botReplyDelay):
+	case <-c.done:
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if answer, ok := c.bot.Reply(ctx, c.language(), text); ok {
+		c.say(answer)
+	}
+}
+
+func (c *botClient) say(text string) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.handler.handleFrame(c.roomCode, c.username, ClientFrame{Type: "message", Text: text})
+}
+
+func (c *botClient) language() string {
+	if room, exists := c.handler.chatService.GetRoom(c.roomCode); exists {
+		return room.Language
+	}
+	return ""
+}
+
+// AttachBot connects the bot that took the second slot of a room, see event.BotJoined
+func (h *ChatHandler) AttachBot(roomCode, username string) {
+	if h.bot == nil {
+		return
+	}
+	if room, exists := h.chatService.GetRoom(roomCode); !exists || room.Bot != username {
+		return
+	}
+
+	client := newBotClient(h, h.bot, roomCode, username)
+	if err := h.addConnection(roomCode, username, client, 0); err != nil {
+		return
+	}
+
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "system",
+		From:      username,
+		Text:      username + " (bot) đã vào phòng chat",
+		Bot:       true,
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	// The user may already be connected; otherwise the bot greets on their join
+	h.connLock.RLock()
+	present := len(h.connections[roomCode]) > 1 || h.buffers[roomCode] != nil
+	h.connLock.RUnlock()
+	if present {
+		h.sendPartnerInfo(roomCode)
+		client.greet()
+	}
+}
+
+// greetFromBot lets the room's bot greet a user who just joined
+func (h *ChatHandler) greetFromBot(roomCode string) {
+	h.connLock.RLock()
+	var bot *botClient
+	for username, client := range h.connections[roomCode] {
+		if model.IsBotUsername(username) {
+			bot, _ = client.(*botClient)
+		}
+	}
+	h.connLock.RUnlock()
+
+	if bot != nil {
+		bot.greet()
+	}
+}
+
+// DetachBot disconnects the bots of a closed room
+func (h *ChatHandler) DetachBot(roomCode string) {
+	h.connLock.Lock()
+	var bots []roomClient
+	for username, client := range h.connections[roomCode] {
+		if model.IsBotUsername(username) {
+			bots = append(bots, client)
+			delete(h.connections[roomCode], username)
+		}
+	}
+	if len(h.connections[roomCode]) == 0 {
+		delete(h.connections, roomCode)
+	}
+	h.connLock.Unlock()
+
+	for _, client := range bots {
+		client.Close()
+	}
+}
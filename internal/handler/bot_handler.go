@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BotHandler serves the API used by bots and integrations through API keys
+type BotHandler struct {
+	userService    service.UserService
+	messageService service.MessageService
+	botRoom        string
+	logger         *logrus.Logger
+}
+
+func NewBotHandler(
+	userService service.UserService,
+	messageService service.MessageService,
+	botRoom string,
+	logger *logrus.Logger,
+) *BotHandler {
+	return &BotHandler{
+		userService:    userService,
+		messageService: messageService,
+		botRoom:        botRoom,
+		logger:         logger,
+	}
+}
+
+type botMessageRequest struct {
+	Text string `json:"text"`
+}
+
+func (h *BotHandler) GetOnlineUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := h.userService.GetOnlineUsers(ctx, page)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get online users")
+		return
+	}
+
+	writeNextCursor(w, users)
+	publicUsers := make([]map[string]interface{}, len(users.Items))
+	for i, user := range users.Items {
+		publicUsers[i] = user.ToPublicUser()
+	}
+
+	WriteJSON(w, http.StatusOK, publicUsers)
+}
+
+// GetMessages returns the recent messages of the bot room
+func (h *BotHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	messages, err := h.messageService.GetRoomHistory(ctx, h.botRoom)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get messages")
+		return
+	}
+	if messages == nil {
+		messages = []*model.Message{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"room":     h.botRoom,
+		"messages": messages,
+	})
+}
+
+// PostMessage posts a message to the bot room as the key owner
+func (h *BotHandler) PostMessage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req botMessageRequest
+	r.Body = http.MaxBytesReader(w, r.Body, h.messageService.MaxFrameSize())
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	message, err := h.messageService.SaveMessage(ctx, h.botRoom, user.Username, req.Text, model.ContentPolicy{Filter: model.ContentFilterMedium})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMessageEmpty), errors.Is(err, service.ErrMessageTooLong),
+			errors.Is(err, service.ErrMessageSpam):
+			WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.WithError(err).WithField("user", user.Username).Error("Failed to post bot message")
+			WriteError(w, http.StatusInternalServerError, "Failed to post message")
+		}
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, message)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Client features a hello frame may declare. The server negotiates the ones it supports;
+// reactions, typing and e2e are not implemented yet, so they are never negotiated.
+const (
+	featureReactions   = "reactions"
+	featureTyping      = "typing"
+	featureE2E         = "e2e"
+	featureMsgpack     = "msgpack"     // binary frames, chosen with ?proto=msgpack at connect
+	featureCompression = "compression" // permessage-deflate, with websocket.compression
+	featureGames       = "games"       // game_invite and game_state frames
+	featureEdits       = "edits"       // edit and delete frames
+)
+
+// frameFeatures maps the frame types that are only sent to clients with a feature
+var frameFeatures = map[string]string{
+	"game_invite": featureGames,
+	"game_state":  featureGames,
+	"edit":        featureEdits,
+	"delete":      featureEdits,
+}
+
+// clientFeatures holds the features a connection negotiated with a hello frame. A
+// connection that sent no hello is taken as a client from before the exchange and gets
+// every frame.
+type clientFeatures struct {
+	negotiated atomic.Pointer[[]string]
+}
+
+// Accepts reports whether the client can parse frames of the type
+func (f *clientFeatures) Accepts(frameType string) bool {
+	feature, gated := frameFeatures[frameType]
+	return !gated || f.has(feature)
+}
+
+func (f *clientFeatures) has(feature string) bool {
+	negotiated := f.negotiated.Load()
+	return negotiated == nil || slices.Contains(*negotiated, feature)
+}
+
+func (f *clientFeatures) negotiate(features []string) {
+	f.negotiated.Store(&features)
+}
+
+// serverFeatures returns the features the server offers to the connection
+func (h *ChatHandler) serverFeatures(client *wsClient) []string {
+	features := []string{featureGames, featureEdits}
+	if client.codec.msgpack {
+		features = append(features, featureMsgpack)
+	}
+	if h.wsConfig.Compression {
+		features = append(features, featureCompression)
+	}
+	return features
+}
+
+// handleHelloFrame negotiates the features of a connection: the ones the client declared
+// that the server supports. The hello_ack frame lists them with the protocol version,
+// and its text warns clients of a deprecated version. A client may send hello again to
+// negotiate anew.
+func (h *ChatHandler) handleHelloFrame(client *wsClient, frame ClientFrame) {
+	supported := h.serverFeatures(client)
+	negotiated := make([]string, 0, len(frame.Features))
+	for _, feature := range frame.Features {
+		if slices.Contains(supported, feature) && !slices.Contains(negotiated, feature) {
+			negotiated = append(negotiated, feature)
+		}
+	}
+	client.negotiate(negotiated)
+
+	data, err := json.Marshal(ChatMessage{
+		Type:      "hello_ack",
+		Text:      client.warning,
+		Features:  negotiated,
+		Version:   ProtocolVersion,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to marshal frame")
+		return
+	}
+	if !client.Send(data) {
+		client.Close()
+	}
+}
+
+// withoutUnsupported drops the frames the client cannot parse
+func withoutUnsupported(frames []roomFrame, client roomClient) []roomFrame {
+	if client == nil {
+		return frames
+	}
+	return slices.DeleteFunc(frames, func(frame roomFrame) bool { return !client.Accepts(frame.Type) })
+}
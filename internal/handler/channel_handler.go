@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// ChannelHandler serves the channel directory and channel membership. Channel messages
+// flow through the chat hub, see ChatHandler.HandleChannelSocket.
+type ChannelHandler struct {
+	channelService service.ChannelService
+	messageService service.MessageService
+	logger         *logrus.Logger
+}
+
+func NewChannelHandler(
+	channelService service.ChannelService,
+	messageService service.MessageService,
+	logger *logrus.Logger,
+) *ChannelHandler {
+	return &ChannelHandler{
+		channelService: channelService,
+		messageService: messageService,
+		logger:         logger,
+	}
+}
+
+// ListChannels returns the channel directory with member counts
+func (h *ChannelHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	channels, err := h.channelService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list channels")
+		WriteError(w, http.StatusInternalServerError, "Failed to list channels")
+		return
+	}
+	if channels == nil {
+		channels = []*model.Channel{}
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"channels": channels,
+		"total":    len(channels),
+	})
+}
+
+func (h *ChannelHandler) JoinChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	channel, err := h.channelService.Join(ctx, mux.Vars(r)["slug"], user.Username)
+	if err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, channel)
+}
+
+func (h *ChannelHandler) LeaveChannel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	if err := h.channelService.Leave(ctx, mux.Vars(r)["slug"], user.Username); err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetChannelMessages returns the latest messages of a channel to its members
+func (h *ChannelHandler) GetChannelMessages(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	channel, err := h.channelService.Get(ctx, mux.Vars(r)["slug"])
+	if err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+
+	member, err := h.channelService.IsMember(ctx, channel.Slug, user.Username)
+	if err != nil {
+		h.writeChannelError(w, err)
+		return
+	}
+	if !member {
+		h.writeChannelError(w, service.ErrNotChannelMember)
+		return
+	}
+
+	messages, err := h.messageService.GetRoomHistory(ctx, channel.RoomCode())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get channel messages")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"channel":  channel.Slug,
+		"messages": visibleMessages(messages, user.Username),
+	})
+}
+
+func (h *ChannelHandler) writeChannelError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrChannelNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrNotChannelMember):
+		WriteError(w, http.StatusForbidden, err.Error())
+	default:
+		h.logger.WithError(err).Error("Channel request failed")
+		WriteError(w, http.StatusInternalServerError, "Channel request failed")
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleChannelSocket connects a channel member to the channel over WebSocket. Frames
+// are handled like room frames and fanned out to every connected member; membership
+// itself is managed over REST, so connecting and disconnecting is not announced.
+func (h *ChatHandler) HandleChannelSocket(w http.ResponseWriter, r *http.Request) {
+	codec, err := socketCodec(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, conn, ok := h.authenticateSocket(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	channel, err := h.channelService.Get(ctx, mux.Vars(r)["slug"])
+	var member bool
+	if err == nil {
+		member, err = h.channelService.IsMember(ctx, channel.Slug, user.Username)
+	}
+	cancel()
+
+	switch {
+	case errors.Is(err, service.ErrChannelNotFound):
+		refuseSocket(w, conn, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to check channel membership")
+		refuseSocket(w, conn, http.StatusInternalServerError, "Failed to join channel")
+		return
+	case !member:
+		refuseSocket(w, conn, http.StatusForbidden, service.ErrNotChannelMember.Error())
+		return
+	}
+
+	conn, err = h.upgradeSocket(w, r, conn)
+	if err != nil {
+		h.logger.WithError(err).WithField("channel", channel.Slug).Warn("WebSocket upgrade failed")
+		return
+	}
+
+	roomCode := channel.RoomCode()
+	client := newWSClient(conn, h.sendPolicy, codec, protocolWarning(r))
+	if err := h.addConnection(roomCode, user.Username, client, previousGeneration(r)); err != nil {
+		refuseConnection(w, conn, err)
+		client.Close()
+		return
+	}
+	h.trackConnection(user, roomCode, client)
+	defer func() {
+		client.Close()
+		h.untrackConnection(client)
+		h.removeChannelConnection(roomCode, user.Username, client)
+	}()
+
+	conn.SetReadLimit(h.messageService.MaxFrameSize())
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	limiter := newFrameLimiter(h.wsConfig.FrameRate, h.wsConfig.FrameBurst)
+	for {
+		messageBytes, err := client.readFrame()
+		if errors.Is(err, errFrameEncoding) {
+			h.sendError(roomCode, user.Username, err)
+			continue
+		}
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				h.logger.WithError(err).WithFields(logrus.Fields{"channel": channel.Slug, "user": user.Username}).Warn("WebSocket closed unexpectedly")
+			}
+			return
+		}
+
+		if !h.allowFrame(roomCode, user.Username, client, limiter) {
+			continue
+		}
+		if frame := parseClientFrame(messageBytes); frame.Type == "hello" {
+			h.handleHelloFrame(client, frame)
+		} else {
+			h.handleFrame(roomCode, user.Username, frame)
+		}
+	}
+}
+
+// removeChannelConnection drops the client and its mutes unless it was replaced by a
+// newer connection
+func (h *ChatHandler) removeChannelConnection(roomCode, username string, client roomClient) {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	roomConns := h.connections[roomCode]
+	if roomConns == nil || roomConns[username] != client {
+		return
+	}
+
+	delete(roomConns, username)
+	if len(roomConns) == 0 {
+		delete(h.connections, roomCode)
+	}
+	h.clearMutes(roomCode, username)
+}
+
+// AnnounceChannelJoin tells the connected members that a user joined the channel
+func (h *ChatHandler) AnnounceChannelJoin(slug, username string) {
+	h.broadcastToRoom(model.ChannelRoomCode(slug), ChatMessage{
+		Type:      "system",
+		Text:      username + " đã tham gia kênh #" + slug,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// DetachChannelMember closes the channel connection of a user who left the channel
+func (h *ChatHandler) DetachChannelMember(slug, username string) {
+	roomCode := model.ChannelRoomCode(slug)
+
+	h.connLock.RLock()
+	client := h.connections[roomCode][username]
+	h.connLock.RUnlock()
+	if client != nil {
+		client.Close()
+	}
+
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "system",
+		Text:      username + " đã rời kênh #" + slug,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// CloseChannel disconnects every member of a deleted channel
+func (h *ChatHandler) CloseChannel(slug string) {
+	roomCode := model.ChannelRoomCode(slug)
+
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "system",
+		Text:      "Kênh #" + slug + " đã bị xoá",
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	h.connLock.RLock()
+	clients := make([]roomClient, 0, len(h.connections[roomCode]))
+	for _, client := range h.connections[roomCode] {
+		clients = append(clients, client)
+	}
+	h.connLock.RUnlock()
+
+	for _, client := range clients {
+		client.CloseWith(CloseKicked, "channel deleted")
+	}
+}
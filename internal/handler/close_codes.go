@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close codes of room and channel sockets. The same code is set on "error" frames, which
+// is also how event streams learn why they end. Refusals without a code of their own
+// close with 4000 + the HTTP status of the refusal (4403, 4404, 4409, ...).
+const (
+	CloseAuthFailed     = 4001 // missing, invalid or expired token
+	CloseRoomFull       = 4002 // the room has no free seat
+	CloseKicked         = 4003 // removed by a moderator, or the channel was deleted
+	CloseRateLimited    = 4004 // too many frames, see websocket.frame_rate
+	CloseServerShutdown = 4005 // the server is restarting; reconnect after a moment
+	CloseConflict       = 4000 + http.StatusConflict
+)
+
+var errServerShutdown = errors.New("server is shutting down")
+
+// socketCloseCode returns the close code of a refusal with the HTTP status
+func socketCloseCode(status int) int {
+	switch status {
+	case http.StatusUnauthorized:
+		return CloseAuthFailed
+	case http.StatusTooManyRequests:
+		return CloseRateLimited
+	case http.StatusServiceUnavailable:
+		return CloseServerShutdown
+	default:
+		return 4000 + status
+	}
+}
+
+// closeSocket sends a close frame and closes the connection
+func closeSocket(conn *websocket.Conn, code int, reason string) {
+	closeMessage := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// refuseConnection refuses a socket or stream that addConnection did not accept
+func refuseConnection(w http.ResponseWriter, conn *websocket.Conn, err error) {
+	status := http.StatusConflict
+	if errors.Is(err, errServerShutdown) {
+		status = http.StatusServiceUnavailable
+	}
+	refuseSocket(w, conn, status, err.Error())
+}
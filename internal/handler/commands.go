@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+)
+
+// CommandPrefix starts a slash command. A message starting with two slashes is sent
+// as a normal message with one slash removed.
+const CommandPrefix = "/"
+
+// Command is a slash command typed in a chat message, e.g. "/roll 20". Commands are
+// never stored or broadcast as messages; they answer through system frames.
+type Command interface {
+	Name() string
+	// Usage is shown in the help text, e.g. "/roll [sides] - roll a die"
+	Usage() string
+	Execute(ctx context.Context, call *CommandCall) error
+}
+
+// CommandCall is one invocation of a command in a room or channel
+type CommandCall struct {
+	RoomCode string
+	Username string
+	Args     string // text after the command name, trimmed
+
+	handler *ChatHandler
+}
+
+// Reply sends a system frame to the caller only
+func (c *CommandCall) Reply(text string) {
+	c.handler.sendToUser(c.RoomCode, c.Username, ChatMessage{
+		Type:      "system",
+		Text:      text,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// Broadcast sends a system frame to everyone in the room
+func (c *CommandCall) Broadcast(text string) {
+	c.handler.broadcastToRoom(c.RoomCode, ChatMessage{
+		Type:      "system",
+		From:      c.Username,
+		Text:      text,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// Partner returns the other member of a two-person room
+func (c *CommandCall) Partner() (string, bool) {
+	room, exists := c.handler.chatService.GetRoom(c.RoomCode)
+	if !exists {
+		return "", false
+	}
+	return room.Partner(c.Username)
+}
+
+// CommandRegistry holds the slash commands available in chat. Deployments add their
+// own commands with Register before the server starts.
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]Command
+}
+
+func NewCommandRegistry(commands ...Command) *CommandRegistry {
+	registry := &CommandRegistry{commands: make(map[string]Command)}
+	for _, command := range commands {
+		registry.Register(command)
+	}
+	return registry
+}
+
+// Register adds a command, replacing a command with the same name
+func (r *CommandRegistry) Register(command Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[strings.ToLower(command.Name())] = command
+}
+
+func (r *CommandRegistry) Lookup(name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	command, ok := r.commands[strings.ToLower(name)]
+	return command, ok
+}
+
+// Help lists the usage of every command, sorted by name
+func (r *CommandRegistry) Help() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usages := make([]string, 0, len(r.commands))
+	for _, command := range r.commands {
+		usages = append(usages, command.Usage())
+	}
+	sort.Strings(usages)
+	return "Các lệnh có thể dùng:\n" + strings.Join(usages, "\n")
+}
+
+// DefaultCommands returns the built-in commands
+func DefaultCommands(auditService service.AuditService) []Command {
+	return []Command{
+		leaveCommand{},
+		reportCommand{auditService: auditService},
+		rollCommand{},
+		meCommand{},
+	}
+}
+
+// parseCommand splits "/name args" into the command name and its arguments. It reports
+// false for text that is not a command, including escaped "//" messages.
+func parseCommand(text string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, CommandPrefix) || strings.HasPrefix(text, CommandPrefix+CommandPrefix) {
+		return "", "", false
+	}
+
+	text = strings.TrimPrefix(text, CommandPrefix)
+	name, args := text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		name, args = text[:i], text[i:]
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// handleCommand runs a slash command. Unknown commands get the help text.
+func (h *ChatHandler) handleCommand(roomCode, username, name, args string) {
+	call := &CommandCall{RoomCode: roomCode, Username: username, Args: args, handler: h}
+
+	command, ok := h.commands.Lookup(name)
+	if !ok {
+		call.Reply("Lệnh /" + name + " không tồn tại. " + h.commands.Help())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := command.Execute(ctx, call); err != nil {
+		call.Reply(err.Error())
+	}
+}
+
+// leaveCommand leaves the current room, or the channel membership in a channel
+type leaveCommand struct{}
+
+func (leaveCommand) Name() string  { return "leave" }
+func (leaveCommand) Usage() string { return "/leave - rời phòng chat hoặc kênh" }
+
+func (leaveCommand) Execute(ctx context.Context, call *CommandCall) error {
+	h := call.handler
+	if model.IsChannelRoom(call.RoomCode) {
+		slug := strings.TrimPrefix(call.RoomCode, model.ChannelRoomPrefix)
+		if err := h.channelService.Leave(ctx, slug, call.Username); err != nil {
+			return fmt.Errorf("không thể rời kênh: %w", err)
+		}
+		return nil
+	}
+
+	h.connLock.RLock()
+	client := h.connections[call.RoomCode][call.Username]
+	h.connLock.RUnlock()
+
+	// Closing the connection makes its transport leave the room and announce it;
+	// long-poll members who have not polled yet have no connection to close
+	if client != nil {
+		client.Close()
+		return nil
+	}
+	h.chatService.LeaveRoom(call.RoomCode, call.Username)
+	h.dropRoomBuffer(call.RoomCode)
+	call.Broadcast(call.Username + " đã rời khỏi phòng chat")
+	return nil
+}
+
+// reportCommand reports the partner to moderators through the audit log
+type reportCommand struct {
+	auditService service.AuditService
+}
+
+func (reportCommand) Name() string  { return "report" }
+func (reportCommand) Usage() string { return "/report [lý do] - báo cáo người đang chat cùng" }
+
+func (c reportCommand) Execute(ctx context.Context, call *CommandCall) error {
+	partner, ok := call.Partner()
+	if !ok {
+		return fmt.Errorf("không có ai để báo cáo")
+	}
+
+	entry := model.NewAuditLog(nil, model.AuditActionChatReport, partner, "")
+	entry.Actor = call.Username
+	entry.Details = map[string]interface{}{
+		"room":   call.RoomCode,
+		"reason": call.Args,
+	}
+	if since, muted := call.handler.mutedSince(call.RoomCode, call.Username, partner); muted {
+		entry.Details["muted_at"] = since
+	}
+	c.auditService.Record(ctx, entry)
+
+	call.Reply("Đã gửi báo cáo về " + partner + " tới đội kiểm duyệt")
+	return nil
+}
+
+// maxRollSides bounds the die of /roll
+const maxRollSides = 1000
+
+// rollCommand rolls a die for everyone to see
+type rollCommand struct{}
+
+func (rollCommand) Name() string { return "roll" }
+func (rollCommand) Usage() string {
+	return "/roll [số mặt] - tung xúc xắc (mặc định 6 mặt)"
+}
+
+func (rollCommand) Execute(ctx context.Context, call *CommandCall) error {
+	sides := 6
+	if call.Args != "" {
+		parsed, err := strconv.Atoi(call.Args)
+		if err != nil || parsed < 2 || parsed > maxRollSides {
+			return fmt.Errorf("số mặt phải từ 2 đến %d", maxRollSides)
+		}
+		sides = parsed
+	}
+
+	call.Broadcast(fmt.Sprintf("%s tung xúc xắc %d mặt: %d", call.Username, sides, rand.IntN(sides)+1))
+	return nil
+}
+
+// meCommand sends an action line such as "* alice waves"
+type meCommand struct{}
+
+func (meCommand) Name() string  { return "me" }
+func (meCommand) Usage() string { return "/me <hành động> - gửi hành động" }
+
+func (meCommand) Execute(ctx context.Context, call *CommandCall) error {
+	if call.Args == "" {
+		return fmt.Errorf("cách dùng: /me <hành động>")
+	}
+	call.Broadcast("* " + call.Username + " " + call.Args)
+	return nil
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"chatmix-backend/internal/model"
+)
+
+// userETag returns a weak ETag for a representation of the given users. It is derived from
+// updated_at plus the presence fields (online, last seen, verified) that change without touching updated_at.
+func userETag(users ...*model.User) string {
+	hash := sha256.New()
+	var buf [8]byte
+	for _, user := range users {
+		hash.Write(user.ID[:])
+		binary.BigEndian.PutUint64(buf[:], uint64(user.UpdatedAt.UnixNano()))
+		hash.Write(buf[:])
+		binary.BigEndian.PutUint64(buf[:], uint64(user.LastSeen.UnixNano()))
+		hash.Write(buf[:])
+		var flags byte
+		if user.IsOnline {
+			flags |= 1
+		}
+		if user.IsVerified {
+			flags |= 2
+		}
+		hash.Write([]byte{flags})
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// checkNotModified sets the ETag header and, when the request's If-None-Match matches it,
+// writes 304 Not Modified and returns true
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison of RFC 9110 to an If-None-Match header value
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
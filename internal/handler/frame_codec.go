@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"chatmix-backend/pkg/msgpack"
+
+	"github.com/gorilla/websocket"
+)
+
+// Frame encodings of a WebSocket connection, chosen with the proto query parameter
+const (
+	protoJSON    = "json"
+	protoMsgpack = "msgpack"
+)
+
+var errFrameEncoding = errors.New("frame could not be decoded")
+
+// frameCodec encodes the frames of one WebSocket connection. Frames are built and
+// buffered as JSON; a msgpack connection gets each frame converted to MessagePack with
+// the same envelope, in binary messages, so one room can mix both kinds of clients.
+type frameCodec struct {
+	msgpack bool
+}
+
+// socketCodec returns the codec the request asks for with ?proto=json|msgpack, JSON by
+// default
+func socketCodec(r *http.Request) (frameCodec, error) {
+	switch proto := r.URL.Query().Get("proto"); proto {
+	case "", protoJSON:
+		return frameCodec{}, nil
+	case protoMsgpack:
+		return frameCodec{msgpack: true}, nil
+	default:
+		return frameCodec{}, fmt.Errorf("unsupported proto %q, use %s or %s", proto, protoJSON, protoMsgpack)
+	}
+}
+
+// encode returns the message type and payload of a JSON frame
+func (c frameCodec) encode(data []byte) (int, []byte, error) {
+	if !c.msgpack {
+		return websocket.TextMessage, data, nil
+	}
+	encoded, err := msgpack.FromJSON(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return websocket.BinaryMessage, encoded, nil
+}
+
+// writeFrame writes a frame directly to the connection, for the frames sent before the
+// connection has a write pump
+func (c frameCodec) writeFrame(conn *websocket.Conn, message ChatMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	messageType, payload, err := c.encode(data)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(messageType, payload)
+}
+
+// decode returns a received frame as JSON. Binary messages are MessagePack; text
+// messages are taken as they are, whatever the connection's encoding.
+func (c frameCodec) decode(messageType int, data []byte) ([]byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return data, nil
+	}
+	decoded, err := msgpack.ToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errFrameEncoding, err)
+	}
+	return decoded, nil
+}
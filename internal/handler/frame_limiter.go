@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// frameLimiter is a token bucket for the frames of one socket. It is used by the read
+// loop of the socket only, so it needs no lock.
+type frameLimiter struct {
+	rate    float64 // tokens per second
+	burst   float64
+	tokens  float64
+	last    time.Time
+	dropped int // frames dropped in a row
+}
+
+func newFrameLimiter(rate float64, burst int) *frameLimiter {
+	return &frameLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Allow reports whether a frame received now may be handled
+func (l *frameLimiter) Allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		l.dropped++
+		return false
+	}
+	l.tokens--
+	l.dropped = 0
+	return true
+}
+
+// allowFrame applies the frame limit of a socket. A dropped frame is answered with a
+// rate limited error frame; a socket that keeps sending after a burst worth of dropped
+// frames is closed with CloseRateLimited.
+func (h *ChatHandler) allowFrame(roomCode, username string, client roomClient, limiter *frameLimiter) bool {
+	if limiter.Allow(time.Now()) {
+		return true
+	}
+
+	if limiter.dropped > int(limiter.burst) {
+		h.logger.WithFields(logrus.Fields{"room": roomCode, "user": username}).Warn("Closing socket that exceeds the frame rate")
+		client.CloseWith(CloseRateLimited, "too many frames")
+		return false
+	}
+	if limiter.dropped == 1 {
+		h.sendToUser(roomCode, username, ChatMessage{
+			Type:      "error",
+			Code:      CloseRateLimited,
+			Text:      "too many messages, slow down",
+			Timestamp: time.Now().UnixMilli(),
+		})
+	}
+	return false
+}
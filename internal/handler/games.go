@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/pkg/games"
+)
+
+// gameReconnectGrace is how long a game waits for a disconnected player before the
+// room may start another one
+const gameReconnectGrace = 2 * time.Minute
+
+var errGame = errors.New("không thể chơi")
+
+// roomGame is the mini-game of a two-person room: a pending invite, then the game. It
+// outlives the connections of its players, so a player who reconnects to the room
+// within gameReconnectGrace finds the game where it was.
+type roomGame struct {
+	mu      sync.Mutex
+	invite  string // game the inviter proposed, until the partner answers
+	inviter string
+	game    games.Game
+	away    map[string]time.Time // players disconnected since
+}
+
+// blocking reports whether the game keeps the room from starting another one; the
+// caller holds mu
+func (g *roomGame) blocking(now time.Time) bool {
+	if g.game == nil || g.game.State().Over {
+		return false
+	}
+	for _, since := range g.away {
+		if now.Sub(since) > gameReconnectGrace {
+			return false
+		}
+	}
+	return true
+}
+
+// roomGame returns the game of a room, creating it when create is set
+func (h *ChatHandler) roomGame(roomCode string, create bool) *roomGame {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	game := h.games[roomCode]
+	if game == nil && create {
+		game = &roomGame{away: make(map[string]time.Time)}
+		h.games[roomCode] = game
+	}
+	return game
+}
+
+// handleGameInviteFrame invites the partner to a game, {"type":"game_invite","game":"tictactoe"}.
+// The partner accepts by inviting back to the same game, which starts it with the
+// inviter moving first, or declines with "decline". The invite is broadcast, so both
+// members see it.
+func (h *ChatHandler) handleGameInviteFrame(roomCode, username string, frame ClientFrame) {
+	partner, err := h.gamePartner(roomCode, username)
+	if err != nil {
+		h.sendError(roomCode, username, err)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(frame.Game))
+	if name == "" {
+		name = games.TicTacToe
+	}
+	if !frame.Decline && !slices.Contains(games.Names(), name) {
+		h.sendError(roomCode, username, fmt.Errorf("%w: không có trò %q, hãy chọn %s", errGame, name, strings.Join(games.Names(), ", ")))
+		return
+	}
+
+	g := h.roomGame(roomCode, true)
+	g.mu.Lock()
+	if g.blocking(time.Now()) {
+		g.mu.Unlock()
+		h.sendError(roomCode, username, fmt.Errorf("%w: trò chơi đang diễn ra", errGame))
+		return
+	}
+
+	invited := g.invite != "" && g.inviter == partner
+	if frame.Decline {
+		if invited {
+			g.invite, g.inviter = "", ""
+		}
+		g.mu.Unlock()
+		if invited {
+			h.broadcastToRoom(roomCode, ChatMessage{
+				Type:      "system",
+				Text:      username + " đã từ chối lời mời chơi",
+				Timestamp: time.Now().UnixMilli(),
+			})
+		}
+		return
+	}
+
+	if invited && g.invite == name {
+		game, err := games.New(name, [2]string{partner, username})
+		if err != nil {
+			g.mu.Unlock()
+			h.sendError(roomCode, username, err)
+			return
+		}
+		g.game = game
+		g.invite, g.inviter = "", ""
+		clear(g.away)
+		state := game.State()
+		g.mu.Unlock()
+
+		h.broadcastToRoom(roomCode, ChatMessage{Type: "game_state", Game: &state, Timestamp: time.Now().UnixMilli()})
+		return
+	}
+
+	g.invite, g.inviter = name, username
+	g.mu.Unlock()
+
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "game_invite",
+		From:      username,
+		Invite:    name,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// handleGameMoveFrame applies a move of the room's game, {"type":"game_move","move":{...}}
+// in the shape of the game, and broadcasts the new state
+func (h *ChatHandler) handleGameMoveFrame(roomCode, username string, frame ClientFrame) {
+	g := h.roomGame(roomCode, false)
+	if g == nil {
+		h.sendError(roomCode, username, fmt.Errorf("%w: chưa có trò chơi nào", errGame))
+		return
+	}
+
+	g.mu.Lock()
+	if g.game == nil {
+		g.mu.Unlock()
+		h.sendError(roomCode, username, fmt.Errorf("%w: chưa có trò chơi nào", errGame))
+		return
+	}
+	if err := g.game.Move(username, frame.Move); err != nil {
+		g.mu.Unlock()
+		h.sendError(roomCode, username, err)
+		return
+	}
+	state := g.game.State()
+	g.mu.Unlock()
+
+	h.broadcastToRoom(roomCode, ChatMessage{Type: "game_state", Game: &state, Timestamp: time.Now().UnixMilli()})
+}
+
+// handleGameStateFrame sends the member the state of the room's game, for a client that
+// lost track of it
+func (h *ChatHandler) handleGameStateFrame(roomCode, username string) {
+	if !h.sendGameState(roomCode, username) {
+		h.sendError(roomCode, username, fmt.Errorf("%w: chưa có trò chơi nào", errGame))
+	}
+}
+
+// sendGameState sends the member the state of the room's game, reporting false when
+// the room has none
+func (h *ChatHandler) sendGameState(roomCode, username string) bool {
+	g := h.roomGame(roomCode, false)
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	if g.game == nil {
+		g.mu.Unlock()
+		return false
+	}
+	state := g.game.State()
+	g.mu.Unlock()
+
+	h.sendToUser(roomCode, username, ChatMessage{Type: "game_state", Game: &state, Timestamp: time.Now().UnixMilli()})
+	return true
+}
+
+// resumeGame sends a player who rejoined the room the state of their unfinished game
+func (h *ChatHandler) resumeGame(roomCode, username string) {
+	g := h.roomGame(roomCode, false)
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	_, away := g.away[username]
+	delete(g.away, username)
+	resume := away && g.game != nil && !g.game.State().Over
+	g.mu.Unlock()
+
+	if resume {
+		h.sendGameState(roomCode, username)
+	}
+}
+
+// leaveGame marks a player who left the room as away, and drops their pending invite
+func (h *ChatHandler) leaveGame(roomCode, username string) {
+	g := h.roomGame(roomCode, false)
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inviter == username {
+		g.invite, g.inviter = "", ""
+	}
+	if g.game != nil && slices.Contains(g.game.State().Players, username) {
+		g.away[username] = time.Now()
+	}
+}
+
+// gamePartner returns the human partner to play with in a two-person room
+func (h *ChatHandler) gamePartner(roomCode, username string) (string, error) {
+	if model.IsChannelRoom(roomCode) {
+		return "", fmt.Errorf("%w: trò chơi chỉ dành cho phòng hai người", errGame)
+	}
+
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists {
+		return "", fmt.Errorf("%w: không có ai đang chat cùng", errGame)
+	}
+	partner, ok := room.Partner(username)
+	if !ok {
+		return "", fmt.Errorf("%w: không có ai đang chat cùng", errGame)
+	}
+	if model.IsBotUsername(partner) {
+		return "", fmt.Errorf("%w: bot không chơi được", errGame)
+	}
+	return partner, nil
+}
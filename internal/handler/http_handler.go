@@ -1,27 +1,44 @@
 package handler
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
 	"chatmix-backend/internal/service"
 
 	"github.com/sirupsen/logrus"
 )
 
+// maxIdempotencyKeyLength caps the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
 type HTTPHandler struct {
 	userService service.UserService
+	idempotency service.IdempotencyService
+	accessLog   *AccessLog // nil when logging.access is disabled
 	logger      *logrus.Logger
 }
 
 func NewHTTPHandler(
 	userService service.UserService,
+	idempotency service.IdempotencyService,
+	accessLog *AccessLog,
 	logger *logrus.Logger,
 ) *HTTPHandler {
 	return &HTTPHandler{
 		userService: userService,
+		idempotency: idempotency,
+		accessLog:   accessLog,
 		logger:      logger,
 	}
 }
@@ -36,44 +53,87 @@ func (h *HTTPHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, health)
 }
 
-func (h *HTTPHandler) LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		wrapped := NewStatusResponseWriter(w)
-
-		next.ServeHTTP(wrapped, r)
-
-		h.logger.WithFields(logrus.Fields{
-			"method":      r.Method,
-			"url":         r.URL.String(),
-			"status":      wrapped.Status(),
-			"duration":    time.Since(start),
-			"remote_addr": r.RemoteAddr,
-			"user_agent":  r.UserAgent(),
-		}).Info("HTTP request")
-	})
+// ClientIPMiddleware resolves the client address once per request and keeps it in the
+// context under "clientIP", honouring forwarding headers only from server.trusted_proxies
+func (h *HTTPHandler) ClientIPMiddleware(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// validate rejects a config with invalid entries, so err is always nil here
+			proxies, _ := cfg.Get().Server.TrustedProxyPrefixes()
+			ctx := context.WithValue(r.Context(), "clientIP", resolveClientIP(r, proxies))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// LoggingMiddleware logs every request and, with logging.access enabled, writes its
+// access log entry. Each request gets an X-Request-ID, kept in the context under
+// "requestID".
+func (h *HTTPHandler) LoggingMiddleware(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := NewStatusResponseWriter(w)
+
+			id := requestID(r)
+			w.Header().Set(requestIDHeader, id)
+			record := &accessRecord{}
+			ctx := context.WithValue(r.Context(), "requestID", id)
+			ctx = context.WithValue(ctx, "accessLog", record)
+
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			latency := time.Since(start)
+			h.logger.WithFields(logrus.Fields{
+				"method":      r.Method,
+				"url":         r.URL.String(),
+				"status":      wrapped.Status(),
+				"duration":    latency,
+				"remote_addr": r.RemoteAddr,
+				"user_agent":  r.UserAgent(),
+				"request_id":  id,
+			}).Info("HTTP request")
+
+			access := cfg.Get().Logging.Access
+			if h.accessLog == nil || !access.Enabled || !sampled(access, wrapped.Status(), latency) {
+				return
+			}
+			err := h.accessLog.write(accessLogEntry{
+				Time:       start,
+				RequestID:  id,
+				Method:     r.Method,
+				Route:      routeTemplate(r),
+				Path:       r.URL.Path,
+				Status:     wrapped.Status(),
+				Bytes:      wrapped.Bytes(),
+				LatencyMS:  float64(latency.Microseconds()) / 1000,
+				UserID:     record.userID,
+				RemoteAddr: clientIP(r),
+				UserAgent:  r.UserAgent(),
+			})
+			if err != nil {
+				h.logger.WithError(err).Warn("Failed to write access log")
+			}
+		})
+	}
 }
 
-func (h *HTTPHandler) CORSMiddleware(corsConfig config.CORSConfig) func(http.Handler) http.Handler {
+func (h *HTTPHandler) CORSMiddleware(cfg *config.Provider) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			corsConfig := cfg.Get().Server.CORS.ForPath(r.URL.Path)
 			origin := r.Header.Get("Origin")
 
 			// Check if origin is allowed
-			allowed := false
-			for _, allowedOrigin := range corsConfig.AllowedOrigins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					allowed = true
-					break
-				}
-			}
+			allowed := corsConfig.AllowsOrigin(origin)
 
 			// Set CORS headers - always set for OPTIONS requests
 			if allowed || r.Method == "OPTIONS" {
-				if len(corsConfig.AllowedOrigins) == 1 && corsConfig.AllowedOrigins[0] == "*" {
+				if corsConfig.AllowsAnyOrigin() {
 					w.Header().Set("Access-Control-Allow-Origin", "*")
 				} else if origin != "" {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Add("Vary", "Origin")
 				}
 			}
 
@@ -87,6 +147,10 @@ func (h *HTTPHandler) CORSMiddleware(corsConfig config.CORSConfig) func(http.Han
 					w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsConfig.AllowedHeaders, ", "))
 				}
 
+				if len(corsConfig.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsConfig.ExposedHeaders, ", "))
+				}
+
 				if corsConfig.AllowCredentials {
 					w.Header().Set("Access-Control-Allow-Credentials", "true")
 				}
@@ -94,6 +158,9 @@ func (h *HTTPHandler) CORSMiddleware(corsConfig config.CORSConfig) func(http.Han
 
 			// Handle preflight OPTIONS request
 			if r.Method == "OPTIONS" {
+				if corsConfig.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsConfig.MaxAge.Seconds())))
+				}
 				WriteStatus(w, http.StatusOK)
 				return
 			}
@@ -109,6 +176,120 @@ func (h *HTTPHandler) CORSMiddleware(corsConfig config.CORSConfig) func(http.Han
 	}
 }
 
+// BodyLimitMiddleware caps request bodies at the limit of server.body_limits for the path.
+// Bodies announced larger are refused up front; handlers see a read error past the limit.
+func (h *HTTPHandler) BodyLimitMiddleware(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := cfg.Get().Server.BodyLimits.ForPath(r.URL.Path)
+			if limit > 0 && r.Body != nil && r.Body != http.NoBody {
+				if r.ContentLength > limit {
+					WriteError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IdempotencyMiddleware replays the first response to retries of a request sent with
+// the same Idempotency-Key header. Keys are scoped by the user, or the client address
+// for anonymous requests, and by the route; reusing a key for a different query string
+// or body gets 422 and retrying while the first request runs gets 409. Responses with a
+// server error are not kept, so those requests can be retried. Requests without the
+// header pass.
+func (h *HTTPHandler) IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || !h.idempotency.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			WriteError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := "ip:" + clientIP(r)
+		if user, ok := r.Context().Value("user").(*model.User); ok {
+			scope = "user:" + user.ID.Hex()
+		}
+		key = scope + " " + r.Method + " " + r.URL.Path + " " + key
+		// Routes such as /api/chat/start take their input from the query string; the
+		// newline cannot occur in a raw query, so query and body cannot run together
+		hash := sha256.New()
+		hash.Write([]byte(r.URL.RawQuery + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		stored, err := h.idempotency.Begin(key, fingerprint)
+		switch {
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			WriteError(w, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			WriteError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		case errors.Is(err, service.ErrIdempotencyFull):
+			h.logger.WithField("path", r.URL.Path).Warn("Idempotency store full, running request without it")
+			next.ServeHTTP(w, r)
+			return
+		case stored != nil:
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// Also reached when the handler panics
+			if !completed || recorder.status >= http.StatusInternalServerError {
+				h.idempotency.Release(key)
+				return
+			}
+			h.idempotency.Complete(key, &service.IdempotentResponse{
+				Status: recorder.status,
+				Header: w.Header().Clone(),
+				Body:   recorder.body.Bytes(),
+			})
+		}()
+		next.ServeHTTP(recorder, r)
+		completed = true
+	})
+}
+
+// responseRecorder keeps a copy of the response it writes through
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *responseRecorder) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseRecorder) Write(data []byte) (int, error) {
+	rw.body.Write(data)
+	return rw.ResponseWriter.Write(data)
+}
+
 // Recovery middleware
 func (h *HTTPHandler) RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
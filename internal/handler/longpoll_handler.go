@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+// pollTimeout is how long a poll waits for new frames. The write deadline of a poll is
+// moved past it, so it works with any server.write_timeout.
+const pollTimeout = 25 * time.Second
+
+// pollIdleTimeout is how long a long-poll member keeps their seat without polling
+const pollIdleTimeout = 2 * pollTimeout
+
+// HandlePoll is the long-poll fallback transport. It returns the room frames broadcast
+// after cursor, waiting up to pollTimeout when there are none yet, together with the
+// cursor for the next poll. Messages are sent with HandleSendMessage. Frames sent to a
+// single member (errors, notifications) are only delivered over WebSocket or SSE. Frames
+// of members the poller muted are left out. A member who stops polling for
+// pollIdleTimeout leaves the room, see pollClient.
+func (h *ChatHandler) HandlePoll(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var cursor uint64
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		cursor = parsed
+	}
+
+	roomCode := mux.Vars(r)["code"]
+	if !h.joinRoom(w, roomCode, user.Username) {
+		h.dropRoomBuffer(roomCode)
+		return
+	}
+	if client := h.trackPoll(roomCode, user); client != nil {
+		defer client.touch()
+	}
+	buffer := h.roomBuffer(roomCode, true)
+	h.sendIcebreaker(roomCode)
+	h.greetFromBot(roomCode)
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(pollTimeout + 10*time.Second)); err != nil {
+		h.logger.WithError(err).WithField("room", roomCode).Warn("Failed to extend write deadline for poll")
+	}
+
+	timer := time.NewTimer(pollTimeout)
+	defer timer.Stop()
+
+	for {
+		frames, latest, missed, wait := buffer.Since(cursor)
+		frames = withoutMuted(frames, h.mutedBy(roomCode, user.Username))
+		if len(frames) > 0 {
+			writePollResponse(w, frames, latest, missed)
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-timer.C:
+			writePollResponse(w, nil, latest, false)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// pollClient holds the seat of a long-poll member in h.connections, so the member is
+// tracked like any other connection. Frames reach pollers through the room buffer and
+// sends are discarded. The client closes itself when no poll arrives within
+// pollIdleTimeout; closing it leaves the room and announces it, as when a socket drops.
+type pollClient struct {
+	connGeneration
+	clientFeatures
+	idle      *time.Timer
+	done      chan struct{}
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newPollClient(onClose func()) *pollClient {
+	c := &pollClient{done: make(chan struct{}), onClose: onClose}
+	c.idle = time.AfterFunc(pollIdleTimeout, c.Close)
+	return c
+}
+
+func (c *pollClient) Send([]byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *pollClient) SendDroppable(data []byte) bool {
+	return c.Send(data)
+}
+
+// touch restarts the idle timeout, at the start and end of every poll
+func (c *pollClient) touch() {
+	c.idle.Reset(pollIdleTimeout)
+}
+
+// Close runs onClose in its own goroutine, since callers such as addConnection close
+// clients while holding connLock
+func (c *pollClient) Close() {
+	c.closeOnce.Do(func() {
+		c.idle.Stop()
+		close(c.done)
+		go c.onClose()
+	})
+}
+
+func (c *pollClient) CloseWith(int, string) {
+	c.Close()
+}
+
+// trackPoll gives a long-poll member a pollClient holding their seat, or restarts the
+// idle timeout of the one they have. A member connected over WebSocket or SSE keeps
+// that connection and nil is returned.
+func (h *ChatHandler) trackPoll(roomCode string, user *model.User) *pollClient {
+	h.connLock.Lock()
+	if h.closing {
+		h.connLock.Unlock()
+		return nil
+	}
+	current := h.connections[roomCode][user.Username]
+	if client, ok := current.(*pollClient); ok {
+		h.connLock.Unlock()
+		client.touch()
+		return client
+	}
+	if current != nil {
+		h.connLock.Unlock()
+		return nil
+	}
+
+	var client *pollClient
+	client = newPollClient(func() { h.disconnect(roomCode, user.Username, client) })
+	h.generation++
+	client.setGeneration(h.generation)
+	if h.connections[roomCode] == nil {
+		h.connections[roomCode] = make(map[string]roomClient)
+	}
+	h.connections[roomCode][user.Username] = client
+	h.connLock.Unlock()
+
+	h.trackConnection(user, roomCode, client)
+	return client
+}
+
+func withoutMuted(frames []roomFrame, muted map[string]bool) []roomFrame {
+	if len(muted) == 0 {
+		return frames
+	}
+	return slices.DeleteFunc(frames, func(frame roomFrame) bool { return muted[frame.From] })
+}
+
+func writePollResponse(w http.ResponseWriter, frames []roomFrame, cursor uint64, missed bool) {
+	data := make([]json.RawMessage, len(frames))
+	for i, frame := range frames {
+		data[i] = frame.Data
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"cursor": cursor,
+		"frames": data,
+		"missed": missed, // frames were dropped from the buffer; reload GET /messages
+	})
+}
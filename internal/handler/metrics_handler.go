@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MetricsHandler serves the metrics of the registry to Prometheus, see
+// config.MetricsConfig
+type MetricsHandler struct {
+	registry *metrics.Registry
+	token    string
+	logger   *logrus.Logger
+}
+
+func NewMetricsHandler(registry *metrics.Registry, config config.MetricsConfig, logger *logrus.Logger) *MetricsHandler {
+	return &MetricsHandler{
+		registry: registry,
+		token:    config.Token,
+		logger:   logger,
+	}
+}
+
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(h.token)) != 1 {
+		WriteError(w, http.StatusUnauthorized, "Invalid metrics token")
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := h.registry.WriteTo(w); err != nil {
+		h.logger.WithError(err).Debug("Failed to write metrics")
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+// roomMutes maps a member to the members they muted and since when
+type roomMutes map[string]map[string]time.Time
+
+var errMuteTarget = errors.New("không thể tắt tiếng")
+
+// handleMuteFrame mutes or unmutes a member for the sender. {"type":"mute"} mutes the
+// partner of a two-person room; in channels the member is named with "target". Frames
+// from a muted member are no longer delivered to the sender, who stays in the room, so
+// they can keep reporting. Mutes last while the sender is in the room, or connected to
+// the channel, and are recorded in the audit log for moderators reviewing reports.
+func (h *ChatHandler) handleMuteFrame(roomCode, username string, frame ClientFrame, mute bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target, err := h.muteTarget(ctx, roomCode, username, strings.TrimSpace(frame.Target))
+	if err != nil {
+		h.sendError(roomCode, username, err)
+		return
+	}
+
+	action, text := model.AuditActionChatUnmute, "Đã bật lại tiếng "+target
+	if mute {
+		action, text = model.AuditActionChatMute, "Đã tắt tiếng "+target+", bạn sẽ không nhận tin nhắn của họ"
+	}
+	if !h.setMuted(roomCode, username, target, mute) {
+		return
+	}
+
+	entry := model.NewAuditLog(nil, action, target, "")
+	entry.Actor = username
+	entry.Details = map[string]interface{}{"room": roomCode}
+	h.auditService.Record(ctx, entry)
+
+	h.sendToUser(roomCode, username, ChatMessage{
+		Type:      "system",
+		Text:      text,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// muteTarget returns the member to mute: the named one, or the partner of a two-person room
+func (h *ChatHandler) muteTarget(ctx context.Context, roomCode, username, target string) (string, error) {
+	if target == username {
+		return "", fmt.Errorf("%w chính mình", errMuteTarget)
+	}
+
+	if model.IsChannelRoom(roomCode) {
+		if target == "" {
+			return "", fmt.Errorf("%w: hãy chọn thành viên bằng \"target\"", errMuteTarget)
+		}
+		slug := strings.TrimPrefix(roomCode, model.ChannelRoomPrefix)
+		member, err := h.channelService.IsMember(ctx, slug, target)
+		if err != nil {
+			return "", err
+		}
+		if !member {
+			return "", fmt.Errorf("%w: %s không ở trong kênh", errMuteTarget, target)
+		}
+		return target, nil
+	}
+
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists {
+		return "", fmt.Errorf("%w: không có ai đang chat cùng", errMuteTarget)
+	}
+	if target == "" {
+		partner, ok := room.Partner(username)
+		if !ok {
+			return "", fmt.Errorf("%w: không có ai đang chat cùng", errMuteTarget)
+		}
+		return partner, nil
+	}
+	if !room.HasUser(target) {
+		return "", fmt.Errorf("%w: %s không ở trong phòng", errMuteTarget, target)
+	}
+	return target, nil
+}
+
+// setMuted records whether username has muted target in the room. It reports false
+// when nothing changed.
+func (h *ChatHandler) setMuted(roomCode, username, target string, mute bool) bool {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	mutes := h.mutes[roomCode]
+	_, muted := mutes[username][target]
+	if muted == mute {
+		return false
+	}
+
+	if !mute {
+		delete(mutes[username], target)
+		if len(mutes[username]) == 0 {
+			delete(mutes, username)
+		}
+		if len(mutes) == 0 {
+			delete(h.mutes, roomCode)
+		}
+		return true
+	}
+
+	if mutes == nil {
+		mutes = make(roomMutes)
+		h.mutes[roomCode] = mutes
+	}
+	if mutes[username] == nil {
+		mutes[username] = make(map[string]time.Time)
+	}
+	mutes[username][target] = time.Now()
+	return true
+}
+
+// mutedSince returns when username muted target in the room
+func (h *ChatHandler) mutedSince(roomCode, username, target string) (time.Time, bool) {
+	h.connLock.RLock()
+	defer h.connLock.RUnlock()
+	since, muted := h.mutes[roomCode][username][target]
+	return since, muted
+}
+
+// mutedBy returns the members username has muted in the room
+func (h *ChatHandler) mutedBy(roomCode, username string) map[string]bool {
+	h.connLock.RLock()
+	defer h.connLock.RUnlock()
+
+	muted := make(map[string]bool, len(h.mutes[roomCode][username]))
+	for target := range h.mutes[roomCode][username] {
+		muted[target] = true
+	}
+	return muted
+}
+
+// clearMutes drops the mutes of a user who left the room; the caller holds connLock
+func (h *ChatHandler) clearMutes(roomCode, username string) {
+	delete(h.mutes[roomCode], username)
+	if len(h.mutes[roomCode]) == 0 {
+		delete(h.mutes, roomCode)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationHandler serves the authenticated user's notification inbox
+type NotificationHandler struct {
+	notificationService service.NotificationService
+	logger              *logrus.Logger
+}
+
+func NewNotificationHandler(notificationService service.NotificationService, logger *logrus.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// ListNotifications returns the latest notifications, or only unread ones with ?unread=true
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	notifications, err := h.notificationService.List(ctx, user.ID, unreadOnly)
+	if err != nil {
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to list notifications")
+		WriteError(w, http.StatusInternalServerError, "Failed to get notifications")
+		return
+	}
+
+	unread, err := h.notificationService.UnreadCount(ctx, user.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get notifications")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"unread_count":  unread,
+	})
+}
+
+func (h *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	unread, err := h.notificationService.UnreadCount(ctx, user.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"unread_count": unread,
+	})
+}
+
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	if err := h.notificationService.MarkRead(ctx, user.ID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, service.ErrNotificationNotFound) {
+			WriteError(w, http.StatusNotFound, "Notification not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "Failed to mark notification as read")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "Notification marked as read",
+	})
+}
+
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	count, err := h.notificationService.MarkAllRead(ctx, user.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to mark notifications as read")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"marked": count,
+	})
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"chatmix-backend/internal/repository"
+)
+
+// nextCursorHeader carries the cursor of the next page of a list endpoint, absent on the
+// last page. Clients pass it back as ?cursor=.
+const nextCursorHeader = "X-Next-Cursor"
+
+// parsePageRequest reads ?limit= and ?cursor= of a list endpoint
+func parsePageRequest(r *http.Request) (repository.PageRequest, error) {
+	query := r.URL.Query()
+
+	var page repository.PageRequest
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return page, errors.New("Invalid limit")
+		}
+		page.Limit = min(n, repository.MaxPageSize)
+	}
+
+	after, err := repository.ParseCursor(query.Get("cursor"))
+	if err != nil {
+		return page, errors.New("Invalid cursor")
+	}
+	page.After = after
+	return page, nil
+}
+
+// writeNextCursor sets the next cursor header of a page
+func writeNextCursor[T any](w http.ResponseWriter, page *repository.Page[T]) {
+	if next := page.Next.Encode(); next != "" {
+		w.Header().Set(nextCursorHeader, next)
+	}
+}
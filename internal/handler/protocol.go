@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"chatmix-backend/internal/config"
+)
+
+// ProtocolVersion is the version of the chat protocol: the REST API and the socket
+// frames. It goes up with every change old clients cannot handle.
+const ProtocolVersion = 1
+
+// protocolHeader carries the protocol version: the server's on every response, the
+// client's on requests
+const protocolHeader = "X-Chat-Protocol"
+
+// clientProtocol returns the protocol version a request states in the X-Chat-Protocol
+// header or, for sockets whose handshake cannot set headers, the protocol query
+// parameter. Requests that state none or an invalid one are version 0.
+func clientProtocol(r *http.Request) int {
+	value := r.Header.Get(protocolHeader)
+	if value == "" {
+		value = r.URL.Query().Get("protocol")
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0
+	}
+	return version
+}
+
+// protocolDeprecation returns the warning for clients of a deprecated protocol version,
+// empty when the version is current
+func protocolDeprecation(policy config.ProtocolConfig, version int) string {
+	if version >= policy.DeprecatedBelow {
+		return ""
+	}
+	return fmt.Sprintf("chat protocol %d is deprecated, upgrade to version %d", version, ProtocolVersion)
+}
+
+// ProtocolMiddleware sets the X-Chat-Protocol header on every response, refuses clients
+// below server.protocol.min_version with 426 and warns deprecated ones with a Warning
+// header. Socket handshakes are refused the same way before the upgrade; the warning is
+// kept in the request context for their hello_ack frame, see protocolWarning.
+func (h *HTTPHandler) ProtocolMiddleware(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(protocolHeader, strconv.Itoa(ProtocolVersion))
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			policy := cfg.Get().Server.Protocol
+			version := clientProtocol(r)
+			if version < policy.MinVersion {
+				WriteError(w, http.StatusUpgradeRequired, fmt.Sprintf("chat protocol %d is no longer supported, upgrade to version %d", version, ProtocolVersion))
+				return
+			}
+			if warning := protocolDeprecation(policy, version); warning != "" {
+				w.Header().Set("Warning", fmt.Sprintf("299 - %s", strconv.Quote(warning)))
+				r = r.WithContext(context.WithValue(r.Context(), "protocolWarning", warning))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// protocolWarning returns the deprecation warning ProtocolMiddleware found for the
+// request, empty when its protocol version is current
+func protocolWarning(r *http.Request) string {
+	warning, _ := r.Context().Value("protocolWarning").(string)
+	return warning
+}
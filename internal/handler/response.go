@@ -3,9 +3,12 @@ package handler
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
 )
 
@@ -22,13 +25,92 @@ func WriteError(w http.ResponseWriter, statusCode int, message string) {
 	})
 }
 
+// writeBodyError answers a request whose body could not be decoded, with 413 when the
+// body exceeded its limit, see HTTPHandler.BodyLimitMiddleware
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		WriteError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	WriteError(w, http.StatusBadRequest, "Invalid request body")
+}
+
 func WriteStatus(w http.ResponseWriter, statusCode int) {
 	w.WriteHeader(statusCode)
 }
 
+// clientIP returns the address resolved by ClientIPMiddleware, or the connection's
+// address for requests that did not pass through it
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value("clientIP").(string); ok {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// remoteHost strips the port from a RemoteAddr
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// resolveClientIP returns the address of the client. X-Forwarded-For and X-Real-IP are
+// only believed when the connection comes from a trusted proxy; X-Forwarded-For is read
+// from the right, skipping the trusted proxies, since anything left of them was sent by
+// the client and can be forged.
+func resolveClientIP(r *http.Request, proxies []netip.Prefix) string {
+	remote := remoteHost(r.RemoteAddr)
+	if !trustedProxy(remote, proxies) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !trustedProxy(client, proxies) {
+			return client
+		}
+	}
+	if client != "" {
+		return client
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return remote
+}
+
+func trustedProxy(ip string, proxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 type StatusResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func NewStatusResponseWriter(w http.ResponseWriter) *StatusResponseWriter {
@@ -40,15 +122,33 @@ func (rw *StatusResponseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *StatusResponseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
 func (rw *StatusResponseWriter) Status() int { return rw.statusCode }
 
+// Bytes returns the size of the body written so far
+func (rw *StatusResponseWriter) Bytes() int64 { return rw.bytes }
+
+// Hijack takes over the connection, e.g. for a WebSocket upgrade, after which the status
+// is 101 Switching Protocols
 func (rw *StatusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hj, ok := rw.ResponseWriter.(http.Hijacker); ok {
-		return hj.Hijack()
+		conn, buf, err := hj.Hijack()
+		if err == nil {
+			rw.statusCode = http.StatusSwitchingProtocols
+		}
+		return conn, buf, err
 	}
 	return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *StatusResponseWriter) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
+
 func (rw *StatusResponseWriter) Flush() {
 	if fl, ok := rw.ResponseWriter.(http.Flusher); ok {
 		fl.Flush()
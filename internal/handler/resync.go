@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+// handleResyncFrame answers a client that saw a gap in the sequence numbers, or
+// reconnected, with a "resync" frame holding the room frames after the frame's seq. When
+// the client fell further behind than the room buffer reaches, the frame has Missed set
+// and holds the stored history of the room instead, which replaces what the client
+// shows. Its seq is the room's latest sequence number; live frames may arrive before it,
+// so clients drop frames whose seq they already have.
+func (h *ChatHandler) handleResyncFrame(roomCode, username string, frame ClientFrame) {
+	buffer := h.roomBuffer(roomCode, false)
+	if buffer == nil {
+		return
+	}
+
+	frames, latest, missed, _ := buffer.Since(frame.Seq)
+	resync := ChatMessage{
+		Type:      "resync",
+		Seq:       latest,
+		Missed:    missed,
+		Timestamp: time.Now().UnixMilli(),
+	}
+
+	muted := h.mutedBy(roomCode, username)
+	if missed {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		messages, err := h.messageService.GetRoomHistory(ctx, roomCode)
+		if err != nil {
+			h.sendError(roomCode, username, err) // logs the failure
+			return
+		}
+		stored := make([]*model.Message, 0, len(messages))
+		for _, message := range visibleMessages(messages, username) {
+			if !message.IsDeleted && !muted[message.From] {
+				stored = append(stored, message)
+			}
+		}
+		resync.History = historyMessages(stored)
+	} else {
+		h.connLock.RLock()
+		client := h.connections[roomCode][username]
+		h.connLock.RUnlock()
+
+		frames = withoutUnsupported(withoutMuted(frames, muted), client)
+		resync.Frames = make([]json.RawMessage, len(frames))
+		for i, frame := range frames {
+			resync.Frames[i] = frame.Data
+		}
+	}
+
+	h.sendToUser(roomCode, username, resync)
+}
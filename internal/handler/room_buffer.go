@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// roomBufferSize is how many recent frames each room keeps for long-poll clients and
+// resync frames
+const roomBufferSize = 256
+
+// roomFrame is a broadcast frame with its position in the room's sequence
+type roomFrame struct {
+	Seq  uint64
+	Data json.RawMessage
+	From string // sender of the frame, for filtering muted members
+	Type string // type of the frame, for filtering frames a client cannot parse
+}
+
+// frameBuffer is a ring of the most recent frames broadcast to a room and the room's
+// sequence counter. Cursors are frame sequence numbers, so a client asking for frames
+// after its cursor never misses one unless it fell more than a full ring behind.
+type frameBuffer struct {
+	mu     sync.Mutex
+	frames []roomFrame
+	start  int
+	count  int
+	last   uint64
+	notify chan struct{} // closed and replaced on every append
+}
+
+func newFrameBuffer(size int) *frameBuffer {
+	return &frameBuffer{
+		frames: make([]roomFrame, size),
+		notify: make(chan struct{}),
+	}
+}
+
+// Append numbers a frame, stores it and wakes up waiting readers. encode renders the
+// frame with its sequence number; deliver queues it to the live connections while the
+// buffer is still locked, so every connection gets the room's frames in sequence order.
+func (b *frameBuffer) Append(from, frameType string, encode func(seq uint64) ([]byte, error), deliver func(data []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, err := encode(b.last + 1)
+	if err != nil {
+		return err
+	}
+	b.last++
+	frame := roomFrame{Seq: b.last, Data: data, From: from, Type: frameType}
+	if b.count < len(b.frames) {
+		b.frames[(b.start+b.count)%len(b.frames)] = frame
+		b.count++
+	} else {
+		b.frames[b.start] = frame
+		b.start = (b.start + 1) % len(b.frames)
+	}
+	deliver(data)
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+	return nil
+}
+
+// Since returns the frames after cursor, the latest sequence number, whether frames
+// between cursor and the oldest buffered frame were dropped, and a channel that is
+// closed on the next append (for waiting when nothing is new yet)
+func (b *frameBuffer) Since(cursor uint64) (frames []roomFrame, latest uint64, missed bool, wait <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cursor > b.last {
+		cursor = b.last // stale cursor from an earlier room with the same code
+	}
+
+	for i := 0; i < b.count; i++ {
+		frame := b.frames[(b.start+i)%len(b.frames)]
+		if frame.Seq > cursor {
+			if len(frames) == 0 && frame.Seq > cursor+1 {
+				missed = true
+			}
+			frames = append(frames, frame)
+		}
+	}
+
+	return frames, b.last, missed, b.notify
+}
+
+// roomBuffer returns the frame buffer of a room, creating it when create is set
+func (h *ChatHandler) roomBuffer(roomCode string, create bool) *frameBuffer {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	buffer := h.buffers[roomCode]
+	if buffer == nil && create {
+		buffer = newFrameBuffer(roomBufferSize)
+		h.buffers[roomCode] = buffer
+	}
+	return buffer
+}
+
+// dropRoomBuffer releases the buffer of a room that no longer exists
+func (h *ChatHandler) dropRoomBuffer(roomCode string) {
+	if _, exists := h.chatService.GetRoom(roomCode); exists {
+		return
+	}
+
+	h.connLock.Lock()
+	delete(h.buffers, roomCode)
+	delete(h.mutes, roomCode)
+	delete(h.games, roomCode)
+	h.connLock.Unlock()
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatmix-backend/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// roomClient is a connection to a room over any transport (WebSocket, SSE).
+// Broadcasts go through Send so every transport shares the same room fan-out.
+type roomClient interface {
+	// Send queues a frame without blocking; it returns false when the client
+	// is closed or too far behind
+	Send(data []byte) bool
+	// SendDroppable queues a room frame that a slow client may lose, see sendPolicy
+	SendDroppable(data []byte) bool
+	// Accepts reports whether the client can parse frames of the type, see
+	// handleHelloFrame
+	Accepts(frameType string) bool
+	Close()
+	// CloseWith closes the client with one of the close codes, telling the client why
+	// when the transport allows it
+	CloseWith(code int, reason string)
+	// Generation is the number addConnection gave the connection; a user's newer
+	// connections have higher numbers
+	Generation() uint64
+	setGeneration(generation uint64)
+}
+
+// connGeneration holds the generation of a room connection
+type connGeneration struct {
+	generation uint64
+}
+
+func (g *connGeneration) Generation() uint64 {
+	return g.generation
+}
+
+func (g *connGeneration) setGeneration(generation uint64) {
+	g.generation = generation
+}
+
+// sendPolicy bounds the frames waiting for a slow client, see
+// config.WebSocketConfig.SendBuffer. Broadcast room frames are droppable, since they
+// carry sequence numbers and the client recovers them with a resync frame.
+type sendPolicy struct {
+	limit      int // frames queued when the client is disconnected
+	watermark  int // frames queued before droppable frames are dropped, with dropOldest
+	dropOldest bool
+	stats      *deliveryStats
+}
+
+func newSendPolicy(cfg config.WebSocketConfig) sendPolicy {
+	return sendPolicy{
+		limit:      cfg.SendBuffer,
+		watermark:  cfg.DropWatermark,
+		dropOldest: cfg.SlowClientPolicy == config.SlowClientDropOldest,
+		stats:      &deliveryStats{},
+	}
+}
+
+// deliveryStats counts what the send policy did to slow clients
+type deliveryStats struct {
+	dropped      atomic.Int64
+	disconnected atomic.Int64
+}
+
+// DeliveryStats are the counters of the send policy since startup
+type DeliveryStats struct {
+	Policy              string `json:"policy"`
+	SendBuffer          int    `json:"send_buffer"`
+	DropWatermark       int    `json:"drop_watermark"`
+	DroppedFrames       int64  `json:"dropped_frames"`       // droppable frames dropped for slow clients
+	ClientsDisconnected int64  `json:"clients_disconnected"` // clients disconnected with a full send buffer
+}
+
+// queuedFrame is a frame waiting in a client queue
+type queuedFrame struct {
+	data      []byte
+	droppable bool
+}
+
+// clientQueue is the outgoing frame queue shared by all transports. Writers wait on
+// ready and drain the queue with next.
+type clientQueue struct {
+	connGeneration
+	clientFeatures
+	policy    sendPolicy
+	mu        sync.Mutex
+	frames    []queuedFrame
+	ready     chan struct{} // holds a token while frames are queued
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClientQueue(policy sendPolicy) clientQueue {
+	return clientQueue{
+		policy: policy,
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (q *clientQueue) Send(data []byte) bool {
+	return q.enqueue(queuedFrame{data: data})
+}
+
+func (q *clientQueue) SendDroppable(data []byte) bool {
+	return q.enqueue(queuedFrame{data: data, droppable: true})
+}
+
+func (q *clientQueue) enqueue(frame queuedFrame) bool {
+	select {
+	case <-q.done:
+		return false
+	default:
+	}
+
+	q.mu.Lock()
+	if q.policy.dropOldest && len(q.frames) >= q.policy.watermark {
+		if i := slices.IndexFunc(q.frames, func(queued queuedFrame) bool { return queued.droppable }); i >= 0 {
+			q.frames = slices.Delete(q.frames, i, i+1)
+			q.policy.stats.dropped.Add(1)
+		}
+	}
+	if len(q.frames) >= q.policy.limit {
+		q.mu.Unlock()
+		q.policy.stats.disconnected.Add(1)
+		return false
+	}
+	q.frames = append(q.frames, frame)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// next takes the oldest queued frame
+func (q *clientQueue) next() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.frames) == 0 {
+		return nil, false
+	}
+	data := q.frames[0].data
+	q.frames[0] = queuedFrame{}
+	q.frames = q.frames[1:]
+	return data, true
+}
+
+func (q *clientQueue) Close() {
+	q.closeOnce.Do(func() { close(q.done) })
+}
+
+// wsClient writes queued frames and pings from a single goroutine,
+// since a websocket connection supports only one concurrent writer
+type wsClient struct {
+	clientQueue
+	conn    *websocket.Conn
+	codec   frameCodec
+	warning string // deprecation warning of the protocol version the client connected with
+}
+
+func newWSClient(conn *websocket.Conn, policy sendPolicy, codec frameCodec, warning string) *wsClient {
+	client := &wsClient{clientQueue: newClientQueue(policy), conn: conn, codec: codec, warning: warning}
+	go client.writePump()
+	return client
+}
+
+func (c *wsClient) Close() {
+	c.clientQueue.Close()
+	c.conn.Close()
+}
+
+func (c *wsClient) CloseWith(code int, reason string) {
+	c.clientQueue.Close()
+	closeSocket(c.conn, code, reason)
+}
+
+// readFrame reads the next frame of the connection as JSON. A frame that cannot be
+// decoded returns errFrameEncoding and leaves the connection open.
+func (c *wsClient) readFrame() ([]byte, error) {
+	messageType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.decode(messageType, data)
+}
+
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ready:
+			for data, ok := c.next(); ok; data, ok = c.next() {
+				messageType, payload, err := c.codec.encode(data)
+				if err != nil {
+					continue
+				}
+				c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				c.conn.EnableWriteCompression(c.has(featureCompression))
+				if err := c.conn.WriteMessage(messageType, payload); err != nil {
+					c.Close()
+					return
+				}
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(5*time.Second)); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// sseClient queues frames for an event stream; the request handler drains the queue
+type sseClient struct {
+	clientQueue
+}
+
+func newSSEClient(policy sendPolicy) *sseClient {
+	return &sseClient{clientQueue: newClientQueue(policy)}
+}
+
+// CloseWith queues an error frame with the code, which the stream writes before it ends
+func (c *sseClient) CloseWith(code int, reason string) {
+	if data, err := json.Marshal(ChatMessage{Type: "error", Code: code, Text: reason, Timestamp: time.Now().UnixMilli()}); err == nil {
+		c.Send(data)
+	}
+	c.Close()
+}
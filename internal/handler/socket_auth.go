@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/service"
+
+	"github.com/gorilla/websocket"
+)
+
+// authFrameLimit bounds the first frame of a socket that has not authenticated yet
+const authFrameLimit = 4096
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+		return authHeader[len("bearer "):]
+	}
+	return ""
+}
+
+var (
+	errInvalidToken   = errors.New("invalid token")
+	errSessionExpired = errors.New("session expired")
+)
+
+// tokenUser returns the user of an access token whose session is still active, so the
+// chat transports refuse devices logged out elsewhere like AuthMiddleware does
+func (h *ChatHandler) tokenUser(ctx context.Context, token string) (*model.User, error) {
+	user, err := h.authService.GetUserFromToken(token)
+	if err != nil || user == nil {
+		return nil, errInvalidToken
+	}
+	if err := h.authService.TouchSession(ctx, token); err != nil {
+		if errors.Is(err, service.ErrSessionExpired) {
+			return nil, errSessionExpired
+		}
+		h.logger.WithError(err).WithField("user_id", user.ID.Hex()).Warn("Failed to record session activity")
+	}
+	return user, nil
+}
+
+// authenticateSocket returns the user of a WebSocket request. A bearer token, the access
+// token cookie, or the deprecated token query parameter when websocket.query_token is
+// enabled, is checked before the upgrade and conn is nil. Otherwise the request is
+// upgraded and the client must send {"type":"auth","token":"..."} within
+// websocket.auth_timeout; conn is then the upgraded connection. ok is false when the
+// request was refused. Callers check the proto parameter with socketCodec first.
+func (h *ChatHandler) authenticateSocket(w http.ResponseWriter, r *http.Request) (user *model.User, conn *websocket.Conn, ok bool) {
+	token := bearerToken(r)
+	if query := r.URL.Query().Get("token"); token == "" && query != "" {
+		if !h.wsConfig.QueryToken {
+			WriteError(w, http.StatusUnauthorized, "token query parameter is disabled, send an auth frame")
+			return nil, nil, false
+		}
+		h.logger.WithField("path", r.URL.Path).Debug("Deprecated token query parameter used")
+		token = query
+	}
+	// The cookie token transport sets a cookie; browsers also send it on cross-site
+	// handshakes, so it is only trusted from pages of this host
+	if token == "" && sameOrigin(r) {
+		token = cookieValue(r, accessTokenCookie)
+	}
+
+	if token != "" {
+		user, err := h.tokenUser(r.Context(), token)
+		if err != nil {
+			WriteError(w, http.StatusUnauthorized, err.Error())
+			return nil, nil, false
+		}
+		noteAccessUser(r, user)
+		return user, nil, true
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.WithError(err).WithField("path", r.URL.Path).Warn("WebSocket upgrade failed")
+		return nil, nil, false
+	}
+
+	conn.SetReadLimit(authFrameLimit)
+	conn.SetReadDeadline(time.Now().Add(h.wsConfig.AuthTimeout))
+	codec, _ := socketCodec(r)
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		refuseSocket(w, conn, http.StatusUnauthorized, "authentication timed out")
+		return nil, nil, false
+	}
+
+	var frame ClientFrame
+	if data, err = codec.decode(messageType, data); err == nil {
+		err = json.Unmarshal(data, &frame)
+	}
+	if err != nil || frame.Type != "auth" || frame.Token == "" {
+		refuseSocket(w, conn, http.StatusUnauthorized, "auth frame required")
+		return nil, nil, false
+	}
+
+	user, err = h.tokenUser(r.Context(), frame.Token)
+	if err != nil {
+		refuseSocket(w, conn, http.StatusUnauthorized, err.Error())
+		return nil, nil, false
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := codec.writeFrame(conn, ChatMessage{Type: "authenticated", From: "system", Timestamp: time.Now().UnixMilli()}); err != nil {
+		conn.Close()
+		return nil, nil, false
+	}
+	noteAccessUser(r, user)
+	return user, conn, true
+}
+
+// upgradeSocket upgrades the request unless the auth frame handshake already did
+func (h *ChatHandler) upgradeSocket(w http.ResponseWriter, r *http.Request, conn *websocket.Conn) (*websocket.Conn, error) {
+	if conn != nil {
+		return conn, nil
+	}
+	return h.upgrader.Upgrade(w, r, nil)
+}
+
+// refuseSocket refuses a socket request: with an HTTP error before the upgrade, or
+// once upgraded with a close frame whose code is socketCloseCode(status)
+func refuseSocket(w http.ResponseWriter, conn *websocket.Conn, status int, message string) {
+	if conn == nil {
+		WriteError(w, status, message)
+		return
+	}
+	closeSocket(conn, socketCloseCode(status), message)
+}
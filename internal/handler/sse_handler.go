@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+// sseHeartbeatInterval keeps proxies from closing an idle event stream
+const sseHeartbeatInterval = 25 * time.Second
+
+// HandleRoomEvents streams room frames as Server-Sent Events, for clients that cannot
+// use WebSocket. Frames are the same JSON as on the socket; messages are sent with
+// HandleSendMessage. EventSource cannot set headers, so the token may be passed as ?token=.
+func (h *ChatHandler) HandleRoomEvents(w http.ResponseWriter, r *http.Request) {
+	roomCode := mux.Vars(r)["code"]
+
+	user, err := h.streamUser(r)
+	if err != nil {
+		WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	if !h.joinRoom(w, roomCode, user.Username) {
+		return
+	}
+
+	client := newSSEClient(h.sendPolicy)
+	if err := h.addConnection(roomCode, user.Username, client, previousGeneration(r)); err != nil {
+		refuseConnection(w, nil, err)
+		return
+	}
+	h.trackConnection(user, roomCode, client)
+	defer h.disconnect(roomCode, user.Username, client)
+
+	// The stream outlives the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.WithError(err).WithField("room", roomCode).Warn("Failed to clear write deadline for event stream")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	h.announceJoin(roomCode, user.Username)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-client.ready:
+			for data, ok := client.next(); ok; data, ok = client.next() {
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-client.done:
+			// A stream closed with a code still gets its error frame
+			for data, ok := client.next(); ok; data, ok = client.next() {
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			}
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// HandleSendMessage accepts a client frame over plain HTTP, for clients on the event
+// stream. Frames go through the same handling as WebSocket frames, so errors are
+// delivered as "error" frames on the member's open stream or socket.
+func (h *ChatHandler) HandleSendMessage(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	roomCode := mux.Vars(r)["code"]
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists {
+		WriteError(w, http.StatusNotFound, "Room not found")
+		return
+	}
+	if !room.HasUser(user.Username) {
+		WriteError(w, http.StatusForbidden, "Not a member of this room")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.messageService.MaxFrameSize()))
+	if err != nil {
+		WriteError(w, http.StatusRequestEntityTooLarge, "Message too large")
+		return
+	}
+
+	h.handleFrame(roomCode, user.Username, parseClientFrame(body))
+
+	WriteJSON(w, http.StatusAccepted, map[string]string{
+		"status": "accepted",
+	})
+}
+
+// streamUser authenticates an event stream from the Authorization header, the token
+// query parameter or the access token cookie
+func (h *ChatHandler) streamUser(r *http.Request) (*model.User, error) {
+	token := bearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		token = cookieValue(r, accessTokenCookie) // set by the cookie token transport
+	}
+	if token == "" {
+		return nil, fmt.Errorf("authentication token required")
+	}
+
+	return h.tokenUser(r.Context(), token)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/config"
+)
+
+// StaticHandler serves the single-page web app, see config.FrontendConfig. Paths that
+// name no file get index.html so client-side routes survive a reload, except paths
+// that look like files or belong to the API.
+type StaticHandler struct {
+	files        fs.FS
+	assetControl string
+}
+
+func NewStaticHandler(files fs.FS, config config.FrontendConfig) (*StaticHandler, error) {
+	if _, err := fs.Stat(files, "index.html"); err != nil {
+		return nil, fmt.Errorf("frontend build has no index.html: %w", err)
+	}
+	return &StaticHandler{
+		files:        files,
+		assetControl: "public, max-age=" + strconv.Itoa(int(config.AssetMaxAge.Seconds())) + ", immutable",
+	}, nil
+}
+
+func (h *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "api" || name == "ws" || strings.HasPrefix(name, "api/") || strings.HasPrefix(name, "ws/") {
+		WriteError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if info, err := fs.Stat(h.files, name); name == "" || err != nil || info.IsDir() {
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name = "index.html"
+	}
+
+	data, err := fs.ReadFile(h.files, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Builds put fingerprinted file names under static/; everything else, index.html
+	// above all, must be revalidated so a new release is picked up
+	if strings.HasPrefix(name, "static/") {
+		w.Header().Set("Cache-Control", h.assetControl)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
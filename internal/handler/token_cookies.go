@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+)
+
+// Cookies of the cookie token transport, see config.TokenTransportCookie
+const (
+	accessTokenCookie  = "chatmix_access"
+	refreshTokenCookie = "chatmix_refresh"
+	csrfCookie         = "chatmix_csrf" // readable by scripts, which echo it in csrfHeader
+	csrfHeader         = "X-CSRF-Token"
+)
+
+// refreshCookiePath keeps the refresh token away from every request but the auth endpoints
+const refreshCookiePath = "/api/auth"
+
+// tokenCookies delivers issued tokens as HttpOnly cookies instead of JSON body fields
+// when auth.token_transport is "cookie". Login also issues a CSRF token that
+// state-changing requests authenticated by cookie must send back in X-CSRF-Token.
+type tokenCookies struct {
+	enabled    bool
+	domain     string
+	secure     bool
+	sameSite   http.SameSite
+	refreshTTL time.Duration
+}
+
+func newTokenCookies(cfg config.AuthConfig) tokenCookies {
+	sameSite := http.SameSiteLaxMode
+	switch cfg.Cookies.SameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return tokenCookies{
+		enabled:    cfg.TokenTransport == config.TokenTransportCookie,
+		domain:     cfg.Cookies.Domain,
+		secure:     !cfg.Cookies.Insecure,
+		sameSite:   sameSite,
+		refreshTTL: time.Duration(cfg.RefreshTokenExpiry) * time.Hour,
+	}
+}
+
+// deliver moves the tokens of a successful auth response into cookies and adds a new CSRF token
+func (c tokenCookies) deliver(w http.ResponseWriter, response *model.AuthResponse) error {
+	if !c.enabled || response.Token == "" {
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(buf)
+	refreshExpiry := time.Now().Add(c.refreshTTL)
+
+	http.SetCookie(w, c.cookie(accessTokenCookie, response.Token, "/", response.ExpiresAt, true))
+	http.SetCookie(w, c.cookie(refreshTokenCookie, response.RefreshToken, refreshCookiePath, refreshExpiry, true))
+	http.SetCookie(w, c.cookie(csrfCookie, csrfToken, "/", refreshExpiry, false))
+
+	response.Token = ""
+	response.RefreshToken = ""
+	response.CSRFToken = csrfToken
+	return nil
+}
+
+// clear removes the token cookies, on logout
+func (c tokenCookies) clear(w http.ResponseWriter) {
+	if !c.enabled {
+		return
+	}
+
+	expired := time.Unix(0, 0)
+	http.SetCookie(w, c.cookie(accessTokenCookie, "", "/", expired, true))
+	http.SetCookie(w, c.cookie(refreshTokenCookie, "", refreshCookiePath, expired, true))
+	http.SetCookie(w, c.cookie(csrfCookie, "", "/", expired, false))
+}
+
+func (c tokenCookies) cookie(name, value, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   c.domain,
+		Expires:  expires,
+		Secure:   c.secure,
+		HttpOnly: httpOnly,
+		SameSite: c.sameSite,
+	}
+}
+
+// cookieValue returns the value of a request cookie, or "" when it is not set
+func cookieValue(r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// validCSRF reports whether a request authenticated by cookie may proceed: safe methods
+// always may, others must echo the CSRF cookie in the X-CSRF-Token header
+func validCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	expected := cookieValue(r, csrfCookie)
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(r.Header.Get(csrfHeader))) == 1
+}
+
+// sameOrigin reports whether a browser request comes from a page of this host. Sockets
+// must check it before trusting cookies, since browsers attach them cross-site.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
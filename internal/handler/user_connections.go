@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// userConnections indexes the room and channel connections by user ID, so frames
+// addressed to a user reach every device of theirs whatever room each one is in
+type userConnections struct {
+	lock   sync.RWMutex
+	byUser map[string]map[roomClient]string // user ID -> connection -> room code
+	owners map[roomClient]connOwner
+}
+
+type connOwner struct {
+	userID   string
+	username string
+}
+
+func newUserConnections() *userConnections {
+	return &userConnections{
+		byUser: make(map[string]map[roomClient]string),
+		owners: make(map[roomClient]connOwner),
+	}
+}
+
+// add records a connection of the user in the room and reports whether it is the user's
+// first open connection
+func (c *userConnections) add(user *model.User, roomCode string, client roomClient) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	userID := user.ID.Hex()
+	conns := c.byUser[userID]
+	if conns == nil {
+		conns = make(map[roomClient]string)
+		c.byUser[userID] = conns
+	}
+	conns[client] = roomCode
+	c.owners[client] = connOwner{userID: userID, username: user.Username}
+	return len(conns) == 1
+}
+
+// remove forgets a connection and returns its owner, and whether it was the owner's last
+// open connection. ok is false for connections add did not record.
+func (c *userConnections) remove(client roomClient) (owner connOwner, last, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	owner, ok = c.owners[client]
+	if !ok {
+		return owner, false, false
+	}
+	delete(c.owners, client)
+
+	conns := c.byUser[owner.userID]
+	delete(conns, client)
+	if len(conns) == 0 {
+		delete(c.byUser, owner.userID)
+		return owner, true, true
+	}
+	return owner, false, true
+}
+
+// of returns the open connections of a user with their room codes
+func (c *userConnections) of(userID string) map[roomClient]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	conns := make(map[roomClient]string, len(c.byUser[userID]))
+	for client, roomCode := range c.byUser[userID] {
+		conns[client] = roomCode
+	}
+	return conns
+}
+
+// OnPresence registers a callback invoked when a user opens their first chat connection,
+// on any device, and when they close their last one. Register callbacks before the
+// handler serves requests.
+func (h *ChatHandler) OnPresence(fn func(userID, username string, online bool)) {
+	h.presence = append(h.presence, fn)
+}
+
+// trackConnection records an accepted room or channel connection of the user
+func (h *ChatHandler) trackConnection(user *model.User, roomCode string, client roomClient) {
+	if h.users.add(user, roomCode, client) {
+		for _, fn := range h.presence {
+			fn(user.ID.Hex(), user.Username, true)
+		}
+	}
+}
+
+// untrackConnection forgets a closed connection recorded by trackConnection
+func (h *ChatHandler) untrackConnection(client roomClient) {
+	owner, last, ok := h.users.remove(client)
+	if !ok || !last {
+		return
+	}
+	for _, fn := range h.presence {
+		fn(owner.userID, owner.username, false)
+	}
+}
+
+// IsConnected reports whether the user has an open chat connection on any device
+func (h *ChatHandler) IsConnected(userID string) bool {
+	return len(h.users.of(userID)) > 0
+}
+
+// pushToUser sends a frame to every connection of the user, in whichever room, and
+// returns how many connections took it
+func (h *ChatHandler) pushToUser(userID string, message ChatMessage) int {
+	conns := h.users.of(userID)
+	if len(conns) == 0 {
+		return 0
+	}
+
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to marshal frame")
+		return 0
+	}
+
+	sent := 0
+	for client, roomCode := range conns {
+		if !client.Accepts(message.Type) {
+			continue
+		}
+		if !client.Send(messageBytes) {
+			h.logger.WithFields(logrus.Fields{"room": roomCode, "user_id": userID}).Debug("Dropping client: closed or too slow")
+			client.Close()
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// KickUser closes every connection of a user a moderator kicked with CloseKicked and
+// tells the room they were removed from, see event.UserKicked. The connections are
+// dropped first, so closing them does not announce that the user left as well.
+func (h *ChatHandler) KickUser(userID, username, roomCode string) {
+	conns := h.users.of(userID)
+
+	h.connLock.Lock()
+	for client, code := range conns {
+		if roomConns := h.connections[code]; roomConns[username] == client {
+			delete(roomConns, username)
+			if len(roomConns) == 0 {
+				delete(h.connections, code)
+			}
+			h.clearMutes(code, username)
+		}
+	}
+	h.connLock.Unlock()
+
+	for client := range conns {
+		client.CloseWith(CloseKicked, "kicked")
+	}
+
+	if roomCode == "" {
+		return
+	}
+	h.leaveGame(roomCode, username)
+	h.dropRoomBuffer(roomCode)
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "system",
+		Text:      username + " đã rời khỏi phòng chat (bị quản trị viên mời ra)",
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
@@ -1,44 +1,157 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
 	"chatmix-backend/internal/service"
+	"chatmix-backend/pkg/chatbot"
+	"chatmix-backend/pkg/games"
+	"chatmix-backend/pkg/geoip"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
 )
 
 type ChatHandler struct {
-	chatService service.ChatService
-	authService service.AuthService
-	upgrader    websocket.Upgrader
-	connections map[string]map[string]*websocket.Conn // connections maps roomCode -> username -> websocket connection
-	connLock    sync.RWMutex
+	chatService        service.ChatService
+	authService        service.AuthService
+	translationService service.TranslationService
+	messageService     service.MessageService
+	auditService       service.AuditService
+	icebreakerService  service.IcebreakerService
+	channelService     service.ChannelService
+	bot                chatbot.Bot
+	commands           *CommandRegistry
+	locator            geoip.Locator
+	wsConfig           config.WebSocketConfig
+	sendPolicy         sendPolicy
+	logger             *logrus.Logger
+	upgrader           websocket.Upgrader
+	connections        map[string]map[string]roomClient // connections maps roomCode -> username -> client (WebSocket, SSE or long-poll)
+	observers          map[string]map[roomClient]string // observers maps roomCode -> hidden moderator connection -> username
+	buffers            map[string]*frameBuffer          // buffers maps roomCode -> recent frames for long-poll clients
+	mutes              map[string]roomMutes             // mutes maps roomCode -> username -> muted member -> since
+	games              map[string]*roomGame             // games maps roomCode -> mini-game of the room
+	generation         uint64                           // generation of the last room connection, guarded by connLock
+	closing            bool                             // set by Shutdown, guarded by connLock
+	connLock           sync.RWMutex
+
+	// users indexes the room and channel connections by user ID
+	users *userConnections
+	// presence are the callbacks of OnPresence
+	presence []func(userID, username string, online bool)
 }
 
 type ChatMessage struct {
-	Type      string `json:"type"`
-	From      string `json:"from"`
-	Text      string `json:"text"`
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	From         string `json:"from"`
+	Text         string `json:"text"`
+	Translation  string `json:"translation,omitempty"`
+	TranslatedTo string `json:"translated_to,omitempty"`
+	Flagged      bool   `json:"flagged,omitempty"` // profanity was masked, clients may blur the message
+	Bot          bool   `json:"bot,omitempty"`     // sent by or about a bot participant
+	EditedAt     int64  `json:"edited_at,omitempty"`
+	Code         int    `json:"code,omitempty"`       // close code of an "error" frame, see CloseAuthFailed
+	Generation   uint64 `json:"generation,omitempty"` // connection generation of a "session" frame
+	// Seq numbers the frames broadcast to a room, one by one from 1, so clients can spot a
+	// gap and send a resync frame. Frames sent to a single member have none, except
+	// "resync" frames, where it is the room's latest sequence number.
+	Seq       uint64 `json:"seq,omitempty"`
 	Timestamp int64  `json:"timestamp"`
+
+	Notification *model.Notification    `json:"notification,omitempty"`
+	Partner      map[string]interface{} `json:"partner,omitempty"` // partner card of a "partner_info" frame
+	History      []ChatMessage          `json:"history,omitempty"` // messages of a "history" frame, oldest first
+	// Frames are the missed room frames of a "resync" frame. Missed means the client fell
+	// too far behind for them, and History holds the stored room messages instead.
+	Frames []json.RawMessage `json:"frames,omitempty"`
+	Missed bool              `json:"missed,omitempty"`
+
+	Invite string       `json:"invite,omitempty"` // game of a "game_invite" frame
+	Game   *games.State `json:"game,omitempty"`   // state of a "game_state" frame
+
+	Features []string `json:"features,omitempty"` // negotiated features of a "hello_ack" frame
+	Version  int      `json:"version,omitempty"`  // protocol version of a "hello_ack" frame
 }
 
-func NewChatHandler(chatService service.ChatService, authService service.AuthService) *ChatHandler {
+// ClientFrame is a frame sent by the client. Plain text frames are treated as messages.
+type ClientFrame struct {
+	// message, edit, delete, mute, unmute, resync, game_invite, game_move, game_state,
+	// hello; auth as the first frame of an unauthenticated socket
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Text    string          `json:"text,omitempty"`
+	Token   string          `json:"token,omitempty"`   // access token of an auth frame
+	Target  string          `json:"target,omitempty"`  // member of a mute or unmute frame, the partner when empty
+	Seq     uint64          `json:"seq,omitempty"`     // last sequence number the client received, of a resync frame
+	Game    string          `json:"game,omitempty"`    // game of a game_invite frame, tictactoe when empty
+	Decline bool            `json:"decline,omitempty"` // declines the partner's game_invite
+	Move    json.RawMessage `json:"move,omitempty"`    // move of a game_move frame, in the shape of the game
+	// Features are the features a hello frame declares, see featureGames
+	Features []string `json:"features,omitempty"`
+}
+
+func parseClientFrame(data []byte) ClientFrame {
+	var frame ClientFrame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Type == "" {
+		return ClientFrame{Type: "message", Text: string(data)}
+	}
+	return frame
+}
+
+func NewChatHandler(
+	chatService service.ChatService,
+	authService service.AuthService,
+	translationService service.TranslationService,
+	messageService service.MessageService,
+	auditService service.AuditService,
+	icebreakerService service.IcebreakerService,
+	channelService service.ChannelService,
+	bot chatbot.Bot,
+	commands *CommandRegistry,
+	locator geoip.Locator,
+	wsConfig config.WebSocketConfig,
+	logger *logrus.Logger,
+) *ChatHandler {
 	return &ChatHandler{
-		chatService: chatService,
-		authService: authService,
+		chatService:        chatService,
+		authService:        authService,
+		translationService: translationService,
+		messageService:     messageService,
+		auditService:       auditService,
+		icebreakerService:  icebreakerService,
+		channelService:     channelService,
+		bot:                bot,
+		commands:           commands,
+		locator:            locator,
+		wsConfig:           wsConfig,
+		sendPolicy:         newSendPolicy(wsConfig),
+		logger:             logger,
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: wsConfig.Compression,
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for development
 			},
 		},
-		connections: make(map[string]map[string]*websocket.Conn),
+		connections: make(map[string]map[string]roomClient),
+		observers:   make(map[string]map[roomClient]string),
+		buffers:     make(map[string]*frameBuffer),
+		mutes:       make(map[string]roomMutes),
+		games:       make(map[string]*roomGame),
+		users:       newUserConnections(),
 	}
 }
 
@@ -48,195 +161,1122 @@ func (h *ChatHandler) HandleStartChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username := r.URL.Query().Get("username")
-	if username == "" {
-		WriteError(w, http.StatusBadRequest, "username required")
+	username, ok := chatUsername(w, r)
+	if !ok {
 		return
 	}
 
-	response, err := h.chatService.StartChat(username)
+	response, err := h.chatService.StartChat(username, h.matchPreferences(r))
 	if err != nil {
-		log.Printf("Error starting chat: %v", err)
+		h.logger.WithError(err).WithField("user", username).Error("Failed to start chat")
 		WriteError(w, http.StatusInternalServerError, "failed to start chat")
 		return
 	}
 
+	if response.Status == model.ChatStatusAtCapacity {
+		if response.EstimatedWaitSeconds > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(response.EstimatedWaitSeconds))
+		}
+		WriteJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	if response.Status == model.ChatStatusQuotaExceeded {
+		if wait := time.Until(response.Quota.ResetAt); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		WriteJSON(w, http.StatusTooManyRequests, response)
+		return
+	}
+
+	if response.Status == model.ChatStatusSuspended {
+		if wait := time.Until(*response.SuspendedUntil); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		WriteJSON(w, http.StatusForbidden, response)
+		return
+	}
+
+	user := r.Context().Value("user").(*model.User)
+	h.auditService.Record(r.Context(), model.NewAuditLog(user, model.AuditActionChatStart, response.RoomCode, clientIP(r)))
+
 	WriteJSON(w, http.StatusOK, response)
 }
 
+// chatUsername returns the authenticated user's username, which is the identity of the
+// chat endpoints. The username parameter older clients send is optional and must match.
+func chatUsername(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return "", false
+	}
+	if query := r.URL.Query().Get("username"); query != "" && query != user.Username {
+		WriteError(w, http.StatusForbidden, "username does not match the authenticated user")
+		return "", false
+	}
+	return user.Username, true
+}
+
+// matchPreferences builds matchmaking preferences from the query string,
+// falling back to the authenticated user's profile
+func (h *ChatHandler) matchPreferences(r *http.Request) model.MatchPreferences {
+	var prefs model.MatchPreferences
+
+	user, _ := r.Context().Value("user").(*model.User)
+	if languages := r.URL.Query().Get("languages"); languages != "" {
+		prefs.Languages = strings.Split(languages, ",")
+	} else if user != nil {
+		prefs.Languages = user.Languages
+	}
+	if user != nil {
+		prefs.Translate = user.TranslateOptIn
+		prefs.ContentFilter = user.EffectiveContentFilter()
+		prefs.Priority = user.QueuePriority()
+		prefs.Premium = user.HasPremium()
+		prefs.UserID = user.ID.Hex()
+		prefs.Age = user.Age
+	}
+
+	// Location requirements only apply when the caller's own location is known
+	if h.locator != nil {
+		if loc, err := h.locator.Lookup(clientIP(r)); err == nil && loc != nil {
+			prefs.Country = loc.Country
+			prefs.TimeZone = loc.TimeZone
+			prefs.SameCountry = r.URL.Query().Get("same_country") == "true"
+		}
+	}
+	// A time zone set in the profile beats the GeoIP guess
+	if user != nil && user.TimeZone != "" {
+		prefs.TimeZone = user.TimeZone
+	}
+	prefs.SameTimeZone = r.URL.Query().Get("same_timezone") == "true" && prefs.TimeZone != ""
+
+	return prefs
+}
+
+// HandleRoomHistory returns the stored messages of a room to its members,
+// with edited and deleted messages in their current state
+func (h *ChatHandler) HandleRoomHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	roomCode := mux.Vars(r)["code"]
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists || !room.HasUser(user.Username) {
+		participated, err := h.messageService.HasParticipated(ctx, roomCode, user.Username)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "Failed to get room history")
+			return
+		}
+		if !participated {
+			WriteError(w, http.StatusForbidden, "Not a member of this room")
+			return
+		}
+	}
+
+	messages, err := h.messageService.GetRoomHistory(ctx, roomCode)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Failed to get room history")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"room":     roomCode,
+		"messages": visibleMessages(messages, user.Username),
+	})
+}
+
+// visibleMessages drops the shadowed messages of other users, see model.Message.IsVisibleTo
+func visibleMessages(messages []*model.Message, username string) []*model.Message {
+	visible := make([]*model.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.IsVisibleTo(username) {
+			visible = append(visible, message)
+		}
+	}
+	return visible
+}
+
+// HandleDeleteMyMessages erases the caller's messages from the history of a finished
+// room; the partner's messages stay
+func (h *ChatHandler) HandleDeleteMyMessages(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	roomCode := mux.Vars(r)["code"]
+	if _, live := h.chatService.GetRoom(roomCode); live {
+		WriteError(w, http.StatusConflict, "Room is still active")
+		return
+	}
+	h.deleteMyMessages(w, r, user, roomCode)
+}
+
+// HandleDeleteHistory erases every message the caller sent, in every room and channel.
+// The caller must not be in a chat.
+func (h *ChatHandler) HandleDeleteHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	if _, inRoom := h.chatService.CurrentRoom(user.Username); inRoom {
+		WriteError(w, http.StatusConflict, "Leave the current chat first")
+		return
+	}
+	h.deleteMyMessages(w, r, user, "")
+}
+
+func (h *ChatHandler) deleteMyMessages(w http.ResponseWriter, r *http.Request, user *model.User, roomCode string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := h.messageService.DeleteOwnMessages(ctx, user.Username, roomCode)
+	if errors.Is(err, service.ErrLegalHold) {
+		WriteError(w, http.StatusConflict, "Your messages are kept for a moderation case and cannot be deleted now")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to delete messages")
+		WriteError(w, http.StatusInternalServerError, "Failed to delete messages")
+		return
+	}
+
+	entry := model.NewAuditLog(user, model.AuditActionHistoryDelete, roomCode, clientIP(r))
+	entry.Details = map[string]interface{}{"deleted": deleted}
+	h.auditService.Record(ctx, entry)
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"deleted": deleted,
+	})
+}
+
 func (h *ChatHandler) HandleQueueStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
-	username := r.URL.Query().Get("username")
-	if username == "" {
-		WriteError(w, http.StatusBadRequest, "username required")
+	username, ok := chatUsername(w, r)
+	if !ok {
 		return
 	}
 
 	position := h.chatService.GetQueuePosition(username)
 	queueSize := h.chatService.GetQueueSize()
 
+	priority := model.QueuePriorityNormal
+	if user, ok := r.Context().Value("user").(*model.User); ok {
+		priority = user.QueuePriority()
+	}
+
 	WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"in_queue":   position > 0,
-		"position":   position,
-		"queue_size": queueSize,
+		"in_queue":               position > 0,
+		"position":               position,
+		"priority":               priority,
+		"queue_size":             queueSize,
+		"estimated_wait_seconds": int(h.chatService.EstimateWait(position).Seconds()),
 	})
 }
 
-func (h *ChatHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	roomCode := r.URL.Query().Get("room")
-	username := r.URL.Query().Get("username")
-	token := r.URL.Query().Get("token")
+// HandleCapacity returns how busy matchmaking is, for clients to show before a user
+// starts a chat. It needs no authentication.
+func (h *ChatHandler) HandleCapacity(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, h.chatService.Capacity())
+}
+
+// HandleCurrentRoom returns the room the authenticated user is currently in, with a new
+// room token to reconnect with
+func (h *ChatHandler) HandleCurrentRoom(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	room, inRoom := h.chatService.CurrentRoom(user.Username)
+	if !inRoom {
+		WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"in_room": false,
+		})
+		return
+	}
+
+	token, err := h.chatService.RoomToken(room.Code, user.Username)
+	if err != nil {
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to issue room token")
+		WriteError(w, http.StatusInternalServerError, "Failed to issue room token")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"in_room":    true,
+		"room":       room.Code,
+		"room_token": token,
+		"users":      room.Users,
+		"waiting":    room.IsWaiting(),
+		"bot":        room.HasBot(),
+		"language":   room.Language,
+		"created_at": room.CreatedAt,
+	})
+}
+
+// HandleRoomPartner returns the partner card of the user's room, see model.User.ToPartnerCard
+func (h *ChatHandler) HandleRoomPartner(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
 
-	if roomCode == "" || username == "" {
-		WriteError(w, http.StatusBadRequest, "room and username required")
+	user, ok := r.Context().Value("user").(*model.User)
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	partner, err := h.chatService.GetPartner(ctx, mux.Vars(r)["code"], user.Username)
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound), errors.Is(err, service.ErrNoPartner):
+		WriteError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrNotRoomMember):
+		WriteError(w, http.StatusForbidden, "Not a member of this room")
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("user", user.Username).Error("Failed to get room partner")
+		WriteError(w, http.StatusInternalServerError, "Failed to get room partner")
 		return
 	}
 
+	WriteJSON(w, http.StatusOK, partner.ToPartnerCard())
+}
+
+// HandleWebSocket connects a user to their room. The room is named by the room_token
+// query parameter from StartChat, checked before the upgrade; the room code alone, which
+// could be guessed, is not accepted.
+func (h *ChatHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("room_token")
 	if token == "" {
-		WriteError(w, http.StatusUnauthorized, "authentication token required")
+		WriteError(w, http.StatusBadRequest, "room_token required")
+		return
+	}
+	roomCode, tokenUser, err := h.chatService.VerifyRoomToken(token)
+	if err != nil {
+		WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if room := r.URL.Query().Get("room"); room != "" && room != roomCode {
+		WriteError(w, http.StatusForbidden, "room does not match the room token")
+		return
+	}
+	codec, err := socketCodec(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, conn, ok := h.authenticateSocket(w, r)
+	if !ok {
+		return
+	}
+
+	// The username parameter is optional; the room member is the token owner
+	username := user.Username
+	if query := r.URL.Query().Get("username"); query != "" && query != username {
+		refuseSocket(w, conn, http.StatusForbidden, "username does not match the token")
+		return
+	}
+	if tokenUser != username {
+		refuseSocket(w, conn, http.StatusForbidden, "room token was issued to another user")
 		return
 	}
 
 	// Verify room exists and user can join
-	if err := h.chatService.JoinRoom(roomCode, username); err != nil {
-		log.Printf("Error joining room: %v", err)
-		WriteError(w, http.StatusForbidden, err.Error())
+	if status, err := h.tryJoinRoom(roomCode, username); err != nil {
+		if conn != nil && errors.Is(err, service.ErrRoomFull) {
+			closeSocket(conn, CloseRoomFull, err.Error())
+			return
+		}
+		refuseSocket(w, conn, status, err.Error())
 		return
 	}
 
 	// Upgrade to WebSocket
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	conn, err = h.upgradeSocket(w, r, conn)
+	if err != nil {
+		h.logger.WithError(err).WithField("room", roomCode).Warn("WebSocket upgrade failed")
+		return
+	}
+
+	client := newWSClient(conn, h.sendPolicy, codec, protocolWarning(r))
+	if err := h.addConnection(roomCode, username, client, previousGeneration(r)); err != nil {
+		refuseConnection(w, conn, err)
+		client.Close()
+		return
+	}
+	h.trackConnection(user, roomCode, client)
+
+	h.handleConnection(roomCode, username, client)
+}
+
+// joinRoom joins the user to the room, writing the error response when that is not allowed
+func (h *ChatHandler) joinRoom(w http.ResponseWriter, roomCode, username string) bool {
+	if status, err := h.tryJoinRoom(roomCode, username); err != nil {
+		WriteError(w, status, err.Error())
+		return false
+	}
+	return true
+}
+
+// tryJoinRoom joins the user to the room, returning the HTTP status of a refusal
+func (h *ChatHandler) tryJoinRoom(roomCode, username string) (int, error) {
+	err := h.chatService.JoinRoom(roomCode, username)
+	if err == nil {
+		h.roomBuffer(roomCode, true)
+		return http.StatusOK, nil
+	}
+
+	h.logger.WithError(err).WithFields(logrus.Fields{"room": roomCode, "user": username}).Debug("Join room refused")
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, service.ErrAlreadyInRoom):
+		return http.StatusConflict, err
+	default:
+		return http.StatusForbidden, err
+	}
+}
+
+// HandleObserveRoom lets a moderator join a room as a hidden, read-only observer.
+// Members are not notified; every observation is recorded in the audit log.
+func (h *ChatHandler) HandleObserveRoom(w http.ResponseWriter, r *http.Request) {
+	roomCode := mux.Vars(r)["code"]
+	codec, err := socketCodec(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, conn, ok := h.authenticateSocket(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.IsStaff() {
+		refuseSocket(w, conn, http.StatusForbidden, "insufficient permissions")
+		return
+	}
+
+	if _, exists := h.chatService.GetRoom(roomCode); !exists {
+		refuseSocket(w, conn, http.StatusNotFound, "room not found")
+		return
+	}
+
+	conn, err = h.upgradeSocket(w, r, conn)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		h.logger.WithError(err).WithField("room", roomCode).Warn("WebSocket upgrade failed")
 		return
 	}
 
-	// Add connection
-	h.addConnection(roomCode, username, conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	h.auditService.Record(ctx, model.NewAuditLog(user, model.AuditActionObserveRoom, roomCode, clientIP(r)))
+	cancel()
+
+	client := newWSClient(conn, h.sendPolicy, codec, protocolWarning(r))
+	h.connLock.Lock()
+	if h.observers[roomCode] == nil {
+		h.observers[roomCode] = make(map[roomClient]string)
+	}
+	h.observers[roomCode][client] = user.Username
+	h.connLock.Unlock()
+
+	defer func() {
+		client.Close()
+		h.removeObserver(roomCode, client)
+	}()
 
-	h.handleConnection(roomCode, username, conn)
+	// Observers are read-only apart from hello frames: drain incoming frames until the
+	// socket closes
+	conn.SetReadLimit(512)
+	for {
+		data, err := client.readFrame()
+		if errors.Is(err, errFrameEncoding) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if frame := parseClientFrame(data); frame.Type == "hello" {
+			h.handleHelloFrame(client, frame)
+		}
+	}
 }
 
-func (h *ChatHandler) addConnection(roomCode, username string, conn *websocket.Conn) {
+func (h *ChatHandler) removeObserver(roomCode string, client roomClient) {
 	h.connLock.Lock()
 	defer h.connLock.Unlock()
 
-	if h.connections[roomCode] == nil {
-		h.connections[roomCode] = make(map[string]*websocket.Conn)
+	if roomObservers := h.observers[roomCode]; roomObservers != nil {
+		delete(roomObservers, client)
+		if len(roomObservers) == 0 {
+			delete(h.observers, roomCode)
+		}
 	}
+}
 
-	// Close existing connection if any
-	if oldConn := h.connections[roomCode][username]; oldConn != nil {
-		oldConn.Close()
+// Close reasons of duplicate room connections, sent with CloseConflict
+const (
+	closeSessionReplaced = "session_replaced" // a newer connection of the user took the seat
+	closeSessionStale    = "session_stale"    // the connection lost the seat to a newer one earlier
+	closeSessionExists   = "session_exists"   // duplicate_policy oldest keeps the open connection
+)
+
+var (
+	errSessionStale  = errors.New(closeSessionStale)
+	errSessionExists = errors.New(closeSessionExists)
+)
+
+// addConnection gives the client the user's seat in the room and tells it its generation.
+// When the user is already connected, the newest connection wins unless
+// websocket.duplicate_policy is oldest; the replaced client is closed with
+// session_replaced. previous is the generation the client held before reconnecting, 0
+// for a first connection: a client whose previous connection was itself replaced is
+// refused with errSessionStale, so a stale tab reconnecting cannot kick the active one.
+func (h *ChatHandler) addConnection(roomCode, username string, client roomClient, previous uint64) error {
+	replaced, err := h.replaceConnection(roomCode, username, client, previous)
+	if err != nil {
+		return err
 	}
 
-	h.connections[roomCode][username] = conn
+	// Closing a socket writes a close frame, which must not hold up everyone else
+	// waiting for connLock
+	if replaced != nil {
+		replaced.CloseWith(CloseConflict, closeSessionReplaced)
+		h.logger.WithFields(logrus.Fields{
+			"room":       roomCode,
+			"user":       username,
+			"generation": replaced.Generation(),
+		}).Debug("Replaced room connection")
+	}
+	return nil
 }
 
-func (h *ChatHandler) removeConnection(roomCode, username string) {
+// replaceConnection stores the client for addConnection and returns the connection it
+// replaced, if any, for the caller to close
+func (h *ChatHandler) replaceConnection(roomCode, username string, client roomClient, previous uint64) (roomClient, error) {
 	h.connLock.Lock()
 	defer h.connLock.Unlock()
 
-	if roomConns := h.connections[roomCode]; roomConns != nil {
-		delete(roomConns, username)
-		if len(roomConns) == 0 {
-			delete(h.connections, roomCode)
+	if h.closing {
+		return nil, errServerShutdown
+	}
+	if h.connections[roomCode] == nil {
+		h.connections[roomCode] = make(map[string]roomClient)
+	}
+
+	// Whichever transport the open connection uses
+	current := h.connections[roomCode][username]
+	if current != nil {
+		_, polling := current.(*pollClient)
+		switch {
+		case polling:
+			// A long-poll member switching to a socket, whatever the policy
+		case h.wsConfig.DuplicatePolicy == config.DuplicateOldest:
+			return nil, errSessionExists
+		case previous != 0 && previous < current.Generation():
+			return nil, errSessionStale
 		}
 	}
 
+	h.generation++
+	client.setGeneration(h.generation)
+	h.connections[roomCode][username] = client
+
+	if data, err := json.Marshal(ChatMessage{Type: "session", Generation: h.generation, Timestamp: time.Now().UnixMilli()}); err == nil {
+		client.Send(data)
+	}
+	return current, nil
+}
+
+// previousGeneration returns the generation query parameter of a reconnecting client
+func previousGeneration(r *http.Request) uint64 {
+	generation, _ := strconv.ParseUint(r.URL.Query().Get("generation"), 10, 64)
+	return generation
+}
+
+// removeConnection drops the client and leaves the room. It reports false when the
+// client was already replaced by a newer connection of the same user, which keeps the seat.
+func (h *ChatHandler) removeConnection(roomCode, username string, client roomClient) bool {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	roomConns := h.connections[roomCode]
+	if roomConns == nil || roomConns[username] != client {
+		return false
+	}
+
+	delete(roomConns, username)
+	if len(roomConns) == 0 {
+		delete(h.connections, roomCode)
+	}
+	h.clearMutes(roomCode, username)
+
 	// Remove user from room in service
 	h.chatService.LeaveRoom(roomCode, username)
+	return true
 }
 
-func (h *ChatHandler) handleConnection(roomCode, username string, conn *websocket.Conn) {
-	defer func() {
-		conn.Close()
-		h.removeConnection(roomCode, username)
-	}()
+// disconnect removes a closed client and tells the room the user has left
+func (h *ChatHandler) disconnect(roomCode, username string, client roomClient) {
+	client.Close()
+	h.untrackConnection(client)
+	if h.isClosing() || !h.removeConnection(roomCode, username, client) {
+		return
+	}
+	h.leaveGame(roomCode, username)
+	h.dropRoomBuffer(roomCode)
 
-	// Set connection limits
-	conn.SetReadLimit(512)
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "system",
+		Text:      username + " đã rời khỏi phòng chat",
+		Timestamp: time.Now().UnixMilli(),
 	})
+}
 
-	// Start ping routine
-	go h.pingRoutine(conn)
+// ExpireMember tells a room that a member restored from a snapshot did not reconnect,
+// see event.RoomMemberExpired
+func (h *ChatHandler) ExpireMember(roomCode, username string) {
+	h.dropRoomBuffer(roomCode)
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "system",
+		Text:      username + " đã rời khỏi phòng chat",
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
 
-	// Send welcome message
+func (h *ChatHandler) announceJoin(roomCode, username string) {
+	h.replayHistory(roomCode, username)
 	h.broadcastToRoom(roomCode, ChatMessage{
 		Type:      "system",
 		Text:      username + " đã vào phòng chat",
 		Timestamp: time.Now().UnixMilli(),
 	})
+	h.sendPartnerInfo(roomCode)
+	h.sendIcebreaker(roomCode)
+	h.greetFromBot(roomCode)
+	h.resumeGame(roomCode, username)
+}
+
+// sendPartnerInfo sends each member of a full room the card of their partner
+func (h *ChatHandler) sendPartnerInfo(roomCode string) {
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists || !room.IsFull() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, member := range room.Users {
+		if model.IsBotUsername(member) {
+			continue
+		}
+
+		partner, err := h.chatService.GetPartner(ctx, roomCode, member)
+		if err != nil {
+			if !errors.Is(err, service.ErrNoPartner) && !errors.Is(err, service.ErrNotRoomMember) {
+				h.logger.WithError(err).WithFields(logrus.Fields{"room": roomCode, "user": member}).Error("Failed to get room partner")
+			}
+			continue
+		}
+
+		h.sendToUser(roomCode, member, ChatMessage{
+			Type:      "partner_info",
+			Partner:   partner.ToPartnerCard(),
+			Timestamp: time.Now().UnixMilli(),
+		})
+	}
+}
+
+// replayHistory sends the joining member the latest room messages before live frames.
+// A message sent while the history is loaded may arrive twice; clients dedupe by id.
+func (h *ChatHandler) replayHistory(roomCode, username string) {
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := h.messageService.GetReplay(ctx, room)
+	if err != nil {
+		h.logger.WithError(err).WithField("room", roomCode).Error("Failed to load history replay")
+		return
+	}
+	messages = visibleMessages(messages, username)
+	if len(messages) == 0 {
+		return
+	}
+
+	h.sendToUser(roomCode, username, ChatMessage{
+		Type:      "history",
+		History:   historyMessages(messages),
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// historyMessages renders stored messages as the message frames of a "history" or
+// "resync" frame
+func historyMessages(messages []*model.Message) []ChatMessage {
+	history := make([]ChatMessage, len(messages))
+	for i, message := range messages {
+		history[i] = ChatMessage{
+			Type:      "message",
+			ID:        message.ID.Hex(),
+			From:      message.From,
+			Text:      message.Text,
+			Flagged:   message.Flagged,
+			Bot:       model.IsBotUsername(message.From),
+			Timestamp: message.CreatedAt.UnixMilli(),
+		}
+		if message.EditedAt != nil {
+			history[i].EditedAt = message.EditedAt.UnixMilli()
+		}
+	}
+	return history
+}
+
+// sendIcebreaker sends a random conversation prompt once the room is full.
+// The room remembers the prompt, so reconnects do not get another one.
+func (h *ChatHandler) sendIcebreaker(roomCode string) {
+	if !h.icebreakerService.Enabled() {
+		return
+	}
+
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists || !room.IsFull() || room.IcebreakerID != "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prompt, err := h.icebreakerService.Pick(ctx, room.Language)
+	if err != nil {
+		h.logger.WithError(err).WithField("room", roomCode).Error("Failed to pick icebreaker")
+		return
+	}
+	if prompt == nil || !h.chatService.SetIcebreaker(roomCode, prompt.ID.Hex()) {
+		return
+	}
+
+	h.icebreakerService.RecordUse(ctx, prompt)
+	h.broadcastToRoom(roomCode, ChatMessage{
+		Type:      "icebreaker",
+		ID:        prompt.ID.Hex(),
+		Text:      prompt.Text,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+func (h *ChatHandler) handleConnection(roomCode, username string, client *wsClient) {
+	defer h.disconnect(roomCode, username, client)
+
+	conn := client.conn
+	// Set connection limits (leaves room for the JSON frame envelope)
+	conn.SetReadLimit(h.messageService.MaxFrameSize())
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	// Send welcome message
+	h.announceJoin(roomCode, username)
 
 	// Message reading loop
+	limiter := newFrameLimiter(h.wsConfig.FrameRate, h.wsConfig.FrameBurst)
 	for {
-		_, messageBytes, err := conn.ReadMessage()
+		messageBytes, err := client.readFrame()
+		if errors.Is(err, errFrameEncoding) {
+			h.sendError(roomCode, username, err)
+			continue
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				h.logger.WithError(err).WithFields(logrus.Fields{"room": roomCode, "user": username}).Warn("WebSocket closed unexpectedly")
 			}
 			break
 		}
 
-		// Broadcast message to room
-		message := ChatMessage{
-			Type:      "message",
-			From:      username,
-			Text:      string(messageBytes),
-			Timestamp: time.Now().UnixMilli(),
+		if !h.allowFrame(roomCode, username, client, limiter) {
+			continue
+		}
+		if frame := parseClientFrame(messageBytes); frame.Type == "hello" {
+			h.handleHelloFrame(client, frame)
+		} else {
+			h.handleFrame(roomCode, username, frame)
 		}
+	}
+}
 
-		h.broadcastToRoom(roomCode, message)
+// handleFrame dispatches a client frame, whichever transport it arrived on
+func (h *ChatHandler) handleFrame(roomCode, username string, frame ClientFrame) {
+	switch frame.Type {
+	case "edit":
+		h.handleEditFrame(roomCode, username, frame)
+	case "delete":
+		h.handleDeleteFrame(roomCode, username, frame)
+	case "mute", "unmute":
+		h.handleMuteFrame(roomCode, username, frame, frame.Type == "mute")
+	case "resync":
+		h.handleResyncFrame(roomCode, username, frame)
+	case "game_invite":
+		h.handleGameInviteFrame(roomCode, username, frame)
+	case "game_move":
+		h.handleGameMoveFrame(roomCode, username, frame)
+	case "game_state":
+		h.handleGameStateFrame(roomCode, username)
+	default:
+		h.handleMessageFrame(roomCode, username, frame)
+	}
+}
+
+func (h *ChatHandler) handleMessageFrame(roomCode, username string, frame ClientFrame) {
+	if name, args, ok := parseCommand(frame.Text); ok {
+		h.handleCommand(roomCode, username, name, args)
+		return
+	}
+	text := frame.Text
+	if strings.HasPrefix(strings.TrimSpace(text), CommandPrefix+CommandPrefix) {
+		text = strings.Replace(text, CommandPrefix+CommandPrefix, CommandPrefix, 1) // escaped slash
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stored, err := h.messageService.SaveMessage(ctx, roomCode, username, text, h.roomContentPolicy(roomCode))
+	switch {
+	case errors.Is(err, service.ErrMessageEmpty):
+		return
+	case errors.Is(err, service.ErrMessageTooLong), errors.Is(err, service.ErrMessageBlocked),
+		errors.Is(err, service.ErrMessageSpam):
+		h.sendError(roomCode, username, err)
+		return
+	case err != nil:
+		h.sendError(roomCode, username, err) // logs the failure
+		return
+	}
+
+	message := ChatMessage{
+		Type:      "message",
+		ID:        stored.ID.Hex(),
+		From:      username,
+		Text:      stored.Text,
+		Flagged:   stored.Flagged,
+		Bot:       model.IsBotUsername(username),
+		Timestamp: stored.CreatedAt.UnixMilli(),
+	}
+
+	if stored.Shadowed {
+		h.sendToUser(roomCode, username, message)
+		return
+	}
+
+	h.chatService.RecordMessage(roomCode, username)
+	h.attachTranslation(roomCode, username, &message)
+	h.broadcastToRoom(roomCode, message)
+}
+
+func (h *ChatHandler) handleEditFrame(roomCode, username string, frame ClientFrame) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	edited, err := h.messageService.EditMessage(ctx, roomCode, frame.ID, username, frame.Text, h.roomContentPolicy(roomCode))
+	if err != nil {
+		h.sendError(roomCode, username, err)
+		return
+	}
+
+	message := ChatMessage{
+		Type:      "edit",
+		ID:        edited.ID.Hex(),
+		From:      username,
+		Text:      edited.Text,
+		Flagged:   edited.Flagged,
+		EditedAt:  edited.EditedAt.UnixMilli(),
+		Timestamp: edited.CreatedAt.UnixMilli(),
+	}
+
+	h.attachTranslation(roomCode, username, &message)
+	h.broadcastToRoom(roomCode, message)
+}
+
+func (h *ChatHandler) handleDeleteFrame(roomCode, username string, frame ClientFrame) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	deleted, err := h.messageService.DeleteMessage(ctx, roomCode, frame.ID, username)
+	if err != nil {
+		h.sendError(roomCode, username, err)
+		return
 	}
 
-	// Send leave message
 	h.broadcastToRoom(roomCode, ChatMessage{
-		Type:      "system",
-		Text:      username + " đã rời khỏi phòng chat",
+		Type:      "delete",
+		ID:        deleted.ID.Hex(),
+		From:      username,
+		Timestamp: deleted.DeletedAt.UnixMilli(),
+	})
+}
+
+// roomContentPolicy returns the content policy of a room, medium when it is gone
+func (h *ChatHandler) roomContentPolicy(roomCode string) model.ContentPolicy {
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists {
+		return model.ContentPolicy{Filter: model.ContentFilterMedium}
+	}
+	return room.ContentPolicy()
+}
+
+// sendError sends an error frame to a single connection in the room
+func (h *ChatHandler) sendError(roomCode, username string, err error) {
+	text := "request failed"
+	if errors.Is(err, service.ErrMessageNotFound) || errors.Is(err, service.ErrMessageNotEditable) ||
+		errors.Is(err, service.ErrMessageEmpty) || errors.Is(err, service.ErrMessageTooLong) ||
+		errors.Is(err, service.ErrMessageBlocked) || errors.Is(err, service.ErrMessageSpam) ||
+		errors.Is(err, errMuteTarget) || errors.Is(err, errGame) || errors.Is(err, errFrameEncoding) || errors.Is(err, games.ErrNotPlayer) ||
+		errors.Is(err, games.ErrNotYourTurn) || errors.Is(err, games.ErrInvalidMove) || errors.Is(err, games.ErrGameOver) {
+		text = err.Error()
+	} else {
+		h.logger.WithError(err).WithFields(logrus.Fields{"room": roomCode, "user": username}).Error("Failed to handle frame")
+	}
+
+	h.sendToUser(roomCode, username, ChatMessage{
+		Type:      "error",
+		Text:      text,
 		Timestamp: time.Now().UnixMilli(),
 	})
 }
 
-func (h *ChatHandler) broadcastToRoom(roomCode string, message ChatMessage) {
+func (h *ChatHandler) sendToUser(roomCode, username string, message ChatMessage) {
 	h.connLock.RLock()
-	roomConns := h.connections[roomCode]
+	client := h.connections[roomCode][username]
 	h.connLock.RUnlock()
 
-	if roomConns == nil {
+	if client == nil || !client.Accepts(message.Type) {
 		return
 	}
 
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		h.logger.WithError(err).WithField("room", roomCode).Error("Failed to marshal frame")
 		return
 	}
 
-	// Send to all connections in room
-	for username, conn := range roomConns {
-		if err := conn.WriteMessage(websocket.TextMessage, messageBytes); err != nil {
-			log.Printf("Error sending message to %s: %v", username, err)
-			conn.Close()
-			h.removeConnection(roomCode, username)
+	if !client.Send(messageBytes) {
+		h.logger.WithFields(logrus.Fields{"room": roomCode, "user": username}).Debug("Dropping client: closed or too slow")
+		client.Close()
+	}
+}
+
+// ConnectionCount returns the number of open room connections, observers included
+func (h *ChatHandler) ConnectionCount() int {
+	h.connLock.RLock()
+	defer h.connLock.RUnlock()
+
+	count := 0
+	for _, clients := range h.connections {
+		count += len(clients)
+	}
+	for _, observers := range h.observers {
+		count += len(observers)
+	}
+	return count
+}
+
+// HandleDeliveryStats returns what the slow client policy did since startup, see
+// config.WebSocketConfig.SendBuffer
+func (h *ChatHandler) HandleDeliveryStats(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, DeliveryStats{
+		Policy:              h.wsConfig.SlowClientPolicy,
+		SendBuffer:          h.sendPolicy.limit,
+		DropWatermark:       h.sendPolicy.watermark,
+		DroppedFrames:       h.sendPolicy.stats.dropped.Load(),
+		ClientsDisconnected: h.sendPolicy.stats.disconnected.Load(),
+	})
+}
+
+// Shutdown closes every room, channel and observer connection with CloseServerShutdown
+// and refuses new ones. Members stay in their rooms, so the rooms can be restored from
+// the snapshot and clients reconnect to them.
+func (h *ChatHandler) Shutdown() {
+	h.connLock.Lock()
+	h.closing = true
+	var clients []roomClient
+	for _, roomConns := range h.connections {
+		for _, client := range roomConns {
+			clients = append(clients, client)
+		}
+	}
+	for _, observers := range h.observers {
+		for client := range observers {
+			clients = append(clients, client)
 		}
 	}
+	h.connLock.Unlock()
+
+	for _, client := range clients {
+		client.CloseWith(CloseServerShutdown, errServerShutdown.Error())
+	}
 }
 
-func (h *ChatHandler) pingRoutine(conn *websocket.Conn) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+func (h *ChatHandler) isClosing() bool {
+	h.connLock.RLock()
+	defer h.connLock.RUnlock()
+	return h.closing
+}
 
-	for range ticker.C {
-		if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(5*time.Second)); err != nil {
-			return
+// DeliverNotification pushes a notification to every chat connection of its recipient
+func (h *ChatHandler) DeliverNotification(notification *model.Notification) {
+	h.pushToUser(notification.UserID.Hex(), ChatMessage{
+		Type:         "notification",
+		Notification: notification,
+		Timestamp:    notification.CreatedAt.UnixMilli(),
+	})
+}
+
+// attachTranslation adds a translation of the message into the partner's primary language
+// when both members opted in and their primary languages differ
+func (h *ChatHandler) attachTranslation(roomCode, sender string, message *ChatMessage) {
+	if !h.translationService.Enabled() {
+		return
+	}
+
+	room, exists := h.chatService.GetRoom(roomCode)
+	if !exists {
+		return
+	}
+
+	senderPrefs := room.Preferences[sender]
+	if !senderPrefs.Translate || senderPrefs.PrimaryLanguage() == "" {
+		return
+	}
+
+	for _, member := range room.Users {
+		if member == sender {
+			continue
+		}
+
+		partnerPrefs := room.Preferences[member]
+		target := partnerPrefs.PrimaryLanguage()
+		if !partnerPrefs.Translate || target == "" || target == senderPrefs.PrimaryLanguage() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		translated, err := h.translationService.Translate(ctx, message.Text, senderPrefs.PrimaryLanguage(), target)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		message.Translation = translated
+		message.TranslatedTo = target
+		return
+	}
+}
+
+// broadcastToRoom sends a frame to everyone in the room except the members who muted
+// its sender, numbered with the room's next sequence number while the room exists
+func (h *ChatHandler) broadcastToRoom(roomCode string, message ChatMessage) {
+	h.connLock.RLock()
+	members := make(map[string]roomClient, len(h.connections[roomCode]))
+	for username, client := range h.connections[roomCode] {
+		if _, muted := h.mutes[roomCode][username][message.From]; muted {
+			continue
+		}
+		members[username] = client
+	}
+	observers := make([]roomClient, 0, len(h.observers[roomCode]))
+	for client := range h.observers[roomCode] {
+		observers = append(observers, client)
+	}
+	buffer := h.buffers[roomCode]
+	h.connLock.RUnlock()
+
+	if len(members) == 0 && len(observers) == 0 && buffer == nil {
+		return
+	}
+
+	// Numbered frames may be dropped for a slow client, which resyncs them
+	send := roomClient.Send
+	if buffer != nil {
+		send = roomClient.SendDroppable
+	}
+	var dropped []string
+	var droppedObservers []roomClient
+	deliver := func(data []byte) {
+		// A numbered frame withheld from a client is replaced by a "skipped" frame with
+		// its sequence number, so the client sees no gap to resync
+		var skipped []byte
+		frameFor := func(client roomClient) []byte {
+			if client.Accepts(message.Type) {
+				return data
+			}
+			if skipped == nil && message.Seq > 0 {
+				skipped, _ = json.Marshal(ChatMessage{Type: "skipped", Seq: message.Seq, Timestamp: message.Timestamp})
+			}
+			return skipped
+		}
+
+		for _, client := range observers {
+			if frame := frameFor(client); frame != nil && !send(client, frame) {
+				droppedObservers = append(droppedObservers, client)
+			}
 		}
+		for username, client := range members {
+			if frame := frameFor(client); frame != nil && !send(client, frame) {
+				dropped = append(dropped, username)
+			}
+		}
+	}
+
+	var err error
+	if buffer != nil {
+		err = buffer.Append(message.From, message.Type, func(seq uint64) ([]byte, error) {
+			message.Seq = seq
+			return json.Marshal(message)
+		}, deliver)
+	} else {
+		var messageBytes []byte
+		if messageBytes, err = json.Marshal(message); err == nil {
+			deliver(messageBytes)
+		}
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("room", roomCode).Error("Failed to marshal frame")
+		return
+	}
+
+	for _, client := range droppedObservers {
+		client.Close()
+		h.removeObserver(roomCode, client)
+	}
+
+	// A client that cannot keep up is dropped and its transport handler announces the
+	// leave once it unwinds
+	for _, username := range dropped {
+		h.logger.WithFields(logrus.Fields{"room": roomCode, "user": username}).Debug("Dropping client: closed or too slow")
+		members[username].Close()
 	}
 }
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+const (
+	ActivityTypeLogin   = "login"
+	ActivityTypeAccount = "account"
+)
+
+// ActivityEvent is one entry of a user's security timeline
+type ActivityEvent struct {
+	Type      string    `json:"type"`
+	Action    string    `json:"action,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+	Active    bool      `json:"active,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+type DailyChatCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD in UTC
+	Chats int    `json:"chats"`
+}
+
+type ActivityTimeline struct {
+	Since       time.Time        `json:"since"`
+	Events      []ActivityEvent  `json:"events"`
+	ChatsPerDay []DailyChatCount `json:"chats_per_day"`
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API key scopes
+const (
+	ScopeUsersRead = "users:read" // read online users
+	ScopeBotRead   = "bot:read"   // read the bot room
+	ScopeBotPost   = "bot:post"   // post to the bot room
+)
+
+// APIKeyScopes lists every scope a key can be granted
+var APIKeyScopes = []string{ScopeUsersRead, ScopeBotRead, ScopeBotPost}
+
+// APIKey lets a bot or integration call the bot API on behalf of its owner.
+// Only a SHA-256 hash of the key is stored; the key itself is shown once on creation.
+type APIKey struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Name       string             `json:"name" bson:"name"`
+	Prefix     string             `json:"prefix" bson:"prefix"` // first characters of the key, to tell keys apart
+	KeyHash    string             `json:"-" bson:"key_hash"`
+	Scopes     []string           `json:"scopes" bson:"scopes"`
+	RateLimit  int                `json:"rate_limit" bson:"rate_limit"` // requests per minute
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the key has not been revoked
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil
+}
+
+// HasScope reports whether the key was granted the scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyCreateRequest struct {
+	Name      string   `json:"name" validate:"required,max=64"`
+	Scopes    []string `json:"scopes" validate:"required,min=1,dive,oneof=users:read bot:read bot:post"`
+	RateLimit int      `json:"rate_limit" validate:"omitempty,min=1"` // requests per minute, defaults to the configured limit
+}
+
+// APIKeyCreateResponse carries the plaintext key, which is not retrievable later
+type APIKeyCreateResponse struct {
+	*APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditActionListRooms   = "admin.rooms.list"
+	AuditActionViewRoom    = "admin.rooms.view"
+	AuditActionObserveRoom = "admin.rooms.observe"
+	AuditActionAnnounce    = "admin.announcements.create"
+	AuditActionBulkUsers   = "admin.users.bulk"
+	AuditActionImportUsers = "admin.users.import"
+	AuditActionBanUser     = "admin.users.ban"
+	AuditActionUnbanUser   = "admin.users.unban"
+	AuditActionKickUser    = "admin.users.kick"
+	AuditActionPurgeTokens = "admin.tokens.purge"
+
+	AuditActionRevokeUserSessions = "admin.users.sessions.revoke"
+
+	AuditActionListRoomEvents = "admin.rooms.events"
+
+	AuditActionGrantSubscription  = "admin.users.subscription.grant"
+	AuditActionRevokeSubscription = "admin.users.subscription.revoke"
+
+	AuditActionViewMessageRevisions = "admin.messages.revisions"
+
+	AuditActionPlaceLegalHold = "admin.users.legal_hold.place"
+	AuditActionLiftLegalHold  = "admin.users.legal_hold.lift"
+
+	AuditActionTimeoutUser = "admin.users.timeout"
+	AuditActionLiftTimeout = "admin.users.timeout.lift"
+
+	AuditActionIcebreakerCreate = "admin.icebreakers.create"
+	AuditActionIcebreakerUpdate = "admin.icebreakers.update"
+	AuditActionIcebreakerDelete = "admin.icebreakers.delete"
+
+	AuditActionBlocklistCreate = "admin.blocklist.create"
+	AuditActionBlocklistUpdate = "admin.blocklist.update"
+	AuditActionBlocklistDelete = "admin.blocklist.delete"
+
+	AuditActionNetworkBanCreate = "admin.network_bans.create"
+	AuditActionNetworkBanUpdate = "admin.network_bans.update"
+	AuditActionNetworkBanDelete = "admin.network_bans.delete"
+
+	AuditActionDisposableDomainBlock   = "admin.disposable_domains.block"
+	AuditActionDisposableDomainUnblock = "admin.disposable_domains.unblock"
+
+	AuditActionChannelCreate = "admin.channels.create"
+	AuditActionChannelUpdate = "admin.channels.update"
+	AuditActionChannelDelete = "admin.channels.delete"
+
+	AuditActionExperimentCreate = "admin.experiments.create"
+	AuditActionExperimentStop   = "admin.experiments.stop"
+
+	// Account events recorded for the user's own activity timeline
+	AuditActionPasswordChange   = "account.password.change"
+	AuditActionTwoFactorEnable  = "account.2fa.enable"
+	AuditActionTwoFactorDisable = "account.2fa.disable"
+	AuditActionRevokeSessions   = "account.sessions.revoke"
+	AuditActionLogoutOthers     = "account.sessions.logout_others"
+	AuditActionAPIKeyCreate     = "account.apikey.create"
+	AuditActionAPIKeyRevoke     = "account.apikey.revoke"
+	AuditActionChatStart        = "chat.start"
+	AuditActionChatReport       = "chat.report" // target is the reported user
+	AuditActionChatMute         = "chat.mute"   // target is the muted room member
+	AuditActionChatUnmute       = "chat.unmute"
+	AuditActionHistoryDelete    = "chat.history.delete" // target is the room, empty for every room
+)
+
+type AuditLog struct {
+	ID        primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ActorID   primitive.ObjectID     `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	Actor     string                 `json:"actor" bson:"actor"`
+	Action    string                 `json:"action" bson:"action"`
+	Target    string                 `json:"target,omitempty" bson:"target,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	IPAddress string                 `json:"ip_address,omitempty" bson:"ip_address,omitempty"`
+	CreatedAt time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// AuditFilter narrows audit log queries; zero values are ignored
+type AuditFilter struct {
+	ActorID primitive.ObjectID
+	Action  string
+	Target  string
+	Since   time.Time
+}
+
+func NewAuditLog(actor *User, action, target, ipAddress string) *AuditLog {
+	entry := &AuditLog{
+		ID:        primitive.NewObjectID(),
+		Action:    action,
+		Target:    target,
+		IPAddress: ipAddress,
+		CreatedAt: time.Now(),
+	}
+	if actor != nil {
+		entry.ActorID = actor.ID
+		entry.Actor = actor.Username
+	}
+	return entry
+}
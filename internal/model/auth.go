@@ -6,30 +6,51 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// CaptchaTTL is how long a captcha challenge can be answered
+const CaptchaTTL = 5 * time.Minute
+
+// CaptchaSolution is either a builtin challenge id and answer, or the response token of an
+// hCaptcha/reCAPTCHA widget, depending on the configured provider
+type CaptchaSolution struct {
+	Captcha       string `json:"captcha"`
+	CaptchaAnswer string `json:"captcha_answer"`
+	CaptchaToken  string `json:"captcha_token"`
+}
+
+// CaptchaInfo tells the frontend which captcha to show. Challenge fields are only set
+// for the builtin provider, SiteKey only for third-party providers.
+type CaptchaInfo struct {
+	Provider    string `json:"provider"`
+	SiteKey     string `json:"site_key,omitempty"`
+	ChallengeID string `json:"challenge_id,omitempty"`
+	Challenge   string `json:"challenge,omitempty"`
+}
+
 type LoginRequest struct {
-	Username      string `json:"username" validate:"required,min=3,max=50"`
-	Password      string `json:"password" validate:"required,min=6"`
-	Captcha       string `json:"captcha" validate:"required"`
-	CaptchaAnswer string `json:"captcha_answer" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Password string `json:"password" validate:"required,min=6"`
+	CaptchaSolution
 }
 
 type RegisterRequest struct {
-	Username      string `json:"username" validate:"required,min=3,max=50"`
-	Email         string `json:"email" validate:"required,email"`
-	Password      string `json:"password" validate:"required,min=6"`
-	Age           int    `json:"age" validate:"min=13,max=150"`
-	Gender        Gender `json:"gender" validate:"oneof=male female other private"`
-	Bio           string `json:"bio" validate:"max=500"`
-	Captcha       string `json:"captcha" validate:"required"`
-	CaptchaAnswer string `json:"captcha_answer" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=50,excludes=:"` // ':' is reserved for bots
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=6"`
+	Age      int    `json:"age" validate:"min=13,max=150"`
+	Gender   Gender `json:"gender" validate:"oneof=male female other private"`
+	Bio      string `json:"bio" validate:"max=500"`
+	CaptchaSolution
 }
 
 type AuthResponse struct {
 	Response
-	User         interface{} `json:"user"`
-	Token        string      `json:"token"`
-	RefreshToken string      `json:"refresh_token"`
-	ExpiresAt    time.Time   `json:"expires_at"`
+	User         interface{}     `json:"user"`
+	Token        string          `json:"token"`
+	RefreshToken string          `json:"refresh_token"`
+	CSRFToken    string          `json:"csrf_token,omitempty"` // cookie token transport only
+	ExpiresAt    time.Time       `json:"expires_at"`
+	Warnings     []string        `json:"warnings,omitempty"`
+	Challenge    *LoginChallenge `json:"challenge,omitempty"`
 }
 
 type RefreshTokenRequest struct {
@@ -39,13 +60,23 @@ type RefreshTokenRequest struct {
 type PasswordChangeRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=6"`
-	Captcha         string `json:"captcha" validate:"required"`
+	CaptchaSolution
 }
 
 type ProfileUpdateRequest struct {
-	Age      int    `json:"age" validate:"min=13,max=150"`
-	Gender   Gender `json:"gender" validate:"oneof=male female other private"`
-	Bio      string `json:"bio" validate:"max=500"`
+	Age            int           `json:"age" validate:"min=13,max=150"`
+	Gender         Gender        `json:"gender" validate:"oneof=male female other private"`
+	Bio            string        `json:"bio" validate:"max=500"`
+	Languages      []string      `json:"languages" validate:"omitempty,max=5,dive,min=2,max=8"`
+	TranslateOptIn *bool         `json:"translate_opt_in"`
+	ContentFilter  ContentFilter `json:"content_filter" validate:"omitempty,oneof=off medium strict"`
+	// Privacy settings
+	HideProfileViews   *bool              `json:"hide_profile_views"`
+	LastSeenVisibility LastSeenVisibility `json:"last_seen_visibility" validate:"omitempty,oneof=exact approximate hidden"`
+	// TimeZone is an IANA name such as "Asia/Ho_Chi_Minh", an empty string removes it.
+	// ActiveHours with Start equal to End removes the active hours.
+	TimeZone    *string      `json:"time_zone" validate:"omitempty,max=64"`
+	ActiveHours *ActiveHours `json:"active_hours"`
 }
 
 type RefreshToken struct {
@@ -56,6 +87,7 @@ type RefreshToken struct {
 	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
 	IsRevoked  bool               `json:"is_revoked" bson:"is_revoked"`
 	DeviceInfo string             `json:"device_info,omitempty" bson:"device_info,omitempty"`
+	SessionID  primitive.ObjectID `json:"session_id,omitempty" bson:"session_id,omitempty"` // session created with the token
 }
 
 type Session struct {
@@ -67,6 +99,8 @@ type Session struct {
 	LastUsed  time.Time          `json:"last_used" bson:"last_used"`
 	IPAddress string             `json:"ip_address" bson:"ip_address"`
 	UserAgent string             `json:"user_agent" bson:"user_agent"`
+	Country   string             `json:"country,omitempty" bson:"country,omitempty"`
+	City      string             `json:"city,omitempty" bson:"city,omitempty"`
 	IsActive  bool               `json:"is_active" bson:"is_active"`
 }
 
@@ -111,7 +145,7 @@ func NewCaptchaChallenge(challenge, answer string, ipAddress string) *CaptchaCha
 		ID:        primitive.NewObjectID(),
 		Challenge: challenge,
 		Answer:    answer,
-		ExpiresAt: time.Now().Add(5 * time.Minute), // 5 minutes expiry
+		ExpiresAt: time.Now().Add(CaptchaTTL),
 		CreatedAt: time.Now(),
 		IsUsed:    false,
 		IPAddress: ipAddress,
@@ -119,19 +153,35 @@ func NewCaptchaChallenge(challenge, answer string, ipAddress string) *CaptchaCha
 }
 
 func (rt *RefreshToken) IsExpired() bool {
-	return time.Now().After(rt.ExpiresAt)
+	return rt.IsExpiredAt(time.Now())
+}
+
+func (rt *RefreshToken) IsExpiredAt(now time.Time) bool {
+	return now.After(rt.ExpiresAt)
 }
 
 func (rt *RefreshToken) IsValid() bool {
-	return !rt.IsRevoked && !rt.IsExpired()
+	return rt.IsValidAt(time.Now())
+}
+
+func (rt *RefreshToken) IsValidAt(now time.Time) bool {
+	return !rt.IsRevoked && !rt.IsExpiredAt(now)
 }
 
 func (s *Session) IsExpired() bool {
-	return time.Now().After(s.ExpiresAt)
+	return s.IsExpiredAt(time.Now())
+}
+
+func (s *Session) IsExpiredAt(now time.Time) bool {
+	return now.After(s.ExpiresAt)
 }
 
 func (s *Session) IsValid() bool {
-	return s.IsActive && !s.IsExpired()
+	return s.IsValidAt(time.Now())
+}
+
+func (s *Session) IsValidAt(now time.Time) bool {
+	return s.IsActive && !s.IsExpiredAt(now)
 }
 
 func (s *Session) UpdateLastUsed() {
@@ -139,11 +189,19 @@ func (s *Session) UpdateLastUsed() {
 }
 
 func (c *CaptchaChallenge) IsExpired() bool {
-	return time.Now().After(c.ExpiresAt)
+	return c.IsExpiredAt(time.Now())
+}
+
+func (c *CaptchaChallenge) IsExpiredAt(now time.Time) bool {
+	return now.After(c.ExpiresAt)
 }
 
 func (c *CaptchaChallenge) IsValid() bool {
-	return !c.IsUsed && !c.IsExpired()
+	return c.IsValidAt(time.Now())
+}
+
+func (c *CaptchaChallenge) IsValidAt(now time.Time) bool {
+	return !c.IsUsed && !c.IsExpiredAt(now)
 }
 
 func (c *CaptchaChallenge) MarkAsUsed() {
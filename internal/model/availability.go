@@ -0,0 +1,102 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ActiveHours is the part of the day a user likes to chat, in whole hours of the user's
+// TimeZone. End is exclusive and may be before Start for a window past midnight.
+type ActiveHours struct {
+	Start int `json:"start" bson:"start" validate:"min=0,max=23"`
+	End   int `json:"end" bson:"end" validate:"min=0,max=23"`
+}
+
+// Contains reports whether the local hour is within the window
+func (h ActiveHours) Contains(hour int) bool {
+	if h.Start <= h.End {
+		return hour >= h.Start && hour < h.End
+	}
+	return hour >= h.Start || hour < h.End
+}
+
+// UTCHours returns the hours of the UTC day the window covers in loc, using the offset
+// of loc at now truncated to whole hours
+func (h ActiveHours) UTCHours(loc *time.Location, now time.Time) [24]bool {
+	var hours [24]bool
+	_, offset := now.In(loc).Zone()
+	shift := offset / 3600
+	for local := 0; local < 24; local++ {
+		if h.Contains(local) {
+			hours[((local-shift)%24+24)%24] = true
+		}
+	}
+	return hours
+}
+
+// Location returns the time zone set in the profile
+func (u *User) Location() (*time.Location, bool) {
+	if u.TimeZone == "" {
+		return nil, false
+	}
+	loc, err := LoadLocation(u.TimeZone)
+	return loc, err == nil
+}
+
+// ActiveUTCHours returns the UTC hours covered by the user's active hours, or false when
+// the profile lacks active hours or a time zone
+func (u *User) ActiveUTCHours(now time.Time) ([24]bool, bool) {
+	loc, ok := u.Location()
+	if !ok || u.ActiveHours == nil {
+		return [24]bool{}, false
+	}
+	return u.ActiveHours.UTCHours(loc, now), true
+}
+
+// Availability is how many people are online around the hours a user likes to chat, see
+// UserService.Availability. The counts leave out the user and those hiding their last seen.
+type Availability struct {
+	TimeZone    string       `json:"time_zone,omitempty"`
+	ActiveHours *ActiveHours `json:"active_hours,omitempty"`
+	Online      int          `json:"online"`
+	// Overlapping are the online users whose active hours overlap the user's, or who are
+	// within their active hours now when the user has none
+	Overlapping int `json:"overlapping"`
+	// ByHour counts the online users active at each hour of the user's day, in the
+	// user's time zone or else in UTC
+	ByHour [24]int `json:"by_hour"`
+}
+
+var locations sync.Map // time zone name -> *time.Location
+
+// LoadLocation is time.LoadLocation with a cache, as matchmaking looks up the same few
+// time zones over and over. It refuses "Local", which names the server's time zone, and
+// the empty name.
+func LoadLocation(name string) (*time.Location, error) {
+	if cached, ok := locations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// utcOffset returns the current UTC offset of the time zone
+func utcOffset(name string) (time.Duration, bool) {
+	if name == "" {
+		return 0, false
+	}
+	loc, err := LoadLocation(name)
+	if err != nil {
+		return 0, false
+	}
+	_, offset := time.Now().In(loc).Zone()
+	return time.Duration(offset) * time.Second, true
+}
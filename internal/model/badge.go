@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	BadgeFirstChat     = "first_chat"
+	BadgeHundredChats  = "chats_100"
+	BadgeWeekStreak    = "streak_7"
+	BadgeVerifiedEmail = "verified_email"
+)
+
+// MaxFeaturedBadges is how many badges are shown on a public profile
+const MaxFeaturedBadges = 3
+
+// BadgeDefinition describes a badge that can be awarded
+type BadgeDefinition struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Badges lists every badge in display order
+var Badges = []BadgeDefinition{
+	{Code: BadgeFirstChat, Name: "Lời chào đầu tiên", Description: "Hoàn thành cuộc chat đầu tiên"},
+	{Code: BadgeHundredChats, Name: "Người mê trò chuyện", Description: "Hoàn thành 100 cuộc chat"},
+	{Code: BadgeWeekStreak, Name: "Chuỗi 7 ngày", Description: "Chat 7 ngày liên tiếp"},
+	{Code: BadgeVerifiedEmail, Name: "Đã xác thực", Description: "Xác thực địa chỉ email"},
+}
+
+// GetBadgeDefinition returns the definition of a badge code
+func GetBadgeDefinition(code string) (BadgeDefinition, bool) {
+	for _, badge := range Badges {
+		if badge.Code == code {
+			return badge, true
+		}
+	}
+	return BadgeDefinition{}, false
+}
+
+// UserBadge is a badge awarded to a user
+type UserBadge struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Username  string             `json:"-" bson:"username"`
+	Code      string             `json:"code" bson:"code"`
+	AwardedAt time.Time          `json:"awarded_at" bson:"awarded_at"`
+}
+
+// BadgeView is an awarded badge as returned by the API
+type BadgeView struct {
+	BadgeDefinition
+	AwardedAt time.Time `json:"awarded_at"`
+	Featured  bool      `json:"featured"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BlocklistSeverity decides which content filter levels act on a blocklist entry
+type BlocklistSeverity string
+
+const (
+	BlocklistSeverityLow    BlocklistSeverity = "low"    // rejected in strict rooms only
+	BlocklistSeverityMedium BlocklistSeverity = "medium" // masked in medium rooms, rejected in strict rooms
+	BlocklistSeverityHigh   BlocklistSeverity = "high"   // rejected in every room, even with the filter off
+)
+
+// IsValid reports whether the severity is a known level
+func (s BlocklistSeverity) IsValid() bool {
+	switch s {
+	case BlocklistSeverityLow, BlocklistSeverityMedium, BlocklistSeverityHigh:
+		return true
+	}
+	return false
+}
+
+// BlocklistEntry is a moderator-managed word or regular expression applied by the
+// content filter on top of the configured profanity words. Version counts the changes
+// of the entry; every change is also recorded in the audit log.
+type BlocklistEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Pattern   string             `json:"pattern" bson:"pattern"`
+	Regex     bool               `json:"regex" bson:"regex"` // Pattern is a case-insensitive regular expression
+	Severity  BlocklistSeverity  `json:"severity" bson:"severity"`
+	Language  string             `json:"language,omitempty" bson:"language,omitempty"` // empty applies to every room
+	Version   int                `json:"version" bson:"version"`
+	CreatedBy string             `json:"created_by" bson:"created_by"`
+	UpdatedBy string             `json:"updated_by" bson:"updated_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// BlocklistRequest creates or updates a blocklist entry. Severity defaults to medium.
+// An update that carries a version fails unless it matches the stored version.
+type BlocklistRequest struct {
+	Pattern  string            `json:"pattern"`
+	Regex    bool              `json:"regex"`
+	Severity BlocklistSeverity `json:"severity"`
+	Language string            `json:"language"`
+	Version  int               `json:"version,omitempty"`
+}
+
+// Matches reports whether the entry applies to a room with the given language
+func (e *BlocklistEntry) Matches(language string) bool {
+	return e.Language == "" || language == "" || e.Language == language
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+)
+
+type BulkAction string
+
+const (
+	BulkActionBan    BulkAction = "ban"
+	BulkActionUnban  BulkAction = "unban"
+	BulkActionVerify BulkAction = "verify"
+	BulkActionDelete BulkAction = "delete"
+)
+
+type BulkJobStatus string
+
+const (
+	BulkJobPending   BulkJobStatus = "pending"
+	BulkJobRunning   BulkJobStatus = "running"
+	BulkJobCompleted BulkJobStatus = "completed"
+	BulkJobFailed    BulkJobStatus = "failed"
+)
+
+func (a BulkAction) IsValid() bool {
+	switch a {
+	case BulkActionBan, BulkActionUnban, BulkActionVerify, BulkActionDelete:
+		return true
+	}
+	return false
+}
+
+// UserFilter selects users for bulk operations; unset criteria are ignored
+type UserFilter struct {
+	JoinedAfter   *time.Time `json:"joined_after,omitempty"`
+	JoinedBefore  *time.Time `json:"joined_before,omitempty"`
+	Unverified    bool       `json:"unverified,omitempty"`
+	InactiveSince *time.Time `json:"inactive_since,omitempty"`
+}
+
+func (f UserFilter) IsEmpty() bool {
+	return f.JoinedAfter == nil && f.JoinedBefore == nil && !f.Unverified && f.InactiveSince == nil
+}
+
+// BulkJob tracks the progress of a bulk user operation
+type BulkJob struct {
+	ID         string        `json:"id"`
+	Action     BulkAction    `json:"action"`
+	Filter     UserFilter    `json:"filter"`
+	Status     BulkJobStatus `json:"status"`
+	Total      int           `json:"total"` // grows while the job pages through the matching users
+	Processed  int           `json:"processed"`
+	Affected   int64         `json:"affected"`
+	Skipped    int           `json:"skipped"`
+	Error      string        `json:"error,omitempty"`
+	CreatedBy  string        `json:"created_by"`
+	CreatedAt  time.Time     `json:"created_at"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
+
+func (j *BulkJob) IsFinished() bool {
+	return j.Status == BulkJobCompleted || j.Status == BulkJobFailed
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChannelRoomPrefix starts the hub room code of a channel, which never collides with
+// generated room codes
+const ChannelRoomPrefix = "#"
+
+// Channel is a persistent public topic room with any number of members, managed by
+// moderators. Its messages are stored under the room code "#<slug>".
+type Channel struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Slug        string             `json:"slug" bson:"slug"` // e.g. "music", unique and immutable
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedBy   string             `json:"created_by" bson:"created_by"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+
+	MemberCount int64 `json:"member_count" bson:"-"` // filled in for the directory
+}
+
+// ChannelMember records that a user joined a channel
+type ChannelMember struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Channel  string             `json:"channel" bson:"channel"` // channel slug
+	Username string             `json:"username" bson:"username"`
+	JoinedAt time.Time          `json:"joined_at" bson:"joined_at"`
+}
+
+// ChannelRequest creates a channel, or updates its name and description. The slug
+// cannot be changed.
+type ChannelRequest struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RoomCode returns the hub room code of the channel
+func (c *Channel) RoomCode() string {
+	return ChannelRoomCode(c.Slug)
+}
+
+// ChannelRoomCode returns the hub room code of a channel slug
+func ChannelRoomCode(slug string) string {
+	return ChannelRoomPrefix + slug
+}
+
+// IsChannelRoom reports whether a room code belongs to a channel
+func IsChannelRoom(roomCode string) bool {
+	return strings.HasPrefix(roomCode, ChannelRoomPrefix)
+}
@@ -1,24 +1,289 @@
 package model
 
-import "time"
+import (
+	"maps"
+	"strings"
+	"time"
+)
+
+const (
+	ChatStatusRoomAssigned = "room_assigned"
+	ChatStatusQueued       = "queued"
+	ChatStatusAtCapacity   = "at_capacity"
+	// ChatStatusQuotaExceeded means the user used up a daily quota, see ChatQuota
+	ChatStatusQuotaExceeded = "quota_exceeded"
+	// ChatStatusSuspended means a moderator timed the user out of chat until
+	// SuspendedUntil, see SanctionTimeout
+	ChatStatusSuspended = "suspended"
+)
 
 type ChatStartResponse struct {
-	Status   string `json:"status"` // "room_assigned", "queued"
+	Status   string `json:"status"` // "room_assigned", "queued", "at_capacity", "quota_exceeded", "suspended"
 	RoomCode string `json:"room,omitempty"`
-	Position int    `json:"position,omitempty"` // position in queue
-	Message  string `json:"message,omitempty"`
+	// RoomToken is what the chat socket connects with, see ChatService.RoomToken
+	RoomToken            string `json:"room_token,omitempty"`
+	Position             int    `json:"position,omitempty"` // position in queue
+	Message              string `json:"message,omitempty"`
+	Language             string `json:"language,omitempty"`               // negotiated room language, empty until a partner joins
+	EstimatedWaitSeconds int    `json:"estimated_wait_seconds,omitempty"` // based on recent room turnover, omitted when unknown
+	// Quota is set when the quota is exceeded; chats can be started again from its ResetAt
+	Quota          *ChatQuota `json:"quota,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// KickRequest is the body of an admin kick. A positive SuspendMinutes also times the user
+// out of chat for that long, see TimeoutRequest.
+type KickRequest struct {
+	SuspendMinutes int    `json:"suspend_minutes,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// ChatCapacity describes how busy matchmaking is, so clients can warn users before they start
+type ChatCapacity struct {
+	RoomsInUse int  `json:"rooms_in_use"`
+	MaxRooms   int  `json:"max_rooms"` // effective room limit, see config.AutoscaleConfig
+	QueueSize  int  `json:"queue_size"`
+	Busy       bool `json:"busy"` // new users are queued rather than given a room
+	// AverageWaitSeconds is how long users waited for a partner over the last
+	// WindowSeconds, counting Matches waits; 0 when nobody was matched
+	AverageWaitSeconds int `json:"average_wait_seconds"`
+	Matches            int `json:"matches"`
+	WindowSeconds      int `json:"window_seconds"`
 }
 
+// MatchPreferences carries the matchmaking hints supplied when starting a chat
+type MatchPreferences struct {
+	Languages []string
+	Translate bool // member opted in to inline message translation
+	// ContentFilter is the member's profanity filter level, see ChatRoom.ContentFilter
+	ContentFilter ContentFilter
+
+	// Country comes from GeoIP and TimeZone from the profile or else GeoIP; both are
+	// empty when unknown
+	Country      string
+	TimeZone     string
+	SameCountry  bool // only match partners from the same country
+	SameTimeZone bool // only match partners whose current UTC offset is the same
+
+	// Priority orders the queue and is derived from the account, see User.QueuePriority
+	Priority int
+	// Premium accounts get the premium daily chat quotas, see User.HasPremium
+	Premium bool
+	// Age comes from the profile and is 0 when not given, see Compatibility
+	Age int
+
+	// UserID assigns the user to the variants of running experiments, see Experiment.Assign
+	UserID string
+	// Variants maps experiment key -> variant of the user, and Matching holds the
+	// matching params of those variants
+	Variants map[string]string
+	Matching MatchingParams
+}
+
+// Accepts reports whether a partner with the given preferences satisfies these location requirements
+func (p MatchPreferences) Accepts(partner MatchPreferences) bool {
+	if p.SameCountry && (p.Country == "" || p.Country != partner.Country) {
+		return false
+	}
+	if p.SameTimeZone && !sameUTCOffset(p.TimeZone, partner.TimeZone) {
+		return false
+	}
+	return true
+}
+
+// Compatible reports whether both sides accept each other
+func (p MatchPreferences) Compatible(partner MatchPreferences) bool {
+	return p.Accepts(partner) && partner.Accepts(p)
+}
+
+// NearTimeZone reports whether the current UTC offsets of both time zones differ by at
+// most maxOffset. It is false when either time zone is unknown.
+func (p MatchPreferences) NearTimeZone(partner MatchPreferences, maxOffset time.Duration) bool {
+	offset, ok := utcOffset(p.TimeZone)
+	partnerOffset, partnerOk := utcOffset(partner.TimeZone)
+	if !ok || !partnerOk {
+		return false
+	}
+	diff := offset - partnerOffset
+	return diff <= maxOffset && -diff <= maxOffset
+}
+
+func sameUTCOffset(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+
+	offsetA, okA := utcOffset(a)
+	offsetB, okB := utcOffset(b)
+	return okA && okB && offsetA == offsetB
+}
+
+// PrimaryLanguage returns the most preferred language, or an empty string
+func (p MatchPreferences) PrimaryLanguage() string {
+	if len(p.Languages) == 0 {
+		return ""
+	}
+	return p.Languages[0]
+}
+
+// Preference buckets group users by the matching constraints they chose, for the
+// matchmaking metrics, see MatchPreferences.Bucket
+const (
+	BucketOpen             = "open"              // no language and no location requirement
+	BucketLanguage         = "language"          // preferred languages only
+	BucketLocation         = "location"          // same country or time zone only
+	BucketLanguageLocation = "language_location" // both
+	BucketBot              = "bot"               // the bot partner of a room
+)
+
+// Bucket returns the preference bucket of the user
+func (p MatchPreferences) Bucket() string {
+	location := p.SameCountry || p.SameTimeZone
+	switch {
+	case len(p.Languages) > 0 && location:
+		return BucketLanguageLocation
+	case len(p.Languages) > 0:
+		return BucketLanguage
+	case location:
+		return BucketLocation
+	default:
+		return BucketOpen
+	}
+}
+
+// QueueEntry is a user waiting for a free room. The queue is ordered by Priority
+// (highest first), then by QueuedAt.
 type QueueEntry struct {
-	Username string
-	QueuedAt time.Time
+	Username    string
+	QueuedAt    time.Time
+	Priority    int
+	Preferences MatchPreferences
+}
+
+// ChatSnapshot is the in-memory matchmaking state saved so it survives restarts
+type ChatSnapshot struct {
+	TakenAt   time.Time
+	Rooms     []*ChatRoom
+	UserRooms map[string]string // username -> code of the room the user was matched to
+	Queue     []QueueEntry
 }
 
 type ChatRoom struct {
-	Code      string
-	Users     []string // max 2 users
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Code         string
+	Users        []string // max 2 users
+	Preferences  map[string]MatchPreferences
+	Language     string
+	MessageCount int
+	IcebreakerID string // prompt sent when the room filled, empty until then
+	Bot          string // username of the bot occupying the second slot, see BotUsername
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	WaitingSince time.Time // when the first member started looking for a partner, queue time included
+
+	// Chat tracking for per-user statistics, see RoomSummary
+	PairedAt      time.Time      // when the room first had two members
+	Participants  []string       // members at PairedAt
+	MessagesBy    map[string]int // messages sent per member
+	PartnerLeftAt time.Time      // when a member first left the paired room
+	LeftFirst     string         // the member who left first
+	// Buckets holds the preference bucket of every member who joined, kept after they
+	// leave, see MatchPreferences.Bucket
+	Buckets map[string]string
+	// Variants holds the experiment variants of every member who joined in one, kept
+	// after they leave
+	Variants map[string]map[string]string
+
+	EventSeq int // sequence number of the last lifecycle event, see RoomEvent
+}
+
+// RoomSummary describes a closed room that had two members, for chat statistics
+type RoomSummary struct {
+	Code       string
+	Members    []string
+	MessagesBy map[string]int
+	PairedAt   time.Time
+	EndedAt    time.Time // when a member first left, or the room closed
+	LeftFirst  string
+	Buckets    map[string]string // preference bucket per member
+	Bot        bool              // a bot was one of the members
+	// Variants maps member -> experiment key -> variant, for members in experiments
+	Variants map[string]map[string]string
+}
+
+// Duration returns how long the members chatted
+func (s RoomSummary) Duration() time.Duration {
+	if s.EndedAt.Before(s.PairedAt) {
+		return 0
+	}
+	return s.EndedAt.Sub(s.PairedAt)
+}
+
+// IsPaired reports whether the room ever had two members
+func (r *ChatRoom) IsPaired() bool {
+	return !r.PairedAt.IsZero()
+}
+
+// RecordMessageFrom counts a message sent by a member
+func (r *ChatRoom) RecordMessageFrom(username string) {
+	r.MessageCount++
+	if r.MessagesBy == nil {
+		r.MessagesBy = make(map[string]int)
+	}
+	r.MessagesBy[username]++
+}
+
+// Summary describes the chat for statistics once the room closes at the given time
+func (r *ChatRoom) Summary(closedAt time.Time) RoomSummary {
+	summary := RoomSummary{
+		Code:       r.Code,
+		Members:    append([]string(nil), r.Participants...),
+		MessagesBy: make(map[string]int, len(r.MessagesBy)),
+		PairedAt:   r.PairedAt,
+		EndedAt:    r.PartnerLeftAt,
+		LeftFirst:  r.LeftFirst,
+		Buckets:    maps.Clone(r.Buckets),
+		Bot:        r.HasBot(),
+		Variants:   maps.Clone(r.Variants),
+	}
+	for user, count := range r.MessagesBy {
+		summary.MessagesBy[user] = count
+	}
+	if summary.EndedAt.IsZero() {
+		summary.EndedAt = closedAt
+	}
+	return summary
+}
+
+// BotUsernamePrefix marks server-side bot participants. Registered usernames cannot
+// contain ':', so a bot never collides with a user.
+const BotUsernamePrefix = "bot:"
+
+// BotUsername returns the room username of the bot with the given name
+func BotUsername(name string) string {
+	return BotUsernamePrefix + name
+}
+
+// IsBotUsername reports whether the username belongs to a bot participant
+func IsBotUsername(username string) bool {
+	return strings.HasPrefix(username, BotUsernamePrefix)
+}
+
+// HasBot reports whether a bot occupies a slot of the room
+func (r *ChatRoom) HasBot() bool {
+	return r.Bot != ""
+}
+
+// HasHumans reports whether any member is not a bot
+func (r *ChatRoom) HasHumans() bool {
+	for _, user := range r.Users {
+		if !IsBotUsername(user) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *ChatRoom) IsFull() bool {
@@ -34,23 +299,146 @@ func (r *ChatRoom) HasUser(username string) bool {
 	return false
 }
 
+// Partner returns the other member of the room
+func (r *ChatRoom) Partner(username string) (string, bool) {
+	for _, user := range r.Users {
+		if user != username {
+			return user, true
+		}
+	}
+	return "", false
+}
+
 func (r *ChatRoom) IsWaiting() bool {
 	return len(r.Users) == 1
 }
 
 func (r *ChatRoom) AddUser(username string) {
+	r.AddUserAt(username, time.Now())
+}
+
+func (r *ChatRoom) AddUserAt(username string, now time.Time) {
 	if !r.HasUser(username) && !r.IsFull() {
 		r.Users = append(r.Users, username)
-		r.UpdatedAt = time.Now()
+		r.UpdatedAt = now
+		if r.IsFull() && !r.IsPaired() {
+			r.PairedAt = now
+			r.Participants = append([]string(nil), r.Users...)
+		}
 	}
 }
 
 func (r *ChatRoom) RemoveUser(username string) {
+	r.RemoveUserAt(username, time.Now())
+}
+
+func (r *ChatRoom) RemoveUserAt(username string, now time.Time) {
 	for i, user := range r.Users {
 		if user == username {
+			if r.IsFull() && r.LeftFirst == "" {
+				r.LeftFirst = username
+				r.PartnerLeftAt = now
+			}
 			r.Users = append(r.Users[:i], r.Users[i+1:]...)
-			r.UpdatedAt = time.Now()
+			delete(r.Preferences, username)
+			r.UpdatedAt = now
 			break
 		}
 	}
 }
+
+// AcceptsMember reports whether every current member and the joining user accept each other
+func (r *ChatRoom) AcceptsMember(prefs MatchPreferences) bool {
+	for _, user := range r.Users {
+		if !r.Preferences[user].Compatible(prefs) {
+			return false
+		}
+	}
+	return true
+}
+
+// NearTimeZone reports whether every member lives in a time zone near the given
+// preferences, see MatchPreferences.NearTimeZone
+func (r *ChatRoom) NearTimeZone(prefs MatchPreferences, maxOffset time.Duration) bool {
+	for _, user := range r.Users {
+		if !r.Preferences[user].NearTimeZone(prefs, maxOffset) {
+			return false
+		}
+	}
+	return len(r.Users) > 0
+}
+
+// SetPreferences records the matchmaking preferences of a member
+func (r *ChatRoom) SetPreferences(username string, prefs MatchPreferences) {
+	if r.Preferences == nil {
+		r.Preferences = make(map[string]MatchPreferences)
+	}
+	r.Preferences[username] = prefs
+
+	if r.Buckets == nil {
+		r.Buckets = make(map[string]string)
+	}
+	r.Buckets[username] = prefs.Bucket()
+	if IsBotUsername(username) {
+		r.Buckets[username] = BucketBot
+	}
+
+	if len(prefs.Variants) > 0 {
+		if r.Variants == nil {
+			r.Variants = make(map[string]map[string]string)
+		}
+		r.Variants[username] = prefs.Variants
+	}
+}
+
+// ContentFilter returns the strictest content filter level among the current members.
+// Members who joined without a level, such as guests, count as medium.
+func (r *ChatRoom) ContentFilter() ContentFilter {
+	level := ContentFilterOff
+	for _, user := range r.Users {
+		memberLevel := r.Preferences[user].ContentFilter
+		if memberLevel == "" {
+			memberLevel = ContentFilterMedium
+		}
+		level = StricterContentFilter(level, memberLevel)
+	}
+	return level
+}
+
+// ContentPolicy describes how messages of a room are moderated
+type ContentPolicy struct {
+	Filter   ContentFilter
+	Language string // room language, selects language-specific blocklist entries
+}
+
+// ContentPolicy returns the content filter level and language of the room
+func (r *ChatRoom) ContentPolicy() ContentPolicy {
+	return ContentPolicy{Filter: r.ContentFilter(), Language: r.Language}
+}
+
+// SharedLanguage returns the first of the given languages spoken by every current member.
+// When neither side declared languages it returns an empty string.
+func (r *ChatRoom) SharedLanguage(languages []string) (string, bool) {
+	for _, lang := range languages {
+		shared := true
+		for _, user := range r.Users {
+			if !containsString(r.Preferences[user].Languages, lang) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// ChatStats are the accumulated chat statistics of a user, updated when their rooms close
+type ChatStats struct {
+	Username      string    `json:"-" bson:"username"`
+	TotalChats    int64     `json:"total_chats" bson:"total_chats"`
+	TotalMessages int64     `json:"total_messages" bson:"total_messages"`
+	TotalDuration int64     `json:"-" bson:"total_duration_ms"` // milliseconds
+	Skips         int64     `json:"skips" bson:"skips"`         // chats the user left first within the skip threshold
+	UpdatedAt     time.Time `json:"updated_at,omitempty" bson:"updated_at"`
+
+	// Consecutive UTC days with at least one chat, ending on LastChatDay (YYYY-MM-DD)
+	CurrentStreak int    `json:"current_streak" bson:"current_streak"`
+	LongestStreak int    `json:"longest_streak" bson:"longest_streak"`
+	LastChatDay   string `json:"last_chat_day,omitempty" bson:"last_chat_day,omitempty"`
+
+	// Derived by Compute
+	AverageChatSeconds float64 `json:"average_chat_seconds" bson:"-"`
+	SkipRate           float64 `json:"skip_rate" bson:"-"`
+}
+
+// Compute fills the derived averages
+func (s *ChatStats) Compute() *ChatStats {
+	if s.TotalChats > 0 {
+		s.AverageChatSeconds = float64(s.TotalDuration) / 1000 / float64(s.TotalChats)
+		s.SkipRate = float64(s.Skips) / float64(s.TotalChats)
+	}
+	return s
+}
+
+// chatDayLayout formats LastChatDay
+const chatDayLayout = "2006-01-02"
+
+// RecordChatDay extends the streak with a chat on the given day (UTC). Chats on a day
+// before LastChatDay do not change the streak.
+func (s *ChatStats) RecordChatDay(at time.Time) {
+	day := at.UTC().Format(chatDayLayout)
+	switch {
+	case day == s.LastChatDay:
+		if s.CurrentStreak == 0 {
+			s.CurrentStreak = 1
+		}
+	case day < s.LastChatDay:
+		return
+	case at.UTC().AddDate(0, 0, -1).Format(chatDayLayout) == s.LastChatDay:
+		s.CurrentStreak++
+	default:
+		s.CurrentStreak = 1
+	}
+
+	s.LastChatDay = day
+	if s.CurrentStreak > s.LongestStreak {
+		s.LongestStreak = s.CurrentStreak
+	}
+}
+
+// GlobalChatStats aggregates the chat statistics of every user
+type GlobalChatStats struct {
+	ChatStats
+	Users int64 `json:"users"` // users with at least one chat
+}
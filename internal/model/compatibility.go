@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// CompatibilityWeights weigh the parts of a compatibility score, see
+// MatchPreferences.Compatibility
+type CompatibilityWeights struct {
+	Language  float64
+	Age       float64
+	TimeZone  float64
+	MaxAgeGap int           // years apart that score nothing for age
+	MaxOffset time.Duration // UTC offset difference that scores nothing for time zone
+}
+
+// Compatibility scores how well a partner suits the user, from 0 up to the sum of the
+// weights. Each part counts between 0 and 1 of its weight: a shared language counts
+// fully, and age and time zone count more the closer they are. Parts the profiles give
+// no data for count nothing, so unknown partners are never ranked above known good ones.
+func (p MatchPreferences) Compatibility(partner MatchPreferences, w CompatibilityWeights) float64 {
+	score := 0.0
+	if w.Language > 0 {
+		for _, lang := range p.Languages {
+			if containsString(partner.Languages, lang) {
+				score += w.Language
+				break
+			}
+		}
+	}
+	if w.Age > 0 && w.MaxAgeGap > 0 && p.Age > 0 && partner.Age > 0 {
+		gap := p.Age - partner.Age
+		if gap < 0 {
+			gap = -gap
+		}
+		score += w.Age * max(1-float64(gap)/float64(w.MaxAgeGap), 0)
+	}
+	if w.TimeZone > 0 && w.MaxOffset > 0 {
+		offset, ok := utcOffset(p.TimeZone)
+		partnerOffset, partnerOk := utcOffset(partner.TimeZone)
+		if ok && partnerOk {
+			diff := offset - partnerOffset
+			if diff < 0 {
+				diff = -diff
+			}
+			score += w.TimeZone * max(1-float64(diff)/float64(w.MaxOffset), 0)
+		}
+	}
+	return score
+}
+
+// Compatibility returns the lowest compatibility score of the joining user with a
+// current member, see MatchPreferences.Compatibility
+func (r *ChatRoom) Compatibility(prefs MatchPreferences, w CompatibilityWeights) float64 {
+	lowest := -1.0
+	for _, user := range r.Users {
+		score := prefs.Compatibility(r.Preferences[user], w)
+		if lowest < 0 || score < lowest {
+			lowest = score
+		}
+	}
+	return max(lowest, 0)
+}
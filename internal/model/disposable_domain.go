@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DisposableDomain is an admin override of the disposable email domain list: Blocked
+// adds a domain to the built-in and remote lists, otherwise the domain is allowed even
+// though one of those lists blocks it
+type DisposableDomain struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Domain    string             `json:"domain" bson:"domain"`
+	Blocked   bool               `json:"blocked" bson:"blocked"`
+	UpdatedBy string             `json:"updated_by" bson:"updated_by"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// DisposableDomainRequest blocks or unblocks a domain
+type DisposableDomainRequest struct {
+	Domain string `json:"domain"`
+}
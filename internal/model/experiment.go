@@ -0,0 +1,100 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExperimentStatus is the state of an A/B experiment
+type ExperimentStatus string
+
+const (
+	ExperimentRunning ExperimentStatus = "running"
+	ExperimentStopped ExperimentStatus = "stopped" // users are no longer assigned; kept for reference
+)
+
+// Experiment splits users between variants of the matching algorithm. A user always
+// lands in the same variant of an experiment, see Assign, and the matchmaking metrics
+// and events carry the variant so the variants can be compared.
+type Experiment struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Key         string              `json:"key" bson:"key"`
+	Description string              `json:"description,omitempty" bson:"description,omitempty"`
+	Variants    []ExperimentVariant `json:"variants" bson:"variants"`
+	Status      ExperimentStatus    `json:"status" bson:"status"`
+	CreatedBy   string              `json:"created_by" bson:"created_by"`
+	CreatedAt   time.Time           `json:"created_at" bson:"created_at"`
+	StoppedBy   string              `json:"stopped_by,omitempty" bson:"stopped_by,omitempty"`
+	StoppedAt   *time.Time          `json:"stopped_at,omitempty" bson:"stopped_at,omitempty"`
+}
+
+// ExperimentVariant is one arm of an experiment. Weight is its share of the users
+// relative to the other variants.
+type ExperimentVariant struct {
+	Name     string         `json:"name" bson:"name"`
+	Weight   int            `json:"weight" bson:"weight"`
+	Matching MatchingParams `json:"matching" bson:"matching"`
+}
+
+// MatchingParams changes how matchmaking treats the users of a variant. The zero value
+// keeps the configured behavior, so a control variant leaves it empty.
+type MatchingParams struct {
+	// IgnoreLanguage stops preferring waiting rooms whose member shares a language
+	IgnoreLanguage bool `json:"ignore_language,omitempty" bson:"ignore_language,omitempty"`
+	// TimeZoneBias overrides chat.timezone_bias.enabled
+	TimeZoneBias *bool `json:"timezone_bias,omitempty" bson:"timezone_bias,omitempty"`
+	// BotWaitSeconds overrides chat.bot.wait_threshold for users waiting alone
+	BotWaitSeconds int `json:"bot_wait_seconds,omitempty" bson:"bot_wait_seconds,omitempty"`
+}
+
+// Merge returns the params with the fields set in other replacing their own
+func (p MatchingParams) Merge(other MatchingParams) MatchingParams {
+	if other.IgnoreLanguage {
+		p.IgnoreLanguage = true
+	}
+	if other.TimeZoneBias != nil {
+		p.TimeZoneBias = other.TimeZoneBias
+	}
+	if other.BotWaitSeconds > 0 {
+		p.BotWaitSeconds = other.BotWaitSeconds
+	}
+	return p
+}
+
+// ExperimentRequest defines an experiment. Variants without a weight get 1.
+type ExperimentRequest struct {
+	Key         string              `json:"key"`
+	Description string              `json:"description"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
+
+// IsRunning reports whether users are assigned to the experiment
+func (e *Experiment) IsRunning() bool {
+	return e.Status == ExperimentRunning
+}
+
+// Assign returns the variant of the user: the SHA-256 hash of the experiment key and
+// the user ID picks a point in the total weight, so the same user gets the same variant
+// on every instance and every restart
+func (e *Experiment) Assign(userID string) *ExperimentVariant {
+	total := 0
+	for _, variant := range e.Variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(e.Key + ":" + userID))
+	point := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for i := range e.Variants {
+		if point < e.Variants[i].Weight {
+			return &e.Variants[i]
+		}
+		point -= e.Variants[i].Weight
+	}
+	return nil
+}
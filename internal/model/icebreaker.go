@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Icebreaker is a conversation prompt sent to a room when it fills up
+type Icebreaker struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Text      string             `json:"text" bson:"text"`
+	Language  string             `json:"language,omitempty" bson:"language,omitempty"` // empty matches every room
+	Active    bool               `json:"active" bson:"active"`
+	UseCount  int64              `json:"use_count" bson:"use_count"`
+	LastUsed  *time.Time         `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// IcebreakerRequest creates or updates a prompt. Active defaults to true on create.
+type IcebreakerRequest struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	Active   *bool  `json:"active"`
+}
+
+// Matches reports whether the prompt can be used in a room with the given language
+func (i *Icebreaker) Matches(language string) bool {
+	return i.Language == "" || language == "" || i.Language == language
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LegalHold freezes the data of a user under a moderation case: while it is in place
+// the retention janitor and the user's own deletion requests leave the user's messages,
+// sessions and reports alone. Lifting the hold deletes it; the audit log keeps its history.
+type LegalHold struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Username string             `json:"username" bson:"username"`
+	Reason   string             `json:"reason" bson:"reason"`
+	CaseRef  string             `json:"case_ref,omitempty" bson:"case_ref,omitempty"` // moderation case or ticket
+	PlacedBy string             `json:"placed_by" bson:"placed_by"`
+	PlacedAt time.Time          `json:"placed_at" bson:"placed_at"`
+}
+
+// LegalHoldRequest places a legal hold on a user
+type LegalHoldRequest struct {
+	Reason  string `json:"reason"`
+	CaseRef string `json:"case_ref"`
+}
+
+// HeldUsers are the users under a legal hold, whose data the repository cleanup methods
+// taking it leave out. The zero value holds nobody.
+type HeldUsers struct {
+	UserIDs   []primitive.ObjectID
+	Usernames []string
+}
+
+// NewHeldUsers collects the users of the holds
+func NewHeldUsers(holds []*LegalHold) HeldUsers {
+	held := HeldUsers{
+		UserIDs:   make([]primitive.ObjectID, 0, len(holds)),
+		Usernames: make([]string, 0, len(holds)),
+	}
+	for _, hold := range holds {
+		held.UserIDs = append(held.UserIDs, hold.UserID)
+		held.Usernames = append(held.Usernames, hold.Username)
+	}
+	return held
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// MatchStats rolls up the matchmaking quality of one UTC day and preference bucket, see
+// MatchPreferences.Bucket. Chats are counted once for each human member, in the
+// member's bucket; chats with a bot are left out.
+type MatchStats struct {
+	Day        string    `json:"day" bson:"day"` // YYYY-MM-DD, UTC
+	Bucket     string    `json:"bucket" bson:"bucket"`
+	Matches    int64     `json:"matches" bson:"matches"`         // users who got a partner, bots included
+	BotMatches int64     `json:"bot_matches" bson:"bot_matches"` // of which with a bot
+	WaitMs     int64     `json:"-" bson:"wait_ms"`               // total wait of the matches
+	Abandoned  int64     `json:"abandoned" bson:"abandoned"`     // users who stopped looking before a match
+	Chats      int64     `json:"chats" bson:"chats"`
+	LongChats  int64     `json:"long_chats" bson:"long_chats"` // chats lasting at least metrics.long_chat
+	Skips      int64     `json:"skips" bson:"skips"`           // chats the member left within chat.skip_threshold
+	UpdatedAt  time.Time `json:"updated_at,omitempty" bson:"updated_at"`
+
+	// Derived by Compute
+	AverageWaitSeconds float64 `json:"average_wait_seconds" bson:"-"`
+	LongChatRate       float64 `json:"long_chat_rate" bson:"-"`
+	SkipRate           float64 `json:"skip_rate" bson:"-"`
+	AbandonRate        float64 `json:"abandon_rate" bson:"-"` // of the users who stopped looking, matched or not
+}
+
+// Add adds the counters of other
+func (s *MatchStats) Add(other *MatchStats) {
+	s.Matches += other.Matches
+	s.BotMatches += other.BotMatches
+	s.WaitMs += other.WaitMs
+	s.Abandoned += other.Abandoned
+	s.Chats += other.Chats
+	s.LongChats += other.LongChats
+	s.Skips += other.Skips
+}
+
+// Compute fills the derived averages and rates
+func (s *MatchStats) Compute() *MatchStats {
+	if s.Matches > 0 {
+		s.AverageWaitSeconds = float64(s.WaitMs) / 1000 / float64(s.Matches)
+	}
+	if s.Chats > 0 {
+		s.LongChatRate = float64(s.LongChats) / float64(s.Chats)
+		s.SkipRate = float64(s.Skips) / float64(s.Chats)
+	}
+	if looked := s.Matches + s.Abandoned; looked > 0 {
+		s.AbandonRate = float64(s.Abandoned) / float64(looked)
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Message struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RoomCode  string             `json:"room" bson:"room_code"`
+	From      string             `json:"from" bson:"from"`
+	Text      string             `json:"text" bson:"text"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	EditedAt  *time.Time         `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+	Flagged   bool               `json:"flagged,omitempty" bson:"flagged"`     // profanity was masked or spam flagged
+	Spam      []string           `json:"spam,omitempty" bson:"spam,omitempty"` // spam heuristics that matched
+	IsDeleted bool               `json:"is_deleted" bson:"is_deleted"`
+	DeletedAt *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// Shadowed messages are only shown to their sender, see config.SpamConfig
+	Shadowed bool `json:"-" bson:"shadowed,omitempty"`
+	// Revisions keeps every replaced version for moderators and is never sent to members
+	Revisions []MessageRevision `json:"-" bson:"revisions,omitempty"`
+}
+
+// MessageRevision is a version of a message that was replaced by an edit or a deletion
+type MessageRevision struct {
+	Text       string    `json:"text" bson:"text"`
+	Flagged    bool      `json:"flagged,omitempty" bson:"flagged"`
+	WrittenAt  time.Time `json:"written_at" bson:"written_at"`   // when this version was sent or edited
+	ReplacedAt time.Time `json:"replaced_at" bson:"replaced_at"` // when it was edited or deleted
+}
+
+func NewMessage(roomCode, from, text string) *Message {
+	return &Message{
+		ID:        primitive.NewObjectID(),
+		RoomCode:  roomCode,
+		From:      from,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Edit replaces the text and returns the revision recording the previous version
+func (m *Message) Edit(text string) MessageRevision {
+	now := time.Now()
+	revision := m.revise(now)
+	m.Text = text
+	m.EditedAt = &now
+	return revision
+}
+
+// MarkDeleted clears the text and returns the revision recording the deleted version
+func (m *Message) MarkDeleted() MessageRevision {
+	now := time.Now()
+	revision := m.revise(now)
+	m.Text = ""
+	m.IsDeleted = true
+	m.DeletedAt = &now
+	return revision
+}
+
+func (m *Message) revise(now time.Time) MessageRevision {
+	revision := MessageRevision{
+		Text:       m.Text,
+		Flagged:    m.Flagged,
+		WrittenAt:  m.CreatedAt,
+		ReplacedAt: now,
+	}
+	if m.EditedAt != nil {
+		revision.WrittenAt = *m.EditedAt
+	}
+	m.Revisions = append(m.Revisions, revision)
+	return revision
+}
+
+// IsVisibleTo reports whether the message is shown to the user: shadowed messages are
+// hidden from everyone but their sender
+func (m *Message) IsVisibleTo(username string) bool {
+	return !m.Shadowed || m.From == username
+}
+
+// IsEditableBy reports whether the user may still edit or delete the message
+func (m *Message) IsEditableBy(username string, window time.Duration) bool {
+	return m.From == username && !m.IsDeleted && time.Since(m.CreatedAt) <= window
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// NetworkBanIP bans an IP address or CIDR range; Value is the normalized prefix
+	NetworkBanIP = "ip"
+	// NetworkBanDevice bans a device fingerprint sent by the client
+	NetworkBanDevice = "device"
+)
+
+// NetworkBan refuses registration, login and chat connections from an IP range or a
+// device, whoever the account. A ban without ExpiresAt is permanent.
+type NetworkBan struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Type      string             `json:"type" bson:"type"` // NetworkBanIP or NetworkBanDevice
+	Value     string             `json:"value" bson:"value"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	CreatedBy string             `json:"created_by" bson:"created_by"`
+	UpdatedBy string             `json:"updated_by" bson:"updated_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+}
+
+// Active reports whether the ban is in force at now
+func (b *NetworkBan) Active(now time.Time) bool {
+	return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
+}
+
+// NetworkBanRequest creates or updates a network ban. A positive TTLMinutes makes the ban
+// expire that long from now; zero makes it permanent. Updates keep the type and value.
+type NetworkBanRequest struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Reason     string `json:"reason,omitempty"`
+	TTLMinutes int    `json:"ttl_minutes,omitempty"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	NotificationFriendRequest  = "friend_request"
+	NotificationReportResolved = "report_resolved"
+	NotificationAccountWarning = "account_warning"
+	NotificationAnnouncement   = "announcement"
+	NotificationBadgeAwarded   = "badge_awarded"
+)
+
+type Notification struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"-" bson:"user_id"`
+	Username  string             `json:"-" bson:"username"`
+	Type      string             `json:"type" bson:"type"`
+	Title     string             `json:"title" bson:"title"`
+	Body      string             `json:"body,omitempty" bson:"body,omitempty"`
+	Data      map[string]string  `json:"data,omitempty" bson:"data,omitempty"`
+	ReadAt    *time.Time         `json:"read_at,omitempty" bson:"read_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func NewNotification(user *User, notificationType, title, body string) *Notification {
+	return &Notification{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Username:  user.Username,
+		Type:      notificationType,
+		Title:     title,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
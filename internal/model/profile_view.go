@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProfileViewDayFormat is the layout of ProfileView.Day, a UTC date
+const ProfileViewDayFormat = "2006-01-02"
+
+// ProfileView records that a viewer opened a profile on a day; a viewer is counted
+// once per profile per day
+type ProfileView struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username string             `json:"username" bson:"username"` // profile owner
+	Viewer   string             `json:"viewer" bson:"viewer"`     // viewer username, or "ip:" and a hash for guests
+	Day      string             `json:"day" bson:"day"`
+	ViewedAt time.Time          `json:"viewed_at" bson:"viewed_at"`
+}
+
+// ProfileViewStats are the view counts shown to the profile owner
+type ProfileViewStats struct {
+	Total     int64 `json:"total"`
+	Last7Days int64 `json:"last_7_days"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// RetentionReport is what a retention run deleted, or would delete in a dry run
+type RetentionReport struct {
+	DryRun    bool              `json:"dry_run"`
+	RanAt     time.Time         `json:"ran_at"`
+	HeldUsers int               `json:"held_users"` // users under a legal hold, whose data was kept
+	Classes   []RetentionResult `json:"classes"`
+}
+
+// RetentionResult is the outcome of a retention run for one data class. Classes kept
+// forever have no cutoff.
+type RetentionResult struct {
+	Class  string     `json:"class"`
+	Period string     `json:"period"`
+	Cutoff *time.Time `json:"cutoff,omitempty"` // data older than this is deleted
+	Count  int64      `json:"count"`            // deleted, or to be deleted in a dry run
+	Error  string     `json:"error,omitempty"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Room lifecycle event types
+const (
+	RoomEventCreated    = "created"
+	RoomEventUserJoined = "user_joined"
+	RoomEventUserLeft   = "user_left"
+	RoomEventClosed     = "closed"
+)
+
+// Reasons of user_left and closed events
+const (
+	RoomReasonLeft    = "left"    // the member left or disconnected
+	RoomReasonExpired = "expired" // a member restored from a snapshot did not reconnect
+	RoomReasonEmpty   = "empty"   // the last human member left
+	RoomReasonLonely  = "lonely"  // nobody joined or talked within chat.room_cleanup_interval
+	RoomReasonKicked  = "kicked"  // a moderator removed the member
+)
+
+// RoomEvent is an entry of the room lifecycle log, which keeps what happened in a room
+// after its messages are gone. Seq orders the events of a room; events are recorded
+// asynchronously and may be stored out of order.
+type RoomEvent struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RoomCode string             `json:"room" bson:"room"`
+	Seq      int                `json:"seq" bson:"seq"`
+	Type     string             `json:"type" bson:"type"`
+	Username string             `json:"username,omitempty" bson:"username,omitempty"` // member of user_joined and user_left
+	Reason   string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	// Message counts: of the member for user_left, per member and in total for closed
+	MessageCount int            `json:"message_count,omitempty" bson:"message_count,omitempty"`
+	MessagesBy   map[string]int `json:"messages_by,omitempty" bson:"messages_by,omitempty"`
+	// Score is the compatibility score of a user_joined member with the waiting member,
+	// with chat.compatibility enabled
+	Score *float64  `json:"score,omitempty" bson:"score,omitempty"`
+	At    time.Time `json:"at" bson:"at"`
+}
+
+// RoomEventFilter narrows room event queries; zero values are ignored
+type RoomEventFilter struct {
+	RoomCode string
+	Username string
+	Since    time.Time
+}
+
+// NextEvent returns the room's next lifecycle event of the given type
+func (r *ChatRoom) NextEvent(eventType string, at time.Time) *RoomEvent {
+	r.EventSeq++
+	return &RoomEvent{
+		RoomCode: r.Code,
+		Seq:      r.EventSeq,
+		Type:     eventType,
+		At:       at,
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SanctionTimeout keeps a user out of matchmaking and chat rooms until the sanction
+// expires. Unlike an account ban the user can still log in and use their profile.
+const SanctionTimeout = "timeout"
+
+// Sanction is a moderation measure against a user that runs until ExpiresAt, or until a
+// moderator lifts it. Lifted and expired sanctions are kept as the user's history.
+type Sanction struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Username  string             `json:"username" bson:"username"`
+	Type      string             `json:"type" bson:"type"` // SanctionTimeout
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	CreatedBy string             `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	LiftedBy  string             `json:"lifted_by,omitempty" bson:"lifted_by,omitempty"`
+	LiftedAt  *time.Time         `json:"lifted_at,omitempty" bson:"lifted_at,omitempty"`
+}
+
+// Active reports whether the sanction is in force at now
+func (s *Sanction) Active(now time.Time) bool {
+	return s.LiftedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// TimeoutRequest times a user out of chat for Minutes
+type TimeoutRequest struct {
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason,omitempty"`
+}
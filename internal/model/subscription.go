@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// Tier is the subscription level of an account
+type Tier string
+
+const (
+	TierFree    Tier = "free"
+	TierPremium Tier = "premium"
+)
+
+// Subscription sources; billing providers report under the source of their webhook,
+// see config.BillingConfig
+const (
+	SubscriptionSourceAdmin = "admin"
+)
+
+// Subscription is the paid entitlement of an account. It grants its tier until
+// ExpiresAt, or indefinitely when ExpiresAt is nil.
+type Subscription struct {
+	Tier      Tier       `json:"tier" bson:"tier"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	Source    string     `json:"source" bson:"source"`                           // admin or the billing provider
+	Reference string     `json:"reference,omitempty" bson:"reference,omitempty"` // subscription ID at the billing provider
+	UpdatedAt time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// ActiveAt reports whether the subscription grants its tier at the given time
+func (s *Subscription) ActiveAt(now time.Time) bool {
+	return s != nil && s.Tier != TierFree && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+// TierAt returns the tier the account is entitled to at the given time. IsPremium is
+// the flag set before subscriptions existed and grants premium indefinitely.
+func (u *User) TierAt(now time.Time) Tier {
+	if u.Subscription.ActiveAt(now) {
+		return u.Subscription.Tier
+	}
+	if u.IsPremium {
+		return TierPremium
+	}
+	return TierFree
+}
+
+// HasPremium reports whether the account is entitled to premium features now
+func (u *User) HasPremium() bool {
+	return u.TierAt(time.Now()) == TierPremium
+}
+
+// SubscriptionRequest grants a tier through the admin API
+type SubscriptionRequest struct {
+	Tier      Tier       `json:"tier"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil never expires
+	Reference string     `json:"reference,omitempty"`
+}
+
+// Billing webhook event types
+const (
+	BillingEventActivated = "subscription.activated" // the subscription started or was renewed until expires_at
+	BillingEventCanceled  = "subscription.canceled"  // the subscription ends at expires_at, or now when unset
+)
+
+// BillingEvent is the body of a billing provider webhook
+type BillingEvent struct {
+	ID         string     `json:"id" validate:"required,max=200"`
+	Type       string     `json:"type" validate:"required,oneof=subscription.activated subscription.canceled"`
+	Username   string     `json:"username" validate:"required"`
+	Tier       Tier       `json:"tier,omitempty" validate:"omitempty,oneof=free premium"` // premium when unset
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Reference  string     `json:"reference,omitempty" validate:"max=200"`
+	OccurredAt time.Time  `json:"occurred_at" validate:"required"`
+}
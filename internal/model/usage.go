@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// DailyUsage counts what a user did on one UTC day, for the daily quotas
+type DailyUsage struct {
+	Username     string    `json:"-" bson:"username"`
+	Day          string    `json:"day" bson:"day"` // YYYY-MM-DD, UTC
+	ChatsStarted int       `json:"chats_started" bson:"chats_started"`
+	ChatSeconds  int64     `json:"chat_seconds" bson:"chat_seconds"` // time in rooms with a partner, added when the room closes
+	UpdatedAt    time.Time `json:"updated_at,omitempty" bson:"updated_at"`
+}
+
+// UsageDay returns the UTC day of t as stored in DailyUsage.Day
+func UsageDay(t time.Time) string {
+	return t.UTC().Format(chatDayLayout)
+}
+
+// UsageResetAt returns when the usage day of t ends and quotas start over
+func UsageResetAt(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+// ChatQuota is a user's chat usage today against the limits of their tier. A zero limit
+// is unlimited.
+type ChatQuota struct {
+	Tier           Tier      `json:"tier"`
+	ChatsStarted   int       `json:"chats_started"`
+	MaxChats       int       `json:"max_chats,omitempty"`
+	ChatMinutes    int       `json:"chat_minutes"`
+	MaxChatMinutes int       `json:"max_chat_minutes,omitempty"`
+	ResetAt        time.Time `json:"reset_at"`
+}
+
+// Exceeded reports whether a limit is used up, so no further chat may be started today
+func (q *ChatQuota) Exceeded() bool {
+	return (q.MaxChats > 0 && q.ChatsStarted >= q.MaxChats) ||
+		(q.MaxChatMinutes > 0 && q.ChatMinutes >= q.MaxChatMinutes)
+}
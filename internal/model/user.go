@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -9,6 +10,47 @@ import (
 
 type Gender string
 
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// ContentFilter is how strictly profanity is filtered in the user's chats
+type ContentFilter string
+
+const (
+	ContentFilterOff    ContentFilter = "off"    // messages are delivered unchanged
+	ContentFilterMedium ContentFilter = "medium" // profanity is masked and the message is flagged
+	ContentFilterStrict ContentFilter = "strict" // messages containing profanity are rejected
+)
+
+// contentFilterRank orders the levels from most to least permissive
+var contentFilterRank = map[ContentFilter]int{
+	ContentFilterOff:    0,
+	ContentFilterMedium: 1,
+	ContentFilterStrict: 2,
+}
+
+// StricterContentFilter returns the stricter of two levels
+func StricterContentFilter(a, b ContentFilter) ContentFilter {
+	if contentFilterRank[b] > contentFilterRank[a] {
+		return b
+	}
+	return a
+}
+
+// LastSeenVisibility is how precisely other users see when the user was last active
+type LastSeenVisibility string
+
+const (
+	LastSeenExact       LastSeenVisibility = "exact"       // last_seen and is_online as recorded
+	LastSeenApproximate LastSeenVisibility = "approximate" // last_seen rounded down to the UTC day
+	LastSeenHidden      LastSeenVisibility = "hidden"      // neither last_seen nor is_online
+)
+
 const (
 	GenderMale    Gender = "male"
 	GenderFemale  Gender = "female"
@@ -17,19 +59,62 @@ const (
 )
 
 type User struct {
-	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Username     string             `json:"username" bson:"username"`
-	Email        string             `json:"email" bson:"email"`
-	PasswordHash string             `json:"-" bson:"password_hash"`
-	Age          int                `json:"age,omitempty" bson:"age,omitempty"`
-	Gender       Gender             `json:"gender,omitempty" bson:"gender,omitempty"`
-	Bio          string             `json:"bio,omitempty" bson:"bio,omitempty"`
-	IsOnline     bool               `json:"is_online" bson:"is_online"`
-	IsVerified   bool               `json:"is_verified" bson:"is_verified"`
-	LastSeen     time.Time          `json:"last_seen" bson:"last_seen"`
-	JoinedAt     time.Time          `json:"joined_at" bson:"joined_at"`
-	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
-	RoomID       string             `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username       string             `json:"username" bson:"username"`
+	Email          string             `json:"email" bson:"email"`
+	PasswordHash   string             `json:"-" bson:"password_hash"`
+	Age            int                `json:"age,omitempty" bson:"age,omitempty"`
+	Gender         Gender             `json:"gender,omitempty" bson:"gender,omitempty"`
+	Bio            string             `json:"bio,omitempty" bson:"bio,omitempty"`
+	IsOnline       bool               `json:"is_online" bson:"is_online"`
+	IsVerified     bool               `json:"is_verified" bson:"is_verified"`
+	LastSeen       time.Time          `json:"last_seen" bson:"last_seen"`
+	JoinedAt       time.Time          `json:"joined_at" bson:"joined_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	RoomID         string             `json:"room_id,omitempty" bson:"room_id,omitempty"`
+	Languages      []string           `json:"languages,omitempty" bson:"languages,omitempty"`
+	TranslateOptIn bool               `json:"translate_opt_in" bson:"translate_opt_in"`
+	Role           Role               `json:"role,omitempty" bson:"role,omitempty"`
+	IsBanned       bool               `json:"is_banned" bson:"is_banned"`
+	IsPremium      bool               `json:"is_premium" bson:"is_premium"`
+	// Subscription is the paid entitlement, see TierAt
+	Subscription *Subscription `json:"subscription,omitempty" bson:"subscription,omitempty"`
+	BannedAt     *time.Time    `json:"banned_at,omitempty" bson:"banned_at,omitempty"`
+	// TwoFactorSecret is set by 2FA setup and only enforced once TwoFactorEnabled is true
+	TwoFactorEnabled bool     `json:"two_factor_enabled" bson:"two_factor_enabled"`
+	TwoFactorSecret  string   `json:"-" bson:"two_factor_secret"`
+	RecoveryCodes    []string `json:"-" bson:"recovery_codes"` // SHA-256 hashes of unused recovery codes
+	// FeaturedBadges are badge codes shown on the public profile, at most MaxFeaturedBadges
+	FeaturedBadges []string `json:"featured_badges,omitempty" bson:"featured_badges,omitempty"`
+	// ContentFilter is the profanity filter level for the user's chats, medium when unset
+	ContentFilter ContentFilter `json:"content_filter,omitempty" bson:"content_filter,omitempty"`
+	// Privacy settings: HideProfileViews stops counting views of the profile, and
+	// LastSeenVisibility is exact when unset
+	HideProfileViews   bool               `json:"hide_profile_views" bson:"hide_profile_views"`
+	LastSeenVisibility LastSeenVisibility `json:"last_seen_visibility,omitempty" bson:"last_seen_visibility,omitempty"`
+	// TimeZone is an IANA time zone name and ActiveHours the hours in it the user likes
+	// to chat, see Availability. Not omitted when empty so clearing them is saved.
+	TimeZone    string       `json:"time_zone,omitempty" bson:"time_zone"`
+	ActiveHours *ActiveHours `json:"active_hours,omitempty" bson:"active_hours"`
+}
+
+// Queue priorities, higher values are assigned rooms first
+const (
+	QueuePriorityNormal   = 0
+	QueuePriorityVerified = 1
+	QueuePriorityPremium  = 2
+)
+
+// QueuePriority returns the chat queue priority the account is entitled to
+func (u *User) QueuePriority() int {
+	switch {
+	case u.HasPremium():
+		return QueuePriorityPremium
+	case u.IsVerified:
+		return QueuePriorityVerified
+	default:
+		return QueuePriorityNormal
+	}
 }
 
 type OnlineUser struct {
@@ -38,13 +123,30 @@ type OnlineUser struct {
 	SendCh chan []byte     `json:"-"`
 }
 
+// NormalizeEmail returns the canonical form emails are stored and looked up in: trimmed
+// and lowercase
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeUsername trims a username and collapses its inner whitespace, keeping its case
+// for display. Usernames are unique regardless of case, see UsernameKey.
+func NormalizeUsername(username string) string {
+	return strings.Join(strings.Fields(username), " ")
+}
+
+// UsernameKey returns the form two usernames that only differ by case or whitespace share
+func UsernameKey(username string) string {
+	return strings.ToLower(NormalizeUsername(username))
+}
+
 // NewUser creates a new user
 func NewUser(username, email string) *User {
 	now := time.Now()
 	return &User{
 		ID:        primitive.NewObjectID(),
-		Username:  username,
-		Email:     email,
+		Username:  NormalizeUsername(username),
+		Email:     NormalizeEmail(email),
 		IsOnline:  false,
 		LastSeen:  now,
 		JoinedAt:  now,
@@ -100,6 +202,61 @@ func isValidEmail(email string) bool {
 	return atIndex > 0 && atIndex < len(email)-1
 }
 
+// NormalizeLanguages lowercases and de-duplicates language codes, keeping preference order
+func NormalizeLanguages(languages []string) []string {
+	seen := make(map[string]bool, len(languages))
+	normalized := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		normalized = append(normalized, lang)
+	}
+	return normalized
+}
+
+// EffectiveContentFilter returns the user's content filter level; users without one get medium
+func (u *User) EffectiveContentFilter() ContentFilter {
+	if u.ContentFilter == "" {
+		return ContentFilterMedium
+	}
+	return u.ContentFilter
+}
+
+// EffectiveLastSeenVisibility returns the user's last seen visibility; users without one get exact
+func (u *User) EffectiveLastSeenVisibility() LastSeenVisibility {
+	if u.LastSeenVisibility == "" {
+		return LastSeenExact
+	}
+	return u.LastSeenVisibility
+}
+
+// EffectiveRole returns the user's role; users without a stored role are regular users
+func (u *User) EffectiveRole() Role {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
+// HasRole reports whether the user has one of the given roles
+func (u *User) HasRole(roles ...Role) bool {
+	role := u.EffectiveRole()
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether the user is a moderator or admin
+func (u *User) IsStaff() bool {
+	return u.HasRole(RoleModerator, RoleAdmin)
+}
+
 func (u *User) UpdateLastSeen() {
 	u.LastSeen = time.Now()
 }
@@ -113,14 +270,21 @@ func (u *User) SetOnline(online bool) {
 
 func (u *User) ToPublicUser() map[string]interface{} {
 	public := map[string]interface{}{
-		"id":          u.ID,
-		"username":    u.Username,
+		"id":       u.ID,
+		"username": u.Username,
 		// nickname field removed - using username only
 		"is_online":   u.IsOnline,
 		"is_verified": u.IsVerified,
 		"last_seen":   u.LastSeen,
 		"joined_at":   u.JoinedAt,
 	}
+	switch u.LastSeenVisibility {
+	case LastSeenApproximate:
+		public["last_seen"] = u.LastSeen.UTC().Truncate(24 * time.Hour)
+	case LastSeenHidden:
+		delete(public, "last_seen")
+		delete(public, "is_online")
+	}
 
 	if u.Age > 0 {
 		public["age"] = u.Age
@@ -131,13 +295,57 @@ func (u *User) ToPublicUser() map[string]interface{} {
 	if u.Bio != "" {
 		public["bio"] = u.Bio
 	}
+	if len(u.Languages) > 0 {
+		public["languages"] = u.Languages
+	}
+	if len(u.FeaturedBadges) > 0 {
+		public["badges"] = u.FeaturedBadges
+	}
+	if u.HasPremium() {
+		public["premium"] = true
+	}
 
 	return public
 }
 
+// ToPartnerCard returns the profile shown to a chat partner: the public profile
+// without account activity details
+func (u *User) ToPartnerCard() map[string]interface{} {
+	card := u.ToPublicUser()
+	delete(card, "id")
+	delete(card, "is_online")
+	delete(card, "last_seen")
+	if u.JoinedAt.IsZero() {
+		delete(card, "joined_at") // bots and guests without an account
+	}
+	if IsBotUsername(u.Username) {
+		card["bot"] = true
+	}
+	return card
+}
+
 func (u *User) ToPrivateUser() map[string]interface{} {
 	private := u.ToPublicUser()
 	private["email"] = u.Email
+	private["translate_opt_in"] = u.TranslateOptIn
+	private["content_filter"] = u.EffectiveContentFilter()
+	private["last_seen"] = u.LastSeen
+	private["is_online"] = u.IsOnline
+	private["hide_profile_views"] = u.HideProfileViews
+	private["last_seen_visibility"] = u.EffectiveLastSeenVisibility()
+	private["tier"] = u.TierAt(time.Now())
+	if u.TimeZone != "" {
+		private["time_zone"] = u.TimeZone
+	}
+	if u.ActiveHours != nil {
+		private["active_hours"] = u.ActiveHours
+	}
+	if u.Subscription != nil {
+		private["subscription"] = u.Subscription
+	}
+	if u.IsStaff() {
+		private["role"] = u.Role
+	}
 	if u.Gender == GenderPrivate {
 		private["gender"] = u.Gender
 	}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// UserSort orders the admin user directory: a field, descending with a "-" prefix
+type UserSort string
+
+const (
+	UserSortJoined       UserSort = "joined_at"
+	UserSortJoinedDesc   UserSort = "-joined_at"
+	UserSortLastSeen     UserSort = "last_seen"
+	UserSortLastSeenDesc UserSort = "-last_seen"
+	UserSortUsername     UserSort = "username"
+	UserSortUsernameDesc UserSort = "-username"
+)
+
+// ParseUserSort returns the sort of a sort query value, joined_at when empty
+func ParseUserSort(value string) (UserSort, bool) {
+	switch sort := UserSort(value); sort {
+	case "":
+		return UserSortJoined, true
+	case UserSortJoined, UserSortJoinedDesc, UserSortLastSeen, UserSortLastSeenDesc, UserSortUsername, UserSortUsernameDesc:
+		return sort, true
+	}
+	return "", false
+}
+
+// Field returns the sorted field and whether the order is descending
+func (s UserSort) Field() (string, bool) {
+	if field, desc := strings.CutPrefix(string(s), "-"); desc {
+		return field, true
+	}
+	if s == "" {
+		return string(UserSortJoined), false
+	}
+	return string(s), false
+}
+
+// UserDirectoryQuery searches the admin user directory; unset criteria are ignored
+type UserDirectoryQuery struct {
+	Banned           *bool
+	Verified         *bool
+	RegisteredAfter  *time.Time
+	RegisteredBefore *time.Time
+	LastSeenBefore   *time.Time
+	// EmailDomain matches the part of the email after the @, ignoring case
+	EmailDomain string
+	Sort        UserSort
+}
+
+// UserDirectoryPage is a page of the admin user directory
+type UserDirectoryPage struct {
+	Users []map[string]interface{} `json:"users"` // see User.ToDirectoryEntry
+	Total int64                    `json:"total"` // users matching the query on all pages
+	Limit int                      `json:"limit"`
+	// NextCursor is passed back as ?cursor= for the next page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ToDirectoryEntry returns the user as the admin directory lists it: the private profile
+// with the moderation fields and the IP addresses of the user's sessions, which no
+// endpoint outside the admin API returns
+func (u *User) ToDirectoryEntry(ipAddresses []string) map[string]interface{} {
+	entry := u.ToPrivateUser()
+	entry["role"] = u.EffectiveRole()
+	entry["is_banned"] = u.IsBanned
+	if u.BannedAt != nil {
+		entry["banned_at"] = u.BannedAt
+	}
+	entry["two_factor_enabled"] = u.TwoFactorEnabled
+	if ipAddresses == nil {
+		ipAddresses = []string{}
+	}
+	entry["ip_addresses"] = ipAddresses
+	return entry
+}
@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// UserImportRecord is one account of an import file. An account carries either the bcrypt
+// or Argon2id hash of its password from the old system, or TempPassword to get a
+// generated password.
+type UserImportRecord struct {
+	Username     string   `json:"username" validate:"required,min=3,max=50,excludes=:"` // same rules as RegisterRequest
+	Email        string   `json:"email" validate:"required,email"`
+	PasswordHash string   `json:"password_hash,omitempty"`
+	TempPassword bool     `json:"temp_password,omitempty"`
+	Age          int      `json:"age" validate:"min=13,max=150"`
+	Gender       Gender   `json:"gender" validate:"oneof=male female other private"`
+	Bio          string   `json:"bio" validate:"max=500"`
+	Languages    []string `json:"languages,omitempty"`
+	Verified     bool     `json:"verified,omitempty"` // the email was verified by the old system
+}
+
+// UserImportError is a record that was not imported
+type UserImportError struct {
+	Row      int    `json:"row"` // 1-based position in the file, header excluded
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+// UserImportCredential is the generated password of an imported account, to be handed
+// to its owner
+type UserImportCredential struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UserImportJob tracks the progress of an account import. A dry run validates every
+// record and checks for taken usernames and emails without creating anything.
+type UserImportJob struct {
+	ID          string                 `json:"id"`
+	DryRun      bool                   `json:"dry_run"`
+	Status      BulkJobStatus          `json:"status"`
+	Total       int                    `json:"total"`
+	Processed   int                    `json:"processed"`
+	Created     int                    `json:"created"` // would be created, for a dry run
+	Failed      int                    `json:"failed"`
+	Errors      []UserImportError      `json:"errors,omitempty"` // the first MaxUserImportErrors failures
+	Credentials []UserImportCredential `json:"credentials,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	CreatedBy   string                 `json:"created_by"`
+	CreatedAt   time.Time              `json:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty"`
+}
+
+// MaxUserImportErrors caps the failures listed on an import job
+const MaxUserImportErrors = 1000
+
+func (j *UserImportJob) IsFinished() bool {
+	return j.Status == BulkJobCompleted || j.Status == BulkJobFailed
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	VerificationPurposeLogin = "login"
+	// VerificationPurposeLoginTOTP marks a pending login awaiting an authenticator or recovery code.
+	// It stores no code hash, the code is checked against the user's TOTP secret.
+	VerificationPurposeLoginTOTP = "login_totp"
+)
+
+const (
+	ChallengeMethodEmail = "email"
+	ChallengeMethodTOTP  = "totp"
+)
+
+// VerificationCode is a one-time code sent to the user to confirm a sensitive action.
+// Only the hash of the code is stored.
+type VerificationCode struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Purpose   string             `json:"purpose" bson:"purpose"`
+	CodeHash  string             `json:"-" bson:"code_hash"`
+	Attempts  int                `json:"attempts" bson:"attempts"`
+	IPAddress string             `json:"ip_address" bson:"ip_address"`
+	UserAgent string             `json:"user_agent" bson:"user_agent"`
+	IsUsed    bool               `json:"is_used" bson:"is_used"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func NewVerificationCode(userID primitive.ObjectID, purpose, codeHash, ipAddress, userAgent string, now time.Time, ttl time.Duration) *VerificationCode {
+	return &VerificationCode{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		CodeHash:  codeHash,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+func (v *VerificationCode) IsValidAt(now time.Time, maxAttempts int) bool {
+	return !v.IsUsed && now.Before(v.ExpiresAt) && v.Attempts < maxAttempts
+}
+
+// LoginChallenge is returned instead of tokens when a login needs an additional verification step
+type LoginChallenge struct {
+	ID        string    `json:"id"`
+	Method    string    `json:"method"`
+	Reasons   []string  `json:"reasons,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type LoginVerificationRequest struct {
+	ChallengeID string `json:"challenge_id" validate:"required"`
+	Code        string `json:"code" validate:"required"`
+}
+
+// TwoFactorSetupResponse carries the secret to add to an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorCodeRequest confirms a 2FA change with an authenticator code, or a recovery code where allowed
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
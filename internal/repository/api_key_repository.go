@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	// ListByUser returns the user's keys, newest first, including revoked ones
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*model.APIKey, error)
+	CountActiveByUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	// Revoke revokes an active key of the user and reports whether one was found
+	Revoke(ctx context.Context, id, userID primitive.ObjectID, at time.Time) (bool, error)
+	TouchLastUsed(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+type apiKeyRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewAPIKeyRepository(db *mongo.Database, collectionName string, timeout time.Duration) APIKeyRepository {
+	return &apiKeyRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareAPIKey(key)
+	_, err := r.collection.InsertOne(ctx, key)
+	return err
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var key model.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*model.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var keys []*model.APIKey
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) CountActiveByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}})
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id, userID primitive.ObjectID, at time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+func (r *apiKeyRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareAPIKey(key *model.APIKey) {
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, entry *model.AuditLog) error
+	Find(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error)
+	// CountBefore and DeleteBefore count and delete the entries recorded before the time,
+	// for the retention policy. Entries by or about held users, their reports included,
+	// are kept.
+	CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+	DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+}
+
+type auditRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewAuditRepository(db *mongo.Database, collectionName string, timeout time.Duration) AuditRepository {
+	return &auditRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *model.AuditLog) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// Find returns the newest audit entries matching the filter
+func (r *auditRepository) Find(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	if !filter.ActorID.IsZero() {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.Target != "" {
+		query["target"] = filter.Target
+	}
+	if !filter.Since.IsZero() {
+		query["created_at"] = bson.M{"$gte": filter.Since}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*model.AuditLog
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *auditRepository) CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, expiredAuditLogs(before, held))
+}
+
+func (r *auditRepository) DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, expiredAuditLogs(before, held))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *auditRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "target", Value: 1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+// expiredAuditLogs selects the entries recorded before the time whose actor and target
+// are not under a legal hold
+func expiredAuditLogs(before time.Time, held model.HeldUsers) bson.M {
+	filter := bson.M{"created_at": bson.M{"$lt": before}}
+	if len(held.UserIDs) > 0 {
+		filter["actor_id"] = bson.M{"$nin": held.UserIDs}
+	}
+	if len(held.Usernames) > 0 {
+		filter["target"] = bson.M{"$nin": held.Usernames}
+	}
+	return filter
+}
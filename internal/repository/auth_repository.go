@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"chatmix-backend/internal/model"
@@ -17,10 +18,13 @@ type RefreshTokenRepository interface {
 	Create(ctx context.Context, token *model.RefreshToken) error
 	GetByID(ctx context.Context, id primitive.ObjectID) (*model.RefreshToken, error)
 	GetByToken(ctx context.Context, token string) (*model.RefreshToken, error)
-	GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*model.RefreshToken, error)
+	// GetByUserID returns a page of the user's unrevoked refresh tokens, newest first
+	GetByUserID(ctx context.Context, userID primitive.ObjectID, page PageRequest) (*Page[*model.RefreshToken], error)
 	Update(ctx context.Context, token *model.RefreshToken) error
 	Revoke(ctx context.Context, id primitive.ObjectID) error
 	RevokeAllByUserID(ctx context.Context, userID primitive.ObjectID) error
+	// RevokeOthersByUserID revokes the user's refresh tokens except those issued with the session
+	RevokeOthersByUserID(ctx context.Context, userID, keepSessionID primitive.ObjectID) error
 	DeleteExpired(ctx context.Context) error
 }
 
@@ -28,11 +32,22 @@ type SessionRepository interface {
 	Create(ctx context.Context, session *model.Session) error
 	GetByID(ctx context.Context, id primitive.ObjectID) (*model.Session, error)
 	GetByToken(ctx context.Context, token string) (*model.Session, error)
-	GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*model.Session, error)
+	// GetByUserID returns a page of the user's active sessions, newest first
+	GetByUserID(ctx context.Context, userID primitive.ObjectID, page PageRequest) (*Page[*model.Session], error)
+	GetRecentByUserID(ctx context.Context, userID primitive.ObjectID, limit int) ([]*model.Session, error)
+	// IPAddressesByUsers returns the distinct IP addresses of each user's sessions
+	IPAddressesByUsers(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID][]string, error)
 	Update(ctx context.Context, session *model.Session) error
+	// Touch records activity on an active session without reactivating a revoked one
+	Touch(ctx context.Context, id primitive.ObjectID, lastUsed, expiresAt time.Time) error
 	DeactivateByToken(ctx context.Context, token string) error
 	DeactivateAllByUserID(ctx context.Context, userID primitive.ObjectID) error
+	DeactivateOthersByUserID(ctx context.Context, userID, keepID primitive.ObjectID) error
 	DeleteExpired(ctx context.Context) error
+	// CountExpiredBefore and DeleteExpiredBefore count and delete the sessions that
+	// expired before the time, for the retention policy. Sessions of held users are kept.
+	CountExpiredBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+	DeleteExpiredBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
 }
 
 type CaptchaRepository interface {
@@ -45,35 +60,44 @@ type CaptchaRepository interface {
 
 type refreshTokenRepository struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
 type sessionRepository struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
 type captchaRepository struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
-func NewRefreshTokenRepository(db *mongo.Database, collectionName string) RefreshTokenRepository {
+func NewRefreshTokenRepository(db *mongo.Database, collectionName string, timeout time.Duration) RefreshTokenRepository {
 	return &refreshTokenRepository{
 		collection: db.Collection(collectionName),
+		timeout:    timeout,
 	}
 }
 
-func NewSessionRepository(db *mongo.Database, collectionName string) SessionRepository {
+func NewSessionRepository(db *mongo.Database, collectionName string, timeout time.Duration) SessionRepository {
 	return &sessionRepository{
 		collection: db.Collection(collectionName),
+		timeout:    timeout,
 	}
 }
 
-func NewCaptchaRepository(db *mongo.Database, collectionName string) CaptchaRepository {
+func NewCaptchaRepository(db *mongo.Database, collectionName string, timeout time.Duration) CaptchaRepository {
 	return &captchaRepository{
 		collection: db.Collection(collectionName),
+		timeout:    timeout,
 	}
 }
 
 func (r *refreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	if token.ID.IsZero() {
 		token.ID = primitive.NewObjectID()
 	}
@@ -85,6 +109,9 @@ func (r *refreshTokenRepository) Create(ctx context.Context, token *model.Refres
 }
 
 func (r *refreshTokenRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var token model.RefreshToken
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&token)
 	if err != nil {
@@ -97,6 +124,9 @@ func (r *refreshTokenRepository) GetByID(ctx context.Context, id primitive.Objec
 }
 
 func (r *refreshTokenRepository) GetByToken(ctx context.Context, token string) (*model.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var refreshToken model.RefreshToken
 	err := r.collection.FindOne(ctx, bson.M{"token": token}).Decode(&refreshToken)
 	if err != nil {
@@ -108,25 +138,29 @@ func (r *refreshTokenRepository) GetByToken(ctx context.Context, token string) (
 	return &refreshToken, nil
 }
 
-func (r *refreshTokenRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*model.RefreshToken, error) {
+func (r *refreshTokenRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, page PageRequest) (*Page[*model.RefreshToken], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"user_id": userID, "is_revoked": false}
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findPage(ctx, r.collection, filter, newestFirst, page, refreshTokenCursor)
+}
 
-	cursor, err := r.collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
+// newestFirst sorts the tokens and sessions of GetByUserID
+var newestFirst = Sort{Field: "created_at", Desc: true}
 
-	var tokens []*model.RefreshToken
-	if err = cursor.All(ctx, &tokens); err != nil {
-		return nil, err
-	}
+func refreshTokenCursor(token *model.RefreshToken) Cursor {
+	return Cursor{Value: token.CreatedAt, ID: token.ID}
+}
 
-	return tokens, nil
+func sessionCursor(session *model.Session) Cursor {
+	return Cursor{Value: session.CreatedAt, ID: session.ID}
 }
 
 func (r *refreshTokenRepository) Update(ctx context.Context, token *model.RefreshToken) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"_id": token.ID}
 	update := bson.M{"$set": token}
 	_, err := r.collection.UpdateOne(ctx, filter, update)
@@ -134,6 +168,9 @@ func (r *refreshTokenRepository) Update(ctx context.Context, token *model.Refres
 }
 
 func (r *refreshTokenRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"_id": id}
 	update := bson.M{"$set": bson.M{"is_revoked": true}}
 	_, err := r.collection.UpdateOne(ctx, filter, update)
@@ -141,13 +178,29 @@ func (r *refreshTokenRepository) Revoke(ctx context.Context, id primitive.Object
 }
 
 func (r *refreshTokenRepository) RevokeAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"user_id": userID}
 	update := bson.M{"$set": bson.M{"is_revoked": true}}
 	_, err := r.collection.UpdateMany(ctx, filter, update)
 	return err
 }
 
+func (r *refreshTokenRepository) RevokeOthersByUserID(ctx context.Context, userID, keepSessionID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "session_id": bson.M{"$ne": keepSessionID}}
+	update := bson.M{"$set": bson.M{"is_revoked": true}}
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
 func (r *refreshTokenRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{
 		"$or": []bson.M{
 			{"expires_at": bson.M{"$lt": time.Now()}},
@@ -159,6 +212,9 @@ func (r *refreshTokenRepository) DeleteExpired(ctx context.Context) error {
 }
 
 func (r *sessionRepository) Create(ctx context.Context, session *model.Session) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	if session.ID.IsZero() {
 		session.ID = primitive.NewObjectID()
 	}
@@ -173,6 +229,9 @@ func (r *sessionRepository) Create(ctx context.Context, session *model.Session)
 }
 
 func (r *sessionRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var session model.Session
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
 	if err != nil {
@@ -185,6 +244,9 @@ func (r *sessionRepository) GetByID(ctx context.Context, id primitive.ObjectID)
 }
 
 func (r *sessionRepository) GetByToken(ctx context.Context, token string) (*model.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var session model.Session
 	err := r.collection.FindOne(ctx, bson.M{"token": token}).Decode(&session)
 	if err != nil {
@@ -196,11 +258,22 @@ func (r *sessionRepository) GetByToken(ctx context.Context, token string) (*mode
 	return &session, nil
 }
 
-func (r *sessionRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*model.Session, error) {
+func (r *sessionRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, page PageRequest) (*Page[*model.Session], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"user_id": userID, "is_active": true}
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findPage(ctx, r.collection, filter, newestFirst, page, sessionCursor)
+}
+
+// GetRecentByUserID returns the latest sessions of a user, including inactive ones
+func (r *sessionRepository) GetRecentByUserID(ctx context.Context, userID primitive.ObjectID, limit int) ([]*model.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
 
-	cursor, err := r.collection.Find(ctx, filter, opts)
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -214,14 +287,59 @@ func (r *sessionRepository) GetByUserID(ctx context.Context, userID primitive.Ob
 	return sessions, nil
 }
 
+func (r *sessionRepository) IPAddressesByUsers(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": bson.M{"$in": userIDs}, "ip_address": bson.M{"$ne": ""}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "ips": bson.M{"$addToSet": "$ip_address"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		UserID primitive.ObjectID `bson:"_id"`
+		IPs    []string           `bson:"ips"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	addresses := make(map[primitive.ObjectID][]string, len(results))
+	for _, result := range results {
+		sort.Strings(result.IPs)
+		addresses[result.UserID] = result.IPs
+	}
+	return addresses, nil
+}
+
 func (r *sessionRepository) Update(ctx context.Context, session *model.Session) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"_id": session.ID}
 	update := bson.M{"$set": session}
 	_, err := r.collection.UpdateOne(ctx, filter, update)
 	return err
 }
 
+func (r *sessionRepository) Touch(ctx context.Context, id primitive.ObjectID, lastUsed, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "is_active": true}
+	update := bson.M{"$set": bson.M{"last_used": lastUsed, "expires_at": expiresAt}}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
 func (r *sessionRepository) DeactivateByToken(ctx context.Context, token string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"token": token}
 	update := bson.M{"$set": bson.M{"is_active": false}}
 	_, err := r.collection.UpdateOne(ctx, filter, update)
@@ -229,13 +347,29 @@ func (r *sessionRepository) DeactivateByToken(ctx context.Context, token string)
 }
 
 func (r *sessionRepository) DeactivateAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"user_id": userID}
 	update := bson.M{"$set": bson.M{"is_active": false}}
 	_, err := r.collection.UpdateMany(ctx, filter, update)
 	return err
 }
 
+func (r *sessionRepository) DeactivateOthersByUserID(ctx context.Context, userID, keepID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "_id": bson.M{"$ne": keepID}}
+	update := bson.M{"$set": bson.M{"is_active": false}}
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
 func (r *sessionRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{
 		"$or": []bson.M{
 			{"expires_at": bson.M{"$lt": time.Now()}},
@@ -246,7 +380,38 @@ func (r *sessionRepository) DeleteExpired(ctx context.Context) error {
 	return err
 }
 
+func (r *sessionRepository) CountExpiredBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, expiredSessions(before, held))
+}
+
+func (r *sessionRepository) DeleteExpiredBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, expiredSessions(before, held))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// expiredSessions selects the sessions that expired before the time of users not under a
+// legal hold
+func expiredSessions(before time.Time, held model.HeldUsers) bson.M {
+	filter := bson.M{"expires_at": bson.M{"$lt": before}}
+	if len(held.UserIDs) > 0 {
+		filter["user_id"] = bson.M{"$nin": held.UserIDs}
+	}
+	return filter
+}
+
 func (r *captchaRepository) Create(ctx context.Context, captcha *model.CaptchaChallenge) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	if captcha.ID.IsZero() {
 		captcha.ID = primitive.NewObjectID()
 	}
@@ -258,6 +423,9 @@ func (r *captchaRepository) Create(ctx context.Context, captcha *model.CaptchaCh
 }
 
 func (r *captchaRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.CaptchaChallenge, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var captcha model.CaptchaChallenge
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&captcha)
 	if err != nil {
@@ -270,6 +438,9 @@ func (r *captchaRepository) GetByID(ctx context.Context, id primitive.ObjectID)
 }
 
 func (r *captchaRepository) Update(ctx context.Context, captcha *model.CaptchaChallenge) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"_id": captcha.ID}
 	update := bson.M{"$set": captcha}
 	_, err := r.collection.UpdateOne(ctx, filter, update)
@@ -277,6 +448,9 @@ func (r *captchaRepository) Update(ctx context.Context, captcha *model.CaptchaCh
 }
 
 func (r *captchaRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{
 		"$or": []bson.M{
 			{"expires_at": bson.M{"$lt": time.Now()}},
@@ -288,6 +462,9 @@ func (r *captchaRepository) DeleteExpired(ctx context.Context) error {
 }
 
 func (r *captchaRepository) DeleteByIPAddress(ctx context.Context, ipAddress string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"ip_address": ipAddress}
 	_, err := r.collection.DeleteMany(ctx, filter)
 	return err
@@ -310,8 +487,7 @@ func (r *refreshTokenRepository) CreateIndexes(ctx context.Context) error {
 		},
 	}
 
-	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
-	return err
+	return ensureIndexes(ctx, r.collection, indexes)
 }
 
 func (r *sessionRepository) CreateIndexes(ctx context.Context) error {
@@ -331,8 +507,7 @@ func (r *sessionRepository) CreateIndexes(ctx context.Context) error {
 		},
 	}
 
-	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
-	return err
+	return ensureIndexes(ctx, r.collection, indexes)
 }
 
 func (r *captchaRepository) CreateIndexes(ctx context.Context) error {
@@ -348,6 +523,5 @@ func (r *captchaRepository) CreateIndexes(ctx context.Context) error {
 		},
 	}
 
-	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
-	return err
+	return ensureIndexes(ctx, r.collection, indexes)
 }
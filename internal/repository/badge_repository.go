@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BadgeRepository interface {
+	// Award stores the badge unless the user already has it, and reports whether it was new
+	Award(ctx context.Context, badge *model.UserBadge) (bool, error)
+	GetByUsername(ctx context.Context, username string) ([]*model.UserBadge, error)
+}
+
+type badgeRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewBadgeRepository(db *mongo.Database, collectionName string, timeout time.Duration) BadgeRepository {
+	return &badgeRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *badgeRepository) Award(ctx context.Context, badge *model.UserBadge) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareUserBadge(badge)
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"username": badge.Username, "code": badge.Code},
+		bson.M{"$setOnInsert": badge},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+// GetByUsername returns the user's badges, oldest first
+func (r *badgeRepository) GetByUsername(ctx context.Context, username string) ([]*model.UserBadge, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "awarded_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"username": username}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var badges []*model.UserBadge
+	if err = cursor.All(ctx, &badges); err != nil {
+		return nil, err
+	}
+	return badges, nil
+}
+
+func (r *badgeRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}, {Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareUserBadge(badge *model.UserBadge) {
+	if badge.ID.IsZero() {
+		badge.ID = primitive.NewObjectID()
+	}
+	if badge.AwardedAt.IsZero() {
+		badge.AwardedAt = time.Now()
+	}
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BlocklistRepository interface {
+	Create(ctx context.Context, entry *model.BlocklistEntry) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*model.BlocklistEntry, error)
+	List(ctx context.Context) ([]*model.BlocklistEntry, error)
+	// Update stores an entry whose Version was incremented, unless the stored entry is no
+	// longer at the previous version. It reports whether the entry was stored.
+	Update(ctx context.Context, entry *model.BlocklistEntry) (bool, error)
+	// Delete removes an entry and returns it, or nil when it does not exist
+	Delete(ctx context.Context, id primitive.ObjectID) (*model.BlocklistEntry, error)
+}
+
+type blocklistRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewBlocklistRepository(db *mongo.Database, collectionName string, timeout time.Duration) BlocklistRepository {
+	return &blocklistRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *blocklistRepository) Create(ctx context.Context, entry *model.BlocklistEntry) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareBlocklistEntry(entry)
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *blocklistRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.BlocklistEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var entry model.BlocklistEntry
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// List returns every entry, oldest first
+func (r *blocklistRepository) List(ctx context.Context) ([]*model.BlocklistEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*model.BlocklistEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *blocklistRepository) Update(ctx context.Context, entry *model.BlocklistEntry) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": entry.ID, "version": entry.Version - 1}, bson.M{"$set": bson.M{
+		"pattern":    entry.Pattern,
+		"regex":      entry.Regex,
+		"severity":   entry.Severity,
+		"language":   entry.Language,
+		"version":    entry.Version,
+		"updated_by": entry.UpdatedBy,
+		"updated_at": entry.UpdatedAt,
+	}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *blocklistRepository) Delete(ctx context.Context, id primitive.ObjectID) (*model.BlocklistEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var entry model.BlocklistEntry
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *blocklistRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "created_at", Value: 1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareBlocklistEntry(entry *model.BlocklistEntry) {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = entry.CreatedAt
+	}
+	if entry.Version == 0 {
+		entry.Version = 1
+	}
+}
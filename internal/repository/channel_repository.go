@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ChannelRepository interface {
+	// Create stores the channel unless the slug is taken, and reports whether it was created
+	Create(ctx context.Context, channel *model.Channel) (bool, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Channel, error)
+	List(ctx context.Context) ([]*model.Channel, error)
+	Update(ctx context.Context, channel *model.Channel) error
+	Delete(ctx context.Context, slug string) (bool, error)
+}
+
+type channelRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewChannelRepository(db *mongo.Database, collectionName string, timeout time.Duration) ChannelRepository {
+	return &channelRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *channelRepository) Create(ctx context.Context, channel *model.Channel) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareChannel(channel)
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"slug": channel.Slug},
+		bson.M{"$setOnInsert": channel},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (r *channelRepository) GetBySlug(ctx context.Context, slug string) (*model.Channel, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var channel model.Channel
+	err := r.collection.FindOne(ctx, bson.M{"slug": slug}).Decode(&channel)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// List returns every channel ordered by slug
+func (r *channelRepository) List(ctx context.Context) ([]*model.Channel, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "slug", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var channels []*model.Channel
+	if err = cursor.All(ctx, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// Update replaces the name and description of a channel
+func (r *channelRepository) Update(ctx context.Context, channel *model.Channel) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	channel.UpdatedAt = time.Now()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"slug": channel.Slug}, bson.M{"$set": bson.M{
+		"name":        channel.Name,
+		"description": channel.Description,
+		"updated_at":  channel.UpdatedAt,
+	}})
+	return err
+}
+
+func (r *channelRepository) Delete(ctx context.Context, slug string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"slug": slug})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *channelRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareChannel(channel *model.Channel) {
+	if channel.ID.IsZero() {
+		channel.ID = primitive.NewObjectID()
+	}
+	if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = time.Now()
+	}
+	if channel.UpdatedAt.IsZero() {
+		channel.UpdatedAt = channel.CreatedAt
+	}
+}
+
+type ChannelMemberRepository interface {
+	// Add stores the membership unless it exists, and reports whether it was new
+	Add(ctx context.Context, member *model.ChannelMember) (bool, error)
+	Remove(ctx context.Context, channel, username string) (bool, error)
+	IsMember(ctx context.Context, channel, username string) (bool, error)
+	// CountByChannel returns the member count of every channel that has members
+	CountByChannel(ctx context.Context) (map[string]int64, error)
+	DeleteByChannel(ctx context.Context, channel string) error
+}
+
+type channelMemberRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewChannelMemberRepository(db *mongo.Database, collectionName string, timeout time.Duration) ChannelMemberRepository {
+	return &channelMemberRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *channelMemberRepository) Add(ctx context.Context, member *model.ChannelMember) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareChannelMember(member)
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"channel": member.Channel, "username": member.Username},
+		bson.M{"$setOnInsert": member},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (r *channelMemberRepository) Remove(ctx context.Context, channel, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"channel": channel, "username": username})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *channelMemberRepository) IsMember(ctx context.Context, channel, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"channel": channel, "username": username},
+		options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *channelMemberRepository) CountByChannel(ctx context.Context) (map[string]int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$channel", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Channel string `bson:"_id"`
+		Count   int64  `bson:"count"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(results))
+	for _, result := range results {
+		counts[result.Channel] = result.Count
+	}
+	return counts, nil
+}
+
+func (r *channelMemberRepository) DeleteByChannel(ctx context.Context, channel string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"channel": channel})
+	return err
+}
+
+func (r *channelMemberRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "channel", Value: 1}, {Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareChannelMember(member *model.ChannelMember) {
+	if member.ID.IsZero() {
+		member.ID = primitive.NewObjectID()
+	}
+	if member.JoinedAt.IsZero() {
+		member.JoinedAt = time.Now()
+	}
+}
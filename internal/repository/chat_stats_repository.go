@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ChatStatsRepository interface {
+	// Increment adds the counters of delta to the user's stats, creating them if needed,
+	// and sets the streak fields of delta
+	Increment(ctx context.Context, delta *model.ChatStats) error
+	GetByUsername(ctx context.Context, username string) (*model.ChatStats, error)
+	Aggregate(ctx context.Context) (*model.GlobalChatStats, error)
+}
+
+type chatStatsRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewChatStatsRepository(db *mongo.Database, collectionName string, timeout time.Duration) ChatStatsRepository {
+	return &chatStatsRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *chatStatsRepository) Increment(ctx context.Context, delta *model.ChatStats) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"username": delta.Username}, bson.M{
+		"$inc": bson.M{
+			"total_chats":       delta.TotalChats,
+			"total_messages":    delta.TotalMessages,
+			"total_duration_ms": delta.TotalDuration,
+			"skips":             delta.Skips,
+		},
+		"$set": bson.M{
+			"current_streak": delta.CurrentStreak,
+			"longest_streak": delta.LongestStreak,
+			"last_chat_day":  delta.LastChatDay,
+			"updated_at":     time.Now(),
+		},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *chatStatsRepository) GetByUsername(ctx context.Context, username string) (*model.ChatStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stats model.ChatStats
+	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&stats)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *chatStatsRepository) Aggregate(ctx context.Context) (*model.GlobalChatStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":               nil,
+			"users":             bson.M{"$sum": 1},
+			"total_chats":       bson.M{"$sum": "$total_chats"},
+			"total_messages":    bson.M{"$sum": "$total_messages"},
+			"total_duration_ms": bson.M{"$sum": "$total_duration_ms"},
+			"skips":             bson.M{"$sum": "$skips"},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Users         int64 `bson:"users"`
+		TotalChats    int64 `bson:"total_chats"`
+		TotalMessages int64 `bson:"total_messages"`
+		TotalDuration int64 `bson:"total_duration_ms"`
+		Skips         int64 `bson:"skips"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return &model.GlobalChatStats{
+		ChatStats: model.ChatStats{
+			TotalChats:    result.TotalChats,
+			TotalMessages: result.TotalMessages,
+			TotalDuration: result.TotalDuration,
+			Skips:         result.Skips,
+		},
+		Users: result.Users,
+	}, nil
+}
+
+func (r *chatStatsRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
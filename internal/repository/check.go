@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"chatmix-backend/internal/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CheckDatabase connects to the configured database and returns what the server would
+// change on startup: missing MongoDB indexes or pending PostgreSQL migrations. It changes
+// nothing, for preflight checks against production databases.
+func CheckDatabase(ctx context.Context, cfg *config.Config) ([]string, error) {
+	if cfg.GetDatabaseDriver() == config.DriverPostgres {
+		return checkPostgres(ctx, cfg)
+	}
+
+	database, err := connectMongo(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer database.Close(context.Background())
+
+	check := &indexCheck{}
+	if err := database.createIndexes(context.WithValue(ctx, indexCheckKey{}, check)); err != nil {
+		return nil, err
+	}
+	return check.missing, nil
+}
+
+// indexCheckKey marks a context under which ensureIndexes only records missing indexes
+type indexCheckKey struct{}
+
+type indexCheck struct {
+	missing []string // "<collection> {<keys>}"
+}
+
+// ensureIndexes creates the indexes of a collection, or under CheckDatabase records the
+// ones that do not exist
+func ensureIndexes(ctx context.Context, collection *mongo.Collection, indexes []mongo.IndexModel) error {
+	check, ok := ctx.Value(indexCheckKey{}).(*indexCheck)
+	if !ok {
+		_, err := collection.Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+
+	specs, err := collection.Indexes().ListSpecifications(ctx)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == namespaceNotFound {
+		specs, err = nil, nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list indexes of %s: %w", collection.Name(), err)
+	}
+
+	// Indexes with a name, e.g. a collation variant of another index, are matched by name
+	existing := make(map[string]bool, 2*len(specs))
+	for _, spec := range specs {
+		existing[indexKeys(spec.KeysDocument)] = true
+		existing[spec.Name] = true
+	}
+	for _, index := range indexes {
+		raw, err := bson.Marshal(index.Keys)
+		if err != nil {
+			return err
+		}
+		keys := indexKeys(raw)
+		if index.Options != nil && index.Options.Name != nil {
+			if !existing[*index.Options.Name] {
+				check.missing = append(check.missing, fmt.Sprintf("%s %s %s", collection.Name(), *index.Options.Name, keys))
+			}
+			continue
+		}
+		if !existing[keys] {
+			check.missing = append(check.missing, fmt.Sprintf("%s %s", collection.Name(), keys))
+		}
+	}
+	return nil
+}
+
+// namespaceNotFound is the error servers before MongoDB 4.4 return for listing the
+// indexes of a collection that does not exist yet
+const namespaceNotFound = 26
+
+// indexKeys formats the keys of an index as "{field: 1, other: -1}", whatever numeric
+// type the directions were stored with
+func indexKeys(raw bson.Raw) string {
+	var keys bson.D
+	if err := bson.Unmarshal(raw, &keys); err != nil {
+		return raw.String()
+	}
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s: %v", key.Key, key.Value)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// checkPostgres returns the migrations that have not been applied
+func checkPostgres(ctx context.Context, cfg *config.Config) ([]string, error) {
+	db, err := sql.Open("postgres", cfg.Database.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	var table sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations')::text`).Scan(&table); err != nil {
+		return nil, err
+	}
+	if table.Valid {
+		rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			if err := rows.Scan(&version); err != nil {
+				return nil, err
+			}
+			applied[version] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := fs.ReadDir(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.SplitN(entry.Name(), "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		if !applied[version] {
+			pending = append(pending, "migration "+entry.Name())
+		}
+	}
+	return pending, nil
+}
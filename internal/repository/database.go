@@ -2,52 +2,104 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
 
 	"chatmix-backend/internal/config"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 type Database struct {
-	Client           *mongo.Client
-	DB               *mongo.Database
-	UserRepo         UserRepository
-	RefreshTokenRepo RefreshTokenRepository
-	SessionRepo      SessionRepository
-	CaptchaRepo      CaptchaRepository
+	Client            *mongo.Client
+	DB                *mongo.Database
+	SQL               *sql.DB
+	UserRepo          UserRepository
+	RefreshTokenRepo  RefreshTokenRepository
+	SessionRepo       SessionRepository
+	CaptchaRepo       CaptchaRepository
+	MessageRepo       MessageRepository
+	AuditRepo         AuditRepository
+	NotificationRepo  NotificationRepository
+	VerificationRepo  VerificationCodeRepository
+	IcebreakerRepo    IcebreakerRepository
+	ChatStatsRepo     ChatStatsRepository
+	BadgeRepo         BadgeRepository
+	APIKeyRepo        APIKeyRepository
+	ChannelRepo       ChannelRepository
+	ChannelMemberRepo ChannelMemberRepository
+	BlocklistRepo     BlocklistRepository
+	ProfileViewRepo   ProfileViewRepository
+	UsageRepo         UsageRepository
+	RoomEventRepo     RoomEventRepository
+	LegalHoldRepo     LegalHoldRepository
+	MatchStatsRepo    MatchStatsRepository
+	ExperimentRepo    ExperimentRepository
+	SanctionRepo      SanctionRepository
+	NetworkBanRepo    NetworkBanRepository
+	DisposableRepo    DisposableDomainRepository
 }
 
 func NewDatabase(cfg *config.Config) (*Database, error) {
-	clientOptions := options.Client().ApplyURI(cfg.Database.URI)
-
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.Timeout)
-	defer cancel()
+	switch cfg.GetDatabaseDriver() {
+	case config.DriverPostgres:
+		return newPostgresDatabase(cfg)
+	default:
+		return newMongoDatabase(cfg)
+	}
+}
 
-	client, err := mongo.Connect(ctx, clientOptions)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+// withTimeout bounds a single repository operation. The caller's deadline still applies if it is sooner.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, timeout)
+}
 
-	if err = client.Ping(ctx, nil); err != nil {
-		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+// mongoClientOptions applies the pool, timeout and concern settings on top of the connection URI
+func mongoClientOptions(cfg config.DatabaseConfig) *options.ClientOptions {
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+
+	if cfg.Pool.MaxSize > 0 {
+		clientOptions.SetMaxPoolSize(cfg.Pool.MaxSize)
+	}
+	if cfg.Pool.MinSize > 0 {
+		clientOptions.SetMinPoolSize(cfg.Pool.MinSize)
+	}
+	if cfg.Pool.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(cfg.Pool.MaxConnIdleTime)
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(cfg.ServerSelectionTimeout)
+	}
+	if cfg.ReadConcern != "" {
+		clientOptions.SetReadConcern(readconcern.New(readconcern.Level(cfg.ReadConcern)))
+	}
+	switch cfg.WriteConcern {
+	case "":
+	case "majority":
+		clientOptions.SetWriteConcern(writeconcern.Majority())
+	default:
+		nodes, _ := strconv.Atoi(cfg.WriteConcern) // validated by config
+		clientOptions.SetWriteConcern(&writeconcern.WriteConcern{W: nodes})
 	}
 
-	db := client.Database(cfg.Database.Name)
+	return clientOptions
+}
 
-	userRepo := NewUserRepository(db, cfg.Database.Collections.Users)
-	refreshTokenRepo := NewRefreshTokenRepository(db, cfg.Database.Collections.RefreshTokens)
-	sessionRepo := NewSessionRepository(db, cfg.Database.Collections.Sessions)
-	captchaRepo := NewCaptchaRepository(db, cfg.Database.Collections.Captchas)
+func newMongoDatabase(cfg *config.Config) (*Database, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.Timeout)
+	defer cancel()
 
-	database := &Database{
-		Client:           client,
-		DB:               db,
-		UserRepo:         userRepo,
-		RefreshTokenRepo: refreshTokenRepo,
-		SessionRepo:      sessionRepo,
-		CaptchaRepo:      captchaRepo,
+	database, err := connectMongo(ctx, cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create indexes
@@ -58,7 +110,79 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	return database, nil
 }
 
+// connectMongo connects to MongoDB and sets up the repositories, without creating indexes
+func connectMongo(ctx context.Context, cfg *config.Config) (*Database, error) {
+	client, err := mongo.Connect(ctx, mongoClientOptions(cfg.Database))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	if err = client.Ping(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	db := client.Database(cfg.Database.Name)
+	timeout := cfg.Database.OperationTimeout
+
+	userRepo := NewUserRepository(db, cfg.Database.Collections.Users, timeout)
+	refreshTokenRepo := NewRefreshTokenRepository(db, cfg.Database.Collections.RefreshTokens, timeout)
+	sessionRepo := NewSessionRepository(db, cfg.Database.Collections.Sessions, timeout)
+	captchaRepo := NewCaptchaRepository(db, cfg.Database.Collections.Captchas, timeout)
+	messageRepo := NewMessageRepository(db, cfg.Database.Collections.Messages, timeout)
+	auditRepo := NewAuditRepository(db, cfg.Database.Collections.AuditLogs, timeout)
+	notificationRepo := NewNotificationRepository(db, cfg.Database.Collections.Notifications, timeout)
+	verificationRepo := NewVerificationCodeRepository(db, cfg.Database.Collections.VerificationCodes, timeout)
+	icebreakerRepo := NewIcebreakerRepository(db, cfg.Database.Collections.Icebreakers, timeout)
+	chatStatsRepo := NewChatStatsRepository(db, cfg.Database.Collections.ChatStats, timeout)
+	badgeRepo := NewBadgeRepository(db, cfg.Database.Collections.Badges, timeout)
+	apiKeyRepo := NewAPIKeyRepository(db, cfg.Database.Collections.APIKeys, timeout)
+	channelRepo := NewChannelRepository(db, cfg.Database.Collections.Channels, timeout)
+	channelMemberRepo := NewChannelMemberRepository(db, cfg.Database.Collections.ChannelMembers, timeout)
+	blocklistRepo := NewBlocklistRepository(db, cfg.Database.Collections.Blocklist, timeout)
+	profileViewRepo := NewProfileViewRepository(db, cfg.Database.Collections.ProfileViews, timeout)
+	usageRepo := NewUsageRepository(db, cfg.Database.Collections.DailyUsage, timeout)
+	roomEventRepo := NewRoomEventRepository(db, cfg.Database.Collections.RoomEvents, timeout)
+	legalHoldRepo := NewLegalHoldRepository(db, cfg.Database.Collections.LegalHolds, timeout)
+	matchStatsRepo := NewMatchStatsRepository(db, cfg.Database.Collections.MatchStats, timeout)
+	experimentRepo := NewExperimentRepository(db, cfg.Database.Collections.Experiments, timeout)
+	sanctionRepo := NewSanctionRepository(db, cfg.Database.Collections.Sanctions, timeout)
+	networkBanRepo := NewNetworkBanRepository(db, cfg.Database.Collections.NetworkBans, timeout)
+	disposableRepo := NewDisposableDomainRepository(db, cfg.Database.Collections.DisposableDomains, timeout)
+
+	return &Database{
+		Client:            client,
+		DB:                db,
+		UserRepo:          userRepo,
+		RefreshTokenRepo:  refreshTokenRepo,
+		SessionRepo:       sessionRepo,
+		CaptchaRepo:       captchaRepo,
+		MessageRepo:       messageRepo,
+		AuditRepo:         auditRepo,
+		NotificationRepo:  notificationRepo,
+		VerificationRepo:  verificationRepo,
+		IcebreakerRepo:    icebreakerRepo,
+		ChatStatsRepo:     chatStatsRepo,
+		BadgeRepo:         badgeRepo,
+		APIKeyRepo:        apiKeyRepo,
+		ChannelRepo:       channelRepo,
+		ChannelMemberRepo: channelMemberRepo,
+		BlocklistRepo:     blocklistRepo,
+		ProfileViewRepo:   profileViewRepo,
+		UsageRepo:         usageRepo,
+		RoomEventRepo:     roomEventRepo,
+		LegalHoldRepo:     legalHoldRepo,
+		MatchStatsRepo:    matchStatsRepo,
+		ExperimentRepo:    experimentRepo,
+		SanctionRepo:      sanctionRepo,
+		NetworkBanRepo:    networkBanRepo,
+		DisposableRepo:    disposableRepo,
+	}, nil
+}
+
 func (d *Database) Close(ctx context.Context) error {
+	if d.SQL != nil {
+		return d.SQL.Close()
+	}
 	if d.Client != nil {
 		return d.Client.Disconnect(ctx)
 	}
@@ -90,5 +214,125 @@ func (d *Database) createIndexes(ctx context.Context) error {
 		}
 	}
 
+	if messageRepo, ok := d.MessageRepo.(*messageRepository); ok {
+		if err := messageRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create message indexes: %w", err)
+		}
+	}
+
+	if auditRepo, ok := d.AuditRepo.(*auditRepository); ok {
+		if err := auditRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create audit log indexes: %w", err)
+		}
+	}
+
+	if notificationRepo, ok := d.NotificationRepo.(*notificationRepository); ok {
+		if err := notificationRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create notification indexes: %w", err)
+		}
+	}
+
+	if verificationRepo, ok := d.VerificationRepo.(*verificationCodeRepository); ok {
+		if err := verificationRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create verification code indexes: %w", err)
+		}
+	}
+
+	if icebreakerRepo, ok := d.IcebreakerRepo.(*icebreakerRepository); ok {
+		if err := icebreakerRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create icebreaker indexes: %w", err)
+		}
+	}
+
+	if blocklistRepo, ok := d.BlocklistRepo.(*blocklistRepository); ok {
+		if err := blocklistRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create blocklist indexes: %w", err)
+		}
+	}
+
+	if profileViewRepo, ok := d.ProfileViewRepo.(*profileViewRepository); ok {
+		if err := profileViewRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create profile view indexes: %w", err)
+		}
+	}
+
+	if usageRepo, ok := d.UsageRepo.(*usageRepository); ok {
+		if err := usageRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create daily usage indexes: %w", err)
+		}
+	}
+
+	if roomEventRepo, ok := d.RoomEventRepo.(*roomEventRepository); ok {
+		if err := roomEventRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create room event indexes: %w", err)
+		}
+	}
+
+	if legalHoldRepo, ok := d.LegalHoldRepo.(*legalHoldRepository); ok {
+		if err := legalHoldRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create legal hold indexes: %w", err)
+		}
+	}
+
+	if matchStatsRepo, ok := d.MatchStatsRepo.(*matchStatsRepository); ok {
+		if err := matchStatsRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create match stats indexes: %w", err)
+		}
+	}
+
+	if experimentRepo, ok := d.ExperimentRepo.(*experimentRepository); ok {
+		if err := experimentRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create experiment indexes: %w", err)
+		}
+	}
+
+	if sanctionRepo, ok := d.SanctionRepo.(*sanctionRepository); ok {
+		if err := sanctionRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create sanction indexes: %w", err)
+		}
+	}
+
+	if networkBanRepo, ok := d.NetworkBanRepo.(*networkBanRepository); ok {
+		if err := networkBanRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create network ban indexes: %w", err)
+		}
+	}
+
+	if disposableRepo, ok := d.DisposableRepo.(*disposableDomainRepository); ok {
+		if err := disposableRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create disposable domain indexes: %w", err)
+		}
+	}
+
+	if chatStatsRepo, ok := d.ChatStatsRepo.(*chatStatsRepository); ok {
+		if err := chatStatsRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create chat stats indexes: %w", err)
+		}
+	}
+
+	if badgeRepo, ok := d.BadgeRepo.(*badgeRepository); ok {
+		if err := badgeRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create badge indexes: %w", err)
+		}
+	}
+
+	if apiKeyRepo, ok := d.APIKeyRepo.(*apiKeyRepository); ok {
+		if err := apiKeyRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create API key indexes: %w", err)
+		}
+	}
+
+	if channelRepo, ok := d.ChannelRepo.(*channelRepository); ok {
+		if err := channelRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create channel indexes: %w", err)
+		}
+	}
+
+	if channelMemberRepo, ok := d.ChannelMemberRepo.(*channelMemberRepository); ok {
+		if err := channelMemberRepo.CreateIndexes(ctx); err != nil {
+			return fmt.Errorf("failed to create channel member indexes: %w", err)
+		}
+	}
+
 	return nil
 }
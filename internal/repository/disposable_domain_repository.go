@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DisposableDomainRepository interface {
+	// List returns every override, by domain
+	List(ctx context.Context) ([]*model.DisposableDomain, error)
+	// Upsert stores the override of its domain, replacing an earlier one
+	Upsert(ctx context.Context, domain *model.DisposableDomain) error
+	// Delete removes the override of a domain and reports whether there was one
+	Delete(ctx context.Context, domain string) (bool, error)
+}
+
+type disposableDomainRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewDisposableDomainRepository(db *mongo.Database, collectionName string, timeout time.Duration) DisposableDomainRepository {
+	return &disposableDomainRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *disposableDomainRepository) List(ctx context.Context) ([]*model.DisposableDomain, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "domain", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var domains []*model.DisposableDomain
+	if err = cursor.All(ctx, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+func (r *disposableDomainRepository) Upsert(ctx context.Context, domain *model.DisposableDomain) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareDisposableDomain(domain)
+	update := bson.M{
+		"$set": bson.M{
+			"blocked":    domain.Blocked,
+			"updated_by": domain.UpdatedBy,
+			"updated_at": domain.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{"_id": domain.ID},
+	}
+	var stored model.DisposableDomain
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"domain": domain.Domain}, update, opts).Decode(&stored); err != nil {
+		return err
+	}
+	domain.ID = stored.ID
+	return nil
+}
+
+func (r *disposableDomainRepository) Delete(ctx context.Context, domain string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"domain": domain})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *disposableDomainRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "domain", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareDisposableDomain(domain *model.DisposableDomain) {
+	if domain.ID.IsZero() {
+		domain.ID = primitive.NewObjectID()
+	}
+	if domain.UpdatedAt.IsZero() {
+		domain.UpdatedAt = time.Now()
+	}
+}
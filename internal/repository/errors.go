@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrDuplicate matches every DuplicateError with errors.Is
+var ErrDuplicate = errors.New("duplicate key")
+
+// DuplicateError is returned when a write conflicts with a unique index. Field names the
+// conflicting field when the index is known.
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Field == "" {
+		return ErrDuplicate.Error()
+	}
+	return e.Field + " already exists"
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// mongoDuplicate turns a duplicate key error on the single-field unique index of one of the
+// fields into a DuplicateError and returns other errors unchanged
+func mongoDuplicate(err error, fields ...string) error {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	for _, field := range fields {
+		if strings.Contains(err.Error(), "index: "+field+"_1 ") || strings.Contains(err.Error(), "index: "+field+"_ci ") {
+			return &DuplicateError{Field: field}
+		}
+	}
+	return &DuplicateError{}
+}
+
+// postgresDuplicate is mongoDuplicate for unique violations of the <table>_<field>_key
+// constraints Postgres names by default
+func postgresDuplicate(err error, table string, fields ...string) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+	for _, field := range fields {
+		if pqErr.Constraint == table+"_"+field+"_key" || pqErr.Constraint == table+"_"+field+"_ci_key" {
+			return &DuplicateError{Field: field}
+		}
+	}
+	return &DuplicateError{}
+}
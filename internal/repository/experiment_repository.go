@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExperimentRepository interface {
+	// Create stores an experiment, failing with ErrDuplicate when the key is taken
+	Create(ctx context.Context, experiment *model.Experiment) error
+	GetByKey(ctx context.Context, key string) (*model.Experiment, error)
+	List(ctx context.Context) ([]*model.Experiment, error)
+	// Stop marks a running experiment stopped and returns it, or nil when no running
+	// experiment has the key
+	Stop(ctx context.Context, key, stoppedBy string, stoppedAt time.Time) (*model.Experiment, error)
+}
+
+type experimentRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewExperimentRepository(db *mongo.Database, collectionName string, timeout time.Duration) ExperimentRepository {
+	return &experimentRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *experimentRepository) Create(ctx context.Context, experiment *model.Experiment) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareExperiment(experiment)
+	_, err := r.collection.InsertOne(ctx, experiment)
+	return mongoDuplicate(err, "key")
+}
+
+func (r *experimentRepository) GetByKey(ctx context.Context, key string) (*model.Experiment, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var experiment model.Experiment
+	err := r.collection.FindOne(ctx, bson.M{"key": key}).Decode(&experiment)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+// List returns every experiment, oldest first
+func (r *experimentRepository) List(ctx context.Context) ([]*model.Experiment, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var experiments []*model.Experiment
+	if err = cursor.All(ctx, &experiments); err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+func (r *experimentRepository) Stop(ctx context.Context, key, stoppedBy string, stoppedAt time.Time) (*model.Experiment, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var experiment model.Experiment
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"key": key, "status": model.ExperimentRunning}, bson.M{"$set": bson.M{
+		"status":     model.ExperimentStopped,
+		"stopped_by": stoppedBy,
+		"stopped_at": stoppedAt,
+	}}, opts).Decode(&experiment)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+func (r *experimentRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareExperiment(experiment *model.Experiment) {
+	if experiment.ID.IsZero() {
+		experiment.ID = primitive.NewObjectID()
+	}
+	if experiment.CreatedAt.IsZero() {
+		experiment.CreatedAt = time.Now()
+	}
+	if experiment.Status == "" {
+		experiment.Status = model.ExperimentRunning
+	}
+}
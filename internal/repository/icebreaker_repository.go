@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IcebreakerRepository interface {
+	Create(ctx context.Context, icebreaker *model.Icebreaker) error
+	CreateMany(ctx context.Context, icebreakers []*model.Icebreaker) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*model.Icebreaker, error)
+	List(ctx context.Context, activeOnly bool) ([]*model.Icebreaker, error)
+	Count(ctx context.Context) (int64, error)
+	Update(ctx context.Context, icebreaker *model.Icebreaker) error
+	Delete(ctx context.Context, id primitive.ObjectID) (bool, error)
+	RecordUse(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error
+}
+
+type icebreakerRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewIcebreakerRepository(db *mongo.Database, collectionName string, timeout time.Duration) IcebreakerRepository {
+	return &icebreakerRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *icebreakerRepository) Create(ctx context.Context, icebreaker *model.Icebreaker) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareIcebreaker(icebreaker)
+	_, err := r.collection.InsertOne(ctx, icebreaker)
+	return err
+}
+
+func (r *icebreakerRepository) CreateMany(ctx context.Context, icebreakers []*model.Icebreaker) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if len(icebreakers) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(icebreakers))
+	for i, icebreaker := range icebreakers {
+		prepareIcebreaker(icebreaker)
+		documents[i] = icebreaker
+	}
+
+	_, err := r.collection.InsertMany(ctx, documents)
+	return err
+}
+
+func (r *icebreakerRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.Icebreaker, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var icebreaker model.Icebreaker
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&icebreaker)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &icebreaker, nil
+}
+
+// List returns the prompts, oldest first
+func (r *icebreakerRepository) List(ctx context.Context, activeOnly bool) ([]*model.Icebreaker, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var icebreakers []*model.Icebreaker
+	if err = cursor.All(ctx, &icebreakers); err != nil {
+		return nil, err
+	}
+	return icebreakers, nil
+}
+
+func (r *icebreakerRepository) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, bson.M{})
+}
+
+// Update replaces the editable fields of a prompt, leaving its usage counters alone
+func (r *icebreakerRepository) Update(ctx context.Context, icebreaker *model.Icebreaker) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	icebreaker.UpdatedAt = time.Now()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": icebreaker.ID}, bson.M{"$set": bson.M{
+		"text":       icebreaker.Text,
+		"language":   icebreaker.Language,
+		"active":     icebreaker.Active,
+		"updated_at": icebreaker.UpdatedAt,
+	}})
+	return err
+}
+
+func (r *icebreakerRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *icebreakerRepository) RecordUse(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"use_count": 1},
+		"$set": bson.M{"last_used_at": usedAt},
+	})
+	return err
+}
+
+func (r *icebreakerRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "active", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareIcebreaker(icebreaker *model.Icebreaker) {
+	if icebreaker.ID.IsZero() {
+		icebreaker.ID = primitive.NewObjectID()
+	}
+	if icebreaker.CreatedAt.IsZero() {
+		icebreaker.CreatedAt = time.Now()
+	}
+	if icebreaker.UpdatedAt.IsZero() {
+		icebreaker.UpdatedAt = icebreaker.CreatedAt
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LegalHoldRepository interface {
+	// Create stores a hold, failing with ErrDuplicate when the user is already held
+	Create(ctx context.Context, hold *model.LegalHold) error
+	GetByUsername(ctx context.Context, username string) (*model.LegalHold, error)
+	List(ctx context.Context) ([]*model.LegalHold, error)
+	// Delete removes the hold of a user and returns it, or nil when there is none
+	Delete(ctx context.Context, username string) (*model.LegalHold, error)
+}
+
+type legalHoldRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewLegalHoldRepository(db *mongo.Database, collectionName string, timeout time.Duration) LegalHoldRepository {
+	return &legalHoldRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *legalHoldRepository) Create(ctx context.Context, hold *model.LegalHold) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareLegalHold(hold)
+	_, err := r.collection.InsertOne(ctx, hold)
+	return mongoDuplicate(err, "username")
+}
+
+func (r *legalHoldRepository) GetByUsername(ctx context.Context, username string) (*model.LegalHold, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var hold model.LegalHold
+	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&hold)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// List returns every hold, oldest first
+func (r *legalHoldRepository) List(ctx context.Context) ([]*model.LegalHold, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "placed_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var holds []*model.LegalHold
+	if err = cursor.All(ctx, &holds); err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func (r *legalHoldRepository) Delete(ctx context.Context, username string) (*model.LegalHold, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var hold model.LegalHold
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"username": username}).Decode(&hold)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *legalHoldRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareLegalHold(hold *model.LegalHold) {
+	if hold.ID.IsZero() {
+		hold.ID = primitive.NewObjectID()
+	}
+	if hold.PlacedAt.IsZero() {
+		hold.PlacedAt = time.Now()
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MatchStatsRepository interface {
+	// Increment adds the counters of delta to the rollup of its day and bucket, creating
+	// it if needed
+	Increment(ctx context.Context, delta *model.MatchStats) error
+	// ListSince returns the rollups of the day and later, by day and bucket
+	ListSince(ctx context.Context, day string) ([]*model.MatchStats, error)
+}
+
+type matchStatsRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewMatchStatsRepository(db *mongo.Database, collectionName string, timeout time.Duration) MatchStatsRepository {
+	return &matchStatsRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *matchStatsRepository) Increment(ctx context.Context, delta *model.MatchStats) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"day": delta.Day, "bucket": delta.Bucket}, bson.M{
+		"$inc": bson.M{
+			"matches":     delta.Matches,
+			"bot_matches": delta.BotMatches,
+			"wait_ms":     delta.WaitMs,
+			"abandoned":   delta.Abandoned,
+			"chats":       delta.Chats,
+			"long_chats":  delta.LongChats,
+			"skips":       delta.Skips,
+		},
+		"$set": bson.M{"updated_at": time.Now()},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *matchStatsRepository) ListSince(ctx context.Context, day string) ([]*model.MatchStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "day", Value: 1}, {Key: "bucket", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"day": bson.M{"$gte": day}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []*model.MatchStats
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r *matchStatsRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "day", Value: 1}, {Key: "bucket", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
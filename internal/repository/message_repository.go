@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MessageRepository interface {
+	Create(ctx context.Context, message *model.Message) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*model.Message, error)
+	// Update stores the current version; replaced versions go through AddRevision
+	Update(ctx context.Context, message *model.Message) error
+	AddRevision(ctx context.Context, id primitive.ObjectID, revision model.MessageRevision) error
+	// GetRevisions returns the replaced versions of a message, oldest first
+	GetRevisions(ctx context.Context, id primitive.ObjectID) ([]model.MessageRevision, error)
+	GetByRoom(ctx context.Context, roomCode string, limit int) ([]*model.Message, error)
+	HasSender(ctx context.Context, roomCode, username string) (bool, error)
+	// DeleteBySender deletes the messages of a sender in a room, or in every room when
+	// roomCode is empty, revisions included
+	DeleteBySender(ctx context.Context, from, roomCode string) (int64, error)
+	// CountBefore and DeleteBefore count and delete the messages sent before the time,
+	// revisions included, for the retention policy. Messages of held users are kept.
+	CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+	DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+}
+
+type messageRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewMessageRepository(db *mongo.Database, collectionName string, timeout time.Duration) MessageRepository {
+	return &messageRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *messageRepository) Create(ctx context.Context, message *model.Message) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	_, err := r.collection.InsertOne(ctx, message)
+	return err
+}
+
+func (r *messageRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.Message, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var message model.Message
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *messageRepository) Update(ctx context.Context, message *model.Message) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": message.ID}
+	update := bson.M{"$set": bson.M{
+		"text":       message.Text,
+		"edited_at":  message.EditedAt,
+		"flagged":    message.Flagged,
+		"spam":       message.Spam,
+		"is_deleted": message.IsDeleted,
+		"deleted_at": message.DeletedAt,
+	}}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *messageRepository) AddRevision(ctx context.Context, id primitive.ObjectID, revision model.MessageRevision) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$push": bson.M{"revisions": revision}})
+	return err
+}
+
+func (r *messageRepository) GetRevisions(ctx context.Context, id primitive.ObjectID) ([]model.MessageRevision, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var message model.Message
+	opts := options.FindOne().SetProjection(bson.M{"revisions": 1})
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}, opts).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return message.Revisions, nil
+}
+
+// GetByRoom returns the latest messages of a room in chronological order
+func (r *messageRepository) GetByRoom(ctx context.Context, roomCode string, limit int) ([]*model.Message, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"room_code": roomCode}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)).
+		SetProjection(bson.M{"revisions": 0})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*model.Message
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	reverseMessages(messages)
+	return messages, nil
+}
+
+func (r *messageRepository) HasSender(ctx context.Context, roomCode, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"room_code": roomCode, "from": username},
+		options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *messageRepository) DeleteBySender(ctx context.Context, from, roomCode string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"from": from}
+	if roomCode != "" {
+		filter["room_code"] = roomCode
+	}
+	result, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *messageRepository) CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, expiredMessages(before, held))
+}
+
+func (r *messageRepository) DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, expiredMessages(before, held))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *messageRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "room_code", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "from", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: 1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func reverseMessages(messages []*model.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// expiredMessages selects the messages sent before the time by users not under a legal hold
+func expiredMessages(before time.Time, held model.HeldUsers) bson.M {
+	filter := bson.M{"created_at": bson.M{"$lt": before}}
+	if len(held.Usernames) > 0 {
+		filter["from"] = bson.M{"$nin": held.Usernames}
+	}
+	return filter
+}
@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS users (
+    id            CHAR(24) PRIMARY KEY,
+    username      TEXT NOT NULL UNIQUE,
+    email         TEXT NOT NULL,
+    password_hash TEXT NOT NULL DEFAULT '',
+    age           INTEGER NOT NULL DEFAULT 0,
+    gender        TEXT NOT NULL DEFAULT '',
+    bio           TEXT NOT NULL DEFAULT '',
+    is_online     BOOLEAN NOT NULL DEFAULT FALSE,
+    is_verified   BOOLEAN NOT NULL DEFAULT FALSE,
+    last_seen     TIMESTAMPTZ NOT NULL,
+    joined_at     TIMESTAMPTZ NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL,
+    room_id       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_users_is_online ON users (is_online);
+CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users (last_seen DESC);
+CREATE INDEX IF NOT EXISTS idx_users_joined_at ON users (joined_at);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          CHAR(24) PRIMARY KEY,
+    user_id     CHAR(24) NOT NULL,
+    token       TEXT NOT NULL UNIQUE,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    is_revoked  BOOLEAN NOT NULL DEFAULT FALSE,
+    device_info TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id         CHAR(24) PRIMARY KEY,
+    user_id    CHAR(24) NOT NULL,
+    token      TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used  TIMESTAMPTZ NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    is_active  BOOLEAN NOT NULL DEFAULT TRUE
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS captchas (
+    id         CHAR(24) PRIMARY KEY,
+    challenge  TEXT NOT NULL,
+    answer     TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    is_used    BOOLEAN NOT NULL DEFAULT FALSE,
+    ip_address TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_captchas_expires_at ON captchas (expires_at);
+CREATE INDEX IF NOT EXISTS idx_captchas_ip_address ON captchas (ip_address);
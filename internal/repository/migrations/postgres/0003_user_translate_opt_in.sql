@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS translate_opt_in BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS messages (
+    id         CHAR(24) PRIMARY KEY,
+    room_code  TEXT NOT NULL,
+    sender     TEXT NOT NULL,
+    text       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    edited_at  TIMESTAMPTZ,
+    is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_messages_room_created ON messages (room_code, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages (sender);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id         CHAR(24) PRIMARY KEY,
+    user_id    CHAR(24) NOT NULL,
+    username   TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL DEFAULT '',
+    data       JSONB,
+    read_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications (user_id, created_at DESC);
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_users_joined_at ON users (joined_at);
+CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users (last_seen);
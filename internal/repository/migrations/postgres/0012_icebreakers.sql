@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS icebreakers (
+    id           CHAR(24) PRIMARY KEY,
+    text         TEXT NOT NULL,
+    language     TEXT NOT NULL DEFAULT '',
+    active       BOOLEAN NOT NULL DEFAULT TRUE,
+    use_count    BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_icebreakers_active_created ON icebreakers (active, created_at);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS chat_stats (
+    username          TEXT PRIMARY KEY,
+    total_chats       BIGINT NOT NULL DEFAULT 0,
+    total_messages    BIGINT NOT NULL DEFAULT 0,
+    total_duration_ms BIGINT NOT NULL DEFAULT 0,
+    skips             BIGINT NOT NULL DEFAULT 0,
+    updated_at        TIMESTAMPTZ NOT NULL
+);
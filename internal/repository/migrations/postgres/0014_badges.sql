@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_badges (
+    id         CHAR(24) PRIMARY KEY,
+    username   TEXT NOT NULL,
+    code       TEXT NOT NULL,
+    awarded_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (username, code)
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS featured_badges TEXT[] DEFAULT '{}';
+
+ALTER TABLE chat_stats ADD COLUMN IF NOT EXISTS current_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chat_stats ADD COLUMN IF NOT EXISTS longest_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chat_stats ADD COLUMN IF NOT EXISTS last_chat_day TEXT NOT NULL DEFAULT '';
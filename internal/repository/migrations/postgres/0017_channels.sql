@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS channels (
+    id          CHAR(24) PRIMARY KEY,
+    slug        TEXT NOT NULL UNIQUE,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_by  TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS channel_members (
+    id        CHAR(24) PRIMARY KEY,
+    channel   TEXT NOT NULL,
+    username  TEXT NOT NULL,
+    joined_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (channel, username)
+);
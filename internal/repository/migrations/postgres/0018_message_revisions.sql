@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id          CHAR(24) PRIMARY KEY,
+    message_id  CHAR(24) NOT NULL,
+    text        TEXT NOT NULL,
+    flagged     BOOLEAN NOT NULL DEFAULT FALSE,
+    written_at  TIMESTAMPTZ NOT NULL,
+    replaced_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_revisions_message ON message_revisions (message_id, replaced_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS blocklist (
+    id         CHAR(24) PRIMARY KEY,
+    pattern    TEXT NOT NULL,
+    regex      BOOLEAN NOT NULL DEFAULT FALSE,
+    severity   TEXT NOT NULL,
+    language   TEXT NOT NULL DEFAULT '',
+    version    INTEGER NOT NULL DEFAULT 1,
+    created_by TEXT NOT NULL DEFAULT '',
+    updated_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_blocklist_created ON blocklist (created_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS profile_views (
+    id        CHAR(24) PRIMARY KEY,
+    username  TEXT NOT NULL,
+    viewer    TEXT NOT NULL,
+    day       TEXT NOT NULL,
+    viewed_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (username, day, viewer)
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS hide_profile_views BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_visibility TEXT NOT NULL DEFAULT '';
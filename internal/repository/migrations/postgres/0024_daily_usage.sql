@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS daily_usage (
+    username      TEXT NOT NULL,
+    day           TEXT NOT NULL,
+    chats_started INTEGER NOT NULL DEFAULT 0,
+    chat_seconds  BIGINT NOT NULL DEFAULT 0,
+    updated_at    TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (username, day)
+);
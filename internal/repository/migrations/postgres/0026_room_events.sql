@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS room_events (
+    id            CHAR(24) PRIMARY KEY,
+    room          TEXT NOT NULL,
+    seq           INTEGER NOT NULL,
+    type          TEXT NOT NULL,
+    username      TEXT NOT NULL DEFAULT '',
+    reason        TEXT NOT NULL DEFAULT '',
+    message_count INTEGER NOT NULL DEFAULT 0,
+    messages_by   JSONB,
+    at            TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_room_events_room ON room_events (room, seq);
+CREATE INDEX IF NOT EXISTS idx_room_events_username ON room_events (username, at DESC);
+CREATE INDEX IF NOT EXISTS idx_room_events_at ON room_events (at DESC);
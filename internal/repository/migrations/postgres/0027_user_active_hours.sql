@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active_hours_start SMALLINT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active_hours_end SMALLINT;
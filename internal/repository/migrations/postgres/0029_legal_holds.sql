@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id        CHAR(24) PRIMARY KEY,
+    user_id   CHAR(24) NOT NULL,
+    username  TEXT NOT NULL UNIQUE,
+    reason    TEXT NOT NULL,
+    case_ref  TEXT NOT NULL DEFAULT '',
+    placed_by TEXT NOT NULL,
+    placed_at TIMESTAMPTZ NOT NULL
+);
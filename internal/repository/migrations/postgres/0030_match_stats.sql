@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS match_stats (
+    day         TEXT NOT NULL,
+    bucket      TEXT NOT NULL,
+    matches     BIGINT NOT NULL DEFAULT 0,
+    bot_matches BIGINT NOT NULL DEFAULT 0,
+    wait_ms     BIGINT NOT NULL DEFAULT 0,
+    abandoned   BIGINT NOT NULL DEFAULT 0,
+    chats       BIGINT NOT NULL DEFAULT 0,
+    long_chats  BIGINT NOT NULL DEFAULT 0,
+    skips       BIGINT NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (day, bucket)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS experiments (
+    id          CHAR(24) PRIMARY KEY,
+    key         TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    variants    JSONB NOT NULL,
+    status      TEXT NOT NULL,
+    created_by  TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    stopped_by  TEXT NOT NULL DEFAULT '',
+    stopped_at  TIMESTAMPTZ
+);
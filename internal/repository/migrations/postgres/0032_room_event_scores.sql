@@ -0,0 +1 @@
+ALTER TABLE room_events ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION;
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_users_banned_joined_at ON users (is_banned, joined_at);
+CREATE INDEX IF NOT EXISTS idx_users_verified_joined_at ON users (is_verified, joined_at);
+CREATE INDEX IF NOT EXISTS idx_users_banned_last_seen ON users (is_banned, last_seen DESC);
+CREATE INDEX IF NOT EXISTS idx_users_email_domain ON users (lower(split_part(email, '@', 2)));
@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_ci_key ON users (lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_ci_key ON users (lower(email));
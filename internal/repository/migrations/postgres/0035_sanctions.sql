@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS sanctions (
+    id         CHAR(24) PRIMARY KEY,
+    user_id    CHAR(24) NOT NULL,
+    username   TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    reason     TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    lifted_by  TEXT NOT NULL DEFAULT '',
+    lifted_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_sanctions_username_type_expires_at ON sanctions (username, type, expires_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sanctions_username_created_at ON sanctions (username, created_at DESC);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS network_bans (
+    id         CHAR(24) PRIMARY KEY,
+    type       TEXT NOT NULL,
+    value      TEXT NOT NULL,
+    reason     TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL,
+    updated_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ,
+    UNIQUE (type, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_network_bans_expires_at ON network_bans (expires_at);
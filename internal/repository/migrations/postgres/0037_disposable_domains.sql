@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS disposable_domains (
+    id         CHAR(24) PRIMARY KEY,
+    domain     TEXT NOT NULL UNIQUE,
+    blocked    BOOLEAN NOT NULL,
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NetworkBanRepository interface {
+	// Create stores a ban, failing with ErrDuplicate when the type and value are banned already
+	Create(ctx context.Context, ban *model.NetworkBan) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*model.NetworkBan, error)
+	// List returns every ban, expired ones included until they are deleted, oldest first
+	List(ctx context.Context) ([]*model.NetworkBan, error)
+	// Update stores the reason and expiry of a ban and reports whether it exists
+	Update(ctx context.Context, ban *model.NetworkBan) (bool, error)
+	// Delete removes a ban and returns it, or nil when it does not exist
+	Delete(ctx context.Context, id primitive.ObjectID) (*model.NetworkBan, error)
+	// DeleteExpired removes the bans that expired before now and returns how many there were
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type networkBanRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewNetworkBanRepository(db *mongo.Database, collectionName string, timeout time.Duration) NetworkBanRepository {
+	return &networkBanRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *networkBanRepository) Create(ctx context.Context, ban *model.NetworkBan) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareNetworkBan(ban)
+	_, err := r.collection.InsertOne(ctx, ban)
+	return mongoDuplicate(err)
+}
+
+func (r *networkBanRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.NetworkBan, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var ban model.NetworkBan
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&ban)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ban, nil
+}
+
+func (r *networkBanRepository) List(ctx context.Context) ([]*model.NetworkBan, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bans []*model.NetworkBan
+	if err = cursor.All(ctx, &bans); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+func (r *networkBanRepository) Update(ctx context.Context, ban *model.NetworkBan) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	set := bson.M{
+		"reason":     ban.Reason,
+		"updated_by": ban.UpdatedBy,
+		"updated_at": ban.UpdatedAt,
+	}
+	update := bson.M{"$set": set}
+	if ban.ExpiresAt != nil {
+		set["expires_at"] = ban.ExpiresAt
+	} else {
+		update["$unset"] = bson.M{"expires_at": ""}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": ban.ID}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *networkBanRepository) Delete(ctx context.Context, id primitive.ObjectID) (*model.NetworkBan, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var ban model.NetworkBan
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&ban)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ban, nil
+}
+
+func (r *networkBanRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *networkBanRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "type", Value: 1}, {Key: "value", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Bans without expires_at are permanent and never removed
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareNetworkBan(ban *model.NetworkBan) {
+	if ban.ID.IsZero() {
+		ban.ID = primitive.NewObjectID()
+	}
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now()
+	}
+	if ban.UpdatedAt.IsZero() {
+		ban.UpdatedAt = ban.CreatedAt
+	}
+}
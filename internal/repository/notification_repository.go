@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *model.Notification) error
+	CreateMany(ctx context.Context, notifications []*model.Notification) error
+	GetByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int) ([]*model.Notification, error)
+	MarkRead(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+	MarkAllRead(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+type notificationRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewNotificationRepository(db *mongo.Database, collectionName string, timeout time.Duration) NotificationRepository {
+	return &notificationRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *notificationRepository) Create(ctx context.Context, notification *model.Notification) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareNotification(notification)
+	_, err := r.collection.InsertOne(ctx, notification)
+	return err
+}
+
+func (r *notificationRepository) CreateMany(ctx context.Context, notifications []*model.Notification) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(notifications))
+	for i, notification := range notifications {
+		prepareNotification(notification)
+		documents[i] = notification
+	}
+
+	_, err := r.collection.InsertMany(ctx, documents)
+	return err
+}
+
+// GetByUser returns the user's notifications, newest first
+func (r *notificationRepository) GetByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read_at"] = bson.M{"$exists": false}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var notifications []*model.Notification
+	if err = cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkRead marks one of the user's notifications as read and reports whether it exists
+func (r *notificationRepository) MarkRead(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "user_id": userID}
+	unread := bson.M{"_id": id, "user_id": userID, "read_at": bson.M{"$exists": false}}
+	result, err := r.collection.UpdateOne(ctx, unread, bson.M{"$set": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount > 0 {
+		return true, nil
+	}
+
+	// Already read notifications still exist
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID, "read_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, bson.M{"user_id": userID, "read_at": bson.M{"$exists": false}})
+}
+
+func (r *notificationRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareNotification(notification *model.Notification) {
+	if notification.ID.IsZero() {
+		notification.ID = primitive.NewObjectID()
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Page sizes of PageRequest
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// ErrInvalidCursor is returned by ParseCursor for cursors this package did not encode
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort orders a paged query by a field. The ID breaks ties, so items with the same value
+// are neither repeated nor skipped between pages.
+type Sort struct {
+	Field string // document field, also the column name in PostgreSQL
+	Desc  bool
+}
+
+// Cursor is the position after the last item of a page: its sort value and ID. Pages
+// continue after it however many items were inserted or deleted before it, unlike an
+// offset. The zero Cursor is the start.
+type Cursor struct {
+	Value interface{} // string or time.Time
+	ID    primitive.ObjectID
+}
+
+// IsZero reports whether the cursor is the start
+func (c Cursor) IsZero() bool {
+	return c.ID.IsZero()
+}
+
+type encodedCursor struct {
+	String string     `json:"s,omitempty"`
+	Time   *time.Time `json:"t,omitempty"`
+	ID     string     `json:"id"`
+}
+
+// Encode returns the cursor as an opaque string for APIs, empty for the zero Cursor
+func (c Cursor) Encode() string {
+	if c.IsZero() {
+		return ""
+	}
+	encoded := encodedCursor{ID: c.ID.Hex()}
+	switch value := c.Value.(type) {
+	case time.Time:
+		encoded.Time = &value
+	case string:
+		encoded.String = value
+	}
+	data, _ := json.Marshal(encoded)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor from Encode; the empty string is the zero Cursor
+func ParseCursor(value string) (Cursor, error) {
+	if value == "" {
+		return Cursor{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var encoded encodedCursor
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(encoded.ID)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	cursor := Cursor{Value: encoded.String, ID: id}
+	if encoded.Time != nil {
+		cursor.Value = *encoded.Time
+	}
+	return cursor, nil
+}
+
+// PageRequest asks for the page after a cursor
+type PageRequest struct {
+	After Cursor
+	// Limit is the page size, DefaultPageSize when 0 and at most MaxPageSize
+	Limit int
+}
+
+func (p PageRequest) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageSize
+	case p.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return p.Limit
+}
+
+// Page is a page of a query. Queries that may return many items, including message
+// history, return a Page instead of loading every match with cursor.All.
+type Page[T any] struct {
+	Items []T
+	// Next continues after the last item; zero on the last page
+	Next Cursor
+}
+
+// ForEachPage calls fn with every page of a query in turn, so a whole collection is
+// processed without holding it in memory
+func ForEachPage[T any](ctx context.Context, size int, fetch func(ctx context.Context, page PageRequest) (*Page[T], error), fn func(items []T) error) error {
+	request := PageRequest{Limit: size}
+	for {
+		page, err := fetch(ctx, request)
+		if err != nil {
+			return err
+		}
+		if err := fn(page.Items); err != nil {
+			return err
+		}
+		if page.Next.IsZero() {
+			return nil
+		}
+		request.After = page.Next
+	}
+}
+
+// findPage runs a paged Find: filter narrowed to the items after the cursor, sorted by
+// sort then _id, and one item more than the limit to learn whether a next page exists.
+// cursorOf returns the cursor after an item.
+func findPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, sort Sort, request PageRequest, cursorOf func(T) Cursor) (*Page[T], error) {
+	direction, after := 1, "$gt"
+	if sort.Desc {
+		direction, after = -1, "$lt"
+	}
+
+	if !request.After.IsZero() {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+			bson.M{sort.Field: bson.M{after: request.After.Value}},
+			bson.M{sort.Field: request.After.Value, "_id": bson.M{after: request.After.ID}},
+		}}}}
+	}
+
+	limit := request.limit()
+	opts := options.Find().
+		SetSort(bson.D{{Key: sort.Field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return newPage(items, limit, cursorOf), nil
+}
+
+// pageClause returns the PostgreSQL condition and ORDER BY/LIMIT clause of a paged
+// query, whose arguments are numbered from next. The condition is empty for the first
+// page. The limit asks for one item more, see newPage.
+func pageClause(sort Sort, request PageRequest, next int) (condition, clause string, args []interface{}) {
+	direction, after := "ASC", ">"
+	if sort.Desc {
+		direction, after = "DESC", "<"
+	}
+
+	if !request.After.IsZero() {
+		condition = fmt.Sprintf("(%s, id) %s ($%d, $%d)", sort.Field, after, next, next+1)
+		args = append(args, request.After.Value, request.After.ID.Hex())
+	}
+	clause = fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", sort.Field, direction, direction, request.limit()+1)
+	return condition, clause, args
+}
+
+// whereAll returns the WHERE clause of the non-empty conditions
+func whereAll(conditions ...string) string {
+	var parts []string
+	for _, condition := range conditions {
+		if condition != "" {
+			parts = append(parts, condition)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(parts, " AND ")
+}
+
+// newPage trims the extra item of a query for limit+1 items, setting Next when it was
+// there
+func newPage[T any](items []T, limit int, cursorOf func(T) Cursor) *Page[T] {
+	page := &Page[T]{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.Next = cursorOf(items[limit-1])
+	}
+	return page
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chatmix-backend/internal/config"
+
+	_ "github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+func newPostgresDatabase(cfg *config.Config) (*Database, error) {
+	db, err := sql.Open("postgres", cfg.Database.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+	}
+
+	if cfg.Database.Pool.MaxSize > 0 {
+		db.SetMaxOpenConns(int(cfg.Database.Pool.MaxSize))
+	}
+	if cfg.Database.Pool.MinSize > 0 {
+		db.SetMaxIdleConns(int(cfg.Database.Pool.MinSize))
+	}
+	if cfg.Database.Pool.MaxConnIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.Database.Pool.MaxConnIdleTime)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.Timeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
+	}
+
+	if err := migratePostgres(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	timeout := cfg.Database.OperationTimeout
+	return &Database{
+		SQL:               db,
+		UserRepo:          NewPostgresUserRepository(db, timeout),
+		RefreshTokenRepo:  NewPostgresRefreshTokenRepository(db, timeout),
+		SessionRepo:       NewPostgresSessionRepository(db, timeout),
+		CaptchaRepo:       NewPostgresCaptchaRepository(db, timeout),
+		MessageRepo:       NewPostgresMessageRepository(db, timeout),
+		AuditRepo:         NewPostgresAuditRepository(db, timeout),
+		NotificationRepo:  NewPostgresNotificationRepository(db, timeout),
+		VerificationRepo:  NewPostgresVerificationCodeRepository(db, timeout),
+		IcebreakerRepo:    NewPostgresIcebreakerRepository(db, timeout),
+		ChatStatsRepo:     NewPostgresChatStatsRepository(db, timeout),
+		BadgeRepo:         NewPostgresBadgeRepository(db, timeout),
+		APIKeyRepo:        NewPostgresAPIKeyRepository(db, timeout),
+		ChannelRepo:       NewPostgresChannelRepository(db, timeout),
+		ChannelMemberRepo: NewPostgresChannelMemberRepository(db, timeout),
+		BlocklistRepo:     NewPostgresBlocklistRepository(db, timeout),
+		ProfileViewRepo:   NewPostgresProfileViewRepository(db, timeout),
+		UsageRepo:         NewPostgresUsageRepository(db, timeout),
+		RoomEventRepo:     NewPostgresRoomEventRepository(db, timeout),
+		LegalHoldRepo:     NewPostgresLegalHoldRepository(db, timeout),
+		MatchStatsRepo:    NewPostgresMatchStatsRepository(db, timeout),
+		ExperimentRepo:    NewPostgresExperimentRepository(db, timeout),
+		SanctionRepo:      NewPostgresSanctionRepository(db, timeout),
+		NetworkBanRepo:    NewPostgresNetworkBanRepository(db, timeout),
+		DisposableRepo:    NewPostgresDisposableDomainRepository(db, timeout),
+	}, nil
+}
+
+// migratePostgres applies embedded migrations that have not been recorded in schema_migrations yet.
+// Migration files are named <version>_<description>.sql and applied in version order.
+func migratePostgres(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration file name %s", name)
+		}
+
+		var applied bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		script, err := postgresMigrations.ReadFile("migrations/postgres/" + name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// placeholders returns n comma-separated positional parameters starting at $start
+func placeholders(start, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = "$" + strconv.Itoa(start+i)
+	}
+	return strings.Join(params, ", ")
+}
+
+// objectIDHex stores an optional object id, empty when unset
+func objectIDHex(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+func objectIDHexes(ids []primitive.ObjectID) []string {
+	hexes := make([]string, len(ids))
+	for i, id := range ids {
+		hexes[i] = id.Hex()
+	}
+	return hexes
+}
+
+func parseObjectID(hex string) (primitive.ObjectID, error) {
+	if hex == "" {
+		return primitive.NilObjectID, nil
+	}
+	return primitive.ObjectIDFromHex(hex)
+}
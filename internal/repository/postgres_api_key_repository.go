@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, rate_limit, created_at, last_used_at, revoked_at`
+
+type postgresAPIKeyRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresAPIKeyRepository(db *sql.DB, timeout time.Duration) APIKeyRepository {
+	return &postgresAPIKeyRepository{db: db, timeout: timeout}
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var key model.APIKey
+	var id, userID string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&id, &userID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes), &key.RateLimit,
+		&key.CreatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if key.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+func (r *postgresAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareAPIKey(key)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO api_keys (`+apiKeyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		key.ID.Hex(), key.UserID.Hex(), key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.RateLimit,
+		key.CreatedAt, key.LastUsedAt, key.RevokedAt)
+	return err
+}
+
+func (r *postgresAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *postgresAPIKeyRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*model.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE user_id = $1 ORDER BY created_at DESC`, userID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *postgresAPIKeyRepository) CountActiveByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID.Hex()).Scan(&count)
+	return count, err
+}
+
+func (r *postgresAPIKeyRepository) Revoke(ctx context.Context, id, userID primitive.ObjectID, at time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id.Hex(), userID.Hex(), at)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *postgresAPIKeyRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id.Hex(), at)
+	return err
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const auditColumns = `id, actor_id, actor, action, target, details, ip_address, created_at`
+
+type postgresAuditRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresAuditRepository(db *sql.DB, timeout time.Duration) AuditRepository {
+	return &postgresAuditRepository{db: db, timeout: timeout}
+}
+
+func scanAuditLog(row rowScanner) (*model.AuditLog, error) {
+	var entry model.AuditLog
+	var id, actorID string
+	var details []byte
+	err := row.Scan(&id, &actorID, &entry.Actor, &entry.Action, &entry.Target, &details, &entry.IPAddress, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if entry.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if entry.ActorID, err = parseObjectID(actorID); err != nil {
+		return nil, err
+	}
+	if len(details) > 0 {
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			return nil, err
+		}
+	}
+	return &entry, nil
+}
+
+func (r *postgresAuditRepository) Create(ctx context.Context, entry *model.AuditLog) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	actorID := ""
+	if !entry.ActorID.IsZero() {
+		actorID = entry.ActorID.Hex()
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO audit_logs (`+auditColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		entry.ID.Hex(), actorID, entry.Actor, entry.Action, entry.Target, details, entry.IPAddress, entry.CreatedAt)
+	return err
+}
+
+func (r *postgresAuditRepository) Find(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if !filter.ActorID.IsZero() {
+		addCondition("actor_id = ?", filter.ActorID.Hex())
+	}
+	if filter.Action != "" {
+		addCondition("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		addCondition("target = ?", filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= ?", filter.Since)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_logs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += ` ORDER BY created_at DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*model.AuditLog
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *postgresAuditRepository) CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	userIDs, usernames := heldArrays(held)
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_logs
+		WHERE created_at < $1 AND actor_id <> ALL($2) AND target <> ALL($3)`, before, userIDs, usernames).Scan(&count)
+	return count, err
+}
+
+func (r *postgresAuditRepository) DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	userIDs, usernames := heldArrays(held)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_logs
+		WHERE created_at < $1 AND actor_id <> ALL($2) AND target <> ALL($3)`, before, userIDs, usernames)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
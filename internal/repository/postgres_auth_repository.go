@@ -0,0 +1,453 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	refreshTokenColumns = `id, user_id, token, expires_at, created_at, is_revoked, device_info, session_id`
+	sessionColumns      = `id, user_id, token, expires_at, created_at, last_used, ip_address, user_agent, is_active, country, city`
+	captchaColumns      = `id, challenge, answer, expires_at, created_at, is_used, ip_address`
+)
+
+type postgresRefreshTokenRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+type postgresSessionRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+type postgresCaptchaRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresRefreshTokenRepository(db *sql.DB, timeout time.Duration) RefreshTokenRepository {
+	return &postgresRefreshTokenRepository{db: db, timeout: timeout}
+}
+
+func NewPostgresSessionRepository(db *sql.DB, timeout time.Duration) SessionRepository {
+	return &postgresSessionRepository{db: db, timeout: timeout}
+}
+
+func NewPostgresCaptchaRepository(db *sql.DB, timeout time.Duration) CaptchaRepository {
+	return &postgresCaptchaRepository{db: db, timeout: timeout}
+}
+
+func scanRefreshToken(row rowScanner) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	var id, userID, sessionID string
+	err := row.Scan(&id, &userID, &token.Token, &token.ExpiresAt, &token.CreatedAt, &token.IsRevoked, &token.DeviceInfo,
+		&sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if token.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if token.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	if token.SessionID, err = parseObjectID(sessionID); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func scanSession(row rowScanner) (*model.Session, error) {
+	var session model.Session
+	var id, userID string
+	err := row.Scan(&id, &userID, &session.Token, &session.ExpiresAt, &session.CreatedAt, &session.LastUsed,
+		&session.IPAddress, &session.UserAgent, &session.IsActive, &session.Country, &session.City)
+	if err != nil {
+		return nil, err
+	}
+	if session.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if session.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func scanCaptcha(row rowScanner) (*model.CaptchaChallenge, error) {
+	var captcha model.CaptchaChallenge
+	var id string
+	err := row.Scan(&id, &captcha.Challenge, &captcha.Answer, &captcha.ExpiresAt, &captcha.CreatedAt,
+		&captcha.IsUsed, &captcha.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if captcha.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	return &captcha, nil
+}
+
+func (r *postgresRefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO refresh_tokens (`+refreshTokenColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		token.ID.Hex(), token.UserID.Hex(), token.Token, token.ExpiresAt, token.CreatedAt, token.IsRevoked, token.DeviceInfo,
+		objectIDHex(token.SessionID))
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) getOne(ctx context.Context, query string, args ...interface{}) (*model.RefreshToken, error) {
+	token, err := scanRefreshToken(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *postgresRefreshTokenRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE id = $1`, id.Hex())
+}
+
+func (r *postgresRefreshTokenRepository) GetByToken(ctx context.Context, token string) (*model.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token = $1`, token)
+}
+
+func (r *postgresRefreshTokenRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, page PageRequest) (*Page[*model.RefreshToken], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	after, clause, args := pageClause(newestFirst, page, 2)
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens` +
+		whereAll("user_id = $1 AND NOT is_revoked", after) + clause
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{userID.Hex()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*model.RefreshToken
+	for rows.Next() {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(tokens, page.limit(), refreshTokenCursor), nil
+}
+
+func (r *postgresRefreshTokenRepository) Update(ctx context.Context, token *model.RefreshToken) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET user_id = $2, token = $3, expires_at = $4,
+		created_at = $5, is_revoked = $6, device_info = $7, session_id = $8 WHERE id = $1`,
+		token.ID.Hex(), token.UserID.Hex(), token.Token, token.ExpiresAt, token.CreatedAt, token.IsRevoked, token.DeviceInfo,
+		objectIDHex(token.SessionID))
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET is_revoked = TRUE WHERE id = $1`, id.Hex())
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) RevokeAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET is_revoked = TRUE WHERE user_id = $1`, userID.Hex())
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) RevokeOthersByUserID(ctx context.Context, userID, keepSessionID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET is_revoked = TRUE WHERE user_id = $1 AND session_id <> $2`,
+		userID.Hex(), keepSessionID.Hex())
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1 OR is_revoked`, time.Now())
+	return err
+}
+
+func (r *postgresSessionRepository) Create(ctx context.Context, session *model.Session) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	if session.LastUsed.IsZero() {
+		session.LastUsed = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO sessions (`+sessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		session.ID.Hex(), session.UserID.Hex(), session.Token, session.ExpiresAt, session.CreatedAt, session.LastUsed,
+		session.IPAddress, session.UserAgent, session.IsActive, session.Country, session.City)
+	return err
+}
+
+func (r *postgresSessionRepository) getOne(ctx context.Context, query string, args ...interface{}) (*model.Session, error) {
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *postgresSessionRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id.Hex())
+}
+
+func (r *postgresSessionRepository) GetByToken(ctx context.Context, token string) (*model.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE token = $1`, token)
+}
+
+func (r *postgresSessionRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, page PageRequest) (*Page[*model.Session], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	after, clause, args := pageClause(newestFirst, page, 2)
+	query := `SELECT ` + sessionColumns + ` FROM sessions` +
+		whereAll("user_id = $1 AND is_active", after) + clause
+	sessions, err := r.getMany(ctx, query, append([]interface{}{userID.Hex()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(sessions, page.limit(), sessionCursor), nil
+}
+
+func (r *postgresSessionRepository) GetRecentByUserID(ctx context.Context, userID primitive.ObjectID, limit int) ([]*model.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.getMany(ctx, `SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, userID.Hex(), limit)
+}
+
+func (r *postgresSessionRepository) getMany(ctx context.Context, query string, args ...interface{}) ([]*model.Session, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *postgresSessionRepository) Update(ctx context.Context, session *model.Session) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET user_id = $2, token = $3, expires_at = $4, created_at = $5,
+		last_used = $6, ip_address = $7, user_agent = $8, is_active = $9, country = $10, city = $11 WHERE id = $1`,
+		session.ID.Hex(), session.UserID.Hex(), session.Token, session.ExpiresAt, session.CreatedAt, session.LastUsed,
+		session.IPAddress, session.UserAgent, session.IsActive, session.Country, session.City)
+	return err
+}
+
+func (r *postgresSessionRepository) Touch(ctx context.Context, id primitive.ObjectID, lastUsed, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET last_used = $2, expires_at = $3 WHERE id = $1 AND is_active`,
+		id.Hex(), lastUsed, expiresAt)
+	return err
+}
+
+func (r *postgresSessionRepository) DeactivateByToken(ctx context.Context, token string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET is_active = FALSE WHERE token = $1`, token)
+	return err
+}
+
+func (r *postgresSessionRepository) DeactivateAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET is_active = FALSE WHERE user_id = $1`, userID.Hex())
+	return err
+}
+
+func (r *postgresSessionRepository) DeactivateOthersByUserID(ctx context.Context, userID, keepID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET is_active = FALSE WHERE user_id = $1 AND id <> $2`,
+		userID.Hex(), keepID.Hex())
+	return err
+}
+
+func (r *postgresSessionRepository) IPAddressesByUsers(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT user_id, array_agg(DISTINCT ip_address ORDER BY ip_address) FROM sessions
+		WHERE user_id = ANY($1) AND ip_address <> '' GROUP BY user_id`, pq.Array(objectIDHexes(userIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := make(map[primitive.ObjectID][]string)
+	for rows.Next() {
+		var userID string
+		var ips []string
+		if err := rows.Scan(&userID, pq.Array(&ips)); err != nil {
+			return nil, err
+		}
+		id, err := parseObjectID(userID)
+		if err != nil {
+			return nil, err
+		}
+		addresses[id] = ips
+	}
+	return addresses, rows.Err()
+}
+
+func (r *postgresSessionRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < $1 OR NOT is_active`, time.Now())
+	return err
+}
+
+func (r *postgresSessionRepository) CountExpiredBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	userIDs, _ := heldArrays(held)
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE expires_at < $1 AND user_id <> ALL($2)`,
+		before, userIDs).Scan(&count)
+	return count, err
+}
+
+func (r *postgresSessionRepository) DeleteExpiredBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	userIDs, _ := heldArrays(held)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < $1 AND user_id <> ALL($2)`, before, userIDs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *postgresCaptchaRepository) Create(ctx context.Context, captcha *model.CaptchaChallenge) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if captcha.ID.IsZero() {
+		captcha.ID = primitive.NewObjectID()
+	}
+	if captcha.CreatedAt.IsZero() {
+		captcha.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO captchas (`+captchaColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		captcha.ID.Hex(), captcha.Challenge, captcha.Answer, captcha.ExpiresAt, captcha.CreatedAt, captcha.IsUsed, captcha.IPAddress)
+	return err
+}
+
+func (r *postgresCaptchaRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.CaptchaChallenge, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	captcha, err := scanCaptcha(r.db.QueryRowContext(ctx, `SELECT `+captchaColumns+` FROM captchas WHERE id = $1`, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return captcha, nil
+}
+
+func (r *postgresCaptchaRepository) Update(ctx context.Context, captcha *model.CaptchaChallenge) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE captchas SET challenge = $2, answer = $3, expires_at = $4, created_at = $5,
+		is_used = $6, ip_address = $7 WHERE id = $1`,
+		captcha.ID.Hex(), captcha.Challenge, captcha.Answer, captcha.ExpiresAt, captcha.CreatedAt, captcha.IsUsed, captcha.IPAddress)
+	return err
+}
+
+func (r *postgresCaptchaRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM captchas WHERE expires_at < $1 OR is_used`, time.Now())
+	return err
+}
+
+func (r *postgresCaptchaRepository) DeleteByIPAddress(ctx context.Context, ipAddress string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM captchas WHERE ip_address = $1`, ipAddress)
+	return err
+}
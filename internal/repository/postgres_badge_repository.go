@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+type postgresBadgeRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresBadgeRepository(db *sql.DB, timeout time.Duration) BadgeRepository {
+	return &postgresBadgeRepository{db: db, timeout: timeout}
+}
+
+func (r *postgresBadgeRepository) Award(ctx context.Context, badge *model.UserBadge) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareUserBadge(badge)
+	result, err := r.db.ExecContext(ctx, `INSERT INTO user_badges (id, username, code, awarded_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (username, code) DO NOTHING`, badge.ID.Hex(), badge.Username, badge.Code, badge.AwardedAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted > 0, err
+}
+
+func (r *postgresBadgeRepository) GetByUsername(ctx context.Context, username string) ([]*model.UserBadge, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id, username, code, awarded_at FROM user_badges
+		WHERE username = $1 ORDER BY awarded_at`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var badges []*model.UserBadge
+	for rows.Next() {
+		var badge model.UserBadge
+		var id string
+		if err := rows.Scan(&id, &badge.Username, &badge.Code, &badge.AwardedAt); err != nil {
+			return nil, err
+		}
+		if badge.ID, err = parseObjectID(id); err != nil {
+			return nil, err
+		}
+		badges = append(badges, &badge)
+	}
+	return badges, rows.Err()
+}
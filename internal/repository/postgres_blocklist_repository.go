@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const blocklistColumns = `id, pattern, regex, severity, language, version, created_by, updated_by, created_at, updated_at`
+
+type postgresBlocklistRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresBlocklistRepository(db *sql.DB, timeout time.Duration) BlocklistRepository {
+	return &postgresBlocklistRepository{db: db, timeout: timeout}
+}
+
+func scanBlocklistEntry(row rowScanner) (*model.BlocklistEntry, error) {
+	var entry model.BlocklistEntry
+	var id, severity string
+	err := row.Scan(&id, &entry.Pattern, &entry.Regex, &severity, &entry.Language, &entry.Version,
+		&entry.CreatedBy, &entry.UpdatedBy, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if entry.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	entry.Severity = model.BlocklistSeverity(severity)
+	return &entry, nil
+}
+
+func (r *postgresBlocklistRepository) Create(ctx context.Context, entry *model.BlocklistEntry) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareBlocklistEntry(entry)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO blocklist (`+blocklistColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		entry.ID.Hex(), entry.Pattern, entry.Regex, string(entry.Severity), entry.Language, entry.Version,
+		entry.CreatedBy, entry.UpdatedBy, entry.CreatedAt, entry.UpdatedAt)
+	return err
+}
+
+func (r *postgresBlocklistRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.BlocklistEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	entry, err := scanBlocklistEntry(r.db.QueryRowContext(ctx,
+		`SELECT `+blocklistColumns+` FROM blocklist WHERE id = $1`, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *postgresBlocklistRepository) List(ctx context.Context) ([]*model.BlocklistEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+blocklistColumns+` FROM blocklist ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*model.BlocklistEntry
+	for rows.Next() {
+		entry, err := scanBlocklistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *postgresBlocklistRepository) Update(ctx context.Context, entry *model.BlocklistEntry) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE blocklist SET pattern = $2, regex = $3, severity = $4, language = $5,
+		version = $6, updated_by = $7, updated_at = $8 WHERE id = $1 AND version = $6 - 1`,
+		entry.ID.Hex(), entry.Pattern, entry.Regex, string(entry.Severity), entry.Language,
+		entry.Version, entry.UpdatedBy, entry.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *postgresBlocklistRepository) Delete(ctx context.Context, id primitive.ObjectID) (*model.BlocklistEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	entry, err := scanBlocklistEntry(r.db.QueryRowContext(ctx,
+		`DELETE FROM blocklist WHERE id = $1 RETURNING `+blocklistColumns, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+const channelColumns = `id, slug, name, description, created_by, created_at, updated_at`
+
+type postgresChannelRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresChannelRepository(db *sql.DB, timeout time.Duration) ChannelRepository {
+	return &postgresChannelRepository{db: db, timeout: timeout}
+}
+
+func scanChannel(row rowScanner) (*model.Channel, error) {
+	var channel model.Channel
+	var id string
+	err := row.Scan(&id, &channel.Slug, &channel.Name, &channel.Description, &channel.CreatedBy,
+		&channel.CreatedAt, &channel.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if channel.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r *postgresChannelRepository) Create(ctx context.Context, channel *model.Channel) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareChannel(channel)
+	result, err := r.db.ExecContext(ctx, `INSERT INTO channels (`+channelColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (slug) DO NOTHING`,
+		channel.ID.Hex(), channel.Slug, channel.Name, channel.Description, channel.CreatedBy,
+		channel.CreatedAt, channel.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted > 0, err
+}
+
+func (r *postgresChannelRepository) GetBySlug(ctx context.Context, slug string) (*model.Channel, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	channel, err := scanChannel(r.db.QueryRowContext(ctx,
+		`SELECT `+channelColumns+` FROM channels WHERE slug = $1`, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (r *postgresChannelRepository) List(ctx context.Context) ([]*model.Channel, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+channelColumns+` FROM channels ORDER BY slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []*model.Channel
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+func (r *postgresChannelRepository) Update(ctx context.Context, channel *model.Channel) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	channel.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `UPDATE channels SET name = $2, description = $3, updated_at = $4 WHERE slug = $1`,
+		channel.Slug, channel.Name, channel.Description, channel.UpdatedAt)
+	return err
+}
+
+func (r *postgresChannelRepository) Delete(ctx context.Context, slug string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM channels WHERE slug = $1`, slug)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+type postgresChannelMemberRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresChannelMemberRepository(db *sql.DB, timeout time.Duration) ChannelMemberRepository {
+	return &postgresChannelMemberRepository{db: db, timeout: timeout}
+}
+
+func (r *postgresChannelMemberRepository) Add(ctx context.Context, member *model.ChannelMember) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareChannelMember(member)
+	result, err := r.db.ExecContext(ctx, `INSERT INTO channel_members (id, channel, username, joined_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (channel, username) DO NOTHING`, member.ID.Hex(), member.Channel, member.Username, member.JoinedAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted > 0, err
+}
+
+func (r *postgresChannelMemberRepository) Remove(ctx context.Context, channel, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM channel_members WHERE channel = $1 AND username = $2`, channel, username)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+func (r *postgresChannelMemberRepository) IsMember(ctx context.Context, channel, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM channel_members WHERE channel = $1 AND username = $2)`,
+		channel, username).Scan(&exists)
+	return exists, err
+}
+
+func (r *postgresChannelMemberRepository) CountByChannel(ctx context.Context) (map[string]int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT channel, COUNT(*) FROM channel_members GROUP BY channel`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var channel string
+		var count int64
+		if err := rows.Scan(&channel, &count); err != nil {
+			return nil, err
+		}
+		counts[channel] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *postgresChannelMemberRepository) DeleteByChannel(ctx context.Context, channel string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM channel_members WHERE channel = $1`, channel)
+	return err
+}
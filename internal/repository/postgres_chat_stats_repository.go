@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+type postgresChatStatsRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresChatStatsRepository(db *sql.DB, timeout time.Duration) ChatStatsRepository {
+	return &postgresChatStatsRepository{db: db, timeout: timeout}
+}
+
+func (r *postgresChatStatsRepository) Increment(ctx context.Context, delta *model.ChatStats) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO chat_stats (username, total_chats, total_messages, total_duration_ms, skips,
+			current_streak, longest_streak, last_chat_day, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (username) DO UPDATE SET
+			total_chats = chat_stats.total_chats + EXCLUDED.total_chats,
+			total_messages = chat_stats.total_messages + EXCLUDED.total_messages,
+			total_duration_ms = chat_stats.total_duration_ms + EXCLUDED.total_duration_ms,
+			skips = chat_stats.skips + EXCLUDED.skips,
+			current_streak = EXCLUDED.current_streak,
+			longest_streak = EXCLUDED.longest_streak,
+			last_chat_day = EXCLUDED.last_chat_day,
+			updated_at = EXCLUDED.updated_at`,
+		delta.Username, delta.TotalChats, delta.TotalMessages, delta.TotalDuration, delta.Skips,
+		delta.CurrentStreak, delta.LongestStreak, delta.LastChatDay)
+	return err
+}
+
+func (r *postgresChatStatsRepository) GetByUsername(ctx context.Context, username string) (*model.ChatStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stats model.ChatStats
+	err := r.db.QueryRowContext(ctx, `SELECT username, total_chats, total_messages, total_duration_ms, skips,
+		current_streak, longest_streak, last_chat_day, updated_at
+		FROM chat_stats WHERE username = $1`, username).Scan(&stats.Username, &stats.TotalChats, &stats.TotalMessages,
+		&stats.TotalDuration, &stats.Skips, &stats.CurrentStreak, &stats.LongestStreak, &stats.LastChatDay,
+		&stats.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *postgresChatStatsRepository) Aggregate(ctx context.Context) (*model.GlobalChatStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stats model.GlobalChatStats
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(SUM(total_chats), 0), COALESCE(SUM(total_messages), 0),
+		COALESCE(SUM(total_duration_ms), 0), COALESCE(SUM(skips), 0) FROM chat_stats`).Scan(&stats.Users,
+		&stats.TotalChats, &stats.TotalMessages, &stats.TotalDuration, &stats.Skips)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+const disposableDomainColumns = `id, domain, blocked, updated_by, updated_at`
+
+type postgresDisposableDomainRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresDisposableDomainRepository(db *sql.DB, timeout time.Duration) DisposableDomainRepository {
+	return &postgresDisposableDomainRepository{db: db, timeout: timeout}
+}
+
+func scanDisposableDomain(row rowScanner) (*model.DisposableDomain, error) {
+	var domain model.DisposableDomain
+	var id string
+	err := row.Scan(&id, &domain.Domain, &domain.Blocked, &domain.UpdatedBy, &domain.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if domain.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (r *postgresDisposableDomainRepository) List(ctx context.Context) ([]*model.DisposableDomain, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+disposableDomainColumns+` FROM disposable_domains ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []*model.DisposableDomain
+	for rows.Next() {
+		domain, err := scanDisposableDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+func (r *postgresDisposableDomainRepository) Upsert(ctx context.Context, domain *model.DisposableDomain) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareDisposableDomain(domain)
+	var id string
+	err := r.db.QueryRowContext(ctx, `INSERT INTO disposable_domains (`+disposableDomainColumns+`) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (domain) DO UPDATE SET blocked = EXCLUDED.blocked, updated_by = EXCLUDED.updated_by,
+		updated_at = EXCLUDED.updated_at RETURNING id`,
+		domain.ID.Hex(), domain.Domain, domain.Blocked, domain.UpdatedBy, domain.UpdatedAt).Scan(&id)
+	if err != nil {
+		return err
+	}
+	domain.ID, err = parseObjectID(id)
+	return err
+}
+
+func (r *postgresDisposableDomainRepository) Delete(ctx context.Context, domain string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM disposable_domains WHERE domain = $1`, domain)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+const experimentColumns = `id, key, description, variants, status, created_by, created_at, stopped_by, stopped_at`
+
+type postgresExperimentRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresExperimentRepository(db *sql.DB, timeout time.Duration) ExperimentRepository {
+	return &postgresExperimentRepository{db: db, timeout: timeout}
+}
+
+func scanExperiment(row rowScanner) (*model.Experiment, error) {
+	var experiment model.Experiment
+	var id string
+	var variants []byte
+	var stoppedAt sql.NullTime
+	err := row.Scan(&id, &experiment.Key, &experiment.Description, &variants, &experiment.Status,
+		&experiment.CreatedBy, &experiment.CreatedAt, &experiment.StoppedBy, &stoppedAt)
+	if err != nil {
+		return nil, err
+	}
+	if experiment.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(variants, &experiment.Variants); err != nil {
+		return nil, err
+	}
+	if stoppedAt.Valid {
+		experiment.StoppedAt = &stoppedAt.Time
+	}
+	return &experiment, nil
+}
+
+func (r *postgresExperimentRepository) Create(ctx context.Context, experiment *model.Experiment) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareExperiment(experiment)
+	variants, err := json.Marshal(experiment.Variants)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO experiments (`+experimentColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		experiment.ID.Hex(), experiment.Key, experiment.Description, variants, experiment.Status,
+		experiment.CreatedBy, experiment.CreatedAt, experiment.StoppedBy, experiment.StoppedAt)
+	return postgresDuplicate(err, "experiments", "key")
+}
+
+func (r *postgresExperimentRepository) GetByKey(ctx context.Context, key string) (*model.Experiment, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	experiment, err := scanExperiment(r.db.QueryRowContext(ctx,
+		`SELECT `+experimentColumns+` FROM experiments WHERE key = $1`, key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return experiment, nil
+}
+
+func (r *postgresExperimentRepository) List(ctx context.Context) ([]*model.Experiment, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+experimentColumns+` FROM experiments ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []*model.Experiment
+	for rows.Next() {
+		experiment, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, experiment)
+	}
+	return experiments, rows.Err()
+}
+
+func (r *postgresExperimentRepository) Stop(ctx context.Context, key, stoppedBy string, stoppedAt time.Time) (*model.Experiment, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	experiment, err := scanExperiment(r.db.QueryRowContext(ctx, `UPDATE experiments
+		SET status = $3, stopped_by = $4, stopped_at = $5
+		WHERE key = $1 AND status = $2
+		RETURNING `+experimentColumns,
+		key, model.ExperimentRunning, model.ExperimentStopped, stoppedBy, stoppedAt))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return experiment, nil
+}
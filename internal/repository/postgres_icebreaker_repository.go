@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const icebreakerColumns = `id, text, language, active, use_count, last_used_at, created_at, updated_at`
+
+type postgresIcebreakerRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresIcebreakerRepository(db *sql.DB, timeout time.Duration) IcebreakerRepository {
+	return &postgresIcebreakerRepository{db: db, timeout: timeout}
+}
+
+func scanIcebreaker(row rowScanner) (*model.Icebreaker, error) {
+	var icebreaker model.Icebreaker
+	var id string
+	var lastUsed sql.NullTime
+	err := row.Scan(&id, &icebreaker.Text, &icebreaker.Language, &icebreaker.Active, &icebreaker.UseCount,
+		&lastUsed, &icebreaker.CreatedAt, &icebreaker.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if icebreaker.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		icebreaker.LastUsed = &lastUsed.Time
+	}
+	return &icebreaker, nil
+}
+
+func insertIcebreaker(ctx context.Context, db execer, icebreaker *model.Icebreaker) error {
+	prepareIcebreaker(icebreaker)
+
+	_, err := db.ExecContext(ctx, `INSERT INTO icebreakers (`+icebreakerColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		icebreaker.ID.Hex(), icebreaker.Text, icebreaker.Language, icebreaker.Active, icebreaker.UseCount,
+		icebreaker.LastUsed, icebreaker.CreatedAt, icebreaker.UpdatedAt)
+	return err
+}
+
+func (r *postgresIcebreakerRepository) Create(ctx context.Context, icebreaker *model.Icebreaker) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return insertIcebreaker(ctx, r.db, icebreaker)
+}
+
+func (r *postgresIcebreakerRepository) CreateMany(ctx context.Context, icebreakers []*model.Icebreaker) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, icebreaker := range icebreakers {
+		if err := insertIcebreaker(ctx, tx, icebreaker); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresIcebreakerRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.Icebreaker, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	icebreaker, err := scanIcebreaker(r.db.QueryRowContext(ctx,
+		`SELECT `+icebreakerColumns+` FROM icebreakers WHERE id = $1`, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return icebreaker, nil
+}
+
+func (r *postgresIcebreakerRepository) List(ctx context.Context, activeOnly bool) ([]*model.Icebreaker, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT ` + icebreakerColumns + ` FROM icebreakers`
+	if activeOnly {
+		query += ` WHERE active`
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var icebreakers []*model.Icebreaker
+	for rows.Next() {
+		icebreaker, err := scanIcebreaker(rows)
+		if err != nil {
+			return nil, err
+		}
+		icebreakers = append(icebreakers, icebreaker)
+	}
+	return icebreakers, rows.Err()
+}
+
+func (r *postgresIcebreakerRepository) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM icebreakers`).Scan(&count)
+	return count, err
+}
+
+func (r *postgresIcebreakerRepository) Update(ctx context.Context, icebreaker *model.Icebreaker) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	icebreaker.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `UPDATE icebreakers SET text = $2, language = $3, active = $4, updated_at = $5 WHERE id = $1`,
+		icebreaker.ID.Hex(), icebreaker.Text, icebreaker.Language, icebreaker.Active, icebreaker.UpdatedAt)
+	return err
+}
+
+func (r *postgresIcebreakerRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM icebreakers WHERE id = $1`, id.Hex())
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+func (r *postgresIcebreakerRepository) RecordUse(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE icebreakers SET use_count = use_count + 1, last_used_at = $2 WHERE id = $1`,
+		id.Hex(), usedAt)
+	return err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/lib/pq"
+)
+
+const legalHoldColumns = `id, user_id, username, reason, case_ref, placed_by, placed_at`
+
+type postgresLegalHoldRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresLegalHoldRepository(db *sql.DB, timeout time.Duration) LegalHoldRepository {
+	return &postgresLegalHoldRepository{db: db, timeout: timeout}
+}
+
+func scanLegalHold(row rowScanner) (*model.LegalHold, error) {
+	var hold model.LegalHold
+	var id, userID string
+	err := row.Scan(&id, &userID, &hold.Username, &hold.Reason, &hold.CaseRef, &hold.PlacedBy, &hold.PlacedAt)
+	if err != nil {
+		return nil, err
+	}
+	if hold.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if hold.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *postgresLegalHoldRepository) Create(ctx context.Context, hold *model.LegalHold) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareLegalHold(hold)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO legal_holds (`+legalHoldColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		hold.ID.Hex(), hold.UserID.Hex(), hold.Username, hold.Reason, hold.CaseRef, hold.PlacedBy, hold.PlacedAt)
+	return postgresDuplicate(err, "legal_holds", "username")
+}
+
+func (r *postgresLegalHoldRepository) GetByUsername(ctx context.Context, username string) (*model.LegalHold, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	hold, err := scanLegalHold(r.db.QueryRowContext(ctx,
+		`SELECT `+legalHoldColumns+` FROM legal_holds WHERE username = $1`, username))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (r *postgresLegalHoldRepository) List(ctx context.Context) ([]*model.LegalHold, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+legalHoldColumns+` FROM legal_holds ORDER BY placed_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []*model.LegalHold
+	for rows.Next() {
+		hold, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+func (r *postgresLegalHoldRepository) Delete(ctx context.Context, username string) (*model.LegalHold, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	hold, err := scanLegalHold(r.db.QueryRowContext(ctx,
+		`DELETE FROM legal_holds WHERE username = $1 RETURNING `+legalHoldColumns, username))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// heldArrays returns the IDs and usernames of the held users for `<> ALL` conditions.
+// The arrays are never NULL, which would match no row.
+func heldArrays(held model.HeldUsers) (userIDs, usernames interface{}) {
+	ids := make([]string, 0, len(held.UserIDs))
+	for _, id := range held.UserIDs {
+		ids = append(ids, id.Hex())
+	}
+	return pq.Array(ids), pq.Array(append([]string{}, held.Usernames...))
+}
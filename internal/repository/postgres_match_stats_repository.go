@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+type postgresMatchStatsRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresMatchStatsRepository(db *sql.DB, timeout time.Duration) MatchStatsRepository {
+	return &postgresMatchStatsRepository{db: db, timeout: timeout}
+}
+
+func (r *postgresMatchStatsRepository) Increment(ctx context.Context, delta *model.MatchStats) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO match_stats
+		(day, bucket, matches, bot_matches, wait_ms, abandoned, chats, long_chats, skips, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		ON CONFLICT (day, bucket) DO UPDATE SET
+			matches = match_stats.matches + EXCLUDED.matches,
+			bot_matches = match_stats.bot_matches + EXCLUDED.bot_matches,
+			wait_ms = match_stats.wait_ms + EXCLUDED.wait_ms,
+			abandoned = match_stats.abandoned + EXCLUDED.abandoned,
+			chats = match_stats.chats + EXCLUDED.chats,
+			long_chats = match_stats.long_chats + EXCLUDED.long_chats,
+			skips = match_stats.skips + EXCLUDED.skips,
+			updated_at = EXCLUDED.updated_at`,
+		delta.Day, delta.Bucket, delta.Matches, delta.BotMatches, delta.WaitMs, delta.Abandoned,
+		delta.Chats, delta.LongChats, delta.Skips)
+	return err
+}
+
+func (r *postgresMatchStatsRepository) ListSince(ctx context.Context, day string) ([]*model.MatchStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT day, bucket, matches, bot_matches, wait_ms, abandoned,
+		chats, long_chats, skips, updated_at FROM match_stats WHERE day >= $1 ORDER BY day, bucket`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*model.MatchStats
+	for rows.Next() {
+		var s model.MatchStats
+		if err := rows.Scan(&s.Day, &s.Bucket, &s.Matches, &s.BotMatches, &s.WaitMs, &s.Abandoned,
+			&s.Chats, &s.LongChats, &s.Skips, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stats = append(stats, &s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const messageColumns = `id, room_code, sender, text, created_at, edited_at, is_deleted, deleted_at, flagged, spam, shadowed`
+
+type postgresMessageRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresMessageRepository(db *sql.DB, timeout time.Duration) MessageRepository {
+	return &postgresMessageRepository{db: db, timeout: timeout}
+}
+
+func scanMessage(row rowScanner) (*model.Message, error) {
+	var message model.Message
+	var id string
+	var editedAt, deletedAt sql.NullTime
+	err := row.Scan(&id, &message.RoomCode, &message.From, &message.Text, &message.CreatedAt, &editedAt,
+		&message.IsDeleted, &deletedAt, &message.Flagged, pq.Array(&message.Spam), &message.Shadowed)
+	if err != nil {
+		return nil, err
+	}
+	if message.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if editedAt.Valid {
+		message.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		message.DeletedAt = &deletedAt.Time
+	}
+	return &message, nil
+}
+
+func (r *postgresMessageRepository) Create(ctx context.Context, message *model.Message) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO messages (`+messageColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		message.ID.Hex(), message.RoomCode, message.From, message.Text, message.CreatedAt, message.EditedAt,
+		message.IsDeleted, message.DeletedAt, message.Flagged, pq.Array(message.Spam), message.Shadowed)
+	return err
+}
+
+func (r *postgresMessageRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.Message, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	message, err := scanMessage(r.db.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = $1`, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+func (r *postgresMessageRepository) Update(ctx context.Context, message *model.Message) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE messages SET text = $2, edited_at = $3, is_deleted = $4, deleted_at = $5,
+		flagged = $6, spam = $7 WHERE id = $1`,
+		message.ID.Hex(), message.Text, message.EditedAt, message.IsDeleted, message.DeletedAt, message.Flagged,
+		pq.Array(message.Spam))
+	return err
+}
+
+func (r *postgresMessageRepository) AddRevision(ctx context.Context, id primitive.ObjectID, revision model.MessageRevision) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO message_revisions (id, message_id, text, flagged, written_at, replaced_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		primitive.NewObjectID().Hex(), id.Hex(), revision.Text, revision.Flagged, revision.WrittenAt, revision.ReplacedAt)
+	return err
+}
+
+func (r *postgresMessageRepository) GetRevisions(ctx context.Context, id primitive.ObjectID) ([]model.MessageRevision, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT text, flagged, written_at, replaced_at FROM message_revisions
+		WHERE message_id = $1 ORDER BY replaced_at, id`, id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []model.MessageRevision
+	for rows.Next() {
+		var revision model.MessageRevision
+		if err := rows.Scan(&revision.Text, &revision.Flagged, &revision.WrittenAt, &revision.ReplacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *postgresMessageRepository) GetByRoom(ctx context.Context, roomCode string, limit int) ([]*model.Message, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+messageColumns+` FROM messages
+		WHERE room_code = $1 ORDER BY created_at DESC LIMIT $2`, roomCode, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*model.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reverseMessages(messages)
+	return messages, nil
+}
+
+func (r *postgresMessageRepository) HasSender(ctx context.Context, roomCode, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM messages WHERE room_code = $1 AND sender = $2)`,
+		roomCode, username).Scan(&exists)
+	return exists, err
+}
+
+func (r *postgresMessageRepository) CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, usernames := heldArrays(held)
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE created_at < $1 AND sender <> ALL($2)`,
+		before, usernames).Scan(&count)
+	return count, err
+}
+
+func (r *postgresMessageRepository) DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, usernames := heldArrays(held)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM message_revisions WHERE message_id IN
+		(SELECT id FROM messages WHERE created_at < $1 AND sender <> ALL($2))`, before, usernames); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE created_at < $1 AND sender <> ALL($2)`, before, usernames)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *postgresMessageRepository) DeleteBySender(ctx context.Context, from, roomCode string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	where, args := `sender = $1`, []interface{}{from}
+	if roomCode != "" {
+		where, args = where+` AND room_code = $2`, append(args, roomCode)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM message_revisions WHERE message_id IN
+		(SELECT id FROM messages WHERE `+where+`)`, args...); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const networkBanColumns = `id, type, value, reason, created_by, updated_by, created_at, updated_at, expires_at`
+
+type postgresNetworkBanRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresNetworkBanRepository(db *sql.DB, timeout time.Duration) NetworkBanRepository {
+	return &postgresNetworkBanRepository{db: db, timeout: timeout}
+}
+
+func scanNetworkBan(row rowScanner) (*model.NetworkBan, error) {
+	var ban model.NetworkBan
+	var id string
+	var expiresAt sql.NullTime
+	err := row.Scan(&id, &ban.Type, &ban.Value, &ban.Reason, &ban.CreatedBy, &ban.UpdatedBy,
+		&ban.CreatedAt, &ban.UpdatedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if ban.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	}
+	return &ban, nil
+}
+
+func (r *postgresNetworkBanRepository) Create(ctx context.Context, ban *model.NetworkBan) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareNetworkBan(ban)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO network_bans (`+networkBanColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		ban.ID.Hex(), ban.Type, ban.Value, ban.Reason, ban.CreatedBy, ban.UpdatedBy, ban.CreatedAt, ban.UpdatedAt, ban.ExpiresAt)
+	return postgresDuplicate(err, "network_bans")
+}
+
+func (r *postgresNetworkBanRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.NetworkBan, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ban, err := scanNetworkBan(r.db.QueryRowContext(ctx,
+		`SELECT `+networkBanColumns+` FROM network_bans WHERE id = $1`, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ban, nil
+}
+
+func (r *postgresNetworkBanRepository) List(ctx context.Context) ([]*model.NetworkBan, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+networkBanColumns+` FROM network_bans ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []*model.NetworkBan
+	for rows.Next() {
+		ban, err := scanNetworkBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+func (r *postgresNetworkBanRepository) Update(ctx context.Context, ban *model.NetworkBan) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE network_bans SET reason = $2, updated_by = $3, updated_at = $4,
+		expires_at = $5 WHERE id = $1`,
+		ban.ID.Hex(), ban.Reason, ban.UpdatedBy, ban.UpdatedAt, ban.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *postgresNetworkBanRepository) Delete(ctx context.Context, id primitive.ObjectID) (*model.NetworkBan, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ban, err := scanNetworkBan(r.db.QueryRowContext(ctx,
+		`DELETE FROM network_bans WHERE id = $1 RETURNING `+networkBanColumns, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ban, nil
+}
+
+func (r *postgresNetworkBanRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM network_bans WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
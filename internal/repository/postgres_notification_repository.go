@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const notificationColumns = `id, user_id, username, type, title, body, data, read_at, created_at`
+
+type postgresNotificationRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresNotificationRepository(db *sql.DB, timeout time.Duration) NotificationRepository {
+	return &postgresNotificationRepository{db: db, timeout: timeout}
+}
+
+func scanNotification(row rowScanner) (*model.Notification, error) {
+	var notification model.Notification
+	var id, userID string
+	var data []byte
+	var readAt sql.NullTime
+	err := row.Scan(&id, &userID, &notification.Username, &notification.Type, &notification.Title, &notification.Body,
+		&data, &readAt, &notification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if notification.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if notification.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &notification.Data); err != nil {
+			return nil, err
+		}
+	}
+	if readAt.Valid {
+		notification.ReadAt = &readAt.Time
+	}
+	return &notification, nil
+}
+
+func insertNotification(ctx context.Context, db execer, notification *model.Notification) error {
+	prepareNotification(notification)
+
+	data, err := json.Marshal(notification.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO notifications (`+notificationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		notification.ID.Hex(), notification.UserID.Hex(), notification.Username, notification.Type, notification.Title,
+		notification.Body, data, notification.ReadAt, notification.CreatedAt)
+	return err
+}
+
+func (r *postgresNotificationRepository) Create(ctx context.Context, notification *model.Notification) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return insertNotification(ctx, r.db, notification)
+}
+
+func (r *postgresNotificationRepository) CreateMany(ctx context.Context, notifications []*model.Notification) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, notification := range notifications {
+		if err := insertNotification(ctx, tx, notification); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresNotificationRepository) GetByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE user_id = $1`
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, userID.Hex(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*model.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *postgresNotificationRepository) MarkRead(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `WITH updated AS (
+			UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2 RETURNING 1
+		) SELECT EXISTS(SELECT 1 FROM updated)`, id.Hex(), userID.Hex()).Scan(&exists)
+	return exists, err
+}
+
+func (r *postgresNotificationRepository) MarkAllRead(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`,
+		userID.Hex())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *postgresNotificationRepository) CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`,
+		userID.Hex()).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+type postgresProfileViewRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresProfileViewRepository(db *sql.DB, timeout time.Duration) ProfileViewRepository {
+	return &postgresProfileViewRepository{db: db, timeout: timeout}
+}
+
+func (r *postgresProfileViewRepository) AddMany(ctx context.Context, views []*model.ProfileView) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if len(views) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, view := range views {
+		prepareProfileView(view)
+		_, err := tx.ExecContext(ctx, `INSERT INTO profile_views (id, username, viewer, day, viewed_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (username, day, viewer) DO NOTHING`, view.ID.Hex(), view.Username, view.Viewer, view.Day, view.ViewedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresProfileViewRepository) Count(ctx context.Context, username, sinceDay string) (*model.ProfileViewStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stats model.ProfileViewStats
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(*) FILTER (WHERE day >= $2) FROM profile_views WHERE username = $1`,
+		username, sinceDay).Scan(&stats.Total, &stats.Last7Days)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const roomEventColumns = `id, room, seq, type, username, reason, message_count, messages_by, score, at`
+
+type postgresRoomEventRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresRoomEventRepository(db *sql.DB, timeout time.Duration) RoomEventRepository {
+	return &postgresRoomEventRepository{db: db, timeout: timeout}
+}
+
+func scanRoomEvent(row rowScanner) (*model.RoomEvent, error) {
+	var e model.RoomEvent
+	var id string
+	var messagesBy []byte
+	var score sql.NullFloat64
+	err := row.Scan(&id, &e.RoomCode, &e.Seq, &e.Type, &e.Username, &e.Reason, &e.MessageCount, &messagesBy, &score, &e.At)
+	if err != nil {
+		return nil, err
+	}
+	if score.Valid {
+		e.Score = &score.Float64
+	}
+	if e.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if len(messagesBy) > 0 {
+		if err := json.Unmarshal(messagesBy, &e.MessagesBy); err != nil {
+			return nil, err
+		}
+	}
+	return &e, nil
+}
+
+func (r *postgresRoomEventRepository) Create(ctx context.Context, e *model.RoomEvent) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if e.ID.IsZero() {
+		e.ID = primitive.NewObjectID()
+	}
+
+	var messagesBy []byte
+	if len(e.MessagesBy) > 0 {
+		var err error
+		if messagesBy, err = json.Marshal(e.MessagesBy); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO room_events (`+roomEventColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		e.ID.Hex(), e.RoomCode, e.Seq, e.Type, e.Username, e.Reason, e.MessageCount, messagesBy, e.Score, e.At)
+	return err
+}
+
+func (r *postgresRoomEventRepository) Find(ctx context.Context, filter model.RoomEventFilter, limit int) ([]*model.RoomEvent, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if filter.RoomCode != "" {
+		addCondition("room = ?", filter.RoomCode)
+	}
+	if filter.Username != "" {
+		addCondition("username = ?", filter.Username)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("at >= ?", filter.Since)
+	}
+
+	query := `SELECT ` + roomEventColumns + ` FROM room_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += ` ORDER BY at DESC, seq DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*model.RoomEvent
+	for rows.Next() {
+		e, err := scanRoomEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *postgresRoomEventRepository) CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, usernames := heldArrays(held)
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM room_events WHERE at < $1 AND username <> ALL($2)`,
+		before, usernames).Scan(&count)
+	return count, err
+}
+
+func (r *postgresRoomEventRepository) DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, usernames := heldArrays(held)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM room_events WHERE at < $1 AND username <> ALL($2)`, before, usernames)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
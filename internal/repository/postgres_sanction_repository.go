@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+const sanctionColumns = `id, user_id, username, type, reason, created_by, created_at, expires_at, lifted_by, lifted_at`
+
+type postgresSanctionRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresSanctionRepository(db *sql.DB, timeout time.Duration) SanctionRepository {
+	return &postgresSanctionRepository{db: db, timeout: timeout}
+}
+
+func scanSanction(row rowScanner) (*model.Sanction, error) {
+	var sanction model.Sanction
+	var id, userID string
+	var liftedAt sql.NullTime
+	err := row.Scan(&id, &userID, &sanction.Username, &sanction.Type, &sanction.Reason, &sanction.CreatedBy,
+		&sanction.CreatedAt, &sanction.ExpiresAt, &sanction.LiftedBy, &liftedAt)
+	if err != nil {
+		return nil, err
+	}
+	if sanction.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if sanction.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	if liftedAt.Valid {
+		sanction.LiftedAt = &liftedAt.Time
+	}
+	return &sanction, nil
+}
+
+func (r *postgresSanctionRepository) Create(ctx context.Context, sanction *model.Sanction) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareSanction(sanction)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO sanctions (`+sanctionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		sanction.ID.Hex(), sanction.UserID.Hex(), sanction.Username, sanction.Type, sanction.Reason,
+		sanction.CreatedBy, sanction.CreatedAt, sanction.ExpiresAt, sanction.LiftedBy, sanction.LiftedAt)
+	return err
+}
+
+func (r *postgresSanctionRepository) GetActive(ctx context.Context, username, sanctionType string, now time.Time) (*model.Sanction, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	sanction, err := scanSanction(r.db.QueryRowContext(ctx, `SELECT `+sanctionColumns+` FROM sanctions
+		WHERE username = $1 AND type = $2 AND lifted_at IS NULL AND expires_at > $3
+		ORDER BY expires_at DESC LIMIT 1`, username, sanctionType, now))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sanction, nil
+}
+
+func (r *postgresSanctionRepository) ListByUsername(ctx context.Context, username string) ([]*model.Sanction, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+sanctionColumns+` FROM sanctions
+		WHERE username = $1 ORDER BY created_at DESC, id DESC`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []*model.Sanction
+	for rows.Next() {
+		sanction, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, sanction)
+	}
+	return sanctions, rows.Err()
+}
+
+func (r *postgresSanctionRepository) Lift(ctx context.Context, username, sanctionType, liftedBy string, now time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE sanctions SET lifted_by = $1, lifted_at = $2
+		WHERE username = $3 AND type = $4 AND lifted_at IS NULL AND expires_at > $2`,
+		liftedBy, now, username, sanctionType)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
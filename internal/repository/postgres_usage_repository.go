@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+type postgresUsageRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresUsageRepository(db *sql.DB, timeout time.Duration) UsageRepository {
+	return &postgresUsageRepository{db: db, timeout: timeout}
+}
+
+func (r *postgresUsageRepository) Increment(ctx context.Context, delta *model.DailyUsage) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO daily_usage (username, day, chats_started, chat_seconds, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (username, day) DO UPDATE SET
+			chats_started = daily_usage.chats_started + EXCLUDED.chats_started,
+			chat_seconds = daily_usage.chat_seconds + EXCLUDED.chat_seconds,
+			updated_at = EXCLUDED.updated_at`,
+		delta.Username, delta.Day, delta.ChatsStarted, delta.ChatSeconds)
+	return err
+}
+
+func (r *postgresUsageRepository) Get(ctx context.Context, username, day string) (*model.DailyUsage, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var usage model.DailyUsage
+	err := r.db.QueryRowContext(ctx, `SELECT username, day, chats_started, chat_seconds, updated_at
+		FROM daily_usage WHERE username = $1 AND day = $2`, username, day).Scan(&usage.Username, &usage.Day,
+		&usage.ChatsStarted, &usage.ChatSeconds, &usage.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,434 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const userColumns = `id, username, email, password_hash, age, gender, bio, is_online, is_verified,
+	last_seen, joined_at, updated_at, room_id, languages, translate_opt_in, role, is_banned, banned_at,
+	is_premium, two_factor_enabled, two_factor_secret, recovery_codes, featured_badges,
+	content_filter, hide_profile_views, last_seen_visibility, subscription_tier, subscription_expires_at,
+	subscription_source, subscription_reference, subscription_updated_at, time_zone, active_hours_start,
+	active_hours_end`
+
+type postgresUserRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresUserRepository(db *sql.DB, timeout time.Duration) UserRepository {
+	return &postgresUserRepository{db: db, timeout: timeout}
+}
+
+func scanUser(row rowScanner) (*model.User, error) {
+	var user model.User
+	var id, gender, role, contentFilter, lastSeenVisibility string
+	var bannedAt, subscriptionExpiresAt, subscriptionUpdatedAt sql.NullTime
+	var subscription model.Subscription
+	var subscriptionTier string
+	var activeStart, activeEnd sql.NullInt32
+	err := row.Scan(&id, &user.Username, &user.Email, &user.PasswordHash, &user.Age, &gender, &user.Bio,
+		&user.IsOnline, &user.IsVerified, &user.LastSeen, &user.JoinedAt, &user.UpdatedAt, &user.RoomID,
+		pq.Array(&user.Languages), &user.TranslateOptIn, &role, &user.IsBanned, &bannedAt,
+		&user.IsPremium, &user.TwoFactorEnabled, &user.TwoFactorSecret, pq.Array(&user.RecoveryCodes),
+		pq.Array(&user.FeaturedBadges), &contentFilter, &user.HideProfileViews, &lastSeenVisibility,
+		&subscriptionTier, &subscriptionExpiresAt, &subscription.Source, &subscription.Reference,
+		&subscriptionUpdatedAt, &user.TimeZone, &activeStart, &activeEnd)
+	if err != nil {
+		return nil, err
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+	if subscriptionTier != "" {
+		subscription.Tier = model.Tier(subscriptionTier)
+		if subscriptionExpiresAt.Valid {
+			subscription.ExpiresAt = &subscriptionExpiresAt.Time
+		}
+		subscription.UpdatedAt = subscriptionUpdatedAt.Time
+		user.Subscription = &subscription
+	}
+	if activeStart.Valid && activeEnd.Valid {
+		user.ActiveHours = &model.ActiveHours{Start: int(activeStart.Int32), End: int(activeEnd.Int32)}
+	}
+	if user.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	user.Gender = model.Gender(gender)
+	user.Role = model.Role(role)
+	user.ContentFilter = model.ContentFilter(contentFilter)
+	user.LastSeenVisibility = model.LastSeenVisibility(lastSeenVisibility)
+	return &user, nil
+}
+
+// userValues returns the column values of a user in userColumns order
+func userValues(user *model.User) []interface{} {
+	subscription := user.Subscription
+	if subscription == nil {
+		subscription = &model.Subscription{}
+	}
+	var subscriptionUpdatedAt *time.Time
+	if !subscription.UpdatedAt.IsZero() {
+		subscriptionUpdatedAt = &subscription.UpdatedAt
+	}
+	var activeStart, activeEnd *int
+	if user.ActiveHours != nil {
+		activeStart, activeEnd = &user.ActiveHours.Start, &user.ActiveHours.End
+	}
+	return []interface{}{
+		user.ID.Hex(), user.Username, user.Email, user.PasswordHash, user.Age, string(user.Gender), user.Bio,
+		user.IsOnline, user.IsVerified, user.LastSeen, user.JoinedAt, user.UpdatedAt, user.RoomID,
+		pq.Array(user.Languages), user.TranslateOptIn, string(user.EffectiveRole()), user.IsBanned, user.BannedAt,
+		user.IsPremium, user.TwoFactorEnabled, user.TwoFactorSecret, pq.Array(user.RecoveryCodes),
+		pq.Array(user.FeaturedBadges), string(user.ContentFilter), user.HideProfileViews,
+		string(user.LastSeenVisibility), string(subscription.Tier), subscription.ExpiresAt, subscription.Source,
+		subscription.Reference, subscriptionUpdatedAt, user.TimeZone, activeStart, activeEnd,
+	}
+}
+
+// userPlaceholders returns "$1, $2, ..." for the user columns
+func userPlaceholders() string {
+	return placeholders(1, len(strings.Split(userColumns, ",")))
+}
+
+// userAssignments returns "col = $n" pairs for every user column except the id
+func userAssignments() string {
+	columns := strings.Split(userColumns, ",")
+	assignments := make([]string, 0, len(columns)-1)
+	for i, column := range columns[1:] {
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", strings.TrimSpace(column), i+2))
+	}
+	return strings.Join(assignments, ", ")
+}
+
+func (r *postgresUserRepository) queryOne(ctx context.Context, query string, args ...interface{}) (*model.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *postgresUserRepository) queryMany(ctx context.Context, query string, args ...interface{}) ([]*model.User, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *postgresUserRepository) Create(ctx context.Context, user *model.User) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	preparePostgresUser(user)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (`+userPlaceholders()+`)`,
+		userValues(user)...)
+	return postgresDuplicate(err, "users", "username", "email")
+}
+
+// CreateMany inserts the batch in one transaction, each user behind a savepoint so a
+// taken username or email only skips that user
+func (r *postgresUserRepository) CreateMany(ctx context.Context, users []*model.User) ([]error, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	errs := make([]error, len(users))
+	if len(users) == 0 {
+		return errs, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i, user := range users {
+		preparePostgresUser(user)
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT create_user`); err != nil {
+			return nil, err
+		}
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (`+userPlaceholders()+`)`,
+			userValues(user)...)
+		if err = postgresDuplicate(err, "users", "username", "email"); errors.Is(err, ErrDuplicate) {
+			errs[i] = err
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT create_user`); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT create_user`); err != nil {
+			return nil, err
+		}
+	}
+	return errs, tx.Commit()
+}
+
+func preparePostgresUser(user *model.User) {
+	prepareNewUser(user)
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.JoinedAt
+	}
+}
+
+func (r *postgresUserRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.queryOne(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id.Hex())
+}
+
+func (r *postgresUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.queryOne(ctx, `SELECT `+userColumns+` FROM users WHERE lower(username) = lower($1)`,
+		model.NormalizeUsername(username))
+}
+
+func (r *postgresUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.queryOne(ctx, `SELECT `+userColumns+` FROM users WHERE lower(email) = lower($1) LIMIT 1`,
+		model.NormalizeEmail(email))
+}
+
+func (r *postgresUserRepository) Update(ctx context.Context, user *model.User) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET `+userAssignments()+` WHERE id = $1`, userValues(user)...)
+	return err
+}
+
+func (r *postgresUserRepository) UpdateLastSeen(ctx context.Context, username string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET last_seen = $2 WHERE username = $1`, username, time.Now())
+	return err
+}
+
+func (r *postgresUserRepository) ReplacePasswordHash(ctx context.Context, id primitive.ObjectID, current, hash string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET password_hash = $3 WHERE id = $1 AND password_hash = $2`,
+		id.Hex(), current, hash)
+	return err
+}
+
+func (r *postgresUserRepository) SetOnlineStatus(ctx context.Context, username string, online bool) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if online {
+		_, err := r.db.ExecContext(ctx, `UPDATE users SET is_online = TRUE, last_seen = $2 WHERE username = $1`,
+			username, time.Now())
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET is_online = FALSE WHERE username = $1`, username)
+	return err
+}
+
+func (r *postgresUserRepository) GetOnlineUsers(ctx context.Context, page PageRequest) (*Page[*model.User], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.queryPage(ctx, "is_online", nil, onlineUsersSort, page, usernameCursor)
+}
+
+func (r *postgresUserRepository) GetAllUsers(ctx context.Context, page PageRequest) (*Page[*model.User], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.queryPage(ctx, "", nil, allUsersSort, page, joinedCursor)
+}
+
+// queryPage returns a page of the users matching the condition, whose arguments are
+// args, see pageClause
+func (r *postgresUserRepository) queryPage(ctx context.Context, condition string, args []interface{}, sort Sort, page PageRequest, cursorOf func(*model.User) Cursor) (*Page[*model.User], error) {
+	after, clause, pageArgs := pageClause(sort, page, len(args)+1)
+	query := `SELECT ` + userColumns + ` FROM users` + whereAll(condition, after) + clause
+	users, err := r.queryMany(ctx, query, append(args, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(users, page.limit(), cursorOf), nil
+}
+
+func (r *postgresUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id.Hex())
+	return err
+}
+
+func (r *postgresUserRepository) DeleteByUsername(ctx context.Context, username string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE username = $1`, username)
+	return err
+}
+
+func (r *postgresUserRepository) Exists(ctx context.Context, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE lower(username) = lower($1))`,
+		model.NormalizeUsername(username)).Scan(&exists)
+	return exists, err
+}
+
+func (r *postgresUserRepository) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+func (r *postgresUserRepository) FindByFilter(ctx context.Context, filter model.UserFilter, page PageRequest) (*Page[*model.User], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.JoinedAfter != nil {
+		addCondition("joined_at >= $%d", *filter.JoinedAfter)
+	}
+	if filter.JoinedBefore != nil {
+		addCondition("joined_at < $%d", *filter.JoinedBefore)
+	}
+	if filter.Unverified {
+		conditions = append(conditions, "NOT is_verified")
+	}
+	if filter.InactiveSince != nil {
+		addCondition("last_seen < $%d", *filter.InactiveSince)
+	}
+
+	return r.queryPage(ctx, strings.Join(conditions, " AND "), args, allUsersSort, page, joinedCursor)
+}
+
+func (r *postgresUserRepository) Search(ctx context.Context, query model.UserDirectoryQuery, page PageRequest) (*Page[*model.User], int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.Banned != nil {
+		addCondition("is_banned = $%d", *query.Banned)
+	}
+	if query.Verified != nil {
+		addCondition("is_verified = $%d", *query.Verified)
+	}
+	if query.RegisteredAfter != nil {
+		addCondition("joined_at >= $%d", *query.RegisteredAfter)
+	}
+	if query.RegisteredBefore != nil {
+		addCondition("joined_at < $%d", *query.RegisteredBefore)
+	}
+	if query.LastSeenBefore != nil {
+		addCondition("last_seen < $%d", *query.LastSeenBefore)
+	}
+	if query.EmailDomain != "" {
+		addCondition("lower(split_part(email, '@', 2)) = $%d", strings.ToLower(query.EmailDomain))
+	}
+	condition := strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+whereAll(condition), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// The sort field is one of the model.UserSort fields, never client input
+	sort, cursorOf := directorySort(query.Sort)
+	users, err := r.queryPage(ctx, condition, args, sort, page, cursorOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *postgresUserRepository) SetBanned(ctx context.Context, ids []primitive.ObjectID, banned bool, at time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var bannedAt interface{}
+	if banned {
+		bannedAt = at
+	}
+	result, err := r.db.ExecContext(ctx, `UPDATE users SET is_banned = $2, banned_at = $3, updated_at = $4
+		WHERE id = ANY($1) AND is_banned <> $2`, pq.Array(objectIDHexes(ids)), banned, bannedAt, at)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *postgresUserRepository) SetVerified(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE users SET is_verified = TRUE, updated_at = $2
+		WHERE id = ANY($1) AND NOT is_verified`, pq.Array(objectIDHexes(ids)), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *postgresUserRepository) DeleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = ANY($1)`, pq.Array(objectIDHexes(ids)))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const verificationColumns = `id, user_id, purpose, code_hash, attempts, ip_address, user_agent, is_used, expires_at, created_at`
+
+type postgresVerificationCodeRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresVerificationCodeRepository(db *sql.DB, timeout time.Duration) VerificationCodeRepository {
+	return &postgresVerificationCodeRepository{db: db, timeout: timeout}
+}
+
+func scanVerificationCode(row rowScanner) (*model.VerificationCode, error) {
+	var code model.VerificationCode
+	var id, userID string
+	err := row.Scan(&id, &userID, &code.Purpose, &code.CodeHash, &code.Attempts, &code.IPAddress, &code.UserAgent,
+		&code.IsUsed, &code.ExpiresAt, &code.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if code.ID, err = parseObjectID(id); err != nil {
+		return nil, err
+	}
+	if code.UserID, err = parseObjectID(userID); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *postgresVerificationCodeRepository) Create(ctx context.Context, code *model.VerificationCode) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if code.ID.IsZero() {
+		code.ID = primitive.NewObjectID()
+	}
+	if code.CreatedAt.IsZero() {
+		code.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO verification_codes (`+verificationColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		code.ID.Hex(), code.UserID.Hex(), code.Purpose, code.CodeHash, code.Attempts, code.IPAddress, code.UserAgent,
+		code.IsUsed, code.ExpiresAt, code.CreatedAt)
+	return err
+}
+
+func (r *postgresVerificationCodeRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.VerificationCode, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	code, err := scanVerificationCode(r.db.QueryRowContext(ctx,
+		`SELECT `+verificationColumns+` FROM verification_codes WHERE id = $1`, id.Hex()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return code, nil
+}
+
+func (r *postgresVerificationCodeRepository) Update(ctx context.Context, code *model.VerificationCode) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE verification_codes SET attempts = $2, is_used = $3 WHERE id = $1`,
+		code.ID.Hex(), code.Attempts, code.IsUsed)
+	return err
+}
+
+func (r *postgresVerificationCodeRepository) InvalidateOpen(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE verification_codes SET is_used = TRUE WHERE user_id = $1 AND purpose = $2 AND NOT is_used`,
+		userID.Hex(), purpose)
+	return err
+}
+
+func (r *postgresVerificationCodeRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM verification_codes WHERE expires_at < $1`, time.Now())
+	return err
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ProfileViewRepository interface {
+	// AddMany stores views, skipping those already recorded for the viewer and day
+	AddMany(ctx context.Context, views []*model.ProfileView) error
+	// Count returns the views of a profile in total and since a day
+	Count(ctx context.Context, username, sinceDay string) (*model.ProfileViewStats, error)
+}
+
+type profileViewRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewProfileViewRepository(db *mongo.Database, collectionName string, timeout time.Duration) ProfileViewRepository {
+	return &profileViewRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *profileViewRepository) AddMany(ctx context.Context, views []*model.ProfileView) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if len(views) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, len(views))
+	for i, view := range views {
+		prepareProfileView(view)
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"username": view.Username, "viewer": view.Viewer, "day": view.Day}).
+			SetUpdate(bson.M{"$setOnInsert": view}).
+			SetUpsert(true)
+	}
+
+	_, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (r *profileViewRepository) Count(ctx context.Context, username, sinceDay string) (*model.ProfileViewStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	total, err := r.collection.CountDocuments(ctx, bson.M{"username": username})
+	if err != nil {
+		return nil, err
+	}
+	recent, err := r.collection.CountDocuments(ctx, bson.M{"username": username, "day": bson.M{"$gte": sinceDay}})
+	if err != nil {
+		return nil, err
+	}
+	return &model.ProfileViewStats{Total: total, Last7Days: recent}, nil
+}
+
+func (r *profileViewRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}, {Key: "day", Value: 1}, {Key: "viewer", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareProfileView(view *model.ProfileView) {
+	if view.ID.IsZero() {
+		view.ID = primitive.NewObjectID()
+	}
+	if view.ViewedAt.IsZero() {
+		view.ViewedAt = time.Now()
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RoomEventRepository interface {
+	Create(ctx context.Context, e *model.RoomEvent) error
+	// Find returns the newest events matching the filter, newest first
+	Find(ctx context.Context, filter model.RoomEventFilter, limit int) ([]*model.RoomEvent, error)
+	// CountBefore and DeleteBefore count and delete the events that happened before the
+	// time, for the retention policy. Events of held users are kept.
+	CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+	DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+}
+
+type roomEventRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewRoomEventRepository(db *mongo.Database, collectionName string, timeout time.Duration) RoomEventRepository {
+	return &roomEventRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *roomEventRepository) Create(ctx context.Context, e *model.RoomEvent) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if e.ID.IsZero() {
+		e.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, e)
+	return err
+}
+
+func (r *roomEventRepository) Find(ctx context.Context, filter model.RoomEventFilter, limit int) ([]*model.RoomEvent, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.RoomCode != "" {
+		query["room"] = filter.RoomCode
+	}
+	if filter.Username != "" {
+		query["username"] = filter.Username
+	}
+	if !filter.Since.IsZero() {
+		query["at"] = bson.M{"$gte": filter.Since}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}, {Key: "seq", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []*model.RoomEvent
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *roomEventRepository) CountBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, expiredRoomEvents(before, held))
+}
+
+func (r *roomEventRepository) DeleteBefore(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, expiredRoomEvents(before, held))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *roomEventRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "room", Value: 1}, {Key: "seq", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "username", Value: 1}, {Key: "at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "at", Value: -1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+// expiredRoomEvents selects the events that happened before the time to users not under
+// a legal hold
+func expiredRoomEvents(before time.Time, held model.HeldUsers) bson.M {
+	filter := bson.M{"at": bson.M{"$lt": before}}
+	if len(held.Usernames) > 0 {
+		filter["username"] = bson.M{"$nin": held.Usernames}
+	}
+	return filter
+}
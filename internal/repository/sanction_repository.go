@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SanctionRepository interface {
+	Create(ctx context.Context, sanction *model.Sanction) error
+	// GetActive returns the sanction of the type in force for the user at now that runs
+	// the longest, or nil when there is none
+	GetActive(ctx context.Context, username, sanctionType string, now time.Time) (*model.Sanction, error)
+	// ListByUsername returns every sanction of the user, newest first
+	ListByUsername(ctx context.Context, username string) ([]*model.Sanction, error)
+	// Lift ends the sanctions of the type in force for the user at now and returns how
+	// many there were
+	Lift(ctx context.Context, username, sanctionType, liftedBy string, now time.Time) (int64, error)
+}
+
+type sanctionRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewSanctionRepository(db *mongo.Database, collectionName string, timeout time.Duration) SanctionRepository {
+	return &sanctionRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *sanctionRepository) Create(ctx context.Context, sanction *model.Sanction) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareSanction(sanction)
+	_, err := r.collection.InsertOne(ctx, sanction)
+	return err
+}
+
+// activeSanctionFilter matches the sanctions of the type in force for the user at now
+func activeSanctionFilter(username, sanctionType string, now time.Time) bson.M {
+	return bson.M{
+		"username":   username,
+		"type":       sanctionType,
+		"lifted_at":  nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+}
+
+func (r *sanctionRepository) GetActive(ctx context.Context, username, sanctionType string, now time.Time) (*model.Sanction, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "expires_at", Value: -1}})
+	var sanction model.Sanction
+	err := r.collection.FindOne(ctx, activeSanctionFilter(username, sanctionType, now), opts).Decode(&sanction)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sanction, nil
+}
+
+func (r *sanctionRepository) ListByUsername(ctx context.Context, username string) ([]*model.Sanction, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"username": username}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sanctions []*model.Sanction
+	if err = cursor.All(ctx, &sanctions); err != nil {
+		return nil, err
+	}
+	return sanctions, nil
+}
+
+func (r *sanctionRepository) Lift(ctx context.Context, username, sanctionType, liftedBy string, now time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(ctx, activeSanctionFilter(username, sanctionType, now),
+		bson.M{"$set": bson.M{"lifted_by": liftedBy, "lifted_at": now}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *sanctionRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "username", Value: 1}, {Key: "type", Value: 1}, {Key: "expires_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "username", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
+
+func prepareSanction(sanction *model.Sanction) {
+	if sanction.ID.IsZero() {
+		sanction.ID = primitive.NewObjectID()
+	}
+	if sanction.CreatedAt.IsZero() {
+		sanction.CreatedAt = time.Now()
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UsageRepository interface {
+	// Increment adds the counters of delta to the user's usage on delta.Day, creating it if needed
+	Increment(ctx context.Context, delta *model.DailyUsage) error
+	Get(ctx context.Context, username, day string) (*model.DailyUsage, error)
+}
+
+type usageRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewUsageRepository(db *mongo.Database, collectionName string, timeout time.Duration) UsageRepository {
+	return &usageRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *usageRepository) Increment(ctx context.Context, delta *model.DailyUsage) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"username": delta.Username, "day": delta.Day}, bson.M{
+		"$inc": bson.M{
+			"chats_started": delta.ChatsStarted,
+			"chat_seconds":  delta.ChatSeconds,
+		},
+		"$set": bson.M{"updated_at": time.Now()},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *usageRepository) Get(ctx context.Context, username, day string) (*model.DailyUsage, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var usage model.DailyUsage
+	err := r.collection.FindOne(ctx, bson.M{"username": username, "day": day}).Decode(&usage)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (r *usageRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}, {Key: "day", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
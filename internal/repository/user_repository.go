@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
 	"chatmix-backend/internal/model"
@@ -14,32 +15,96 @@ import (
 )
 
 type UserRepository interface {
+	// Create returns a DuplicateError for the field when the username or email is taken
 	Create(ctx context.Context, user *model.User) error
+	// CreateMany inserts the users in one batch. errs holds the error of each user, a
+	// DuplicateError when its username or email is taken; err fails the whole batch.
+	CreateMany(ctx context.Context, users []*model.User) (errs []error, err error)
 	GetByID(ctx context.Context, id primitive.ObjectID) (*model.User, error)
+	// GetByUsername, GetByEmail and Exists ignore case and surrounding whitespace, like the
+	// unique indexes that keep usernames and emails from differing only by case
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
 	UpdateLastSeen(ctx context.Context, username string) error
+	// ReplacePasswordHash sets a new password hash unless the stored one is no longer
+	// current, so a password changed meanwhile is kept
+	ReplacePasswordHash(ctx context.Context, id primitive.ObjectID, current, hash string) error
 	SetOnlineStatus(ctx context.Context, username string, online bool) error
-	GetOnlineUsers(ctx context.Context) ([]*model.User, error)
-	GetAllUsers(ctx context.Context) ([]*model.User, error)
+	// GetOnlineUsers and GetAllUsers return a page of the online users by username and
+	// of all users by join date
+	GetOnlineUsers(ctx context.Context, page PageRequest) (*Page[*model.User], error)
+	GetAllUsers(ctx context.Context, page PageRequest) (*Page[*model.User], error)
 	Delete(ctx context.Context, id primitive.ObjectID) error
 	DeleteByUsername(ctx context.Context, username string) error
 	Exists(ctx context.Context, username string) (bool, error)
 	Count(ctx context.Context) (int64, error)
+	// FindByFilter returns a page of the users matching every set criterion of the
+	// filter, oldest first
+	FindByFilter(ctx context.Context, filter model.UserFilter, page PageRequest) (*Page[*model.User], error)
+	// Search returns a page of the users matching the directory query, in its sort, and
+	// how many match in all
+	Search(ctx context.Context, query model.UserDirectoryQuery, page PageRequest) (*Page[*model.User], int64, error)
+	SetBanned(ctx context.Context, ids []primitive.ObjectID, banned bool, at time.Time) (int64, error)
+	SetVerified(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	DeleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, error)
 }
 
+// caseInsensitive compares strings ignoring case but not accents
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
 type userRepository struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
-func NewUserRepository(db *mongo.Database, collectionName string) UserRepository {
+func NewUserRepository(db *mongo.Database, collectionName string, timeout time.Duration) UserRepository {
 	return &userRepository{
 		collection: db.Collection(collectionName),
+		timeout:    timeout,
 	}
 }
 
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	prepareNewUser(user)
+	_, err := r.collection.InsertOne(ctx, user)
+	return mongoDuplicate(err, "username", "email")
+}
+
+func (r *userRepository) CreateMany(ctx context.Context, users []*model.User) ([]error, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	errs := make([]error, len(users))
+	if len(users) == 0 {
+		return errs, nil
+	}
+
+	docs := make([]interface{}, len(users))
+	for i, user := range users {
+		prepareNewUser(user)
+		docs[i] = user
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	switch {
+	case err == nil:
+		return errs, nil
+	case !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil:
+		return nil, err
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		errs[writeErr.Index] = mongoDuplicate(writeErr, "username", "email")
+	}
+	return errs, nil
+}
+
+// prepareNewUser fills the ID and the timestamps of a user about to be inserted
+func prepareNewUser(user *model.User) {
 	if user.ID.IsZero() {
 		user.ID = primitive.NewObjectID()
 	}
@@ -51,12 +116,12 @@ func (r *userRepository) Create(ctx context.Context, user *model.User) error {
 	if user.LastSeen.IsZero() {
 		user.LastSeen = time.Now()
 	}
-
-	_, err := r.collection.InsertOne(ctx, user)
-	return err
 }
 
 func (r *userRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var user model.User
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
 	if err != nil {
@@ -69,8 +134,12 @@ func (r *userRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*m
 }
 
 func (r *userRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var user model.User
-	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	opts := options.FindOne().SetCollation(caseInsensitive)
+	err := r.collection.FindOne(ctx, bson.M{"username": model.NormalizeUsername(username)}, opts).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, nil
@@ -81,8 +150,12 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*m
 }
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var user model.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	opts := options.FindOne().SetCollation(caseInsensitive)
+	err := r.collection.FindOne(ctx, bson.M{"email": model.NormalizeEmail(email)}, opts).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, nil
@@ -93,6 +166,9 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.U
 }
 
 func (r *userRepository) Update(ctx context.Context, user *model.User) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"_id": user.ID}
 	update := bson.M{"$set": user}
 
@@ -101,6 +177,9 @@ func (r *userRepository) Update(ctx context.Context, user *model.User) error {
 }
 
 func (r *userRepository) UpdateLastSeen(ctx context.Context, username string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"username": username}
 	update := bson.M{"$set": bson.M{"last_seen": time.Now()}}
 
@@ -108,7 +187,19 @@ func (r *userRepository) UpdateLastSeen(ctx context.Context, username string) er
 	return err
 }
 
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id primitive.ObjectID, current, hash string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "password_hash": current},
+		bson.M{"$set": bson.M{"password_hash": hash}})
+	return err
+}
+
 func (r *userRepository) SetOnlineStatus(ctx context.Context, username string, online bool) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	filter := bson.M{"username": username}
 	updateDoc := bson.M{"is_online": online}
 
@@ -122,53 +213,64 @@ func (r *userRepository) SetOnlineStatus(ctx context.Context, username string, o
 	return err
 }
 
-func (r *userRepository) GetOnlineUsers(ctx context.Context) ([]*model.User, error) {
-	filter := bson.M{"is_online": true}
-	opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}})
+func (r *userRepository) GetOnlineUsers(ctx context.Context, page PageRequest) (*Page[*model.User], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	cursor, err := r.collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
+	return findPage(ctx, r.collection, bson.M{"is_online": true}, onlineUsersSort, page, usernameCursor)
+}
 
-	var users []*model.User
-	if err = cursor.All(ctx, &users); err != nil {
-		return nil, err
-	}
+func (r *userRepository) GetAllUsers(ctx context.Context, page PageRequest) (*Page[*model.User], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	return users, nil
+	return findPage(ctx, r.collection, bson.M{}, allUsersSort, page, joinedCursor)
 }
 
-func (r *userRepository) GetAllUsers(ctx context.Context) ([]*model.User, error) {
-	opts := options.Find().SetSort(bson.D{{Key: "joined_at", Value: 1}})
+// Sorts of GetOnlineUsers and GetAllUsers and the cursors after their items
+var (
+	onlineUsersSort = Sort{Field: "username"}
+	allUsersSort    = Sort{Field: "joined_at"}
+)
 
-	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
-	if err != nil {
-		return nil, err
+func usernameCursor(user *model.User) Cursor { return Cursor{Value: user.Username, ID: user.ID} }
+func joinedCursor(user *model.User) Cursor   { return Cursor{Value: user.JoinedAt, ID: user.ID} }
+func lastSeenCursor(user *model.User) Cursor { return Cursor{Value: user.LastSeen, ID: user.ID} }
+
+// directorySort returns the Sort of a user directory sort and the cursor after its items
+func directorySort(sort model.UserSort) (Sort, func(*model.User) Cursor) {
+	field, desc := sort.Field()
+	switch field {
+	case string(model.UserSortUsername):
+		return Sort{Field: field, Desc: desc}, usernameCursor
+	case string(model.UserSortLastSeen):
+		return Sort{Field: field, Desc: desc}, lastSeenCursor
 	}
-	defer cursor.Close(ctx)
-
-	var users []*model.User
-	if err = cursor.All(ctx, &users); err != nil {
-		return nil, err
-	}
-
-	return users, nil
+	return Sort{Field: string(model.UserSortJoined), Desc: desc}, joinedCursor
 }
 
 func (r *userRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
 
 func (r *userRepository) DeleteByUsername(ctx context.Context, username string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	_, err := r.collection.DeleteOne(ctx, bson.M{"username": username})
 	return err
 }
 
 func (r *userRepository) Exists(ctx context.Context, username string) (bool, error) {
-	count, err := r.collection.CountDocuments(ctx, bson.M{"username": username})
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Count().SetCollation(caseInsensitive)
+	count, err := r.collection.CountDocuments(ctx, bson.M{"username": model.NormalizeUsername(username)}, opts)
 	if err != nil {
 		return false, err
 	}
@@ -176,15 +278,137 @@ func (r *userRepository) Exists(ctx context.Context, username string) (bool, err
 }
 
 func (r *userRepository) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	return r.collection.CountDocuments(ctx, bson.M{})
 }
 
+func (r *userRepository) FindByFilter(ctx context.Context, filter model.UserFilter, page PageRequest) (*Page[*model.User], error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	joined := bson.M{}
+	if filter.JoinedAfter != nil {
+		joined["$gte"] = *filter.JoinedAfter
+	}
+	if filter.JoinedBefore != nil {
+		joined["$lt"] = *filter.JoinedBefore
+	}
+	if len(joined) > 0 {
+		query["joined_at"] = joined
+	}
+	if filter.Unverified {
+		query["is_verified"] = false
+	}
+	if filter.InactiveSince != nil {
+		query["last_seen"] = bson.M{"$lt": *filter.InactiveSince}
+	}
+
+	return findPage(ctx, r.collection, query, allUsersSort, page, joinedCursor)
+}
+
+func (r *userRepository) Search(ctx context.Context, query model.UserDirectoryQuery, page PageRequest) (*Page[*model.User], int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if query.Banned != nil {
+		filter["is_banned"] = *query.Banned
+	}
+	if query.Verified != nil {
+		filter["is_verified"] = *query.Verified
+	}
+	joined := bson.M{}
+	if query.RegisteredAfter != nil {
+		joined["$gte"] = *query.RegisteredAfter
+	}
+	if query.RegisteredBefore != nil {
+		joined["$lt"] = *query.RegisteredBefore
+	}
+	if len(joined) > 0 {
+		filter["joined_at"] = joined
+	}
+	if query.LastSeenBefore != nil {
+		filter["last_seen"] = bson.M{"$lt": *query.LastSeenBefore}
+	}
+	if query.EmailDomain != "" {
+		filter["email"] = primitive.Regex{Pattern: "@" + regexp.QuoteMeta(query.EmailDomain) + "$", Options: "i"}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort, cursorOf := directorySort(query.Sort)
+	users, err := findPage(ctx, r.collection, filter, sort, page, cursorOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) SetBanned(ctx context.Context, ids []primitive.ObjectID, banned bool, at time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"is_banned": true, "banned_at": at, "updated_at": at}}
+	if !banned {
+		update = bson.M{"$set": bson.M{"is_banned": false, "updated_at": at}, "$unset": bson.M{"banned_at": ""}}
+	}
+
+	result, err := r.collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "is_banned": bson.M{"$ne": banned}}, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *userRepository) SetVerified(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "is_verified": false},
+		bson.M{"$set": bson.M{"is_verified": true, "updated_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *userRepository) DeleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
 func (r *userRepository) CreateIndexes(ctx context.Context) error {
 	indexes := []mongo.IndexModel{
 		{
 			Keys:    bson.D{{Key: "username", Value: 1}},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Usernames and emails differing only by case are duplicates too. Lookups use the
+		// same collation so they can use these indexes.
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetName("username_ci").SetUnique(true).SetCollation(caseInsensitive),
+		},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_ci").SetUnique(true).SetCollation(caseInsensitive),
+		},
 		{
 			Keys: bson.D{{Key: "is_online", Value: 1}},
 		},
@@ -194,8 +418,17 @@ func (r *userRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "joined_at", Value: 1}},
 		},
+		// Admin directory filters, see Search
+		{
+			Keys: bson.D{{Key: "is_banned", Value: 1}, {Key: "joined_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "is_verified", Value: 1}, {Key: "joined_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "is_banned", Value: 1}, {Key: "last_seen", Value: -1}},
+		},
 	}
 
-	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
-	return err
+	return ensureIndexes(ctx, r.collection, indexes)
 }
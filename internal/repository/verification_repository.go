@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatmix-backend/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type VerificationCodeRepository interface {
+	Create(ctx context.Context, code *model.VerificationCode) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*model.VerificationCode, error)
+	Update(ctx context.Context, code *model.VerificationCode) error
+	// InvalidateOpen marks the user's unused codes of the purpose as used
+	InvalidateOpen(ctx context.Context, userID primitive.ObjectID, purpose string) error
+	DeleteExpired(ctx context.Context) error
+}
+
+type verificationCodeRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func NewVerificationCodeRepository(db *mongo.Database, collectionName string, timeout time.Duration) VerificationCodeRepository {
+	return &verificationCodeRepository{
+		collection: db.Collection(collectionName),
+		timeout:    timeout,
+	}
+}
+
+func (r *verificationCodeRepository) Create(ctx context.Context, code *model.VerificationCode) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if code.ID.IsZero() {
+		code.ID = primitive.NewObjectID()
+	}
+	if code.CreatedAt.IsZero() {
+		code.CreatedAt = time.Now()
+	}
+	_, err := r.collection.InsertOne(ctx, code)
+	return err
+}
+
+func (r *verificationCodeRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*model.VerificationCode, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var code model.VerificationCode
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *verificationCodeRepository) Update(ctx context.Context, code *model.VerificationCode) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": code.ID}
+	update := bson.M{"$set": code}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *verificationCodeRepository) InvalidateOpen(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "purpose": purpose, "is_used": false}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"is_used": true}})
+	return err
+}
+
+func (r *verificationCodeRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lt": time.Now()}})
+	return err
+}
+
+func (r *verificationCodeRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	return ensureIndexes(ctx, r.collection, indexes)
+}
@@ -5,6 +5,7 @@ import (
 
 	"chatmix-backend/internal/config"
 	"chatmix-backend/internal/handler"
+	"chatmix-backend/internal/model"
 	"chatmix-backend/internal/service"
 
 	"github.com/gorilla/mux"
@@ -13,79 +14,243 @@ import (
 
 // Router manages HTTP routes
 type Router struct {
-	mux         *mux.Router
-	config      *config.Config
-	logger      *logrus.Logger
-	httpHandler *handler.HTTPHandler
-	authHandler *handler.UserHandler
-	chatHandler *handler.ChatHandler
+	mux                 *mux.Router
+	config              *config.Provider
+	logger              *logrus.Logger
+	httpHandler         *handler.HTTPHandler
+	authHandler         *handler.UserHandler
+	chatHandler         *handler.ChatHandler
+	adminHandler        *handler.AdminHandler
+	notificationHandler *handler.NotificationHandler
+	apiKeyHandler       *handler.APIKeyHandler
+	botHandler          *handler.BotHandler
+	channelHandler      *handler.ChannelHandler
+	banEnforcer         *handler.BanEnforcer
+	billingHandler      *handler.BillingHandler // nil without a billing webhook secret
+	metricsHandler      *handler.MetricsHandler // nil unless metrics.enabled
+	staticHandler       http.Handler            // nil for API-only deployments
 }
 
 func NewRouter(
-	config *config.Config,
+	config *config.Provider,
 	logger *logrus.Logger,
 	httpHandler *handler.HTTPHandler,
 	authHandler *handler.UserHandler,
 	authService service.AuthService,
 	chatHandler *handler.ChatHandler,
+	adminHandler *handler.AdminHandler,
+	notificationHandler *handler.NotificationHandler,
+	apiKeyHandler *handler.APIKeyHandler,
+	botHandler *handler.BotHandler,
+	channelHandler *handler.ChannelHandler,
+	banEnforcer *handler.BanEnforcer,
+	billingHandler *handler.BillingHandler,
+	metricsHandler *handler.MetricsHandler,
+	staticHandler http.Handler,
 ) *Router {
 
 	return &Router{
-		mux:         mux.NewRouter(),
-		config:      config,
-		logger:      logger,
-		httpHandler: httpHandler,
-		authHandler: authHandler,
-		chatHandler: chatHandler,
+		mux:                 mux.NewRouter(),
+		config:              config,
+		logger:              logger,
+		httpHandler:         httpHandler,
+		authHandler:         authHandler,
+		chatHandler:         chatHandler,
+		adminHandler:        adminHandler,
+		notificationHandler: notificationHandler,
+		apiKeyHandler:       apiKeyHandler,
+		botHandler:          botHandler,
+		channelHandler:      channelHandler,
+		banEnforcer:         banEnforcer,
+		billingHandler:      billingHandler,
+		metricsHandler:      metricsHandler,
+		staticHandler:       staticHandler,
 	}
 }
 
 func (r *Router) SetupRoutes() *mux.Router {
 	r.mux.Use(r.httpHandler.RecoveryMiddleware)
-	r.mux.Use(r.httpHandler.LoggingMiddleware)
-	r.mux.Use(r.httpHandler.CORSMiddleware(r.config.Server.CORS))
+	r.mux.Use(r.httpHandler.ClientIPMiddleware(r.config))
+	r.mux.Use(r.httpHandler.LoggingMiddleware(r.config))
+	r.mux.Use(r.httpHandler.CORSMiddleware(r.config))
+	r.mux.Use(r.httpHandler.BodyLimitMiddleware(r.config))
 	r.mux.Methods("OPTIONS").HandlerFunc(r.handleOptions)
 
 	// API routes
 	api := r.mux.PathPrefix("/api").Subrouter()
+	api.Use(r.httpHandler.ProtocolMiddleware(r.config))
 	r.setupAPIRoutes(api)
 
 	// WebSocket chat route (handles auth internally via token query param)
-	r.mux.HandleFunc("/ws/chat", r.chatHandler.HandleWebSocket).Methods("GET")
+	ws := r.mux.PathPrefix("/ws").Subrouter()
+	ws.Use(r.httpHandler.ProtocolMiddleware(r.config))
+	ws.Handle("/chat", r.enforceBans(r.chatHandler.HandleWebSocket)).Methods("GET")
+	ws.HandleFunc("/admin/rooms/{code}/observe", r.chatHandler.HandleObserveRoom).Methods("GET")
+	ws.Handle("/channels/{slug}", r.enforceBans(r.chatHandler.HandleChannelSocket)).Methods("GET")
 
 	// Health check
 	r.mux.HandleFunc("/health", r.httpHandler.HealthCheck).Methods("GET")
 
+	if r.metricsHandler != nil {
+		r.mux.Handle(r.config.Get().Metrics.Path, r.metricsHandler).Methods("GET")
+	}
+
+	// The web app, when this server serves it; registered last so it only gets paths
+	// no other route matched
+	if r.staticHandler != nil {
+		r.mux.PathPrefix("/").Handler(r.staticHandler).Methods("GET", "HEAD")
+	}
+
 	return r.mux
 }
 
 func (r *Router) setupAPIRoutes(api *mux.Router) {
 	auth := api.PathPrefix("/auth").Subrouter()
-	auth.HandleFunc("/register", r.authHandler.Register).Methods("POST")
-	auth.HandleFunc("/login", r.authHandler.Login).Methods("POST")
+	auth.Handle("/register", r.banEnforcer.Middleware(r.idempotent(r.authHandler.Register))).Methods("POST")
+	auth.Handle("/login", r.enforceBans(r.authHandler.Login)).Methods("POST")
+	auth.Handle("/login/verify", r.enforceBans(r.authHandler.VerifyLogin)).Methods("POST")
 	auth.HandleFunc("/refresh", r.authHandler.RefreshToken).Methods("POST")
 	auth.HandleFunc("/captcha", r.authHandler.GenerateCaptcha).Methods("GET")
 
 	authProtected := api.PathPrefix("/auth").Subrouter()
 	authProtected.Use(r.authHandler.AuthMiddleware)
 	authProtected.HandleFunc("/logout", r.authHandler.Logout).Methods("POST")
+	authProtected.HandleFunc("/logout-others", r.authHandler.LogoutOthers).Methods("POST")
 	authProtected.HandleFunc("/change-password", r.authHandler.ChangePassword).Methods("POST")
 	authProtected.HandleFunc("/profile", r.authHandler.GetProfile).Methods("GET")
 	authProtected.HandleFunc("/profile", r.authHandler.UpdateProfile).Methods("PUT")
 	authProtected.HandleFunc("/revoke-sessions", r.authHandler.RevokeAllSessions).Methods("POST")
+	authProtected.HandleFunc("/activity", r.authHandler.GetActivity).Methods("GET")
+	authProtected.HandleFunc("/stats", r.authHandler.GetChatStats).Methods("GET")
+	authProtected.HandleFunc("/2fa/setup", r.authHandler.SetupTwoFactor).Methods("POST")
+	authProtected.HandleFunc("/2fa/enable", r.authHandler.EnableTwoFactor).Methods("POST")
+	authProtected.HandleFunc("/2fa/disable", r.authHandler.DisableTwoFactor).Methods("POST")
+	authProtected.HandleFunc("/2fa/recovery-codes", r.authHandler.RegenerateRecoveryCodes).Methods("POST")
+	authProtected.HandleFunc("/apikeys", r.apiKeyHandler.ListAPIKeys).Methods("GET")
+	authProtected.HandleFunc("/apikeys", r.apiKeyHandler.CreateAPIKey).Methods("POST")
+	authProtected.HandleFunc("/apikeys/{id}", r.apiKeyHandler.RevokeAPIKey).Methods("DELETE")
 
 	chatProtected := api.PathPrefix("/chat").Subrouter()
 	chatProtected.Use(r.authHandler.AuthMiddleware)
-	chatProtected.HandleFunc("/start", r.chatHandler.HandleStartChat).Methods("POST")
+	chatProtected.Handle("/start", r.idempotent(r.chatHandler.HandleStartChat)).Methods("POST")
 	chatProtected.HandleFunc("/queue-status", r.chatHandler.HandleQueueStatus).Methods("GET")
+	chatProtected.HandleFunc("/current", r.chatHandler.HandleCurrentRoom).Methods("GET")
+	chatProtected.HandleFunc("/availability", r.authHandler.GetAvailability).Methods("GET")
+	chatProtected.HandleFunc("/rooms/{code}/partner", r.chatHandler.HandleRoomPartner).Methods("GET")
+	chatProtected.HandleFunc("/rooms/{code}/messages", r.chatHandler.HandleRoomHistory).Methods("GET")
+	chatProtected.Handle("/rooms/{code}/messages", r.enforceBans(r.chatHandler.HandleSendMessage)).Methods("POST")
+	chatProtected.HandleFunc("/rooms/{code}/messages/mine", r.chatHandler.HandleDeleteMyMessages).Methods("DELETE")
+	chatProtected.HandleFunc("/history", r.chatHandler.HandleDeleteHistory).Methods("DELETE")
+	chatProtected.Handle("/rooms/{code}/poll", r.enforceBans(r.chatHandler.HandlePoll)).Methods("GET")
+	chatProtected.HandleFunc("/channels", r.channelHandler.ListChannels).Methods("GET")
+	chatProtected.HandleFunc("/channels/{slug}/join", r.channelHandler.JoinChannel).Methods("POST")
+	chatProtected.HandleFunc("/channels/{slug}/leave", r.channelHandler.LeaveChannel).Methods("POST")
+	chatProtected.HandleFunc("/channels/{slug}/messages", r.channelHandler.GetChannelMessages).Methods("GET")
+
+	api.HandleFunc("/chat/capacity", r.chatHandler.HandleCapacity).Methods("GET")
+
+	// Signed by the billing provider rather than authenticated
+	if r.billingHandler != nil {
+		api.HandleFunc("/billing/webhook", r.billingHandler.HandleWebhook).Methods("POST")
+	}
+
+	// SSE fallback for chat (handles auth internally, EventSource cannot send headers)
+	api.Handle("/chat/rooms/{code}/events", r.enforceBans(r.chatHandler.HandleRoomEvents)).Methods("GET")
+
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(r.authHandler.AuthMiddleware)
+	admin.Use(r.authHandler.RequireRole(model.RoleModerator, model.RoleAdmin))
+	admin.HandleFunc("/stats", r.adminHandler.GetStats).Methods("GET")
+	admin.HandleFunc("/rooms", r.adminHandler.ListRooms).Methods("GET")
+	admin.HandleFunc("/rooms/{code}", r.adminHandler.GetRoom).Methods("GET")
+	admin.HandleFunc("/room-events", r.adminHandler.ListRoomEvents).Methods("GET")
+	admin.HandleFunc("/delivery", r.chatHandler.HandleDeliveryStats).Methods("GET")
+	admin.HandleFunc("/matchmaking/stats", r.adminHandler.GetMatchmakingStats).Methods("GET")
+	admin.HandleFunc("/messages/{id}/revisions", r.adminHandler.GetMessageRevisions).Methods("GET")
+	admin.HandleFunc("/users/{username}/timeout", r.adminHandler.TimeoutUser).Methods("POST")
+	admin.HandleFunc("/users/{username}/timeout", r.adminHandler.LiftTimeout).Methods("DELETE")
+	admin.HandleFunc("/users/{username}/sanctions", r.adminHandler.ListSanctions).Methods("GET")
+	admin.HandleFunc("/blocklist", r.adminHandler.ListBlocklist).Methods("GET")
+	admin.HandleFunc("/blocklist", r.adminHandler.CreateBlocklistEntry).Methods("POST")
+	admin.HandleFunc("/blocklist/{id}", r.adminHandler.UpdateBlocklistEntry).Methods("PUT")
+	admin.HandleFunc("/blocklist/{id}", r.adminHandler.DeleteBlocklistEntry).Methods("DELETE")
+	admin.HandleFunc("/channels", r.adminHandler.CreateChannel).Methods("POST")
+	admin.HandleFunc("/channels/{slug}", r.adminHandler.UpdateChannel).Methods("PUT")
+	admin.HandleFunc("/channels/{slug}", r.adminHandler.DeleteChannel).Methods("DELETE")
+
+	adminOnly := admin.NewRoute().Subrouter()
+	adminOnly.Use(r.authHandler.RequireRole(model.RoleAdmin))
+	adminOnly.HandleFunc("/announcements", r.adminHandler.CreateAnnouncement).Methods("POST")
+	adminOnly.HandleFunc("/users", r.adminHandler.ListUsers).Methods("GET")
+	adminOnly.HandleFunc("/users/{username}/ban", r.adminHandler.BanUser).Methods("POST")
+	adminOnly.HandleFunc("/users/{username}/ban", r.adminHandler.UnbanUser).Methods("DELETE")
+	adminOnly.HandleFunc("/users/{username}/kick", r.adminHandler.KickUser).Methods("POST")
+	adminOnly.HandleFunc("/users/{username}/sessions", r.adminHandler.RevokeUserSessions).Methods("DELETE")
+	adminOnly.HandleFunc("/users/{username}/subscription", r.adminHandler.GrantSubscription).Methods("PUT")
+	adminOnly.HandleFunc("/users/{username}/subscription", r.adminHandler.RevokeSubscription).Methods("DELETE")
+	adminOnly.HandleFunc("/tokens/expired", r.adminHandler.PurgeExpiredTokens).Methods("DELETE")
+	adminOnly.HandleFunc("/retention", r.adminHandler.GetRetentionReport).Methods("GET")
+	adminOnly.HandleFunc("/network-bans", r.adminHandler.ListNetworkBans).Methods("GET")
+	adminOnly.HandleFunc("/network-bans", r.adminHandler.CreateNetworkBan).Methods("POST")
+	adminOnly.HandleFunc("/network-bans/{id}", r.adminHandler.UpdateNetworkBan).Methods("PUT")
+	adminOnly.HandleFunc("/network-bans/{id}", r.adminHandler.DeleteNetworkBan).Methods("DELETE")
+	adminOnly.HandleFunc("/disposable-domains", r.adminHandler.GetDisposableDomains).Methods("GET")
+	adminOnly.HandleFunc("/disposable-domains", r.adminHandler.BlockDisposableDomain).Methods("POST")
+	adminOnly.HandleFunc("/disposable-domains/{domain}", r.adminHandler.UnblockDisposableDomain).Methods("DELETE")
+	adminOnly.HandleFunc("/passwords/benchmark", r.adminHandler.BenchmarkPasswords).Methods("GET")
+	adminOnly.HandleFunc("/legal-holds", r.adminHandler.ListLegalHolds).Methods("GET")
+	adminOnly.HandleFunc("/users/{username}/legal-hold", r.adminHandler.PlaceLegalHold).Methods("POST")
+	adminOnly.HandleFunc("/users/{username}/legal-hold", r.adminHandler.LiftLegalHold).Methods("DELETE")
+	adminOnly.HandleFunc("/experiments", r.adminHandler.ListExperiments).Methods("GET")
+	adminOnly.HandleFunc("/experiments", r.adminHandler.CreateExperiment).Methods("POST")
+	adminOnly.HandleFunc("/experiments/{key}/stop", r.adminHandler.StopExperiment).Methods("POST")
+	adminOnly.HandleFunc("/audit", r.adminHandler.ListAuditLogs).Methods("GET")
+	adminOnly.HandleFunc("/users/bulk", r.adminHandler.StartBulkUserJob).Methods("POST")
+	adminOnly.HandleFunc("/users/bulk", r.adminHandler.ListBulkUserJobs).Methods("GET")
+	adminOnly.HandleFunc("/users/bulk/{id}", r.adminHandler.GetBulkUserJob).Methods("GET")
+	adminOnly.HandleFunc("/users/import", r.adminHandler.ImportUsers).Methods("POST")
+	adminOnly.HandleFunc("/users/import/{id}", r.adminHandler.GetUserImportJob).Methods("GET")
+	adminOnly.HandleFunc("/icebreakers", r.adminHandler.ListIcebreakers).Methods("GET")
+	adminOnly.HandleFunc("/icebreakers", r.adminHandler.CreateIcebreaker).Methods("POST")
+	adminOnly.HandleFunc("/icebreakers/{id}", r.adminHandler.UpdateIcebreaker).Methods("PUT")
+	adminOnly.HandleFunc("/icebreakers/{id}", r.adminHandler.DeleteIcebreaker).Methods("DELETE")
+
+	// Bot API, authenticated by X-API-Key with a scope per route
+	bot := api.PathPrefix("/bot").Subrouter()
+	bot.Handle("/users/online", r.requireAPIKey(model.ScopeUsersRead, r.botHandler.GetOnlineUsers)).Methods("GET")
+	bot.Handle("/messages", r.requireAPIKey(model.ScopeBotRead, r.botHandler.GetMessages)).Methods("GET")
+	bot.Handle("/messages", r.requireAPIKey(model.ScopeBotPost, r.botHandler.PostMessage)).Methods("POST")
+
+	notifications := api.PathPrefix("/notifications").Subrouter()
+	notifications.Use(r.authHandler.AuthMiddleware)
+	notifications.HandleFunc("", r.notificationHandler.ListNotifications).Methods("GET")
+	notifications.HandleFunc("/unread-count", r.notificationHandler.UnreadCount).Methods("GET")
+	notifications.HandleFunc("/read-all", r.notificationHandler.MarkAllRead).Methods("POST")
+	notifications.HandleFunc("/{id}/read", r.notificationHandler.MarkRead).Methods("POST")
 
 	api.HandleFunc("/users", r.authHandler.GetUsers).Methods("GET")
 	api.HandleFunc("/users/online", r.authHandler.GetOnlineUsers).Methods("GET")
-	api.HandleFunc("/users/{username}", r.authHandler.GetUser).Methods("GET")
+	api.Handle("/users/{username}", r.authHandler.OptionalAuthMiddleware(http.HandlerFunc(r.authHandler.GetUser))).Methods("GET")
+	api.HandleFunc("/users/{username}/badges", r.authHandler.GetUserBadges).Methods("GET")
 
 	api.HandleFunc("/health", r.httpHandler.HealthCheck).Methods("GET")
 }
 
+// requireAPIKey wraps a bot route with API key authentication for the scope
+func (r *Router) requireAPIKey(scope string, handlerFunc http.HandlerFunc) http.Handler {
+	return r.apiKeyHandler.APIKeyMiddleware(scope)(handlerFunc)
+}
+
+// enforceBans refuses a route to banned IP ranges and devices, see handler.BanEnforcer
+func (r *Router) enforceBans(handlerFunc http.HandlerFunc) http.Handler {
+	return r.banEnforcer.Middleware(handlerFunc)
+}
+
+// idempotent lets clients retry a POST route safely with an Idempotency-Key header
+func (r *Router) idempotent(handlerFunc http.HandlerFunc) http.Handler {
+	return r.httpHandler.IdempotencyMiddleware(handlerFunc)
+}
+
 func (r *Router) ListRoutes() []string {
 	var routes []string
 
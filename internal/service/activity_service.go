@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// activitySessionLimit caps the logins included in a timeline
+	activitySessionLimit = 50
+	// activityAuditLimit caps the audit entries scanned for a timeline
+	activityAuditLimit = 1000
+	// MaxActivityDays is the longest period a timeline can cover
+	MaxActivityDays = 90
+)
+
+type ActivityService interface {
+	Timeline(ctx context.Context, user *model.User, days int) (*model.ActivityTimeline, error)
+}
+
+type activityService struct {
+	sessionRepo repository.SessionRepository
+	audit       AuditService
+	logger      *logrus.Logger
+	clock       Clock
+}
+
+func NewActivityService(
+	sessionRepo repository.SessionRepository,
+	audit AuditService,
+	logger *logrus.Logger,
+	opts ...Option,
+) ActivityService {
+	deps := newServiceDeps(opts)
+	return &activityService{
+		sessionRepo: sessionRepo,
+		audit:       audit,
+		logger:      logger,
+		clock:       deps.clock,
+	}
+}
+
+// Timeline assembles the user's logins and account events of the last days, newest first,
+// along with the number of chats started per day
+func (s *activityService) Timeline(ctx context.Context, user *model.User, days int) (*model.ActivityTimeline, error) {
+	if days <= 0 || days > MaxActivityDays {
+		days = MaxActivityDays
+	}
+	since := s.clock.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days+1)
+
+	sessions, err := s.sessionRepo.GetRecentByUserID(ctx, user.ID, activitySessionLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	entries, err := s.audit.Find(ctx, model.AuditFilter{ActorID: user.ID, Since: since}, activityAuditLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &model.ActivityTimeline{
+		Since:       since,
+		Events:      []model.ActivityEvent{},
+		ChatsPerDay: []model.DailyChatCount{},
+	}
+
+	for _, session := range sessions {
+		if session.CreatedAt.Before(since) {
+			continue
+		}
+		timeline.Events = append(timeline.Events, model.ActivityEvent{
+			Type:      model.ActivityTypeLogin,
+			IPAddress: session.IPAddress,
+			UserAgent: session.UserAgent,
+			Country:   session.Country,
+			City:      session.City,
+			Active:    session.IsActive,
+			At:        session.CreatedAt,
+		})
+	}
+
+	chats := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Action == model.AuditActionChatStart {
+			chats[entry.CreatedAt.UTC().Format(time.DateOnly)]++
+			continue
+		}
+		timeline.Events = append(timeline.Events, model.ActivityEvent{
+			Type:      model.ActivityTypeAccount,
+			Action:    entry.Action,
+			IPAddress: entry.IPAddress,
+			At:        entry.CreatedAt,
+		})
+	}
+
+	sort.Slice(timeline.Events, func(i, j int) bool {
+		return timeline.Events[i].At.After(timeline.Events[j].At)
+	})
+
+	for day := since; !day.After(s.clock.Now().UTC()); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		timeline.ChatsPerDay = append(timeline.ChatsPerDay, model.DailyChatCount{Date: date, Chats: chats[date]})
+	}
+
+	return timeline, nil
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// apiKeyPrefix marks ChatMix API keys so they are recognizable in configs and secret scanners
+	apiKeyPrefix = "cmx_"
+	// apiKeyDisplayLength is how much of the key is kept to tell keys apart
+	apiKeyDisplayLength = 12
+	// rateLimitWindow is the window API key rate limits are counted over
+	rateLimitWindow = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound  = errors.New("API key not found")
+	ErrAPIKeyInvalid   = errors.New("invalid API key")
+	ErrAPIKeyLimit     = errors.New("API key limit reached")
+	ErrAPIKeyRateLimit = errors.New("rate limit exceeded")
+)
+
+type APIKeyService interface {
+	// Create issues a key for the user and returns it with the plaintext key, which is not stored
+	Create(ctx context.Context, user *model.User, req model.APIKeyCreateRequest) (*model.APIKeyCreateResponse, error)
+	List(ctx context.Context, userID primitive.ObjectID) ([]*model.APIKey, error)
+	Revoke(ctx context.Context, userID primitive.ObjectID, id string) error
+	// Authenticate resolves a plaintext key to the key and its owner. Revoked keys and
+	// keys of banned users are rejected with ErrAPIKeyInvalid.
+	Authenticate(ctx context.Context, rawKey string) (*model.APIKey, *model.User, error)
+	// Allow counts a request against the key's rate limit. It returns the requests left in
+	// the current window, or ErrAPIKeyRateLimit with the time until the window resets.
+	Allow(key *model.APIKey) (int, time.Duration, error)
+}
+
+// rateWindow counts the requests of one key in the current window
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	userRepo   repository.UserRepository
+	config     *config.Config
+	logger     *logrus.Logger
+	clock      Clock
+	codes      CodeGenerator
+
+	windows     map[primitive.ObjectID]*rateWindow
+	windowsLock sync.Mutex
+	lastPrune   time.Time
+}
+
+func NewAPIKeyService(
+	apiKeyRepo repository.APIKeyRepository,
+	userRepo repository.UserRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) APIKeyService {
+	deps := newServiceDeps(opts)
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+		config:     config,
+		logger:     logger,
+		clock:      deps.clock,
+		codes:      deps.codes,
+		windows:    make(map[primitive.ObjectID]*rateWindow),
+	}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, user *model.User, req model.APIKeyCreateRequest) (*model.APIKeyCreateResponse, error) {
+	cfg := s.config.Auth.APIKeys
+
+	count, err := s.apiKeyRepo.CountActiveByUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count API keys: %w", err)
+	}
+	if count >= int64(cfg.MaxPerUser) {
+		return nil, fmt.Errorf("%w (max %d)", ErrAPIKeyLimit, cfg.MaxPerUser)
+	}
+
+	rateLimit := req.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = cfg.DefaultRateLimit
+	}
+	rateLimit = min(rateLimit, cfg.MaxRateLimit)
+
+	token, err := s.codes.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	rawKey := apiKeyPrefix + token
+
+	key := &model.APIKey{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    rawKey[:apiKeyDisplayLength],
+		KeyHash:   hashAPIKey(rawKey),
+		Scopes:    uniqueScopes(req.Scopes),
+		RateLimit: rateLimit,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{"user": user.Username, "key": key.Prefix}).Info("API key created")
+	return &model.APIKeyCreateResponse{APIKey: key, Key: rawKey}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userID primitive.ObjectID) ([]*model.APIKey, error) {
+	keys, err := s.apiKeyRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userID primitive.ObjectID, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrAPIKeyNotFound
+	}
+
+	revoked, err := s.apiKeyRepo.Revoke(ctx, objectID, userID, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	s.windowsLock.Lock()
+	delete(s.windows, objectID)
+	s.windowsLock.Unlock()
+	return nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*model.APIKey, *model.User, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if key == nil || !key.IsActive() {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	user, err := s.userRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get API key owner: %w", err)
+	}
+	if user == nil || user.IsBanned {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	now := s.clock.Now()
+	if err := s.apiKeyRepo.TouchLastUsed(ctx, key.ID, now); err != nil {
+		s.logger.WithError(err).WithField("key", key.Prefix).Warn("Failed to record API key use")
+	}
+	key.LastUsedAt = &now
+
+	return key, user, nil
+}
+
+func (s *apiKeyService) Allow(key *model.APIKey) (int, time.Duration, error) {
+	now := s.clock.Now()
+
+	s.windowsLock.Lock()
+	defer s.windowsLock.Unlock()
+
+	s.pruneWindows(now)
+
+	window := s.windows[key.ID]
+	if window == nil || now.Sub(window.start) >= rateLimitWindow {
+		window = &rateWindow{start: now}
+		s.windows[key.ID] = window
+	}
+
+	if window.count >= key.RateLimit {
+		return 0, window.start.Add(rateLimitWindow).Sub(now), ErrAPIKeyRateLimit
+	}
+	window.count++
+	return key.RateLimit - window.count, 0, nil
+}
+
+// pruneWindows drops expired windows once per window length. Must be called with windowsLock held.
+func (s *apiKeyService) pruneWindows(now time.Time) {
+	if now.Sub(s.lastPrune) < rateLimitWindow {
+		return
+	}
+	s.lastPrune = now
+
+	for id, window := range s.windows {
+		if now.Sub(window.start) >= rateLimitWindow {
+			delete(s.windows, id)
+		}
+	}
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func uniqueScopes(scopes []string) []string {
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !containsCode(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+type AuditService interface {
+	Record(ctx context.Context, entry *model.AuditLog)
+	Find(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error)
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+	logger    *logrus.Logger
+}
+
+func NewAuditService(auditRepo repository.AuditRepository, logger *logrus.Logger) AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+// Record stores an audit entry. Failures are logged but never block the audited action.
+func (s *auditService) Record(ctx context.Context, entry *model.AuditLog) {
+	fields := logrus.Fields{
+		"actor":  entry.Actor,
+		"action": entry.Action,
+		"target": entry.Target,
+		"ip":     entry.IPAddress,
+	}
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		s.logger.WithError(err).WithFields(fields).Error("Failed to write audit log")
+		return
+	}
+
+	s.logger.WithFields(fields).Info("Audit event recorded")
+}
+
+func (s *auditService) Find(ctx context.Context, filter model.AuditFilter, limit int) ([]*model.AuditLog, error) {
+	entries, err := s.auditRepo.Find(ctx, filter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find audit logs: %w", err)
+	}
+	return entries, nil
+}
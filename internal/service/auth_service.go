@@ -3,32 +3,64 @@ package service
 import (
 	"context"
 	"crypto/rand"
-	"encoding/base64"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
 	"chatmix-backend/internal/model"
 	"chatmix-backend/internal/repository"
+	"chatmix-backend/pkg/captcha"
+	"chatmix-backend/pkg/geoip"
+	"chatmix-backend/pkg/mailer"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrAccountBanned           = errors.New("account is banned")
+	ErrUsernameTaken           = errors.New("username already exists")
+	ErrEmailTaken              = errors.New("email already exists")
+	ErrVerificationRequired    = errors.New("login verification required")
+	ErrInvalidVerificationCode = errors.New("invalid verification code")
+	ErrVerificationExpired     = errors.New("verification code expired")
+	ErrSecondFactorLocked      = errors.New("too many failed verification attempts")
+)
+
+func describeLocation(loc *geoip.Location) string {
+	if loc.City != "" {
+		return loc.City + ", " + loc.Country
+	}
+	return loc.Country
+}
+
 type AuthService interface {
 	Register(ctx context.Context, req *model.RegisterRequest, ipAddress string) (*model.AuthResponse, error)
 	Login(ctx context.Context, req *model.LoginRequest, ipAddress, userAgent string) (*model.AuthResponse, error)
+	VerifyLogin(ctx context.Context, req *model.LoginVerificationRequest, ipAddress, userAgent string) (*model.AuthResponse, error)
 	RefreshToken(ctx context.Context, req *model.RefreshTokenRequest) (*model.AuthResponse, error)
 	Logout(ctx context.Context, userID string, token string) error
 	ValidateToken(tokenString string) (*jwt.Token, error)
 	GetUserFromToken(tokenString string) (*model.User, error)
+	TouchSession(ctx context.Context, token string) error
 	ChangePassword(ctx context.Context, userID string, req *model.PasswordChangeRequest) error
-	GenerateCaptcha(ctx context.Context, ipAddress string) (string, string, error)
-	ValidateCaptcha(ctx context.Context, challenge, answer string) error
+	GenerateCaptcha(ctx context.Context, ipAddress string) (*model.CaptchaInfo, error)
+	ValidateCaptcha(ctx context.Context, solution model.CaptchaSolution, ipAddress string) error
 	RevokeAllSessions(ctx context.Context, userID string) error
+	// LogoutOthers revokes every session and refresh token of the user except the session of the token
+	LogoutOthers(ctx context.Context, userID, token string) error
+	SetupTwoFactor(ctx context.Context, userID string) (*model.TwoFactorSetupResponse, error)
+	EnableTwoFactor(ctx context.Context, userID, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error)
 }
 
 type authService struct {
@@ -36,9 +68,25 @@ type authService struct {
 	refreshTokenRepo repository.RefreshTokenRepository
 	sessionRepo      repository.SessionRepository
 	captchaRepo      repository.CaptchaRepository
+	captchaVerifier  captcha.Verifier
+	disposable       DisposableEmailService
+	verificationRepo repository.VerificationCodeRepository
 	config           *config.Config
 	logger           *logrus.Logger
-	jwtSecret        []byte
+	keyring          *jwtKeyring
+	locator          geoip.Locator
+	notifications    NotificationService
+	mailer           mailer.Mailer
+	templates        *mailer.Templates
+	events           *event.Bus
+	clock            Clock
+	codes            CodeGenerator
+	passwords        PasswordService
+	throttle         *loginThrottle
+	secondFactor     *secondFactorLimiter
+
+	touches   map[string]sessionTouch // by access token, see TouchSession
+	touchLock sync.Mutex
 }
 
 func NewAuthService(
@@ -46,54 +94,67 @@ func NewAuthService(
 	refreshTokenRepo repository.RefreshTokenRepository,
 	sessionRepo repository.SessionRepository,
 	captchaRepo repository.CaptchaRepository,
+	captchaVerifier captcha.Verifier,
+	disposable DisposableEmailService,
+	passwords PasswordService,
+	verificationRepo repository.VerificationCodeRepository,
+	locator geoip.Locator,
+	notifications NotificationService,
+	mailer mailer.Mailer,
+	templates *mailer.Templates,
+	events *event.Bus,
 	config *config.Config,
 	logger *logrus.Logger,
-) AuthService {
+	opts ...Option,
+) (AuthService, error) {
+	keyring, err := newJWTKeyring(config.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := newServiceDeps(opts)
+
 	return &authService{
 		userRepo:         userRepo,
 		refreshTokenRepo: refreshTokenRepo,
 		sessionRepo:      sessionRepo,
 		captchaRepo:      captchaRepo,
+		captchaVerifier:  captchaVerifier,
+		disposable:       disposable,
+		verificationRepo: verificationRepo,
 		config:           config,
 		logger:           logger,
-		jwtSecret:        []byte(config.Auth.JWTSecret),
-	}
+		keyring:          keyring,
+		locator:          locator,
+		notifications:    notifications,
+		mailer:           mailer,
+		templates:        templates,
+		events:           events,
+		clock:            deps.clock,
+		codes:            deps.codes,
+		passwords:        passwords,
+		throttle:         newLoginThrottle(config.Auth.LoginThrottle, deps.clock, deps.sleeper),
+		secondFactor:     newSecondFactorLimiter(config.Auth.TwoFactor, deps.clock),
+		touches:          make(map[string]sessionTouch),
+	}, nil
 }
 
 func (s *authService) Register(ctx context.Context, req *model.RegisterRequest, ipAddress string) (*model.AuthResponse, error) {
 	response := &model.AuthResponse{}
 
-	if err := s.ValidateCaptcha(ctx, req.Captcha, req.CaptchaAnswer); err != nil {
+	if err := s.ValidateCaptcha(ctx, req.CaptchaSolution, ipAddress); err != nil {
 		response.Code = 1
 		response.Message = "Invalid captcha"
 		return response, err
 	}
 
-	existingUser, err := s.userRepo.GetByUsername(ctx, req.Username)
-	if err != nil {
-		response.Code = 2
-		response.Message = "Failed to check user existence"
-		return response, err
-	}
-	if existingUser != nil {
-		response.Code = 3
-		response.Message = "Username already exists"
-		return response, err
-	}
-
-	existingEmail, err := s.userRepo.GetByEmail(ctx, req.Email)
-	if err != nil {
-		response.Code = 4
-		response.Message = "Failed to check email existence"
-		return response, err
-	}
-	if existingEmail != nil {
-		response.Code = 5
-		response.Message = "Email already exists"
+	if err := s.disposable.Check(req.Email, DisposableFlowRegistration); err != nil {
+		response.Code = 9
+		response.Message = "Disposable email addresses are not allowed"
 		return response, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(req.Password)
 	if err != nil {
 		response.Code = 6
 		response.Message = "Failed to hash password"
@@ -101,14 +162,26 @@ func (s *authService) Register(ctx context.Context, req *model.RegisterRequest,
 	}
 
 	user := model.NewUserWithProfile(req.Username, req.Email, req.Age, req.Gender, req.Bio)
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
 
 	if !user.IsValid(s.config.Features.MaxUsernameLength) {
 		response.Code = 7
 		response.Message = "Invalid user data"
 		return response, err
 	}
+	// The unique indexes decide between concurrent registrations of the same username or email
 	if err := s.userRepo.Create(ctx, user); err != nil {
+		var duplicate *repository.DuplicateError
+		if errors.As(err, &duplicate) {
+			if duplicate.Field == "email" {
+				response.Code = 5
+				response.Message = "Email already exists"
+				return response, ErrEmailTaken
+			}
+			response.Code = 3
+			response.Message = "Username already exists"
+			return response, ErrUsernameTaken
+		}
 		response.Code = 8
 		response.Message = "Failed to create user"
 		return response, err
@@ -125,12 +198,19 @@ func (s *authService) Register(ctx context.Context, req *model.RegisterRequest,
 
 func (s *authService) Login(ctx context.Context, req *model.LoginRequest, ipAddress, userAgent string) (*model.AuthResponse, error) {
 	response := &model.AuthResponse{}
-	if err := s.ValidateCaptcha(ctx, req.Captcha, req.CaptchaAnswer); err != nil {
+	if err := s.ValidateCaptcha(ctx, req.CaptchaSolution, ipAddress); err != nil {
 		response.Code = 1
 		response.Message = "Invalid captcha"
 		return response, err
 	}
 
+	if err := s.throttle.Wait(ctx, req.Username, ipAddress); err != nil {
+		response.Code = 2
+		response.Message = "Login canceled"
+		return response, err
+	}
+
+	// Both lookups ignore case and surrounding whitespace, see UserRepository
 	user, err := s.userRepo.GetByUsername(ctx, req.Username)
 	if err != nil {
 		response.Code = 2
@@ -148,23 +228,275 @@ func (s *authService) Login(ctx context.Context, req *model.LoginRequest, ipAddr
 	}
 
 	if user == nil {
+		s.throttle.Fail(req.Username, ipAddress)
 		response.Code = 4
 		response.Message = "Invalid credentials"
 		return response, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	if err := s.passwords.Verify(user.PasswordHash, req.Password); err != nil {
+		s.throttle.Fail(req.Username, ipAddress)
 		response.Code = 5
 		response.Message = "Invalid credentials"
 		return response, err
 	}
+	s.rehashPassword(ctx, user, req.Password)
+
+	if user.IsBanned {
+		response.Code = 6
+		response.Message = "Account is banned"
+		return response, ErrAccountBanned
+	}
+
+	// The account's failures are only cleared once no further factor is needed, see
+	// VerifyLogin
+	if user.TwoFactorEnabled {
+		if s.secondFactor.Locked(user.ID.Hex()) {
+			response.Code = 9
+			response.Message = "Too many failed verification attempts, try again later"
+			return response, ErrSecondFactorLocked
+		}
+		challenge, err := s.startTwoFactorChallenge(ctx, user, ipAddress, userAgent)
+		if err != nil {
+			response.Code = 7
+			response.Message = "Failed to start login verification"
+			return response, err
+		}
+
+		response.Code = 8
+		response.Message = "Two-factor authentication required"
+		response.Challenge = challenge
+		return response, ErrVerificationRequired
+	}
+
+	location := s.locate(ipAddress)
+	risk := s.evaluateLoginRisk(ctx, user, ipAddress, userAgent, location)
+
+	if s.config.Auth.StepUp.Enabled && risk.requiresVerification() {
+		if s.secondFactor.Locked(user.ID.Hex()) {
+			response.Code = 9
+			response.Message = "Too many failed verification attempts, try again later"
+			return response, ErrSecondFactorLocked
+		}
+		challenge, err := s.startLoginVerification(ctx, user, ipAddress, userAgent, risk)
+		if err != nil {
+			response.Code = 7
+			response.Message = "Failed to start login verification"
+			return response, err
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"user_id": user.ID.Hex(),
+			"reasons": risk.reasons(),
+		}).Info("Suspicious login requires verification")
+
+		response.Code = 8
+		response.Message = "Additional verification required"
+		response.Challenge = challenge
+		return response, ErrVerificationRequired
+	}
 
+	s.throttle.Succeed(req.Username)
 	s.logger.WithFields(logrus.Fields{
 		"user_id":  user.ID.Hex(),
 		"username": user.Username,
 	}).Info("User logged in successfully")
 
-	return s.generateTokensAndSession(ctx, user, ipAddress, userAgent)
+	authResponse, err := s.generateTokensAndSession(ctx, user, ipAddress, userAgent)
+	if err != nil || !risk.NewCountry {
+		return authResponse, err
+	}
+
+	s.warnUnusualLocation(ctx, user, ipAddress, location, authResponse)
+	return authResponse, nil
+}
+
+// warnUnusualLocation adds a warning to the login response and notifies the user
+func (s *authService) warnUnusualLocation(ctx context.Context, user *model.User, ipAddress string, location *geoip.Location, authResponse *model.AuthResponse) {
+	authResponse.Warnings = append(authResponse.Warnings,
+		fmt.Sprintf("New login from an unusual location: %s", describeLocation(location)))
+
+	if s.notifications == nil {
+		return
+	}
+
+	notification := model.NewNotification(user, model.NotificationAccountWarning, "New login from an unusual location",
+		fmt.Sprintf("Your account was signed in from %s (IP %s). If this was not you, change your password.",
+			describeLocation(location), ipAddress))
+	if err := s.notifications.Notify(ctx, notification); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID.Hex()).Error("Failed to send login location warning")
+	}
+}
+
+// emailLocale is the locale of emails to the user: their first language, or the default
+// locale when they have none
+func emailLocale(user *model.User) string {
+	if len(user.Languages) > 0 {
+		return user.Languages[0]
+	}
+	return ""
+}
+
+// startLoginVerification emails a one-time code to the user and returns the challenge to
+// answer. Earlier open challenges are invalidated.
+func (s *authService) startLoginVerification(ctx context.Context, user *model.User, ipAddress, userAgent string, risk loginRisk) (*model.LoginChallenge, error) {
+	if err := s.verificationRepo.InvalidateOpen(ctx, user.ID, model.VerificationPurposeLogin); err != nil {
+		return nil, fmt.Errorf("failed to invalidate verification codes: %w", err)
+	}
+
+	code := s.codes.Digits(6)
+	verification := model.NewVerificationCode(user.ID, model.VerificationPurposeLogin, "", ipAddress, userAgent,
+		s.clock.Now(), s.config.Auth.StepUp.CodeTTL)
+	verification.CodeHash = hashVerificationCode(verification.ID.Hex(), code)
+
+	if err := s.verificationRepo.Create(ctx, verification); err != nil {
+		return nil, fmt.Errorf("failed to save verification code: %w", err)
+	}
+
+	msg, err := s.templates.Render(mailer.TemplateLoginCode, emailLocale(user), map[string]interface{}{
+		"IPAddress":        ipAddress,
+		"Code":             code,
+		"ExpiresInMinutes": int(s.config.Auth.StepUp.CodeTTL.Minutes()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg.To = user.Email
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return &model.LoginChallenge{
+		ID:        verification.ID.Hex(),
+		Method:    model.ChallengeMethodEmail,
+		Reasons:   risk.reasons(),
+		ExpiresAt: verification.ExpiresAt,
+	}, nil
+}
+
+// VerifyLogin completes a login that required step-up verification
+func (s *authService) VerifyLogin(ctx context.Context, req *model.LoginVerificationRequest, ipAddress, userAgent string) (*model.AuthResponse, error) {
+	response := &model.AuthResponse{}
+
+	id, err := primitive.ObjectIDFromHex(req.ChallengeID)
+	if err != nil {
+		response.Code = 1
+		response.Message = "Invalid verification code"
+		return response, ErrInvalidVerificationCode
+	}
+
+	verification, err := s.verificationRepo.GetByID(ctx, id)
+	if err != nil {
+		response.Code = 2
+		response.Message = "Failed to get verification"
+		return response, err
+	}
+	if verification == nil || (verification.Purpose != model.VerificationPurposeLogin &&
+		verification.Purpose != model.VerificationPurposeLoginTOTP) {
+		response.Code = 1
+		response.Message = "Invalid verification code"
+		return response, ErrInvalidVerificationCode
+	}
+
+	if !verification.IsValidAt(s.clock.Now(), s.config.Auth.StepUp.MaxAttempts) {
+		response.Code = 3
+		response.Message = "Verification code expired"
+		return response, ErrVerificationExpired
+	}
+
+	user, err := s.userRepo.GetByID(ctx, verification.UserID)
+	if err != nil || user == nil {
+		response.Code = 5
+		response.Message = "Failed to get user"
+		return response, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if s.secondFactor.Locked(user.ID.Hex()) {
+		response.Code = 7
+		response.Message = "Too many failed verification attempts, try again later"
+		return response, ErrSecondFactorLocked
+	}
+
+	var valid, usedRecoveryCode bool
+	switch verification.Purpose {
+	case model.VerificationPurposeLoginTOTP:
+		valid, usedRecoveryCode = s.checkSecondFactor(user, req.Code, true)
+	default:
+		valid = subtle.ConstantTimeCompare([]byte(verification.CodeHash),
+			[]byte(hashVerificationCode(req.ChallengeID, req.Code))) == 1
+	}
+
+	if !valid {
+		s.secondFactor.Fail(user.ID.Hex())
+		verification.Attempts++
+		if err := s.verificationRepo.Update(ctx, verification); err != nil {
+			s.logger.WithError(err).Error("Failed to record verification attempt")
+		}
+		response.Code = 1
+		response.Message = "Invalid verification code"
+		return response, ErrInvalidVerificationCode
+	}
+
+	verification.IsUsed = true
+	if err := s.verificationRepo.Update(ctx, verification); err != nil {
+		response.Code = 4
+		response.Message = "Failed to complete verification"
+		return response, err
+	}
+
+	if usedRecoveryCode {
+		user.UpdatedAt = s.clock.Now()
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			response.Code = 4
+			response.Message = "Failed to complete verification"
+			return response, fmt.Errorf("failed to consume recovery code: %w", err)
+		}
+	}
+
+	if user.IsBanned {
+		response.Code = 6
+		response.Message = "Account is banned"
+		return response, ErrAccountBanned
+	}
+
+	// The login was typed as either, see Login
+	s.secondFactor.Succeed(user.ID.Hex())
+	s.throttle.Succeed(user.Username)
+	s.throttle.Succeed(user.Email)
+	s.logger.WithFields(logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"username": user.Username,
+		"method":   verification.Purpose,
+	}).Info("User logged in successfully after verification")
+
+	authResponse, err := s.generateTokensAndSession(ctx, user, ipAddress, userAgent)
+	if err != nil {
+		return authResponse, err
+	}
+	if usedRecoveryCode {
+		authResponse.Warnings = append(authResponse.Warnings,
+			fmt.Sprintf("A recovery code was used, %d remaining", len(user.RecoveryCodes)))
+	}
+	return authResponse, nil
+}
+
+func hashVerificationCode(challengeID, code string) string {
+	sum := sha256.Sum256([]byte(challengeID + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// locate resolves the approximate location of an IP address, or nil when GeoIP is disabled or the IP is unknown
+func (s *authService) locate(ipAddress string) *geoip.Location {
+	if s.locator == nil || ipAddress == "" {
+		return nil
+	}
+
+	loc, err := s.locator.Lookup(ipAddress)
+	if err != nil {
+		s.logger.WithError(err).WithField("ip", ipAddress).Debug("GeoIP lookup failed")
+		return nil
+	}
+	return loc
 }
 
 func (s *authService) generateTokensAndSession(ctx context.Context, user *model.User, ipAddress, userAgent string) (*model.AuthResponse, error) {
@@ -176,19 +508,21 @@ func (s *authService) generateTokensAndSession(ctx context.Context, user *model.
 		return response, err
 	}
 
-	refreshTokenString, err := s.generateRandomToken()
+	refreshTokenString, err := s.codes.Token()
 	if err != nil {
 		response.Code = 2
 		response.Message = "Failed to generate refresh token"
 		return response, err
 	}
 
+	session := model.NewSession(user.ID, accessToken, expiresAt, ipAddress, userAgent)
 	refreshToken := model.NewRefreshToken(
 		user.ID,
 		refreshTokenString,
-		time.Now().Add(time.Duration(s.config.Auth.RefreshTokenExpiry)*time.Hour),
+		s.clock.Now().Add(time.Duration(s.config.Auth.RefreshTokenExpiry)*time.Hour),
 	)
 	refreshToken.DeviceInfo = userAgent
+	refreshToken.SessionID = session.ID
 
 	if err := s.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
 		response.Code = 3
@@ -196,7 +530,10 @@ func (s *authService) generateTokensAndSession(ctx context.Context, user *model.
 		return response, err
 	}
 
-	session := model.NewSession(user.ID, accessToken, expiresAt, ipAddress, userAgent)
+	if loc := s.locate(ipAddress); loc != nil {
+		session.Country = loc.Country
+		session.City = loc.City
+	}
 	if err := s.sessionRepo.Create(ctx, session); err != nil {
 		response.Code = 4
 		response.Message = "Failed to create session"
@@ -209,6 +546,8 @@ func (s *authService) generateTokensAndSession(ctx context.Context, user *model.
 		return response, err
 	}
 
+	s.events.Publish(event.UserLoggedIn{User: user})
+
 	return &model.AuthResponse{
 		User:         user.ToPrivateUser(),
 		Token:        accessToken,
@@ -218,19 +557,19 @@ func (s *authService) generateTokensAndSession(ctx context.Context, user *model.
 }
 
 func (s *authService) generateAccessToken(user *model.User) (string, time.Time, error) {
-	expiresAt := time.Now().Add(time.Duration(s.config.Auth.AccessTokenExpiry) * time.Hour)
+	now := s.clock.Now()
+	expiresAt := now.Add(time.Duration(s.config.Auth.AccessTokenExpiry) * time.Hour)
 
 	claims := jwt.MapClaims{
 		"user_id":  user.ID.Hex(),
 		"username": user.Username,
 		"email":    user.Email,
 		"exp":      expiresAt.Unix(),
-		"iat":      time.Now().Unix(),
+		"iat":      now.Unix(),
 		"iss":      "chatmix",
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtSecret)
+	tokenString, err := s.keyring.sign(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -238,14 +577,6 @@ func (s *authService) generateAccessToken(user *model.User) (string, time.Time,
 	return tokenString, expiresAt, nil
 }
 
-func (s *authService) generateRandomToken() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
-}
-
 func (s *authService) RefreshToken(ctx context.Context, req *model.RefreshTokenRequest) (*model.AuthResponse, error) {
 	response := &model.AuthResponse{}
 	refreshToken, err := s.refreshTokenRepo.GetByToken(ctx, req.RefreshToken)
@@ -255,7 +586,7 @@ func (s *authService) RefreshToken(ctx context.Context, req *model.RefreshTokenR
 		return response, err
 	}
 
-	if refreshToken == nil || !refreshToken.IsValid() {
+	if refreshToken == nil || !refreshToken.IsValidAt(s.clock.Now()) {
 		response.Code = 2
 		response.Message = "Invalid or expired refresh token"
 		return response, err
@@ -275,6 +606,12 @@ func (s *authService) RefreshToken(ctx context.Context, req *model.RefreshTokenR
 		return response, err
 	}
 
+	if user.IsBanned {
+		response.Code = 6
+		response.Message = "Account is banned"
+		return response, ErrAccountBanned
+	}
+
 	// Revoke old refresh token
 	if err := s.refreshTokenRepo.Revoke(ctx, refreshToken.ID); err != nil {
 		response.Code = 5
@@ -286,12 +623,10 @@ func (s *authService) RefreshToken(ctx context.Context, req *model.RefreshTokenR
 }
 
 func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.jwtSecret, nil
-	})
+	return jwt.Parse(tokenString, s.keyring.keyFunc,
+		jwt.WithValidMethods(s.keyring.validMethods()),
+		jwt.WithTimeFunc(s.clock.Now),
+	)
 }
 
 // GetUserFromToken extracts user from JWT token
@@ -314,6 +649,9 @@ func (s *authService) GetUserFromToken(tokenString string) (*model.User, error)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get user: %w", err)
 		}
+		if user != nil && user.IsBanned {
+			return nil, ErrAccountBanned
+		}
 
 		return user, nil
 	}
@@ -329,19 +667,21 @@ func (s *authService) Logout(ctx context.Context, userID, token string) error {
 		if err := s.userRepo.SetOnlineStatus(ctx, user.Username, false); err != nil {
 			s.logger.WithError(err).WithField("user_id", userID).Error("Failed to set user offline")
 		}
+		s.events.Publish(event.UserLoggedOut{Username: user.Username})
 	}
 
 	// Deactivate session
 	if err := s.sessionRepo.DeactivateByToken(ctx, token); err != nil {
 		s.logger.WithError(err).WithField("user_id", userID).Error("Failed to deactivate session")
 	}
+	s.rememberTouch(token, "", s.clock.Now(), time.Time{})
 
 	s.logger.WithField("user_id", userID).Info("User logged out")
 	return nil
 }
 
 func (s *authService) ChangePassword(ctx context.Context, userID string, req *model.PasswordChangeRequest) error {
-	if err := s.ValidateCaptcha(ctx, req.Captcha, req.Captcha); err != nil {
+	if err := s.ValidateCaptcha(ctx, req.CaptchaSolution, ""); err != nil {
 		return fmt.Errorf("invalid captcha: %w", err)
 	}
 
@@ -350,17 +690,17 @@ func (s *authService) ChangePassword(ctx context.Context, userID string, req *mo
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+	if err := s.passwords.Verify(user.PasswordHash, req.CurrentPassword); err != nil {
 		return fmt.Errorf("invalid current password")
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(req.NewPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash new password: %w", err)
 	}
 
-	user.PasswordHash = string(hashedPassword)
-	user.UpdatedAt = time.Now()
+	user.PasswordHash = hashedPassword
+	user.UpdatedAt = s.clock.Now()
 
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
@@ -375,8 +715,15 @@ func (s *authService) ChangePassword(ctx context.Context, userID string, req *mo
 	return nil
 }
 
-// GenerateCaptcha generates a simple math captcha
-func (s *authService) GenerateCaptcha(ctx context.Context, ipAddress string) (string, string, error) {
+// GenerateCaptcha generates a simple math captcha. With a third-party provider configured
+// it only returns the site key for the frontend widget.
+func (s *authService) GenerateCaptcha(ctx context.Context, ipAddress string) (*model.CaptchaInfo, error) {
+	info := &model.CaptchaInfo{Provider: s.config.Captcha.GetProvider()}
+	if s.captchaVerifier != nil {
+		info.SiteKey = s.config.Captcha.SiteKey
+		return info, nil
+	}
+
 	// Generate simple math captcha
 	a := randomInt(1, 20)
 	b := randomInt(1, 20)
@@ -399,31 +746,55 @@ func (s *authService) GenerateCaptcha(ctx context.Context, ipAddress string) (st
 	}
 
 	// Create captcha record
-	captcha := model.NewCaptchaChallenge(challenge, answer, ipAddress)
-	if err := s.captchaRepo.Create(ctx, captcha); err != nil {
-		return "", "", fmt.Errorf("failed to create captcha: %w", err)
+	record := model.NewCaptchaChallenge(challenge, answer, ipAddress)
+	record.CreatedAt = s.clock.Now()
+	record.ExpiresAt = record.CreatedAt.Add(model.CaptchaTTL)
+	if err := s.captchaRepo.Create(ctx, record); err != nil {
+		return nil, fmt.Errorf("failed to create captcha: %w", err)
 	}
 
-	return captcha.ID.Hex(), challenge, nil
+	info.ChallengeID = record.ID.Hex()
+	info.Challenge = challenge
+	return info, nil
 }
 
-// ValidateCaptcha validates a captcha answer
-func (s *authService) ValidateCaptcha(ctx context.Context, challengeID, answer string) error {
-	captcha, err := s.captchaRepo.GetByID(ctx, mustParseObjectID(challengeID))
+// ValidateCaptcha checks a captcha solution against the configured provider.
+// It always succeeds when captcha is disabled in features.
+func (s *authService) ValidateCaptcha(ctx context.Context, solution model.CaptchaSolution, ipAddress string) error {
+	if !s.config.Features.CaptchaEnabled {
+		return nil
+	}
+
+	if s.captchaVerifier != nil {
+		if solution.CaptchaToken == "" {
+			return fmt.Errorf("captcha token is required")
+		}
+		if err := s.captchaVerifier.Verify(ctx, solution.CaptchaToken, ipAddress); err != nil {
+			return fmt.Errorf("captcha verification failed: %w", err)
+		}
+		return nil
+	}
+
+	challengeID, err := primitive.ObjectIDFromHex(solution.Captcha)
+	if err != nil {
+		return fmt.Errorf("invalid captcha")
+	}
+
+	record, err := s.captchaRepo.GetByID(ctx, challengeID)
 	if err != nil {
 		return fmt.Errorf("invalid captcha")
 	}
 
-	if captcha == nil || !captcha.IsValid() {
+	if record == nil || !record.IsValidAt(s.clock.Now()) {
 		return fmt.Errorf("captcha expired or already used")
 	}
 
-	if captcha.Answer != answer {
+	if record.Answer != solution.CaptchaAnswer {
 		return fmt.Errorf("incorrect captcha answer")
 	}
 
-	captcha.MarkAsUsed()
-	if err := s.captchaRepo.Update(ctx, captcha); err != nil {
+	record.MarkAsUsed()
+	if err := s.captchaRepo.Update(ctx, record); err != nil {
 		s.logger.WithError(err).Error("Failed to mark captcha as used")
 	}
 
@@ -441,6 +812,30 @@ func (s *authService) RevokeAllSessions(ctx context.Context, userID string) erro
 	if err := s.refreshTokenRepo.RevokeAllByUserID(ctx, userOID); err != nil {
 		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
 	}
+	s.forgetTouches(userID, "")
+
+	return nil
+}
+
+func (s *authService) LogoutOthers(ctx context.Context, userID, token string) error {
+	userOID := mustParseObjectID(userID)
+
+	current, err := s.sessionRepo.GetByToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if current == nil || current.UserID != userOID || !current.IsValidAt(s.clock.Now()) {
+		return ErrSessionExpired
+	}
+
+	if err := s.sessionRepo.DeactivateOthersByUserID(ctx, userOID, current.ID); err != nil {
+		return fmt.Errorf("failed to deactivate sessions: %w", err)
+	}
+
+	if err := s.refreshTokenRepo.RevokeOthersByUserID(ctx, userOID, current.ID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	s.forgetTouches(userID, token)
 
 	return nil
 }
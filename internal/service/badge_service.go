@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	hundredChats = 100
+	weekStreak   = 7
+)
+
+// BadgeService awards badges from chat and account events
+type BadgeService interface {
+	// HandleChatStats awards chat badges, see event.ChatStatsRecorded
+	HandleChatStats(stats *model.ChatStats)
+	// HandleLogin awards account badges, see event.UserLoggedIn
+	HandleLogin(user *model.User)
+	GetUserBadges(ctx context.Context, username string) ([]model.BadgeView, error)
+}
+
+type badgeService struct {
+	badgeRepo     repository.BadgeRepository
+	userRepo      repository.UserRepository
+	notifications NotificationService
+	logger        *logrus.Logger
+	clock         Clock
+}
+
+func NewBadgeService(
+	badgeRepo repository.BadgeRepository,
+	userRepo repository.UserRepository,
+	notifications NotificationService,
+	logger *logrus.Logger,
+	opts ...Option,
+) BadgeService {
+	deps := newServiceDeps(opts)
+	return &badgeService{
+		badgeRepo:     badgeRepo,
+		userRepo:      userRepo,
+		notifications: notifications,
+		logger:        logger,
+		clock:         deps.clock,
+	}
+}
+
+func (s *badgeService) HandleChatStats(stats *model.ChatStats) {
+	var codes []string
+	if stats.TotalChats >= 1 {
+		codes = append(codes, model.BadgeFirstChat)
+	}
+	if stats.TotalChats >= hundredChats {
+		codes = append(codes, model.BadgeHundredChats)
+	}
+	if stats.CurrentStreak >= weekStreak {
+		codes = append(codes, model.BadgeWeekStreak)
+	}
+	s.award(stats.Username, codes...)
+}
+
+func (s *badgeService) HandleLogin(user *model.User) {
+	if user.IsVerified {
+		s.award(user.Username, model.BadgeVerifiedEmail)
+	}
+}
+
+// GetUserBadges returns the user's badges in award order
+func (s *badgeService) GetUserBadges(ctx context.Context, username string) ([]model.BadgeView, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	badges, err := s.badgeRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get badges: %w", err)
+	}
+
+	views := make([]model.BadgeView, 0, len(badges))
+	for _, badge := range badges {
+		definition, ok := model.GetBadgeDefinition(badge.Code)
+		if !ok {
+			continue
+		}
+		views = append(views, model.BadgeView{
+			BadgeDefinition: definition,
+			AwardedAt:       badge.AwardedAt,
+			Featured:        containsCode(user.FeaturedBadges, badge.Code),
+		})
+	}
+	return views, nil
+}
+
+// award stores the badges the user does not have yet, features them while there is room
+// on the profile, and notifies the user
+func (s *badgeService) award(username string, codes ...string) {
+	if len(codes) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var awarded []string
+	for _, code := range codes {
+		isNew, err := s.badgeRepo.Award(ctx, &model.UserBadge{
+			Username:  username,
+			Code:      code,
+			AwardedAt: s.clock.Now(),
+		})
+		if err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{"user": username, "badge": code}).Error("Failed to award badge")
+			continue
+		}
+		if isNew {
+			awarded = append(awarded, code)
+		}
+	}
+	if len(awarded) == 0 {
+		return
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil || user == nil {
+		s.logger.WithError(err).WithField("user", username).Warn("Failed to load user for awarded badges")
+		return
+	}
+
+	featured := false
+	for _, code := range awarded {
+		if len(user.FeaturedBadges) < model.MaxFeaturedBadges && !containsCode(user.FeaturedBadges, code) {
+			user.FeaturedBadges = append(user.FeaturedBadges, code)
+			featured = true
+		}
+	}
+	if featured {
+		user.UpdatedAt = s.clock.Now()
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			s.logger.WithError(err).WithField("user", username).Error("Failed to feature awarded badges")
+		}
+	}
+
+	for _, code := range awarded {
+		definition, _ := model.GetBadgeDefinition(code)
+		notification := model.NewNotification(user, model.NotificationBadgeAwarded, "Huy hiệu mới: "+definition.Name, definition.Description)
+		notification.Data = map[string]string{"badge": code}
+		if err := s.notifications.Notify(ctx, notification); err != nil {
+			s.logger.WithError(err).WithField("user", username).Warn("Failed to send badge notification")
+		}
+	}
+}
+
+func containsCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
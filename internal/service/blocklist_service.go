@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/pkg/profanity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxBlocklistPatternLength caps the length of a blocklist word or expression in characters
+const maxBlocklistPatternLength = 200
+
+var (
+	ErrBlocklistNotFound = errors.New("blocklist entry not found")
+	ErrBlocklistInvalid  = errors.New("invalid blocklist entry")
+	ErrBlocklistConflict = errors.New("blocklist entry was changed by someone else")
+)
+
+type BlocklistService interface {
+	List(ctx context.Context) ([]*model.BlocklistEntry, error)
+	Create(ctx context.Context, req model.BlocklistRequest, actor string) (*model.BlocklistEntry, error)
+	// Update replaces an entry and increments its version
+	Update(ctx context.Context, id string, req model.BlocklistRequest, actor string) (*model.BlocklistEntry, error)
+	// Delete removes an entry and returns it
+	Delete(ctx context.Context, id string) (*model.BlocklistEntry, error)
+	// Rules returns the compiled entries applied to chat messages, see MessageService
+	Rules() *BlocklistRules
+	// Reload compiles the rules again from the stored entries
+	Reload(ctx context.Context) error
+	// Run reloads the rules every chat.blocklist_refresh until the context is cancelled
+	Run(ctx context.Context)
+}
+
+type blocklistService struct {
+	blocklistRepo repository.BlocklistRepository
+	rules         atomic.Pointer[BlocklistRules]
+	config        *config.Config
+	logger        *logrus.Logger
+	clock         Clock
+}
+
+func NewBlocklistService(
+	blocklistRepo repository.BlocklistRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) BlocklistService {
+	deps := newServiceDeps(opts)
+	s := &blocklistService{
+		blocklistRepo: blocklistRepo,
+		config:        config,
+		logger:        logger,
+		clock:         deps.clock,
+	}
+	s.rules.Store(compileBlocklist(nil))
+	return s
+}
+
+func (s *blocklistService) List(ctx context.Context) ([]*model.BlocklistEntry, error) {
+	entries, err := s.blocklistRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocklist: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *blocklistService) Create(ctx context.Context, req model.BlocklistRequest, actor string) (*model.BlocklistEntry, error) {
+	if err := normalizeBlocklistRequest(&req); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	entry := &model.BlocklistEntry{
+		ID:        primitive.NewObjectID(),
+		Pattern:   req.Pattern,
+		Regex:     req.Regex,
+		Severity:  req.Severity,
+		Language:  req.Language,
+		Version:   1,
+		CreatedBy: actor,
+		UpdatedBy: actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.blocklistRepo.Create(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to create blocklist entry: %w", err)
+	}
+
+	s.reloadAfterChange(ctx)
+	return entry, nil
+}
+
+func (s *blocklistService) Update(ctx context.Context, id string, req model.BlocklistRequest, actor string) (*model.BlocklistEntry, error) {
+	entry, err := s.getEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version != 0 && req.Version != entry.Version {
+		return nil, ErrBlocklistConflict
+	}
+	if err := normalizeBlocklistRequest(&req); err != nil {
+		return nil, err
+	}
+
+	entry.Pattern = req.Pattern
+	entry.Regex = req.Regex
+	entry.Severity = req.Severity
+	entry.Language = req.Language
+	entry.Version++
+	entry.UpdatedBy = actor
+	entry.UpdatedAt = s.clock.Now()
+
+	updated, err := s.blocklistRepo.Update(ctx, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update blocklist entry: %w", err)
+	}
+	if !updated {
+		return nil, ErrBlocklistConflict
+	}
+
+	s.reloadAfterChange(ctx)
+	return entry, nil
+}
+
+func (s *blocklistService) Delete(ctx context.Context, id string) (*model.BlocklistEntry, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrBlocklistNotFound
+	}
+
+	entry, err := s.blocklistRepo.Delete(ctx, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+	if entry == nil {
+		return nil, ErrBlocklistNotFound
+	}
+
+	s.reloadAfterChange(ctx)
+	return entry, nil
+}
+
+func (s *blocklistService) Rules() *BlocklistRules {
+	return s.rules.Load()
+}
+
+func (s *blocklistService) Reload(ctx context.Context) error {
+	entries, err := s.blocklistRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load blocklist: %w", err)
+	}
+	s.rules.Store(compileBlocklist(entries))
+	return nil
+}
+
+func (s *blocklistService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Chat.BlocklistRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				s.logger.WithError(err).Warn("Failed to refresh blocklist")
+			}
+		}
+	}
+}
+
+// reloadAfterChange applies a change right away on this instance; other instances pick
+// it up on their next refresh
+func (s *blocklistService) reloadAfterChange(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to reload blocklist after a change")
+	}
+}
+
+func (s *blocklistService) getEntry(ctx context.Context, id string) (*model.BlocklistEntry, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrBlocklistNotFound
+	}
+
+	entry, err := s.blocklistRepo.GetByID(ctx, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocklist entry: %w", err)
+	}
+	if entry == nil {
+		return nil, ErrBlocklistNotFound
+	}
+	return entry, nil
+}
+
+func normalizeBlocklistRequest(req *model.BlocklistRequest) error {
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	req.Language = strings.ToLower(strings.TrimSpace(req.Language))
+	if req.Severity == "" {
+		req.Severity = model.BlocklistSeverityMedium
+	}
+
+	if req.Pattern == "" || utf8.RuneCountInString(req.Pattern) > maxBlocklistPatternLength {
+		return fmt.Errorf("%w: pattern must be 1-%d characters", ErrBlocklistInvalid, maxBlocklistPatternLength)
+	}
+	if !req.Severity.IsValid() {
+		return fmt.Errorf("%w: severity must be low, medium or high", ErrBlocklistInvalid)
+	}
+	if req.Regex {
+		pattern, err := regexp.Compile(req.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBlocklistInvalid, err)
+		}
+		if pattern.MatchString("") {
+			return fmt.Errorf("%w: pattern must not match empty text", ErrBlocklistInvalid)
+		}
+	}
+	return nil
+}
+
+// BlocklistRules holds the blocklist compiled into one filter per language and severity
+type BlocklistRules struct {
+	filters map[blocklistKey]*profanity.Filter
+}
+
+type blocklistKey struct {
+	language string
+	severity model.BlocklistSeverity
+}
+
+// compileBlocklist builds the rules of the entries. Single words are matched like the
+// profanity words; phrases and expressions are matched case-insensitively in the text.
+func compileBlocklist(entries []*model.BlocklistEntry) *BlocklistRules {
+	words := make(map[blocklistKey][]string)
+	patterns := make(map[blocklistKey][]*regexp.Regexp)
+	for _, entry := range entries {
+		key := blocklistKey{language: entry.Language, severity: entry.Severity}
+		switch {
+		case entry.Regex:
+			// Entries were validated when saved; skip any that no longer compile
+			if pattern, err := regexp.Compile("(?i)" + entry.Pattern); err == nil {
+				patterns[key] = append(patterns[key], pattern)
+			}
+		case !profanity.IsWord(entry.Pattern):
+			patterns[key] = append(patterns[key], regexp.MustCompile("(?i)"+regexp.QuoteMeta(entry.Pattern)))
+		default:
+			words[key] = append(words[key], entry.Pattern)
+		}
+	}
+
+	rules := &BlocklistRules{filters: make(map[blocklistKey]*profanity.Filter)}
+	for key, list := range words {
+		rules.filters[key] = profanity.NewWithPatterns(list, patterns[key])
+	}
+	for key, list := range patterns {
+		if _, exists := rules.filters[key]; !exists {
+			rules.filters[key] = profanity.NewWithPatterns(nil, list)
+		}
+	}
+	return rules
+}
+
+// Contains reports whether the text matches an entry of one of the severities for the
+// room language
+func (r *BlocklistRules) Contains(text, language string, severities ...model.BlocklistSeverity) bool {
+	for key, filter := range r.filters {
+		if r.applies(key, language, severities) && filter.Contains(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask masks the matches of the entries of the severities for the room language
+func (r *BlocklistRules) Mask(text, language string, severities ...model.BlocklistSeverity) (string, bool) {
+	found := false
+	for key, filter := range r.filters {
+		if !r.applies(key, language, severities) {
+			continue
+		}
+		var matched bool
+		text, matched = filter.Mask(text)
+		found = found || matched
+	}
+	return text, found
+}
+
+func (r *BlocklistRules) applies(key blocklistKey, language string, severities []model.BlocklistSeverity) bool {
+	if key.language != "" && language != "" && key.language != language {
+		return false
+	}
+	for _, severity := range severities {
+		if key.severity == severity {
+			return true
+		}
+	}
+	return false
+}
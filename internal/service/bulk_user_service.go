@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// bulkBatchSize is how many users a bulk job updates per database call
+	bulkBatchSize = 100
+	// maxRetainedBulkJobs is how many finished jobs are kept for progress queries
+	maxRetainedBulkJobs = 50
+)
+
+var (
+	ErrInvalidBulkAction = errors.New("invalid bulk action")
+	ErrEmptyUserFilter   = errors.New("at least one filter criterion is required")
+)
+
+// BulkUserService runs bulk ban, verify and delete operations as background jobs.
+// Jobs are kept in memory and do not survive a restart.
+type BulkUserService interface {
+	StartJob(actor *model.User, action model.BulkAction, filter model.UserFilter) (*model.BulkJob, error)
+	GetJob(id string) (*model.BulkJob, bool)
+	ListJobs() []*model.BulkJob
+}
+
+type bulkUserService struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	sessionRepo      repository.SessionRepository
+	logger           *logrus.Logger
+	clock            Clock
+	jobs             map[string]*model.BulkJob
+	jobsLock         sync.RWMutex
+}
+
+func NewBulkUserService(
+	userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	sessionRepo repository.SessionRepository,
+	logger *logrus.Logger,
+	opts ...Option,
+) BulkUserService {
+	deps := newServiceDeps(opts)
+	return &bulkUserService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		logger:           logger,
+		clock:            deps.clock,
+		jobs:             make(map[string]*model.BulkJob),
+	}
+}
+
+// StartJob validates the request and runs it in the background. Staff accounts are never affected.
+func (s *bulkUserService) StartJob(actor *model.User, action model.BulkAction, filter model.UserFilter) (*model.BulkJob, error) {
+	if !action.IsValid() {
+		return nil, ErrInvalidBulkAction
+	}
+	if filter.IsEmpty() {
+		return nil, ErrEmptyUserFilter
+	}
+
+	job := &model.BulkJob{
+		ID:        primitive.NewObjectID().Hex(),
+		Action:    action,
+		Filter:    filter,
+		Status:    model.BulkJobPending,
+		CreatedBy: actor.Username,
+		CreatedAt: s.clock.Now(),
+	}
+
+	s.jobsLock.Lock()
+	s.jobs[job.ID] = job
+	s.pruneJobs()
+	snapshot := *job
+	s.jobsLock.Unlock()
+
+	go s.run(job)
+
+	return &snapshot, nil
+}
+
+func (s *bulkUserService) GetJob(id string) (*model.BulkJob, bool) {
+	s.jobsLock.RLock()
+	defer s.jobsLock.RUnlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// ListJobs returns all retained jobs, newest first
+func (s *bulkUserService) ListJobs() []*model.BulkJob {
+	s.jobsLock.RLock()
+	jobs := make([]*model.BulkJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		snapshot := *job
+		jobs = append(jobs, &snapshot)
+	}
+	s.jobsLock.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+func (s *bulkUserService) run(job *model.BulkJob) {
+	ctx := context.Background()
+	logger := s.logger.WithFields(logrus.Fields{
+		"job_id": job.ID,
+		"action": job.Action,
+		"actor":  job.CreatedBy,
+	})
+
+	s.update(job, func() {
+		now := s.clock.Now()
+		job.Status = model.BulkJobRunning
+		job.StartedAt = &now
+	})
+
+	// Pages continue after the last user of the previous one, so users the action takes
+	// out of the filter, such as verified or deleted ones, do not shift the next page
+	fetch := func(ctx context.Context, page repository.PageRequest) (*repository.Page[*model.User], error) {
+		users, err := s.userRepo.FindByFilter(ctx, job.Filter, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find users: %w", err)
+		}
+		return users, nil
+	}
+	var totalAffected int64
+	err := repository.ForEachPage(ctx, bulkBatchSize, fetch, func(users []*model.User) error {
+		var batch []primitive.ObjectID
+		skipped := 0
+		for _, user := range users {
+			if user.IsStaff() {
+				skipped++
+				continue
+			}
+			batch = append(batch, user.ID)
+		}
+		s.update(job, func() {
+			job.Total += len(batch)
+			job.Skipped += skipped
+		})
+		if len(batch) == 0 {
+			return nil
+		}
+
+		affected, err := s.apply(ctx, job.Action, batch)
+		totalAffected += affected
+		s.update(job, func() {
+			job.Processed += len(batch)
+			job.Affected += affected
+		})
+		return err
+	})
+	if err != nil {
+		s.finish(job, err)
+		logger.WithError(err).Error("Bulk user job failed")
+		return
+	}
+
+	s.finish(job, nil)
+	logger.WithFields(logrus.Fields{
+		"total":    job.Total,
+		"affected": totalAffected,
+	}).Info("Bulk user job completed")
+}
+
+// apply performs the action on one batch of users and returns how many were changed
+func (s *bulkUserService) apply(ctx context.Context, action model.BulkAction, ids []primitive.ObjectID) (int64, error) {
+	switch action {
+	case model.BulkActionBan:
+		affected, err := s.userRepo.SetBanned(ctx, ids, true, s.clock.Now())
+		if err != nil {
+			return 0, fmt.Errorf("failed to ban users: %w", err)
+		}
+		s.revokeCredentials(ctx, ids)
+		return affected, nil
+	case model.BulkActionUnban:
+		affected, err := s.userRepo.SetBanned(ctx, ids, false, s.clock.Now())
+		if err != nil {
+			return 0, fmt.Errorf("failed to unban users: %w", err)
+		}
+		return affected, nil
+	case model.BulkActionVerify:
+		affected, err := s.userRepo.SetVerified(ctx, ids)
+		if err != nil {
+			return 0, fmt.Errorf("failed to verify users: %w", err)
+		}
+		return affected, nil
+	case model.BulkActionDelete:
+		s.revokeCredentials(ctx, ids)
+		affected, err := s.userRepo.DeleteMany(ctx, ids)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete users: %w", err)
+		}
+		return affected, nil
+	default:
+		return 0, ErrInvalidBulkAction
+	}
+}
+
+// revokeCredentials ends the sessions and refresh tokens of the users. Failures are logged only.
+func (s *bulkUserService) revokeCredentials(ctx context.Context, ids []primitive.ObjectID) {
+	for _, id := range ids {
+		if err := s.sessionRepo.DeactivateAllByUserID(ctx, id); err != nil {
+			s.logger.WithError(err).WithField("user_id", id.Hex()).Error("Failed to deactivate sessions")
+		}
+		if err := s.refreshTokenRepo.RevokeAllByUserID(ctx, id); err != nil {
+			s.logger.WithError(err).WithField("user_id", id.Hex()).Error("Failed to revoke refresh tokens")
+		}
+	}
+}
+
+func (s *bulkUserService) update(job *model.BulkJob, fn func()) {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+	fn()
+}
+
+func (s *bulkUserService) finish(job *model.BulkJob, err error) {
+	s.update(job, func() {
+		now := s.clock.Now()
+		job.FinishedAt = &now
+		job.Status = model.BulkJobCompleted
+		if err != nil {
+			job.Status = model.BulkJobFailed
+			job.Error = err.Error()
+		}
+	})
+}
+
+// pruneJobs drops the oldest finished jobs beyond the retention limit. Must be called with jobsLock held.
+func (s *bulkUserService) pruneJobs() {
+	if len(s.jobs) <= maxRetainedBulkJobs {
+		return
+	}
+
+	var finished []*model.BulkJob
+	for _, job := range s.jobs {
+		if job.IsFinished() {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreatedAt.Before(finished[j].CreatedAt)
+	})
+
+	for _, job := range finished {
+		if len(s.jobs) <= maxRetainedBulkJobs {
+			return
+		}
+		delete(s.jobs, job.ID)
+	}
+}
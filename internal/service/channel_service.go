@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/event"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxChannelNameLength        = 50
+	maxChannelDescriptionLength = 300
+)
+
+// channelSlugPattern allows slugs like "music" or "k-pop"
+var channelSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+var (
+	ErrChannelNotFound    = errors.New("channel not found")
+	ErrChannelExists      = errors.New("channel already exists")
+	ErrChannelInvalid     = errors.New("channel slug must be 2-32 lowercase letters, digits or dashes and the name 1-50 characters")
+	ErrNotChannelMember   = errors.New("not a member of this channel")
+	ErrChannelDescTooLong = errors.New("channel description must be at most 300 characters")
+)
+
+type ChannelService interface {
+	// List returns every channel with its member count, for the directory
+	List(ctx context.Context) ([]*model.Channel, error)
+	Get(ctx context.Context, slug string) (*model.Channel, error)
+	Create(ctx context.Context, req model.ChannelRequest, createdBy string) (*model.Channel, error)
+	Update(ctx context.Context, slug string, req model.ChannelRequest) (*model.Channel, error)
+	Delete(ctx context.Context, slug string) error
+	Join(ctx context.Context, slug, username string) (*model.Channel, error)
+	Leave(ctx context.Context, slug, username string) error
+	IsMember(ctx context.Context, slug, username string) (bool, error)
+}
+
+type channelService struct {
+	channelRepo repository.ChannelRepository
+	memberRepo  repository.ChannelMemberRepository
+	events      *event.Bus
+	logger      *logrus.Logger
+	clock       Clock
+	codes       CodeGenerator
+}
+
+func NewChannelService(
+	channelRepo repository.ChannelRepository,
+	memberRepo repository.ChannelMemberRepository,
+	events *event.Bus,
+	logger *logrus.Logger,
+	opts ...Option,
+) ChannelService {
+	deps := newServiceDeps(opts)
+	return &channelService{
+		channelRepo: channelRepo,
+		memberRepo:  memberRepo,
+		events:      events,
+		logger:      logger,
+		clock:       deps.clock,
+		codes:       deps.codes,
+	}
+}
+
+func (s *channelService) List(ctx context.Context) ([]*model.Channel, error) {
+	channels, err := s.channelRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %w", err)
+	}
+
+	counts, err := s.memberRepo.CountByChannel(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count channel members: %w", err)
+	}
+	for _, channel := range channels {
+		channel.MemberCount = counts[channel.Slug]
+	}
+	return channels, nil
+}
+
+func (s *channelService) Get(ctx context.Context, slug string) (*model.Channel, error) {
+	channel, err := s.channelRepo.GetBySlug(ctx, normalizeChannelSlug(slug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+	if channel == nil {
+		return nil, ErrChannelNotFound
+	}
+	return channel, nil
+}
+
+func (s *channelService) Create(ctx context.Context, req model.ChannelRequest, createdBy string) (*model.Channel, error) {
+	slug := normalizeChannelSlug(req.Slug)
+	if !channelSlugPattern.MatchString(slug) {
+		return nil, ErrChannelInvalid
+	}
+	name, description, err := validateChannelText(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	channel := &model.Channel{
+		ID:          primitive.NewObjectID(),
+		Slug:        slug,
+		Name:        name,
+		Description: description,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	created, err := s.channelRepo.Create(ctx, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+	if !created {
+		return nil, ErrChannelExists
+	}
+	return channel, nil
+}
+
+// Update changes the name and description of a channel
+func (s *channelService) Update(ctx context.Context, slug string, req model.ChannelRequest) (*model.Channel, error) {
+	channel, err := s.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	name, description, err := validateChannelText(req)
+	if err != nil {
+		return nil, err
+	}
+
+	channel.Name = name
+	channel.Description = description
+	if err := s.channelRepo.Update(ctx, channel); err != nil {
+		return nil, fmt.Errorf("failed to update channel: %w", err)
+	}
+	return channel, nil
+}
+
+// Delete removes the channel and its memberships. Its messages are kept.
+func (s *channelService) Delete(ctx context.Context, slug string) error {
+	slug = normalizeChannelSlug(slug)
+	deleted, err := s.channelRepo.Delete(ctx, slug)
+	if err != nil {
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+	if !deleted {
+		return ErrChannelNotFound
+	}
+
+	if err := s.memberRepo.DeleteByChannel(ctx, slug); err != nil {
+		s.logger.WithError(err).WithField("channel", slug).Warn("Failed to delete channel members")
+	}
+	s.events.Publish(event.ChannelDeleted{Channel: slug})
+	return nil
+}
+
+func (s *channelService) Join(ctx context.Context, slug, username string) (*model.Channel, error) {
+	channel, err := s.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := s.memberRepo.Add(ctx, &model.ChannelMember{
+		ID:       primitive.NewObjectID(),
+		Channel:  channel.Slug,
+		Username: username,
+		JoinedAt: s.clock.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to join channel: %w", err)
+	}
+	if added {
+		s.events.Publish(event.ChannelJoined{Channel: channel.Slug, Username: username})
+	}
+	return channel, nil
+}
+
+func (s *channelService) Leave(ctx context.Context, slug, username string) error {
+	slug = normalizeChannelSlug(slug)
+	removed, err := s.memberRepo.Remove(ctx, slug, username)
+	if err != nil {
+		return fmt.Errorf("failed to leave channel: %w", err)
+	}
+	if !removed {
+		return ErrNotChannelMember
+	}
+
+	s.events.Publish(event.ChannelLeft{Channel: slug, Username: username})
+	return nil
+}
+
+func (s *channelService) IsMember(ctx context.Context, slug, username string) (bool, error) {
+	return s.memberRepo.IsMember(ctx, normalizeChannelSlug(slug), username)
+}
+
+// normalizeChannelSlug accepts slugs written as "#Music"
+func normalizeChannelSlug(slug string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(slug)), model.ChannelRoomPrefix)
+}
+
+func validateChannelText(req model.ChannelRequest) (string, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxChannelNameLength {
+		return "", "", ErrChannelInvalid
+	}
+	description := strings.TrimSpace(req.Description)
+	if utf8.RuneCountInString(description) > maxChannelDescriptionLength {
+		return "", "", ErrChannelDescTooLong
+	}
+	return name, description, nil
+}
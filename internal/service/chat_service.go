@@ -2,12 +2,15 @@ package service
 
 import (
 	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
 	"chatmix-backend/internal/model"
-	"crypto/rand"
-	"encoding/base32"
+	"chatmix-backend/pkg/namegen"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,123 +20,386 @@ import (
 // Simple chat matching service
 // Logic: User tries to join existing waiting room, or creates new room
 
+var (
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrRoomFull      = errors.New("room is full")
+	ErrAlreadyInRoom = errors.New("already in another room")
+	ErrNotRoomMember = errors.New("not matched to this room")
+	ErrNoPartner     = errors.New("no partner in this room")
+)
+
 type ChatService interface {
-	StartChat(username string) (*model.ChatStartResponse, error)
+	StartChat(username string, prefs model.MatchPreferences) (*model.ChatStartResponse, error)
+	// JoinRoom fails with a TimeoutError while the user is timed out of chat
 	JoinRoom(roomCode, username string) error
+	// RoomToken returns a short-lived token binding the user to the room they were
+	// matched to; chat sockets connect with it rather than the guessable room code
+	RoomToken(roomCode, username string) (string, error)
+	// VerifyRoomToken returns the room code and username a room token binds, or
+	// ErrInvalidRoomToken
+	VerifyRoomToken(token string) (string, string, error)
 	LeaveRoom(roomCode, username string)
+	// Kick removes the user from their room and the queue so they cannot reconnect, and
+	// publishes event.UserKicked to close their connections. It returns the room they
+	// were removed from.
+	Kick(user *model.User) string
 	GetRoom(roomCode string) (*model.ChatRoom, bool)
+	CurrentRoom(username string) (*model.ChatRoom, bool)
+	// GetPartner returns the other member of the user's room. Bots and guests without
+	// an account are returned with only their username set.
+	GetPartner(ctx context.Context, roomCode, username string) (*model.User, error)
 	GetWaitingRooms() []*model.ChatRoom
+	ListRooms() []*model.ChatRoom
+	RecordMessage(roomCode, username string)
+	SetIcebreaker(roomCode, promptID string) bool
 	GetQueuePosition(username string) int
 	GetQueueSize() int
+	EstimateWait(position int) time.Duration
+	// Capacity returns room and queue usage and the average wait for a partner recently
+	Capacity() model.ChatCapacity
+	// Snapshot returns a copy of the rooms and the queue, see ChatSnapshotter
+	Snapshot() *model.ChatSnapshot
+	// Restore adds the rooms and queue entries of a snapshot. Restored room members who
+	// do not rejoin within grace are removed from their room. It returns the number of
+	// rooms restored.
+	Restore(snapshot *model.ChatSnapshot, grace time.Duration) int
+	// Start runs the matchmaker and the queue and room cleanup in the background until
+	// ctx is done or Stop is called
+	Start(ctx context.Context)
+	// Stop stops the background work of Start and waits for it to finish
+	Stop()
 }
 
+// maxTurnoverSamples is how many recent room closures are kept to estimate queue wait times
+const maxTurnoverSamples = 100
+
+// waitWindow is the period the average wait for a partner is reported over, see Capacity
+const waitWindow = 10 * time.Minute
+
+// maxWaitSamples bounds the waits kept within waitWindow
+const maxWaitSamples = 1000
+
+// Lock order: queueLock before roomsLock when both are held
 type chatService struct {
 	rooms     map[string]*model.ChatRoom
 	roomsLock sync.RWMutex
+	// userRooms maps username -> code of the room the user was last matched to. The entry
+	// is kept after the user leaves so they can reconnect while the room exists.
+	// Guarded by roomsLock.
+	userRooms map[string]string
+	// restored maps username -> room code of members restored from a snapshot who have
+	// not rejoined yet. Guarded by roomsLock.
+	restored  map[string]string
 	queue     []model.QueueEntry
 	queueLock sync.RWMutex
-	config    *config.ChatConfig
-	logger    *logrus.Logger
+	config    *config.Provider
+	limiter   *RoomLimiter
+	users     UserService
+	usage     UsageService
+	// experiments assigns users to the variants of running matching experiments
+	experiments ExperimentService
+	sanctions   SanctionService
+	events      *event.Bus
+	logger      *logrus.Logger
+	clock       Clock
+	codes       CodeGenerator
+	// names generates bot names with chat.bot.generated_names
+	names *namegen.Generator
+	// roomKey signs room tokens when chat.room_token.secret is empty
+	roomKey []byte
+
+	// wake signals the matchmaker, see signalMatchmaker
+	wake chan struct{}
+	// stop cancels the context of the goroutines of Start, which workers tracks
+	stop     context.CancelFunc
+	stopLock sync.Mutex
+	workers  sync.WaitGroup
+
+	// roomClosures holds the most recent room closure times, oldest first
+	roomClosures []time.Time
+	// waits holds how long users waited for a partner within waitWindow, oldest first
+	waits     []waitSample
+	statsLock sync.Mutex
 }
 
-func NewChatService(cfg *config.Config, logger *logrus.Logger) ChatService {
+func NewChatService(
+	cfg *config.Provider,
+	limiter *RoomLimiter,
+	users UserService,
+	usage UsageService,
+	experiments ExperimentService,
+	sanctions SanctionService,
+	events *event.Bus,
+	logger *logrus.Logger,
+	opts ...Option,
+) ChatService {
+	deps := newServiceDeps(opts)
 	cs := &chatService{
-		rooms:  make(map[string]*model.ChatRoom),
-		queue:  make([]model.QueueEntry, 0),
-		config: &cfg.Chat,
-		logger: logger,
+		rooms:       make(map[string]*model.ChatRoom),
+		userRooms:   make(map[string]string),
+		restored:    make(map[string]string),
+		queue:       make([]model.QueueEntry, 0),
+		config:      cfg,
+		limiter:     limiter,
+		users:       users,
+		usage:       usage,
+		experiments: experiments,
+		sanctions:   sanctions,
+		events:      events,
+		logger:      logger,
+		clock:       deps.clock,
+		codes:       deps.codes,
+		names:       namegen.New(namegen.WithRand(deps.codes.Index)),
+		roomKey:     newRoomTokenKey(),
+		wake:        make(chan struct{}, 1),
 	}
 
-	// Start background queue processor
-	go cs.processQueue()
-	go cs.cleanupExpiredQueueEntries()
-	go cs.cleanupLonelyRooms()
+	// A raised room limit or reloaded matching settings may let queued users in
+	limiter.OnRaise(cs.signalMatchmaker)
+	cfg.OnReload(func(_, _ *config.Config) { cs.signalMatchmaker() })
 
 	return cs
 }
 
-// StartChat finds a waiting room and joins it, creates a new room, or adds to queue
-func (s *chatService) StartChat(username string) (*model.ChatStartResponse, error) {
-	s.roomsLock.Lock()
-	defer s.roomsLock.Unlock()
+func (s *chatService) Start(ctx context.Context) {
+	s.stopLock.Lock()
+	defer s.stopLock.Unlock()
+	if s.stop != nil {
+		return // already started
+	}
 
-	// First, check if user is already in a room
-	for _, room := range s.rooms {
-		if room.HasUser(username) {
-			return &model.ChatStartResponse{
-				Status:   "room_assigned",
-				RoomCode: room.Code,
-				Message:  "Already in room",
-			}, nil
+	ctx, s.stop = context.WithCancel(ctx)
+	for _, run := range []func(context.Context){s.runMatchmaker, s.cleanupExpiredQueueEntries, s.cleanupLonelyRooms} {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			run(ctx)
+		}()
+	}
+}
+
+func (s *chatService) Stop() {
+	s.stopLock.Lock()
+	if s.stop != nil {
+		s.stop()
+	}
+	s.stopLock.Unlock()
+
+	s.workers.Wait()
+}
+
+// StartChat finds a waiting room and joins it, creates a new room, or adds to queue.
+// Waiting rooms whose member shares one of the preferred languages are tried first.
+// Assigned rooms come with the room token to connect with.
+// Users who are neither matched nor queued yet must be within their daily quota.
+// Users timed out of chat get the end of their timeout.
+func (s *chatService) StartChat(username string, prefs model.MatchPreferences) (*model.ChatStartResponse, error) {
+	prefs.Languages = model.NormalizeLanguages(prefs.Languages)
+	prefs.Variants, prefs.Matching = s.experiments.Assign(prefs.UserID)
+
+	if timeout := s.activeTimeout(username); timeout != nil {
+		return &model.ChatStartResponse{
+			Status:         model.ChatStatusSuspended,
+			Message:        (&TimeoutError{Until: timeout.ExpiresAt}).Error(),
+			SuspendedUntil: &timeout.ExpiresAt,
+		}, nil
+	}
+	// Users already in a room or the queue keep their place whatever their quota
+	if response := s.currentMatch(username); response != nil {
+		return s.withRoomToken(response, username), nil
+	}
+	if response := s.checkQuota(username, prefs.Premium); response != nil {
+		return response, nil
+	}
+
+	response, err := s.match(username, prefs)
+	if err != nil {
+		return response, err
+	}
+	if response.Status == model.ChatStatusRoomAssigned || response.Status == model.ChatStatusQueued {
+		s.usage.RecordChatStarted(username)
+	}
+	return s.withRoomToken(response, username), nil
+}
+
+// currentMatch returns the room or queue position of a user who was matched or queued
+// before, or nil
+func (s *chatService) currentMatch(username string) *model.ChatStartResponse {
+	s.queueLock.RLock()
+	defer s.queueLock.RUnlock()
+
+	s.roomsLock.RLock()
+	defer s.roomsLock.RUnlock()
+
+	if room := s.activeRoom(username); room != nil {
+		return &model.ChatStartResponse{
+			Status:   model.ChatStatusRoomAssigned,
+			RoomCode: room.Code,
+			Message:  "Already in room",
+			Language: room.Language,
 		}
 	}
+	return s.queuedResponse(username)
+}
 
-	// Try to find a waiting room (exactly 1 user)
-	for _, room := range s.rooms {
-		if room.IsWaiting() {
-			room.AddUser(username)
+// queuedResponse returns the queue position of the user, or nil when they are not
+// queued. Must be called with queueLock held.
+func (s *chatService) queuedResponse(username string) *model.ChatStartResponse {
+	for i, entry := range s.queue {
+		if entry.Username == username {
 			return &model.ChatStartResponse{
-				Status:   "room_assigned",
-				RoomCode: room.Code,
-				Message:  "Joined existing room",
-			}, nil
+				Status:               model.ChatStatusQueued,
+				Position:             i + 1,
+				Message:              "Already in queue",
+				EstimatedWaitSeconds: int(s.EstimateWait(i + 1).Seconds()),
+			}
 		}
 	}
+	return nil
+}
 
-	// Check if we can create a new room (under limit)
-	if len(s.rooms) < s.config.MaxRooms {
-		// Create new room
-		code := s.generateRoomCode()
-		room := &model.ChatRoom{
-			Code:      code,
-			Users:     []string{username},
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
+// checkQuota returns a quota_exceeded response when the user may not start another
+// chat today. Usage that cannot be read does not keep anyone from chatting.
+func (s *chatService) checkQuota(username string, premium bool) *model.ChatStartResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-		s.rooms[code] = room
+	tier := model.TierFree
+	if premium {
+		tier = model.TierPremium
+	}
+	quota, err := s.usage.ChatQuota(ctx, username, tier)
+	if err != nil {
+		s.logger.WithError(err).WithField("user", username).Warn("Failed to check chat quota")
+		return nil
+	}
+	if quota == nil || !quota.Exceeded() {
+		return nil
+	}
 
-		log.Println("List of rooms:")
-		for code, room := range s.rooms {
-			log.Printf("Room %s: %v", code, room)
+	message := fmt.Sprintf("You have used up today's %d chats, come back after the daily reset", quota.MaxChats)
+	if quota.MaxChats == 0 || quota.ChatsStarted < quota.MaxChats {
+		message = fmt.Sprintf("You have used up today's %d chat minutes, come back after the daily reset", quota.MaxChatMinutes)
+	}
+	return &model.ChatStartResponse{
+		Status:  model.ChatStatusQuotaExceeded,
+		Message: message,
+		Quota:   quota,
+	}
+}
+
+// match joins a waiting room, creates a room or queues the user. While others are queued
+// the user queues behind them rather than taking a room they are waiting for, and the
+// queue is matched in order right away.
+func (s *chatService) match(username string, prefs model.MatchPreferences) (*model.ChatStartResponse, error) {
+	response, queuedBehind := s.matchOrQueue(username, prefs)
+	if !queuedBehind {
+		return response, nil
+	}
+
+	s.tryAssignQueuedUsers()
+	if matched := s.currentMatch(username); matched != nil {
+		if matched.Status == model.ChatStatusRoomAssigned {
+			matched.Message = "Matched from queue"
 		}
+		return matched, nil
+	}
+	return response, nil
+}
+
+// matchOrQueue is match under the locks. queuedBehind reports that the user was queued
+// behind users already waiting.
+func (s *chatService) matchOrQueue(username string, prefs model.MatchPreferences) (*model.ChatStartResponse, bool) {
+	s.queueLock.Lock()
+	defer s.queueLock.Unlock()
+
+	s.roomsLock.Lock()
+	defer s.roomsLock.Unlock()
+
+	// The user may have been matched since currentMatch
+	if room := s.activeRoom(username); room != nil {
+		return &model.ChatStartResponse{
+			Status:   model.ChatStatusRoomAssigned,
+			RoomCode: room.Code,
+			Message:  "Already in room",
+			Language: room.Language,
+		}, false
+	}
+	if response := s.queuedResponse(username); response != nil {
+		return response, false
+	}
+
+	if len(s.queue) > 0 {
+		response := s.addToQueue(username, prefs)
+		return response, response.Status == model.ChatStatusQueued
+	}
+
+	// Try to find a waiting room (exactly 1 user)
+	if room, score := s.findWaitingRoom(prefs); room != nil {
+		s.joinWaitingRoom(room, username, prefs, s.clock.Now(), score)
+		return &model.ChatStartResponse{
+			Status:   model.ChatStatusRoomAssigned,
+			RoomCode: room.Code,
+			Message:  "Joined existing room",
+			Language: room.Language,
+		}, false
+	}
+
+	// Check if we can create a new room (under limit)
+	if len(s.rooms) < s.limiter.Limit() {
+		room := s.createRoom(username, prefs, s.clock.Now())
+		s.logger.WithFields(logrus.Fields{
+			"room":  room.Code,
+			"user":  username,
+			"rooms": len(s.rooms),
+		}).Debug("Created room")
 		return &model.ChatStartResponse{
-			Status:   "room_assigned",
+			Status:   model.ChatStatusRoomAssigned,
 			RoomCode: room.Code,
 			Message:  "Created new room",
-		}, nil
+		}, false
 
 	}
 
 	// Room limit reached, add to queue
-	return s.addToQueue(username)
+	return s.addToQueue(username, prefs), false
 }
 
-// JoinRoom allows user to join specific room if space available
+// JoinRoom (re)joins the room the user was matched to. Users can only be in one room,
+// and only rooms assigned by StartChat or the queue can be joined, so reconnecting works
+// but joining arbitrary room codes does not.
 func (s *chatService) JoinRoom(roomCode, username string) error {
+	if timeout := s.activeTimeout(username); timeout != nil {
+		return &TimeoutError{Until: timeout.ExpiresAt}
+	}
+
 	s.roomsLock.Lock()
 	defer s.roomsLock.Unlock()
 
-	// log list room
-	log.Println("List of rooms:")
-	for code, room := range s.rooms {
-		log.Printf("Room %s: %v", code, room)
-	}
-
 	room, exists := s.rooms[roomCode]
 	if !exists {
-		return fmt.Errorf("room not found")
+		return ErrRoomNotFound
+	}
+
+	if current := s.activeRoom(username); current != nil && current.Code != roomCode {
+		return ErrAlreadyInRoom
 	}
 
 	if room.HasUser(username) {
+		delete(s.restored, username)
 		return nil // already in room
 	}
 
+	if s.userRooms[username] != roomCode {
+		return ErrNotRoomMember
+	}
+
 	if room.IsFull() {
-		return fmt.Errorf("room is full")
+		return ErrRoomFull
 	}
 
-	room.AddUser(username)
+	room.AddUserAt(username, s.clock.Now())
+	s.publishRoomEvent(room, model.RoomEventUserJoined, username, "")
 	return nil
 }
 
@@ -147,14 +413,60 @@ func (s *chatService) LeaveRoom(roomCode, username string) {
 		return
 	}
 
-	room.RemoveUser(username)
+	if room.HasUser(username) {
+		now := s.clock.Now()
+		if !room.IsPaired() {
+			s.abandoned(username, room.Preferences[username], event.AbandonLeft, room.WaitingSince, now)
+		}
+		room.RemoveUserAt(username, now)
+		s.publishRoomEvent(room, model.RoomEventUserLeft, username, model.RoomReasonLeft)
+		s.signalMatchmaker() // the room has a free slot
+	}
+	delete(s.restored, username)
 
-	// Delete room if empty
-	if len(room.Users) == 0 {
-		delete(s.rooms, roomCode)
+	// Delete room if empty, or only a bot is left
+	if !room.HasHumans() {
+		s.deleteRoom(roomCode, model.RoomReasonEmpty)
 	}
 }
 
+func (s *chatService) Kick(user *model.User) string {
+	s.removeFromQueue(user.Username)
+
+	s.roomsLock.Lock()
+	now := s.clock.Now()
+	roomCode := ""
+	if room := s.activeRoom(user.Username); room != nil {
+		roomCode = room.Code
+		room.RemoveUserAt(user.Username, now)
+		s.publishRoomEvent(room, model.RoomEventUserLeft, user.Username, model.RoomReasonKicked)
+		if !room.HasHumans() {
+			s.deleteRoom(room.Code, model.RoomReasonEmpty)
+		}
+		s.signalMatchmaker()
+	}
+	delete(s.userRooms, user.Username)
+	delete(s.restored, user.Username)
+	s.roomsLock.Unlock()
+
+	s.events.Publish(event.UserKicked{UserID: user.ID.Hex(), Username: user.Username, RoomCode: roomCode})
+	return roomCode
+}
+
+// activeTimeout returns the running chat timeout of the user, or nil. Chat is not
+// refused when the timeout cannot be checked.
+func (s *chatService) activeTimeout(username string) *model.Sanction {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	timeout, err := s.sanctions.ActiveTimeout(ctx, username)
+	if err != nil {
+		s.logger.WithError(err).WithField("user", username).Warn("Failed to check chat timeout")
+		return nil
+	}
+	return timeout
+}
+
 // GetRoom returns room by code
 func (s *chatService) GetRoom(roomCode string) (*model.ChatRoom, bool) {
 	s.roomsLock.RLock()
@@ -168,6 +480,45 @@ func (s *chatService) GetRoom(roomCode string) (*model.ChatRoom, bool) {
 	return s.cloneRoom(room), true
 }
 
+// CurrentRoom returns the room the user is currently a member of
+func (s *chatService) CurrentRoom(username string) (*model.ChatRoom, bool) {
+	s.roomsLock.RLock()
+	defer s.roomsLock.RUnlock()
+
+	room := s.activeRoom(username)
+	if room == nil {
+		return nil, false
+	}
+	return s.cloneRoom(room), true
+}
+
+func (s *chatService) GetPartner(ctx context.Context, roomCode, username string) (*model.User, error) {
+	room, exists := s.GetRoom(roomCode)
+	if !exists {
+		return nil, ErrRoomNotFound
+	}
+	if !room.HasUser(username) {
+		return nil, ErrNotRoomMember
+	}
+
+	partner, ok := room.Partner(username)
+	if !ok {
+		return nil, ErrNoPartner
+	}
+	if model.IsBotUsername(partner) {
+		return &model.User{Username: partner}, nil
+	}
+
+	user, err := s.users.GetUser(ctx, partner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partner: %w", err)
+	}
+	if user == nil {
+		return &model.User{Username: partner}, nil
+	}
+	return user, nil
+}
+
 // GetWaitingRooms returns all rooms waiting for a second user
 func (s *chatService) GetWaitingRooms() []*model.ChatRoom {
 	s.roomsLock.RLock()
@@ -183,7 +534,45 @@ func (s *chatService) GetWaitingRooms() []*model.ChatRoom {
 	return waitingRooms
 }
 
-// GetQueuePosition returns user's position in queue (1-based), 0 if not in queue
+// ListRooms returns a snapshot of all active rooms
+func (s *chatService) ListRooms() []*model.ChatRoom {
+	s.roomsLock.RLock()
+	defer s.roomsLock.RUnlock()
+
+	rooms := make([]*model.ChatRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, s.cloneRoom(room))
+	}
+
+	return rooms
+}
+
+// RecordMessage increments the message counters of a room and its sender
+func (s *chatService) RecordMessage(roomCode, username string) {
+	s.roomsLock.Lock()
+	defer s.roomsLock.Unlock()
+
+	if room, exists := s.rooms[roomCode]; exists {
+		room.RecordMessageFrom(username)
+	}
+}
+
+// SetIcebreaker records the prompt sent to a full room. It reports false when the room
+// is gone, not full, or already has one, so each room gets at most one icebreaker.
+func (s *chatService) SetIcebreaker(roomCode, promptID string) bool {
+	s.roomsLock.Lock()
+	defer s.roomsLock.Unlock()
+
+	room, exists := s.rooms[roomCode]
+	if !exists || !room.IsFull() || room.IcebreakerID != "" {
+		return false
+	}
+	room.IcebreakerID = promptID
+	return true
+}
+
+// GetQueuePosition returns user's effective position in queue (1-based) after higher
+// priority entries, 0 if not in queue
 func (s *chatService) GetQueuePosition(username string) int {
 	s.queueLock.RLock()
 	defer s.queueLock.RUnlock()
@@ -204,33 +593,164 @@ func (s *chatService) GetQueueSize() int {
 	return len(s.queue)
 }
 
-// addToQueue adds user to queue and returns response
-func (s *chatService) addToQueue(username string) (*model.ChatStartResponse, error) {
-	s.queueLock.Lock()
-	defer s.queueLock.Unlock()
+// EstimateWait estimates how long the user at the given queue position will wait,
+// based on the rate rooms closed recently. Each freed room can take two queued users.
+// Returns 0 when there is not enough history.
+func (s *chatService) EstimateWait(position int) time.Duration {
+	if position <= 0 {
+		return 0
+	}
 
-	// Check if user already in queue
-	for i, entry := range s.queue {
-		if entry.Username == username {
-			return &model.ChatStartResponse{
-				Status:   "queued",
-				Position: i + 1,
-				Message:  "Already in queue",
-			}, nil
-		}
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	if len(s.roomClosures) < 2 {
+		return 0
+	}
+
+	elapsed := s.clock.Now().Sub(s.roomClosures[0])
+	if elapsed <= 0 {
+		return 0
+	}
+
+	perClosure := elapsed / time.Duration(len(s.roomClosures))
+	roomsNeeded := (position + 1) / 2
+	return perClosure * time.Duration(roomsNeeded)
+}
+
+// recordRoomClosure records a room closure for turnover statistics
+func (s *chatService) recordRoomClosure() {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	s.roomClosures = append(s.roomClosures, s.clock.Now())
+	if len(s.roomClosures) > maxTurnoverSamples {
+		s.roomClosures = s.roomClosures[len(s.roomClosures)-maxTurnoverSamples:]
+	}
+}
+
+type waitSample struct {
+	at   time.Time
+	wait time.Duration
+}
+
+// recordWait records the wait of a user who started looking for a partner at startedAt
+// and got one at now
+func (s *chatService) recordWait(startedAt, now time.Time) {
+	if startedAt.IsZero() {
+		return
 	}
 
-	// Add to queue
-	s.queue = append(s.queue, model.QueueEntry{
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	s.waits = append(s.waits, waitSample{at: now, wait: max(now.Sub(startedAt), 0)})
+	s.pruneWaits(now)
+}
+
+// pruneWaits drops waits older than waitWindow. Must be called with statsLock held.
+func (s *chatService) pruneWaits(now time.Time) {
+	drop := max(len(s.waits)-maxWaitSamples, 0)
+	for drop < len(s.waits) && now.Sub(s.waits[drop].at) > waitWindow {
+		drop++
+	}
+	s.waits = slices.Delete(s.waits, 0, drop)
+}
+
+// matched records the wait of a user who started looking for a partner at startedAt and
+// got one at now, and publishes it for the matchmaking metrics
+func (s *chatService) matched(username string, prefs model.MatchPreferences, startedAt, now time.Time, bot bool) {
+	s.recordWait(startedAt, now)
+	if startedAt.IsZero() {
+		return
+	}
+	s.events.Publish(event.MatchMade{
 		Username: username,
-		QueuedAt: time.Now(),
+		Bucket:   prefs.Bucket(),
+		Wait:     max(now.Sub(startedAt), 0),
+		Bot:      bot,
+		Variants: prefs.Variants,
+	})
+}
+
+// abandoned publishes that a user who started looking for a partner at startedAt gave up
+// at now without one, for the matchmaking metrics
+func (s *chatService) abandoned(username string, prefs model.MatchPreferences, reason string, startedAt, now time.Time) {
+	e := event.MatchAbandoned{Username: username, Bucket: prefs.Bucket(), Reason: reason, Variants: prefs.Variants}
+	if !startedAt.IsZero() {
+		e.Waited = max(now.Sub(startedAt), 0)
+	}
+	s.events.Publish(e)
+}
+
+func (s *chatService) Capacity() model.ChatCapacity {
+	s.roomsLock.RLock()
+	rooms := len(s.rooms)
+	s.roomsLock.RUnlock()
+
+	capacity := model.ChatCapacity{
+		RoomsInUse:    rooms,
+		MaxRooms:      s.limiter.Limit(),
+		QueueSize:     s.GetQueueSize(),
+		WindowSeconds: int(waitWindow.Seconds()),
+	}
+
+	s.statsLock.Lock()
+	s.pruneWaits(s.clock.Now())
+	var total time.Duration
+	for _, sample := range s.waits {
+		total += sample.wait
+	}
+	capacity.Matches = len(s.waits)
+	s.statsLock.Unlock()
+
+	if capacity.Matches > 0 {
+		capacity.AverageWaitSeconds = int((total / time.Duration(capacity.Matches)).Seconds())
+	}
+	capacity.Busy = capacity.QueueSize > 0 || capacity.RoomsInUse >= capacity.MaxRooms
+	return capacity
+}
+
+// addToQueue adds user to queue and returns response.
+// Must be called with queueLock held.
+func (s *chatService) addToQueue(username string, prefs model.MatchPreferences) *model.ChatStartResponse {
+	if maxQueue := s.chatConfig().MaxQueueLength; maxQueue > 0 && len(s.queue) >= maxQueue {
+		return &model.ChatStartResponse{
+			Status:               model.ChatStatusAtCapacity,
+			Message:              "Server at capacity, please try again later",
+			EstimatedWaitSeconds: int(s.EstimateWait(len(s.queue) + 1).Seconds()),
+		}
+	}
+
+	position := s.enqueue(model.QueueEntry{
+		Username:    username,
+		QueuedAt:    s.clock.Now(),
+		Priority:    prefs.Priority,
+		Preferences: prefs,
 	})
 
 	return &model.ChatStartResponse{
-		Status:   "queued",
-		Position: len(s.queue),
-		Message:  fmt.Sprintf("Added to queue. Position: %d", len(s.queue)),
-	}, nil
+		Status:               model.ChatStatusQueued,
+		Position:             position,
+		Message:              fmt.Sprintf("Added to queue. Position: %d", position),
+		EstimatedWaitSeconds: int(s.EstimateWait(position).Seconds()),
+	}
+}
+
+// enqueue inserts the entry behind every entry of the same or higher priority, keeping the
+// queue in priority-then-FIFO order, and returns its 1-based position.
+// Must be called with queueLock held.
+func (s *chatService) enqueue(entry model.QueueEntry) int {
+	index := sort.Search(len(s.queue), func(i int) bool {
+		return s.queue[i].Priority < entry.Priority
+	})
+
+	s.queue = append(s.queue, model.QueueEntry{})
+	copy(s.queue[index+1:], s.queue[index:])
+	s.queue[index] = entry
+	s.signalMatchmaker() // for the entry's bot deadline
+
+	return index + 1
 }
 
 // removeFromQueue removes user from queue
@@ -246,17 +766,7 @@ func (s *chatService) removeFromQueue(username string) {
 	}
 }
 
-// processQueue runs in background to assign rooms to queued users
-func (s *chatService) processQueue() {
-	ticker := time.NewTicker(5 * time.Second) // Check every 5 seconds
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.tryAssignQueuedUsers()
-	}
-}
-
-// tryAssignQueuedUsers tries to assign rooms to users in queue
+// tryAssignQueuedUsers tries to assign rooms to users in queue, in priority-then-FIFO order
 func (s *chatService) tryAssignQueuedUsers() {
 	s.queueLock.Lock()
 	defer s.queueLock.Unlock()
@@ -274,24 +784,14 @@ func (s *chatService) tryAssignQueuedUsers() {
 
 		// Try to find a waiting room
 		roomAssigned := false
-		for _, room := range s.rooms {
-			if room.IsWaiting() {
-				room.AddUser(user.Username)
-				roomAssigned = true
-				break
-			}
+		if room, score := s.findWaitingRoom(user.Preferences); room != nil {
+			s.joinWaitingRoom(room, user.Username, user.Preferences, user.QueuedAt, score)
+			roomAssigned = true
 		}
 
 		// If no waiting room and we can create new room
-		if !roomAssigned && len(s.rooms) < s.config.MaxRooms {
-			code := s.generateRoomCode()
-			room := &model.ChatRoom{
-				Code:      code,
-				Users:     []string{user.Username},
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
-			s.rooms[code] = room
+		if !roomAssigned && len(s.rooms) < s.limiter.Limit() {
+			s.createRoom(user.Username, user.Preferences, user.QueuedAt)
 			roomAssigned = true
 		}
 
@@ -303,87 +803,416 @@ func (s *chatService) tryAssignQueuedUsers() {
 	}
 }
 
-// cleanupExpiredQueueEntries removes users who have been in queue too long
-func (s *chatService) cleanupExpiredQueueEntries() {
-	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
-	defer ticker.Stop()
+// pairWithBots gives users who waited longer than the bot wait threshold a bot partner:
+// queued users get a new room beyond the room limit, up to the bot room limit, and
+// users alone in a room that never had a partner get the bot as their partner.
+func (s *chatService) pairWithBots(now time.Time) {
+	botConfig := s.chatConfig().Bot
+	if !botConfig.Enabled {
+		return
+	}
 
-	for range ticker.C {
-		s.queueLock.Lock()
-		now := time.Now()
+	s.queueLock.Lock()
+	defer s.queueLock.Unlock()
 
-		var validEntries []model.QueueEntry
-		for _, entry := range s.queue {
-			if now.Sub(entry.QueuedAt) < s.config.QueueTimeout {
-				validEntries = append(validEntries, entry)
+	s.roomsLock.Lock()
+	defer s.roomsLock.Unlock()
+
+	botRooms := 0
+	for _, room := range s.rooms {
+		if room.HasBot() {
+			botRooms++
+		}
+	}
+
+	for _, room := range s.rooms {
+		if botRooms >= botConfig.MaxRooms {
+			return
+		}
+		if room.IsWaiting() && !room.IsPaired() && now.Sub(room.UpdatedAt) >= botWaitThreshold(botConfig, room.Preferences[room.Users[0]]) {
+			s.addBot(room, s.botName(botConfig), now)
+			botRooms++
+		}
+	}
+
+	for i := 0; i < len(s.queue) && botRooms < botConfig.MaxRooms; i++ {
+		entry := s.queue[i]
+		if now.Sub(entry.QueuedAt) < botWaitThreshold(botConfig, entry.Preferences) {
+			continue
+		}
+
+		room := s.createRoom(entry.Username, entry.Preferences, entry.QueuedAt)
+		s.addBot(room, s.botName(botConfig), now)
+		botRooms++
+
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		i--
+	}
+}
+
+// botWaitThreshold returns how long the user waits before getting a bot partner, which
+// the user's experiment variants may change
+func botWaitThreshold(botConfig config.BotConfig, prefs model.MatchPreferences) time.Duration {
+	if prefs.Matching.BotWaitSeconds > 0 {
+		return time.Duration(prefs.Matching.BotWaitSeconds) * time.Second
+	}
+	return botConfig.WaitThreshold
+}
+
+// botName returns the name of the bot joining a room: chat.bot.name, or with
+// chat.bot.generated_names a generated name no other room's bot uses.
+// Must be called with roomsLock held.
+func (s *chatService) botName(botConfig config.BotConfig) string {
+	if !botConfig.GeneratedNames {
+		return botConfig.Name
+	}
+
+	name, err := s.names.Unique(context.Background(), func(_ context.Context, name string) (bool, error) {
+		bot := model.BotUsername(name)
+		for _, room := range s.rooms {
+			if room.Bot == bot {
+				return true, nil
 			}
 		}
+		return false, nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to generate a bot name, using chat.bot.name")
+		return botConfig.Name
+	}
+	return name
+}
 
-		s.queue = validEntries
-		s.queueLock.Unlock()
+// addBot puts a bot in the second slot of the room. The bot has no content filter
+// preference of its own, so the room uses its human member's level.
+// Must be called with roomsLock held.
+func (s *chatService) addBot(room *model.ChatRoom, name string, now time.Time) {
+	if !room.IsPaired() {
+		waiting := room.Users[0]
+		s.matched(waiting, room.Preferences[waiting], room.WaitingSince, now, true)
 	}
+
+	bot := model.BotUsername(name)
+	room.Bot = bot
+	room.AddUserAt(bot, now)
+	room.SetPreferences(bot, model.MatchPreferences{ContentFilter: model.ContentFilterOff})
+	s.publishRoomEvent(room, model.RoomEventUserJoined, bot, "")
+
+	s.logger.WithField("room", room.Code).Info("Bot joined waiting room")
+	s.events.Publish(event.BotJoined{RoomCode: room.Code, Bot: bot})
 }
 
-// cleanupLonelyRooms removes rooms where a single user has been waiting too long
-func (s *chatService) cleanupLonelyRooms() {
-	log.Println("Cleaning up lonely rooms...")
-	ticker := time.NewTicker(s.config.RoomCleanupInterval)
+// cleanupExpiredQueueEntries removes users who have been in queue too long until ctx is
+// done
+func (s *chatService) cleanupExpiredQueueEntries(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
 	defer ticker.Stop()
 
-	for range ticker.C {
-		s.roomsLock.Lock()
-		now := time.Now()
-
-		var roomsToDelete []string
-		for code, room := range s.rooms {
-			// Check if room has exactly 1 user and has been waiting longer than cleanup interval
-			if len(room.Users) == 1 && now.Sub(room.UpdatedAt) >= s.config.RoomCleanupInterval {
-				log.Printf("Room %s is lonely and will be deleted", code)
-				log.Printf("Room %s was created at %s", code, room.CreatedAt)
-				log.Printf("Room %s was updated at %s", code, room.UpdatedAt)
-				log.Printf("RoomCleanupInterval: %s", s.config.RoomCleanupInterval)
-				roomsToDelete = append(roomsToDelete, code)
-			}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expireQueueEntries(s.clock.Now())
+		}
+	}
+}
+
+// expireQueueEntries drops queue entries older than the queue timeout at the given time
+func (s *chatService) expireQueueEntries(now time.Time) {
+	s.queueLock.Lock()
+	defer s.queueLock.Unlock()
+
+	var validEntries []model.QueueEntry
+	for _, entry := range s.queue {
+		if now.Sub(entry.QueuedAt) < s.chatConfig().QueueTimeout {
+			validEntries = append(validEntries, entry)
+		} else {
+			s.abandoned(entry.Username, entry.Preferences, event.AbandonQueueTimeout, entry.QueuedAt, now)
 		}
+	}
+
+	s.queue = validEntries
+}
 
-		// Delete the lonely rooms
-		for _, code := range roomsToDelete {
-			delete(s.rooms, code)
+// cleanupLonelyRooms removes rooms where a single user has been waiting too long until
+// ctx is done
+func (s *chatService) cleanupLonelyRooms(ctx context.Context) {
+	ticker := time.NewTicker(s.chatConfig().RoomCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.removeLonelyRooms(s.clock.Now())
 		}
+	}
+}
 
-		s.roomsLock.Unlock()
+// removeLonelyRooms deletes rooms whose single member has been waiting longer than
+// the cleanup interval at the given time
+func (s *chatService) removeLonelyRooms(now time.Time) {
+	s.roomsLock.Lock()
+	defer s.roomsLock.Unlock()
+
+	var roomsToDelete []string
+	for code, room := range s.rooms {
+		// Check if room has exactly 1 user and has been waiting longer than cleanup interval.
+		// A bot room nobody has written in counts as lonely too: its user never showed up.
+		lonely := len(room.Users) == 1 || (room.HasBot() && room.MessageCount == 0)
+		if lonely && now.Sub(room.UpdatedAt) >= s.chatConfig().RoomCleanupInterval {
+			s.logger.WithFields(logrus.Fields{
+				"room":       code,
+				"created_at": room.CreatedAt,
+				"updated_at": room.UpdatedAt,
+				"interval":   s.chatConfig().RoomCleanupInterval,
+			}).Debug("Deleting lonely room")
+			if !room.IsPaired() {
+				for _, user := range room.Users {
+					s.abandoned(user, room.Preferences[user], event.AbandonLonelyRoom, room.WaitingSince, now)
+				}
+			}
+			roomsToDelete = append(roomsToDelete, code)
+		}
+	}
+
+	// Delete the lonely rooms
+	for _, code := range roomsToDelete {
+		s.deleteRoom(code, model.RoomReasonLonely)
 	}
 }
 
 // Helper methods
 
+// chatConfig returns the current chat settings, which may change on config reload
+func (s *chatService) chatConfig() config.ChatConfig {
+	return s.config.Get().Chat
+}
+
+// findWaitingRoom returns a waiting room whose member's location requirements are compatible.
+// With chat.compatibility it is the room with the best compatibility score, which is
+// returned too. Otherwise it prefers a room whose member shares a language and then, with
+// chat.timezone_bias, one whose member lives in a near time zone. The matching params of
+// the user's experiment variants may turn either preference off or on.
+// Must be called with roomsLock held.
+func (s *chatService) findWaitingRoom(prefs model.MatchPreferences) (*model.ChatRoom, *float64) {
+	if compat := s.chatConfig().Compatibility; compat.Enabled {
+		return s.findCompatibleRoom(prefs, compat)
+	}
+
+	bias := s.chatConfig().TimeZoneBias
+	if prefs.Matching.TimeZoneBias != nil {
+		bias.Enabled = *prefs.Matching.TimeZoneBias
+	}
+	preferLanguage := !prefs.Matching.IgnoreLanguage
+	topScore := 0
+	if preferLanguage {
+		topScore += 2 // a shared language outweighs a near time zone
+	}
+	if bias.Enabled {
+		topScore++
+	}
+
+	var best *model.ChatRoom
+	bestScore := -1
+	for _, room := range s.rooms {
+		if !room.IsWaiting() || !room.AcceptsMember(prefs) {
+			continue
+		}
+		roomScore := 0
+		if _, ok := room.SharedLanguage(prefs.Languages); ok && preferLanguage {
+			roomScore += 2
+		}
+		if bias.Enabled && room.NearTimeZone(prefs, bias.MaxOffset) {
+			roomScore++
+		}
+		if roomScore > bestScore {
+			best, bestScore = room, roomScore
+		}
+		if bestScore == topScore {
+			break
+		}
+	}
+	return best, nil
+}
+
+// findCompatibleRoom returns the waiting room with the highest compatibility score for
+// the user, and the score. Must be called with roomsLock held.
+func (s *chatService) findCompatibleRoom(prefs model.MatchPreferences, compat config.CompatibilityConfig) (*model.ChatRoom, *float64) {
+	weights := model.CompatibilityWeights{
+		Language:  compat.LanguageWeight,
+		Age:       compat.AgeWeight,
+		TimeZone:  compat.TimeZoneWeight,
+		MaxAgeGap: compat.MaxAgeGap,
+		MaxOffset: compat.MaxOffset,
+	}
+	if prefs.Matching.IgnoreLanguage {
+		weights.Language = 0
+	}
+	if prefs.Matching.TimeZoneBias != nil && !*prefs.Matching.TimeZoneBias {
+		weights.TimeZone = 0
+	}
+
+	var best *model.ChatRoom
+	bestScore := -1.0
+	for _, room := range s.rooms {
+		if !room.IsWaiting() || !room.AcceptsMember(prefs) {
+			continue
+		}
+		// Ties go to the room waiting longest
+		score := room.Compatibility(prefs, weights)
+		if score > bestScore || (score == bestScore && room.WaitingSince.Before(best.WaitingSince)) {
+			best, bestScore = room, score
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return best, &bestScore
+}
+
+// joinWaitingRoom adds the user, who started looking for a partner at startedAt, to the
+// room and negotiates the room language. The compatibility score, when the room was
+// ranked by one, goes in the user_joined event. Must be called with roomsLock held.
+func (s *chatService) joinWaitingRoom(room *model.ChatRoom, username string, prefs model.MatchPreferences, startedAt time.Time, score *float64) {
+	now := s.clock.Now()
+	if !room.IsPaired() {
+		waiting := room.Users[0]
+		s.matched(waiting, room.Preferences[waiting], room.WaitingSince, now, false)
+	}
+	s.matched(username, prefs, startedAt, now, false)
+
+	room.Language = negotiateLanguage(room, prefs.Languages)
+	room.AddUserAt(username, now)
+	room.SetPreferences(username, prefs)
+	s.userRooms[username] = room.Code
+
+	e := s.roomEvent(room, model.RoomEventUserJoined, username, "")
+	e.Score = score
+	s.events.Publish(event.RoomLifecycle{Event: e})
+}
+
+// createRoom creates a room with the user, who started looking for a partner at
+// startedAt, as its first member. Must be called with roomsLock held.
+func (s *chatService) createRoom(username string, prefs model.MatchPreferences, startedAt time.Time) *model.ChatRoom {
+	code := s.generateRoomCode()
+	now := s.clock.Now()
+	room := &model.ChatRoom{
+		Code:         code,
+		Users:        []string{username},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		WaitingSince: startedAt,
+	}
+	room.SetPreferences(username, prefs)
+	s.rooms[code] = room
+	s.userRooms[username] = code
+	s.publishRoomEvent(room, model.RoomEventCreated, "", "")
+	s.publishRoomEvent(room, model.RoomEventUserJoined, username, "")
+	return room
+}
+
+// activeRoom returns the room the user is currently a member of, or nil.
+// Must be called with roomsLock held.
+func (s *chatService) activeRoom(username string) *model.ChatRoom {
+	room, exists := s.rooms[s.userRooms[username]]
+	if !exists || !room.HasUser(username) {
+		return nil
+	}
+	return room
+}
+
+// deleteRoom removes a room and forgets its memberships; reason is one of the
+// model.RoomReason values of closed events. Must be called with roomsLock held.
+func (s *chatService) deleteRoom(code, reason string) {
+	if room, exists := s.rooms[code]; exists {
+		if room.IsPaired() {
+			s.events.Publish(event.RoomClosed{Summary: room.Summary(s.clock.Now())})
+		}
+		s.publishRoomEvent(room, model.RoomEventClosed, "", reason)
+	}
+
+	delete(s.rooms, code)
+	for username, roomCode := range s.userRooms {
+		if roomCode == code {
+			delete(s.userRooms, username)
+		}
+	}
+	s.recordRoomClosure()
+	s.signalMatchmaker()
+}
+
+// publishRoomEvent publishes the room's next lifecycle event, see roomEvent.
+// Must be called with roomsLock held.
+func (s *chatService) publishRoomEvent(room *model.ChatRoom, eventType, username, reason string) {
+	s.events.Publish(event.RoomLifecycle{Event: s.roomEvent(room, eventType, username, reason)})
+}
+
+// roomEvent returns the room's next lifecycle event with the message counts it rolls up.
+// Must be called with roomsLock held.
+func (s *chatService) roomEvent(room *model.ChatRoom, eventType, username, reason string) *model.RoomEvent {
+	e := room.NextEvent(eventType, s.clock.Now())
+	e.Username = username
+	e.Reason = reason
+	switch eventType {
+	case model.RoomEventUserLeft:
+		e.MessageCount = room.MessagesBy[username]
+	case model.RoomEventClosed:
+		e.MessageCount = room.MessageCount
+		e.MessagesBy = maps.Clone(room.MessagesBy)
+	}
+	return e
+}
+
+// negotiateLanguage picks the room language for a joining user: a shared language if any,
+// otherwise the only side's preference when the other declared none.
+func negotiateLanguage(room *model.ChatRoom, languages []string) string {
+	if lang, ok := room.SharedLanguage(languages); ok {
+		return lang
+	}
+
+	var memberLanguages []string
+	for _, user := range room.Users {
+		memberLanguages = append(memberLanguages, room.Preferences[user].Languages...)
+	}
+
+	switch {
+	case len(languages) == 0 && len(memberLanguages) > 0:
+		return memberLanguages[0]
+	case len(memberLanguages) == 0 && len(languages) > 0:
+		return languages[0]
+	default:
+		return ""
+	}
+}
+
 func (s *chatService) cloneRoom(room *model.ChatRoom) *model.ChatRoom {
 	clone := &model.ChatRoom{
-		Code:      room.Code,
-		CreatedAt: room.CreatedAt,
-		UpdatedAt: room.UpdatedAt,
-		Users:     make([]string, len(room.Users)),
+		Code:         room.Code,
+		Language:     room.Language,
+		MessageCount: room.MessageCount,
+		IcebreakerID: room.IcebreakerID,
+		Bot:          room.Bot,
+		CreatedAt:    room.CreatedAt,
+		UpdatedAt:    room.UpdatedAt,
+		Users:        make([]string, len(room.Users)),
+		Preferences:  make(map[string]model.MatchPreferences, len(room.Preferences)),
 	}
 	copy(clone.Users, room.Users)
+	for user, prefs := range room.Preferences {
+		prefs.Languages = append([]string(nil), prefs.Languages...)
+		clone.Preferences[user] = prefs
+	}
 	return clone
 }
 
 func (s *chatService) generateRoomCode() string {
 	for {
-		code := generateRandomCode(8)
+		code := s.codes.RoomCode(8)
 		if _, exists := s.rooms[code]; !exists {
 			return code
 		}
 	}
 }
-
-func generateRandomCode(n int) string {
-	bytes := make([]byte, n)
-	_, _ = rand.Read(bytes)
-	encoded := base32.StdEncoding.EncodeToString(bytes)
-	code := strings.ToUpper(strings.TrimRight(encoded, "="))
-	if len(code) >= n {
-		return code[:n]
-	}
-	return code
-}
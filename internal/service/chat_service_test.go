@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestChatService(t *testing.T) ChatService {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Chat.MaxRooms = 10
+	cfg.Chat.QueueTimeout = time.Minute
+	cfg.Chat.RoomCleanupInterval = time.Minute
+	provider := config.NewProvider("", cfg)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewChatService(provider, NewRoomLimiter(provider, logger), nil, nil, nil, nil, event.NewBus(logger), logger)
+}
+
+// waitForGoroutines polls until at most want goroutines run, since exiting goroutines
+// are not gone the moment the ones waiting for them return
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := runtime.NumGoroutine()
+		if got <= want {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines still running, want at most %d:\n%s", got, want, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChatServiceStopReleasesWorkers(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	s := newTestChatService(t)
+
+	s.Start(context.Background())
+	if runtime.NumGoroutine() <= baseline {
+		t.Fatal("Start did not start the background loops")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+	waitForGoroutines(t, baseline)
+}
+
+func TestChatServiceContextCancelReleasesWorkers(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	s := newTestChatService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	cancel()
+	waitForGoroutines(t, baseline)
+
+	// Stop after the context ended only waits for the loops that already exited
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return after the context was cancelled")
+	}
+	waitForGoroutines(t, baseline)
+}
+
+func TestChatServiceStartTwiceStartsOnce(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	s := newTestChatService(t)
+
+	s.Start(context.Background())
+	started := runtime.NumGoroutine()
+	s.Start(context.Background())
+	if got := runtime.NumGoroutine(); got > started {
+		t.Fatalf("second Start started %d more goroutines", got-started)
+	}
+
+	s.Stop()
+	waitForGoroutines(t, baseline)
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
+	"chatmix-backend/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Snapshot copies the rooms and the queue one after the other rather than holding both
+// locks, so it does not hold up matching. A user matched in between may appear in both;
+// Restore skips queue entries of room members.
+func (s *chatService) Snapshot() *model.ChatSnapshot {
+	snapshot := &model.ChatSnapshot{TakenAt: s.clock.Now()}
+
+	s.roomsLock.RLock()
+	snapshot.Rooms = make([]*model.ChatRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		snapshot.Rooms = append(snapshot.Rooms, snapshotRoom(room))
+	}
+	snapshot.UserRooms = maps.Clone(s.userRooms)
+	s.roomsLock.RUnlock()
+
+	s.queueLock.RLock()
+	snapshot.Queue = make([]model.QueueEntry, len(s.queue))
+	for i, entry := range s.queue {
+		entry.Preferences.Languages = append([]string(nil), entry.Preferences.Languages...)
+		snapshot.Queue[i] = entry
+	}
+	s.queueLock.RUnlock()
+
+	return snapshot
+}
+
+// snapshotRoom copies every field of a room, including the chat tracking that
+// cloneRoom leaves out
+func snapshotRoom(room *model.ChatRoom) *model.ChatRoom {
+	clone := *room
+	clone.Users = append([]string(nil), room.Users...)
+	clone.Participants = append([]string(nil), room.Participants...)
+	clone.MessagesBy = maps.Clone(room.MessagesBy)
+	clone.Buckets = maps.Clone(room.Buckets)
+	clone.Variants = maps.Clone(room.Variants)
+	clone.Preferences = make(map[string]model.MatchPreferences, len(room.Preferences))
+	for user, prefs := range room.Preferences {
+		prefs.Languages = append([]string(nil), prefs.Languages...)
+		clone.Preferences[user] = prefs
+	}
+	return &clone
+}
+
+func (s *chatService) Restore(snapshot *model.ChatSnapshot, grace time.Duration) int {
+	s.roomsLock.Lock()
+
+	restored := 0
+	var bots []event.BotJoined
+	for _, room := range snapshot.Rooms {
+		// Rooms created since startup win over the snapshot
+		if _, exists := s.rooms[room.Code]; exists || len(room.Users) == 0 {
+			continue
+		}
+		if room.Preferences == nil {
+			room.Preferences = make(map[string]model.MatchPreferences)
+		}
+		s.rooms[room.Code] = room
+		restored++
+
+		for _, user := range room.Users {
+			if model.IsBotUsername(user) {
+				bots = append(bots, event.BotJoined{RoomCode: room.Code, Bot: user})
+				continue
+			}
+			s.restored[user] = room.Code
+		}
+	}
+	for username, code := range snapshot.UserRooms {
+		if _, exists := s.rooms[code]; exists && s.userRooms[username] == "" {
+			s.userRooms[username] = code
+		}
+	}
+
+	var waiting []model.QueueEntry
+	for _, entry := range snapshot.Queue {
+		if s.activeRoom(entry.Username) == nil {
+			waiting = append(waiting, entry)
+		}
+	}
+	s.roomsLock.Unlock()
+
+	s.queueLock.Lock()
+	queued := make(map[string]bool, len(s.queue))
+	for _, entry := range s.queue {
+		queued[entry.Username] = true
+	}
+	for _, entry := range waiting {
+		if !queued[entry.Username] {
+			s.enqueue(entry)
+		}
+	}
+	s.queueLock.Unlock()
+
+	// Bots are connections of the chat handler, which attaches them again
+	for _, bot := range bots {
+		s.events.Publish(bot)
+	}
+
+	time.AfterFunc(grace, s.expireRestoredMembers)
+	return restored
+}
+
+// expireRestoredMembers removes restored room members who did not rejoin their room
+func (s *chatService) expireRestoredMembers() {
+	s.roomsLock.Lock()
+	var expired []event.RoomMemberExpired
+	for username, code := range s.restored {
+		delete(s.restored, username)
+
+		room, exists := s.rooms[code]
+		if !exists || !room.HasUser(username) {
+			continue
+		}
+		room.RemoveUserAt(username, s.clock.Now())
+		s.publishRoomEvent(room, model.RoomEventUserLeft, username, model.RoomReasonExpired)
+		s.signalMatchmaker()
+		expired = append(expired, event.RoomMemberExpired{RoomCode: code, Username: username})
+		if !room.HasHumans() {
+			s.deleteRoom(code, model.RoomReasonEmpty)
+		}
+	}
+	s.roomsLock.Unlock()
+
+	for _, e := range expired {
+		s.events.Publish(e)
+	}
+	if len(expired) > 0 {
+		s.logger.WithField("members", len(expired)).Info("Removed restored room members that did not reconnect")
+	}
+}
+
+// ChatSnapshotStore persists the chat snapshot
+type ChatSnapshotStore interface {
+	// Load returns the saved snapshot, or nil when there is none
+	Load() (*model.ChatSnapshot, error)
+	Save(snapshot *model.ChatSnapshot) error
+}
+
+type fileSnapshotStore struct {
+	path string
+}
+
+// NewFileSnapshotStore stores the snapshot as JSON in a file, replaced atomically on save
+func NewFileSnapshotStore(path string) ChatSnapshotStore {
+	return &fileSnapshotStore{path: path}
+}
+
+func (f *fileSnapshotStore) Load() (*model.ChatSnapshot, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot model.ChatSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode chat snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+func (f *fileSnapshotStore) Save(snapshot *model.ChatSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// ChatSnapshotter saves the chat service state periodically and restores it on startup,
+// so rooms and the queue survive deploys
+type ChatSnapshotter struct {
+	chat   ChatService
+	store  ChatSnapshotStore
+	config config.SnapshotConfig
+	logger *logrus.Logger
+}
+
+func NewChatSnapshotter(chat ChatService, store ChatSnapshotStore, cfg config.SnapshotConfig, logger *logrus.Logger) *ChatSnapshotter {
+	return &ChatSnapshotter{chat: chat, store: store, config: cfg, logger: logger}
+}
+
+// Restore loads the saved snapshot into the chat service unless it is older than max_age.
+// It must run after the event subscribers are registered, since bots are attached through events.
+func (c *ChatSnapshotter) Restore() {
+	snapshot, err := c.store.Load()
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to load chat snapshot")
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+
+	age := time.Since(snapshot.TakenAt)
+	if age > c.config.MaxAge {
+		c.logger.WithField("age", age).Warn("Chat snapshot too old, not restoring it")
+		return
+	}
+
+	rooms := c.chat.Restore(snapshot, c.config.RestoreGrace)
+	c.logger.WithFields(logrus.Fields{
+		"rooms": rooms,
+		"queue": len(snapshot.Queue),
+		"age":   age,
+	}).Info("Restored chat state from snapshot")
+}
+
+// Run saves a snapshot every interval until the context is cancelled
+func (c *ChatSnapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Save()
+		}
+	}
+}
+
+// Save writes the current state; it is also called on shutdown
+func (c *ChatSnapshotter) Save() {
+	if err := c.store.Save(c.chat.Snapshot()); err != nil {
+		c.logger.WithError(err).Error("Failed to save chat snapshot")
+	}
+}
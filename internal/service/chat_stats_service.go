@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ChatStatsService interface {
+	// RecordRoom adds a closed room to its members' stats, see event.RoomClosed
+	RecordRoom(summary model.RoomSummary)
+	GetUserStats(ctx context.Context, username string) (*model.ChatStats, error)
+	GetGlobalStats(ctx context.Context) (*model.GlobalChatStats, error)
+}
+
+type chatStatsService struct {
+	chatStatsRepo repository.ChatStatsRepository
+	events        *event.Bus
+	config        *config.Config
+	logger        *logrus.Logger
+}
+
+func NewChatStatsService(
+	chatStatsRepo repository.ChatStatsRepository,
+	events *event.Bus,
+	config *config.Config,
+	logger *logrus.Logger,
+) ChatStatsService {
+	return &chatStatsService{
+		chatStatsRepo: chatStatsRepo,
+		events:        events,
+		config:        config,
+		logger:        logger,
+	}
+}
+
+func (s *chatStatsService) RecordRoom(summary model.RoomSummary) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	duration := summary.Duration()
+	for _, username := range summary.Members {
+		if model.IsBotUsername(username) {
+			continue
+		}
+
+		delta := &model.ChatStats{
+			Username:      username,
+			TotalChats:    1,
+			TotalMessages: int64(summary.MessagesBy[username]),
+			TotalDuration: duration.Milliseconds(),
+		}
+		if summary.LeftFirst == username && duration < s.config.Chat.SkipThreshold {
+			delta.Skips = 1
+		}
+
+		if err := s.recordChat(ctx, delta, summary.PairedAt); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"user": username,
+				"room": summary.Code,
+			}).Error("Failed to record chat stats")
+		}
+	}
+}
+
+// recordChat applies the delta and the chat day streak, then publishes the new totals.
+// A user is only in one room at a time, so reading the previous streak does not race.
+func (s *chatStatsService) recordChat(ctx context.Context, delta *model.ChatStats, day time.Time) error {
+	previous, err := s.chatStatsRepo.GetByUsername(ctx, delta.Username)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		previous = &model.ChatStats{Username: delta.Username}
+	}
+
+	updated := *previous
+	updated.RecordChatDay(day)
+	delta.CurrentStreak = updated.CurrentStreak
+	delta.LongestStreak = updated.LongestStreak
+	delta.LastChatDay = updated.LastChatDay
+
+	if err := s.chatStatsRepo.Increment(ctx, delta); err != nil {
+		return err
+	}
+
+	updated.TotalChats += delta.TotalChats
+	updated.TotalMessages += delta.TotalMessages
+	updated.TotalDuration += delta.TotalDuration
+	updated.Skips += delta.Skips
+
+	s.events.Publish(event.ChatStatsRecorded{Stats: updated.Compute()})
+	return nil
+}
+
+// GetUserStats returns the user's chat stats, all zero before their first chat
+func (s *chatStatsService) GetUserStats(ctx context.Context, username string) (*model.ChatStats, error) {
+	stats, err := s.chatStatsRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat stats: %w", err)
+	}
+	if stats == nil {
+		stats = &model.ChatStats{Username: username}
+	}
+	return stats.Compute(), nil
+}
+
+// GetGlobalStats sums the stats of every user. Each chat counts once per member.
+func (s *chatStatsService) GetGlobalStats(ctx context.Context) (*model.GlobalChatStats, error) {
+	stats, err := s.chatStatsRepo.Aggregate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate chat stats: %w", err)
+	}
+	stats.Compute()
+	return stats, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Clock supplies the current time. Services read time through it so expiry,
+// queue timeout and cleanup behavior can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper waits for a duration, so services that slow down requests can be driven
+// without real waiting. Sleep returns early with the context's error when it is done.
+type Sleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// CodeGenerator produces the random identifiers handed out by services
+type CodeGenerator interface {
+	// RoomCode returns an uppercase alphanumeric room code of length n
+	RoomCode(n int) string
+	// Token returns an opaque URL-safe token such as a refresh token
+	Token() (string, error)
+	// Digits returns a numeric code of length n, such as an emailed verification code
+	Digits(n int) string
+	// Index returns a random index in [0, n), such as for picking a prompt
+	Index(n int) int
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+type timerSleeper struct{}
+
+func (timerSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type randomCodeGenerator struct{}
+
+func (randomCodeGenerator) RoomCode(n int) string {
+	bytes := make([]byte, n)
+	_, _ = rand.Read(bytes)
+	encoded := base32.StdEncoding.EncodeToString(bytes)
+	code := strings.ToUpper(strings.TrimRight(encoded, "="))
+	if len(code) >= n {
+		return code[:n]
+	}
+	return code
+}
+
+func (randomCodeGenerator) Digits(n int) string {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			panic(fmt.Sprintf("crypto/rand failed: %v", err))
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	return string(digits)
+}
+
+func (randomCodeGenerator) Index(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return int(i.Int64())
+}
+
+func (randomCodeGenerator) Token() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(bytes), nil
+}
+
+// Option overrides a default dependency of a service
+type Option func(*serviceDeps)
+
+type serviceDeps struct {
+	clock   Clock
+	codes   CodeGenerator
+	sleeper Sleeper
+}
+
+// WithClock replaces the system clock
+func WithClock(clock Clock) Option {
+	return func(d *serviceDeps) { d.clock = clock }
+}
+
+// WithSleeper replaces the timer based sleeper
+func WithSleeper(sleeper Sleeper) Option {
+	return func(d *serviceDeps) { d.sleeper = sleeper }
+}
+
+// WithCodeGenerator replaces the crypto/rand based code generator
+func WithCodeGenerator(codes CodeGenerator) Option {
+	return func(d *serviceDeps) { d.codes = codes }
+}
+
+func newServiceDeps(opts []Option) serviceDeps {
+	deps := serviceDeps{
+		clock:   systemClock{},
+		codes:   randomCodeGenerator{},
+		sleeper: timerSleeper{},
+	}
+	for _, opt := range opts {
+		opt(&deps)
+	}
+	return deps
+}
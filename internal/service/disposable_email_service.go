@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/pkg/disposable"
+	"chatmix-backend/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DisposableFlowRegistration and DisposableFlowEmailChange label the checks in the
+	// chatmix_disposable_emails_blocked_total metric
+	DisposableFlowRegistration = "registration"
+	DisposableFlowEmailChange  = "email_change"
+
+	// disposableFetchTimeout bounds a download of auth.disposable_emails.remote_url
+	disposableFetchTimeout = 30 * time.Second
+	// maxDomainLength is the longest domain name DNS allows
+	maxDomainLength = 253
+)
+
+var (
+	ErrDisposableEmail          = errors.New("disposable email addresses are not allowed")
+	ErrDisposableDomainInvalid  = errors.New("invalid domain")
+	ErrDisposableDomainNotFound = errors.New("domain is not blocked")
+)
+
+// DisposableEmailService decides whether an email address belongs to a disposable mail
+// service, see config.DisposableConfig. Check is served from memory.
+type DisposableEmailService interface {
+	// Check returns ErrDisposableEmail when auth.disposable_emails is enabled and the domain
+	// of the email is blocked, counting the refusal under the flow
+	Check(email, flow string) error
+	// Status describes the loaded lists and returns the admin overrides
+	Status(ctx context.Context) (*DisposableStatus, error)
+	// Block adds a domain and its subdomains to the list
+	Block(ctx context.Context, domain, actor string) (*model.DisposableDomain, error)
+	// Unblock allows a domain again, overriding the built-in and remote lists if they block it
+	Unblock(ctx context.Context, domain, actor string) (*model.DisposableDomain, error)
+	// Reload loads the overrides again from the database
+	Reload(ctx context.Context) error
+	// Run fetches the remote list every auth.disposable_emails.remote_refresh and reloads
+	// the overrides every auth.disposable_emails.refresh until the context is cancelled
+	Run(ctx context.Context)
+}
+
+// DisposableStatus is the state of the disposable domain lists
+type DisposableStatus struct {
+	Enabled          bool                      `json:"enabled"`
+	BlockedDomains   int                       `json:"blocked_domains"`
+	BuiltinDomains   int                       `json:"builtin_domains"`
+	RemoteDomains    int                       `json:"remote_domains"`
+	RemoteFetchedAt  *time.Time                `json:"remote_fetched_at,omitempty"`
+	Overrides        []*model.DisposableDomain `json:"overrides"`
+	OverridesBlocked int                       `json:"overrides_blocked"`
+}
+
+type disposableEmailService struct {
+	domainRepo repository.DisposableDomainRepository
+	client     *http.Client
+	config     *config.Config
+	logger     *logrus.Logger
+	clock      Clock
+	blocked    *metrics.Counter
+
+	builtin []string
+	list    atomic.Pointer[disposable.List]
+
+	// lock guards the sources the list is compiled from
+	lock            sync.Mutex
+	remote          []string
+	remoteFetchedAt *time.Time
+	overrides       []*model.DisposableDomain
+}
+
+func NewDisposableEmailService(
+	domainRepo repository.DisposableDomainRepository,
+	registry *metrics.Registry,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) DisposableEmailService {
+	deps := newServiceDeps(opts)
+	s := &disposableEmailService{
+		domainRepo: domainRepo,
+		client:     &http.Client{Timeout: disposableFetchTimeout},
+		config:     config,
+		logger:     logger,
+		clock:      deps.clock,
+		blocked: registry.NewCounter("chatmix_disposable_emails_blocked_total",
+			"Email addresses refused because their domain is disposable.", "flow"),
+		builtin: disposable.Default(),
+	}
+	registry.NewGaugeFunc("chatmix_disposable_domains", "Domains on the disposable email list.",
+		func() float64 { return float64(s.list.Load().Len()) })
+	s.compile()
+	return s
+}
+
+func (s *disposableEmailService) Check(email, flow string) error {
+	if !s.config.Auth.DisposableEmails.Enabled || !s.list.Load().BlocksEmail(email) {
+		return nil
+	}
+	s.blocked.Inc(flow)
+	s.logger.WithFields(logrus.Fields{
+		"domain": disposable.Domain(email),
+		"flow":   flow,
+	}).Info("Disposable email address refused")
+	return ErrDisposableEmail
+}
+
+func (s *disposableEmailService) Status(ctx context.Context) (*DisposableStatus, error) {
+	overrides, err := s.domainRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disposable domains: %w", err)
+	}
+	if overrides == nil {
+		overrides = []*model.DisposableDomain{}
+	}
+
+	s.lock.Lock()
+	status := &DisposableStatus{
+		Enabled:         s.config.Auth.DisposableEmails.Enabled,
+		BlockedDomains:  s.list.Load().Len(),
+		BuiltinDomains:  len(s.builtin),
+		RemoteDomains:   len(s.remote),
+		RemoteFetchedAt: s.remoteFetchedAt,
+		Overrides:       overrides,
+	}
+	s.lock.Unlock()
+	for _, override := range overrides {
+		if override.Blocked {
+			status.OverridesBlocked++
+		}
+	}
+	return status, nil
+}
+
+func (s *disposableEmailService) Block(ctx context.Context, domain, actor string) (*model.DisposableDomain, error) {
+	domain, err := normalizeDisposableDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	return s.override(ctx, domain, true, actor)
+}
+
+func (s *disposableEmailService) Unblock(ctx context.Context, domain, actor string) (*model.DisposableDomain, error) {
+	domain, err := normalizeDisposableDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	if !s.list.Load().Blocks(domain) {
+		return nil, ErrDisposableDomainNotFound
+	}
+	return s.override(ctx, domain, false, actor)
+}
+
+func (s *disposableEmailService) override(ctx context.Context, domain string, blocked bool, actor string) (*model.DisposableDomain, error) {
+	override := &model.DisposableDomain{
+		Domain:    domain,
+		Blocked:   blocked,
+		UpdatedBy: actor,
+		UpdatedAt: s.clock.Now(),
+	}
+	if err := s.domainRepo.Upsert(ctx, override); err != nil {
+		return nil, fmt.Errorf("failed to save disposable domain: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"domain":     domain,
+		"blocked":    blocked,
+		"updated_by": actor,
+	}).Info("Disposable domain override saved")
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to reload disposable domains after a change")
+	}
+	return override, nil
+}
+
+func (s *disposableEmailService) Reload(ctx context.Context) error {
+	overrides, err := s.domainRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load disposable domains: %w", err)
+	}
+	s.lock.Lock()
+	s.overrides = overrides
+	s.lock.Unlock()
+	s.compile()
+	return nil
+}
+
+func (s *disposableEmailService) Run(ctx context.Context) {
+	cfg := s.config.Auth.DisposableEmails
+	ticker := time.NewTicker(cfg.Refresh)
+	defer ticker.Stop()
+
+	// The remote list is fetched here rather than in Reload so a slow server does not
+	// hold up startup
+	var remoteTick <-chan time.Time
+	if cfg.RemoteURL != "" {
+		s.fetchRemote(ctx)
+		remoteTicker := time.NewTicker(cfg.RemoteRefresh)
+		defer remoteTicker.Stop()
+		remoteTick = remoteTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				s.logger.WithError(err).Warn("Failed to refresh disposable domains")
+			}
+		case <-remoteTick:
+			s.fetchRemote(ctx)
+		}
+	}
+}
+
+// fetchRemote replaces the remote list; on failure the previous one is kept
+func (s *disposableEmailService) fetchRemote(ctx context.Context) {
+	url := s.config.Auth.DisposableEmails.RemoteURL
+	domains, err := disposable.Fetch(ctx, s.client, url)
+	if err != nil {
+		s.logger.WithError(err).WithField("url", url).Warn("Failed to fetch disposable domain list")
+		return
+	}
+
+	now := s.clock.Now()
+	s.lock.Lock()
+	s.remote = domains
+	s.remoteFetchedAt = &now
+	s.lock.Unlock()
+	s.compile()
+	s.logger.WithField("count", len(domains)).Info("Disposable domain list fetched")
+}
+
+// compile builds the list from the built-in and remote domains and the overrides
+func (s *disposableEmailService) compile() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	blocked := make([]string, 0, len(s.builtin)+len(s.remote)+len(s.overrides))
+	blocked = append(blocked, s.builtin...)
+	blocked = append(blocked, s.remote...)
+	var allowed []string
+	for _, override := range s.overrides {
+		if override.Blocked {
+			blocked = append(blocked, override.Domain)
+		} else {
+			allowed = append(allowed, override.Domain)
+		}
+	}
+	s.list.Store(disposable.New(blocked, allowed))
+}
+
+// normalizeDisposableDomain validates a domain sent by an admin
+func normalizeDisposableDomain(domain string) (string, error) {
+	domain = disposable.NormalizeDomain(domain)
+	if domain == "" || len(domain) > maxDomainLength || !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("%w: domain must be a name such as example.com", ErrDisposableDomainInvalid)
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", fmt.Errorf("%w: %q is not a valid domain", ErrDisposableDomainInvalid, domain)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return "", fmt.Errorf("%w: %q is not a valid domain", ErrDisposableDomainInvalid, domain)
+			}
+		}
+	}
+	return domain, nil
+}
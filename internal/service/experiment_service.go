@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxExperimentVariants bounds the variants of an experiment, which are metric labels
+	maxExperimentVariants = 5
+	// maxExperimentDescriptionLength caps the description of an experiment in characters
+	maxExperimentDescriptionLength = 500
+	// maxBotWaitSeconds caps the bot wait a variant may set
+	maxBotWaitSeconds = 600
+)
+
+// experimentNamePattern restricts experiment keys and variant names to what reads well
+// in metric labels
+var experimentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+var (
+	ErrExperimentNotFound = errors.New("experiment not found")
+	ErrExperimentExists   = errors.New("experiment key is already used")
+	ErrExperimentInvalid  = errors.New("invalid experiment")
+	ErrExperimentStopped  = errors.New("experiment is already stopped")
+)
+
+// ExperimentService defines and stops A/B experiments on the matching algorithm and
+// assigns users to their variants, see model.Experiment
+type ExperimentService interface {
+	List(ctx context.Context) ([]*model.Experiment, error)
+	Create(ctx context.Context, req model.ExperimentRequest, actor string) (*model.Experiment, error)
+	// Stop ends a running experiment; its users get the configured matching again
+	Stop(ctx context.Context, key, actor string) (*model.Experiment, error)
+	// Assign returns the variant of the user in every running experiment and the
+	// matching params of those variants, later experiments winning conflicts. Users
+	// without an ID are in no experiment.
+	Assign(userID string) (map[string]string, model.MatchingParams)
+	// Reload loads the running experiments again from the database
+	Reload(ctx context.Context) error
+	// Run reloads the experiments every chat.experiment_refresh until the context is
+	// cancelled
+	Run(ctx context.Context)
+}
+
+type experimentService struct {
+	experimentRepo repository.ExperimentRepository
+	running        atomic.Pointer[[]*model.Experiment]
+	config         *config.Config
+	logger         *logrus.Logger
+	clock          Clock
+}
+
+func NewExperimentService(
+	experimentRepo repository.ExperimentRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) ExperimentService {
+	deps := newServiceDeps(opts)
+	s := &experimentService{
+		experimentRepo: experimentRepo,
+		config:         config,
+		logger:         logger,
+		clock:          deps.clock,
+	}
+	s.running.Store(&[]*model.Experiment{})
+	return s
+}
+
+func (s *experimentService) List(ctx context.Context) ([]*model.Experiment, error) {
+	experiments, err := s.experimentRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	return experiments, nil
+}
+
+func (s *experimentService) Create(ctx context.Context, req model.ExperimentRequest, actor string) (*model.Experiment, error) {
+	if err := normalizeExperimentRequest(&req); err != nil {
+		return nil, err
+	}
+
+	experiment := &model.Experiment{
+		Key:         req.Key,
+		Description: req.Description,
+		Variants:    req.Variants,
+		Status:      model.ExperimentRunning,
+		CreatedBy:   actor,
+		CreatedAt:   s.clock.Now(),
+	}
+	if err := s.experimentRepo.Create(ctx, experiment); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrExperimentExists
+		}
+		return nil, fmt.Errorf("failed to create experiment: %w", err)
+	}
+
+	s.reloadAfterChange(ctx)
+	return experiment, nil
+}
+
+func (s *experimentService) Stop(ctx context.Context, key, actor string) (*model.Experiment, error) {
+	experiment, err := s.experimentRepo.Stop(ctx, key, actor, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop experiment: %w", err)
+	}
+	if experiment == nil {
+		existing, err := s.experimentRepo.GetByKey(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get experiment: %w", err)
+		}
+		if existing == nil {
+			return nil, ErrExperimentNotFound
+		}
+		return nil, ErrExperimentStopped
+	}
+
+	s.reloadAfterChange(ctx)
+	return experiment, nil
+}
+
+func (s *experimentService) Assign(userID string) (map[string]string, model.MatchingParams) {
+	var params model.MatchingParams
+	running := *s.running.Load()
+	if userID == "" || len(running) == 0 {
+		return nil, params
+	}
+
+	variants := make(map[string]string, len(running))
+	for _, experiment := range running {
+		if variant := experiment.Assign(userID); variant != nil {
+			variants[experiment.Key] = variant.Name
+			params = params.Merge(variant.Matching)
+		}
+	}
+	return variants, params
+}
+
+func (s *experimentService) Reload(ctx context.Context) error {
+	experiments, err := s.experimentRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load experiments: %w", err)
+	}
+
+	running := make([]*model.Experiment, 0, len(experiments))
+	for _, experiment := range experiments {
+		if experiment.IsRunning() {
+			running = append(running, experiment)
+		}
+	}
+	s.running.Store(&running)
+	return nil
+}
+
+func (s *experimentService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Chat.ExperimentRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				s.logger.WithError(err).Warn("Failed to refresh experiments")
+			}
+		}
+	}
+}
+
+// reloadAfterChange applies a change right away on this instance; other instances pick
+// it up on their next refresh
+func (s *experimentService) reloadAfterChange(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to reload experiments after a change")
+	}
+}
+
+func normalizeExperimentRequest(req *model.ExperimentRequest) error {
+	req.Key = strings.ToLower(strings.TrimSpace(req.Key))
+	req.Description = strings.TrimSpace(req.Description)
+
+	if !experimentNamePattern.MatchString(req.Key) {
+		return fmt.Errorf("%w: key must be 1-40 lowercase letters, digits, '-' or '_'", ErrExperimentInvalid)
+	}
+	if utf8.RuneCountInString(req.Description) > maxExperimentDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", ErrExperimentInvalid, maxExperimentDescriptionLength)
+	}
+	if len(req.Variants) < 2 || len(req.Variants) > maxExperimentVariants {
+		return fmt.Errorf("%w: an experiment needs 2-%d variants", ErrExperimentInvalid, maxExperimentVariants)
+	}
+
+	names := make(map[string]bool, len(req.Variants))
+	for i := range req.Variants {
+		variant := &req.Variants[i]
+		variant.Name = strings.ToLower(strings.TrimSpace(variant.Name))
+		if !experimentNamePattern.MatchString(variant.Name) {
+			return fmt.Errorf("%w: variant names must be 1-40 lowercase letters, digits, '-' or '_'", ErrExperimentInvalid)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("%w: variant %q is defined twice", ErrExperimentInvalid, variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.Weight == 0 {
+			variant.Weight = 1
+		}
+		if variant.Weight < 0 || variant.Weight > 100 {
+			return fmt.Errorf("%w: variant weights must be 1-100", ErrExperimentInvalid)
+		}
+		if variant.Matching.BotWaitSeconds < 0 || variant.Matching.BotWaitSeconds > maxBotWaitSeconds {
+			return fmt.Errorf("%w: bot_wait_seconds must be 0-%d", ErrExperimentInvalid, maxBotWaitSeconds)
+		}
+	}
+	return nil
+}
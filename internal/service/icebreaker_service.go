@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxIcebreakerLength caps the length of a prompt in characters
+const maxIcebreakerLength = 300
+
+var (
+	ErrIcebreakerNotFound = errors.New("icebreaker not found")
+	ErrIcebreakerInvalid  = errors.New("icebreaker text must be 1-300 characters")
+)
+
+type IcebreakerService interface {
+	Enabled() bool
+	List(ctx context.Context) ([]*model.Icebreaker, error)
+	Create(ctx context.Context, req model.IcebreakerRequest) (*model.Icebreaker, error)
+	Update(ctx context.Context, id string, req model.IcebreakerRequest) (*model.Icebreaker, error)
+	Delete(ctx context.Context, id string) error
+	// Pick returns a random active prompt for a room language, or nil when there is none
+	Pick(ctx context.Context, language string) (*model.Icebreaker, error)
+	RecordUse(ctx context.Context, icebreaker *model.Icebreaker)
+	// SeedDefaults stores the configured prompts when the pool is empty
+	SeedDefaults(ctx context.Context) error
+}
+
+type icebreakerService struct {
+	icebreakerRepo repository.IcebreakerRepository
+	config         *config.Config
+	logger         *logrus.Logger
+	clock          Clock
+	codes          CodeGenerator
+}
+
+func NewIcebreakerService(
+	icebreakerRepo repository.IcebreakerRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) IcebreakerService {
+	deps := newServiceDeps(opts)
+	return &icebreakerService{
+		icebreakerRepo: icebreakerRepo,
+		config:         config,
+		logger:         logger,
+		clock:          deps.clock,
+		codes:          deps.codes,
+	}
+}
+
+func (s *icebreakerService) Enabled() bool {
+	return s.config.Chat.Icebreakers.Enabled
+}
+
+func (s *icebreakerService) List(ctx context.Context) ([]*model.Icebreaker, error) {
+	icebreakers, err := s.icebreakerRepo.List(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list icebreakers: %w", err)
+	}
+	return icebreakers, nil
+}
+
+func (s *icebreakerService) Create(ctx context.Context, req model.IcebreakerRequest) (*model.Icebreaker, error) {
+	text, err := validateIcebreakerText(req.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	icebreaker := &model.Icebreaker{
+		ID:        primitive.NewObjectID(),
+		Text:      text,
+		Language:  strings.ToLower(strings.TrimSpace(req.Language)),
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.icebreakerRepo.Create(ctx, icebreaker); err != nil {
+		return nil, fmt.Errorf("failed to create icebreaker: %w", err)
+	}
+	return icebreaker, nil
+}
+
+// Update changes the text and language of a prompt, and its active flag when given
+func (s *icebreakerService) Update(ctx context.Context, id string, req model.IcebreakerRequest) (*model.Icebreaker, error) {
+	icebreaker, err := s.getIcebreaker(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := validateIcebreakerText(req.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	icebreaker.Text = text
+	icebreaker.Language = strings.ToLower(strings.TrimSpace(req.Language))
+	if req.Active != nil {
+		icebreaker.Active = *req.Active
+	}
+	if err := s.icebreakerRepo.Update(ctx, icebreaker); err != nil {
+		return nil, fmt.Errorf("failed to update icebreaker: %w", err)
+	}
+	return icebreaker, nil
+}
+
+func (s *icebreakerService) Delete(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrIcebreakerNotFound
+	}
+
+	deleted, err := s.icebreakerRepo.Delete(ctx, objectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete icebreaker: %w", err)
+	}
+	if !deleted {
+		return ErrIcebreakerNotFound
+	}
+	return nil
+}
+
+func (s *icebreakerService) Pick(ctx context.Context, language string) (*model.Icebreaker, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	icebreakers, err := s.icebreakerRepo.List(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list icebreakers: %w", err)
+	}
+
+	var candidates []*model.Icebreaker
+	for _, icebreaker := range icebreakers {
+		if icebreaker.Matches(language) {
+			candidates = append(candidates, icebreaker)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	return candidates[s.codes.Index(len(candidates))], nil
+}
+
+// RecordUse counts a prompt as sent, for usage analytics
+func (s *icebreakerService) RecordUse(ctx context.Context, icebreaker *model.Icebreaker) {
+	if err := s.icebreakerRepo.RecordUse(ctx, icebreaker.ID, s.clock.Now()); err != nil {
+		s.logger.WithError(err).WithField("icebreaker", icebreaker.ID.Hex()).Warn("Failed to record icebreaker use")
+	}
+}
+
+func (s *icebreakerService) SeedDefaults(ctx context.Context) error {
+	prompts := s.config.Chat.Icebreakers.Prompts
+	if len(prompts) == 0 {
+		return nil
+	}
+
+	count, err := s.icebreakerRepo.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count icebreakers: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	now := s.clock.Now()
+	var icebreakers []*model.Icebreaker
+	for _, prompt := range prompts {
+		text, err := validateIcebreakerText(prompt)
+		if err != nil {
+			s.logger.WithField("prompt", prompt).Warn("Skipping invalid icebreaker prompt")
+			continue
+		}
+		icebreakers = append(icebreakers, &model.Icebreaker{
+			ID:        primitive.NewObjectID(),
+			Text:      text,
+			Active:    true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	if err := s.icebreakerRepo.CreateMany(ctx, icebreakers); err != nil {
+		return fmt.Errorf("failed to seed icebreakers: %w", err)
+	}
+	s.logger.WithField("count", len(icebreakers)).Info("Seeded icebreaker prompts")
+	return nil
+}
+
+func (s *icebreakerService) getIcebreaker(ctx context.Context, id string) (*model.Icebreaker, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrIcebreakerNotFound
+	}
+
+	icebreaker, err := s.icebreakerRepo.GetByID(ctx, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get icebreaker: %w", err)
+	}
+	if icebreaker == nil {
+		return nil, ErrIcebreakerNotFound
+	}
+	return icebreaker, nil
+}
+
+func validateIcebreakerText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > maxIcebreakerLength {
+		return "", ErrIcebreakerInvalid
+	}
+	return text, nil
+}
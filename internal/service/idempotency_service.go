@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+)
+
+var (
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+	ErrIdempotencyMismatch   = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyFull       = errors.New("idempotency store is full")
+)
+
+// IdempotentResponse is a response stored for replaying to retries of a request
+type IdempotentResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyService remembers the responses of requests sent with an Idempotency-Key
+// header for server.idempotency.ttl, so a retried request gets the first response
+// instead of running twice. Keys are scoped by the caller, see handler.IdempotencyMiddleware.
+type IdempotencyService interface {
+	Enabled() bool
+	// Begin claims the key for a request with the fingerprint. It returns the stored
+	// response when the request already completed, ErrIdempotencyInProgress while it is
+	// still running and ErrIdempotencyMismatch when the key was used for another request.
+	Begin(key, fingerprint string) (*IdempotentResponse, error)
+	// Complete stores the response of a claimed key
+	Complete(key string, response *IdempotentResponse)
+	// Release forgets a claimed key, so the request can be retried
+	Release(key string)
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    *IdempotentResponse // nil while the request runs
+	expiresAt   time.Time
+}
+
+type idempotencyService struct {
+	config config.IdempotencyConfig
+	clock  Clock
+
+	lock      sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastPrune time.Time
+}
+
+func NewIdempotencyService(config *config.Config, opts ...Option) IdempotencyService {
+	deps := newServiceDeps(opts)
+	return &idempotencyService{
+		config:  config.Server.Idempotency,
+		clock:   deps.clock,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+func (s *idempotencyService) Enabled() bool {
+	return s.config.Enabled
+}
+
+func (s *idempotencyService) Begin(key, fingerprint string) (*IdempotentResponse, error) {
+	now := s.clock.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.prune(now)
+
+	if entry := s.entries[key]; entry != nil && now.Before(entry.expiresAt) {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, ErrIdempotencyMismatch
+		case entry.response == nil:
+			return nil, ErrIdempotencyInProgress
+		default:
+			return entry.response, nil
+		}
+	}
+
+	if len(s.entries) >= s.config.MaxEntries {
+		return nil, ErrIdempotencyFull
+	}
+	s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, expiresAt: now.Add(s.config.TTL)}
+	return nil, nil
+}
+
+func (s *idempotencyService) Complete(key string, response *IdempotentResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if entry := s.entries[key]; entry != nil {
+		entry.response = response
+	}
+}
+
+func (s *idempotencyService) Release(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.entries, key)
+}
+
+// prune drops expired entries at most once a minute. Must be called with lock held.
+func (s *idempotencyService) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+
+	"chatmix-backend/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// legacyKeyID identifies the single auth.jwt_secret used before key rotation was configured.
+// Tokens without a kid header are validated against it.
+const legacyKeyID = "default"
+
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for verify-only keys
+	verifyKey interface{}
+}
+
+// jwtKeyring holds the active signing key and every key still accepted for validation
+type jwtKeyring struct {
+	active *jwtKey
+	keys   map[string]*jwtKey
+}
+
+func newJWTKeyring(cfg config.AuthConfig) (*jwtKeyring, error) {
+	ring := &jwtKeyring{keys: make(map[string]*jwtKey)}
+
+	if cfg.JWTSecret != "" {
+		ring.add(hmacKey(legacyKeyID, cfg.JWTSecret))
+	}
+	for _, keyConfig := range cfg.SigningKeys {
+		key, err := loadJWTKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signing key %s: %w", keyConfig.ID, err)
+		}
+		ring.add(key)
+	}
+
+	activeID := cfg.ActiveKeyID
+	if activeID == "" {
+		activeID = legacyKeyID
+	}
+	ring.active = ring.keys[activeID]
+	if ring.active == nil || ring.active.signKey == nil {
+		return nil, fmt.Errorf("active signing key %q cannot sign tokens", activeID)
+	}
+
+	return ring, nil
+}
+
+// CheckSigningKeys loads the signing keys of the config as the auth service would,
+// returning why they cannot sign or verify tokens
+func CheckSigningKeys(cfg config.AuthConfig) error {
+	_, err := newJWTKeyring(cfg)
+	return err
+}
+
+func hmacKey(id, secret string) *jwtKey {
+	return &jwtKey{
+		id:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// loadJWTKey builds a key from config, reading PEM files for asymmetric algorithms.
+// When only the private key is given the public key is derived from it.
+func loadJWTKey(cfg config.SigningKeyConfig) (*jwtKey, error) {
+	switch cfg.GetAlgorithm() {
+	case config.AlgorithmRS256:
+		key := &jwtKey{id: cfg.ID, method: jwt.SigningMethodRS256}
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		}
+		if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+		return key, nil
+
+	case config.AlgorithmEdDSA:
+		key := &jwtKey{id: cfg.ID, method: jwt.SigningMethodEdDSA}
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = private.(crypto.Signer).Public()
+		}
+		if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+		return key, nil
+
+	default:
+		return hmacKey(cfg.ID, cfg.Secret), nil
+	}
+}
+
+func (r *jwtKeyring) add(key *jwtKey) {
+	r.keys[key.id] = key
+}
+
+// sign signs the claims with the active key and records its ID in the kid header
+func (r *jwtKeyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.id
+	return token.SignedString(r.active.signKey)
+}
+
+// keyFunc resolves the verification key from the token's kid header and rejects
+// tokens whose alg does not match the algorithm configured for that key
+func (r *jwtKeyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKeyID
+	}
+
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+// validMethods lists the algorithms of all configured keys
+func (r *jwtKeyring) validMethods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range r.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
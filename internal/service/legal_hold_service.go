@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxLegalHoldReasonLength caps the reason and case reference of a hold in characters
+const maxLegalHoldReasonLength = 500
+
+var (
+	ErrLegalHoldNotFound = errors.New("user has no legal hold")
+	ErrLegalHoldExists   = errors.New("user is already under a legal hold")
+	ErrLegalHoldInvalid  = errors.New("invalid legal hold")
+	// ErrLegalHold refuses to delete data of a user under a legal hold
+	ErrLegalHold = errors.New("data is under a legal hold")
+)
+
+// LegalHoldService places and lifts legal holds, see model.LegalHold
+type LegalHoldService interface {
+	List(ctx context.Context) ([]*model.LegalHold, error)
+	Place(ctx context.Context, username string, req model.LegalHoldRequest, actor string) (*model.LegalHold, error)
+	// Lift removes the hold of a user and returns it
+	Lift(ctx context.Context, username string) (*model.LegalHold, error)
+	// IsHeld reports whether the user is under a legal hold
+	IsHeld(ctx context.Context, username string) (bool, error)
+	// Held returns the users under a legal hold, for data cleanup to leave out
+	Held(ctx context.Context) (model.HeldUsers, error)
+}
+
+type legalHoldService struct {
+	holdRepo repository.LegalHoldRepository
+	userRepo repository.UserRepository
+	logger   *logrus.Logger
+	clock    Clock
+}
+
+func NewLegalHoldService(
+	holdRepo repository.LegalHoldRepository,
+	userRepo repository.UserRepository,
+	logger *logrus.Logger,
+	opts ...Option,
+) LegalHoldService {
+	deps := newServiceDeps(opts)
+	return &legalHoldService{
+		holdRepo: holdRepo,
+		userRepo: userRepo,
+		logger:   logger,
+		clock:    deps.clock,
+	}
+}
+
+func (s *legalHoldService) List(ctx context.Context) ([]*model.LegalHold, error) {
+	holds, err := s.holdRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list legal holds: %w", err)
+	}
+	return holds, nil
+}
+
+func (s *legalHoldService) Place(ctx context.Context, username string, req model.LegalHoldRequest, actor string) (*model.LegalHold, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.CaseRef = strings.TrimSpace(req.CaseRef)
+	if req.Reason == "" || utf8.RuneCountInString(req.Reason) > maxLegalHoldReasonLength ||
+		utf8.RuneCountInString(req.CaseRef) > maxLegalHoldReasonLength {
+		return nil, fmt.Errorf("%w: reason must be 1-%d characters", ErrLegalHoldInvalid, maxLegalHoldReasonLength)
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	hold := &model.LegalHold{
+		UserID:   user.ID,
+		Username: user.Username,
+		Reason:   req.Reason,
+		CaseRef:  req.CaseRef,
+		PlacedBy: actor,
+		PlacedAt: s.clock.Now(),
+	}
+	if err := s.holdRepo.Create(ctx, hold); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrLegalHoldExists
+		}
+		return nil, fmt.Errorf("failed to place legal hold: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"username":  hold.Username,
+		"placed_by": actor,
+	}).Info("Legal hold placed")
+	return hold, nil
+}
+
+func (s *legalHoldService) Lift(ctx context.Context, username string) (*model.LegalHold, error) {
+	hold, err := s.holdRepo.Delete(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lift legal hold: %w", err)
+	}
+	if hold == nil {
+		return nil, ErrLegalHoldNotFound
+	}
+
+	s.logger.WithField("username", hold.Username).Info("Legal hold lifted")
+	return hold, nil
+}
+
+func (s *legalHoldService) IsHeld(ctx context.Context, username string) (bool, error) {
+	hold, err := s.holdRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to get legal hold: %w", err)
+	}
+	return hold != nil, nil
+}
+
+func (s *legalHoldService) Held(ctx context.Context) (model.HeldUsers, error) {
+	holds, err := s.List(ctx)
+	if err != nil {
+		return model.HeldUsers{}, err
+	}
+	return model.NewHeldUsers(holds), nil
+}
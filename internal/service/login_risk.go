@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/pkg/geoip"
+)
+
+// recentSessionHistory is how many past sessions a login is compared against
+const recentSessionHistory = 20
+
+// loginRisk describes how a login differs from the user's recent session history
+type loginRisk struct {
+	NewCountry bool
+	NewIP      bool
+	NewDevice  bool
+}
+
+func (r loginRisk) reasons() []string {
+	var reasons []string
+	if r.NewCountry {
+		reasons = append(reasons, "new_country")
+	}
+	if r.NewIP {
+		reasons = append(reasons, "new_ip")
+	}
+	if r.NewDevice {
+		reasons = append(reasons, "new_device")
+	}
+	return reasons
+}
+
+// requiresVerification reports whether the login is suspicious enough for step-up verification:
+// a new country, or a new IP address combined with a new device
+func (r loginRisk) requiresVerification() bool {
+	return r.NewCountry || (r.NewIP && r.NewDevice)
+}
+
+// evaluateLoginRisk compares the login with the user's recent sessions. Users without
+// any session history have nothing to compare against and are never flagged.
+func (s *authService) evaluateLoginRisk(ctx context.Context, user *model.User, ipAddress, userAgent string, loc *geoip.Location) loginRisk {
+	sessions, err := s.sessionRepo.GetRecentByUserID(ctx, user.ID, recentSessionHistory)
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID.Hex()).Error("Failed to get session history")
+		return loginRisk{}
+	}
+	if len(sessions) == 0 {
+		return loginRisk{}
+	}
+
+	risk := loginRisk{NewIP: true, NewDevice: true}
+	knownCountry := false
+	seenCountry := false
+	for _, session := range sessions {
+		if session.IPAddress == ipAddress {
+			risk.NewIP = false
+		}
+		if session.UserAgent == userAgent {
+			risk.NewDevice = false
+		}
+		if session.Country != "" {
+			seenCountry = true
+			if loc != nil && session.Country == loc.Country {
+				knownCountry = true
+			}
+		}
+	}
+	risk.NewCountry = loc != nil && seenCountry && !knownCountry
+
+	return risk
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+)
+
+// maxThrottleEntries bounds the tracked accounts and addresses. Past it, entries whose
+// failures expired are swept, then the throttleEvictBatch least recently failed ones are
+// evicted, so logins with endless unique usernames cannot grow the map.
+const (
+	maxThrottleEntries = 10000
+	throttleEvictBatch = maxThrottleEntries / 10
+)
+
+// loginFailures counts the consecutive failed logins of an account or IP address
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// loginThrottle delays logins after failures, see config.LoginThrottleConfig. Delays
+// slow down credential stuffing without locking legitimate users out.
+type loginThrottle struct {
+	cfg      config.LoginThrottleConfig
+	clock    Clock
+	sleeper  Sleeper
+	mu       sync.Mutex
+	failures map[string]*loginFailures // by throttleKeys
+}
+
+func newLoginThrottle(cfg config.LoginThrottleConfig, clock Clock, sleeper Sleeper) *loginThrottle {
+	return &loginThrottle{
+		cfg:      cfg,
+		clock:    clock,
+		sleeper:  sleeper,
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+// throttleKeys returns the keys a login attempt counts against: the account, by the
+// username or email as typed, and the client address
+func throttleKeys(login, ipAddress string) []string {
+	keys := []string{"account:" + strings.ToLower(login)}
+	if ipAddress != "" {
+		keys = append(keys, "ip:"+ipAddress)
+	}
+	return keys
+}
+
+// Delay returns how long an attempt waits: BaseDelay doubled for each failure after the
+// first of the account or address with the most recent failures, at most MaxDelay
+func (t *loginThrottle) Delay(login, ipAddress string) time.Duration {
+	if !t.cfg.Enabled {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	count := 0
+	for _, key := range throttleKeys(login, ipAddress) {
+		if failures := t.current(key, now); failures != nil {
+			count = max(count, failures.count)
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	delay := t.cfg.BaseDelay
+	for i := 1; i < count && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, t.cfg.MaxDelay)
+}
+
+// Wait sleeps for the delay of an attempt; it returns the context's error when the
+// request ends first
+func (t *loginThrottle) Wait(ctx context.Context, login, ipAddress string) error {
+	delay := t.Delay(login, ipAddress)
+	if delay == 0 {
+		return nil
+	}
+	return t.sleeper.Sleep(ctx, delay)
+}
+
+// Fail records a failed attempt against the account and the address
+func (t *loginThrottle) Fail(login, ipAddress string) {
+	if !t.cfg.Enabled {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	if len(t.failures) >= maxThrottleEntries {
+		for key := range t.failures {
+			t.current(key, now)
+		}
+	}
+	if len(t.failures) >= maxThrottleEntries {
+		t.evictOldest(len(t.failures) - maxThrottleEntries + throttleEvictBatch)
+	}
+	for _, key := range throttleKeys(login, ipAddress) {
+		failures := t.current(key, now)
+		if failures == nil {
+			failures = &loginFailures{}
+			t.failures[key] = failures
+		}
+		failures.count++
+		failures.last = now
+	}
+}
+
+// Succeed clears the failures of the account. The address keeps its count, so one
+// valid account does not reset a credential stuffing run.
+func (t *loginThrottle) Succeed(login string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, throttleKeys(login, "")[0])
+}
+
+// evictOldest drops the n entries with the oldest last failure. Evicting in batches
+// keeps the sort off most failed logins. The lock must be held.
+func (t *loginThrottle) evictOldest(n int) {
+	keys := make([]string, 0, len(t.failures))
+	for key := range t.failures {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return t.failures[keys[i]].last.Before(t.failures[keys[j]].last) })
+	for _, key := range keys[:min(n, len(keys))] {
+		delete(t.failures, key)
+	}
+}
+
+// current returns the failures of a key, dropping them once they are older than
+// ResetAfter. The lock must be held.
+func (t *loginThrottle) current(key string, now time.Time) *loginFailures {
+	failures := t.failures[key]
+	if failures != nil && now.Sub(failures.last) >= t.cfg.ResetAfter {
+		delete(t.failures, key)
+		return nil
+	}
+	return failures
+}
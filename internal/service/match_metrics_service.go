@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxMatchStatsDays is the most days of rollups DailyStats returns
+const MaxMatchStatsDays = 90
+
+// MatchMetricsService measures the quality of matchmaking from the events of the chat
+// service: how long users wait for a partner, how many chats last, how many are skipped
+// and how many users give up looking, per preference bucket. It exports the measures to
+// Prometheus and adds them to daily rollups, so the matching can be tuned with data.
+// Users in matching experiments are also counted per experiment and variant, in
+// Prometheus only.
+type MatchMetricsService interface {
+	RecordMatch(e event.MatchMade)
+	RecordAbandon(e event.MatchAbandoned)
+	// RecordRoom measures a closed room, see event.RoomClosed
+	RecordRoom(summary model.RoomSummary)
+	// DailyStats returns the rollups of the last days, today included, per day and bucket,
+	// with an "all" bucket summing each day
+	DailyStats(ctx context.Context, days int) ([]*model.MatchStats, error)
+	// Run adds the counters to the daily rollups every metrics.flush_interval until the
+	// context is cancelled
+	Run(ctx context.Context)
+	// Flush adds the counters to the daily rollups; it is also called on shutdown
+	Flush(ctx context.Context)
+}
+
+type matchStatsKey struct {
+	day    string
+	bucket string
+}
+
+type matchMetricsService struct {
+	matchStatsRepo repository.MatchStatsRepository
+	config         *config.Config
+	logger         *logrus.Logger
+	clock          Clock
+
+	waits     *metrics.Histogram
+	abandoned *metrics.Counter
+	chats     *metrics.Counter
+	longChats *metrics.Counter
+	skips     *metrics.Counter
+
+	// The same measures per experiment and variant, see model.Experiment
+	experimentWaits     *metrics.Histogram
+	experimentAbandoned *metrics.Counter
+	experimentChats     *metrics.Counter
+	experimentLongChats *metrics.Counter
+	experimentSkips     *metrics.Counter
+
+	lock    sync.Mutex
+	pending map[matchStatsKey]*model.MatchStats
+}
+
+func NewMatchMetricsService(
+	matchStatsRepo repository.MatchStatsRepository,
+	registry *metrics.Registry,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) MatchMetricsService {
+	deps := newServiceDeps(opts)
+	return &matchMetricsService{
+		matchStatsRepo: matchStatsRepo,
+		config:         config,
+		logger:         logger,
+		clock:          deps.clock,
+		waits: registry.NewHistogram("chatmix_match_wait_seconds",
+			"Time from looking for a partner until getting one.", metrics.DefaultBuckets, "bucket", "partner"),
+		abandoned: registry.NewCounter("chatmix_match_abandoned_total",
+			"Users who stopped looking for a partner before getting one.", "bucket", "reason"),
+		chats: registry.NewCounter("chatmix_chats_total",
+			"Closed chats between two people, counted for each member in the member's bucket.", "bucket"),
+		longChats: registry.NewCounter("chatmix_long_chats_total",
+			"Closed chats that lasted at least metrics.long_chat.", "bucket"),
+		skips: registry.NewCounter("chatmix_chat_skips_total",
+			"Chats the member left within chat.skip_threshold.", "bucket"),
+		experimentWaits: registry.NewHistogram("chatmix_experiment_match_wait_seconds",
+			"Time from looking for a partner until getting one, per experiment variant.", metrics.DefaultBuckets,
+			"experiment", "variant", "partner"),
+		experimentAbandoned: registry.NewCounter("chatmix_experiment_match_abandoned_total",
+			"Users who stopped looking for a partner before getting one, per experiment variant.", "experiment", "variant"),
+		experimentChats: registry.NewCounter("chatmix_experiment_chats_total",
+			"Closed chats between two people, counted for each member in an experiment.", "experiment", "variant"),
+		experimentLongChats: registry.NewCounter("chatmix_experiment_long_chats_total",
+			"Closed chats that lasted at least metrics.long_chat, per experiment variant.", "experiment", "variant"),
+		experimentSkips: registry.NewCounter("chatmix_experiment_chat_skips_total",
+			"Chats the member left within chat.skip_threshold, per experiment variant.", "experiment", "variant"),
+		pending: make(map[matchStatsKey]*model.MatchStats),
+	}
+}
+
+func (s *matchMetricsService) RecordMatch(e event.MatchMade) {
+	partner := "human"
+	if e.Bot {
+		partner = "bot"
+	}
+	s.waits.Observe(e.Wait.Seconds(), e.Bucket, partner)
+	for experiment, variant := range e.Variants {
+		s.experimentWaits.Observe(e.Wait.Seconds(), experiment, variant, partner)
+	}
+
+	s.add(e.Bucket, func(stats *model.MatchStats) {
+		stats.Matches++
+		stats.WaitMs += e.Wait.Milliseconds()
+		if e.Bot {
+			stats.BotMatches++
+		}
+	})
+}
+
+func (s *matchMetricsService) RecordAbandon(e event.MatchAbandoned) {
+	s.abandoned.Inc(e.Bucket, e.Reason)
+	for experiment, variant := range e.Variants {
+		s.experimentAbandoned.Inc(experiment, variant)
+	}
+	s.add(e.Bucket, func(stats *model.MatchStats) { stats.Abandoned++ })
+}
+
+func (s *matchMetricsService) RecordRoom(summary model.RoomSummary) {
+	// Chats with a bot say nothing about the matching
+	if summary.Bot {
+		return
+	}
+
+	duration := summary.Duration()
+	long := duration >= s.config.Metrics.LongChat
+	for _, username := range summary.Members {
+		bucket := summary.Buckets[username]
+		if bucket == "" {
+			bucket = model.BucketOpen
+		}
+		skipped := summary.LeftFirst == username && duration < s.config.Chat.SkipThreshold
+
+		s.chats.Inc(bucket)
+		if long {
+			s.longChats.Inc(bucket)
+		}
+		if skipped {
+			s.skips.Inc(bucket)
+		}
+		for experiment, variant := range summary.Variants[username] {
+			s.experimentChats.Inc(experiment, variant)
+			if long {
+				s.experimentLongChats.Inc(experiment, variant)
+			}
+			if skipped {
+				s.experimentSkips.Inc(experiment, variant)
+			}
+		}
+		s.add(bucket, func(stats *model.MatchStats) {
+			stats.Chats++
+			if long {
+				stats.LongChats++
+			}
+			if skipped {
+				stats.Skips++
+			}
+		})
+	}
+}
+
+// add applies a change to the pending counters of today and the bucket
+func (s *matchMetricsService) add(bucket string, change func(stats *model.MatchStats)) {
+	key := matchStatsKey{day: model.UsageDay(s.clock.Now()), bucket: bucket}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats, ok := s.pending[key]
+	if !ok {
+		stats = &model.MatchStats{Day: key.day, Bucket: key.bucket}
+		s.pending[key] = stats
+	}
+	change(stats)
+}
+
+func (s *matchMetricsService) DailyStats(ctx context.Context, days int) ([]*model.MatchStats, error) {
+	days = min(max(days, 1), MaxMatchStatsDays)
+	since := model.UsageDay(s.clock.Now().AddDate(0, 0, -(days - 1)))
+
+	stats, err := s.matchStatsRepo.ListSince(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list match stats: %w", err)
+	}
+
+	// Counters not flushed yet belong in the rollups too
+	s.lock.Lock()
+	for _, pending := range s.pending {
+		if pending.Day < since {
+			continue
+		}
+		found := false
+		for _, stored := range stats {
+			if stored.Day == pending.Day && stored.Bucket == pending.Bucket {
+				stored.Add(pending)
+				found = true
+				break
+			}
+		}
+		if !found {
+			copied := *pending
+			stats = append(stats, &copied)
+		}
+	}
+	s.lock.Unlock()
+
+	totals := make(map[string]*model.MatchStats)
+	for _, day := range stats {
+		total, ok := totals[day.Day]
+		if !ok {
+			total = &model.MatchStats{Day: day.Day, Bucket: "all"}
+			totals[day.Day] = total
+		}
+		total.Add(day)
+		if day.UpdatedAt.After(total.UpdatedAt) {
+			total.UpdatedAt = day.UpdatedAt
+		}
+	}
+	for _, total := range totals {
+		stats = append(stats, total)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Day != stats[j].Day {
+			return stats[i].Day < stats[j].Day
+		}
+		return stats[i].Bucket < stats[j].Bucket
+	})
+	for _, day := range stats {
+		day.Compute()
+	}
+	return stats, nil
+}
+
+func (s *matchMetricsService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Metrics.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Flush(ctx)
+		}
+	}
+}
+
+func (s *matchMetricsService) Flush(ctx context.Context) {
+	s.lock.Lock()
+	pending := s.pending
+	s.pending = make(map[matchStatsKey]*model.MatchStats)
+	s.lock.Unlock()
+
+	for key, stats := range pending {
+		if err := s.matchStatsRepo.Increment(ctx, stats); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"day":    key.day,
+				"bucket": key.bucket,
+			}).Error("Failed to write match stats")
+		}
+	}
+}
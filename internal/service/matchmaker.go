@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// minMatchmakerWait keeps the matchmaker from spinning on a bot deadline that has passed
+// but could not be acted on
+const minMatchmakerWait = time.Second
+
+// signalMatchmaker wakes the matchmaker after a change that may let a queued user in: a
+// member left, a room closed, the queue or the room limit changed. It never blocks; a
+// pending signal already covers this one.
+func (s *chatService) signalMatchmaker() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runMatchmaker assigns rooms to queued users until ctx is done. It runs when signaled
+// and when the next waiting user is due a bot partner, instead of polling.
+func (s *chatService) runMatchmaker(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		s.tryAssignQueuedUsers()
+		now := s.clock.Now()
+		s.pairWithBots(now)
+
+		timer.Stop()
+		if wait, ok := s.nextBotDeadline(now); ok {
+			timer.Reset(max(wait, minMatchmakerWait))
+		}
+	}
+}
+
+// nextBotDeadline returns how long until the next queued user or waiting room member is
+// due a bot partner, see pairWithBots. It reports false when no bot can join.
+func (s *chatService) nextBotDeadline(now time.Time) (time.Duration, bool) {
+	botConfig := s.chatConfig().Bot
+	if !botConfig.Enabled {
+		return 0, false
+	}
+
+	s.queueLock.RLock()
+	defer s.queueLock.RUnlock()
+
+	s.roomsLock.RLock()
+	defer s.roomsLock.RUnlock()
+
+	botRooms := 0
+	for _, room := range s.rooms {
+		if room.HasBot() {
+			botRooms++
+		}
+	}
+	// A closing bot room signals the matchmaker
+	if botRooms >= botConfig.MaxRooms {
+		return 0, false
+	}
+
+	var next time.Time
+	earlier := func(deadline time.Time) {
+		if next.IsZero() || deadline.Before(next) {
+			next = deadline
+		}
+	}
+	for _, room := range s.rooms {
+		if room.IsWaiting() && !room.IsPaired() {
+			earlier(room.UpdatedAt.Add(botWaitThreshold(botConfig, room.Preferences[room.Users[0]])))
+		}
+	}
+	for _, entry := range s.queue {
+		earlier(entry.QueuedAt.Add(botWaitThreshold(botConfig, entry.Preferences)))
+	}
+
+	if next.IsZero() {
+		return 0, false
+	}
+	return next.Sub(now), true
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/event"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/pkg/profanity"
+	"chatmix-backend/pkg/sanitize"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrMessageNotEditable = errors.New("message can no longer be changed")
+	ErrMessageEmpty       = errors.New("message is empty")
+	ErrMessageTooLong     = errors.New("message is too long")
+	ErrMessageBlocked     = errors.New("message contains blocked words")
+)
+
+// frameEnvelopeSize is the room left in a WebSocket frame for the JSON envelope around the text
+const frameEnvelopeSize = 512
+
+type MessageService interface {
+	// SaveMessage and EditMessage apply the room's content policy, see model.ChatRoom.ContentPolicy
+	SaveMessage(ctx context.Context, roomCode, from, text string, policy model.ContentPolicy) (*model.Message, error)
+	EditMessage(ctx context.Context, roomCode, messageID, username, text string, policy model.ContentPolicy) (*model.Message, error)
+	DeleteMessage(ctx context.Context, roomCode, messageID, username string) (*model.Message, error)
+	GetRoomHistory(ctx context.Context, roomCode string) ([]*model.Message, error)
+	// GetReplay returns the messages replayed to a member joining the room, see config.ReplayConfig
+	GetReplay(ctx context.Context, room *model.ChatRoom) ([]*model.Message, error)
+	HasParticipated(ctx context.Context, roomCode, username string) (bool, error)
+	// DeleteOwnMessages erases the messages a user sent in a room, or in every room when
+	// roomCode is empty, revisions included. It returns how many were erased, or
+	// ErrLegalHold when the user is under a legal hold.
+	DeleteOwnMessages(ctx context.Context, username, roomCode string) (int64, error)
+	// GetRevisions returns a message with the versions replaced by edits and deletion, for moderators
+	GetRevisions(ctx context.Context, messageID string) (*model.Message, []model.MessageRevision, error)
+	// SpamStats returns the spam detection counters, see config.SpamConfig
+	SpamStats() SpamStats
+	// MaxFrameSize is the WebSocket read limit that fits a message of the maximum length
+	MaxFrameSize() int64
+}
+
+type messageService struct {
+	messageRepo repository.MessageRepository
+	sanitizer   *sanitize.Sanitizer
+	profanity   *profanity.Filter
+	blocklist   BlocklistService
+	legalHolds  LegalHoldService
+	spam        *spamScreen // nil when spam detection is disabled
+	events      *event.Bus
+	config      *config.Config
+	logger      *logrus.Logger
+}
+
+func NewMessageService(
+	messageRepo repository.MessageRepository,
+	blocklist BlocklistService,
+	legalHolds LegalHoldService,
+	events *event.Bus,
+	config *config.Config,
+	logger *logrus.Logger,
+) MessageService {
+	return &messageService{
+		messageRepo: messageRepo,
+		sanitizer: sanitize.New(sanitize.Options{
+			MaxRunes: config.Chat.MaxMessageLength,
+			MaxLines: config.Chat.MaxMessageLines,
+		}),
+		profanity:  newProfanityFilter(config.Chat.ProfanityWords),
+		blocklist:  blocklist,
+		legalHolds: legalHolds,
+		spam:       newSpamScreen(config.Chat.Spam),
+		events:     events,
+		config:     config,
+		logger:     logger,
+	}
+}
+
+func newProfanityFilter(words []string) *profanity.Filter {
+	if len(words) == 0 {
+		words = profanity.DefaultWords
+	}
+	return profanity.New(words)
+}
+
+// SaveMessage sanitizes and stores a message. A message that fails to persist is still
+// returned so it can be delivered.
+func (s *messageService) SaveMessage(ctx context.Context, roomCode, from, text string, policy model.ContentPolicy) (*model.Message, error) {
+	text, err := s.clean(text)
+	if err != nil {
+		return nil, err
+	}
+	text, flagged, err := s.moderate(text, policy)
+	if err != nil {
+		return nil, err
+	}
+	verdict := s.screenSpam(from, text, false)
+	if verdict.action == config.SpamActionBlock {
+		return nil, ErrMessageSpam
+	}
+
+	message := model.NewMessage(roomCode, from, text)
+	message.Flagged = flagged || verdict.action != ""
+	message.Spam = verdict.reasons
+	message.Shadowed = verdict.action == config.SpamActionShadowLimit
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		s.logger.WithError(err).WithField("room", roomCode).Error("Failed to save message")
+		return message, fmt.Errorf("failed to save message: %w", err)
+	}
+	s.events.Publish(event.MessageSent{Message: message})
+	return message, nil
+}
+
+// EditMessage replaces the text of a message sent by the user within the edit window
+func (s *messageService) EditMessage(ctx context.Context, roomCode, messageID, username, text string, policy model.ContentPolicy) (*model.Message, error) {
+	text, err := s.clean(text)
+	if err != nil {
+		return nil, err
+	}
+	text, flagged, err := s.moderate(text, policy)
+	if err != nil {
+		return nil, err
+	}
+	// Edits cannot be hidden once delivered, so shadow limiting only flags them
+	verdict := s.screenSpam(username, text, true)
+	if verdict.action == config.SpamActionBlock {
+		return nil, ErrMessageSpam
+	}
+
+	message, err := s.getEditableMessage(ctx, roomCode, messageID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.messageRepo.AddRevision(ctx, message.ID, message.Edit(text)); err != nil {
+		return nil, fmt.Errorf("failed to store message revision: %w", err)
+	}
+	message.Flagged = flagged || verdict.action != ""
+	message.Spam = verdict.reasons
+	if err := s.messageRepo.Update(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+
+	return message, nil
+}
+
+// DeleteMessage marks a message sent by the user within the edit window as deleted
+func (s *messageService) DeleteMessage(ctx context.Context, roomCode, messageID, username string) (*model.Message, error) {
+	message, err := s.getEditableMessage(ctx, roomCode, messageID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.messageRepo.AddRevision(ctx, message.ID, message.MarkDeleted()); err != nil {
+		return nil, fmt.Errorf("failed to store message revision: %w", err)
+	}
+	if err := s.messageRepo.Update(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	return message, nil
+}
+
+func (s *messageService) DeleteOwnMessages(ctx context.Context, username, roomCode string) (int64, error) {
+	held, err := s.legalHolds.IsHeld(ctx, username)
+	if err != nil {
+		return 0, err
+	}
+	if held {
+		return 0, ErrLegalHold
+	}
+
+	deleted, err := s.messageRepo.DeleteBySender(ctx, username, roomCode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user":    username,
+		"room":    roomCode,
+		"deleted": deleted,
+	}).Info("User deleted their messages")
+	return deleted, nil
+}
+
+// screenSpam runs spam detection on the text of a user; bots are trusted
+func (s *messageService) screenSpam(from, text string, edit bool) spamVerdict {
+	if model.IsBotUsername(from) {
+		return spamVerdict{}
+	}
+	return s.spam.screen(from, text, edit)
+}
+
+func (s *messageService) SpamStats() SpamStats {
+	return s.spam.snapshot()
+}
+
+func (s *messageService) MaxFrameSize() int64 {
+	return int64(s.config.Chat.MaxMessageLength*utf8.UTFMax + frameEnvelopeSize)
+}
+
+// clean runs the sanitation pipeline and maps its result to message errors
+func (s *messageService) clean(text string) (string, error) {
+	cleaned, err := s.sanitizer.Clean(text)
+	if errors.Is(err, sanitize.ErrTooLong) {
+		return "", fmt.Errorf("%w (max %d characters)", ErrMessageTooLong, s.config.Chat.MaxMessageLength)
+	}
+	if err != nil {
+		return "", err
+	}
+	if cleaned == "" {
+		return "", ErrMessageEmpty
+	}
+	return cleaned, nil
+}
+
+// moderate applies a content filter level: strict rejects profanity, medium masks it
+// and flags the message so clients can blur it, off leaves the text unchanged.
+// Blocklist entries apply by severity: high ones are rejected at every level, medium
+// ones are treated like profanity, and low ones are only rejected in strict rooms.
+func (s *messageService) moderate(text string, policy model.ContentPolicy) (string, bool, error) {
+	rules := s.blocklist.Rules()
+	if rules.Contains(text, policy.Language, model.BlocklistSeverityHigh) {
+		return "", false, ErrMessageBlocked
+	}
+
+	switch policy.Filter {
+	case model.ContentFilterOff:
+		return text, false, nil
+	case model.ContentFilterStrict:
+		if s.profanity.Contains(text) ||
+			rules.Contains(text, policy.Language, model.BlocklistSeverityMedium, model.BlocklistSeverityLow) {
+			return "", false, ErrMessageBlocked
+		}
+		return text, false, nil
+	default:
+		masked, flagged := s.profanity.Mask(text)
+		masked, blocked := rules.Mask(masked, policy.Language, model.BlocklistSeverityMedium)
+		return masked, flagged || blocked, nil
+	}
+}
+
+func (s *messageService) GetRoomHistory(ctx context.Context, roomCode string) ([]*model.Message, error) {
+	messages, err := s.messageRepo.GetByRoom(ctx, roomCode, s.config.Chat.HistoryLimit)
+	if err != nil {
+		s.logger.WithError(err).WithField("room", roomCode).Error("Failed to get room history")
+		return nil, fmt.Errorf("failed to get room history: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *messageService) GetReplay(ctx context.Context, room *model.ChatRoom) ([]*model.Message, error) {
+	replay := s.config.Chat.Replay
+	if !replay.Enabled || room.MessageCount == 0 {
+		return nil, nil
+	}
+	if !replay.IncludeWaiting && !room.IsPaired() {
+		return nil, nil
+	}
+
+	messages, err := s.messageRepo.GetByRoom(ctx, room.Code, replay.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room replay: %w", err)
+	}
+
+	replayed := messages[:0]
+	for _, message := range messages {
+		if message.IsDeleted || (!replay.IncludeWaiting && message.CreatedAt.Before(room.PairedAt)) {
+			continue
+		}
+		replayed = append(replayed, message)
+	}
+	return replayed, nil
+}
+
+func (s *messageService) HasParticipated(ctx context.Context, roomCode, username string) (bool, error) {
+	return s.messageRepo.HasSender(ctx, roomCode, username)
+}
+
+func (s *messageService) GetRevisions(ctx context.Context, messageID string) (*model.Message, []model.MessageRevision, error) {
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, nil, ErrMessageNotFound
+	}
+
+	message, err := s.messageRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if message == nil {
+		return nil, nil, ErrMessageNotFound
+	}
+
+	revisions, err := s.messageRepo.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get message revisions: %w", err)
+	}
+	return message, revisions, nil
+}
+
+func (s *messageService) getEditableMessage(ctx context.Context, roomCode, messageID, username string) (*model.Message, error) {
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+
+	message, err := s.messageRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if message == nil || message.RoomCode != roomCode {
+		return nil, ErrMessageNotFound
+	}
+
+	if !message.IsEditableBy(username, s.config.Chat.EditWindow) {
+		return nil, ErrMessageNotEditable
+	}
+
+	return message, nil
+}
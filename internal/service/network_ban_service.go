@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxDeviceFingerprintLength caps a banned device fingerprint in characters
+	maxDeviceFingerprintLength = 256
+	// maxNetworkBanReasonLength caps the reason of a ban in characters
+	maxNetworkBanReasonLength = 500
+	// minIPv4BanBits and minIPv6BanBits refuse ranges so wide they would lock out whole
+	// regions or every user
+	minIPv4BanBits = 8
+	minIPv6BanBits = 32
+)
+
+var (
+	ErrNetworkBanNotFound = errors.New("network ban not found")
+	ErrNetworkBanInvalid  = errors.New("invalid network ban")
+	ErrNetworkBanExists   = errors.New("network ban already exists")
+)
+
+// NetworkBanService manages the IP and device bans, see model.NetworkBan. Check is served
+// from memory and is cheap enough to call on every guarded request.
+type NetworkBanService interface {
+	List(ctx context.Context) ([]*model.NetworkBan, error)
+	Create(ctx context.Context, req model.NetworkBanRequest, actor string) (*model.NetworkBan, error)
+	// Update sets the reason and expiry of a ban
+	Update(ctx context.Context, id string, req model.NetworkBanRequest, actor string) (*model.NetworkBan, error)
+	// Delete removes a ban and returns it
+	Delete(ctx context.Context, id string) (*model.NetworkBan, error)
+	// Check returns a ban in force for the client address or device fingerprint, or nil.
+	// The address may carry a port; an empty fingerprint is not checked.
+	Check(ip, device string) *model.NetworkBan
+	// Reload loads the bans again from the database
+	Reload(ctx context.Context) error
+	// Run deletes expired bans and reloads the rest every auth.network_bans.refresh until
+	// the context is cancelled
+	Run(ctx context.Context)
+}
+
+type networkBanService struct {
+	banRepo repository.NetworkBanRepository
+	bans    atomic.Pointer[networkBans]
+	config  *config.Config
+	logger  *logrus.Logger
+	clock   Clock
+}
+
+func NewNetworkBanService(
+	banRepo repository.NetworkBanRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) NetworkBanService {
+	deps := newServiceDeps(opts)
+	s := &networkBanService{
+		banRepo: banRepo,
+		config:  config,
+		logger:  logger,
+		clock:   deps.clock,
+	}
+	s.bans.Store(compileNetworkBans(nil))
+	return s
+}
+
+func (s *networkBanService) List(ctx context.Context) ([]*model.NetworkBan, error) {
+	bans, err := s.banRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network bans: %w", err)
+	}
+	return bans, nil
+}
+
+func (s *networkBanService) Create(ctx context.Context, req model.NetworkBanRequest, actor string) (*model.NetworkBan, error) {
+	value, err := normalizeNetworkBanValue(req.Type, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeNetworkBanRequest(&req); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	ban := &model.NetworkBan{
+		ID:        primitive.NewObjectID(),
+		Type:      req.Type,
+		Value:     value,
+		Reason:    req.Reason,
+		CreatedBy: actor,
+		UpdatedBy: actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: banExpiry(now, req.TTLMinutes),
+	}
+	if err := s.banRepo.Create(ctx, ban); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrNetworkBanExists
+		}
+		return nil, fmt.Errorf("failed to create network ban: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"type":       ban.Type,
+		"value":      ban.Value,
+		"created_by": actor,
+	}).Info("Network ban created")
+	s.reloadAfterChange(ctx)
+	return ban, nil
+}
+
+func (s *networkBanService) Update(ctx context.Context, id string, req model.NetworkBanRequest, actor string) (*model.NetworkBan, error) {
+	ban, err := s.getBan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeNetworkBanRequest(&req); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	ban.Reason = req.Reason
+	ban.ExpiresAt = banExpiry(now, req.TTLMinutes)
+	ban.UpdatedBy = actor
+	ban.UpdatedAt = now
+
+	updated, err := s.banRepo.Update(ctx, ban)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update network ban: %w", err)
+	}
+	if !updated {
+		return nil, ErrNetworkBanNotFound
+	}
+
+	s.reloadAfterChange(ctx)
+	return ban, nil
+}
+
+func (s *networkBanService) Delete(ctx context.Context, id string) (*model.NetworkBan, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNetworkBanNotFound
+	}
+
+	ban, err := s.banRepo.Delete(ctx, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete network ban: %w", err)
+	}
+	if ban == nil {
+		return nil, ErrNetworkBanNotFound
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"type":  ban.Type,
+		"value": ban.Value,
+	}).Info("Network ban deleted")
+	s.reloadAfterChange(ctx)
+	return ban, nil
+}
+
+func (s *networkBanService) Check(ip, device string) *model.NetworkBan {
+	return s.bans.Load().match(ip, device, s.clock.Now())
+}
+
+func (s *networkBanService) Reload(ctx context.Context) error {
+	bans, err := s.banRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load network bans: %w", err)
+	}
+	s.bans.Store(compileNetworkBans(bans))
+	return nil
+}
+
+func (s *networkBanService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Auth.NetworkBans.Refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if deleted, err := s.banRepo.DeleteExpired(ctx, s.clock.Now()); err != nil {
+				s.logger.WithError(err).Warn("Failed to delete expired network bans")
+			} else if deleted > 0 {
+				s.logger.WithField("count", deleted).Info("Expired network bans deleted")
+			}
+			if err := s.Reload(ctx); err != nil {
+				s.logger.WithError(err).Warn("Failed to refresh network bans")
+			}
+		}
+	}
+}
+
+// reloadAfterChange applies a change right away on this instance; other instances pick
+// it up on their next refresh
+func (s *networkBanService) reloadAfterChange(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to reload network bans after a change")
+	}
+}
+
+func (s *networkBanService) getBan(ctx context.Context, id string) (*model.NetworkBan, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNetworkBanNotFound
+	}
+
+	ban, err := s.banRepo.GetByID(ctx, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network ban: %w", err)
+	}
+	if ban == nil {
+		return nil, ErrNetworkBanNotFound
+	}
+	return ban, nil
+}
+
+// normalizeNetworkBanValue validates the value of a ban of the type. Addresses become
+// single-address prefixes and ranges are masked, so "10.0.0.7/8" is stored as "10.0.0.0/8".
+func normalizeNetworkBanValue(banType, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch banType {
+	case model.NetworkBanIP:
+		prefix, err := parseBanPrefix(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: value must be an IP address or CIDR range", ErrNetworkBanInvalid)
+		}
+		if (prefix.Addr().Is4() && prefix.Bits() < minIPv4BanBits) || (prefix.Addr().Is6() && prefix.Bits() < minIPv6BanBits) {
+			return "", fmt.Errorf("%w: ranges wider than /%d (IPv4) or /%d (IPv6) are not allowed",
+				ErrNetworkBanInvalid, minIPv4BanBits, minIPv6BanBits)
+		}
+		return prefix.String(), nil
+	case model.NetworkBanDevice:
+		if value == "" || utf8.RuneCountInString(value) > maxDeviceFingerprintLength {
+			return "", fmt.Errorf("%w: device fingerprint must be 1-%d characters", ErrNetworkBanInvalid, maxDeviceFingerprintLength)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("%w: type must be %q or %q", ErrNetworkBanInvalid, model.NetworkBanIP, model.NetworkBanDevice)
+	}
+}
+
+func parseBanPrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func normalizeNetworkBanRequest(req *model.NetworkBanRequest) error {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(req.Reason) > maxNetworkBanReasonLength {
+		return fmt.Errorf("%w: reason must be at most %d characters", ErrNetworkBanInvalid, maxNetworkBanReasonLength)
+	}
+	if req.TTLMinutes < 0 {
+		return fmt.Errorf("%w: ttl_minutes must not be negative", ErrNetworkBanInvalid)
+	}
+	return nil
+}
+
+// banExpiry returns the expiry of a ban with the TTL from now, nil for a permanent ban
+func banExpiry(now time.Time, ttlMinutes int) *time.Time {
+	if ttlMinutes <= 0 {
+		return nil
+	}
+	expiresAt := now.Add(time.Duration(ttlMinutes) * time.Minute)
+	return &expiresAt
+}
+
+// networkBans is the loaded bans, looked up by Check
+type networkBans struct {
+	prefixes []prefixBan
+	devices  map[string]*model.NetworkBan
+}
+
+type prefixBan struct {
+	prefix netip.Prefix
+	ban    *model.NetworkBan
+}
+
+func compileNetworkBans(bans []*model.NetworkBan) *networkBans {
+	compiled := &networkBans{devices: make(map[string]*model.NetworkBan)}
+	for _, ban := range bans {
+		switch ban.Type {
+		case model.NetworkBanIP:
+			prefix, err := netip.ParsePrefix(ban.Value)
+			if err != nil {
+				continue // stored values are normalized; skip anything edited by hand
+			}
+			compiled.prefixes = append(compiled.prefixes, prefixBan{prefix: prefix, ban: ban})
+		case model.NetworkBanDevice:
+			compiled.devices[ban.Value] = ban
+		}
+	}
+	return compiled
+}
+
+func (b *networkBans) match(ip, device string, now time.Time) *model.NetworkBan {
+	if device != "" {
+		if ban, ok := b.devices[device]; ok && ban.Active(now) {
+			return ban
+		}
+	}
+	if len(b.prefixes) == 0 {
+		return nil
+	}
+	addr, ok := parseClientAddr(ip)
+	if !ok {
+		return nil
+	}
+	for _, entry := range b.prefixes {
+		if entry.prefix.Contains(addr) && entry.ban.Active(now) {
+			return entry.ban
+		}
+	}
+	return nil
+}
+
+// parseClientAddr parses a client address, with or without a port
+func parseClientAddr(ip string) (netip.Addr, bool) {
+	ip = strings.TrimSpace(ip)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		host, _, splitErr := net.SplitHostPort(ip)
+		if splitErr != nil {
+			return netip.Addr{}, false
+		}
+		if addr, err = netip.ParseAddr(host); err != nil {
+			return netip.Addr{}, false
+		}
+	}
+	return addr.Unmap().WithZone(""), true
+}
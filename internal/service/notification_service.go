@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chatmix-backend/internal/event"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// notificationListLimit caps how many notifications a single inbox request returns
+const notificationListLimit = 50
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationService interface {
+	Notify(ctx context.Context, notification *model.Notification) error
+	Announce(ctx context.Context, title, body string) (int, error)
+	List(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]*model.Notification, error)
+	MarkRead(ctx context.Context, userID primitive.ObjectID, notificationID string) error
+	MarkAllRead(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	UnreadCount(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+	userRepo         repository.UserRepository
+	events           *event.Bus
+	logger           *logrus.Logger
+}
+
+func NewNotificationService(
+	notificationRepo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	events *event.Bus,
+	logger *logrus.Logger,
+) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		events:           events,
+		logger:           logger,
+	}
+}
+
+// Notify stores a notification and publishes it for real-time delivery
+func (s *notificationService) Notify(ctx context.Context, notification *model.Notification) error {
+	if err := s.notificationRepo.Create(ctx, notification); err != nil {
+		s.logger.WithError(err).WithField("user", notification.Username).Error("Failed to store notification")
+		return fmt.Errorf("failed to store notification: %w", err)
+	}
+
+	s.events.Publish(event.NotificationCreated{Notification: notification})
+	return nil
+}
+
+// Announce sends a system announcement to every user and returns the number of recipients
+func (s *notificationService) Announce(ctx context.Context, title, body string) (int, error) {
+	// A page of users at a time, so the announcement never holds every user in memory
+	recipients := 0
+	err := repository.ForEachPage(ctx, repository.MaxPageSize, s.userRepo.GetAllUsers, func(users []*model.User) error {
+		notifications := make([]*model.Notification, len(users))
+		for i, user := range users {
+			notifications[i] = model.NewNotification(user, model.NotificationAnnouncement, title, body)
+		}
+
+		if err := s.notificationRepo.CreateMany(ctx, notifications); err != nil {
+			s.logger.WithError(err).Error("Failed to store announcement")
+			return fmt.Errorf("failed to store announcement: %w", err)
+		}
+
+		for _, notification := range notifications {
+			s.events.Publish(event.NotificationCreated{Notification: notification})
+		}
+		recipients += len(notifications)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.WithField("recipients", recipients).Info("Announcement sent")
+	return recipients, nil
+}
+
+func (s *notificationService) List(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]*model.Notification, error) {
+	notifications, err := s.notificationRepo.GetByUser(ctx, userID, unreadOnly, notificationListLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID primitive.ObjectID, notificationID string) error {
+	id, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return ErrNotificationNotFound
+	}
+
+	found, err := s.notificationRepo.MarkRead(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification as read: %w", err)
+	}
+	if !found {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	count, err := s.notificationRepo.MarkAllRead(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications as read: %w", err)
+	}
+	return count, nil
+}
+
+func (s *notificationService) UnreadCount(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	count, err := s.notificationRepo.CountUnread(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/pkg/metrics"
+	"chatmix-backend/pkg/passhash"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// passwordBenchmarkSamples is how many hashes a benchmark times; the median is reported
+	passwordBenchmarkSamples = 3
+	// minBcryptCost and maxBcryptCost are the costs bcrypt accepts
+	minBcryptCost = 4
+	maxBcryptCost = 31
+)
+
+// passwordLatencyBuckets are histogram bounds for hashing times in seconds
+var passwordLatencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// ErrPasswordBenchmarkRunning is returned while another benchmark runs on this instance
+var ErrPasswordBenchmarkRunning = errors.New("a password benchmark is already running")
+
+// Benchmark verdicts, see PasswordBenchmark
+const (
+	PasswordLatencyOK      = "ok"
+	PasswordLatencyTooFast = "too_fast"
+	PasswordLatencyTooSlow = "too_slow"
+)
+
+// PasswordService hashes and verifies passwords with the parameters of auth.passwords,
+// recording how long each takes
+type PasswordService interface {
+	Hash(password string) (string, error)
+	// Verify checks a password against a hash of either algorithm, see passhash.Hasher
+	Verify(encoded, password string) error
+	NeedsRehash(encoded string) bool
+	// Benchmark times hashes with the configured parameters on this instance and tells
+	// whether they fall within auth.passwords.target_latency, suggesting parameters that
+	// would when they do not
+	Benchmark(ctx context.Context) (*PasswordBenchmark, error)
+}
+
+// PasswordBenchmark is the result of PasswordService.Benchmark. Times are in milliseconds.
+type PasswordBenchmark struct {
+	Algorithm  string             `json:"algorithm"`
+	Parameters PasswordParameters `json:"parameters"`
+	Samples    int                `json:"samples"`
+	MedianMs   float64            `json:"median_ms"`
+	TargetMin  float64            `json:"target_min_ms"`
+	TargetMax  float64            `json:"target_max_ms"`
+	Verdict    string             `json:"verdict"` // PasswordLatencyOK, PasswordLatencyTooFast or PasswordLatencyTooSlow
+	// Suggested are parameters expected to take about the middle of the band, with their
+	// estimated time; only set when the verdict is not ok
+	Suggested   *PasswordParameters `json:"suggested,omitempty"`
+	EstimatedMs float64             `json:"estimated_ms,omitempty"`
+	// Observed are the hashes and verifications done since the instance started
+	Observed map[string]PasswordLatency `json:"observed"`
+}
+
+// PasswordParameters are the cost parameters of the algorithm: the bcrypt cost, or the
+// Argon2id memory in KiB, iterations and parallelism
+type PasswordParameters struct {
+	BcryptCost  int    `json:"bcrypt_cost,omitempty"`
+	Memory      uint32 `json:"memory,omitempty"`
+	Iterations  uint32 `json:"iterations,omitempty"`
+	Parallelism uint8  `json:"parallelism,omitempty"`
+}
+
+// PasswordLatency is the number and mean time of password operations
+type PasswordLatency struct {
+	Count  int64   `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+}
+
+type passwordService struct {
+	hasher  passhash.Hasher
+	config  *config.Config
+	logger  *logrus.Logger
+	latency *metrics.Histogram
+
+	hashes        latencyTotals
+	verifications latencyTotals
+	benchmark     sync.Mutex
+}
+
+// latencyTotals adds up the operations of one kind for the observed means
+type latencyTotals struct {
+	count atomic.Int64
+	total atomic.Int64 // nanoseconds
+}
+
+func (t *latencyTotals) add(d time.Duration) {
+	t.count.Add(1)
+	t.total.Add(int64(d))
+}
+
+func (t *latencyTotals) snapshot() PasswordLatency {
+	count := t.count.Load()
+	if count == 0 {
+		return PasswordLatency{}
+	}
+	return PasswordLatency{Count: count, MeanMs: milliseconds(time.Duration(t.total.Load() / count))}
+}
+
+func NewPasswordService(registry *metrics.Registry, config *config.Config, logger *logrus.Logger) PasswordService {
+	return &passwordService{
+		hasher: newPasswordHasher(config.Auth.Passwords),
+		config: config,
+		logger: logger,
+		latency: registry.NewHistogram("chatmix_password_hash_seconds",
+			"Time to hash a new password or verify one against its stored hash.", passwordLatencyBuckets,
+			"operation", "algorithm"),
+	}
+}
+
+// newPasswordHasher returns the hasher of auth.passwords
+func newPasswordHasher(cfg config.PasswordsConfig) passhash.Hasher {
+	return passhash.Hasher{
+		Algorithm:  cfg.Algorithm,
+		BcryptCost: cfg.BcryptCost,
+		Argon2: passhash.Argon2Params{
+			Memory:      cfg.Argon2.Memory,
+			Iterations:  cfg.Argon2.Iterations,
+			Parallelism: cfg.Argon2.Parallelism,
+			SaltLength:  cfg.Argon2.SaltLength,
+			KeyLength:   cfg.Argon2.KeyLength,
+		},
+	}
+}
+
+func (s *passwordService) Hash(password string) (string, error) {
+	start := time.Now()
+	hash, err := s.hasher.Hash(password)
+	elapsed := time.Since(start)
+	if err == nil {
+		s.hashes.add(elapsed)
+		s.latency.Observe(elapsed.Seconds(), "hash", s.hasher.Algorithm)
+	}
+	return hash, err
+}
+
+func (s *passwordService) Verify(encoded, password string) error {
+	start := time.Now()
+	err := s.hasher.Verify(encoded, password)
+	elapsed := time.Since(start)
+	// Hashes that could not be parsed were not computed, so there is nothing to time
+	if algorithm, identifyErr := passhash.Identify(encoded); identifyErr == nil {
+		s.verifications.add(elapsed)
+		s.latency.Observe(elapsed.Seconds(), "verify", algorithm)
+	}
+	return err
+}
+
+func (s *passwordService) NeedsRehash(encoded string) bool {
+	return s.hasher.NeedsRehash(encoded)
+}
+
+func (s *passwordService) Benchmark(ctx context.Context) (*PasswordBenchmark, error) {
+	// Each benchmark keeps a core busy, so they do not run concurrently
+	if !s.benchmark.TryLock() {
+		return nil, ErrPasswordBenchmarkRunning
+	}
+	defer s.benchmark.Unlock()
+
+	random := make([]byte, 18)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	password := base64.RawURLEncoding.EncodeToString(random)
+
+	samples := make([]time.Duration, 0, passwordBenchmarkSamples)
+	for i := 0; i < passwordBenchmarkSamples; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		if _, err := s.hasher.Hash(password); err != nil {
+			return nil, err
+		}
+		samples = append(samples, time.Since(start))
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	median := samples[len(samples)/2]
+
+	band := s.config.Auth.Passwords.TargetLatency
+	result := &PasswordBenchmark{
+		Algorithm:  s.hasher.Algorithm,
+		Parameters: passwordParameters(s.hasher),
+		Samples:    len(samples),
+		MedianMs:   milliseconds(median),
+		TargetMin:  milliseconds(band.Min),
+		TargetMax:  milliseconds(band.Max),
+		Verdict:    PasswordLatencyOK,
+		Observed: map[string]PasswordLatency{
+			"hash":   s.hashes.snapshot(),
+			"verify": s.verifications.snapshot(),
+		},
+	}
+	switch {
+	case median < band.Min:
+		result.Verdict = PasswordLatencyTooFast
+	case median > band.Max:
+		result.Verdict = PasswordLatencyTooSlow
+	}
+	if result.Verdict != PasswordLatencyOK {
+		// Aim for the geometric middle of the band, as cost steps scale the time by a factor
+		target := math.Sqrt(float64(band.Min) * float64(band.Max))
+		suggested, factor := suggestPasswordParameters(s.hasher, target/float64(median))
+		result.Suggested = &suggested
+		result.EstimatedMs = result.MedianMs * factor
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"algorithm": result.Algorithm,
+		"median_ms": result.MedianMs,
+		"verdict":   result.Verdict,
+	}).Info("Password hashing benchmarked")
+	return result, nil
+}
+
+func passwordParameters(hasher passhash.Hasher) PasswordParameters {
+	if hasher.Algorithm == passhash.Argon2id {
+		return PasswordParameters{
+			Memory:      hasher.Argon2.Memory,
+			Iterations:  hasher.Argon2.Iterations,
+			Parallelism: hasher.Argon2.Parallelism,
+		}
+	}
+	return PasswordParameters{BcryptCost: hasher.BcryptCost}
+}
+
+// suggestPasswordParameters scales the cost of the hasher's parameters by about factor
+// and returns them with the factor they actually scale the time by. A bcrypt cost step
+// doubles the time; Argon2id time grows with iterations times memory, and the memory is
+// only lowered when a single iteration is still too slow.
+func suggestPasswordParameters(hasher passhash.Hasher, factor float64) (PasswordParameters, float64) {
+	if hasher.Algorithm != passhash.Argon2id {
+		steps := int(math.Round(math.Log2(factor)))
+		cost := min(max(hasher.BcryptCost+steps, minBcryptCost), maxBcryptCost)
+		return PasswordParameters{BcryptCost: cost}, math.Exp2(float64(cost - hasher.BcryptCost))
+	}
+
+	params := hasher.Argon2
+	work := float64(params.Iterations) * float64(params.Memory)
+	suggested := PasswordParameters{Memory: params.Memory, Parallelism: params.Parallelism}
+	if iterations := math.Round(float64(params.Iterations) * factor); iterations >= 1 {
+		suggested.Iterations = uint32(iterations)
+	} else {
+		suggested.Iterations = 1
+		minMemory := 8 * uint32(params.Parallelism)
+		suggested.Memory = max(uint32(work*factor), minMemory)
+	}
+	return suggested, float64(suggested.Iterations) * float64(suggested.Memory) / work
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// rehashPassword replaces the password hash of a user who just logged in when it uses
+// the algorithm that is no longer preferred or weaker parameters. Failures are only
+// logged: the old hash keeps working and the next login tries again.
+func (s *authService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	if !s.passwords.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.userRepo.ReplacePasswordHash(ctx, user.ID, user.PasswordHash, hash)
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID.Hex()).Warn("Failed to rehash password")
+		return
+	}
+	user.PasswordHash = hash
+	s.logger.WithField("user_id", user.ID.Hex()).Debug("Password rehashed")
+}
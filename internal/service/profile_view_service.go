@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProfileViewService counts profile views, one per viewer per profile per day. Views are
+// buffered and written in batches so profile requests never wait on the database.
+type ProfileViewService interface {
+	Enabled() bool
+	// Record counts a view of the profile by a user, or by a guest from the IP address
+	// when viewer is nil. Owners viewing their own profile are not counted.
+	Record(profile, viewer *model.User, ipAddress string)
+	// Stats returns the view counts of a profile
+	Stats(ctx context.Context, username string) (*model.ProfileViewStats, error)
+	// Run writes the buffered views every features.profile_views.flush_interval until the
+	// context is cancelled
+	Run(ctx context.Context)
+	// Flush writes the buffered views; it is also called on shutdown
+	Flush(ctx context.Context)
+}
+
+type profileViewKey struct {
+	username string
+	viewer   string
+	day      string
+}
+
+type profileViewService struct {
+	profileViewRepo repository.ProfileViewRepository
+	config          config.ProfileViewsConfig
+	logger          *logrus.Logger
+	clock           Clock
+
+	lock    sync.Mutex
+	pending map[profileViewKey]time.Time
+	dropped int
+}
+
+func NewProfileViewService(
+	profileViewRepo repository.ProfileViewRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) ProfileViewService {
+	deps := newServiceDeps(opts)
+	return &profileViewService{
+		profileViewRepo: profileViewRepo,
+		config:          config.Features.ProfileViews,
+		logger:          logger,
+		clock:           deps.clock,
+		pending:         make(map[profileViewKey]time.Time),
+	}
+}
+
+func (s *profileViewService) Enabled() bool {
+	return s.config.Enabled
+}
+
+func (s *profileViewService) Record(profile, viewer *model.User, ipAddress string) {
+	if !s.Enabled() || profile.HideProfileViews {
+		return
+	}
+
+	var viewerID string
+	switch {
+	case viewer != nil && viewer.ID == profile.ID:
+		return
+	case viewer != nil:
+		viewerID = viewer.Username
+	case ipAddress != "":
+		// Guests are told apart by address, which is not stored in the clear
+		sum := sha256.Sum256([]byte(ipAddress))
+		viewerID = "ip:" + hex.EncodeToString(sum[:8])
+	default:
+		return
+	}
+
+	now := s.clock.Now()
+	key := profileViewKey{username: profile.Username, viewer: viewerID, day: now.UTC().Format(model.ProfileViewDayFormat)}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, exists := s.pending[key]; exists {
+		return
+	}
+	if len(s.pending) >= s.config.MaxPending {
+		s.dropped++
+		return
+	}
+	s.pending[key] = now
+}
+
+func (s *profileViewService) Stats(ctx context.Context, username string) (*model.ProfileViewStats, error) {
+	since := s.clock.Now().UTC().AddDate(0, 0, -6).Format(model.ProfileViewDayFormat)
+	stats, err := s.profileViewRepo.Count(ctx, username, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count profile views: %w", err)
+	}
+	return stats, nil
+}
+
+func (s *profileViewService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Flush(ctx)
+		}
+	}
+}
+
+func (s *profileViewService) Flush(ctx context.Context) {
+	s.lock.Lock()
+	pending, dropped := s.pending, s.dropped
+	s.pending = make(map[profileViewKey]time.Time)
+	s.dropped = 0
+	s.lock.Unlock()
+
+	if dropped > 0 {
+		s.logger.WithField("dropped", dropped).Warn("Profile view buffer full, views were not counted")
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	views := make([]*model.ProfileView, 0, len(pending))
+	for key, viewedAt := range pending {
+		views = append(views, &model.ProfileView{
+			Username: key.username,
+			Viewer:   key.viewer,
+			Day:      key.day,
+			ViewedAt: viewedAt,
+		})
+	}
+	if err := s.profileViewRepo.AddMany(ctx, views); err != nil {
+		s.logger.WithError(err).WithField("views", len(views)).Error("Failed to store profile views")
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionService deletes the data older than the retention period of its class, see
+// config.RetentionConfig. The data of users under a legal hold is kept.
+type RetentionService interface {
+	// Run enforces the policy every retention.interval until ctx ends
+	Run(ctx context.Context)
+	// Enforce deletes the expired data of every class, or only counts it with dryRun
+	Enforce(ctx context.Context, dryRun bool) *model.RetentionReport
+}
+
+// retentionClass counts and deletes the data of a class older than a cutoff
+type retentionClass struct {
+	count  func(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+	delete func(ctx context.Context, before time.Time, held model.HeldUsers) (int64, error)
+}
+
+type retentionService struct {
+	classes          map[string]retentionClass
+	legalHoldService LegalHoldService
+	config           config.RetentionConfig
+	logger           *logrus.Logger
+	clock            Clock
+}
+
+func NewRetentionService(
+	messageRepo repository.MessageRepository,
+	auditRepo repository.AuditRepository,
+	sessionRepo repository.SessionRepository,
+	roomEventRepo repository.RoomEventRepository,
+	legalHoldService LegalHoldService,
+	retention config.RetentionConfig,
+	logger *logrus.Logger,
+	opts ...Option,
+) RetentionService {
+	deps := newServiceDeps(opts)
+	return &retentionService{
+		classes: map[string]retentionClass{
+			config.RetentionMessages:   {messageRepo.CountBefore, messageRepo.DeleteBefore},
+			config.RetentionAuditLogs:  {auditRepo.CountBefore, auditRepo.DeleteBefore},
+			config.RetentionSessions:   {sessionRepo.CountExpiredBefore, sessionRepo.DeleteExpiredBefore},
+			config.RetentionRoomEvents: {roomEventRepo.CountBefore, roomEventRepo.DeleteBefore},
+		},
+		legalHoldService: legalHoldService,
+		config:           retention,
+		logger:           logger,
+		clock:            deps.clock,
+	}
+}
+
+func (s *retentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Enforce(ctx, false)
+		}
+	}
+}
+
+func (s *retentionService) Enforce(ctx context.Context, dryRun bool) *model.RetentionReport {
+	now := s.clock.Now()
+	report := &model.RetentionReport{DryRun: dryRun, RanAt: now}
+
+	names := make([]string, 0, len(s.classes))
+	for name := range s.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Without the list of holds nothing can be deleted safely
+	held, heldErr := s.legalHoldService.Held(ctx)
+	if heldErr != nil {
+		s.logger.WithError(heldErr).Error("Failed to load legal holds, skipping retention")
+	}
+	report.HeldUsers = len(held.Usernames)
+
+	for _, name := range names {
+		period := s.config.Periods[name]
+		result := model.RetentionResult{Class: name, Period: period.String()}
+		if period > 0 {
+			cutoff := now.Add(-period)
+			result.Cutoff = &cutoff
+			if heldErr != nil {
+				result.Error = "failed to load legal holds"
+				report.Classes = append(report.Classes, result)
+				continue
+			}
+
+			run := s.classes[name].delete
+			if dryRun {
+				run = s.classes[name].count
+			}
+			count, err := run(ctx, cutoff, held)
+			result.Count = count
+			if err != nil {
+				result.Error = "failed to apply retention"
+				s.logger.WithError(err).WithField("class", name).Error("Failed to apply retention")
+			} else if count > 0 && !dryRun {
+				s.logger.WithFields(logrus.Fields{
+					"class":   name,
+					"deleted": count,
+					"cutoff":  cutoff,
+				}).Info("Deleted data past its retention period")
+			}
+		}
+		report.Classes = append(report.Classes, result)
+	}
+	return report
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RoomEventService keeps the room lifecycle log, see model.RoomEvent
+type RoomEventService interface {
+	// Record stores a lifecycle event, see event.RoomLifecycle
+	Record(e *model.RoomEvent)
+	Find(ctx context.Context, filter model.RoomEventFilter, limit int) ([]*model.RoomEvent, error)
+}
+
+type roomEventService struct {
+	roomEventRepo repository.RoomEventRepository
+	config        *config.Config
+	logger        *logrus.Logger
+}
+
+func NewRoomEventService(roomEventRepo repository.RoomEventRepository, config *config.Config, logger *logrus.Logger) RoomEventService {
+	return &roomEventService{
+		roomEventRepo: roomEventRepo,
+		config:        config,
+		logger:        logger,
+	}
+}
+
+func (s *roomEventService) Record(e *model.RoomEvent) {
+	if !s.config.Chat.RoomEvents.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.roomEventRepo.Create(ctx, e); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"room": e.RoomCode,
+			"type": e.Type,
+		}).Error("Failed to record room event")
+	}
+}
+
+func (s *roomEventService) Find(ctx context.Context, filter model.RoomEventFilter, limit int) ([]*model.RoomEvent, error) {
+	events, err := s.roomEventRepo.Find(ctx, filter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find room events: %w", err)
+	}
+	if events == nil {
+		events = []*model.RoomEvent{}
+	}
+	return events, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Load thresholds of the room limiter, as fractions of the configured ceilings
+const (
+	autoscaleShrinkAbove = 0.9
+	autoscaleGrowBelow   = 0.7
+)
+
+// LoadSignals are the runtime measurements the room limit adapts to
+type LoadSignals struct {
+	HeapBytes   uint64 `json:"heap_bytes"`
+	Goroutines  int    `json:"goroutines"`
+	Connections int    `json:"connections"`
+}
+
+// RoomLimitStatus is the effective room limit and the signals it was last adjusted to
+type RoomLimitStatus struct {
+	Limit     int         `json:"limit"`
+	Adaptive  bool        `json:"adaptive"`
+	Signals   LoadSignals `json:"signals"`
+	UpdatedAt time.Time   `json:"updated_at,omitempty"`
+}
+
+// RoomLimiter provides the room limit of the chat service. With chat.autoscale enabled
+// the limit follows the server load, otherwise it is chat.max_rooms.
+type RoomLimiter struct {
+	config *config.Provider
+	logger *logrus.Logger
+	clock  Clock
+
+	lock   sync.Mutex
+	limit  int // adapted limit, 0 until the first adjustment
+	status RoomLimitStatus
+	// raised are called after Adjust raises the limit
+	raised []func()
+}
+
+func NewRoomLimiter(cfg *config.Provider, logger *logrus.Logger, opts ...Option) *RoomLimiter {
+	deps := newServiceDeps(opts)
+	return &RoomLimiter{config: cfg, logger: logger, clock: deps.clock}
+}
+
+// Limit returns the number of rooms that may be open, bot rooms aside
+func (l *RoomLimiter) Limit() int {
+	chat := l.config.Get().Chat
+	if !chat.Autoscale.Enabled {
+		return chat.MaxRooms
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.limit == 0 {
+		return clampRooms(chat.MaxRooms, chat.Autoscale)
+	}
+	return l.limit
+}
+
+// OnRaise registers a callback invoked after the limit is raised
+func (l *RoomLimiter) OnRaise(fn func()) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.raised = append(l.raised, fn)
+}
+
+// Status returns the effective limit and the last load signals
+func (l *RoomLimiter) Status() RoomLimitStatus {
+	limit := l.Limit()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	status := l.status
+	status.Limit = limit
+	status.Adaptive = l.config.Get().Chat.Autoscale.Enabled
+	return status
+}
+
+// Run adjusts the limit every chat.autoscale.interval until the context is cancelled.
+// connections returns the number of open chat connections.
+func (l *RoomLimiter) Run(ctx context.Context, connections func() int) {
+	ticker := time.NewTicker(l.config.Get().Chat.Autoscale.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if l.config.Get().Chat.Autoscale.Enabled {
+				l.Adjust(readLoadSignals(connections))
+			}
+		}
+	}
+}
+
+// Adjust moves the limit one step according to the load signals: down by a tenth when
+// any signal is near its ceiling, up by a tenth when all are well below
+func (l *RoomLimiter) Adjust(signals LoadSignals) {
+	autoscale := l.config.Get().Chat.Autoscale
+	load := max(
+		loadRatio(float64(signals.HeapBytes), float64(autoscale.MaxHeapMB)*1024*1024),
+		loadRatio(float64(signals.Goroutines), float64(autoscale.MaxGoroutines)),
+		loadRatio(float64(signals.Connections), float64(autoscale.MaxConnections)),
+	)
+
+	current := l.Limit()
+	step := max(current/10, 1)
+	next := current
+	switch {
+	case load > autoscaleShrinkAbove:
+		next = current - step
+	case load < autoscaleGrowBelow:
+		next = current + step
+	}
+	next = clampRooms(next, autoscale)
+
+	l.lock.Lock()
+	l.limit = next
+	l.status = RoomLimitStatus{Signals: signals, UpdatedAt: l.clock.Now()}
+	raised := l.raised
+	l.lock.Unlock()
+
+	if next > current {
+		for _, fn := range raised {
+			fn()
+		}
+	}
+
+	if next != current {
+		l.logger.WithFields(logrus.Fields{
+			"limit":       next,
+			"previous":    current,
+			"load":        load,
+			"heap_bytes":  signals.HeapBytes,
+			"goroutines":  signals.Goroutines,
+			"connections": signals.Connections,
+		}).Info("Adjusted room limit")
+	}
+}
+
+func readLoadSignals(connections func() int) LoadSignals {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return LoadSignals{
+		HeapBytes:   mem.HeapAlloc,
+		Goroutines:  runtime.NumGoroutine(),
+		Connections: connections(),
+	}
+}
+
+// loadRatio returns value as a fraction of ceiling, 0 when the ceiling is not set
+func loadRatio(value, ceiling float64) float64 {
+	if ceiling <= 0 {
+		return 0
+	}
+	return value / ceiling
+}
+
+func clampRooms(limit int, autoscale config.AutoscaleConfig) int {
+	return min(max(limit, autoscale.MinRooms), autoscale.MaxRooms)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"chatmix-backend/internal/model"
+)
+
+// ErrInvalidRoomToken is returned for room tokens that are malformed, forged or expired
+var ErrInvalidRoomToken = errors.New("invalid or expired room token")
+
+// roomTokenClaims is the payload of a room token
+type roomTokenClaims struct {
+	Room      string `json:"r"`
+	Username  string `json:"u"`
+	ExpiresAt int64  `json:"exp"` // unix seconds
+}
+
+// newRoomTokenKey returns the key that signs room tokens when chat.room_token.secret is
+// empty
+func newRoomTokenKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// roomTokenKey returns the configured secret, or the key generated on startup
+func (s *chatService) roomTokenKey() []byte {
+	if secret := s.config.Get().Chat.RoomToken.Secret; secret != "" {
+		return []byte(secret)
+	}
+	return s.roomKey
+}
+
+// RoomToken returns a room token for the user's room. It is "<payload>.<signature>", the
+// base64url JSON claims and their HMAC-SHA256, valid for chat.room_token.ttl.
+func (s *chatService) RoomToken(roomCode, username string) (string, error) {
+	s.roomsLock.RLock()
+	_, exists := s.rooms[roomCode]
+	member := s.userRooms[username] == roomCode
+	s.roomsLock.RUnlock()
+
+	if !exists {
+		return "", ErrRoomNotFound
+	}
+	if !member {
+		return "", ErrNotRoomMember
+	}
+	return s.signRoomToken(roomCode, username), nil
+}
+
+// withRoomToken sets the room token of a room_assigned response
+func (s *chatService) withRoomToken(response *model.ChatStartResponse, username string) *model.ChatStartResponse {
+	if response.Status == model.ChatStatusRoomAssigned {
+		response.RoomToken = s.signRoomToken(response.RoomCode, username)
+	}
+	return response
+}
+
+func (s *chatService) signRoomToken(roomCode, username string) string {
+	payload, _ := json.Marshal(roomTokenClaims{
+		Room:      roomCode,
+		Username:  username,
+		ExpiresAt: s.clock.Now().Add(s.config.Get().Chat.RoomToken.TTL).Unix(),
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.roomTokenMAC(encoded))
+}
+
+func (s *chatService) roomTokenMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, s.roomTokenKey())
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// VerifyRoomToken checks the signature and expiry of a room token and returns the room
+// and user it binds. Membership is checked again when the user joins the room.
+func (s *chatService) VerifyRoomToken(token string) (string, string, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", "", ErrInvalidRoomToken
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decoded, s.roomTokenMAC(payload)) {
+		return "", "", ErrInvalidRoomToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", ErrInvalidRoomToken
+	}
+	var claims roomTokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.Room == "" || claims.Username == "" {
+		return "", "", ErrInvalidRoomToken
+	}
+	if !s.clock.Now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return "", "", ErrInvalidRoomToken
+	}
+	return claims.Room, claims.Username, nil
+}
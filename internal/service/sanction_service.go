@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxTimeout bounds a chat timeout; longer ones are what account bans are for
+const MaxTimeout = 7 * 24 * time.Hour
+
+// maxSanctionReasonLength caps the reason of a sanction in characters
+const maxSanctionReasonLength = 500
+
+var (
+	ErrTimeoutNotFound = errors.New("user has no running timeout")
+	ErrTimeoutInvalid  = errors.New("invalid timeout")
+	// ErrTimedOut refuses chat to a user a moderator timed out, see TimeoutError
+	ErrTimedOut = errors.New("suspended from chat")
+)
+
+// TimeoutError refuses chat to a user until the end of their timeout. It matches ErrTimedOut.
+type TimeoutError struct {
+	Until time.Time
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrTimedOut, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimedOut
+}
+
+// SanctionService times users out of chat, see model.Sanction
+type SanctionService interface {
+	// Timeout keeps the user out of matchmaking and chat rooms for req.Minutes, replacing
+	// any timeout they have. It does not remove them from their room; see ChatService.Kick.
+	Timeout(ctx context.Context, user *model.User, req model.TimeoutRequest, actor string) (*model.Sanction, error)
+	// LiftTimeout ends the running timeout of the user
+	LiftTimeout(ctx context.Context, username, actor string) error
+	// ActiveTimeout returns the running timeout of the user, or nil
+	ActiveTimeout(ctx context.Context, username string) (*model.Sanction, error)
+	// History returns every sanction of the user, newest first
+	History(ctx context.Context, username string) ([]*model.Sanction, error)
+}
+
+type sanctionService struct {
+	sanctionRepo repository.SanctionRepository
+	logger       *logrus.Logger
+	clock        Clock
+}
+
+func NewSanctionService(sanctionRepo repository.SanctionRepository, logger *logrus.Logger, opts ...Option) SanctionService {
+	deps := newServiceDeps(opts)
+	return &sanctionService{
+		sanctionRepo: sanctionRepo,
+		logger:       logger,
+		clock:        deps.clock,
+	}
+}
+
+func (s *sanctionService) Timeout(ctx context.Context, user *model.User, req model.TimeoutRequest, actor string) (*model.Sanction, error) {
+	duration := time.Duration(req.Minutes) * time.Minute
+	if duration <= 0 || duration > MaxTimeout {
+		return nil, fmt.Errorf("%w: minutes must be between 1 and %d", ErrTimeoutInvalid, int(MaxTimeout/time.Minute))
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(req.Reason) > maxSanctionReasonLength {
+		return nil, fmt.Errorf("%w: reason must be at most %d characters", ErrTimeoutInvalid, maxSanctionReasonLength)
+	}
+
+	now := s.clock.Now()
+	if _, err := s.sanctionRepo.Lift(ctx, user.Username, model.SanctionTimeout, actor, now); err != nil {
+		return nil, fmt.Errorf("failed to replace timeout: %w", err)
+	}
+	sanction := &model.Sanction{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Type:      model.SanctionTimeout,
+		Reason:    req.Reason,
+		CreatedBy: actor,
+		CreatedAt: now,
+		ExpiresAt: now.Add(duration),
+	}
+	if err := s.sanctionRepo.Create(ctx, sanction); err != nil {
+		return nil, fmt.Errorf("failed to create timeout: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"username":   sanction.Username,
+		"created_by": actor,
+		"expires_at": sanction.ExpiresAt,
+	}).Info("User timed out of chat")
+	return sanction, nil
+}
+
+func (s *sanctionService) LiftTimeout(ctx context.Context, username, actor string) error {
+	lifted, err := s.sanctionRepo.Lift(ctx, username, model.SanctionTimeout, actor, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to lift timeout: %w", err)
+	}
+	if lifted == 0 {
+		return ErrTimeoutNotFound
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"username":  username,
+		"lifted_by": actor,
+	}).Info("Chat timeout lifted")
+	return nil
+}
+
+func (s *sanctionService) ActiveTimeout(ctx context.Context, username string) (*model.Sanction, error) {
+	sanction, err := s.sanctionRepo.GetActive(ctx, username, model.SanctionTimeout, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeout: %w", err)
+	}
+	return sanction, nil
+}
+
+func (s *sanctionService) History(ctx context.Context, username string) ([]*model.Sanction, error) {
+	sanctions, err := s.sanctionRepo.ListByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sanctions: %w", err)
+	}
+	return sanctions, nil
+}
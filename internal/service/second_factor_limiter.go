@@ -0,0 +1,90 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+)
+
+// secondFactorLimiter locks the second login factor of an account after repeated wrong
+// codes, see config.TwoFactorConfig. Each login opens a new challenge with its own
+// attempts, so without it knowing the password would allow guessing codes indefinitely.
+type secondFactorLimiter struct {
+	cfg      config.TwoFactorConfig
+	clock    Clock
+	mu       sync.Mutex
+	failures map[string]*loginFailures // by user ID
+}
+
+func newSecondFactorLimiter(cfg config.TwoFactorConfig, clock Clock) *secondFactorLimiter {
+	return &secondFactorLimiter{
+		cfg:      cfg,
+		clock:    clock,
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+// Locked reports whether the account reached MaxFailures within Lockout of the last one
+func (l *secondFactorLimiter) Locked(userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	failures := l.current(userID, l.clock.Now())
+	return failures != nil && failures.count >= l.cfg.MaxFailures
+}
+
+// Fail records a wrong code for the account
+func (l *secondFactorLimiter) Fail(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if len(l.failures) >= maxThrottleEntries {
+		for key := range l.failures {
+			l.current(key, now)
+		}
+	}
+	if len(l.failures) >= maxThrottleEntries {
+		l.evictOldest(len(l.failures) - maxThrottleEntries + throttleEvictBatch)
+	}
+	failures := l.current(userID, now)
+	if failures == nil {
+		failures = &loginFailures{}
+		l.failures[userID] = failures
+	}
+	failures.count++
+	failures.last = now
+}
+
+// Succeed clears the failures of the account
+func (l *secondFactorLimiter) Succeed(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, userID)
+}
+
+// evictOldest drops the n accounts with the oldest last failure. The lock must be held.
+func (l *secondFactorLimiter) evictOldest(n int) {
+	keys := make([]string, 0, len(l.failures))
+	for key := range l.failures {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return l.failures[keys[i]].last.Before(l.failures[keys[j]].last) })
+	for _, key := range keys[:min(n, len(keys))] {
+		delete(l.failures, key)
+	}
+}
+
+// current returns the failures of an account, dropping them once they are older than
+// Lockout. The lock must be held.
+func (l *secondFactorLimiter) current(userID string, now time.Time) *loginFailures {
+	failures := l.failures[userID]
+	if failures != nil && now.Sub(failures.last) >= l.cfg.Lockout {
+		delete(l.failures, userID)
+		return nil
+	}
+	return failures
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionExpired is returned by TouchSession when the session of the token expired or
+// was revoked, so the token no longer authenticates even though the JWT is still valid
+var ErrSessionExpired = errors.New("session expired")
+
+// maxSessionTouches bounds the touch cache; older entries are pruned past it
+const maxSessionTouches = 10000
+
+// sessionTouch caches the last recorded activity of a session
+type sessionTouch struct {
+	userID    string
+	at        time.Time
+	expiresAt time.Time
+}
+
+// TouchSession records activity on the session of the access token, at most once per
+// auth.sessions.touch_interval, and rejects the token once its session expired or was
+// revoked. With sliding sessions it also extends the session expiry.
+func (s *authService) TouchSession(ctx context.Context, token string) error {
+	cfg := s.config.Auth.Sessions
+	now := s.clock.Now()
+
+	s.touchLock.Lock()
+	touch, ok := s.touches[token]
+	s.touchLock.Unlock()
+	if ok && now.Sub(touch.at) < cfg.TouchInterval {
+		if now.After(touch.expiresAt) {
+			return ErrSessionExpired
+		}
+		return nil
+	}
+
+	session, err := s.sessionRepo.GetByToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || !session.IsValidAt(now) {
+		// Remembered as expired so the lookup is not repeated on every request
+		s.rememberTouch(token, "", now, time.Time{})
+		return ErrSessionExpired
+	}
+
+	expiresAt := session.ExpiresAt
+	if cfg.Sliding {
+		expiresAt = now.Add(cfg.IdleTimeout)
+	}
+	if err := s.sessionRepo.Touch(ctx, session.ID, now, expiresAt); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	s.rememberTouch(token, session.UserID.Hex(), now, expiresAt)
+	return nil
+}
+
+func (s *authService) rememberTouch(token, userID string, now, expiresAt time.Time) {
+	s.touchLock.Lock()
+	defer s.touchLock.Unlock()
+
+	if len(s.touches) >= maxSessionTouches {
+		for cached, touch := range s.touches {
+			if now.Sub(touch.at) >= s.config.Auth.Sessions.TouchInterval {
+				delete(s.touches, cached)
+			}
+		}
+	}
+	s.touches[token] = sessionTouch{userID: userID, at: now, expiresAt: expiresAt}
+}
+
+// forgetTouches drops the cached sessions of the user other than the one of keep, so
+// tokens of sessions revoked here are rejected on their next request rather than after
+// auth.sessions.touch_interval
+func (s *authService) forgetTouches(userID, keep string) {
+	s.touchLock.Lock()
+	defer s.touchLock.Unlock()
+
+	for token, touch := range s.touches {
+		if touch.userID == userID && token != keep {
+			delete(s.touches, token)
+		}
+	}
+}
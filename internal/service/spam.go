@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/pkg/spam"
+)
+
+var ErrMessageSpam = errors.New("message looks like spam")
+
+// spamActionRank orders actions from the mildest, the strictest matched action applies
+var spamActionRank = map[string]int{
+	config.SpamActionFlag:        1,
+	config.SpamActionShadowLimit: 2,
+	config.SpamActionBlock:       3,
+}
+
+// SpamStats counts the messages caught by spam detection since startup
+type SpamStats struct {
+	Reasons map[string]int64 `json:"reasons"` // messages per matched heuristic
+	Actions map[string]int64 `json:"actions"` // messages per applied action
+}
+
+// spamVerdict is the outcome of screening a message
+type spamVerdict struct {
+	reasons []string
+	action  string // empty when nothing matched
+}
+
+// spamScreen applies the configured actions to the heuristics of pkg/spam and counts the results
+type spamScreen struct {
+	detector *spam.Detector
+	actions  map[string]string
+
+	mu    sync.Mutex
+	stats SpamStats
+}
+
+func newSpamScreen(cfg config.SpamConfig) *spamScreen {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &spamScreen{
+		detector: spam.New(spam.Options{
+			AllowDomains:   cfg.AllowDomains,
+			DenyDomains:    cfg.DenyDomains,
+			RepeatLimit:    cfg.RepeatLimit,
+			RepeatWindow:   cfg.RepeatWindow,
+			CapsRatio:      cfg.CapsRatio,
+			CapsMinLetters: cfg.CapsMinLetters,
+			MaxEmoji:       cfg.MaxEmoji,
+		}),
+		actions: cfg.Actions,
+		stats:   SpamStats{Reasons: make(map[string]int64), Actions: make(map[string]int64)},
+	}
+}
+
+// screen checks a new message, or an edit when edit is set, which skips repeat detection
+func (s *spamScreen) screen(from, text string, edit bool) spamVerdict {
+	if s == nil {
+		return spamVerdict{}
+	}
+
+	var reasons []spam.Reason
+	if edit {
+		reasons = s.detector.Inspect(text)
+	} else {
+		reasons = s.detector.Check(from, text, time.Now())
+	}
+	if len(reasons) == 0 {
+		return spamVerdict{}
+	}
+
+	var verdict spamVerdict
+	for _, reason := range reasons {
+		verdict.reasons = append(verdict.reasons, string(reason))
+		if action := s.actions[string(reason)]; spamActionRank[action] > spamActionRank[verdict.action] {
+			verdict.action = action
+		}
+	}
+
+	s.mu.Lock()
+	for _, reason := range verdict.reasons {
+		s.stats.Reasons[reason]++
+	}
+	s.stats.Actions[verdict.action]++
+	s.mu.Unlock()
+
+	return verdict
+}
+
+func (s *spamScreen) snapshot() SpamStats {
+	stats := SpamStats{Reasons: make(map[string]int64), Actions: make(map[string]int64)}
+	if s == nil {
+		return stats
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for reason, count := range s.stats.Reasons {
+		stats.Reasons[reason] = count
+	}
+	for action, count := range s.stats.Actions {
+		stats.Actions[action] = count
+	}
+	return stats
+}
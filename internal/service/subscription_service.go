@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrStaleBillingEvent = errors.New("billing event is older than the current subscription")
+
+// SubscriptionService manages the paid entitlements of accounts, see model.Subscription
+type SubscriptionService interface {
+	// Grant sets the user's subscription, replacing any previous one
+	Grant(ctx context.Context, username string, req *model.SubscriptionRequest, source string) (*model.User, error)
+	// Revoke moves the user to the free tier, clearing the premium flag set before
+	// subscriptions too
+	Revoke(ctx context.Context, username string, source string) (*model.User, error)
+	// HandleBillingEvent applies an event of the billing provider source. Events that
+	// occurred before the last update of the subscription return ErrStaleBillingEvent,
+	// so redelivered and reordered events do not undo newer ones.
+	HandleBillingEvent(ctx context.Context, source string, e *model.BillingEvent) (*model.User, error)
+}
+
+type subscriptionService struct {
+	userRepo repository.UserRepository
+	logger   *logrus.Logger
+	clock    Clock
+}
+
+func NewSubscriptionService(userRepo repository.UserRepository, logger *logrus.Logger, opts ...Option) SubscriptionService {
+	deps := newServiceDeps(opts)
+	return &subscriptionService{
+		userRepo: userRepo,
+		logger:   logger,
+		clock:    deps.clock,
+	}
+}
+
+func (s *subscriptionService) Grant(ctx context.Context, username string, req *model.SubscriptionRequest, source string) (*model.User, error) {
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Subscription = &model.Subscription{
+		Tier:      req.Tier,
+		ExpiresAt: req.ExpiresAt,
+		Source:    source,
+		Reference: req.Reference,
+		UpdatedAt: s.clock.Now(),
+	}
+	return user, s.save(ctx, user)
+}
+
+func (s *subscriptionService) Revoke(ctx context.Context, username string, source string) (*model.User, error) {
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// A free subscription rather than none, so its UpdatedAt still orders billing events
+	user.Subscription = &model.Subscription{Tier: model.TierFree, Source: source, UpdatedAt: s.clock.Now()}
+	user.IsPremium = false
+	return user, s.save(ctx, user)
+}
+
+func (s *subscriptionService) HandleBillingEvent(ctx context.Context, source string, e *model.BillingEvent) (*model.User, error) {
+	user, err := s.getUser(ctx, e.Username)
+	if err != nil {
+		return nil, err
+	}
+	if current := user.Subscription; current != nil && e.OccurredAt.Before(current.UpdatedAt) {
+		return nil, ErrStaleBillingEvent
+	}
+
+	tier := e.Tier
+	if tier == "" {
+		tier = model.TierPremium
+	}
+	subscription := &model.Subscription{
+		Tier:      tier,
+		ExpiresAt: e.ExpiresAt,
+		Source:    source,
+		Reference: e.Reference,
+		UpdatedAt: e.OccurredAt,
+	}
+	// A canceled subscription stays until the end of the paid period, when given
+	if e.Type == model.BillingEventCanceled && e.ExpiresAt == nil {
+		subscription.ExpiresAt = &e.OccurredAt
+	}
+	user.Subscription = subscription
+
+	if err := s.save(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user":    user.Username,
+		"event":   e.ID,
+		"type":    e.Type,
+		"tier":    tier,
+		"expires": subscription.ExpiresAt,
+	}).Info("Applied billing event")
+	return user, nil
+}
+
+func (s *subscriptionService) getUser(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *subscriptionService) save(ctx context.Context, user *model.User) error {
+	user.UpdatedAt = s.clock.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/pkg/translate"
+
+	"github.com/sirupsen/logrus"
+)
+
+type TranslationService interface {
+	Enabled() bool
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+type translationService struct {
+	provider translate.Provider
+	config   *config.TranslationConfig
+	logger   *logrus.Logger
+
+	cacheLock sync.Mutex
+	cache     map[translationKey]*list.Element
+	lru       *list.List
+}
+
+type translationKey struct {
+	text   string
+	source string
+	target string
+}
+
+type translationEntry struct {
+	key       translationKey
+	text      string
+	expiresAt time.Time
+}
+
+// NewTranslationService wraps the provider with an LRU cache. A nil provider disables translation.
+func NewTranslationService(provider translate.Provider, cfg *config.Config, logger *logrus.Logger) TranslationService {
+	return &translationService{
+		provider: provider,
+		config:   &cfg.Translation,
+		logger:   logger,
+		cache:    make(map[translationKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (s *translationService) Enabled() bool {
+	return s.provider != nil && s.config.Enabled
+}
+
+// Translate returns the cached translation if present, otherwise asks the provider
+func (s *translationService) Translate(ctx context.Context, text, source, target string) (string, error) {
+	key := translationKey{text: text, source: source, target: target}
+	if cached, ok := s.getCached(key); ok {
+		return cached, nil
+	}
+
+	translated, err := s.provider.Translate(ctx, text, source, target)
+	if err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"source": source,
+			"target": target,
+		}).Warn("Translation failed")
+		return "", err
+	}
+
+	s.putCached(key, translated)
+	return translated, nil
+}
+
+func (s *translationService) getCached(key translationKey) (string, bool) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	elem, ok := s.cache[key]
+	if !ok {
+		return "", false
+	}
+
+	entry := elem.Value.(*translationEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.lru.Remove(elem)
+		delete(s.cache, key)
+		return "", false
+	}
+
+	s.lru.MoveToFront(elem)
+	return entry.text, true
+}
+
+func (s *translationService) putCached(key translationKey, text string) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	entry := &translationEntry{key: key, text: text, expiresAt: time.Now().Add(s.config.CacheTTL)}
+	if elem, ok := s.cache[key]; ok {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	s.cache[key] = s.lru.PushFront(entry)
+	for s.lru.Len() > s.config.CacheSize {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.cache, oldest.Value.(*translationEntry).key)
+	}
+}
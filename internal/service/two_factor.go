@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/pkg/totp"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+)
+
+// recoveryCodeLength is the length of a recovery code without the separator
+const recoveryCodeLength = 10
+
+// SetupTwoFactor generates a new TOTP secret for the user. 2FA is not enforced until
+// the user confirms a code from their authenticator app with EnableTwoFactor.
+func (s *authService) SetupTwoFactor(ctx context.Context, userID string) (*model.TwoFactorSetupResponse, error) {
+	user, err := s.getUserForTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	user.TwoFactorSecret = secret
+	user.UpdatedAt = s.clock.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to save secret: %w", err)
+	}
+
+	return &model.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.config.Auth.TwoFactor.Issuer, user.Username, secret),
+	}, nil
+}
+
+// EnableTwoFactor turns on 2FA after verifying a code for the secret from SetupTwoFactor
+// and returns the recovery codes, which are only shown once
+func (s *authService) EnableTwoFactor(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.getUserForTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+	if !totp.Validate(user.TwoFactorSecret, code, s.clock.Now()) {
+		return nil, ErrInvalidVerificationCode
+	}
+
+	recoveryCodes := s.resetRecoveryCodes(user)
+	user.TwoFactorEnabled = true
+	user.UpdatedAt = s.clock.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	s.logger.WithField("user_id", userID).Info("Two-factor authentication enabled")
+	return recoveryCodes, nil
+}
+
+// DisableTwoFactor turns off 2FA, confirmed with an authenticator or recovery code
+func (s *authService) DisableTwoFactor(ctx context.Context, userID, code string) error {
+	user, err := s.getUserForTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if valid, _ := s.checkSecondFactor(user, code, true); !valid {
+		return ErrInvalidVerificationCode
+	}
+
+	user.TwoFactorEnabled = false
+	user.TwoFactorSecret = ""
+	user.RecoveryCodes = nil
+	user.UpdatedAt = s.clock.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	s.logger.WithField("user_id", userID).Info("Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, confirmed with an authenticator code
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.getUserForTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if valid, _ := s.checkSecondFactor(user, code, false); !valid {
+		return nil, ErrInvalidVerificationCode
+	}
+
+	recoveryCodes := s.resetRecoveryCodes(user)
+	user.UpdatedAt = s.clock.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+
+	return recoveryCodes, nil
+}
+
+// startTwoFactorChallenge records a pending login that must be completed with VerifyLogin.
+// Earlier open challenges are invalidated, so each login leaves one to guess at.
+func (s *authService) startTwoFactorChallenge(ctx context.Context, user *model.User, ipAddress, userAgent string) (*model.LoginChallenge, error) {
+	if err := s.verificationRepo.InvalidateOpen(ctx, user.ID, model.VerificationPurposeLoginTOTP); err != nil {
+		return nil, fmt.Errorf("failed to invalidate login challenges: %w", err)
+	}
+	verification := model.NewVerificationCode(user.ID, model.VerificationPurposeLoginTOTP, "", ipAddress, userAgent,
+		s.clock.Now(), s.config.Auth.StepUp.CodeTTL)
+	if err := s.verificationRepo.Create(ctx, verification); err != nil {
+		return nil, fmt.Errorf("failed to save login challenge: %w", err)
+	}
+
+	s.logger.WithField("user_id", user.ID.Hex()).Info("Login requires two-factor authentication")
+
+	return &model.LoginChallenge{
+		ID:        verification.ID.Hex(),
+		Method:    model.ChallengeMethodTOTP,
+		ExpiresAt: verification.ExpiresAt,
+	}, nil
+}
+
+// checkSecondFactor validates an authenticator code, or a recovery code when allowRecovery is set.
+// A matching recovery code is removed from the user, the caller must persist the change.
+func (s *authService) checkSecondFactor(user *model.User, code string, allowRecovery bool) (valid, usedRecoveryCode bool) {
+	if totp.Validate(user.TwoFactorSecret, strings.TrimSpace(code), s.clock.Now()) {
+		return true, false
+	}
+	if !allowRecovery {
+		return false, false
+	}
+
+	hash := hashRecoveryCode(code)
+	for i, stored := range user.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// resetRecoveryCodes replaces the user's recovery codes and returns the new codes in plain text
+func (s *authService) resetRecoveryCodes(user *model.User) []string {
+	count := s.config.Auth.TwoFactor.RecoveryCodes
+	codes := make([]string, count)
+	hashes := make([]string, count)
+	for i := range codes {
+		code := s.codes.RoomCode(recoveryCodeLength)
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	user.RecoveryCodes = hashes
+	return codes
+}
+
+func (s *authService) getUserForTwoFactor(ctx context.Context, userID string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, mustParseObjectID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+// hashRecoveryCode normalizes the code so separators and case do not matter
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UsageService tracks per-user daily usage and checks it against the quotas of the
+// user's tier, see config.QuotaConfig
+type UsageService interface {
+	// ChatQuota returns the user's chat usage today with the limits of the tier, or nil
+	// when quotas are disabled
+	ChatQuota(ctx context.Context, username string, tier model.Tier) (*model.ChatQuota, error)
+	RecordChatStarted(username string)
+	// RecordRoom adds the duration of a closed room to its members' usage, see event.RoomClosed
+	RecordRoom(summary model.RoomSummary)
+}
+
+type usageService struct {
+	usageRepo repository.UsageRepository
+	config    *config.Provider
+	logger    *logrus.Logger
+	clock     Clock
+}
+
+func NewUsageService(
+	usageRepo repository.UsageRepository,
+	config *config.Provider,
+	logger *logrus.Logger,
+	opts ...Option,
+) UsageService {
+	deps := newServiceDeps(opts)
+	return &usageService{
+		usageRepo: usageRepo,
+		config:    config,
+		logger:    logger,
+		clock:     deps.clock,
+	}
+}
+
+func (s *usageService) ChatQuota(ctx context.Context, username string, tier model.Tier) (*model.ChatQuota, error) {
+	quotas := s.config.Get().Chat.Quotas
+	if !quotas.Enabled {
+		return nil, nil
+	}
+
+	now := s.clock.Now()
+	usage, err := s.usageRepo.Get(ctx, username, model.UsageDay(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	if usage == nil {
+		usage = &model.DailyUsage{}
+	}
+
+	limits := config.QuotaLimits{
+		MaxChatsPerDay:       quotas.MaxChatsPerDay,
+		MaxChatMinutesPerDay: quotas.MaxChatMinutesPerDay,
+	}
+	if tier == model.TierPremium {
+		limits = quotas.Premium
+	}
+
+	return &model.ChatQuota{
+		Tier:           tier,
+		ChatsStarted:   usage.ChatsStarted,
+		MaxChats:       limits.MaxChatsPerDay,
+		ChatMinutes:    int(usage.ChatSeconds / 60),
+		MaxChatMinutes: limits.MaxChatMinutesPerDay,
+		ResetAt:        model.UsageResetAt(now),
+	}, nil
+}
+
+func (s *usageService) RecordChatStarted(username string) {
+	if !s.config.Get().Chat.Quotas.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	delta := &model.DailyUsage{Username: username, Day: model.UsageDay(s.clock.Now()), ChatsStarted: 1}
+	if err := s.usageRepo.Increment(ctx, delta); err != nil {
+		s.logger.WithError(err).WithField("user", username).Error("Failed to record started chat")
+	}
+}
+
+func (s *usageService) RecordRoom(summary model.RoomSummary) {
+	if !s.config.Get().Chat.Quotas.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A chat across midnight counts towards the day it ended
+	seconds := int64(summary.Duration().Seconds())
+	day := model.UsageDay(summary.EndedAt)
+	for _, username := range summary.Members {
+		if model.IsBotUsername(username) || seconds == 0 {
+			continue
+		}
+		delta := &model.DailyUsage{Username: username, Day: day, ChatSeconds: seconds}
+		if err := s.usageRepo.Increment(ctx, delta); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"user": username,
+				"room": summary.Code,
+			}).Error("Failed to record chat minutes")
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserAdminService holds the single-account operations of the admin API, which
+// chatmixctl also runs against the database directly in offline mode
+type UserAdminService interface {
+	// ListUsers returns the users matching the filter, all of them for an empty filter
+	ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, error)
+	// SearchUsers returns a page of the admin user directory, with the IP addresses of
+	// each user's sessions
+	SearchUsers(ctx context.Context, query model.UserDirectoryQuery, page repository.PageRequest) (*model.UserDirectoryPage, error)
+	// SetBanned bans or unbans a user; banning also ends their sessions
+	SetBanned(ctx context.Context, username string, banned bool) (*model.User, error)
+	// RevokeSessions ends every session and refresh token of a user
+	RevokeSessions(ctx context.Context, username string) (*model.User, error)
+	// PurgeExpired deletes expired refresh tokens, sessions and captchas
+	PurgeExpired(ctx context.Context) error
+}
+
+type userAdminService struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	sessionRepo      repository.SessionRepository
+	captchaRepo      repository.CaptchaRepository
+	logger           *logrus.Logger
+	clock            Clock
+}
+
+func NewUserAdminService(
+	userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	sessionRepo repository.SessionRepository,
+	captchaRepo repository.CaptchaRepository,
+	logger *logrus.Logger,
+	opts ...Option,
+) UserAdminService {
+	deps := newServiceDeps(opts)
+	return &userAdminService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		captchaRepo:      captchaRepo,
+		logger:           logger,
+		clock:            deps.clock,
+	}
+}
+
+func (s *userAdminService) ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	fetch := func(ctx context.Context, page repository.PageRequest) (*repository.Page[*model.User], error) {
+		return s.userRepo.FindByFilter(ctx, filter, page)
+	}
+	var users []*model.User
+	err := repository.ForEachPage(ctx, repository.MaxPageSize, fetch, func(page []*model.User) error {
+		users = append(users, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *userAdminService) SearchUsers(ctx context.Context, query model.UserDirectoryQuery, request repository.PageRequest) (*model.UserDirectoryPage, error) {
+	result, total, err := s.userRepo.Search(ctx, query, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	users := result.Items
+
+	ids := make([]primitive.ObjectID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	addresses, err := s.sessionRepo.IPAddressesByUsers(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session addresses: %w", err)
+	}
+
+	page := &model.UserDirectoryPage{
+		Users:      make([]map[string]interface{}, len(users)),
+		Total:      total,
+		Limit:      request.Limit,
+		NextCursor: result.Next.Encode(),
+	}
+	for i, user := range users {
+		page.Users[i] = user.ToDirectoryEntry(addresses[user.ID])
+	}
+	return page, nil
+}
+
+func (s *userAdminService) SetBanned(ctx context.Context, username string, banned bool) (*model.User, error) {
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	if _, err := s.userRepo.SetBanned(ctx, []primitive.ObjectID{user.ID}, banned, now); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	user.IsBanned = banned
+	user.BannedAt = nil
+	if banned {
+		user.BannedAt = &now
+		if err := s.revoke(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"username": user.Username,
+		"banned":   banned,
+	}).Info("User ban updated")
+	return user, nil
+}
+
+func (s *userAdminService) RevokeSessions(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revoke(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userAdminService) PurgeExpired(ctx context.Context) error {
+	var errs []error
+	if err := s.refreshTokenRepo.DeleteExpired(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete expired refresh tokens: %w", err))
+	}
+	if err := s.sessionRepo.DeleteExpired(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete expired sessions: %w", err))
+	}
+	if err := s.captchaRepo.DeleteExpired(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete expired captchas: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *userAdminService) getUser(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *userAdminService) revoke(ctx context.Context, userID primitive.ObjectID) error {
+	if err := s.sessionRepo.DeactivateAllByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to deactivate sessions: %w", err)
+	}
+	if err := s.refreshTokenRepo.RevokeAllByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"chatmix-backend/internal/config"
+	"chatmix-backend/internal/model"
+	"chatmix-backend/internal/repository"
+	"chatmix-backend/pkg/passhash"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// tempPasswordLength is the length of the passwords generated for imported accounts
+const tempPasswordLength = 12
+
+// Import file formats
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+var (
+	ErrUserImportInvalid = errors.New("invalid import file")
+	ErrUserImportEmpty   = errors.New("import file has no records")
+)
+
+// userImportColumns are the CSV columns of an import file, in any order after the header.
+// Languages are separated by ";".
+var userImportColumns = []string{"username", "email", "password_hash", "temp_password", "age", "gender", "bio", "languages", "verified"}
+
+// UserImportService imports accounts from another system, such as during a migration.
+// Records are validated with the registration rules and created in batches. Jobs are
+// kept in memory like bulk user jobs.
+type UserImportService interface {
+	// StartJob runs an import in the background
+	StartJob(actor *model.User, records []model.UserImportRecord, dryRun bool) (*model.UserImportJob, error)
+	GetJob(id string) (*model.UserImportJob, bool)
+	// Import runs an import in the caller's goroutine, calling progress with a copy of
+	// the job after every batch
+	Import(ctx context.Context, actor string, records []model.UserImportRecord, dryRun bool, progress func(*model.UserImportJob)) *model.UserImportJob
+}
+
+type userImportService struct {
+	userRepo  repository.UserRepository
+	config    *config.Config
+	logger    *logrus.Logger
+	clock     Clock
+	codes     CodeGenerator
+	validator *validator.Validate
+	jobs      map[string]*model.UserImportJob
+	jobsLock  sync.RWMutex
+}
+
+func NewUserImportService(
+	userRepo repository.UserRepository,
+	config *config.Config,
+	logger *logrus.Logger,
+	opts ...Option,
+) UserImportService {
+	deps := newServiceDeps(opts)
+	return &userImportService{
+		userRepo:  userRepo,
+		config:    config,
+		logger:    logger,
+		clock:     deps.clock,
+		codes:     deps.codes,
+		validator: validator.New(),
+		jobs:      make(map[string]*model.UserImportJob),
+	}
+}
+
+func (s *userImportService) StartJob(actor *model.User, records []model.UserImportRecord, dryRun bool) (*model.UserImportJob, error) {
+	if len(records) == 0 {
+		return nil, ErrUserImportEmpty
+	}
+
+	job := s.newJob(actor.Username, dryRun)
+	s.jobsLock.Lock()
+	s.jobs[job.ID] = job
+	s.pruneJobs()
+	snapshot := *job
+	s.jobsLock.Unlock()
+
+	go s.run(context.Background(), job, records, nil)
+
+	return &snapshot, nil
+}
+
+func (s *userImportService) GetJob(id string) (*model.UserImportJob, bool) {
+	s.jobsLock.RLock()
+	defer s.jobsLock.RUnlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+func (s *userImportService) Import(ctx context.Context, actor string, records []model.UserImportRecord, dryRun bool, progress func(*model.UserImportJob)) *model.UserImportJob {
+	job := s.newJob(actor, dryRun)
+	s.run(ctx, job, records, progress)
+	return job
+}
+
+func (s *userImportService) newJob(actor string, dryRun bool) *model.UserImportJob {
+	return &model.UserImportJob{
+		ID:        primitive.NewObjectID().Hex(),
+		DryRun:    dryRun,
+		Status:    model.BulkJobPending,
+		CreatedBy: actor,
+		CreatedAt: s.clock.Now(),
+	}
+}
+
+func (s *userImportService) run(ctx context.Context, job *model.UserImportJob, records []model.UserImportRecord, progress func(*model.UserImportJob)) {
+	logger := s.logger.WithFields(logrus.Fields{
+		"job_id":  job.ID,
+		"actor":   job.CreatedBy,
+		"dry_run": job.DryRun,
+	})
+
+	s.update(job, func() {
+		now := s.clock.Now()
+		job.Status = model.BulkJobRunning
+		job.StartedAt = &now
+		job.Total = len(records)
+	})
+
+	usernames := make(map[string]bool, len(records))
+	emails := make(map[string]bool, len(records))
+	for start := 0; start < len(records); start += bulkBatchSize {
+		end := min(start+bulkBatchSize, len(records))
+
+		var users []*model.User
+		var rows []int
+		var credentials []model.UserImportCredential
+		var failures []model.UserImportError
+		for i, record := range records[start:end] {
+			row := start + i + 1
+			user, password, err := s.prepare(record, job.DryRun)
+			switch {
+			case err != nil:
+			case usernames[model.UsernameKey(user.Username)]:
+				err = errors.New("username appears twice in the file")
+			case emails[user.Email]:
+				err = errors.New("email appears twice in the file")
+			case job.DryRun:
+				err = s.checkTaken(ctx, user)
+			}
+			if err != nil {
+				failures = append(failures, model.UserImportError{Row: row, Username: record.Username, Error: err.Error()})
+				continue
+			}
+
+			usernames[model.UsernameKey(user.Username)] = true
+			emails[user.Email] = true
+			users = append(users, user)
+			rows = append(rows, row)
+			credentials = append(credentials, model.UserImportCredential{Username: user.Username, Email: user.Email, Password: password})
+		}
+
+		created := len(users)
+		if !job.DryRun && len(users) > 0 {
+			errs, err := s.userRepo.CreateMany(ctx, users)
+			if err != nil {
+				s.finish(job, fmt.Errorf("failed to create users: %w", err))
+				logger.WithError(err).Error("User import failed")
+				return
+			}
+			created = 0
+			for i, err := range errs {
+				if err != nil {
+					failures = append(failures, model.UserImportError{Row: rows[i], Username: users[i].Username, Error: err.Error()})
+					credentials[i].Password = ""
+					continue
+				}
+				created++
+			}
+		}
+
+		s.update(job, func() {
+			job.Processed = end
+			job.Created += created
+			job.Failed += len(failures)
+			sort.Slice(failures, func(i, j int) bool { return failures[i].Row < failures[j].Row })
+			for _, failure := range failures {
+				if len(job.Errors) < model.MaxUserImportErrors {
+					job.Errors = append(job.Errors, failure)
+				}
+			}
+			for _, credential := range credentials {
+				if credential.Password != "" {
+					job.Credentials = append(job.Credentials, credential)
+				}
+			}
+		})
+		if progress != nil {
+			progress(s.snapshot(job))
+		}
+	}
+
+	s.finish(job, nil)
+	logger.WithFields(logrus.Fields{
+		"total":   job.Total,
+		"created": job.Created,
+		"failed":  job.Failed,
+	}).Info("User import completed")
+}
+
+// prepare validates a record with the registration rules and builds its account. password
+// is the generated password of a temp_password record, which is not generated on dry runs.
+func (s *userImportService) prepare(record model.UserImportRecord, dryRun bool) (*model.User, string, error) {
+	record.Username = strings.TrimSpace(record.Username)
+	record.Email = strings.TrimSpace(record.Email)
+	if err := s.validator.Struct(&record); err != nil {
+		return nil, "", fmt.Errorf("validation failed: %w", err)
+	}
+
+	user := model.NewUserWithProfile(record.Username, record.Email, record.Age, record.Gender, record.Bio)
+	user.Languages = record.Languages
+	user.IsVerified = record.Verified
+
+	var password string
+	switch {
+	case record.PasswordHash != "" && record.TempPassword:
+		return nil, "", errors.New("set either password_hash or temp_password")
+	case record.PasswordHash != "":
+		if _, err := passhash.Identify(record.PasswordHash); err != nil {
+			return nil, "", errors.New("password_hash is not a bcrypt or argon2id hash")
+		}
+		user.PasswordHash = record.PasswordHash
+	case record.TempPassword:
+		if !dryRun {
+			password = s.codes.RoomCode(tempPasswordLength)
+			hashed, err := newPasswordHasher(s.config.Auth.Passwords).Hash(password)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to hash password: %w", err)
+			}
+			user.PasswordHash = hashed
+		}
+	default:
+		return nil, "", errors.New("password_hash or temp_password is required")
+	}
+
+	if !user.IsValid(s.config.Features.MaxUsernameLength) {
+		return nil, "", errors.New("invalid user data")
+	}
+	return user, password, nil
+}
+
+// checkTaken reports a taken username or email like CreateMany would
+func (s *userImportService) checkTaken(ctx context.Context, user *model.User) error {
+	existing, err := s.userRepo.GetByUsername(ctx, user.Username)
+	if err != nil {
+		return fmt.Errorf("failed to check username: %w", err)
+	}
+	if existing != nil {
+		return &repository.DuplicateError{Field: "username"}
+	}
+
+	existing, err = s.userRepo.GetByEmail(ctx, user.Email)
+	if err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if existing != nil {
+		return &repository.DuplicateError{Field: "email"}
+	}
+	return nil
+}
+
+func (s *userImportService) snapshot(job *model.UserImportJob) *model.UserImportJob {
+	s.jobsLock.RLock()
+	defer s.jobsLock.RUnlock()
+	snapshot := *job
+	return &snapshot
+}
+
+func (s *userImportService) update(job *model.UserImportJob, fn func()) {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+	fn()
+}
+
+func (s *userImportService) finish(job *model.UserImportJob, err error) {
+	s.update(job, func() {
+		now := s.clock.Now()
+		job.FinishedAt = &now
+		job.Status = model.BulkJobCompleted
+		if err != nil {
+			job.Status = model.BulkJobFailed
+			job.Error = err.Error()
+		}
+	})
+}
+
+// pruneJobs drops the oldest finished jobs beyond the retention limit. Must be called with jobsLock held.
+func (s *userImportService) pruneJobs() {
+	if len(s.jobs) <= maxRetainedBulkJobs {
+		return
+	}
+
+	var finished []*model.UserImportJob
+	for _, job := range s.jobs {
+		if job.IsFinished() {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreatedAt.Before(finished[j].CreatedAt)
+	})
+
+	for _, job := range finished {
+		if len(s.jobs) <= maxRetainedBulkJobs {
+			return
+		}
+		delete(s.jobs, job.ID)
+	}
+}
+
+// ParseUserImport reads the records of an import file: a JSON array of records, or CSV
+// with a header row naming the userImportColumns
+func ParseUserImport(r io.Reader, format string) ([]model.UserImportRecord, error) {
+	switch format {
+	case ImportFormatJSON:
+		var records []model.UserImportRecord
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUserImportInvalid, err)
+		}
+		return records, nil
+	case ImportFormatCSV:
+		return parseUserImportCSV(r)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrUserImportInvalid, format)
+	}
+}
+
+func parseUserImportCSV(r io.Reader) ([]model.UserImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header row", ErrUserImportInvalid)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !containsCode(userImportColumns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrUserImportInvalid, name)
+		}
+		columns[name] = i
+	}
+
+	var records []model.UserImportRecord
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUserImportInvalid, err)
+		}
+
+		value := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		record := model.UserImportRecord{
+			Username:     value("username"),
+			Email:        value("email"),
+			PasswordHash: value("password_hash"),
+			Gender:       model.Gender(value("gender")),
+			Bio:          value("bio"),
+		}
+		if languages := value("languages"); languages != "" {
+			record.Languages = strings.Split(languages, ";")
+		}
+		for column, target := range map[string]*bool{"temp_password": &record.TempPassword, "verified": &record.Verified} {
+			if text := value(column); text != "" {
+				if *target, err = strconv.ParseBool(text); err != nil {
+					return nil, fmt.Errorf("%w: row %d: %s must be true or false", ErrUserImportInvalid, row, column)
+				}
+			}
+		}
+		if text := value("age"); text != "" {
+			if record.Age, err = strconv.Atoi(text); err != nil {
+				return nil, fmt.Errorf("%w: row %d: age must be a number", ErrUserImportInvalid, row)
+			}
+		}
+		records = append(records, record)
+	}
+}
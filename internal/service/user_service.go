@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"chatmix-backend/internal/config"
 	"chatmix-backend/internal/model"
@@ -13,6 +15,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 type UserService interface {
 	CreateUser(ctx context.Context, username string) (*model.User, error)
 	GetUser(ctx context.Context, username string) (*model.User, error)
@@ -20,8 +24,12 @@ type UserService interface {
 	UpdateUser(ctx context.Context, user *model.User) error
 	SetUserOnline(ctx context.Context, username string) error
 	SetUserOffline(ctx context.Context, username string) error
-	GetOnlineUsers(ctx context.Context) ([]*model.User, error)
-	GetAllUsers(ctx context.Context) ([]*model.User, error)
+	// GetOnlineUsers returns a page of the online users, leaving out those who hide their
+	// last seen, so a page may hold fewer users than asked for
+	GetOnlineUsers(ctx context.Context, page repository.PageRequest) (*repository.Page[*model.User], error)
+	GetAllUsers(ctx context.Context, page repository.PageRequest) (*repository.Page[*model.User], error)
+	// Availability counts the online users around the user's active hours
+	Availability(ctx context.Context, user *model.User) (*model.Availability, error)
 	DeleteUser(ctx context.Context, username string) error
 	UserExists(ctx context.Context, username string) (bool, error)
 	ValidateUsername(username string) error
@@ -76,6 +84,10 @@ func (s *userService) CreateUser(ctx context.Context, username string) (*model.U
 	user := model.NewUser(username, username+"@example.com")
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
+		// Created by a concurrent call in the meantime
+		if errors.Is(err, repository.ErrDuplicate) {
+			return s.userRepo.GetByUsername(ctx, username)
+		}
 		s.logger.WithError(err).WithField("username", username).Error("Failed to create user")
 		return nil, fmt.Errorf("failed to save user: %w", err)
 	}
@@ -165,25 +177,88 @@ func (s *userService) SetUserOffline(ctx context.Context, username string) error
 	return nil
 }
 
-func (s *userService) GetOnlineUsers(ctx context.Context) ([]*model.User, error) {
-	users, err := s.userRepo.GetOnlineUsers(ctx)
+func (s *userService) GetOnlineUsers(ctx context.Context, page repository.PageRequest) (*repository.Page[*model.User], error) {
+	users, err := s.userRepo.GetOnlineUsers(ctx, page)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get online users")
 		return nil, fmt.Errorf("failed to get online users: %w", err)
 	}
 
-	s.logger.WithField("count", len(users)).Info("Retrieved online users")
+	users.Items = visibleOnline(users.Items)
+	s.logger.WithField("count", len(users.Items)).Info("Retrieved online users")
 	return users, nil
 }
 
-func (s *userService) GetAllUsers(ctx context.Context) ([]*model.User, error) {
-	users, err := s.userRepo.GetAllUsers(ctx)
+// visibleOnline filters out users who hide their last seen, they do not show up as online
+// either
+func visibleOnline(users []*model.User) []*model.User {
+	visible := users[:0]
+	for _, user := range users {
+		if user.LastSeenVisibility != model.LastSeenHidden {
+			visible = append(visible, user)
+		}
+	}
+	return visible
+}
+
+func (s *userService) Availability(ctx context.Context, user *model.User) (*model.Availability, error) {
+	var online []*model.User
+	err := repository.ForEachPage(ctx, repository.MaxPageSize, s.userRepo.GetOnlineUsers, func(users []*model.User) error {
+		online = append(online, visibleOnline(users)...)
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get online users")
+		return nil, fmt.Errorf("failed to get online users: %w", err)
+	}
+
+	now := time.Now()
+	availability := &model.Availability{TimeZone: user.TimeZone, ActiveHours: user.ActiveHours}
+	window, hasWindow := user.ActiveUTCHours(now)
+	// ByHour is indexed by the user's local hour
+	shift := 0
+	if loc, ok := user.Location(); ok {
+		_, offset := now.In(loc).Zone()
+		shift = offset / 3600
+	}
+
+	for _, other := range online {
+		if other.Username == user.Username {
+			continue
+		}
+		availability.Online++
+
+		hours, ok := other.ActiveUTCHours(now)
+		if !ok {
+			continue
+		}
+		for utc, active := range hours {
+			if active {
+				availability.ByHour[((utc+shift)%24+24)%24]++
+			}
+		}
+		if hasWindow {
+			for utc := range hours {
+				if hours[utc] && window[utc] {
+					availability.Overlapping++
+					break
+				}
+			}
+		} else if hours[now.UTC().Hour()] {
+			availability.Overlapping++
+		}
+	}
+	return availability, nil
+}
+
+func (s *userService) GetAllUsers(ctx context.Context, page repository.PageRequest) (*repository.Page[*model.User], error) {
+	users, err := s.userRepo.GetAllUsers(ctx, page)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get all users")
 		return nil, fmt.Errorf("failed to get all users: %w", err)
 	}
 
-	s.logger.WithField("count", len(users)).Info("Retrieved all users")
+	s.logger.WithField("count", len(users.Items)).Info("Retrieved all users")
 	return users, nil
 }
 
@@ -252,14 +327,18 @@ func (s *userService) GetUserStats(ctx context.Context) (map[string]interface{},
 		return nil, fmt.Errorf("failed to get total user count: %w", err)
 	}
 
-	onlineUsers, err := s.userRepo.GetOnlineUsers(ctx)
+	onlineUsers := 0
+	err = repository.ForEachPage(ctx, repository.MaxPageSize, s.userRepo.GetOnlineUsers, func(users []*model.User) error {
+		onlineUsers += len(users)
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get online users: %w", err)
 	}
 
 	stats := map[string]interface{}{
 		"total_users":         totalUsers,
-		"online_users":        len(onlineUsers),
+		"online_users":        onlineUsers,
 		"max_username_length": s.config.Features.MaxUsernameLength,
 	}
 
@@ -0,0 +1,15 @@
+// Package captcha verifies response tokens from third-party captcha widgets.
+package captcha
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRejected is returned when the provider does not accept the token
+var ErrRejected = errors.New("captcha rejected")
+
+// Verifier checks a response token produced by a captcha widget in the browser
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
@@ -0,0 +1,15 @@
+// Package chatbot provides server-side chat partners for users who would otherwise
+// wait alone for a match.
+package chatbot
+
+import "context"
+
+// Bot generates the messages of a bot participant. Implementations must be safe for
+// concurrent use, since one bot chats in many rooms at once.
+type Bot interface {
+	// Greeting returns the first message sent once a user is in the room, in the room
+	// language when the bot supports it
+	Greeting(language string) string
+	// Reply returns the answer to a partner's message, or false to stay silent
+	Reply(ctx context.Context, language, message string) (string, bool)
+}
@@ -0,0 +1,106 @@
+package chatbot
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Rule answers messages containing any of its keywords
+type Rule struct {
+	Keywords []string
+	Replies  []string
+}
+
+// Script is the dialogue of a scripted bot in one language
+type Script struct {
+	Greeting  string
+	Rules     []Rule
+	Fallbacks []string // used when no rule matches
+}
+
+// Scripted is a keyword-matching bot. Replies of a rule rotate so repeated
+// questions do not get the same answer every time.
+type Scripted struct {
+	scripts         map[string]Script
+	defaultLanguage string
+	turn            atomic.Uint64
+}
+
+// NewScripted returns a bot with the given scripts keyed by language code. Rooms in
+// other languages use the default language script.
+func NewScripted(scripts map[string]Script, defaultLanguage string) *Scripted {
+	return &Scripted{scripts: scripts, defaultLanguage: defaultLanguage}
+}
+
+// NewDefaultScripted returns the built-in Vietnamese and English scripted bot
+func NewDefaultScripted() *Scripted {
+	return NewScripted(map[string]Script{
+		"vi": {
+			Greeting: "Chào bạn! Mình là bot của ChatMix, mình sẽ trò chuyện cùng bạn trong lúc chờ người thật nhé.",
+			Rules: []Rule{
+				{Keywords: []string{"chào", "hello", "hi"}, Replies: []string{"Chào bạn! Hôm nay của bạn thế nào?", "Xin chào! Bạn đang làm gì vậy?"}},
+				{Keywords: []string{"bot", "người", "thật"}, Replies: []string{"Mình là bot thôi, khi có người rảnh bạn có thể bắt đầu cuộc chat mới để gặp người thật."}},
+				{Keywords: []string{"tên"}, Replies: []string{"Mình là bot của ChatMix. Còn bạn tên gì?"}},
+				{Keywords: []string{"buồn", "chán", "mệt"}, Replies: []string{"Nghe có vẻ hôm nay không dễ dàng. Bạn muốn kể thêm không?", "Mong là bạn sẽ thấy khá hơn. Điều gì thường giúp bạn vui lên?"}},
+				{Keywords: []string{"tạm biệt", "bye"}, Replies: []string{"Tạm biệt bạn, chúc bạn một ngày vui!"}},
+			},
+			Fallbacks: []string{
+				"Thú vị đấy! Bạn kể thêm đi.",
+				"Vậy à? Sao bạn lại nghĩ vậy?",
+				"Mình hiểu rồi. Còn điều gì khác khiến bạn quan tâm không?",
+			},
+		},
+		"en": {
+			Greeting: "Hi! I'm the ChatMix bot. I'll keep you company until someone is free to chat.",
+			Rules: []Rule{
+				{Keywords: []string{"hello", "hi", "hey"}, Replies: []string{"Hey there! How is your day going?", "Hi! What are you up to?"}},
+				{Keywords: []string{"bot", "human", "real"}, Replies: []string{"I'm just a bot. Start a new chat later to meet a real person."}},
+				{Keywords: []string{"name"}, Replies: []string{"I'm the ChatMix bot. What's your name?"}},
+				{Keywords: []string{"sad", "bored", "tired"}, Replies: []string{"Sounds like a rough day. Want to talk about it?", "I hope things get better. What usually cheers you up?"}},
+				{Keywords: []string{"bye", "goodbye"}, Replies: []string{"Bye, have a great day!"}},
+			},
+			Fallbacks: []string{
+				"Interesting! Tell me more.",
+				"Really? Why do you think so?",
+				"I see. What else is on your mind?",
+			},
+		},
+	}, "vi")
+}
+
+func (b *Scripted) Greeting(language string) string {
+	return b.script(language).Greeting
+}
+
+func (b *Scripted) Reply(ctx context.Context, language, message string) (string, bool) {
+	script := b.script(language)
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	normalized := " " + strings.Join(words, " ") + " "
+
+	for _, rule := range script.Rules {
+		for _, keyword := range rule.Keywords {
+			if strings.Contains(normalized, " "+keyword+" ") {
+				return b.pick(rule.Replies)
+			}
+		}
+	}
+	return b.pick(script.Fallbacks)
+}
+
+func (b *Scripted) script(language string) Script {
+	if script, ok := b.scripts[language]; ok {
+		return script
+	}
+	return b.scripts[b.defaultLanguage]
+}
+
+func (b *Scripted) pick(replies []string) (string, bool) {
+	if len(replies) == 0 {
+		return "", false
+	}
+	return replies[b.turn.Add(1)%uint64(len(replies))], true
+}
@@ -0,0 +1,128 @@
+// Package disposable recognises email addresses of disposable (throwaway) mail services.
+package disposable
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxListSize caps a fetched list, which stays well under it with a few hundred thousand domains
+const maxListSize = 16 << 20
+
+//go:embed domains.txt
+var builtinDomains string
+
+// Default returns the built-in domains
+func Default() []string {
+	domains, _ := Parse(strings.NewReader(builtinDomains))
+	return domains
+}
+
+// Parse reads a domain list with one domain per line. Blank lines and lines starting
+// with '#' are skipped; domains are lowercased.
+func Parse(r io.Reader) ([]string, error) {
+	var domains []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if domain := NormalizeDomain(line); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains, scanner.Err()
+}
+
+// Fetch downloads a list in the format read by Parse
+func Fetch(ctx context.Context, client *http.Client, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("disposable domain list: unexpected status %d", resp.StatusCode)
+	}
+	return Parse(io.LimitReader(resp.Body, maxListSize))
+}
+
+// NormalizeDomain lowercases a domain and drops a leading "@" or "." and a trailing dot
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimLeft(domain, "@.")
+	return strings.TrimSuffix(domain, ".")
+}
+
+// Domain returns the normalized domain of an email address, or "" when it has none
+func Domain(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return ""
+	}
+	return NormalizeDomain(email[at+1:])
+}
+
+// List is a set of blocked domains. The zero value blocks nothing.
+type List struct {
+	blocked map[string]bool
+	allowed map[string]bool
+}
+
+// New returns a list blocking the domains and their subdomains. An allowed domain and
+// its subdomains are not blocked, even when one of its parent domains is.
+func New(blocked, allowed []string) *List {
+	l := &List{blocked: make(map[string]bool, len(blocked)), allowed: make(map[string]bool, len(allowed))}
+	for _, domain := range allowed {
+		if domain = NormalizeDomain(domain); domain != "" {
+			l.allowed[domain] = true
+		}
+	}
+	for _, domain := range blocked {
+		if domain = NormalizeDomain(domain); domain != "" && !l.allowed[domain] {
+			l.blocked[domain] = true
+		}
+	}
+	return l
+}
+
+// Len returns the number of blocked domains
+func (l *List) Len() int {
+	return len(l.blocked)
+}
+
+// Blocks reports whether the domain is blocked. The most specific listed domain decides,
+// so "a.mailinator.com" may be allowed while "mailinator.com" is blocked.
+func (l *List) Blocks(domain string) bool {
+	domain = NormalizeDomain(domain)
+	for domain != "" {
+		if l.allowed[domain] {
+			return false
+		}
+		if l.blocked[domain] {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// BlocksEmail reports whether the domain of the email address is blocked
+func (l *List) BlocksEmail(email string) bool {
+	return l.Blocks(Domain(email))
+}
@@ -0,0 +1,80 @@
+// Package games holds the turn-based mini-games two chat partners can play in a room.
+// The server keeps the game state and checks every move, so clients only send moves
+// and draw the states they get back.
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownGame = errors.New("unknown game")
+	ErrNotPlayer   = errors.New("you are not playing this game")
+	ErrNotYourTurn = errors.New("it is not your turn")
+	ErrInvalidMove = errors.New("invalid move")
+	ErrGameOver    = errors.New("the game is over")
+)
+
+// Game is one match between two players. Implementations need no locking; callers
+// serialize the calls of a game.
+type Game interface {
+	// Move applies the move of a player, in the JSON shape of the game
+	Move(player string, move json.RawMessage) error
+	// State returns the current state, for every player alike
+	State() State
+}
+
+// State is what clients draw. Fields a game does not use stay empty.
+type State struct {
+	Game    string   `json:"game"`
+	Players []string `json:"players"`
+	Turn    string   `json:"turn,omitempty"` // player to move, empty once the game is over
+	Board   []string `json:"board,omitempty"`
+	Over    bool     `json:"over"`
+	Winner  string   `json:"winner,omitempty"` // empty for a draw
+}
+
+// Factory starts a game between two players; the first one moves first
+type Factory func(players [2]string) Game
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Factory{
+		TicTacToe: NewTicTacToe,
+	}
+)
+
+// Register adds a game, replacing a game with the same name
+func Register(name string, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[strings.ToLower(name)] = factory
+}
+
+// New starts the named game between two players
+func New(name string, players [2]string) (Game, error) {
+	registryLock.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryLock.RUnlock()
+	if !ok {
+		return nil, ErrUnknownGame
+	}
+	return factory(players), nil
+}
+
+// Names lists the registered games, sorted
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,104 @@
+package games
+
+import (
+	"encoding/json"
+)
+
+// TicTacToe is the name of the built-in tic-tac-toe game
+const TicTacToe = "tictactoe"
+
+// ticTacToeLines are the rows, columns and diagonals of the 3x3 board
+var ticTacToeLines = [8][3]int{
+	{0, 1, 2}, {3, 4, 5}, {6, 7, 8},
+	{0, 3, 6}, {1, 4, 7}, {2, 5, 8},
+	{0, 4, 8}, {2, 4, 6},
+}
+
+// ticTacToe is played on cells 0-8, row by row. The first player is X. A move is
+// {"cell": 4}.
+type ticTacToe struct {
+	players [2]string
+	board   [9]string
+	turn    int
+	winner  string
+	over    bool
+}
+
+func NewTicTacToe(players [2]string) Game {
+	return &ticTacToe{players: players}
+}
+
+func (g *ticTacToe) Move(player string, move json.RawMessage) error {
+	index := g.playerIndex(player)
+	switch {
+	case index < 0:
+		return ErrNotPlayer
+	case g.over:
+		return ErrGameOver
+	case index != g.turn:
+		return ErrNotYourTurn
+	}
+
+	var cell struct {
+		Cell *int `json:"cell"`
+	}
+	if err := json.Unmarshal(move, &cell); err != nil || cell.Cell == nil || *cell.Cell < 0 || *cell.Cell > 8 || g.board[*cell.Cell] != "" {
+		return ErrInvalidMove
+	}
+
+	g.board[*cell.Cell] = g.mark(index)
+	g.turn = 1 - g.turn
+	g.finish()
+	return nil
+}
+
+func (g *ticTacToe) State() State {
+	state := State{
+		Game:    TicTacToe,
+		Players: g.players[:],
+		Board:   append([]string(nil), g.board[:]...),
+		Over:    g.over,
+		Winner:  g.winner,
+	}
+	if !g.over {
+		state.Turn = g.players[g.turn]
+	}
+	return state
+}
+
+// finish ends the game on a full line or a full board
+func (g *ticTacToe) finish() {
+	for _, line := range ticTacToeLines {
+		mark := g.board[line[0]]
+		if mark != "" && g.board[line[1]] == mark && g.board[line[2]] == mark {
+			g.over = true
+			g.winner = g.players[0]
+			if mark == g.mark(1) {
+				g.winner = g.players[1]
+			}
+			return
+		}
+	}
+	for _, mark := range g.board {
+		if mark == "" {
+			return
+		}
+	}
+	g.over = true
+}
+
+func (g *ticTacToe) mark(index int) string {
+	if index == 0 {
+		return "X"
+	}
+	return "O"
+}
+
+func (g *ticTacToe) playerIndex(player string) int {
+	for i, name := range g.players {
+		if name == player {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,14 @@
+package geoip
+
+// Location is the approximate position of an IP address
+type Location struct {
+	Country     string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+	CountryName string `json:"country_name,omitempty"`
+	City        string `json:"city,omitempty"`
+	TimeZone    string `json:"time_zone,omitempty"` // IANA time zone name
+}
+
+// Locator resolves IP addresses to locations. Unknown or private addresses yield a nil location.
+type Locator interface {
+	Lookup(ip string) (*Location, error)
+}
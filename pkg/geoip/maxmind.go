@@ -0,0 +1,52 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// MaxMind reads a GeoLite2/GeoIP2 City database
+type MaxMind struct {
+	reader *geoip2.Reader
+}
+
+func NewMaxMind(databasePath string) (*MaxMind, error) {
+	reader, err := geoip2.Open(databasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &MaxMind{reader: reader}, nil
+}
+
+func (m *MaxMind) Lookup(ip string) (*Location, error) {
+	host, _, err := net.SplitHostPort(ip)
+	if err != nil {
+		host = ip
+	}
+
+	parsed := net.ParseIP(host)
+	if parsed == nil || parsed.IsLoopback() || parsed.IsPrivate() {
+		return nil, nil
+	}
+
+	record, err := m.reader.City(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("GeoIP lookup failed: %w", err)
+	}
+	if record.Country.IsoCode == "" {
+		return nil, nil
+	}
+
+	return &Location{
+		Country:     record.Country.IsoCode,
+		CountryName: record.Country.Names["en"],
+		City:        record.City.Names["en"],
+		TimeZone:    record.Location.TimeZone,
+	}, nil
+}
+
+func (m *MaxMind) Close() error {
+	return m.reader.Close()
+}
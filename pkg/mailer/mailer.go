@@ -0,0 +1,25 @@
+// Package mailer sends outbound email: an SMTP transport, per-locale templates and an
+// asynchronous queue that retries failed deliveries.
+package mailer
+
+import "context"
+
+// Message is an email with a plain-text body and an optional HTML alternative
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Noop discards every message. It is used when email delivery is disabled.
+type Noop struct{}
+
+func (Noop) Send(ctx context.Context, msg Message) error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by Queue.Send when no more messages can be buffered
+var ErrQueueFull = errors.New("email queue is full")
+
+// ErrQueueClosed is returned by Queue.Send after Close
+var ErrQueueClosed = errors.New("email queue is closed")
+
+// QueueOptions configures a Queue. Zero values use the defaults noted on each field.
+type QueueOptions struct {
+	Size         int           // buffered messages, default 100
+	Workers      int           // concurrent deliveries, default 1
+	MaxAttempts  int           // deliveries tried per message, default 3
+	RetryBackoff time.Duration // wait before the first retry, doubled for each next one; default 5s
+	SendTimeout  time.Duration // bound of a single delivery, default 30s
+	// OnError is called with the message and last error once every attempt failed
+	OnError func(msg Message, err error)
+}
+
+// Queue is a Mailer that returns immediately and delivers messages in the background,
+// retrying failed deliveries with exponential backoff
+type Queue struct {
+	mailer    Mailer
+	opts      QueueOptions
+	jobs      chan Message
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+	abort     chan struct{} // closed when Close gives up waiting
+	abortOnce sync.Once
+}
+
+func NewQueue(mailer Mailer, opts QueueOptions) *Queue {
+	if opts.Size <= 0 {
+		opts.Size = 100
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 5 * time.Second
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = 30 * time.Second
+	}
+
+	q := &Queue{
+		mailer: mailer,
+		opts:   opts,
+		jobs:   make(chan Message, opts.Size),
+		abort:  make(chan struct{}),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Send queues the message. The context only bounds the enqueue, not the delivery.
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be delivered. When ctx
+// ends first, pending retries are abandoned and the context error is returned.
+func (q *Queue) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		close(q.jobs)
+		q.mu.Unlock()
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		q.abortOnce.Do(func() { close(q.abort) })
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for msg := range q.jobs {
+		q.deliver(msg)
+	}
+}
+
+func (q *Queue) deliver(msg Message) {
+	backoff := q.opts.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := q.send(msg)
+		if err == nil {
+			return
+		}
+		if attempt >= q.opts.MaxAttempts || !q.wait(backoff) {
+			if q.opts.OnError != nil {
+				q.opts.OnError(msg, err)
+			}
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// wait sleeps before a retry and reports false when the queue was aborted meanwhile
+func (q *Queue) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-q.abort:
+		return false
+	}
+}
+
+func (q *Queue) send(msg Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.opts.SendTimeout)
+	defer cancel()
+	return q.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template names shipped with the package
+const (
+	TemplateLoginCode = "login_code"
+)
+
+// ErrTemplateNotFound is returned when a template exists in neither the requested nor the default locale
+var ErrTemplateNotFound = errors.New("email template not found")
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Templates renders emails from per-locale templates laid out as <locale>/<name>.txt with an
+// optional <locale>/<name>.html. The text template defines the subject in a "subject" block:
+//
+//	{{define "subject"}}Your login code{{end}}
+//	Your code is {{.Code}}.
+type Templates struct {
+	text          map[string]*texttemplate.Template // keyed by locale/name
+	html          map[string]*htmltemplate.Template
+	defaultLocale string
+}
+
+// NewTemplates loads the built-in templates, then the ones in dir which replace built-in
+// templates of the same locale and name. An empty dir uses only the built-in templates.
+func NewTemplates(dir, defaultLocale string) (*Templates, error) {
+	t := &Templates{
+		text:          make(map[string]*texttemplate.Template),
+		html:          make(map[string]*htmltemplate.Template),
+		defaultLocale: defaultLocale,
+	}
+
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.load(builtin); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := t.load(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("failed to load email templates from %s: %w", dir, err)
+		}
+	}
+	return t, nil
+}
+
+func (t *Templates) load(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case strings.HasSuffix(path, ".txt"):
+			key := strings.TrimSuffix(path, ".txt")
+			tmpl, err := texttemplate.New(key).Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("invalid template %s: %w", path, err)
+			}
+			if tmpl.Lookup("subject") == nil {
+				return fmt.Errorf("template %s has no subject block", path)
+			}
+			t.text[key] = tmpl
+		case strings.HasSuffix(path, ".html"):
+			key := strings.TrimSuffix(path, ".html")
+			tmpl, err := htmltemplate.New(key).Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("invalid template %s: %w", path, err)
+			}
+			t.html[key] = tmpl
+		}
+		return nil
+	})
+}
+
+// Render builds the message for a template in the locale, falling back to the default
+// locale when the template is not translated. The recipient is left for the caller.
+func (t *Templates) Render(name, locale string, data interface{}) (Message, error) {
+	key := locale + "/" + name
+	text, ok := t.text[key]
+	if !ok {
+		key = t.defaultLocale + "/" + name
+		if text, ok = t.text[key]; !ok {
+			return Message{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+		}
+	}
+
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", key, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s: %w", key, err)
+	}
+
+	msg := Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()),
+	}
+	if html, ok := t.html[key]; ok {
+		var buf bytes.Buffer
+		if err := html.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("failed to render %s html: %w", key, err)
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
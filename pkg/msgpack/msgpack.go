@@ -0,0 +1,407 @@
+// Package msgpack encodes the JSON data model in MessagePack (https://msgpack.org):
+// nil, booleans, numbers, strings, arrays and string-keyed maps. It converts JSON
+// documents to MessagePack and back, so a protocol defined in JSON can be offered in
+// the more compact binary form without a second schema.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxDepth bounds the nesting of decoded arrays and maps
+const maxDepth = 64
+
+var (
+	// ErrUnsupported is returned for values outside the JSON data model, such as
+	// extension types or maps with non-string keys
+	ErrUnsupported = errors.New("msgpack: unsupported type")
+	// ErrMalformed is returned for truncated or invalid input
+	ErrMalformed = errors.New("msgpack: malformed data")
+)
+
+// FromJSON converts a JSON document to MessagePack
+func FromJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return Marshal(value)
+}
+
+// ToJSON converts a MessagePack value to a JSON document. Binary values become base64
+// strings, as encoding/json writes byte slices.
+func ToJSON(data []byte) ([]byte, error) {
+	value, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// Marshal encodes a value of the JSON data model: nil, bool, string, json.Number,
+// float64, int, int64, uint64, []byte, []interface{} and map[string]interface{}. Map
+// keys are written sorted, so equal values encode to equal bytes.
+func Marshal(value interface{}) ([]byte, error) {
+	var e encoder
+	if err := e.encode(value); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes a single MessagePack value into the types Marshal accepts: nil,
+// bool, string, int64, uint64 (above math.MaxInt64), float64, []byte, []interface{}
+// and map[string]interface{}
+func Unmarshal(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrMalformed)
+	}
+	return value, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case string:
+		e.encodeString(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.encodeInt(i)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%w: number %q", ErrUnsupported, v)
+		}
+		e.encodeFloat(f)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			e.encodeInt(int64(v))
+		} else {
+			e.encodeFloat(v)
+		}
+	case int:
+		e.encodeInt(int64(v))
+	case int64:
+		e.encodeInt(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			e.encodeInt(int64(v))
+		} else {
+			e.buf = append(e.buf, 0xcf)
+			e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+		}
+	case []byte:
+		e.encodeBinary(v)
+	case []interface{}:
+		e.encodeLength(len(v), 0x90, 15, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		e.encodeLength(len(v), 0x80, 15, 0xde, 0xdf)
+		for _, key := range keys {
+			e.encodeString(key)
+			if err := e.encode(v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupported, value)
+	}
+	return nil
+}
+
+func (e *encoder) encodeInt(i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		e.buf = append(e.buf, byte(i))
+	case i < 0 && i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *encoder) encodeFloat(f float64) {
+	e.buf = append(e.buf, 0xcb)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+}
+
+func (e *encoder) encodeString(s string) {
+	switch n := len(s); {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) encodeBinary(b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// encodeLength writes the header of an array or map: the fix form up to fixMax
+// entries, then the 16 and 32 bit forms
+func (e *encoder) encodeLength(n int, fix byte, fixMax int, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, code16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, code32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", ErrMalformed)
+	}
+	code, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code >= 0xa0 && code <= 0xbf:
+		return d.string(int(code & 0x1f))
+	case code >= 0x90 && code <= 0x9f:
+		return d.array(int(code&0x0f), depth)
+	case code >= 0x80 && code <= 0x8f:
+		return d.mapping(int(code&0x0f), depth)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := d.next(1 << (code - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		u := readUint(b)
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0:
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int8(b[0])), nil
+	case 0xd1:
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case 0xd2:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case 0xd3:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case 0xca:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.string(n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(n, depth)
+	}
+	return nil, fmt.Errorf("%w: type 0x%02x", ErrUnsupported, code)
+}
+
+func (d *decoder) array(n, depth int) (interface{}, error) {
+	// Every item takes at least a byte, which bounds what a forged length allocates
+	if n > len(d.data)-d.pos {
+		return nil, ErrMalformed
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *decoder) mapping(n, depth int) (interface{}, error) {
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, ErrMalformed
+	}
+	entries := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: map key %T", ErrUnsupported, key)
+		}
+		if entries[name], err = d.decode(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func (d *decoder) string(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// length reads a big-endian length of size bytes
+func (d *decoder) length(size int) (int, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	return int(readUint(b)), nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, ErrMalformed
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func readUint(b []byte) uint64 {
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u
+}
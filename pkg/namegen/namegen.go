@@ -0,0 +1,164 @@
+// Package namegen generates friendly display names in the adjective and animal style,
+// such as "CalmOtter" or "BrightFalcon42", for participants without a chosen name.
+// Combinations that read as offensive are never returned.
+package namegen
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"chatmix-backend/pkg/profanity"
+)
+
+// DefaultAdjectives are kind or neutral words only, so no pairing reads as an insult
+var DefaultAdjectives = []string{
+	"Amber", "Brave", "Breezy", "Bright", "Calm", "Cheerful", "Clever", "Cosmic", "Cozy", "Curious",
+	"Daring", "Dreamy", "Eager", "Fancy", "Gentle", "Golden", "Happy", "Honest", "Jolly", "Kind",
+	"Lively", "Lucky", "Mellow", "Merry", "Mighty", "Misty", "Noble", "Patient", "Polite", "Quick",
+	"Quiet", "Rapid", "Silver", "Sleepy", "Snowy", "Sunny", "Swift", "Tidy", "Witty", "Zesty",
+}
+
+// DefaultAnimals leave out animal names that double as slurs or insults
+var DefaultAnimals = []string{
+	"Badger", "Beaver", "Bison", "Crane", "Dolphin", "Falcon", "Ferret", "Finch", "Fox", "Gecko",
+	"Heron", "Ibis", "Koala", "Lemur", "Lynx", "Marten", "Meerkat", "Moose", "Narwhal", "Ocelot",
+	"Orca", "Otter", "Owl", "Panda", "Pelican", "Penguin", "Puffin", "Quokka", "Rabbit", "Raven",
+	"Robin", "Salmon", "Seal", "Sparrow", "Squirrel", "Swan", "Tiger", "Toucan", "Turtle", "Walrus",
+}
+
+// DefaultBlockedFragments are checked against the whole lowercased name, so a word
+// formed across the adjective and the animal is caught as well
+var DefaultBlockedFragments = []string{
+	"anal", "anus", "arse", "ass", "boob", "butt", "cock", "cum", "dick", "dyke", "fag",
+	"fuck", "hoe", "homo", "jew", "kkk", "nazi", "nig", "penis", "piss", "poo", "porn",
+	"rape", "retard", "sex", "shit", "slut", "spic", "tit", "twat", "wank", "whore",
+}
+
+// ErrExhausted is returned by Unique when every attempt produced a taken name
+var ErrExhausted = errors.New("namegen: no free name found")
+
+// Generator picks names from its word lists. It is safe for concurrent use.
+type Generator struct {
+	adjectives []string
+	animals    []string
+	fragments  []string
+	words      *profanity.Filter
+	intn       func(n int) int
+}
+
+// Option changes a default of New
+type Option func(*Generator)
+
+// WithWords replaces the word lists
+func WithWords(adjectives, animals []string) Option {
+	return func(g *Generator) {
+		g.adjectives = adjectives
+		g.animals = animals
+	}
+}
+
+// WithBlockedFragments adds fragments no name may contain, on top of the defaults
+func WithBlockedFragments(fragments ...string) Option {
+	return func(g *Generator) {
+		for _, fragment := range fragments {
+			if fragment = strings.ToLower(strings.TrimSpace(fragment)); fragment != "" {
+				g.fragments = append(g.fragments, fragment)
+			}
+		}
+	}
+}
+
+// WithRand replaces the crypto/rand source; intn must return a number in [0, n)
+func WithRand(intn func(n int) int) Option {
+	return func(g *Generator) { g.intn = intn }
+}
+
+func New(opts ...Option) *Generator {
+	g := &Generator{
+		adjectives: DefaultAdjectives,
+		animals:    DefaultAnimals,
+		fragments:  append([]string(nil), DefaultBlockedFragments...),
+		words:      profanity.New(profanity.DefaultWords),
+		intn:       randomIntn,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Allowed reports whether a name is free of blocked fragments and profanity
+func (g *Generator) Allowed(name string) bool {
+	lower := strings.ToLower(name)
+	for _, fragment := range g.fragments {
+		if strings.Contains(lower, fragment) {
+			return false
+		}
+	}
+	return !g.words.Contains(name)
+}
+
+// Generate returns a random allowed name without a number, or "" when the word lists
+// hold no allowed combination
+func (g *Generator) Generate() string {
+	// Random picks first; the word lists are small enough to fall back to a full scan
+	for attempt := 0; attempt < 20; attempt++ {
+		name := g.adjectives[g.intn(len(g.adjectives))] + g.animals[g.intn(len(g.animals))]
+		if g.Allowed(name) {
+			return name
+		}
+	}
+	for _, adjective := range g.adjectives {
+		for _, animal := range g.animals {
+			if name := adjective + animal; g.Allowed(name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Unique returns a generated name that taken reports as free. Names collide rarely at
+// first, so plain names are tried before names with a number of growing length.
+func (g *Generator) Unique(ctx context.Context, taken func(ctx context.Context, name string) (bool, error)) (string, error) {
+	const attempts = 10
+	for attempt := 0; attempt < attempts; attempt++ {
+		name := g.Generate()
+		if name == "" {
+			break
+		}
+		if attempt > 0 {
+			// 2 digits, then 3, then 4
+			digits := min(2+attempt/3, 4)
+			limit := 1
+			for i := 0; i < digits; i++ {
+				limit *= 10
+			}
+			name += strconv.Itoa(limit/10 + g.intn(limit-limit/10))
+		}
+		if !g.Allowed(name) {
+			continue
+		}
+
+		used, err := taken(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return name, nil
+		}
+	}
+	return "", ErrExhausted
+}
+
+func randomIntn(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic("namegen: crypto/rand failed: " + err.Error())
+	}
+	return int(i.Int64())
+}
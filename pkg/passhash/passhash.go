@@ -0,0 +1,176 @@
+// Package passhash hashes passwords with bcrypt or Argon2id. Hashes are self-describing
+// strings carrying the algorithm, its version and parameters: bcrypt's "$2a$<cost>$..."
+// and the PHC format "$argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>".
+// Any supported hash verifies, whichever algorithm is preferred, so stored hashes can be
+// migrated one login at a time.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var (
+	// ErrMismatch is returned when the password does not match the hash
+	ErrMismatch = errors.New("passhash: password does not match")
+	// ErrUnknownHash is returned for hashes of no supported algorithm
+	ErrUnknownHash = errors.New("passhash: unknown hash format")
+)
+
+// Argon2Params are the Argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32 // bytes
+	KeyLength   uint32 // bytes
+}
+
+// Hasher hashes new passwords with the preferred algorithm and verifies hashes of
+// either algorithm
+type Hasher struct {
+	Algorithm  string // Bcrypt or Argon2id
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Identify returns the algorithm of a hash
+func Identify(encoded string) (string, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		if _, _, _, err := decodeArgon2(encoded); err != nil {
+			return "", err
+		}
+		return Argon2id, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(encoded)); err != nil {
+			return "", ErrUnknownHash
+		}
+		return Bcrypt, nil
+	}
+	return "", ErrUnknownHash
+}
+
+// Hash returns the hash of a password with the preferred algorithm
+func (h Hasher) Hash(password string) (string, error) {
+	if h.Algorithm != Argon2id {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.Argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+	return encodeArgon2(h.Argon2, salt, key), nil
+}
+
+// Verify checks a password against a hash of either algorithm; it returns ErrMismatch
+// for a wrong password
+func (h Hasher) Verify(encoded, password string) error {
+	algorithm, err := Identify(encoded)
+	if err != nil {
+		return err
+	}
+
+	if algorithm == Bcrypt {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether a hash should be replaced by a new one: it uses the other
+// algorithm, an older version or weaker parameters than the hasher's
+func (h Hasher) NeedsRehash(encoded string) bool {
+	algorithm, err := Identify(encoded)
+	if err != nil || algorithm != h.preferred() {
+		return true
+	}
+
+	if algorithm == Bcrypt {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost < h.BcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.Argon2.Memory || params.Iterations < h.Argon2.Iterations ||
+		params.Parallelism < h.Argon2.Parallelism || uint32(len(salt)) < h.Argon2.SaltLength ||
+		uint32(len(key)) < h.Argon2.KeyLength
+}
+
+func (h Hasher) preferred() string {
+	if h.Algorithm == Argon2id {
+		return Argon2id
+	}
+	return Bcrypt
+}
+
+var b64 = base64.RawStdEncoding
+
+func encodeArgon2(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+// decodeArgon2 parses a PHC Argon2id hash. Hashes of other Argon2 versions are refused,
+// the library only computes the current one.
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil ||
+		params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,125 @@
+// Package profanity finds and masks blocked words in chat text.
+package profanity
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultWords is used when no word list is configured
+var DefaultWords = []string{
+	"fuck", "fucking", "shit", "bitch", "bastard", "asshole", "cunt", "dick", "motherfucker",
+	"đm", "đmm", "vcl", "clgt", "đéo", "địt", "lồn", "cặc", "đĩ",
+}
+
+// leet maps digits commonly substituted for letters, so "sh1t" matches "shit"
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+}
+
+// Filter matches whole words against a block list, ignoring case and digit substitutions,
+// and optionally regular expressions against the raw text
+type Filter struct {
+	words    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func New(words []string) *Filter {
+	f := &Filter{words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		word = normalize(strings.TrimSpace(word))
+		if word != "" {
+			f.words[word] = true
+		}
+	}
+	return f
+}
+
+// NewWithPatterns returns a filter that also masks every match of the patterns. Patterns
+// are used as given, so they should carry their own (?i) flag.
+func NewWithPatterns(words []string, patterns []*regexp.Regexp) *Filter {
+	f := New(words)
+	f.patterns = patterns
+	return f
+}
+
+// Contains reports whether the text has a blocked word
+func (f *Filter) Contains(text string) bool {
+	_, found := f.Mask(text)
+	return found
+}
+
+// Mask replaces every letter of blocked words with '*' and reports whether any were found
+func (f *Filter) Mask(text string) (string, bool) {
+	runes := []rune(text)
+	found := false
+
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && isWordRune(runes[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		if f.words[normalize(string(runes[start:i]))] {
+			for j := start; j < i; j++ {
+				runes[j] = '*'
+			}
+			found = true
+		}
+		start = -1
+	}
+
+	masked := text
+	if found {
+		masked = string(runes)
+	}
+	for _, pattern := range f.patterns {
+		masked = pattern.ReplaceAllStringFunc(masked, func(match string) string {
+			if match == "" {
+				return match
+			}
+			found = true
+			return strings.Repeat("*", utf8.RuneCountInString(match))
+		})
+	}
+	return masked, found
+}
+
+// IsWord reports whether the text is a single word as the filter splits text, so it can
+// be matched as a blocked word rather than as a phrase
+func IsWord(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+func normalize(word string) string {
+	return strings.Map(func(r rune) rune {
+		if letter, ok := leet[r]; ok {
+			return letter
+		}
+		return unicode.ToLower(r)
+	}, word)
+}
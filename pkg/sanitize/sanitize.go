@@ -0,0 +1,126 @@
+// Package sanitize cleans user-supplied chat text before it is stored or broadcast.
+package sanitize
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrTooLong is returned when the cleaned text exceeds Options.MaxRunes
+var ErrTooLong = errors.New("text is too long")
+
+const (
+	zeroWidthNonJoiner = '\u200c'
+	zeroWidthJoiner    = '\u200d' // joins emoji sequences such as family and profession emoji
+	// maxCombiningMarks caps stacked diacritics ("zalgo" text) on a single base character
+	maxCombiningMarks = 4
+)
+
+// blankRunes render as empty space but are not classified as whitespace by unicode.IsSpace.
+// They are commonly abused to send messages that look empty.
+var blankRunes = map[rune]bool{
+	'\u115f': true, // Hangul Choseong filler
+	'\u1160': true, // Hangul Jungseong filler
+	'\u2800': true, // Braille pattern blank
+	'\u3164': true, // Hangul filler
+	'\uffa0': true, // Halfwidth Hangul filler
+}
+
+type Options struct {
+	MaxRunes int // maximum length after cleaning, 0 means unlimited
+	MaxLines int // lines beyond this are joined onto the last line, 0 means unlimited
+}
+
+// Sanitizer normalizes text to NFC, drops invalid UTF-8, control and invisible formatting
+// characters, collapses runs of whitespace and blank lines, and enforces a length limit.
+type Sanitizer struct {
+	opts Options
+}
+
+func New(opts Options) *Sanitizer {
+	return &Sanitizer{opts: opts}
+}
+
+// Clean returns the sanitized text, which is empty if nothing visible remains
+func (s *Sanitizer) Clean(text string) (string, error) {
+	text = strings.ToValidUTF8(text, "")
+	text = norm.NFC.String(text)
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = cleanLine(line)
+		if line == "" {
+			// Keep at most one blank line between paragraphs
+			if len(lines) > 0 && !blank {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		lines = append(lines, line)
+		blank = false
+	}
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if s.opts.MaxLines > 0 && len(lines) > s.opts.MaxLines {
+		tail := strings.Join(lines[s.opts.MaxLines-1:], " ")
+		lines = append(lines[:s.opts.MaxLines-1], strings.Join(strings.Fields(tail), " "))
+	}
+
+	cleaned := strings.Join(lines, "\n")
+	if s.opts.MaxRunes > 0 && utf8.RuneCountInString(cleaned) > s.opts.MaxRunes {
+		return "", ErrTooLong
+	}
+	return cleaned, nil
+}
+
+// cleanLine drops control and invisible characters, collapses horizontal whitespace
+// to single spaces and trims the line
+func cleanLine(line string) string {
+	var b strings.Builder
+	b.Grow(len(line))
+
+	space := false
+	marks := 0
+	for _, r := range line {
+		switch {
+		case unicode.IsSpace(r) || blankRunes[r]:
+			space = true
+			continue
+		case unicode.IsControl(r) || isInvisible(r):
+			continue
+		case unicode.Is(unicode.Mn, r):
+			if marks >= maxCombiningMarks {
+				continue
+			}
+			marks++
+		default:
+			marks = 0
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// isInvisible reports whether r is a format character with no visible glyph, such as zero-width
+// spaces and bidi overrides. Joiners and tag characters are kept since emoji sequences rely on them.
+func isInvisible(r rune) bool {
+	if r == zeroWidthJoiner || r == zeroWidthNonJoiner || (r >= 0xe0020 && r <= 0xe007f) {
+		return false
+	}
+	return unicode.Is(unicode.Cf, r)
+}
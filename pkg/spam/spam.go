@@ -0,0 +1,182 @@
+// Package spam detects links, repeated messages and shouting in chat text.
+package spam
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Reason names a heuristic that matched a message
+type Reason string
+
+const (
+	ReasonLink       Reason = "link"        // a link to a domain outside the allow list
+	ReasonDeniedLink Reason = "denied_link" // a link to a domain on the deny list
+	ReasonRepeat     Reason = "repeat"      // the sender repeated the same message too often
+	ReasonCaps       Reason = "caps"        // mostly capital letters
+	ReasonEmoji      Reason = "emoji"       // too many emoji
+)
+
+// linkPattern matches URLs with a scheme or www prefix, and bare domains with a common TLD
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+|\b(?:[a-z0-9-]+\.)+` +
+	`(?:com|net|org|info|io|co|me|vn|app|dev|xyz|gg|ly|cc|tk|ru|top|club|shop|site|online|link|live)\b(?:/[^\s<>"]*)?`)
+
+type Options struct {
+	AllowDomains []string // links to these domains and their subdomains are not reported
+	DenyDomains  []string // links to these domains and their subdomains are reported as ReasonDeniedLink
+
+	RepeatLimit  int           // the same text sent this many times within RepeatWindow is a repeat, 0 disables
+	RepeatWindow time.Duration // how long sent texts are remembered per sender
+
+	CapsRatio      float64 // share of capital letters above which a message is shouting, 0 disables
+	CapsMinLetters int     // messages with fewer letters are never shouting
+
+	MaxEmoji int // messages with more emoji are reported, 0 disables
+}
+
+// Detector checks messages against the heuristics. It is safe for concurrent use.
+type Detector struct {
+	opts Options
+
+	mu     sync.Mutex
+	recent map[string][]sent // by sender
+}
+
+type sent struct {
+	text string
+	at   time.Time
+}
+
+func New(opts Options) *Detector {
+	return &Detector{opts: opts, recent: make(map[string][]sent)}
+}
+
+// Check returns the reasons the text looks like spam and remembers it for repeat detection
+func (d *Detector) Check(sender, text string, now time.Time) []Reason {
+	reasons := d.Inspect(text)
+	if d.repeated(sender, text, now) {
+		reasons = append(reasons, ReasonRepeat)
+	}
+	return reasons
+}
+
+// Inspect is Check without repeat detection, for edited messages
+func (d *Detector) Inspect(text string) []Reason {
+	var reasons []Reason
+	if reason, ok := d.links(text); ok {
+		reasons = append(reasons, reason)
+	}
+	if d.shouting(text) {
+		reasons = append(reasons, ReasonCaps)
+	}
+	if d.opts.MaxEmoji > 0 && countEmoji(text) > d.opts.MaxEmoji {
+		reasons = append(reasons, ReasonEmoji)
+	}
+	return reasons
+}
+
+// links reports the strongest link reason: a denied domain wins over an unknown one
+func (d *Detector) links(text string) (Reason, bool) {
+	var found Reason
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		host := linkHost(link)
+		switch {
+		case matchesDomain(host, d.opts.DenyDomains):
+			return ReasonDeniedLink, true
+		case !matchesDomain(host, d.opts.AllowDomains):
+			found = ReasonLink
+		}
+	}
+	return found, found != ""
+}
+
+func (d *Detector) shouting(text string) bool {
+	if d.opts.CapsRatio <= 0 {
+		return false
+	}
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= d.opts.CapsMinLetters && letters > 0 && float64(upper)/float64(letters) > d.opts.CapsRatio
+}
+
+func (d *Detector) repeated(sender, text string, now time.Time) bool {
+	if d.opts.RepeatLimit <= 0 {
+		return false
+	}
+	text = strings.Join(strings.Fields(strings.ToLower(text)), " ")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop expired entries of every sender now and then so the map does not grow unbounded
+	if len(d.recent) > 1000 {
+		for key, entries := range d.recent {
+			if len(entries) == 0 || now.Sub(entries[len(entries)-1].at) > d.opts.RepeatWindow {
+				delete(d.recent, key)
+			}
+		}
+	}
+
+	var entries []sent
+	count := 1
+	for _, entry := range d.recent[sender] {
+		if now.Sub(entry.at) > d.opts.RepeatWindow {
+			continue
+		}
+		entries = append(entries, entry)
+		if entry.text == text {
+			count++
+		}
+	}
+	d.recent[sender] = append(entries, sent{text: text, at: now})
+	return count >= d.opts.RepeatLimit
+}
+
+// linkHost returns the lowercase host of a matched link
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+func countEmoji(text string) int {
+	count := 0
+	for _, r := range text {
+		if isEmoji(r) {
+			count++
+		}
+	}
+	return count
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1F300 && r <= 0x1FAFF) || // pictographs, emoticons, transport, supplemental symbols
+		(r >= 0x2600 && r <= 0x27BF) || // miscellaneous symbols and dingbats
+		(r >= 0x1F1E6 && r <= 0x1F1FF) // regional indicators used by flags
+}
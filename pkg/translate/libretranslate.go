@@ -0,0 +1,75 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LibreTranslate calls a LibreTranslate-compatible HTTP API
+type LibreTranslate struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func NewLibreTranslate(endpoint, apiKey string, timeout time.Duration) *LibreTranslate {
+	return &LibreTranslate{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type libreTranslateResponse struct {
+	TranslatedText string `json:"translatedText"`
+	Error          string `json:"error"`
+}
+
+func (t *LibreTranslate) Translate(ctx context.Context, text, source, target string) (string, error) {
+	body, err := json.Marshal(libreTranslateRequest{
+		Q:      text,
+		Source: source,
+		Target: target,
+		Format: "text",
+		APIKey: t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("translation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result libreTranslateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode translation response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translation provider returned %d: %s", resp.StatusCode, result.Error)
+	}
+
+	return result.TranslatedText, nil
+}
@@ -0,0 +1,8 @@
+package translate
+
+import "context"
+
+// Provider translates text between two languages identified by ISO 639-1 codes
+type Provider interface {
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
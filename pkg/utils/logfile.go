@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chatmix-backend/internal/config"
+)
+
+const logDateLayout = "2006-01-02"
+
+// RotatingFile writes logs to one file per day, named <prefix>-<date>.log, and starts a
+// new file early when the current one reaches the size limit. The switch happens inside
+// Write under the lock, so no entry is dropped or split across files. Rotated files are
+// gzipped in the background when enabled and removed once beyond the retention limits.
+type RotatingFile struct {
+	dir      string
+	prefix   string
+	maxSize  int64
+	maxAge   time.Duration
+	maxFiles int
+	compress bool
+	now      func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	path string
+	day  string
+	size int64
+
+	archiveMu sync.Mutex // serializes compression and retention
+}
+
+// NewRotatingFile opens the log files named after prefix in the logging directory
+func NewRotatingFile(cfg config.LoggingConfig, prefix string) (*RotatingFile, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	w := &RotatingFile{
+		dir:      cfg.Dir,
+		prefix:   prefix,
+		maxSize:  int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxAge:   cfg.MaxAge,
+		maxFiles: cfg.MaxFiles,
+		compress: cfg.Compress,
+		now:      time.Now,
+	}
+
+	file, size, err := w.open(w.dailyPath(w.now()))
+	if err != nil {
+		return nil, err
+	}
+	w.file, w.path, w.day, w.size = file, file.Name(), w.now().Format(logDateLayout), size
+
+	// Archive files left by earlier runs
+	go w.archive()
+
+	return w, nil
+}
+
+func (w *RotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	switch {
+	case now.Format(logDateLayout) != w.day:
+		w.rotateDaily(now)
+	case w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize:
+		w.rotateBySize(now)
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// rotateDaily switches to the file of the new day. The new file is opened before the
+// old one is closed; on failure logging continues in the old file.
+func (w *RotatingFile) rotateDaily(now time.Time) {
+	file, size, err := w.open(w.dailyPath(now))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to rotate log file: %v\n", err)
+		return
+	}
+
+	old := w.file
+	w.file, w.path, w.day, w.size = file, file.Name(), now.Format(logDateLayout), size
+	old.Close()
+	go w.archive()
+}
+
+// rotateBySize renames the full file aside, still open, and continues in a fresh file
+// under the daily name. On failure logging continues in the current file.
+func (w *RotatingFile) rotateBySize(now time.Time) {
+	rotated := filepath.Join(w.dir, fmt.Sprintf("%s-%s-%s.log", w.prefix, w.day, now.Format("150405.000")))
+	if err := os.Rename(w.path, rotated); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to rotate log file: %v\n", err)
+		return
+	}
+
+	file, size, err := w.open(w.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to rotate log file: %v\n", err)
+		w.path = rotated
+		return
+	}
+
+	old := w.file
+	w.file, w.size = file, size
+	old.Close()
+	go w.archive()
+}
+
+func (w *RotatingFile) open(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+func (w *RotatingFile) dailyPath(now time.Time) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s-%s.log", w.prefix, now.Format(logDateLayout)))
+}
+
+// archive compresses the rotated files, then applies the retention policy
+func (w *RotatingFile) archive() {
+	w.archiveMu.Lock()
+	defer w.archiveMu.Unlock()
+
+	if w.compress {
+		files, err := w.rotatedFiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list log files: %v\n", err)
+			return
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file.path, ".log") {
+				continue
+			}
+			if err := gzipFile(file.path); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to compress log file %s: %v\n", file.path, err)
+			}
+		}
+	}
+
+	if err := w.removeExpired(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to apply log retention: %v\n", err)
+	}
+}
+
+type rotatedLogFile struct {
+	path    string
+	modTime time.Time
+}
+
+// rotatedFiles lists the log files other than the current one, newest first
+func (w *RotatingFile) rotatedFiles() ([]rotatedLogFile, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	current := filepath.Base(w.path)
+	w.mu.Unlock()
+
+	var files []rotatedLogFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == current || !strings.HasPrefix(name, w.prefix+"-") ||
+			!(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedLogFile{path: filepath.Join(w.dir, name), modTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files, nil
+}
+
+// removeExpired deletes rotated files older than maxAge and all but the newest maxFiles
+func (w *RotatingFile) removeExpired() error {
+	if w.maxAge <= 0 && w.maxFiles <= 0 {
+		return nil
+	}
+
+	files, err := w.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	now := w.now()
+	for i, file := range files {
+		expired := w.maxAge > 0 && now.Sub(file.modTime) > w.maxAge
+		if expired || (w.maxFiles > 0 && i >= w.maxFiles) {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gzipFile replaces path with path.gz, keeping the modification time for retention
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(path)
+}
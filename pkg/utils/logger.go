@@ -4,8 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"time"
+	"sync"
 
 	"chatmix-backend/internal/config"
 
@@ -15,11 +14,7 @@ import (
 func NewLogger(cfg *config.Config) *logrus.Logger {
 	logger := logrus.New()
 
-	level, err := logrus.ParseLevel(cfg.Logging.Level)
-	if err != nil {
-		level = logrus.InfoLevel
-	}
-	logger.SetLevel(level)
+	logger.SetLevel(parseLevel(cfg.Logging.Level, logrus.InfoLevel))
 
 	switch cfg.Logging.Format {
 	case "json":
@@ -33,18 +28,7 @@ func NewLogger(cfg *config.Config) *logrus.Logger {
 		})
 	}
 
-	logsDir := "logs"
-	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		fmt.Printf("Failed to create logs directory: %v\n", err)
-		logger.SetOutput(os.Stdout)
-		return logger
-	}
-
-	currentTime := time.Now()
-	logFileName := fmt.Sprintf("chatmix-%s.log", currentTime.Format("2006-01-02"))
-	logFilePath := filepath.Join(logsDir, logFileName)
-
-	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	logFile, err := NewRotatingFile(cfg.Logging, "chatmix")
 	if err != nil {
 		fmt.Printf("Failed to open log file: %v\n", err)
 		logger.SetOutput(os.Stdout)
@@ -57,6 +41,88 @@ func NewLogger(cfg *config.Config) *logrus.Logger {
 	return logger
 }
 
+// NewAccessLog opens the output of the access log, see config.AccessLogConfig
+func NewAccessLog(cfg config.LoggingConfig) (io.Writer, error) {
+	if cfg.Access.Output == config.AccessLogStdout {
+		return os.Stdout, nil
+	}
+	return NewRotatingFile(cfg, "access")
+}
+
+// Loggers hands out one logger per component. Component loggers share the output and
+// format of the base logger and tag entries with a "component" field; their level comes
+// from logging.components, falling back to logging.level.
+type Loggers struct {
+	base    *logrus.Logger
+	mu      sync.Mutex
+	config  config.LoggingConfig
+	loggers map[string]*logrus.Logger
+}
+
+func NewLoggers(base *logrus.Logger, cfg config.LoggingConfig) *Loggers {
+	return &Loggers{
+		base:    base,
+		config:  cfg,
+		loggers: make(map[string]*logrus.Logger),
+	}
+}
+
+// For returns the logger of a component, creating it on first use
+func (l *Loggers) For(component string) *logrus.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if logger, ok := l.loggers[component]; ok {
+		return logger
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(l.base.Out)
+	logger.SetFormatter(l.base.Formatter)
+	logger.SetLevel(l.level(component))
+	logger.AddHook(componentHook(component))
+	l.loggers[component] = logger
+	return logger
+}
+
+// SetLevels applies new levels to the base and component loggers, e.g. on config reload
+func (l *Loggers) SetLevels(cfg config.LoggingConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = cfg
+	l.base.SetLevel(parseLevel(cfg.Level, logrus.InfoLevel))
+	for component, logger := range l.loggers {
+		logger.SetLevel(l.level(component))
+	}
+}
+
+func (l *Loggers) level(component string) logrus.Level {
+	base := parseLevel(l.config.Level, logrus.InfoLevel)
+	return parseLevel(l.config.Components[component], base)
+}
+
+// parseLevel parses a level name, returning fallback when it is empty or unknown
+func parseLevel(name string, fallback logrus.Level) logrus.Level {
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return fallback
+	}
+	return level
+}
+
+// componentHook adds the component field to every entry of a component logger
+type componentHook string
+
+func (h componentHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h componentHook) Fire(entry *logrus.Entry) error {
+	entry.Data["component"] = string(h)
+	return nil
+}
+
 func LogWithFields(logger *logrus.Logger, fields map[string]interface{}) *logrus.Entry {
 	return logger.WithFields(logrus.Fields(fields))
 }
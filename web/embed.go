@@ -0,0 +1,21 @@
+// Package web holds the production build of the frontend, embedded into the server
+// binary. Build it with `task frontend` (or copy frontend/build to web/dist) before
+// building the server; without it the server only serves the API.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// Dist returns the embedded build, rooted at web/dist
+func Dist() fs.FS {
+	files, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err) // "dist" is a valid path, fs.Sub cannot fail
+	}
+	return files
+}